@@ -0,0 +1,127 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/bench"
+	"github.com/Sudo-Ivan/website-archiver/pkg"
+)
+
+const (
+	// benchUsage lists the bench subcommands
+	benchUsage = "usage: website-archiver bench run|record|compare [flags]"
+	// defaultBaseline is the recorded benchmark baseline of the repository
+	defaultBaseline = "internal/bench/testdata/baseline.json"
+	// defaultBenchThreshold is how much slower a benchmark may get, in percent
+	defaultBenchThreshold = 10.0
+	// percent converts fractions to percentages
+	percent = 100
+)
+
+// errBenchRegression is returned when a benchmark got slower than the baseline allows
+var errBenchRegression = errors.New("benchmarks regressed against the baseline")
+
+// runBench dispatches the bench subcommands: run prints the results of a
+// benchmark run, record saves them as the baseline, and compare fails when
+// they regressed against it
+func runBench(_ context.Context, cfg *config.Config, args []string) error {
+	if len(args) < pkg.OneLength {
+		return errors.New(benchUsage)
+	}
+	mode := args[pkg.FirstIndex]
+	if mode != "run" && mode != "record" && mode != "compare" {
+		return errors.New(benchUsage)
+	}
+	fs := flag.NewFlagSet("bench "+mode, flag.ContinueOnError)
+	filter := fs.String("run", pkg.EmptyString, "Only run benchmarks whose names contain this ("+strings.Join(bench.Names(), ", ")+")")
+	count := fs.Int("count", 3, "Runs of each benchmark; the fastest is kept")
+	baseline := fs.String("baseline", defaultBaseline, "Baseline file to record or compare against")
+	threshold := fs.Float64("threshold", defaultBenchThreshold, "Percent a benchmark may get slower or allocate more before compare fails")
+	configFlags(fs, cfg)
+	if err := fs.Parse(args[pkg.SecondIndex:]); err != nil {
+		return err
+	}
+
+	if *threshold < pkg.ZeroValue {
+		return fmt.Errorf("--threshold must not be negative")
+	}
+
+	// Crawls log every page; keep the results readable
+	restore := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: max(cfg.LogLevel, slog.LevelWarn)})))
+	current, err := bench.Run(cfg, *filter, *count)
+	slog.SetDefault(restore)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case "record":
+		if err := current.Write(*baseline, cfg.FilePerms); err != nil {
+			return err
+		}
+		printResults(os.Stdout, current)
+		slog.Info("Benchmark baseline recorded", "baseline", *baseline)
+		return nil
+	case "compare":
+		base, err := bench.Load(*baseline)
+		if err != nil {
+			return err
+		}
+		changes := bench.Compare(base, current, *threshold/percent)
+		printChanges(os.Stdout, changes)
+		if regressed := bench.Regressions(changes); len(regressed) > pkg.ZeroLength {
+			return fmt.Errorf("%w: %d metrics by more than %.0f%%", errBenchRegression, len(regressed), *threshold)
+		}
+		if base.GOOS != current.GOOS || base.GOARCH != current.GOARCH || base.CPUs != current.CPUs {
+			slog.Warn("Baseline was recorded on another kind of machine", "baseline", fmt.Sprintf("%s/%s, %d CPUs", base.GOOS, base.GOARCH, base.CPUs))
+		}
+		return nil
+	default:
+		printResults(os.Stdout, current)
+		return nil
+	}
+}
+
+// printResults writes benchmark results as a table
+func printResults(w io.Writer, base *bench.Baseline) {
+	tw := tabwriter.NewWriter(w, pkg.ZeroValue, pkg.TabWidth, pkg.TabPadding, ' ', pkg.ZeroValue)
+	fmt.Fprintln(tw, "BENCHMARK\tN\tNS/OP\tALLOCS/OP\tB/OP\tTHROUGHPUT")
+	for _, r := range base.Results {
+		throughput := pkg.EmptyString
+		if r.MBPerSec > pkg.ZeroValue {
+			throughput = fmt.Sprintf("%.1f MB/s", r.MBPerSec)
+		}
+		for unit, v := range r.Extra {
+			throughput = fmt.Sprintf("%.1f %s", v, unit)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", r.Name, r.N, r.NsPerOp, r.AllocsPerOp, r.BytesPerOp, throughput)
+	}
+	tw.Flush()
+}
+
+// printChanges writes a comparison with the baseline as a table
+func printChanges(w io.Writer, changes []bench.Change) {
+	tw := tabwriter.NewWriter(w, pkg.ZeroValue, pkg.TabWidth, pkg.TabPadding, ' ', pkg.ZeroValue)
+	fmt.Fprintln(tw, "BENCHMARK\tMETRIC\tBASELINE\tNOW\tCHANGE\t")
+	for _, c := range changes {
+		mark := pkg.EmptyString
+		if c.Regressed {
+			mark = "REGRESSED"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.0f\t%.0f\t%+.1f%%\t%s\n", c.Name, c.Metric, c.Base, c.Now, c.Delta*percent, mark)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,270 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/cdxcache"
+	"github.com/Sudo-Ivan/website-archiver/internal/downloader"
+	"github.com/Sudo-Ivan/website-archiver/internal/fetcherr"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/retry"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+	"github.com/Sudo-Ivan/website-archiver/internal/wayback"
+	"github.com/Sudo-Ivan/website-archiver/pkg"
+)
+
+// cdxFields are the fields the CDX API can return, in its default order
+var cdxFields = []string{"urlkey", "timestamp", "original", "mimetype", "statuscode", "digest", "length"}
+
+// cdxSlots bounds the CDX queries in flight across the URLs of a run, which
+// are processed concurrently
+var (
+	cdxSlotsOnce sync.Once
+	cdxSlots     chan struct{}
+)
+
+// cdxCache returns the CDX response cache, or nil when caching is disabled
+func cdxCache(cfg *config.Config) *cdxcache.Cache {
+	if cfg.CDXCacheTTL <= pkg.ZeroValue {
+		return nil
+	}
+	cache, err := cdxcache.New(cfg.CDXCacheDir, cfg.CDXCacheTTL, cfg.DirPerms, cfg.FilePerms)
+	if err != nil {
+		slog.Warn("CDX cache disabled", pkg.LogError, err)
+		return nil
+	}
+	return cache
+}
+
+// queryCDX runs a CDX API query and returns the raw rows, header first.
+// Responses are served from the on-disk cache while they are fresh.
+func queryCDX(ctx context.Context, cfg *config.Config, params url.Values) ([][]string, error) {
+	params.Set("output", "json")
+	query := cfg.WaybackAPIURL + "?" + params.Encode()
+	cache := cdxCache(cfg)
+	if cache != nil {
+		if rows, ok := cache.Get(query); ok {
+			slog.Debug("Using cached CDX response", "query", query)
+			return rows, nil
+		}
+	}
+
+	cdxSlotsOnce.Do(func() { cdxSlots = make(chan struct{}, max(cfg.CDXConcurrency, pkg.OneLength)) })
+	select {
+	case cdxSlots <- struct{}{}:
+		defer func() { <-cdxSlots }()
+	case <-ctx.Done():
+		return nil, &fetcherr.FetchError{URL: query, Stage: fetcherr.StageCDX, Err: ctx.Err()}
+	}
+
+	ctx, attempts := retry.TrackAttempts(ctx)
+	status := pkg.ZeroValue
+	fail := func(err error) error {
+		return &fetcherr.FetchError{URL: query, Stage: fetcherr.StageCDX, Status: status, Attempt: attempts(), Err: err}
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", query, nil)
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	resp, err := cfg.HTTPClient().Do(req)
+	if err != nil {
+		return nil, fail(err)
+	}
+	defer resp.Body.Close()
+	status = resp.StatusCode
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fail(fmt.Errorf("failed to read CDX response: %w", err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		if err := wayback.ClassifyBody(params.Get("url"), resp.StatusCode, resp.Header, body); err != nil {
+			return nil, fail(err)
+		}
+		return nil, fail(fmt.Errorf("CDX API error: %s", strings.TrimSpace(string(body))))
+	}
+
+	rows, err := wayback.ParseCDX(body)
+	if err != nil {
+		return nil, fail(err)
+	}
+	if cache != nil {
+		if err := cache.Put(query, rows); err != nil {
+			slog.Warn("Failed to cache CDX response", pkg.LogError, err)
+		}
+	}
+	return rows, nil
+}
+
+// verifySnapshot compares the saved payload of a downloaded snapshot with
+// the digest the CDX API lists and records the outcome on the snapshot's page
+// in the manifest of dir, so truncated replays do not go unnoticed. Pages
+// whose links were rewritten no longer hold the payload and are not compared.
+func verifySnapshot(snapshot CDXResponse, waybackURL, dir string, cfg *config.Config) {
+	if snapshot.Digest == pkg.EmptyString || (snapshot.Status != pkg.EmptyString && snapshot.Status != strconv.Itoa(http.StatusOK)) {
+		// Redirects and error captures are not downloaded as they were archived
+		return
+	}
+	fail := func(err error) {
+		slog.Warn("Failed to verify snapshot", pkg.LogError, err, pkg.LogTimestamp, snapshot.Timestamp)
+	}
+	s, err := downloader.OpenStorage(dir, cfg)
+	if err != nil {
+		fail(err)
+		return
+	}
+	m, err := manifest.Read(s)
+	if err != nil {
+		fail(err)
+		return
+	}
+	var page *manifest.Resource
+	for i := range m.Resources {
+		if m.Resources[i].URL == waybackURL {
+			page = &m.Resources[i]
+			break
+		}
+	}
+	if page == nil || len(page.Chunks) > pkg.ZeroLength {
+		slog.Debug("Snapshot page is not saved as a file, not verifying it", pkg.LogTimestamp, snapshot.Timestamp)
+		return
+	}
+	payload, err := storage.ReadFile(s, page.Path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if sum := sha256.Sum256(payload); hex.EncodeToString(sum[:]) != page.Digest {
+		slog.Debug("Snapshot page was rewritten, not verifying it", pkg.LogTimestamp, snapshot.Timestamp)
+		return
+	}
+
+	capture := wayback.Capture{Timestamp: snapshot.Timestamp, Original: snapshot.Original, Digest: snapshot.Digest}
+	match := capture.Match(payload)
+	if !match {
+		slog.Warn("Snapshot does not match its CDX digest, the Wayback replay may be truncated", pkg.LogTimestamp, snapshot.Timestamp, "expected", snapshot.Digest, "digest", wayback.PayloadDigest(payload), "size", len(payload))
+	}
+	page.CDXDigest = snapshot.Digest
+	page.DigestMismatch = !match
+	if err := m.Save(s); err != nil {
+		slog.Warn("Failed to record snapshot verification", pkg.LogError, err, pkg.LogTimestamp, snapshot.Timestamp)
+	}
+}
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// runCDX lists the Wayback Machine captures of a URL without downloading them
+func runCDX(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("cdx", flag.ContinueOnError)
+	fields := fs.String("fields", "timestamp,original,mimetype,statuscode,digest,length", "Comma-separated fields to output ("+strings.Join(cdxFields, ",")+")")
+	format := fs.String("format", "table", "Output format (table|json|csv)")
+	from := fs.String("from", pkg.EmptyString, "Only captures at or after this timestamp (YYYY[MMDDhhmmss])")
+	to := fs.String("to", pkg.EmptyString, "Only captures at or before this timestamp (YYYY[MMDDhhmmss])")
+	matchType := fs.String("match-type", pkg.EmptyString, "URL match type (exact|prefix|host|domain)")
+	collapse := fs.String("collapse", pkg.EmptyString, "Collapse adjacent captures sharing a field, e.g. digest or timestamp:8")
+	limit := fs.Int("limit", pkg.ZeroValue, "Maximum number of captures (negative for the newest)")
+	var filters stringList
+	fs.Var(&filters, "filter", "CDX filter such as statuscode:200 or !mimetype:image/.* (repeatable)")
+	authFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength {
+		return fmt.Errorf("usage: website-archiver cdx [flags] <url>")
+	}
+	if err := setupAuth(cfg); err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("url", fs.Arg(pkg.FirstIndex))
+	params.Set("fl", *fields)
+	for key, value := range map[string]string{"from": *from, "to": *to, "matchType": *matchType, "collapse": *collapse} {
+		if value != pkg.EmptyString {
+			params.Set(key, value)
+		}
+	}
+	if *limit != pkg.ZeroValue {
+		params.Set("limit", fmt.Sprint(*limit))
+	}
+	for _, f := range filters {
+		params.Add("filter", f)
+	}
+
+	rows, err := queryCDX(ctx, cfg, params)
+	if err != nil {
+		return err
+	}
+	return writeCDXRows(os.Stdout, *format, strings.Split(*fields, ","), rows)
+}
+
+// writeCDXRows prints CDX rows in the requested format. The first row returned
+// by the API is a header and is replaced by the requested field names.
+func writeCDXRows(w io.Writer, format string, fields []string, rows [][]string) error {
+	if len(rows) > pkg.ZeroLength {
+		rows = rows[pkg.OneLength:]
+	}
+
+	switch format {
+	case "json":
+		records := make([]map[string]string, pkg.ZeroLength, len(rows))
+		for _, row := range rows {
+			record := make(map[string]string, len(fields))
+			for i, field := range fields {
+				if i < len(row) {
+					record[field] = row[i]
+				}
+			}
+			records = append(records, record)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent(pkg.EmptyString, "  ")
+		return enc.Encode(records)
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write(fields); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, pkg.ZeroValue, pkg.TabWidth, pkg.TabPadding, ' ', pkg.ZeroValue)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(fields, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format %q (use table, json, or csv)", format)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/simhash"
+	"github.com/Sudo-Ivan/website-archiver/pkg"
+)
+
+// waybackPrefix matches the replay prefix of Wayback Machine URLs
+var waybackPrefix = regexp.MustCompile(`^https?://web\.archive\.org/web/[0-9]+[a-z_]*/`)
+
+// SnapshotChange compares the pages of one snapshot with the previous one.
+type SnapshotChange struct {
+	From        string         `json:"from"`
+	To          string         `json:"to"`
+	Change      simhash.Change `json:"change"`
+	Unchanged   int            `json:"unchanged"`
+	Minor       int            `json:"minor"`
+	Significant int            `json:"significant"`
+	Added       int            `json:"added"`
+	Removed     int            `json:"removed"`
+}
+
+// pageFingerprints maps the original URL of every HTML page in a snapshot to its fingerprint
+func pageFingerprints(dir string) (map[string]simhash.Fingerprint, error) {
+	m, err := manifest.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	pages := make(map[string]simhash.Fingerprint)
+	for _, r := range m.Resources {
+		if r.SimHash == pkg.EmptyString {
+			continue
+		}
+		f, err := simhash.Parse(r.SimHash)
+		if err != nil {
+			continue
+		}
+		pages[waybackPrefix.ReplaceAllString(r.URL, pkg.EmptyString)] = f
+	}
+	return pages, nil
+}
+
+// compareFingerprints classifies how the pages of a snapshot changed
+func compareFingerprints(prev, next map[string]simhash.Fingerprint) SnapshotChange {
+	var c SnapshotChange
+	for page, f := range next {
+		old, ok := prev[page]
+		if !ok {
+			c.Added++
+			continue
+		}
+		switch simhash.Classify(old, f) {
+		case simhash.NoChange:
+			c.Unchanged++
+		case simhash.MinorChange:
+			c.Minor++
+		default:
+			c.Significant++
+		}
+	}
+	for page := range prev {
+		if _, ok := next[page]; !ok {
+			c.Removed++
+		}
+	}
+
+	switch {
+	case c.Significant > pkg.ZeroCount || c.Added > pkg.ZeroCount || c.Removed > pkg.ZeroCount:
+		c.Change = simhash.SignificantChange
+	case c.Minor > pkg.ZeroCount:
+		c.Change = simhash.MinorChange
+	default:
+		c.Change = simhash.NoChange
+	}
+	return c
+}
+
+// detectSnapshotChanges annotates each snapshot with how much its content
+// changed since the previous one and writes the comparison to changes.json
+func detectSnapshotChanges(snapshots []Snapshot, outputDir string, cfg *config.Config) {
+	var changes []SnapshotChange
+	var prev map[string]simhash.Fingerprint
+	for i := range snapshots {
+		pages, err := pageFingerprints(filepath.Join(outputDir, snapshots[i].Path))
+		if err != nil {
+			slog.Warn("Failed to read snapshot fingerprints", pkg.LogError, err, pkg.LogTimestamp, snapshots[i].Timestamp)
+			prev = nil
+			continue
+		}
+		if prev != nil {
+			c := compareFingerprints(prev, pages)
+			c.From, c.To = snapshots[i-pkg.OneIndex].Timestamp, snapshots[i].Timestamp
+			snapshots[i].Change = c.Change
+			changes = append(changes, c)
+			slog.Info("Snapshot change", pkg.LogTimestamp, c.To, "change", c.Change, "significant", c.Significant, "minor", c.Minor, "added", c.Added, "removed", c.Removed)
+		}
+		prev = pages
+	}
+
+	data, err := json.MarshalIndent(changes, pkg.EmptyString, "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(outputDir, pkg.ChangesJSON), data, cfg.FilePerms)
+	}
+	if err != nil {
+		slog.Warn("Failed to write snapshot change report", pkg.LogError, fmt.Errorf("changes report: %w", err))
+	}
+}
@@ -0,0 +1,1179 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/catalog"
+	"github.com/Sudo-Ivan/website-archiver/internal/chunk"
+	"github.com/Sudo-Ivan/website-archiver/internal/downloader"
+	"github.com/Sudo-Ivan/website-archiver/internal/fetcherr"
+	"github.com/Sudo-Ivan/website-archiver/internal/linkcheck"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/profile"
+	"github.com/Sudo-Ivan/website-archiver/internal/proxy"
+	"github.com/Sudo-Ivan/website-archiver/internal/replay"
+	"github.com/Sudo-Ivan/website-archiver/internal/result"
+	"github.com/Sudo-Ivan/website-archiver/internal/retention"
+	"github.com/Sudo-Ivan/website-archiver/internal/schedule"
+	"github.com/Sudo-Ivan/website-archiver/internal/secrets"
+	"github.com/Sudo-Ivan/website-archiver/internal/server"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+	"github.com/Sudo-Ivan/website-archiver/internal/store"
+	"github.com/Sudo-Ivan/website-archiver/internal/torrent"
+	"github.com/Sudo-Ivan/website-archiver/internal/warc"
+	"github.com/Sudo-Ivan/website-archiver/internal/zim"
+	"github.com/Sudo-Ivan/website-archiver/pkg"
+)
+
+// subcommand runs a named mode of the tool with its own arguments.
+type subcommand func(ctx context.Context, cfg *config.Config, args []string) error
+
+// subcommands maps the first command-line argument to the mode it selects.
+// Anything else is treated as a URL for the default download mode.
+var subcommands = map[string]subcommand{
+	"prune":   runPrune,
+	"gc":      runGC,
+	"chunk":   runChunk,
+	"unchunk": runUnchunk,
+	"link":    runLink,
+
+	"annotate": runAnnotate,
+
+	"serve-zim": runServeZIM,
+	"cdx":       runCDX,
+	"check":     runCheck,
+	"replay":    runReplay,
+
+	"proxy-record": runProxyRecord,
+	"fixture":      runFixture,
+	"bench":        runBench,
+	"serve":        runServe,
+	"catalog":      runCatalog,
+	"watch":        runWatch,
+	"rezim":        runRezim,
+	"torrent":      runTorrent,
+	"secret":       runSecret,
+	"config":       runConfig,
+	"profile":      runProfile,
+	"stats":        runStats,
+
+	"proxy-archive": runProxyArchive,
+}
+
+// retentionPolicy builds the retention policy configured in cfg
+func retentionPolicy(cfg *config.Config) retention.Policy {
+	return retention.Policy{
+		KeepLast: cfg.RetentionKeepLast,
+		MaxAge:   time.Duration(cfg.RetentionKeepDays) * pkg.Day,
+		MaxGB:    cfg.RetentionMaxGB,
+	}
+}
+
+// runPrune removes captures that fall outside the retention policy
+func runPrune(_ context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	fs.IntVar(&cfg.RetentionKeepLast, "keep-last", cfg.RetentionKeepLast, "Keep only the newest N captures per collection")
+	fs.IntVar(&cfg.RetentionKeepDays, "keep-days", cfg.RetentionKeepDays, "Remove captures older than this many days")
+	fs.Float64Var(&cfg.RetentionMaxGB, "max-gb", cfg.RetentionMaxGB, "Maximum size of each collection in GB")
+	dryRun := fs.Bool("dry-run", false, "Only report what would be removed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	policy := retentionPolicy(cfg)
+	if !policy.Enabled() {
+		return fmt.Errorf("no retention policy set (use --keep-last, --keep-days, or --max-gb)")
+	}
+	return pruneCaptures(cfg, policy, *dryRun)
+}
+
+// pruneCaptures applies a retention policy to the output directory and logs the result
+func pruneCaptures(cfg *config.Config, policy retention.Policy, dryRun bool) error {
+	removed, err := retention.Prune(cfg.OutputDir, policy, dryRun)
+	var freed int64
+	for _, c := range removed {
+		freed += c.Size
+		slog.Info("Pruned capture", "collection", c.Collection, "paths", c.Paths, "bytes", c.Size, "dryRun", dryRun)
+	}
+	slog.Info("Prune Summary", "removed", len(removed), "freedBytes", freed, "dryRun", dryRun)
+	if err != nil || dryRun || len(removed) == pkg.ZeroLength {
+		return err
+	}
+
+	// Pruned manifests may have been the last references to stored objects
+	orphans, err := store.GC(cfg.OutputDir, false)
+	if len(orphans) > pkg.ZeroLength {
+		slog.Info("Removed orphaned store objects", "count", len(orphans))
+	}
+	return err
+}
+
+// runGC removes store objects that no capture manifest references anymore
+func runGC(_ context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Only report orphaned objects")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	orphans, err := store.GC(cfg.OutputDir, *dryRun)
+	var freed int64
+	for _, obj := range orphans {
+		freed += obj.Size
+		slog.Info("Orphaned object", "digest", obj.Digest, "bytes", obj.Size, "dryRun", *dryRun)
+	}
+	slog.Info("GC Summary", "orphans", len(orphans), "freedBytes", freed, "dryRun", *dryRun)
+	return err
+}
+
+// runChunk moves the large files of a capture into content-defined chunks in
+// the store, so backups of the output directory dedupe across captures
+func runChunk(_ context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("chunk", flag.ContinueOnError)
+	threshold := cfg.ChunkThreshold
+	if threshold == pkg.ZeroValue {
+		threshold = chunk.DefaultMinSize
+	}
+	fs.Func("threshold", "Chunk files of at least this size (default 256K)", func(value string) error {
+		size, err := config.ParseSize(value)
+		threshold = size
+		return err
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength {
+		return fmt.Errorf("usage: website-archiver chunk [--threshold 8M] <capture-dir>")
+	}
+	return chunkCapture(cfg, fs.Arg(pkg.FirstIndex), threshold)
+}
+
+// chunkCapture chunks the files of captureDir into the store of the output directory
+func chunkCapture(cfg *config.Config, captureDir string, threshold int64) error {
+	stats, err := store.Chunk(cfg.OutputDir, captureDir, threshold, chunk.DefaultOptions(), cfg.DirPerms, cfg.FilePerms)
+	slog.Info("Chunk Summary", "capture", captureDir, "files", stats.Files, "bytes", stats.Bytes, "chunks", stats.Chunks)
+	return err
+}
+
+// runUnchunk reassembles the chunked files of a capture into a plain mirror
+func runUnchunk(_ context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("unchunk", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength {
+		return fmt.Errorf("usage: website-archiver unchunk <capture-dir>")
+	}
+	captureDir := fs.Arg(pkg.FirstIndex)
+	stats, err := store.Restore(cfg.OutputDir, captureDir, cfg.DirPerms, cfg.FilePerms)
+	slog.Info("Unchunk Summary", "capture", captureDir, "files", stats.Files, "bytes", stats.Bytes, "chunks", stats.Chunks)
+	return err
+}
+
+// runLink hardlinks the identical files of captures to shared store objects,
+// so snapshots of a site take disk space and inodes only for what changed
+func runLink(_ context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("link", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	dir := cfg.OutputDir
+	switch fs.NArg() {
+	case pkg.ZeroLength:
+	case pkg.OneLength:
+		dir = fs.Arg(pkg.FirstIndex)
+	default:
+		return fmt.Errorf("usage: website-archiver link [capture-dir]")
+	}
+	return linkCapture(cfg, dir)
+}
+
+// linkCapture links the files of the captures below dir to the store of the output directory
+func linkCapture(cfg *config.Config, dir string) error {
+	stats, err := store.Link(cfg.OutputDir, dir, cfg.DirPerms, cfg.FilePerms)
+	slog.Info("Link Summary", "dir", dir, "files", stats.Files, "shared", stats.Shared, "savedBytes", stats.Bytes)
+	return err
+}
+
+// runAnnotate adds or removes tags and sets the note of the captures below a
+// directory, or lists their annotations when no change is asked for
+func runAnnotate(_ context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("annotate", flag.ContinueOnError)
+	var add, remove []string
+	fs.Func("tag", "Add a tag; comma-separated (repeatable)", func(value string) error {
+		add = append(add, value)
+		return nil
+	})
+	fs.Func("untag", "Remove a tag; comma-separated (repeatable)", func(value string) error {
+		remove = append(remove, value)
+		return nil
+	})
+	note := fs.String("note", pkg.EmptyString, "Replace the note")
+	clearNote := fs.Bool("clear-note", false, "Remove the note")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength {
+		return fmt.Errorf("usage: website-archiver annotate [--tag t] [--untag t] [--note text | --clear-note] <capture-dir>")
+	}
+	remove = manifest.CleanTags(remove)
+	change := len(add) > pkg.ZeroLength || len(remove) > pkg.ZeroLength || *note != pkg.EmptyString || *clearNote
+
+	found := false
+	err := filepath.WalkDir(fs.Arg(pkg.FirstIndex), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == store.DirName {
+			return filepath.SkipDir
+		}
+		dir, ok := manifest.CaptureDir(path)
+		if d.IsDir() || !ok {
+			return nil
+		}
+		found = true
+		m, err := manifest.Load(dir)
+		if err != nil {
+			return err
+		}
+		if change {
+			tags := slices.DeleteFunc(manifest.CleanTags(append(m.Tags, add...)), func(tag string) bool { return slices.Contains(remove, tag) })
+			text := m.Note
+			if *clearNote {
+				text = pkg.EmptyString
+			} else if *note != pkg.EmptyString {
+				text = *note
+			}
+			m.SetAnnotations(tags, text)
+			if err := m.Write(dir, cfg.FilePerms); err != nil {
+				return err
+			}
+		}
+		slog.Info("Capture annotations", "dir", dir, "tags", strings.Join(m.Tags, ","), "note", m.Note)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to annotate captures: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("no manifest found in %s", fs.Arg(pkg.FirstIndex))
+	}
+	return nil
+}
+
+// runRezim rebuilds a ZIM file from a capture kept with --keep-raw, without
+// downloading anything again, optionally with new metadata or index settings
+func runRezim(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("rezim", flag.ContinueOnError)
+	output := fs.String("output", pkg.EmptyString, "ZIM file to write (default: <domain>_<date>.zim next to the capture)")
+	seed := fs.String("url", pkg.EmptyString, "Archived URL (default: read from the capture manifest)")
+	var overrides zimMetadata
+	fs.StringVar(&overrides.Title, "title", pkg.EmptyString, "ZIM title")
+	fs.StringVar(&overrides.Name, "name", pkg.EmptyString, "ZIM name")
+	fs.StringVar(&overrides.Description, "description", pkg.EmptyString, "ZIM description")
+	fs.StringVar(&overrides.LongDescription, "long-description", pkg.EmptyString, "ZIM long description")
+	fs.StringVar(&overrides.Language, "language", pkg.EmptyString, "ZIM language code (e.g. eng)")
+	fs.StringVar(&overrides.Creator, "creator", pkg.EmptyString, "ZIM creator")
+	fs.StringVar(&overrides.Publisher, "publisher", pkg.EmptyString, "ZIM publisher")
+	fs.StringVar(&overrides.Tags, "tags", pkg.EmptyString, "Semicolon-separated ZIM tags (default: the tags of the capture)")
+	fs.StringVar(&overrides.Welcome, "welcome", pkg.EmptyString, "Welcome page relative to the capture")
+	fs.StringVar(&overrides.Illustration, "illustration", pkg.EmptyString, "48x48 PNG illustration relative to the capture")
+	fs.BoolVar(&overrides.FullTextIndex, "full-text-index", false, "Build the full-text search index (with --zim-engine external)")
+	fs.StringVar(&cfg.ZIMEngine, "zim-engine", cfg.ZIMEngine, "ZIM writer: native, or external to run zimwriterfs")
+	fs.Func("max-size", "Split the ZIM file into parts of at most this size (e.g. 4G)", func(value string) error {
+		size, err := config.ParseSize(value)
+		cfg.ZIMMaxSize = size
+		return err
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength {
+		return fmt.Errorf("usage: website-archiver rezim [--title ...] [--zim-engine native|external] [--full-text-index] [--output file.zim] <raw-archive-dir|manifest.json>")
+	}
+
+	captureDir := fs.Arg(pkg.FirstIndex)
+	if dir, ok := manifest.CaptureDir(captureDir); ok {
+		captureDir = dir
+	}
+	info, err := describeCapture(captureDir)
+	if err != nil {
+		return err
+	}
+	if *seed != pkg.EmptyString {
+		info.URL = *seed
+	}
+	archivedURL := info.URL
+	if err := config.CheckZIMEngine(cfg.ZIMEngine); err != nil {
+		return err
+	}
+	if cfg.ZIMEngine == config.ZIMEngineExternal {
+		if _, err := exec.LookPath("zimwriterfs"); err != nil {
+			return fmt.Errorf("zimwriterfs not found in PATH: %w", err)
+		}
+	}
+
+	meta := defaultZIMMetadata(info).with(overrides)
+	if meta.Illustration == pkg.EmptyString {
+		if meta.Illustration, err = zimIllustration(captureDir, archivedURL); err != nil {
+			return err
+		}
+	}
+	zimFile := *output
+	if zimFile == pkg.EmptyString {
+		zimFile = filepath.Join(filepath.Dir(filepath.Clean(captureDir)), fmt.Sprintf("%s_%s.zim", getDomain(archivedURL), time.Now().Format("20060102")))
+	}
+
+	slog.Info("Rebuilding ZIM file", "file", zimFile, "capture", captureDir, "url", archivedURL)
+	if err := writeZIM(ctx, captureDir, zimFile, meta, cfg.ZIMEngine); err != nil {
+		return err
+	}
+	return finalizeZIMFile(zimFile, captureDir, nil, cfg)
+}
+
+// captureInfo describes a capture, as read from its manifests
+type captureInfo struct {
+	// URL is the archived URL and Snapshots the number of manifests
+	URL       string
+	Snapshots int
+	// Tags of all the manifests and the first note among them
+	Tags []string
+	Note string
+}
+
+// describeCapture returns the URL a capture archived, the number of
+// snapshots it holds, and its annotations, read from its manifests
+func describeCapture(captureDir string) (captureInfo, error) {
+	var info captureInfo
+	err := filepath.WalkDir(captureDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == store.DirName {
+			return filepath.SkipDir
+		}
+		dir, ok := manifest.CaptureDir(path)
+		if d.IsDir() || !ok {
+			return nil
+		}
+		m, err := manifest.Load(dir)
+		if err != nil {
+			return err
+		}
+		info.Snapshots++
+		if info.URL == pkg.EmptyString {
+			info.URL = m.URL
+			if original, _, ok := catalog.OriginalURL(m.URL); ok {
+				info.URL = original
+			}
+		}
+		info.Tags = manifest.CleanTags(append(info.Tags, m.Tags...))
+		if info.Note == pkg.EmptyString {
+			info.Note = m.Note
+		}
+		return nil
+	})
+	if err != nil {
+		return captureInfo{}, fmt.Errorf("failed to read capture %s: %w", captureDir, err)
+	}
+	if info.Snapshots == pkg.ZeroCount {
+		return captureInfo{}, fmt.Errorf("no manifest found in %s", captureDir)
+	}
+	return info, nil
+}
+
+// runSecret stores credentials in the OS keyring and checks that secret
+// references resolve, without printing the secrets themselves
+func runSecret(_ context.Context, _ *config.Config, args []string) error {
+	const usage = "usage: website-archiver secret set <keyring:service/account> < secret | secret check <reference>"
+	if len(args) != pkg.SecondIndex+pkg.OneLength {
+		return errors.New(usage)
+	}
+	ref := args[pkg.SecondIndex]
+	switch args[pkg.FirstIndex] {
+	case "set":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read secret from standard input: %w", err)
+		}
+		secret := strings.TrimRight(string(data), "\r\n")
+		if secret == pkg.EmptyString {
+			return errors.New("empty secret on standard input")
+		}
+		if err := secrets.Store(ref, secret); err != nil {
+			return err
+		}
+		slog.Info("Secret stored", "reference", ref)
+		return nil
+	case "check":
+		secret, err := secrets.Resolve(ref)
+		if err != nil {
+			return err
+		}
+		slog.Info("Secret resolved", "reference", ref, "length", len(secret))
+		return nil
+	default:
+		return errors.New(usage)
+	}
+}
+
+// runProfile lists, shows, and deletes the profiles saved with --save-profile
+func runProfile(_ context.Context, _ *config.Config, args []string) error {
+	const usage = "usage: website-archiver profile list | profile show <name> | profile delete <name>"
+	if len(args) == pkg.OneLength && args[pkg.FirstIndex] == "list" {
+		names, err := profile.List()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	if len(args) != pkg.SecondIndex+pkg.OneLength {
+		return errors.New(usage)
+	}
+	name := args[pkg.SecondIndex]
+	switch args[pkg.FirstIndex] {
+	case "show":
+		p, err := profile.Load(name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.Join(p.Flags, " "))
+		return nil
+	case "delete":
+		if err := profile.Delete(name); err != nil {
+			return err
+		}
+		slog.Info("Profile deleted", "profile", name)
+		return nil
+	default:
+		return errors.New(usage)
+	}
+}
+
+// runServeZIM serves a ZIM file for a quick look, through kiwix-serve when it
+// is installed and through the built-in ZIM reader otherwise
+func runServeZIM(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve-zim", flag.ContinueOnError)
+	addr := fs.String("addr", pkg.DefaultServeAddr, "Address to listen on")
+	internal := fs.Bool("internal", false, "Use the built-in ZIM reader even if kiwix-serve is installed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength {
+		return fmt.Errorf("usage: website-archiver serve-zim [--addr host:port] [--internal] <file.zim>")
+	}
+	zimFile := fs.Arg(pkg.FirstIndex)
+
+	if kiwix, err := exec.LookPath(pkg.KiwixServeCmd); err == nil && !*internal {
+		host, port, err := net.SplitHostPort(*addr)
+		if err != nil {
+			return fmt.Errorf("invalid address %s: %w", *addr, err)
+		}
+		cmd := exec.CommandContext(ctx, kiwix, "--address", host, "--port", port, zimFile) // #nosec G204 - args are the user's own file and address
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		slog.Info("Serving ZIM file with kiwix-serve", "file", zimFile, "addr", *addr)
+		return cmd.Run()
+	}
+
+	reader, err := zim.Open(zimFile)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	server := &http.Server{Addr: *addr, Handler: zim.Handler(reader), ReadHeaderTimeout: cfg.HTTPTimeout}
+	slog.Info("Serving ZIM file", "file", zimFile, "url", "http://"+*addr+"/")
+	return serveHTTP(ctx, server, nil)
+}
+
+// runReplay serves an archive directory, applying the link rewrites recorded
+// for pages captured in fidelity mode as they are requested
+func runReplay(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	addr := fs.String("addr", pkg.DefaultServeAddr, "Address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength {
+		return fmt.Errorf("usage: website-archiver replay [--addr host:port] <archive-dir>")
+	}
+	archiveDir := fs.Arg(pkg.FirstIndex)
+
+	rewrites, err := replay.LoadRewrites(storage.NewLocal(archiveDir, cfg.DirPerms))
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Addr: *addr, Handler: replay.Handler(archiveDir, rewrites), ReadHeaderTimeout: cfg.HTTPTimeout}
+	slog.Info("Replaying archive", "archive", archiveDir, "pages", len(rewrites.Pages), "url", "http://"+*addr+"/")
+	return serveHTTP(ctx, server, nil)
+}
+
+// runProxyRecord runs a local proxy that records everything browsed through
+// it into a WARC file, intercepting HTTPS with a locally generated CA
+func runProxyRecord(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("proxy-record", flag.ContinueOnError)
+	addr := fs.String("addr", pkg.DefaultProxyAddr, "Address to listen on")
+	output := fs.String("output", filepath.Join(cfg.OutputDir, "proxy_"+time.Now().Format("20060102_150405")+".warc.gz"), "WARC file to record into")
+	caDir := fs.String("ca-dir", filepath.Join(cfg.OutputDir, pkg.ProxyCADir), "Directory holding the interception CA")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.ZeroLength {
+		return fmt.Errorf("usage: website-archiver proxy-record [--addr host:port] [--output file.warc.gz] [--ca-dir dir]")
+	}
+
+	ca, err := proxy.LoadOrCreateCA(*caDir, cfg.DirPerms)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*output), cfg.DirPerms); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	w, err := warc.Create(*output, cfg.FilePerms)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := w.WriteInfo("website-archiver proxy-record"); err != nil {
+		return err
+	}
+
+	transport := cfg.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	recorder := &proxy.Recorder{Transport: transport, WARC: w, CA: ca, Timeout: cfg.HTTPTimeout}
+	server := &http.Server{Addr: *addr, Handler: recorder, ReadHeaderTimeout: cfg.HTTPTimeout}
+
+	slog.Info("Recording proxy listening", "addr", *addr, "warc", *output, "ca", filepath.Join(*caDir, proxy.CertFile))
+	slog.Info("Set your browser's HTTP and HTTPS proxy to the address above and trust the CA certificate to record HTTPS")
+	// Exchanges in flight are recorded before the WARC file is closed
+	return serveHTTP(ctx, server, nil)
+}
+
+// runProxyArchive runs a local proxy that serves pages live and archives the
+// ones browsed through it in the background, serving their archived copies
+// when a site fails
+func runProxyArchive(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("proxy-archive", flag.ContinueOnError)
+	addr := fs.String("addr", pkg.DefaultProxyAddr, "Address to listen on")
+	caDir := fs.String("ca-dir", filepath.Join(cfg.OutputDir, pkg.ProxyCADir), "Directory holding the interception CA")
+	depth := fs.Int("depth", pkg.ZeroLength, "Crawl depth archiving each page; 0 archives the page with its assets")
+	maxAge := fs.Duration("max-age", pkg.Day, "How long a capture keeps a page fresh; pages browsed after that are archived again")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.ZeroLength || *depth < pkg.ZeroLength {
+		return fmt.Errorf("usage: website-archiver proxy-archive [--addr host:port] [--ca-dir dir] [--depth n] [--max-age duration]")
+	}
+
+	ca, err := proxy.LoadOrCreateCA(*caDir, cfg.DirPerms)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.OutputDir, cfg.DirPerms); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	cat, err := catalog.Build(cfg.OutputDir)
+	if err != nil {
+		return err
+	}
+
+	// An interrupt stops the proxy gracefully, so the capture in progress
+	// is saved and the catalog written
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	queue := make(chan string, pkg.ProxyQueueSize)
+	archived := make(chan struct{})
+	go func() {
+		defer close(archived)
+		archiveBrowsed(ctx, queue, *depth, cat, cfg)
+	}()
+	transport := cfg.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	archiver := &proxy.Archiver{Transport: transport, CA: ca, Timeout: cfg.HTTPTimeout, Catalog: cat, MaxAge: *maxAge, Queue: queue}
+	server := &http.Server{Addr: *addr, Handler: archiver, ReadHeaderTimeout: cfg.HTTPTimeout}
+
+	slog.Info("Archiving proxy listening", "addr", *addr, "output", cfg.OutputDir, "ca", filepath.Join(*caDir, proxy.CertFile), "maxAge", maxAge.String())
+	slog.Info("Set your browser's HTTP and HTTPS proxy to the address above and trust the CA certificate to archive HTTPS")
+	err = serveHTTP(ctx, server, nil)
+	stop()
+	<-archived
+	return err
+}
+
+// archiveBrowsed archives the pages of queue one at a time and adds their
+// captures to cat, until ctx is done, when the catalog is written with the
+// interrupted capture and the pages left in queue are logged
+func archiveBrowsed(ctx context.Context, queue <-chan string, depth int, cat *catalog.Catalog, cfg *config.Config) {
+	var started time.Time
+	for {
+		var page string
+		select {
+		case <-ctx.Done():
+			updateCatalog(cat, cfg)
+			for len(queue) > pkg.ZeroLength {
+				slog.Warn("Browsed page left unarchived", pkg.LogURL, <-queue)
+			}
+			return
+		case page = <-queue:
+		}
+		// Captures of a domain are named after the second they start in
+		if wait := time.Until(started.Truncate(time.Second).Add(time.Second)); wait > 0 {
+			time.Sleep(wait)
+		}
+		started = time.Now()
+		results := make(chan DownloadResult, pkg.OneLength)
+		processURL(ctx, page, depth, false, false, pkg.EmptyString, false, false, results, cfg)
+		res := <-results
+		if res.Error != nil {
+			slog.Warn("Failed to archive browsed page", pkg.LogError, res.Error, pkg.LogURL, page, fetcherr.Attr(res.Error))
+			continue
+		}
+		slog.Info("Archived browsed page", pkg.LogURL, page, "dir", res.OutputDir)
+		updateCatalog(cat, cfg)
+	}
+}
+
+// updateCatalog rebuilds cat from the captures in the output directory and
+// writes it
+func updateCatalog(cat *catalog.Catalog, cfg *config.Config) {
+	rebuilt, err := catalog.Build(cfg.OutputDir)
+	if err != nil {
+		slog.Warn("Failed to rebuild catalog", pkg.LogError, err)
+		return
+	}
+	cat.Replace(rebuilt)
+	if err := cat.Write(cfg.FilePerms); err != nil {
+		slog.Warn("Failed to write catalog", pkg.LogError, err)
+	}
+}
+
+// runServe runs the archiver in server mode: jobs are started over the HTTP
+// API or the web UI, their progress is streamed as Server-Sent Events, and the
+// catalog of captures can be searched and browsed
+func runServe(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", pkg.DefaultServeAddr, "Address to listen on")
+	fs.StringVar(&cfg.ContentAddr, "content-addr", cfg.ContentAddr, "Address serving the archived captures and Memento endpoints, apart from the API")
+	fs.StringVar(&cfg.APIToken, "api-token", cfg.APIToken, "Token required to start jobs and change annotations (default: a random one, logged at start)")
+	fs.StringVar(&cfg.PublicAddr, "public-addr", cfg.PublicAddr, "Also serve a public read-only gallery of the published collections on this address, e.g. :8081")
+	fs.Func("public-collection", "Publish the captures with this tag in the public gallery; comma-separated (repeatable)", func(value string) error {
+		cfg.PublicCollections = append(cfg.PublicCollections, value)
+		return nil
+	})
+	authFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.ZeroLength {
+		return fmt.Errorf("usage: website-archiver serve [--addr host:port] [--content-addr host:port] [--api-token token] [--public-addr host:port --public-collection tag]")
+	}
+	collections := manifest.CleanTags(cfg.PublicCollections)
+	if cfg.PublicAddr != pkg.EmptyString && len(collections) == pkg.ZeroLength {
+		return errors.New("--public-addr publishes nothing without --public-collection")
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, cfg.DirPerms); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := setupAuth(cfg); err != nil {
+		return err
+	}
+	cat, err := catalog.Build(cfg.OutputDir)
+	if err != nil {
+		return err
+	}
+	if err := cat.Write(cfg.FilePerms); err != nil {
+		return err
+	}
+	slog.Info("Catalog loaded", "entries", len(cat.Entries))
+
+	uiURL := "http://" + *addr + "/"
+	if cfg.APIToken == pkg.EmptyString {
+		// The link of the UI carries a generated token; one given is not logged
+		cfg.APIToken = rand.Text()
+		uiURL += "#token=" + cfg.APIToken
+	}
+
+	// An interrupt stops the jobs gracefully, so their state is saved, and
+	// all listeners with them
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv := server.New(ctx, cfg, cat, runJob)
+	// Listen before serving, so a taken address fails the command
+	contentListener, err := net.Listen("tcp", cfg.ContentAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for the archived content: %w", err)
+	}
+	content := &http.Server{Handler: srv.ContentHandler(cfg.ContentAddr), ReadHeaderTimeout: cfg.HTTPTimeout}
+	go func() {
+		if err := serveHTTP(ctx, content, contentListener); err != nil {
+			slog.Error("Archived content stopped", "error", err)
+		}
+	}()
+	slog.Info("Archived content listening", "url", "http://"+cfg.ContentAddr+"/web/")
+	if cfg.PublicAddr != pkg.EmptyString {
+		// Listen before serving, so a taken address fails the command
+		listener, err := net.Listen("tcp", cfg.PublicAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for the public gallery: %w", err)
+		}
+		public := &http.Server{Handler: srv.PublicHandler(collections), ReadHeaderTimeout: cfg.HTTPTimeout}
+		go func() {
+			if err := serveHTTP(ctx, public, listener); err != nil {
+				slog.Error("Public gallery stopped", "error", err)
+			}
+		}()
+		slog.Info("Public gallery listening", "url", "http://"+cfg.PublicAddr+"/", "collections", strings.Join(collections, ","))
+	}
+	httpServer := &http.Server{Addr: *addr, Handler: srv.Handler(), ReadHeaderTimeout: cfg.HTTPTimeout}
+	slog.Info("Server mode listening", "url", uiURL)
+	return serveHTTP(ctx, httpServer, nil)
+}
+
+// runCatalog moves the catalog between machines: export writes a portable
+// bundle of the index with integrity hashes, and import verifies a bundle and
+// merges its entries into the local catalog, keeping every capture of both
+func runCatalog(_ context.Context, cfg *config.Config, args []string) error {
+	const usage = "usage: website-archiver catalog export [--output bundle.tar.gz] | catalog import <bundle.tar.gz>"
+	if len(args) == pkg.ZeroLength {
+		return errors.New(usage)
+	}
+	switch args[pkg.FirstIndex] {
+	case "export":
+		fs := flag.NewFlagSet("catalog export", flag.ContinueOnError)
+		output := fs.String("output", pkg.EmptyString, "Bundle to write (default: <output-dir>/catalog_<timestamp>.tar.gz)")
+		source := fs.String("source", pkg.EmptyString, "Name recorded for this archiver in the bundle (default: hostname)")
+		if err := fs.Parse(args[pkg.OneIndex:]); err != nil {
+			return err
+		}
+		if fs.NArg() != pkg.ZeroLength {
+			return errors.New(usage)
+		}
+		return exportCatalog(cfg, *output, *source)
+	case "import":
+		fs := flag.NewFlagSet("catalog import", flag.ContinueOnError)
+		if err := fs.Parse(args[pkg.OneIndex:]); err != nil {
+			return err
+		}
+		if fs.NArg() != pkg.OneLength {
+			return errors.New(usage)
+		}
+		return importCatalog(cfg, fs.Arg(pkg.FirstIndex))
+	default:
+		return errors.New(usage)
+	}
+}
+
+// runStats reports per-domain statistics of the captures in the output directory
+func runStats(_ context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	domain := fs.String("domain", pkg.EmptyString, "Only report this domain")
+	format := fs.String("format", "table", "Output format: table, csv, or json")
+	trend := fs.Bool("trend", false, "List every capture instead of one row per domain")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cat, err := catalog.Build(cfg.OutputDir)
+	if err != nil {
+		return err
+	}
+	stats := cat.Stats(*domain)
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent(pkg.EmptyString, "  ")
+		if err := enc.Encode(stats); err != nil {
+			return fmt.Errorf("failed to write statistics: %w", err)
+		}
+		return nil
+	case "csv":
+		return catalog.WriteStatsCSV(os.Stdout, stats, *trend)
+	case "table":
+		tw := tabwriter.NewWriter(os.Stdout, pkg.ZeroValue, pkg.TabWidth, pkg.TabPadding, ' ', pkg.ZeroValue)
+		if *trend {
+			fmt.Fprintln(tw, "DOMAIN\tTIMESTAMP\tPAGES\tBYTES\tERRORS\tCAPTURE")
+			for _, s := range stats {
+				for _, run := range s.Trend {
+					fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d/%d\t%s\n", run.Domain, run.Timestamp, run.Pages, run.Bytes, run.Errors, run.Resources, run.Capture)
+				}
+			}
+		} else {
+			fmt.Fprintln(tw, "DOMAIN\tCAPTURES\tFIRST\tLAST\tPAGES\tBYTES\tERROR RATE")
+			for _, s := range stats {
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d (%+d)\t%d (%+d)\t%.1f%%\n", s.Domain, s.Captures, s.First, s.Last, s.Pages, s.PagesGrowth, s.Bytes, s.BytesGrowth, s.ErrorRate*100)
+			}
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format %q (want table, csv, or json)", *format)
+	}
+}
+
+// exportCatalog rebuilds the catalog of the output directory and writes it as a bundle
+func exportCatalog(cfg *config.Config, output, source string) error {
+	cat, err := catalog.Build(cfg.OutputDir)
+	if err != nil {
+		return err
+	}
+	if source == pkg.EmptyString {
+		if source, err = os.Hostname(); err != nil {
+			return fmt.Errorf("failed to get hostname: %w", err)
+		}
+	}
+	if output == pkg.EmptyString {
+		output = filepath.Join(cfg.OutputDir, "catalog_"+time.Now().Format(catalog.TimestampFormat)+".tar.gz")
+	}
+
+	f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, cfg.FilePerms) // #nosec G304 - output is chosen by the user
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	if err := cat.Export(f, source); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close bundle: %w", err)
+	}
+	slog.Info("Catalog exported", "bundle", output, "entries", len(cat.Entries), "source", source)
+	return nil
+}
+
+// importCatalog merges a bundle into the output directory and rewrites its catalog
+func importCatalog(cfg *config.Config, bundle string) error {
+	if err := os.MkdirAll(cfg.OutputDir, cfg.DirPerms); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	f, err := os.Open(bundle) // #nosec G304 - bundle is chosen by the user
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	info, added, err := catalog.Import(cfg.OutputDir, f, cfg.FilePerms)
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+
+	cat, err := catalog.Build(cfg.OutputDir)
+	if err != nil {
+		return err
+	}
+	if err := cat.Write(cfg.FilePerms); err != nil {
+		return err
+	}
+	slog.Info("Catalog imported", "bundle", bundle, "source", info.Source, "bundled", info.Entries, "added", added, "entries", len(cat.Entries))
+	return nil
+}
+
+// runTorrent creates torrents of finished archives and seeds them: create
+// hashes ZIM files or capture directories into .torrent files next to them,
+// and seed uploads the archives of torrent files to peers until interrupted
+func runTorrent(ctx context.Context, cfg *config.Config, args []string) error {
+	const usage = "usage: website-archiver torrent create [--tracker url] [--webseed url] <zim-file|capture-dir>... | torrent seed [--addr :6881] [file.torrent...]"
+	if len(args) == pkg.ZeroLength {
+		return errors.New(usage)
+	}
+	switch args[pkg.FirstIndex] {
+	case "create":
+		fs := flag.NewFlagSet("torrent create", flag.ContinueOnError)
+		fs.Func("tracker", "Announce URL of a tracker; comma-separated (repeatable)", func(value string) error {
+			cfg.TorrentTrackers = append(cfg.TorrentTrackers, strings.Split(value, ",")...)
+			return nil
+		})
+		fs.Func("webseed", "URL the archive is published at over HTTP; comma-separated (repeatable)", func(value string) error {
+			cfg.TorrentWebSeeds = append(cfg.TorrentWebSeeds, strings.Split(value, ",")...)
+			return nil
+		})
+		if err := fs.Parse(args[pkg.OneIndex:]); err != nil {
+			return err
+		}
+		if fs.NArg() == pkg.ZeroLength {
+			return errors.New(usage)
+		}
+		for _, path := range fs.Args() {
+			if err := createTorrent(filepath.Clean(path), cfg); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "seed":
+		fs := flag.NewFlagSet("torrent seed", flag.ContinueOnError)
+		addr := fs.String("addr", pkg.DefaultSeedAddr, "Address peers connect to")
+		if err := fs.Parse(args[pkg.OneIndex:]); err != nil {
+			return err
+		}
+		return seedTorrents(ctx, cfg, *addr, fs.Args())
+	default:
+		return errors.New(usage)
+	}
+}
+
+// seedTorrents seeds the given torrent files, or every one in the output
+// directory, from the directory each is in
+func seedTorrents(ctx context.Context, cfg *config.Config, addr string, files []string) error {
+	if len(files) == pkg.ZeroLength {
+		matches, err := filepath.Glob(filepath.Join(cfg.OutputDir, "*"+torrent.Ext))
+		if err != nil {
+			return err
+		}
+		files = matches
+	}
+	if len(files) == pkg.ZeroLength {
+		return fmt.Errorf("no torrents to seed in %s; create them with --torrent or torrent create", cfg.OutputDir)
+	}
+	seeder := torrent.NewSeeder(cfg.HTTPClient())
+	seeding := 0
+	for _, file := range files {
+		meta, err := torrent.Load(file)
+		if err == nil {
+			err = seeder.Add(meta, filepath.Dir(file))
+		}
+		if err != nil {
+			slog.Warn("Not seeding torrent", pkg.LogError, err, "file", file)
+			continue
+		}
+		slog.Info("Seeding torrent", "file", file, "name", meta.Name, "bytes", meta.TotalLength(), "magnet", meta.Magnet())
+		seeding++
+	}
+	if seeding == pkg.ZeroLength {
+		return errors.New("none of the torrents can be seeded")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for peers: %w", err)
+	}
+	// Trackers are told when seeding stops
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	slog.Info("Seeder listening", "addr", listener.Addr().String(), "torrents", seeding)
+	return seeder.Serve(ctx, listener)
+}
+
+// runWatch keeps sites archived by recrawling their pages on adaptive
+// schedules: each page is fetched again after an interval that starts from
+// its caching headers and shrinks or grows as recrawls find it changed or not
+func runWatch(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	depth := fs.Int("depth", pkg.OneDepth, "Crawl depth of seed recrawls, which discover new pages")
+	bounds := schedule.Bounds{}
+	fs.DurationVar(&bounds.Min, "min-interval", schedule.DefaultMinInterval, "Shortest recrawl interval of a page")
+	fs.DurationVar(&bounds.Max, "max-interval", schedule.DefaultMaxInterval, "Longest recrawl interval of a page")
+	authFlags(fs, cfg)
+	debugFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := startDiagnostics(cfg); err != nil {
+		return err
+	}
+	if fs.NArg() == pkg.ZeroLength || bounds.Min <= 0 || bounds.Max < bounds.Min {
+		return fmt.Errorf("usage: website-archiver watch [--depth 1] [--min-interval 15m] [--max-interval 168h] <url...>")
+	}
+	for _, seed := range fs.Args() {
+		if err := validateURL(seed, cfg.LegacyProtocols); err != nil {
+			return fmt.Errorf("invalid URL %s: %w", seed, err)
+		}
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, cfg.DirPerms); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := setupAuth(cfg); err != nil {
+		return err
+	}
+	state, err := schedule.Load(cfg.OutputDir)
+	if err != nil {
+		return err
+	}
+	for _, seed := range fs.Args() {
+		// Seeds are due right away and recrawled with the watch depth
+		state.Entry(seed, seed).Depth = *depth
+	}
+
+	for {
+		due, next := state.Due(time.Now())
+		for seed, entries := range due {
+			recrawl(ctx, cfg, state, seed, entries, bounds)
+			if err := state.Save(cfg.FilePerms); err != nil {
+				return err
+			}
+		}
+		if len(due) > pkg.ZeroLength {
+			continue
+		}
+		if next.IsZero() {
+			return nil
+		}
+		slog.Info("Waiting for next recrawl", "at", next.Format(time.RFC3339))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// recrawl captures the due pages of a site and updates their schedules.
+// Every page found in the capture counts as a check, and the pages a seed
+// recrawl discovers start being watched
+func recrawl(ctx context.Context, cfg *config.Config, state *schedule.State, seed string, entries []*schedule.Entry, bounds schedule.Bounds) {
+	first := entries[pkg.FirstIndex]
+	for _, e := range entries {
+		if e.URL == seed {
+			first = e
+		}
+	}
+	var extra []string
+	for _, e := range entries {
+		if e != first {
+			extra = append(extra, e.URL)
+		}
+	}
+
+	outputDir := filepath.Join(cfg.OutputDir, getDomain(seed)+"_"+time.Now().Format("20060102_150405"))
+	slog.Info("Recrawling watched pages", pkg.LogURL, seed, "pages", len(entries), "output", outputDir)
+	// The recrawl runs to the end; HTTPTimeout bounds each of its requests
+	err := downloader.DownloadURLs(ctx, first.URL, extra, first.Depth, outputDir, false, false, cfg)
+	if err != nil {
+		slog.Warn("Recrawl failed", pkg.LogURL, seed, pkg.LogError, err)
+	}
+
+	captured := make(map[string]manifest.Resource)
+	if m, err := manifest.Load(outputDir); err == nil {
+		for _, r := range m.Resources {
+			if r.Status == http.StatusOK && strings.Contains(r.ContentType, "html") {
+				captured[r.URL] = r
+			}
+		}
+	}
+
+	now := time.Now()
+	changed := pkg.ZeroCount
+	for u, r := range captured {
+		e := state.Entry(u, seed)
+		before := e.Digest
+		e.Observe(r.Digest, schedule.Headers{CacheControl: r.CacheControl, Expires: r.Expires, LastModified: r.LastModified}, now, bounds)
+		if before != pkg.EmptyString && before != r.Digest {
+			changed++
+		}
+	}
+	for _, e := range entries {
+		if _, ok := captured[e.URL]; !ok {
+			e.Fail(now, bounds)
+		}
+	}
+	slog.Info("Recrawl Summary", pkg.LogURL, seed, "due", len(entries), "captured", len(captured), "changed", changed)
+}
+
+// runJob archives a URL for server mode the same way the command line does
+func runJob(ctx context.Context, req server.JobRequest, cfg *config.Config) (*result.Result, error) {
+	if err := validateURL(req.URL, cfg.LegacyProtocols); err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", req.URL, err)
+	}
+	if len(req.Tags) > pkg.ZeroLength || req.Note != pkg.EmptyString {
+		cfg.Tags, cfg.Note = req.Tags, req.Note
+	}
+	results := make(chan DownloadResult, pkg.OneLength)
+	processURL(ctx, req.URL, req.Depth, req.ZIM, req.AllSnapshots, req.Snapshot, false, false, results, cfg)
+	res := <-results
+	return res.Report, res.Error
+}
+
+// runCheck compares an archive directory with the live site and reports
+// resources that are gone, redirect, or changed since they were captured
+func runCheck(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	againstLive := fs.Bool("against-live", false, "Re-fetch archived URLs and compare them with the live site")
+	threshold := fs.Float64("change-threshold", pkg.DefaultChangeThreshold, "Relative size difference above which a resource counts as changed")
+	format := fs.String("format", "table", "Output format (table|json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength || !*againstLive {
+		return fmt.Errorf("usage: website-archiver check --against-live [--change-threshold 0.2] [--format table|json] <archive-dir>")
+	}
+
+	archiveDir := fs.Arg(pkg.FirstIndex)
+	m, err := manifest.Load(archiveDir)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Checking archive against live site", "archive", archiveDir, "resources", len(m.Resources))
+	results := linkcheck.AgainstLive(ctx, cfg.HTTPClient(), m, linkcheck.Options{ChangeThreshold: *threshold})
+
+	counts := make(map[linkcheck.Status]int)
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	if err := writeCheckResults(os.Stdout, *format, results); err != nil {
+		return err
+	}
+	slog.Info("Check Summary",
+		"resources", len(results),
+		"unchanged", counts[linkcheck.StatusUnchanged],
+		"equivalent", counts[linkcheck.StatusEquivalent],
+		"minorChange", counts[linkcheck.StatusMinorChange],
+		"changed", counts[linkcheck.StatusChanged],
+		"redirect", counts[linkcheck.StatusRedirect],
+		"gone", counts[linkcheck.StatusGone],
+		"httpError", counts[linkcheck.StatusHTTPError],
+		"unreachable", counts[linkcheck.StatusUnreachable],
+	)
+	return nil
+}
+
+// writeCheckResults prints link rot results as a table or JSON
+func writeCheckResults(w io.Writer, format string, results []linkcheck.Result) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent(pkg.EmptyString, "  ")
+		return enc.Encode(results)
+	case "table":
+		tw := tabwriter.NewWriter(w, pkg.ZeroValue, pkg.TabWidth, pkg.TabPadding, ' ', pkg.ZeroValue)
+		fmt.Fprintln(tw, "STATUS\tHTTP\tURL\tDETAIL")
+		for _, r := range results {
+			detail := r.Detail
+			if r.Location != pkg.EmptyString {
+				detail = "-> " + r.Location
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", r.Status, r.HTTPStatus, r.URL, detail)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format %q (use table or json)", format)
+	}
+}
@@ -0,0 +1,398 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/browserprofile"
+	"github.com/Sudo-Ivan/website-archiver/internal/challenge"
+	"github.com/Sudo-Ivan/website-archiver/internal/errorpages"
+	"github.com/Sudo-Ivan/website-archiver/internal/filenames"
+	"github.com/Sudo-Ivan/website-archiver/internal/mimefilter"
+	"github.com/Sudo-Ivan/website-archiver/internal/onion"
+	"github.com/Sudo-Ivan/website-archiver/internal/paywall"
+	"github.com/Sudo-Ivan/website-archiver/internal/preset"
+	"github.com/Sudo-Ivan/website-archiver/internal/referrer"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+	"github.com/Sudo-Ivan/website-archiver/internal/urlfilter"
+	"github.com/Sudo-Ivan/website-archiver/internal/urlnorm"
+)
+
+// Setting is one configuration value together with the environment
+// variable and flag that set it.
+type Setting struct {
+	Env   string
+	Flag  string
+	Value string
+}
+
+// masked hides a secret value in listings of the configuration.
+func masked(value string) string {
+	if value == EmptyString {
+		return EmptyString
+	}
+	return "********"
+}
+
+// Settings lists the effective configuration values.
+func (c *Config) Settings() []Setting {
+	size := func(n int64) string { return strconv.FormatInt(n, 10) }
+	list := func(items []string) string { return strings.Join(items, ",") }
+	ints := func(items []int) string {
+		parts := make([]string, len(items))
+		for i, n := range items {
+			parts[i] = strconv.Itoa(n)
+		}
+		return list(parts)
+	}
+	return []Setting{
+		{Env: "HTTP_TIMEOUT", Value: c.HTTPTimeout.String()},
+		{Env: "MAX_IDLE_CONNS_PER_HOST", Flag: "max-idle-conns-per-host", Value: strconv.Itoa(c.MaxIdleConnsPerHost)},
+		{Env: "TLS_HANDSHAKE_TIMEOUT", Flag: "tls-handshake-timeout", Value: c.TLSHandshakeTimeout.String()},
+		{Env: "DISABLE_HTTP2", Flag: "disable-http2", Value: strconv.FormatBool(c.DisableHTTP2)},
+		{Env: "RETRY_ATTEMPTS", Flag: "retry-attempts", Value: strconv.Itoa(c.RetryAttempts)},
+		{Env: "RETRY_BACKOFF", Flag: "retry-backoff", Value: c.RetryBackoff.String()},
+		{Env: "RETRY_STATUSES", Flag: "retry-statuses", Value: ints(c.RetryStatuses)},
+		{Env: "MAX_DEPTH", Value: strconv.Itoa(c.MaxDepth)},
+		{Env: "DIR_PERMS", Value: fmt.Sprintf("%o", c.DirPerms)},
+		{Env: "FILE_PERMS", Value: fmt.Sprintf("%o", c.FilePerms)},
+		{Env: "LOG_LEVEL", Value: c.LogLevel.String()},
+		{Env: "OUTPUT_DIR", Value: c.OutputDir},
+		{Env: "RESULT_FILE", Flag: "result-file", Value: c.ResultFile},
+		{Env: "STORAGE", Flag: "storage", Value: c.StorageURL},
+		{Env: "S3_ENDPOINT", Flag: "s3-endpoint", Value: c.S3Endpoint},
+		{Env: "AWS_REGION", Flag: "s3-region", Value: c.S3Region},
+		{Env: "WAYBACK_API_URL", Value: c.WaybackAPIURL},
+		{Env: "PARSE_WORKERS", Flag: "parse-workers", Value: strconv.Itoa(c.ParseWorkers)},
+		{Env: "PARSE_QUEUE", Flag: "parse-queue", Value: strconv.Itoa(c.ParseQueue)},
+		{Env: "VISITED_BLOOM", Flag: "visited-bloom", Value: strconv.FormatBool(c.VisitedBloom)},
+		{Env: "VISITED_EXPECTED", Flag: "visited-expected", Value: strconv.Itoa(c.VisitedExpected)},
+		{Env: "VISITED_FP_RATE", Flag: "visited-fp-rate", Value: strconv.FormatFloat(c.VisitedFPRate, 'g', -1, 64)},
+		{Env: "CDX_CACHE_DIR", Flag: "cdx-cache-dir", Value: c.CDXCacheDir},
+		{Env: "CDX_CACHE_TTL", Flag: "cdx-cache-ttl", Value: c.CDXCacheTTL.String()},
+		{Env: "CDX_CONCURRENCY", Flag: "cdx-concurrency", Value: strconv.Itoa(c.CDXConcurrency)},
+		{Env: "CONCURRENCY", Flag: "concurrency", Value: strconv.Itoa(c.Concurrency)},
+		{Env: "FRONTIER_DIR", Flag: "frontier-dir", Value: c.FrontierDir},
+		{Env: "FRONTIER_WORKERS", Flag: "frontier-workers", Value: strconv.Itoa(c.FrontierWorkers)},
+		{Env: "WORDPRESS", Flag: "wordpress", Value: strconv.FormatBool(c.WordPress)},
+		{Env: "PRESET", Flag: "preset", Value: c.Preset},
+		{Env: "DOC_VERSIONS", Flag: "doc-versions", Value: c.DocVersions},
+		{Env: "A11Y_REPORT", Flag: "a11y-report", Value: strconv.FormatBool(c.A11yReport)},
+		{Env: "FIDELITY", Flag: "fidelity", Value: strconv.FormatBool(c.Fidelity)},
+		{Env: "KEEP_CONTENT_ENCODING", Flag: "keep-content-encoding", Value: strconv.FormatBool(c.KeepContentEncoding)},
+		{Env: "ARCHIVED_AT_META", Flag: "archived-at-meta", Value: strconv.FormatBool(c.ArchivedAtMeta)},
+		{Env: "BANNER", Flag: "banner", Value: strconv.FormatBool(c.Banner)},
+		{Env: "TIME_TRAVEL", Flag: "time-travel", Value: strconv.FormatBool(c.TimeTravel)},
+		{Env: "NOINDEX", Flag: "noindex", Value: strconv.FormatBool(c.NoIndex)},
+		{Env: "CANONICAL", Flag: "canonical", Value: strconv.FormatBool(c.Canonical)},
+		{Env: "SRCSET", Flag: "srcset", Value: c.Srcset},
+		{Env: "SITEMAP_SEEDS", Flag: "sitemap-seeds", Value: strconv.FormatBool(c.SitemapSeeds)},
+		{Env: "RESPECT_ROBOTS", Flag: "respect-robots", Value: strconv.FormatBool(c.RespectRobots)},
+		{Env: "SITEMAP", Flag: "sitemap", Value: strconv.FormatBool(c.Sitemap)},
+		{Env: "SEARCH_PAGE", Flag: "search-page", Value: strconv.FormatBool(c.SearchPage)},
+		{Env: "SCREENSHOTS", Flag: "screenshots", Value: strconv.FormatBool(c.Screenshots)},
+		{Env: "SCREENSHOT_LIMIT", Flag: "screenshot-limit", Value: strconv.Itoa(c.ScreenshotLimit)},
+		{Env: "OFFLINE_REPLAY_TEST", Flag: "offline-replay-test", Value: strconv.FormatBool(c.OfflineReplayTest)},
+		{Env: "LEGACY_PROTOCOLS", Flag: "legacy-protocols", Value: strconv.FormatBool(c.LegacyProtocols)},
+		{Env: "IPFS_GATEWAY", Flag: "ipfs-gateway", Value: c.IPFSGateway},
+		{Env: "AUTH_HEADERS", Flag: "auth", Value: list(c.AuthHeaders)},
+		{Env: "AUTH_COOKIES", Flag: "auth-cookie", Value: list(c.AuthCookies)},
+		{Env: "COOKIES_FILE", Flag: "cookies-file", Value: c.CookiesFile},
+		{Env: "REQUEST_HEADERS", Flag: "header", Value: strings.Join(c.Headers, " | ")},
+		{Env: "HTTP_USER", Flag: "user", Value: c.User},
+		{Env: "HTTP_PASSWORD", Flag: "password", Value: masked(c.Password)},
+		{Env: "REFERRER_POLICY", Flag: "referrer-policy", Value: c.ReferrerPolicy},
+		{Env: "REFERER", Flag: "referer", Value: c.Referer},
+		{Env: "CHALLENGE", Flag: "challenge", Value: c.Challenge},
+		{Env: "BROWSER_PROFILE", Flag: "browser-profile", Value: c.BrowserProfile},
+		{Env: "USER_AGENT", Flag: "user-agent", Value: c.UserAgent},
+		{Env: "USER_AGENT_FILE", Flag: "user-agent-file", Value: c.UserAgentFile},
+		{Env: "DEBUG_ADDR", Flag: "debug-addr", Value: c.DebugAddr},
+		{Env: "PUBLIC_ADDR", Flag: "public-addr", Value: c.PublicAddr},
+		{Env: "PUBLIC_COLLECTIONS", Flag: "public-collection", Value: list(c.PublicCollections)},
+		{Env: "CONTENT_ADDR", Flag: "content-addr", Value: c.ContentAddr},
+		{Env: "API_TOKEN", Flag: "api-token", Value: masked(c.APIToken)},
+		{Env: "TOR", Flag: "tor", Value: strconv.FormatBool(c.Tor)},
+		{Env: "TOR_PROXY", Flag: "tor-proxy", Value: c.TorProxy},
+		{Flag: "remap", Value: list(c.RemapRules)},
+		{Env: "REMAP_FILE", Flag: "remap-file", Value: c.RemapFile},
+		{Env: "DEPTH_BOOSTS", Flag: "depth-boost", Value: list(c.DepthBoosts)},
+		{Env: "DEPTH_BOOST_FILE", Flag: "depth-boost-file", Value: c.DepthBoostFile},
+		{Env: "RESTRICT_FILE_NAMES", Flag: "restrict-file-names", Value: c.RestrictFileNames},
+		{Env: "WAYBACK_PATCH", Flag: "wayback-patch", Value: strconv.FormatBool(c.WaybackPatch)},
+		{Env: "PAYWALL_FALLBACK", Flag: "paywall-fallback", Value: c.PaywallFallback},
+		{Env: "WAYBACK_MAX_BYTES", Flag: "wayback-max-bytes", Value: size(c.WaybackMaxBytes)},
+		{Env: "WAYBACK_MAX_REQUESTS", Flag: "wayback-max-requests", Value: strconv.Itoa(c.WaybackMaxRequests)},
+		{Flag: "wayback-continue", Value: c.WaybackContinue},
+		{Flag: "resume", Value: c.Resume},
+		{Flag: "update", Value: c.Update},
+		{Env: "COST_PER_GB", Flag: "cost-per-gb", Value: strconv.FormatFloat(c.CostPerGB, 'g', -1, 64)},
+		{Env: "RETENTION_KEEP_LAST", Value: strconv.Itoa(c.RetentionKeepLast)},
+		{Env: "RETENTION_KEEP_DAYS", Value: strconv.Itoa(c.RetentionKeepDays)},
+		{Env: "RETENTION_MAX_GB", Value: strconv.FormatFloat(c.RetentionMaxGB, 'g', -1, 64)},
+		{Env: "ZIM_MAX_SIZE", Flag: "zim-max-size", Value: size(c.ZIMMaxSize)},
+		{Env: "KEEP_RAW", Flag: "keep-raw", Value: strconv.FormatBool(c.KeepRaw)},
+		{Env: "ZIM_ENGINE", Flag: "zim-engine", Value: c.ZIMEngine},
+		{Env: "WARC", Flag: "warc", Value: strconv.FormatBool(c.WARC)},
+		{Env: "SINGLE_FILE", Flag: "single-file", Value: strconv.FormatBool(c.SingleFile)},
+		{Env: "MHTML", Flag: "mhtml", Value: strconv.FormatBool(c.MHTML)},
+		{Env: "PDF", Flag: "pdf", Value: strconv.FormatBool(c.PDF)},
+		{Env: "EPUB", Flag: "epub", Value: strconv.FormatBool(c.EPUB)},
+		{Env: "EPUB_READABLE", Flag: "epub-readable", Value: strconv.FormatBool(c.EPUBReadable)},
+		{Env: "TORRENT", Flag: "torrent", Value: strconv.FormatBool(c.Torrent)},
+		{Env: "TORRENT_TRACKERS", Flag: "tracker", Value: list(c.TorrentTrackers)},
+		{Env: "TORRENT_WEBSEEDS", Flag: "webseed", Value: list(c.TorrentWebSeeds)},
+		{Env: "CAPTURE_TAGS", Flag: "tag", Value: list(c.Tags)},
+		{Env: "CAPTURE_NOTE", Flag: "note", Value: c.Note},
+		{Env: "SPAN_HOSTS_ASSETS", Flag: "span-hosts-assets", Value: strconv.FormatBool(c.SpanHostsAssets)},
+		{Env: "ASSET_HOSTS", Flag: "asset-host", Value: list(c.AssetHosts)},
+		{Env: "HOST_ALIASES", Flag: "host-alias", Value: list(c.HostAliases)},
+		{Env: "INCLUDE_PATTERNS", Flag: "include-pattern", Value: list(c.IncludePatterns)},
+		{Env: "EXCLUDE_PATTERNS", Flag: "exclude-pattern", Value: list(c.ExcludePatterns)},
+		{Env: "DROP_PARAMS", Flag: "drop-param", Value: list(c.DropParams)},
+		{Env: "STRIP_TRACKING", Flag: "strip-tracking", Value: strconv.FormatBool(c.StripTracking)},
+		{Env: "STRIP_TRAILING_SLASH", Flag: "strip-trailing-slash", Value: strconv.FormatBool(c.StripTrailingSlash)},
+		{Env: "ACCEPT_MIME", Flag: "accept-mime", Value: list(c.AcceptMIME)},
+		{Env: "REJECT_MIME", Flag: "reject-mime", Value: list(c.RejectMIME)},
+		{Env: "MAX_FILE_SIZE", Flag: "max-file-size", Value: size(c.MaxFileSize)},
+		{Env: "LISTING_MAX_FILE_SIZE", Flag: "listing-max-file-size", Value: size(c.ListingMaxFileSize)},
+		{Env: "LISTING_MAX_TOTAL_SIZE", Flag: "listing-max-total-size", Value: size(c.ListingMaxTotalSize)},
+		{Env: "ERROR_PAGES", Flag: "error-pages", Value: c.ErrorPages},
+		{Env: "ERROR_STATUSES", Flag: "error-status", Value: list(c.ErrorStatuses)},
+		{Env: "CHUNK_THRESHOLD", Flag: "chunk-threshold", Value: size(c.ChunkThreshold)},
+		{Env: "HARDLINK", Flag: "hardlink", Value: strconv.FormatBool(c.Hardlink)},
+	}
+}
+
+// Validate reports every invalid or contradictory setting, each with the
+// change that fixes it.
+func (c *Config) Validate() error {
+	var errs []error
+	problem := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.HTTPTimeout <= 0 {
+		problem("HTTP_TIMEOUT must be positive, e.g. HTTP_TIMEOUT=30s")
+	}
+	if c.RetryAttempts < 1 {
+		problem("--retry-attempts must be at least 1; use 1 to disable retries")
+	}
+	if c.RetryBackoff < 0 {
+		problem("--retry-backoff must not be negative")
+	}
+	if c.MaxIdleConnsPerHost < 0 {
+		problem("--max-idle-conns-per-host must not be negative")
+	}
+	if c.TLSHandshakeTimeout < 0 {
+		problem("--tls-handshake-timeout must not be negative; use 0 for no timeout")
+	}
+	for _, status := range c.RetryStatuses {
+		if status < 100 || status > 599 {
+			problem("--retry-statuses: %d is not an HTTP status", status)
+		}
+	}
+	if c.MaxDepth < 0 {
+		problem("MAX_DEPTH must not be negative")
+	}
+	if c.ParseWorkers < 0 {
+		problem("--parse-workers must not be negative; use 0 for one worker per CPU")
+	}
+	if c.ParseQueue < 0 {
+		problem("--parse-queue must not be negative")
+	}
+	if c.VisitedBloom && c.VisitedExpected <= 0 {
+		problem("--visited-expected must be positive when --visited-bloom is set")
+	}
+	if c.VisitedBloom && (c.VisitedFPRate <= 0 || c.VisitedFPRate >= 1) {
+		problem("--visited-fp-rate must be between 0 and 1 (exclusive), e.g. 0.01")
+	}
+	if c.CDXConcurrency < 1 {
+		problem("--cdx-concurrency must be at least 1")
+	}
+	if c.CDXCacheTTL < 0 {
+		problem("--cdx-cache-ttl must not be negative; use 0 to disable the CDX cache")
+	}
+	if c.WaybackMaxBytes < 0 || c.WaybackMaxRequests < 0 {
+		problem("--wayback-max-bytes and --wayback-max-requests must not be negative; use 0 for no limit")
+	}
+	if c.Concurrency < 1 {
+		problem("--concurrency must be at least 1")
+	}
+	if c.FrontierWorkers < 0 {
+		problem("--frontier-workers must not be negative; use 0 to follow --concurrency")
+	}
+	if strings.TrimSpace(c.UserAgent) == EmptyString || strings.ContainsAny(c.UserAgent, "\r\n\x00") {
+		problem("--user-agent must be a single non-empty line")
+	}
+	if c.Password != EmptyString && c.User == EmptyString {
+		problem("--password needs --user")
+	}
+	if c.Tor {
+		if _, _, err := net.SplitHostPort(c.TorProxy); err != nil {
+			problem("--tor-proxy must be host:port, e.g. %s", onion.DefaultProxy)
+		}
+		if c.LegacyProtocols {
+			problem("--legacy-protocols cannot be used with --tor; FTP and Gopher connections would bypass Tor")
+		}
+		if c.WaybackPatch || c.WaybackContinue != EmptyString {
+			problem("--wayback-patch and --wayback-continue cannot be used with --tor, which disables the Wayback Machine")
+		}
+		if c.PaywallFallback != paywall.None {
+			problem("--paywall-fallback cannot be used with --tor, which disables the Wayback Machine")
+		}
+	}
+	if c.PublicAddr != EmptyString {
+		if _, _, err := net.SplitHostPort(c.PublicAddr); err != nil {
+			problem("--public-addr must be host:port, e.g. :8081")
+		}
+		if len(c.PublicCollections) == 0 {
+			problem("--public-addr publishes nothing without --public-collection; tag the captures to publish and name the tags")
+		}
+	}
+	if c.Resume != EmptyString && c.WaybackContinue != EmptyString {
+		problem("--resume and --wayback-continue cannot be combined; resume the interrupted crawl first")
+	}
+	if c.Update != EmptyString && (c.Resume != EmptyString || c.WaybackContinue != EmptyString) {
+		problem("--update starts a new crawl of a capture and cannot be combined with --resume or --wayback-continue")
+	}
+	if err := storage.Check(c.StorageURL); err != nil {
+		problem("--storage: %w", err)
+	}
+	if c.StorageURL != EmptyString {
+		for _, local := range []struct {
+			flag string
+			set  bool
+		}{
+			{"--visited-bloom", c.VisitedBloom},
+			{"--screenshots", c.Screenshots},
+			{"--offline-replay-test", c.OfflineReplayTest},
+			{"--chunk-threshold", c.ChunkThreshold > 0},
+			{"--hardlink", c.Hardlink},
+			{"--wayback-continue", c.WaybackContinue != EmptyString},
+			{"--resume", c.Resume != EmptyString},
+			{"--update", c.Update != EmptyString},
+			{"--torrent", c.Torrent},
+		} {
+			if local.set {
+				problem("%s needs the capture on local disk and cannot be used with --storage", local.flag)
+			}
+		}
+	}
+	if err := preset.Validate(c.Preset, c.DocVersions); err != nil {
+		problem("invalid preset settings: %w", err)
+	}
+	if c.Preset == EmptyString && c.DocVersions != DefaultDocVersions {
+		problem("--doc-versions %s has no effect without --preset %s", c.DocVersions, preset.Docs)
+	}
+	if _, err := filenames.Parse(c.RestrictFileNames); err != nil {
+		problem("--restrict-file-names: %w", err)
+	}
+	if _, err := urlfilter.New(c.IncludePatterns, c.ExcludePatterns); err != nil {
+		problem("%w", err)
+	}
+	if _, err := urlnorm.New(c.DropParams, c.StripTracking, c.StripTrailingSlash); err != nil {
+		problem("--drop-param: %w", err)
+	}
+	if _, err := mimefilter.New(c.AcceptMIME, c.RejectMIME); err != nil {
+		problem("%w", err)
+	}
+	if _, err := referrer.New(c.ReferrerPolicy, c.Referer); err != nil {
+		problem("%v", err)
+	}
+	if c.Referer != EmptyString && strings.ToLower(strings.TrimSpace(c.ReferrerPolicy)) != referrer.Custom {
+		problem("--referer has no effect without --referrer-policy custom")
+	}
+	if err := challenge.CheckMode(c.Challenge); err != nil {
+		problem("%v", err)
+	}
+	if err := paywall.CheckMode(c.PaywallFallback); err != nil {
+		problem("%v", err)
+	}
+	if c.BrowserProfile != EmptyString {
+		if _, err := browserprofile.Lookup(c.BrowserProfile); err != nil {
+			problem("%v", err)
+		}
+	}
+	if _, err := errorpages.New(c.ErrorPages, c.ErrorStatuses); err != nil {
+		problem("--error-pages: %w", err)
+	}
+	if len(c.ErrorStatuses) > 0 && (c.ErrorPages == EmptyString || c.ErrorPages == errorpages.Fail) {
+		problem("--error-status has no effect without --error-pages save, report, or skip")
+	}
+	if c.ListingMaxFileSize > 0 && c.ListingMaxTotalSize > 0 && c.ListingMaxFileSize > c.ListingMaxTotalSize {
+		problem("--listing-max-file-size exceeds --listing-max-total-size; no listing file that large could ever be fetched")
+	}
+	if c.ArchivedAtMeta && c.Fidelity {
+		problem("--archived-at-meta has no effect with --fidelity, which keeps pages byte-for-byte")
+	}
+	if c.Banner && c.Fidelity {
+		problem("--banner has no effect with --fidelity, which keeps pages byte-for-byte")
+	}
+	for _, tracker := range c.TorrentTrackers {
+		if u, err := url.Parse(tracker); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "udp") || u.Host == "" {
+			problem("--tracker %s is not an http, https, or udp announce URL", tracker)
+		}
+	}
+	for _, seed := range c.TorrentWebSeeds {
+		if u, err := url.Parse(seed); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problem("--webseed %s is not an http or https URL", seed)
+		}
+	}
+	if len(c.AssetHosts) > 0 && !c.SpanHostsAssets {
+		problem("--asset-host has no effect without --span-hosts-assets")
+	}
+	for _, alias := range c.HostAliases {
+		if err := CheckHostAlias(alias); err != nil {
+			problem("%v", err)
+		}
+	}
+	if err := CheckZIMEngine(c.ZIMEngine); err != nil {
+		problem("%v", err)
+	}
+	if (c.NoIndex || c.Canonical) && c.Fidelity {
+		problem("--noindex and --canonical have no effect with --fidelity, which keeps pages byte-for-byte")
+	}
+	if c.Srcset != SrcsetAll && c.Srcset != SrcsetLargest {
+		problem("unknown --srcset %q (want %s or %s)", c.Srcset, SrcsetAll, SrcsetLargest)
+	}
+	if c.ScreenshotLimit < 0 {
+		problem("--screenshot-limit must not be negative; use 0 for every page")
+	}
+	if c.CostPerGB < 0 {
+		problem("--cost-per-gb must not be negative")
+	}
+	if c.RetentionKeepLast < 0 || c.RetentionKeepDays < 0 || c.RetentionMaxGB < 0 {
+		problem("retention limits must not be negative; use 0 to disable a limit")
+	}
+	for _, endpoint := range []struct{ name, raw string }{{"WAYBACK_API_URL", c.WaybackAPIURL}, {"--ipfs-gateway", c.IPFSGateway}} {
+		name, raw := endpoint.name, endpoint.raw
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == EmptyString {
+			problem("%s must be an absolute http(s) URL, got %q", name, raw)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckZIMEngine reports an error unless engine names a ZIM writer.
+func CheckZIMEngine(engine string) error {
+	if engine != ZIMEngineNative && engine != ZIMEngineExternal {
+		return fmt.Errorf("unknown --zim-engine %q (want %s or %s)", engine, ZIMEngineNative, ZIMEngineExternal)
+	}
+	return nil
+}
+
+// CheckHostAlias reports an error unless alias is a bare hostname.
+func CheckHostAlias(alias string) error {
+	if alias = strings.TrimSpace(alias); alias == EmptyString || strings.ContainsAny(alias, "/:@ ") {
+		return fmt.Errorf("--host-alias %q is not a hostname such as www.example.com", alias)
+	}
+	return nil
+}
@@ -6,10 +6,24 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/challenge"
+	"github.com/Sudo-Ivan/website-archiver/internal/errorpages"
+	"github.com/Sudo-Ivan/website-archiver/internal/onion"
+	"github.com/Sudo-Ivan/website-archiver/internal/paywall"
+	"github.com/Sudo-Ivan/website-archiver/internal/progress"
+	"github.com/Sudo-Ivan/website-archiver/internal/referrer"
+	"github.com/Sudo-Ivan/website-archiver/internal/retry"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+	"github.com/Sudo-Ivan/website-archiver/internal/useragent"
 )
 
 const (
@@ -19,12 +33,47 @@ const (
 	DefaultDirPerms = 0750
 	// DefaultHTTPTimeout is the default timeout for HTTP requests
 	DefaultHTTPTimeout = 30 * time.Second
+	// DefaultMaxIdleConnsPerHost is the default number of idle connections
+	// kept alive for each host
+	DefaultMaxIdleConnsPerHost = 16
+	// DefaultTLSHandshakeTimeout is the default timeout for TLS handshakes
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	// DefaultRetryAttempts is the default number of attempts per request
+	DefaultRetryAttempts = 3
+	// DefaultRetryBackoff is the default backoff before the first retry
+	DefaultRetryBackoff = 500 * time.Millisecond
 	// DefaultWaybackAPIURL is the default URL for the Wayback Machine CDX API
 	DefaultWaybackAPIURL = "https://web.archive.org/cdx/search/cdx"
 	// DefaultOutputDir is the default directory for downloaded files
 	DefaultOutputDir = "downloads"
+	// SrcsetAll and SrcsetLargest select which candidates of responsive
+	// images are downloaded
+	SrcsetAll     = "all"
+	SrcsetLargest = "largest"
+	// ZIMEngineNative writes ZIM files in Go and ZIMEngineExternal with the
+	// zimwriterfs binary, which can also build a full-text index
+	ZIMEngineNative   = "native"
+	ZIMEngineExternal = "external"
 	// DefaultFilePerms is the default file permissions in octal
 	DefaultFilePerms = 0600
+	// DefaultDocVersions is the default documentation version selection for the docs preset
+	DefaultDocVersions = "latest"
+	// DefaultParseQueue is the default number of fetched pages waiting for the parse stage
+	DefaultParseQueue = 64
+	// DefaultVisitedExpected is the default number of URLs the disk visited set is sized for
+	DefaultVisitedExpected = 1000000
+	// DefaultVisitedFPRate is the default false-positive rate of the visited set Bloom filter
+	DefaultVisitedFPRate = 0.01
+	// DefaultCDXCacheTTL is how long cached CDX API responses are reused
+	DefaultCDXCacheTTL = 24 * time.Hour
+	// DefaultCDXConcurrency is the default number of CDX API queries in flight
+	DefaultCDXConcurrency = 4
+	// DefaultConcurrency is the default number of fetch workers of a crawl
+	DefaultConcurrency = 8
+	// DefaultIPFSGateway is the default gateway for ipfs:// and ipns:// seeds
+	DefaultIPFSGateway = "https://ipfs.io"
+	// DefaultContentAddr is the default listen address of the archived content in server mode
+	DefaultContentAddr = "127.0.0.1:8082"
 	// EmptyString represents an empty string constant
 	EmptyString = ""
 )
@@ -35,6 +84,22 @@ type Config struct {
 	HTTPTimeout time.Duration
 	MaxDepth    int
 	DirPerms    os.FileMode
+	// Transport is used by every HTTP client; nil means http.DefaultTransport.
+	// A run sets it to one transport from NewTransport, so all its clients
+	// share their connections
+	Transport http.RoundTripper
+	// Tuning of the transport of NewTransport
+	MaxIdleConnsPerHost int
+	TLSHandshakeTimeout time.Duration
+	DisableHTTP2        bool
+	// Progress receives per-URL crawl events; nil disables them
+	Progress progress.Func
+	// Retries of requests failing with a network error or one of
+	// RetryStatuses: RetryAttempts counts the first attempt, and the backoff
+	// starts at RetryBackoff
+	RetryAttempts int
+	RetryBackoff  time.Duration
+	RetryStatuses []int
 
 	// File permissions
 	FilePerms os.FileMode
@@ -42,23 +107,409 @@ type Config struct {
 	// Wayback Machine settings
 	WaybackAPIURL string
 
+	// Parse stage settings; ParseWorkers 0 means one worker per CPU
+	ParseWorkers int
+	ParseQueue   int
+
+	// Visited set settings; VisitedBloom keeps it on disk behind a Bloom filter
+	VisitedBloom    bool
+	VisitedExpected int
+	VisitedFPRate   float64
+
+	// CDX API response cache; an empty CDXCacheDir uses the user cache
+	// directory and a CDXCacheTTL of 0 disables caching. CDXConcurrency
+	// bounds the queries in flight across the URLs of a run.
+	CDXCacheDir    string
+	CDXCacheTTL    time.Duration
+	CDXConcurrency int
+
+	// Concurrency is the number of fetch workers of a crawl
+	Concurrency int
+
+	// Persistent crawl frontier settings; an empty FrontierDir keeps the
+	// queue in memory. FrontierWorkers 0 uses Concurrency workers.
+	FrontierDir     string
+	FrontierWorkers int
+
 	// Output settings
 	OutputDir string
+	// ResultFile receives the results of a run as JSON, with the outcome of
+	// every URL and the files created; "-" writes them to standard output
+	ResultFile string
+	// StorageURL writes captures to remote storage instead of OutputDir,
+	// e.g. s3://bucket/prefix; S3Endpoint and S3Region locate the service.
+	// OpenStorage, when set, opens the storage of each capture instead, for
+	// programs embedding the downloader.
+	StorageURL  string
+	S3Endpoint  string
+	S3Region    string
+	OpenStorage func(outputDir string) (storage.Storage, error)
 
 	// Logging settings
 	LogLevel slog.Level
+
+	// Capture settings
+	WordPress   bool
+	Preset      string
+	DocVersions string
+	A11yReport  bool
+	Fidelity    bool
+	// KeepContentEncoding saves resources other than pages, feeds, and
+	// stylesheets with the content coding they were served with
+	KeepContentEncoding bool
+	// ArchivedAtMeta adds <meta name="archived-at"> with the fetch time to saved pages
+	ArchivedAtMeta bool
+	// Banner adds a dismissible provenance banner to saved pages
+	Banner bool
+	// TimeTravel adds a bar linking to the same page in the other snapshots
+	// to the pages of multi-snapshot archives
+	TimeTravel bool
+	// NoIndex and Canonical add a robots noindex meta and a canonical link to
+	// the original URL to saved pages, for mirrors that are hosted publicly
+	NoIndex   bool
+	Canonical bool
+	// Srcset selects the srcset candidates downloaded: SrcsetAll or SrcsetLargest
+	Srcset string
+	// Sitemap writes a sitemap and a site map page built from the crawl graph
+	Sitemap bool
+	// SearchPage writes an offline full-text search page into each capture
+	SearchPage bool
+	// Screenshots takes a screenshot of each page for a thumbnail gallery,
+	// at most ScreenshotLimit pages (0 = all)
+	Screenshots     bool
+	ScreenshotLimit int
+	// OfflineReplayTest loads every page of a finished capture in a headless
+	// Chromium without network access and reports what fails to load
+	OfflineReplayTest bool
+
+	// SitemapSeeds queues the pages listed in the site's sitemap.xml before
+	// crawling recursively
+	SitemapSeeds bool
+
+	// RespectRobots honors the robots.txt Disallow rules and Crawl-delay of
+	// every host in direct downloads
+	RespectRobots bool
+
+	// LegacyProtocols enables ftp:// and gopher:// URLs
+	LegacyProtocols bool
+
+	// RestrictFileNames escapes characters of local file names, as a
+	// comma-separated list of unix, windows, ascii, lowercase, uppercase, nocontrol
+	RestrictFileNames string
+
+	// IPFSGateway is the HTTP gateway ipfs:// and ipns:// seeds are fetched through
+	IPFSGateway string
+
+	// Per-host credentials as "host=<secret reference>", resolved through
+	// the secrets package so they can live in the OS keyring
+	AuthHeaders []string
+	AuthCookies []string
+
+	// CookiesFile is a Netscape cookies.txt file loaded into CookieJar, which
+	// every HTTP client shares; nil leaves each crawl with a jar of its own
+	CookiesFile string
+	CookieJar   http.CookieJar
+
+	// Headers ("Name: value") and Basic credentials sent with every request,
+	// for staging sites and CDNs that gate all of their hosts alike
+	Headers  []string
+	User     string
+	Password string
+
+	// ReferrerPolicy selects the Referer, Origin, and Sec-Fetch-* headers of
+	// crawl requests: none, browser, or custom, which sends Referer
+	ReferrerPolicy string
+	Referer        string
+	// Challenge is what is done about anti-bot challenges: skip, cookies,
+	// or browser, the last two pausing the crawl for the user to pass them
+	Challenge string
+	// BrowserProfile sends the User-Agent, Accept, and client hint headers
+	// of a browser, by name; empty sends the crawler's own
+	BrowserProfile string
+
+	// UserAgent is sent with every request unless UserAgentFile lists agents
+	// to use in turn; main loads those into UserAgents
+	UserAgent     string
+	UserAgentFile string
+	UserAgents    *useragent.Rotation
+
+	// DebugAddr serves pprof profiles and expvar variables while the
+	// program runs
+	DebugAddr string
+
+	// PublicAddr serves a read-only gallery of the captures tagged with one
+	// of PublicCollections in server mode
+	PublicAddr        string
+	PublicCollections []string
+
+	// ContentAddr serves the archived captures in server mode, apart from
+	// the API so their scripts cannot reach it
+	ContentAddr string
+	// APIToken authenticates the requests that start jobs or change
+	// annotations in server mode
+	APIToken string
+
+	// Tor routes every request through the Tor SOCKS5 proxy at TorProxy
+	Tor      bool
+	TorProxy string
+
+	// URL remapping rules ("pattern=>replacement") applied during link
+	// conversion, given on the command line and in RemapFile
+	RemapRules []string
+	RemapFile  string
+
+	// Depth boosts ("pattern=+N") for parts of the site, given on the
+	// command line, in DEPTH_BOOSTS one per line, and in DepthBoostFile
+	DepthBoosts    []string
+	DepthBoostFile string
+
+	// Fetching requisites from other hosts, all of them or those listed with
+	// their subdomains, while pages stay on the site
+	SpanHostsAssets bool
+	AssetHosts      []string
+
+	// HostAliases are other hostnames of the seed's site, such as its www
+	// or non-www twin or an old domain, crawled and saved as the seed's host
+	HostAliases []string
+
+	// Regular expressions limiting the discovered URLs that are crawled
+	IncludePatterns []string
+	ExcludePatterns []string
+
+	// URL normalization before deduplication: query parameters to drop,
+	// given as globs, the tracking parameters, and trailing slashes
+	DropParams         []string
+	StripTracking      bool
+	StripTrailingSlash bool
+
+	// Wayback Machine patching of missing assets in direct downloads
+	WaybackPatch bool
+	// PaywallFallback is the archive the newest capture of paywalled pages
+	// is saved from instead: none, wayback, archive.today, or newest of both
+	PaywallFallback string
+
+	// Soft caps on the requests sent to and bytes downloaded from the
+	// Wayback Machine in a run, 0 means unlimited. WaybackContinue is a
+	// capture directory whose crawl stopped at the caps, to continue.
+	WaybackMaxBytes    int64
+	WaybackMaxRequests int
+	WaybackContinue    string
+
+	// Resume is a capture directory whose interrupted crawl is continued
+	// from its crawl state
+	Resume string
+	// Update is a capture directory that is archived again in place, keeping
+	// the files the site reports unchanged
+	Update string
+
+	// Bandwidth accounting settings
+	CostPerGB float64
+
+	// Retention settings, applied by the prune subcommand and after each run
+	RetentionKeepLast int
+	RetentionKeepDays int
+	RetentionMaxGB    float64
+
+	// ZIM settings; KeepRaw keeps the downloaded files once the ZIM is built
+	// and ZIMEngine selects the writer, native or external (zimwriterfs)
+	ZIMMaxSize int64
+	KeepRaw    bool
+	ZIMEngine  string
+
+	// WARC records every exchange of the crawl into a WARC file next to the
+	// capture directory
+	WARC bool
+	// SingleFile writes a self-contained copy of every page, with its
+	// assets inlined, next to the capture directory
+	SingleFile bool
+	// MHTML writes an MHTML archive of every page next to the capture
+	// directory
+	MHTML bool
+	// PDF prints every page to PDF with a headless Chromium and merges them
+	// into one bookmarked PDF next to the capture directory
+	PDF bool
+	// EPUB writes the capture as an EPUB book next to the capture directory,
+	// keeping only the main text of its pages with EPUBReadable
+	EPUB         bool
+	EPUBReadable bool
+
+	// Torrent creation for finished archives, with the announce URLs of
+	// their trackers and the HTTP locations they are published at
+	Torrent         bool
+	TorrentTrackers []string
+	TorrentWebSeeds []string
+
+	// Tags and a note attached to the captures of a run
+	Tags []string
+	Note string
+
+	// Media type globs (e.g. image/*) of the responses that are saved
+	// and of the ones that are skipped after their headers are read
+	AcceptMIME []string
+	RejectMIME []string
+
+	// MaxFileSize caps the size of every fetched resource, 0 means unlimited
+	MaxFileSize int64
+
+	// ErrorPages is what is done with error responses of ErrorStatuses, or
+	// of every 4xx and 5xx status when none are given: fail, save, report,
+	// or skip
+	ErrorPages    string
+	ErrorStatuses []string
+
+	// Directory listing size caps, 0 means unlimited
+	ListingMaxFileSize  int64
+	ListingMaxTotalSize int64
+
+	// ChunkThreshold moves files of at least this size into content-defined
+	// chunks in the store after each run, 0 disables chunking
+	ChunkThreshold int64
+
+	// Hardlink links identical files of the captures to shared store
+	// objects after each run
+	Hardlink bool
 }
 
 // New creates a new Config instance with values from environment variables or defaults
 func New() *Config {
 	config := &Config{
 		HTTPTimeout:   getEnvDuration("HTTP_TIMEOUT", DefaultHTTPTimeout),
+		RetryAttempts: getEnvInt("RETRY_ATTEMPTS", DefaultRetryAttempts),
+		RetryBackoff:  getEnvDuration("RETRY_BACKOFF", DefaultRetryBackoff),
+		RetryStatuses: getEnvIntList("RETRY_STATUSES", retry.DefaultStatuses),
 		MaxDepth:      getEnvInt("MAX_DEPTH", DefaultMaxDepth),
 		DirPerms:      getEnvFileMode("DIR_PERMS", DefaultDirPerms),
 		FilePerms:     getEnvFileMode("FILE_PERMS", DefaultFilePerms),
+		ParseWorkers:  getEnvInt("PARSE_WORKERS", 0),
+		ParseQueue:    getEnvInt("PARSE_QUEUE", DefaultParseQueue),
 		WaybackAPIURL: getEnvString("WAYBACK_API_URL", DefaultWaybackAPIURL),
 		OutputDir:     getEnvString("OUTPUT_DIR", DefaultOutputDir),
+		ResultFile:    getEnvString("RESULT_FILE", EmptyString),
 		LogLevel:      getEnvLogLevel("LOG_LEVEL", slog.LevelInfo),
+		WordPress:     getEnvBool("WORDPRESS", false),
+		Preset:        getEnvString("PRESET", EmptyString),
+		DocVersions:   getEnvString("DOC_VERSIONS", DefaultDocVersions),
+		WaybackPatch:  getEnvBool("WAYBACK_PATCH", false),
+
+		PaywallFallback: getEnvString("PAYWALL_FALLBACK", paywall.None),
+
+		MaxIdleConnsPerHost: getEnvInt("MAX_IDLE_CONNS_PER_HOST", DefaultMaxIdleConnsPerHost),
+		TLSHandshakeTimeout: getEnvDuration("TLS_HANDSHAKE_TIMEOUT", DefaultTLSHandshakeTimeout),
+		DisableHTTP2:        getEnvBool("DISABLE_HTTP2", false),
+
+		WaybackMaxBytes:     getEnvSize("WAYBACK_MAX_BYTES", 0),
+		WaybackMaxRequests:  getEnvInt("WAYBACK_MAX_REQUESTS", 0),
+		A11yReport:          getEnvBool("A11Y_REPORT", false),
+		Fidelity:            getEnvBool("FIDELITY", false),
+		KeepContentEncoding: getEnvBool("KEEP_CONTENT_ENCODING", false),
+		Sitemap:             getEnvBool("SITEMAP", false),
+		ArchivedAtMeta:      getEnvBool("ARCHIVED_AT_META", false),
+		Banner:              getEnvBool("BANNER", false),
+		TimeTravel:          getEnvBool("TIME_TRAVEL", true),
+		NoIndex:             getEnvBool("NOINDEX", false),
+		Canonical:           getEnvBool("CANONICAL", false),
+		Srcset:              getEnvString("SRCSET", SrcsetAll),
+		RespectRobots:       getEnvBool("RESPECT_ROBOTS", false),
+		SitemapSeeds:        getEnvBool("SITEMAP_SEEDS", false),
+		SearchPage:          getEnvBool("SEARCH_PAGE", false),
+
+		Screenshots:       getEnvBool("SCREENSHOTS", false),
+		ScreenshotLimit:   getEnvInt("SCREENSHOT_LIMIT", 0),
+		OfflineReplayTest: getEnvBool("OFFLINE_REPLAY_TEST", false),
+		CostPerGB:         getEnvFloat("COST_PER_GB", 0),
+
+		LegacyProtocols: getEnvBool("LEGACY_PROTOCOLS", false),
+		IPFSGateway:     getEnvString("IPFS_GATEWAY", DefaultIPFSGateway),
+
+		RemapFile: getEnvString("REMAP_FILE", EmptyString),
+
+		DepthBoosts:    getEnvLines("DEPTH_BOOSTS"),
+		DepthBoostFile: getEnvString("DEPTH_BOOST_FILE", EmptyString),
+
+		RestrictFileNames: getEnvString("RESTRICT_FILE_NAMES", EmptyString),
+
+		AuthHeaders: getEnvList("AUTH_HEADERS"),
+		AuthCookies: getEnvList("AUTH_COOKIES"),
+		CookiesFile: getEnvString("COOKIES_FILE", EmptyString),
+		Headers:     getEnvLines("REQUEST_HEADERS"),
+		User:        getEnvString("HTTP_USER", EmptyString),
+		Password:    getEnvString("HTTP_PASSWORD", EmptyString),
+
+		ReferrerPolicy: getEnvString("REFERRER_POLICY", referrer.None),
+		Referer:        getEnvString("REFERER", EmptyString),
+		Challenge:      getEnvString("CHALLENGE", challenge.Skip),
+		BrowserProfile: getEnvString("BROWSER_PROFILE", EmptyString),
+
+		UserAgent:     getEnvString("USER_AGENT", useragent.Default),
+		UserAgentFile: getEnvString("USER_AGENT_FILE", EmptyString),
+
+		DebugAddr: getEnvString("DEBUG_ADDR", EmptyString),
+
+		PublicAddr:        getEnvString("PUBLIC_ADDR", EmptyString),
+		PublicCollections: getEnvList("PUBLIC_COLLECTIONS"),
+		ContentAddr:       getEnvString("CONTENT_ADDR", DefaultContentAddr),
+		APIToken:          getEnvString("API_TOKEN", EmptyString),
+
+		Tor:      getEnvBool("TOR", false),
+		TorProxy: getEnvString("TOR_PROXY", onion.DefaultProxy),
+
+		RetentionKeepLast: getEnvInt("RETENTION_KEEP_LAST", 0),
+		RetentionKeepDays: getEnvInt("RETENTION_KEEP_DAYS", 0),
+		RetentionMaxGB:    getEnvFloat("RETENTION_MAX_GB", 0),
+
+		ZIMMaxSize: getEnvSize("ZIM_MAX_SIZE", 0),
+		KeepRaw:    getEnvBool("KEEP_RAW", false),
+		ZIMEngine:  getEnvString("ZIM_ENGINE", ZIMEngineNative),
+
+		WARC:         getEnvBool("WARC", false),
+		SingleFile:   getEnvBool("SINGLE_FILE", false),
+		MHTML:        getEnvBool("MHTML", false),
+		PDF:          getEnvBool("PDF", false),
+		EPUB:         getEnvBool("EPUB", false),
+		EPUBReadable: getEnvBool("EPUB_READABLE", false),
+
+		Torrent:         getEnvBool("TORRENT", false),
+		TorrentTrackers: getEnvList("TORRENT_TRACKERS"),
+		TorrentWebSeeds: getEnvList("TORRENT_WEBSEEDS"),
+
+		Tags: getEnvList("CAPTURE_TAGS"),
+		Note: getEnvString("CAPTURE_NOTE", EmptyString),
+
+		SpanHostsAssets: getEnvBool("SPAN_HOSTS_ASSETS", false),
+		AssetHosts:      getEnvList("ASSET_HOSTS"),
+		HostAliases:     getEnvList("HOST_ALIASES"),
+
+		// Patterns may contain commas, so they are given one per line
+		IncludePatterns: getEnvLines("INCLUDE_PATTERNS"),
+		ExcludePatterns: getEnvLines("EXCLUDE_PATTERNS"),
+
+		DropParams:         getEnvList("DROP_PARAMS"),
+		StripTracking:      getEnvBool("STRIP_TRACKING", false),
+		StripTrailingSlash: getEnvBool("STRIP_TRAILING_SLASH", false),
+
+		AcceptMIME: getEnvList("ACCEPT_MIME"),
+		RejectMIME: getEnvList("REJECT_MIME"),
+
+		MaxFileSize:         getEnvSize("MAX_FILE_SIZE", 0),
+		ListingMaxFileSize:  getEnvSize("LISTING_MAX_FILE_SIZE", 0),
+		ListingMaxTotalSize: getEnvSize("LISTING_MAX_TOTAL_SIZE", 0),
+
+		ErrorPages:    getEnvString("ERROR_PAGES", errorpages.Fail),
+		ErrorStatuses: getEnvList("ERROR_STATUSES"),
+
+		ChunkThreshold: getEnvSize("CHUNK_THRESHOLD", 0),
+		Hardlink:       getEnvBool("HARDLINK", false),
+
+		VisitedBloom:    getEnvBool("VISITED_BLOOM", false),
+		VisitedExpected: getEnvInt("VISITED_EXPECTED", DefaultVisitedExpected),
+		VisitedFPRate:   getEnvFloat("VISITED_FP_RATE", DefaultVisitedFPRate),
+
+		CDXCacheDir:     getEnvString("CDX_CACHE_DIR", EmptyString),
+		CDXCacheTTL:     getEnvDuration("CDX_CACHE_TTL", DefaultCDXCacheTTL),
+		CDXConcurrency:  getEnvInt("CDX_CONCURRENCY", DefaultCDXConcurrency),
+		FrontierDir:     getEnvString("FRONTIER_DIR", EmptyString),
+		FrontierWorkers: getEnvInt("FRONTIER_WORKERS", 0),
+		Concurrency:     getEnvInt("CONCURRENCY", DefaultConcurrency),
 	}
 
 	// Configure slog
@@ -72,6 +523,72 @@ func New() *Config {
 	return config
 }
 
+// HTTPClient returns an HTTP client using the configured timeout and
+// transport and User-Agent. Failed requests are retried, each attempt with
+// its own timeout and, with a rotation, the next agent.
+func (c *Config) HTTPClient() *http.Client {
+	return c.HTTPClientVia(c.Transport)
+}
+
+// HTTPClientVia returns the client of HTTPClient sending its requests
+// through transport instead of the shared one.
+func (c *Config) HTTPClientVia(transport http.RoundTripper) *http.Client {
+	agents := c.UserAgents
+	if agents == nil {
+		agents = useragent.New(c.UserAgent)
+	}
+	agent := &useragent.Transport{Next: transport, Agents: agents}
+	if c.RetryAttempts <= 1 {
+		return &http.Client{Timeout: c.HTTPTimeout, Transport: agent, Jar: c.CookieJar}
+	}
+	policy := retry.Policy{MaxAttempts: c.RetryAttempts, Base: c.RetryBackoff, Statuses: c.RetryStatuses}
+	return &http.Client{Transport: &retry.Transport{Next: agent, Policy: policy, Timeout: c.HTTPTimeout}, Jar: c.CookieJar}
+}
+
+// NewTransport returns a transport tuned by the config, to be shared by the
+// HTTP clients of a run so connections are kept alive, and multiplexed over
+// HTTP/2 unless it is disabled.
+func (c *Config) NewTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	t.MaxIdleConns = max(t.MaxIdleConns, c.MaxIdleConnsPerHost)
+	t.TLSHandshakeTimeout = c.TLSHandshakeTimeout
+	if c.DisableHTTP2 {
+		// A non-nil empty map keeps the transport from negotiating HTTP/2
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	return t
+}
+
+// ParseSize parses a byte size such as "2G", "700M", "512K", or "1048576".
+// Suffixes are binary multiples and may be followed by "B" or "iB".
+func ParseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+	multiplier := int64(1)
+	if s != EmptyString {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	number, err := strconv.ParseFloat(s, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
 // Helper functions to get environment variables with defaults
 func getEnvString(key, defaultValue string) string {
 	if value := os.Getenv(key); value != EmptyString {
@@ -80,6 +597,54 @@ func getEnvString(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvList splits a comma-separated environment variable into its items
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != EmptyString {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvLines returns the non-empty lines of an environment variable, for
+// lists whose items may contain commas.
+func getEnvLines(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), "\n") {
+		if item = strings.TrimSpace(item); item != EmptyString {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ParseIntList parses a comma-separated list of integers such as "429,503".
+func ParseIntList(value string) ([]int, error) {
+	var items []int
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == EmptyString {
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", item)
+		}
+		items = append(items, n)
+	}
+	return items, nil
+}
+
+func getEnvIntList(key string, defaultValue []int) []int {
+	if value := os.Getenv(key); value != EmptyString {
+		if result, err := ParseIntList(value); err == nil {
+			return result
+		}
+	}
+	return defaultValue
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != EmptyString {
 		var result int
@@ -90,6 +655,33 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != EmptyString {
+		if result, err := strconv.ParseFloat(value, 64); err == nil {
+			return result
+		}
+	}
+	return defaultValue
+}
+
+func getEnvSize(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != EmptyString {
+		if result, err := ParseSize(value); err == nil {
+			return result
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != EmptyString {
+		if result, err := strconv.ParseBool(value); err == nil {
+			return result
+		}
+	}
+	return defaultValue
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != EmptyString {
 		if duration, err := time.ParseDuration(value); err == nil {
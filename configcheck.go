@@ -0,0 +1,157 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/depthboost"
+	"github.com/Sudo-Ivan/website-archiver/internal/profile"
+	"github.com/Sudo-Ivan/website-archiver/internal/remap"
+	"github.com/Sudo-Ivan/website-archiver/pkg"
+)
+
+// Sources of a configuration value, as printed by config check
+const (
+	sourceDefault = "default"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+	sourceProfile = "profile"
+)
+
+// runConfig dispatches the config subcommands
+func runConfig(ctx context.Context, cfg *config.Config, args []string) error {
+	const usage = "usage: website-archiver config check [--profile name] [flags] [url...] [depth]"
+	if len(args) < pkg.OneLength {
+		return errors.New(usage)
+	}
+	switch args[pkg.FirstIndex] {
+	case "check":
+		return checkConfig(ctx, cfg, args[pkg.SecondIndex:], os.Stdout)
+	default:
+		return errors.New(usage)
+	}
+}
+
+// checkConfig parses the same flags as a download run, prints every
+// effective setting with where it came from, and reports each problem the
+// run would hit.
+func checkConfig(_ context.Context, cfg *config.Config, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	var opts runOptions
+	runFlags(fs, &opts)
+	configFlags(fs, cfg)
+	torFlags(fs, cfg)
+	profileFlags, _, rest, err := profile.Expand(args)
+	if err != nil {
+		return err
+	}
+	// Parse the profiles first to tell their flags from the command line's
+	if err := fs.Parse(profileFlags); err != nil {
+		return err
+	}
+	fromProfile := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { fromProfile[f.Name] = true })
+	if err := fs.Parse(rest); err != nil {
+		return err
+	}
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	for _, f := range rest[:len(rest)-fs.NArg()] {
+		if name, _, _ := strings.Cut(strings.TrimLeft(f, "-"), "="); strings.HasPrefix(f, "-") {
+			fromProfile[name] = false
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, pkg.ZeroValue, pkg.TabWidth, pkg.TabPadding, ' ', pkg.ZeroValue)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range cfg.Settings() {
+		name, source := s.Env, sourceDefault
+		if name == pkg.EmptyString {
+			name = "--" + s.Flag
+		}
+		switch {
+		case s.Flag != pkg.EmptyString && fromProfile[s.Flag]:
+			source = sourceProfile + " --" + s.Flag
+		case s.Flag != pkg.EmptyString && setFlags[s.Flag]:
+			source = sourceFlag + " --" + s.Flag
+		case s.Env != pkg.EmptyString && os.Getenv(s.Env) != pkg.EmptyString:
+			source = sourceEnv
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, s.Value, source)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write settings: %w", err)
+	}
+
+	var problems []error
+	if err := cfg.Validate(); err != nil {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			problems = append(problems, joined.Unwrap()...)
+		} else {
+			problems = append(problems, err)
+		}
+	}
+	problems = append(problems, runProblems(cfg, opts, fs.Args())...)
+	if len(problems) == pkg.ZeroLength {
+		fmt.Fprintln(w, "\nConfiguration OK")
+		return nil
+	}
+	fmt.Fprintln(w, "\nProblems:")
+	for _, problem := range problems {
+		fmt.Fprintln(w, "  - "+problem.Error())
+	}
+	return fmt.Errorf("configuration has %d problem(s)", len(problems))
+}
+
+// runProblems checks the settings that only make sense together with the
+// flags and arguments of a download run.
+func runProblems(cfg *config.Config, opts runOptions, args []string) []error {
+	var problems []error
+	if len(args) > pkg.ZeroLength {
+		depth := pkg.ZeroDepth
+		urls := args
+		if n, err := fmt.Sscanf(args[len(args)-pkg.OneLength], "%d", &depth); err == nil && n == pkg.OneLength {
+			urls = args[:len(args)-pkg.OneLength]
+		}
+		if depth > cfg.MaxDepth {
+			problems = append(problems, fmt.Errorf("depth %d exceeds MAX_DEPTH %d; lower the depth or raise MAX_DEPTH", depth, cfg.MaxDepth))
+		}
+		for _, url := range urls {
+			if err := validateURL(url, cfg.LegacyProtocols); err != nil {
+				problems = append(problems, fmt.Errorf("invalid URL %s: %w", url, err))
+			}
+		}
+	}
+	if opts.allSnapshots && opts.specificSnapshot != pkg.EmptyString {
+		problems = append(problems, errors.New("--all-snapshots and --snapshot exclude each other; pick one"))
+	}
+	if cfg.WaybackPatch && (opts.allSnapshots || opts.specificSnapshot != pkg.EmptyString) {
+		problems = append(problems, errors.New("--wayback-patch only applies to direct downloads; drop it or the snapshot flags"))
+	}
+	if !opts.createZim && cfg.KeepRaw {
+		problems = append(problems, errors.New("--keep-raw has no effect without --zim"))
+	}
+	if !opts.createZim && cfg.ZIMMaxSize > pkg.ZeroValue {
+		problems = append(problems, errors.New("--zim-max-size has no effect without --zim"))
+	}
+	if err := setupAuth(cfg); err != nil {
+		problems = append(problems, fmt.Errorf("credentials: %w", err))
+	}
+	if _, err := remap.Load(cfg.RemapRules, cfg.RemapFile); err != nil {
+		problems = append(problems, fmt.Errorf("remap rules: %w", err))
+	}
+	if _, err := depthboost.Load(cfg.DepthBoosts, cfg.DepthBoostFile); err != nil {
+		problems = append(problems, fmt.Errorf("depth boosts: %w", err))
+	}
+	return problems
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/sitetest"
+	"github.com/Sudo-Ivan/website-archiver/pkg"
+)
+
+// fixtureUsage lists the fixture subcommands
+const fixtureUsage = "usage: website-archiver fixture generate|record|serve|check [flags] ..."
+
+// errGoldenMismatch is returned when an archive differs from its golden snapshot
+var errGoldenMismatch = errors.New("archive differs from the golden snapshot")
+
+// runFixture dispatches the fixture subcommands, which build test data for
+// end-to-end runs of the downloader: generate writes a fake site, record
+// snapshots a live one, serve replays a fixture over HTTP, and check archives
+// a fixture in memory and compares the result with its golden snapshot
+func runFixture(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) < pkg.OneLength {
+		return errors.New(fixtureUsage)
+	}
+	rest := args[pkg.SecondIndex:]
+	switch args[pkg.FirstIndex] {
+	case "generate":
+		return generateFixture(cfg, rest)
+	case "record":
+		return recordFixture(ctx, cfg, rest)
+	case "serve":
+		return serveFixture(ctx, cfg, rest)
+	case "check":
+		return checkFixture(ctx, cfg, rest)
+	default:
+		return errors.New(fixtureUsage)
+	}
+}
+
+func generateFixture(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("fixture generate", flag.ContinueOnError)
+	var spec sitetest.Spec
+	fs.IntVar(&spec.Pages, "pages", 10, "Number of pages")
+	fs.IntVar(&spec.Links, "links", 3, "Links from each page to other pages")
+	fs.BoolVar(&spec.Assets, "assets", true, "Add a stylesheet, a script, and images")
+	fs.BoolVar(&spec.Broken, "broken", true, "Add a broken link to the index")
+	fs.BoolVar(&spec.Query, "query", true, "Add a listing whose pages differ only in their query string")
+	fs.Uint64Var(&spec.Seed, "seed", 1, "Seed of the random link structure")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength {
+		return fmt.Errorf("usage: website-archiver fixture generate [--pages n] [--links n] [--seed n] <fixture-dir>")
+	}
+	dir := fs.Arg(pkg.FirstIndex)
+	site := sitetest.Generate(spec)
+	if err := site.Save(dir, cfg.DirPerms, cfg.FilePerms); err != nil {
+		return err
+	}
+	slog.Info("Fixture generated", "fixture", dir, "resources", len(site.URLs()))
+	return nil
+}
+
+func recordFixture(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("fixture record", flag.ContinueOnError)
+	depth := fs.Int("depth", pkg.OneDepth, "Crawl depth")
+	configFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.TwoLength {
+		return fmt.Errorf("usage: website-archiver fixture record [--depth n] [flags] <url> <fixture-dir>")
+	}
+	seed, dir := fs.Arg(pkg.FirstIndex), fs.Arg(pkg.SecondIndex)
+	if err := validateURL(seed, cfg.LegacyProtocols); err != nil {
+		return fmt.Errorf("invalid URL %s: %w", seed, err)
+	}
+	site, err := sitetest.Record(ctx, seed, *depth, cfg)
+	if err != nil {
+		return err
+	}
+	if err := site.Save(dir, cfg.DirPerms, cfg.FilePerms); err != nil {
+		return err
+	}
+	slog.Info("Fixture recorded", pkg.LogURL, seed, "fixture", dir, "resources", len(site.URLs()))
+	return nil
+}
+
+func serveFixture(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("fixture serve", flag.ContinueOnError)
+	addr := fs.String("addr", pkg.DefaultServeAddr, "Address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength {
+		return fmt.Errorf("usage: website-archiver fixture serve [--addr host:port] <fixture-dir>")
+	}
+	site, err := sitetest.Load(fs.Arg(pkg.FirstIndex))
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: *addr, Handler: site, ReadHeaderTimeout: cfg.HTTPTimeout}
+	slog.Info("Serving fixture", "fixture", fs.Arg(pkg.FirstIndex), "resources", len(site.URLs()), "url", "http://"+*addr+"/")
+	return serveHTTP(ctx, server, nil)
+}
+
+func checkFixture(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("fixture check", flag.ContinueOnError)
+	depth := fs.Int("depth", config.DefaultMaxDepth, "Crawl depth")
+	update := fs.Bool("update", false, "Rewrite the golden snapshot from this run instead of comparing")
+	configFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != pkg.OneLength {
+		return fmt.Errorf("usage: website-archiver fixture check [--depth n] [--update] [flags] <fixture-dir>")
+	}
+	dir := fs.Arg(pkg.FirstIndex)
+	site, err := sitetest.Load(dir)
+	if err != nil {
+		return err
+	}
+	golden := filepath.Join(dir, sitetest.GoldenFile)
+	diffs, err := sitetest.Compare(ctx, site, *depth, cfg, golden, *update)
+	if err != nil {
+		return err
+	}
+	if *update {
+		slog.Info("Golden snapshot updated", "golden", golden)
+		return nil
+	}
+	for _, diff := range diffs {
+		fmt.Println(diff)
+	}
+	if len(diffs) > pkg.ZeroLength {
+		return fmt.Errorf("%w: %d differences", errGoldenMismatch, len(diffs))
+	}
+	slog.Info("Archive matches the golden snapshot", "golden", golden)
+	return nil
+}
@@ -2,4 +2,16 @@ module github.com/Sudo-Ivan/website-archiver
 
 go 1.24.5
 
-require golang.org/x/net v0.42.0
+require (
+	filippo.io/age v1.2.1
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.18.0
+	github.com/ulikunitz/xz v0.5.12
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/net v0.42.0
+)
+
+require (
+	golang.org/x/crypto v0.40.0 // indirect
+	golang.org/x/sys v0.34.0 // indirect
+)
@@ -0,0 +1,240 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package a11y runs basic accessibility checks on captured pages and verifies
+// that accessibility metadata survives the link rewriting pass.
+package a11y
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+	"golang.org/x/net/html"
+)
+
+// Issue is a single accessibility problem found on a page.
+type Issue struct {
+	Rule    string `json:"rule"`
+	Element string `json:"element"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// Page collects the issues found on one captured page.
+type Page struct {
+	URL    string  `json:"url"`
+	Path   string  `json:"path"`
+	Issues []Issue `json:"issues"`
+}
+
+// Report is the accessibility report of a capture. It is safe for concurrent use.
+type Report struct {
+	mu    sync.Mutex
+	Pages []Page `json:"pages"`
+}
+
+// Add records the issues of a page; pages without issues are skipped.
+func (r *Report) Add(p Page) {
+	if len(p.Issues) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Pages = append(r.Pages, p)
+}
+
+// Write stores the report as JSON as name in s.
+func (r *Report) Write(s storage.Storage, name string) error {
+	r.mu.Lock()
+	sort.Slice(r.Pages, func(i, j int) bool { return r.Pages[i].URL < r.Pages[j].URL })
+	if r.Pages == nil {
+		r.Pages = []Page{}
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode accessibility report: %w", err)
+	}
+	if err := storage.WriteFile(s, name, data); err != nil {
+		return fmt.Errorf("failed to write accessibility report: %w", err)
+	}
+	return nil
+}
+
+// IsMetadata reports whether an attribute carries accessibility metadata
+// that must survive rewriting.
+func IsMetadata(key string) bool {
+	return strings.HasPrefix(key, "aria-") || key == "role" || key == "alt" || key == "lang" || key == "tabindex"
+}
+
+// Metadata lists the accessibility attributes of a document in document
+// order, as "tag key=value" strings, for round-trip comparisons.
+func Metadata(doc *html.Node) []string {
+	var out []string
+	walk(doc, func(n *html.Node) {
+		for _, a := range n.Attr {
+			if IsMetadata(a.Key) {
+				out = append(out, n.Data+" "+a.Key+"="+a.Val)
+			}
+		}
+	})
+	return out
+}
+
+// Lost returns the accessibility attributes present in before but not in after.
+func Lost(before, after []string) []string {
+	remaining := make(map[string]int, len(after))
+	for _, a := range after {
+		remaining[a]++
+	}
+	var lost []string
+	for _, b := range before {
+		if remaining[b] > 0 {
+			remaining[b]--
+			continue
+		}
+		lost = append(lost, b)
+	}
+	return lost
+}
+
+// Check runs the basic accessibility rules on a parsed page.
+func Check(doc *html.Node) []Issue {
+	var issues []Issue
+	ids := make(map[string]bool)
+	labelled := make(map[string]bool)
+	var lastHeading int
+	hasTitle := false
+
+	walk(doc, func(n *html.Node) {
+		if id := attr(n, "id"); id != "" {
+			ids[id] = true
+		}
+		if n.Data == "label" {
+			if target := attr(n, "for"); target != "" {
+				labelled[target] = true
+			}
+		}
+	})
+
+	walk(doc, func(n *html.Node) {
+		switch n.Data {
+		case "html":
+			if attr(n, "lang") == "" {
+				issues = append(issues, Issue{Rule: "html-lang", Element: "html", Detail: "document language is not set"})
+			}
+		case "title":
+			hasTitle = hasTitle || strings.TrimSpace(text(n)) != ""
+		case "img":
+			if _, ok := attrOK(n, "alt"); !ok && attr(n, "role") != "presentation" && !hasAriaName(n) {
+				issues = append(issues, Issue{Rule: "img-alt", Element: describe(n, "src"), Detail: "image has no alt text"})
+			}
+		case "a":
+			if attr(n, "href") != "" && strings.TrimSpace(text(n)) == "" && !hasAriaName(n) && !containsNamedImage(n) {
+				issues = append(issues, Issue{Rule: "link-name", Element: describe(n, "href"), Detail: "link has no accessible name"})
+			}
+		case "button":
+			if strings.TrimSpace(text(n)) == "" && !hasAriaName(n) && !containsNamedImage(n) {
+				issues = append(issues, Issue{Rule: "button-name", Element: describe(n, "id"), Detail: "button has no accessible name"})
+			}
+		case "input", "select", "textarea":
+			kind := attr(n, "type")
+			if kind == "hidden" || kind == "submit" || kind == "button" || kind == "reset" || kind == "image" {
+				break
+			}
+			if !labelled[attr(n, "id")] && !hasAriaName(n) && attr(n, "title") == "" && !insideLabel(n) {
+				issues = append(issues, Issue{Rule: "form-label", Element: describe(n, "name"), Detail: "form field has no label"})
+			}
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			level := int(n.Data[1] - '0')
+			if lastHeading > 0 && level > lastHeading+1 {
+				issues = append(issues, Issue{Rule: "heading-order", Element: n.Data, Detail: fmt.Sprintf("heading level jumps from h%d to h%d", lastHeading, level)})
+			}
+			lastHeading = level
+		}
+
+		for _, key := range []string{"aria-labelledby", "aria-describedby"} {
+			for _, ref := range strings.Fields(attr(n, key)) {
+				if !ids[ref] {
+					issues = append(issues, Issue{Rule: "aria-reference", Element: describe(n, "id"), Detail: fmt.Sprintf("%s references missing id %q", key, ref)})
+				}
+			}
+		}
+	})
+
+	if !hasTitle {
+		issues = append(issues, Issue{Rule: "document-title", Element: "title", Detail: "page has no title"})
+	}
+	return issues
+}
+
+func walk(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		fn(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func attrOK(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func attr(n *html.Node, key string) string {
+	v, _ := attrOK(n, key)
+	return v
+}
+
+func hasAriaName(n *html.Node) bool {
+	return strings.TrimSpace(attr(n, "aria-label")) != "" || attr(n, "aria-labelledby") != "" || attr(n, "title") != ""
+}
+
+func containsNamedImage(n *html.Node) bool {
+	found := false
+	walk(n, func(c *html.Node) {
+		if (c.Data == "img" && strings.TrimSpace(attr(c, "alt")) != "") || (c.Data == "svg" && hasAriaName(c)) {
+			found = true
+		}
+	})
+	return found
+}
+
+func insideLabel(n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.Data == "label" {
+			return true
+		}
+	}
+	return false
+}
+
+func text(n *html.Node) string {
+	var sb strings.Builder
+	var collect func(*html.Node)
+	collect = func(c *html.Node) {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(n)
+	return sb.String()
+}
+
+func describe(n *html.Node, key string) string {
+	if v := attr(n, key); v != "" {
+		return fmt.Sprintf("%s[%s=%q]", n.Data, key, v)
+	}
+	return n.Data
+}
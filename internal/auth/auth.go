@@ -0,0 +1,93 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package auth adds per-host credentials to outgoing requests: an
+// Authorization header for mirrors and sites behind HTTP authentication,
+// and cookies for sites that hand out signed session cookies. Credentials
+// are resolved from secret references, so they can live in the OS keyring.
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/secrets"
+)
+
+// Kinds of credential a rule adds.
+const (
+	KindAuthorization = "Authorization"
+	KindCookie        = "Cookie"
+)
+
+// Rule adds one credential to requests for Host and its subdomains.
+type Rule struct {
+	Host  string
+	Kind  string
+	Value string
+}
+
+// Matches reports whether the rule applies to requests for host.
+func (r Rule) Matches(host string) bool {
+	host = strings.ToLower(host)
+	return host == r.Host || strings.HasSuffix(host, "."+r.Host)
+}
+
+// ParseRule parses "host=<secret reference>" and resolves the secret. An
+// Authorization secret of the form "user:password" becomes Basic
+// credentials; anything with a space, such as "Bearer <token>", is used as
+// the header value as it is.
+func ParseRule(kind, spec string) (Rule, error) {
+	host, ref, ok := strings.Cut(spec, "=")
+	if !ok || host == "" || ref == "" {
+		return Rule{}, fmt.Errorf("%s rule %q is not of the form host=<secret reference>", kind, spec)
+	}
+	secret, err := secrets.Resolve(ref)
+	if err != nil {
+		return Rule{}, fmt.Errorf("failed to resolve %s secret for %s: %w", kind, host, err)
+	}
+	if kind == KindAuthorization && !strings.Contains(secret, " ") && strings.Contains(secret, ":") {
+		secret = "Basic " + base64.StdEncoding.EncodeToString([]byte(secret))
+	}
+	return Rule{Host: strings.ToLower(host), Kind: kind, Value: secret}, nil
+}
+
+// Transport adds the credentials of matching rules to requests. Cookies are
+// added to those a request already has; an Authorization header already set
+// is kept. Credentials follow the host only, so a redirect to another host
+// does not receive them.
+type Transport struct {
+	Next  http.RoundTripper
+	Rules []Rule
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	var matched []Rule
+	for _, rule := range t.Rules {
+		if rule.Matches(req.URL.Hostname()) && (rule.Kind == KindCookie || req.Header.Get(rule.Kind) == "") {
+			matched = append(matched, rule)
+		}
+	}
+	if len(matched) == 0 {
+		return next.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the request they are given
+	req = req.Clone(req.Context())
+	for _, rule := range matched {
+		if existing := req.Header.Get(rule.Kind); rule.Kind == KindCookie && existing != "" {
+			req.Header.Set(KindCookie, existing+"; "+rule.Value)
+			continue
+		}
+		req.Header.Set(rule.Kind, rule.Value)
+	}
+	return next.RoundTrip(req)
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// headerSeparators are the characters RFC 9110 does not allow in header names.
+const headerSeparators = "\"(),/:;<=>?@[\\]{}"
+
+// ParseHeader parses a "Name: value" header as given to --header.
+func ParseHeader(spec string) (name, value string, err error) {
+	name, value, ok := strings.Cut(spec, ":")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || name == "" || strings.ContainsFunc(name, func(r rune) bool { return r <= ' ' || r >= 0x7f || strings.ContainsRune(headerSeparators, r) }) {
+		return "", "", fmt.Errorf("header %q is not of the form \"Name: value\"", spec)
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return "", "", fmt.Errorf("header %s has an invalid value", name)
+	}
+	return http.CanonicalHeaderKey(name), value, nil
+}
+
+// Headers adds the same headers and Basic credentials to every request,
+// whatever its host. Headers replace those the request already has, so a
+// User-Agent given by the user wins; the credentials are only added to
+// requests without an Authorization header, such as one from a Rule.
+type Headers struct {
+	Next     http.RoundTripper
+	Header   http.Header
+	User     string
+	Password string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Headers) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	// RoundTrippers must not modify the request they are given
+	req = req.Clone(req.Context())
+	for name, values := range t.Header {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	if t.User != "" && req.Header.Get(KindAuthorization) == "" {
+		req.SetBasicAuth(t.User, t.Password)
+	}
+	return next.RoundTrip(req)
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package bandwidth accounts for the bytes a run downloads, broken down by
+// host and content type, and turns them into an egress cost report.
+package bandwidth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+)
+
+// bytesPerGB is the divisor used for cost calculations.
+const bytesPerGB = 1 << 30
+
+// Meter is an http.RoundTripper that counts response body bytes.
+type Meter struct {
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	byHost map[string]int64
+	byType map[string]int64
+	total  int64
+}
+
+// NewMeter wraps next, or http.DefaultTransport when next is nil.
+func NewMeter(next http.RoundTripper) *Meter {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Meter{
+		next:   next,
+		byHost: make(map[string]int64),
+		byType: make(map[string]int64),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (m *Meter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := m.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	if contentType == "" {
+		contentType = "unknown"
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, meter: m, host: req.URL.Host, contentType: contentType}
+	return resp, nil
+}
+
+func (m *Meter) add(host, contentType string, n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.byHost[host] += n
+	m.byType[contentType] += n
+	m.total += n
+}
+
+type countingBody struct {
+	io.ReadCloser
+	meter       *Meter
+	host        string
+	contentType string
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.meter.add(b.host, b.contentType, int64(n))
+	}
+	return n, err
+}
+
+// Entry is one line of a report.
+type Entry struct {
+	Name  string  `json:"name"`
+	Bytes int64   `json:"bytes"`
+	Cost  float64 `json:"cost,omitempty"`
+}
+
+// Report summarizes the traffic counted by a Meter.
+type Report struct {
+	TotalBytes int64   `json:"totalBytes"`
+	CostPerGB  float64 `json:"costPerGB,omitempty"`
+	TotalCost  float64 `json:"totalCost,omitempty"`
+	Hosts      []Entry `json:"hosts"`
+	Types      []Entry `json:"contentTypes"`
+}
+
+// Report builds a report priced at costPerGB (zero disables pricing).
+func (m *Meter) Report(costPerGB float64) *Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &Report{
+		TotalBytes: m.total,
+		CostPerGB:  costPerGB,
+		TotalCost:  cost(m.total, costPerGB),
+		Hosts:      entries(m.byHost, costPerGB),
+		Types:      entries(m.byType, costPerGB),
+	}
+}
+
+// Write stores the report as JSON at path.
+func (r *Report) Write(path string, perms os.FileMode) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bandwidth report: %w", err)
+	}
+	if err := os.WriteFile(path, data, perms); err != nil {
+		return fmt.Errorf("failed to write bandwidth report: %w", err)
+	}
+	return nil
+}
+
+func entries(counts map[string]int64, costPerGB float64) []Entry {
+	out := make([]Entry, 0, len(counts))
+	for name, n := range counts {
+		out = append(out, Entry{Name: name, Bytes: n, Cost: cost(n, costPerGB)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Bytes != out[j].Bytes {
+			return out[i].Bytes > out[j].Bytes
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func cost(n int64, costPerGB float64) float64 {
+	return float64(n) / bytesPerGB * costPerGB
+}
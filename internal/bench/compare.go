@@ -0,0 +1,63 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package bench
+
+// allocSlack is how many allocations per operation a benchmark may gain
+// regardless of the threshold, so benchmarks that barely allocate do not
+// fail on runtime noise.
+const allocSlack = 10
+
+// Change compares one metric of a benchmark with its baseline.
+type Change struct {
+	Name   string
+	Metric string
+	Base   float64
+	Now    float64
+	// Delta is the relative change, positive when the metric grew.
+	Delta float64
+	// Regressed is set when the metric grew by more than the threshold.
+	Regressed bool
+}
+
+// Compare checks time and allocations per operation of current against
+// base. A metric regresses when it grew by more than threshold, a fraction
+// such as 0.1 for 10%. Benchmarks missing from the baseline are skipped.
+func Compare(base, current *Baseline, threshold float64) []Change {
+	recorded := make(map[string]Result, len(base.Results))
+	for _, r := range base.Results {
+		recorded[r.Name] = r
+	}
+	var changes []Change
+	for _, r := range current.Results {
+		old, ok := recorded[r.Name]
+		if !ok {
+			continue
+		}
+		changes = append(changes,
+			change(r.Name, "ns/op", float64(old.NsPerOp), float64(r.NsPerOp), threshold, 0),
+			change(r.Name, "allocs/op", float64(old.AllocsPerOp), float64(r.AllocsPerOp), threshold, allocSlack),
+		)
+	}
+	return changes
+}
+
+func change(name, metric string, base, now, threshold, slack float64) Change {
+	c := Change{Name: name, Metric: metric, Base: base, Now: now}
+	if base > 0 {
+		c.Delta = (now - base) / base
+	}
+	c.Regressed = c.Delta > threshold && now-base > slack
+	return c
+}
+
+// Regressions returns the changes that regressed.
+func Regressions(changes []Change) []Change {
+	var out []Change
+	for _, c := range changes {
+		if c.Regressed {
+			out = append(out, c)
+		}
+	}
+	return out
+}
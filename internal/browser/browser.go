@@ -0,0 +1,40 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package browser finds an installed Chromium or Chrome and starts it, for
+// the screenshots, PDFs, replay tests, and challenges that need a real one.
+package browser
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// names are the browser commands tried in order.
+var names = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable"}
+
+// ErrNotFound is returned when no browser is installed.
+var ErrNotFound = errors.New("no Chromium or Chrome found in PATH (tried " + strings.Join(names, ", ") + ")")
+
+// Find returns the path of the first installed browser.
+func Find() (string, error) {
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// Command returns the command running the browser at path with args, the
+// last of which is usually the page to open.
+func Command(ctx context.Context, path string, args ...string) *exec.Cmd {
+	if os.Geteuid() == 0 {
+		// Chromium refuses to run its sandbox as root, e.g. in containers
+		args = append([]string{"--no-sandbox"}, args...)
+	}
+	return exec.CommandContext(ctx, path, args...) // #nosec G204 - arguments are passed without a shell
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package browserprofile sends the headers of a current desktop browser with
+// crawl requests: its User-Agent, Accept headers that depend on what is
+// requested, Accept-Language, and Chrome's client hints. Bot detection often
+// blocks requests whose headers no browser would send.
+package browserprofile
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/referrer"
+)
+
+// Profile is the headers of a browser.
+type Profile struct {
+	Name      string
+	UserAgent string
+	// Accept is the Accept header by Sec-Fetch-Dest; "" holds the one of
+	// other destinations.
+	Accept map[string]string
+	// Document holds headers sent with navigations only, Header those sent
+	// with every request.
+	Document http.Header
+	Header   http.Header
+}
+
+// chrome is Chrome 141 on Windows.
+var chrome = Profile{
+	Name:      "chrome",
+	UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/141.0.0.0 Safari/537.36",
+	Accept: map[string]string{
+		"document": "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+		"image":    "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+		"style":    "text/css,*/*;q=0.1",
+		"":         "*/*",
+	},
+	Document: http.Header{
+		"Upgrade-Insecure-Requests": {"1"},
+		"Priority":                  {"u=0, i"},
+	},
+	Header: http.Header{
+		"Accept-Language":    {"en-US,en;q=0.9"},
+		"Sec-Ch-Ua":          {`"Google Chrome";v="141", "Not?A_Brand";v="8", "Chromium";v="141"`},
+		"Sec-Ch-Ua-Mobile":   {"?0"},
+		"Sec-Ch-Ua-Platform": {`"Windows"`},
+	},
+}
+
+// firefox is Firefox 144 on Windows.
+var firefox = Profile{
+	Name:      "firefox",
+	UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:144.0) Gecko/20100101 Firefox/144.0",
+	Accept: map[string]string{
+		"document": "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		"image":    "image/avif,image/webp,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+		"style":    "text/css,*/*;q=0.1",
+		"":         "*/*",
+	},
+	Document: http.Header{
+		"Upgrade-Insecure-Requests": {"1"},
+		"Priority":                  {"u=0, i"},
+	},
+	Header: http.Header{
+		"Accept-Language": {"en-US,en;q=0.5"},
+	},
+}
+
+// safari is Safari 26 on macOS.
+var safari = Profile{
+	Name:      "safari",
+	UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/26.0 Safari/605.1.15",
+	Accept: map[string]string{
+		"document": "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		"image":    "image/webp,image/avif,image/jxl,image/heic,image/heic-sequence,video/*;q=0.8,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+		"style":    "text/css,*/*;q=0.1",
+		"":         "*/*",
+	},
+	Document: http.Header{
+		"Priority": {"u=0, i"},
+	},
+	Header: http.Header{
+		"Accept-Language": {"en-US,en;q=0.9"},
+	},
+}
+
+var profiles = []Profile{chrome, firefox, safari}
+
+// Names returns the names of the profiles.
+func Names() []string {
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// Lookup returns the profile called name.
+func Lookup(name string) (Profile, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	i := slices.IndexFunc(profiles, func(p Profile) bool { return p.Name == name })
+	if i < 0 {
+		return Profile{}, fmt.Errorf("unknown browser profile %q (want %s)", name, strings.Join(Names(), ", "))
+	}
+	return profiles[i], nil
+}
+
+// Transport adds the headers of Profile that requests do not have yet, so
+// headers set by the crawl or the user take precedence.
+type Transport struct {
+	Next    http.RoundTripper
+	Profile Profile
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	// RoundTrippers must not modify the request they are given
+	req = req.Clone(req.Context())
+	dest := req.Header.Get("Sec-Fetch-Dest")
+	if dest == "" {
+		dest = referrer.Destination(req)
+	}
+	accept, ok := t.Profile.Accept[dest]
+	if !ok {
+		accept = t.Profile.Accept[""]
+	}
+	setMissing(req.Header, http.Header{"Accept": {accept}})
+	setMissing(req.Header, t.Profile.Header)
+	if dest == "document" {
+		setMissing(req.Header, t.Profile.Document)
+	}
+	return next.RoundTrip(req)
+}
+
+// setMissing copies the headers of from that h does not have into h.
+func setMissing(h, from http.Header) {
+	for name, values := range from {
+		if _, ok := h[name]; !ok {
+			h[name] = append([]string(nil), values...)
+		}
+	}
+}
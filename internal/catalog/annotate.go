@@ -0,0 +1,97 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+// ErrUnknownCapture is returned for captures the catalog does not list.
+var ErrUnknownCapture = errors.New("unknown capture")
+
+// Tagged returns the local captures with tag, or all of them when tag is
+// empty, newest first.
+func (c *Catalog) Tagged(tag string) []Run {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	runs := []Run{}
+	for _, run := range c.Runs {
+		if tag == "" || slices.Contains(run.Tags, tag) {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Timestamp > runs[j].Timestamp })
+	return runs
+}
+
+// Annotate replaces the tags and the note of a local capture, both in its
+// manifest and in the catalog, and returns the updated capture.
+func (c *Catalog) Annotate(capture string, tags []string, note string, perms os.FileMode) (Run, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := slices.IndexFunc(c.Runs, func(run Run) bool { return run.Capture == capture })
+	if i < 0 {
+		return Run{}, fmt.Errorf("%w %s", ErrUnknownCapture, capture)
+	}
+	dir := filepath.Join(c.root, filepath.FromSlash(capture))
+	m, err := manifest.Load(dir)
+	if err != nil {
+		return Run{}, err
+	}
+	m.SetAnnotations(tags, note)
+	if err := m.Write(dir, perms); err != nil {
+		return Run{}, err
+	}
+	c.Runs[i].Tags, c.Runs[i].Note = m.Tags, m.Note
+	return c.Runs[i], nil
+}
+
+// Published returns a catalog of the local captures tagged with one of
+// collections, for publishing them: it indexes only their entries, and their
+// tags other than the collections are left out.
+func (c *Catalog) Published(collections []string) *Catalog {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	sub := &Catalog{root: c.root, Index: make(map[string]map[int]int)}
+	kept := make(map[string]bool)
+	for _, run := range c.Runs {
+		var tags []string
+		for _, tag := range run.Tags {
+			if slices.Contains(collections, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) == 0 {
+			continue
+		}
+		run.Tags = tags
+		kept[run.Capture] = true
+		sub.Runs = append(sub.Runs, run)
+	}
+	ids := make(map[int]int)
+	for id, e := range c.Entries {
+		if e.Source == "" && kept[e.Capture] {
+			ids[id] = len(sub.Entries)
+			sub.Entries = append(sub.Entries, e)
+		}
+	}
+	for term, postings := range c.Index {
+		for id, n := range postings {
+			if subID, ok := ids[id]; ok {
+				if sub.Index[term] == nil {
+					sub.Index[term] = make(map[int]int)
+				}
+				sub.Index[term][subID] = n
+			}
+		}
+	}
+	return sub
+}
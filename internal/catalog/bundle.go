@@ -0,0 +1,271 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package catalog
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// ImportsFileName holds entries imported from other archivers, which
+	// have no local manifest and so are kept apart from the rebuilt catalog.
+	ImportsFileName = "catalog-imports.json"
+
+	// BundleVersion is the format version of export bundles.
+	BundleVersion = 1
+
+	// Members of an export bundle.
+	bundleCatalog  = "catalog.json"
+	bundleInfo     = "bundle.json"
+	bundleChecksum = "SHA256SUMS"
+
+	// maxBundleMember bounds the size of a bundle member read into memory.
+	maxBundleMember = 256 << 20
+	// unknownSource names the archiver of bundles that do not say.
+	unknownSource = "unknown"
+)
+
+// BundleInfo describes an export bundle.
+type BundleInfo struct {
+	Version   int       `json:"version"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"createdAt"`
+	Entries   int       `json:"entries"`
+}
+
+// Export writes the catalog as a gzipped tar bundle holding the catalog dump,
+// a description of the bundle, and SHA-256 sums of both for verification.
+func (c *Catalog) Export(w io.Writer, source string) error {
+	c.mu.RLock()
+	dump, err := json.Marshal(c)
+	entries := len(c.Entries)
+	c.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode catalog: %w", err)
+	}
+	info, err := json.MarshalIndent(BundleInfo{Version: BundleVersion, Source: source, CreatedAt: time.Now().UTC(), Entries: entries}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle info: %w", err)
+	}
+	var sums bytes.Buffer
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{{bundleCatalog, dump}, {bundleInfo, info}} {
+		sum := sha256.Sum256(member.data)
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), member.name)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{{bundleInfo, info}, {bundleCatalog, dump}, {bundleChecksum, sums.Bytes()}} {
+		if err := tw.WriteHeader(&tar.Header{Name: member.name, Mode: 0o644, Size: int64(len(member.data)), ModTime: now}); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+		if _, err := tw.Write(member.data); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// ReadBundle reads and verifies an export bundle.
+func ReadBundle(r io.Reader) (*BundleInfo, *Catalog, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	defer gz.Close()
+
+	members := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if hdr.Size > maxBundleMember {
+			return nil, nil, fmt.Errorf("bundle member %s is larger than %d bytes", hdr.Name, maxBundleMember)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxBundleMember))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read bundle member %s: %w", hdr.Name, err)
+		}
+		members[hdr.Name] = data
+	}
+
+	if err := verifySums(members); err != nil {
+		return nil, nil, err
+	}
+	info := &BundleInfo{}
+	if err := json.Unmarshal(members[bundleInfo], info); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse bundle info: %w", err)
+	}
+	if info.Version != BundleVersion {
+		return nil, nil, fmt.Errorf("unsupported bundle version %d", info.Version)
+	}
+	c := &Catalog{}
+	if err := json.Unmarshal(members[bundleCatalog], c); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse bundled catalog: %w", err)
+	}
+	// Bundles come from elsewhere: their paths must not leave a capture
+	for _, e := range c.Entries {
+		if !localPath(e.Capture) || !localPath(e.Path) {
+			return nil, nil, fmt.Errorf("bundled entry %s has an unsafe path %s/%s", e.URL, e.Capture, e.Path)
+		}
+	}
+	return info, c, nil
+}
+
+// verifySums checks every member listed in the checksum file, and that the
+// catalog and its description are among them.
+func verifySums(members map[string][]byte) error {
+	sums, ok := members[bundleChecksum]
+	if !ok {
+		return fmt.Errorf("bundle has no %s", bundleChecksum)
+	}
+	verified := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		want, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			continue
+		}
+		data, ok := members[name]
+		if !ok {
+			return fmt.Errorf("bundle member %s is missing", name)
+		}
+		got := sha256.Sum256(data)
+		if hex.EncodeToString(got[:]) != want {
+			return fmt.Errorf("bundle member %s failed its integrity check", name)
+		}
+		verified[name] = true
+	}
+	for _, name := range []string{bundleCatalog, bundleInfo} {
+		if !verified[name] {
+			return fmt.Errorf("bundle member %s has no checksum", name)
+		}
+	}
+	return nil
+}
+
+// Merge adds the entries of other that c does not have yet, carrying their
+// full-text postings along, and returns how many were added. Entries are the
+// same when URL, timestamp, and digest match.
+func (c *Catalog) Merge(other *Catalog, source string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Index == nil {
+		c.Index = make(map[string]map[int]int)
+	}
+
+	known := make(map[string]bool, len(c.Entries))
+	for _, e := range c.Entries {
+		known[entryKey(e)] = true
+	}
+	remap := make(map[int]int)
+	for id, e := range other.Entries {
+		key := entryKey(e)
+		if known[key] {
+			continue
+		}
+		known[key] = true
+		if e.Source == "" {
+			e.Source = source
+		}
+		remap[id] = len(c.Entries)
+		c.Entries = append(c.Entries, e)
+	}
+	for term, postings := range other.Index {
+		for id, n := range postings {
+			newID, ok := remap[id]
+			if !ok {
+				continue
+			}
+			if c.Index[term] == nil {
+				c.Index[term] = make(map[int]int)
+			}
+			c.Index[term][newID] = n
+		}
+	}
+	return len(remap)
+}
+
+func entryKey(e Entry) string {
+	return e.URL + "\x00" + e.Timestamp + "\x00" + e.Digest
+}
+
+// Import merges a bundle into the imported entries kept in root and returns
+// the bundle description and the number of entries added. Rebuild the
+// catalog afterwards to include them.
+func Import(root string, r io.Reader, perms os.FileMode) (*BundleInfo, int, error) {
+	info, bundled, err := ReadBundle(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	imports, err := loadFile(root, ImportsFileName)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Imported entries must never pass for local ones
+	source := info.Source
+	if source == "" {
+		source = unknownSource
+	}
+	added := imports.Merge(bundled, source)
+	data, err := json.Marshal(imports)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode imported entries: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ImportsFileName), data, perms); err != nil {
+		return nil, 0, fmt.Errorf("failed to write imported entries: %w", err)
+	}
+	return info, added, nil
+}
+
+// loadFile reads a catalog stored under name in root; a missing file is an
+// empty catalog.
+func loadFile(root, name string) (*Catalog, error) {
+	c := &Catalog{root: root, Index: make(map[string]map[int]int)}
+	data, err := os.ReadFile(filepath.Join(root, name)) // #nosec G304 - root is the output directory
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if c.Index == nil {
+		c.Index = make(map[string]map[int]int)
+	}
+	return c, nil
+}
@@ -0,0 +1,400 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package catalog indexes every capture below an output directory: which URL
+// was captured when, where its copy lives, and a full-text index of the
+// visible text of captured pages. The catalog is rebuilt from the manifests
+// and stored as JSON next to the captures.
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/simhash"
+	"github.com/Sudo-Ivan/website-archiver/internal/store"
+	"golang.org/x/net/html"
+)
+
+const (
+	// FileName is the name of the catalog inside the output directory.
+	FileName = "catalog.json"
+	// TimestampFormat is the 14-digit capture timestamp layout used by Wayback URLs.
+	TimestampFormat = "20060102150405"
+
+	// minTermLength skips single characters when indexing.
+	minTermLength = 2
+	// snippetLength is the number of characters of text kept per page for results.
+	snippetLength = 200
+)
+
+// waybackURL matches Wayback Machine replay URLs, capturing the timestamp and original URL.
+var waybackURL = regexp.MustCompile(`^https?://web\.archive\.org/web/([0-9]{14})[a-z_]*/(.+)$`)
+
+// OriginalURL splits a Wayback Machine replay URL into the URL it archives
+// and the capture timestamp. It reports false for any other URL.
+func OriginalURL(u string) (original, timestamp string, ok bool) {
+	match := waybackURL.FindStringSubmatch(u)
+	if match == nil {
+		return "", "", false
+	}
+	return match[2], match[1], true
+}
+
+// Entry is a single captured resource.
+type Entry struct {
+	URL         string `json:"url"`
+	Timestamp   string `json:"timestamp"`
+	Capture     string `json:"capture"`
+	Path        string `json:"path"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int64  `json:"size"`
+	Digest      string `json:"digest,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Snippet     string `json:"snippet,omitempty"`
+	// Source names the archiver an imported entry came from; local entries have none.
+	Source string `json:"source,omitempty"`
+}
+
+// Catalog is the index of an output directory. It is safe for concurrent use.
+type Catalog struct {
+	mu   sync.RWMutex
+	root string
+
+	Entries []Entry `json:"entries"`
+	// Index maps a lowercase term to the entries containing it and how often.
+	Index map[string]map[int]int `json:"index"`
+	// Runs summarizes each local capture for the collection statistics.
+	Runs []Run `json:"runs,omitempty"`
+}
+
+// Result is a search hit, with the annotations of its capture.
+type Result struct {
+	Entry
+	Score int      `json:"score"`
+	Tags  []string `json:"tags,omitempty"`
+	Note  string   `json:"note,omitempty"`
+}
+
+// TagPrefix marks the words of a query that restrict the results to the
+// captures with a tag, as in "tag:research budget".
+const TagPrefix = "tag:"
+
+// Build scans every capture manifest below root and indexes what they
+// describe, together with the entries imported from other archivers.
+// Manifests that cannot be read are logged and skipped.
+func Build(root string) (*Catalog, error) {
+	c := &Catalog{root: root, Index: make(map[string]map[int]int)}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == store.DirName {
+			return fs.SkipDir
+		}
+		dir, ok := manifest.CaptureDir(path)
+		if d.IsDir() || !ok {
+			return nil
+		}
+		m, err := manifest.Load(dir)
+		if err != nil {
+			slog.Warn("Skipping unreadable manifest", "error", err, "dir", dir)
+			return nil
+		}
+		capture, err := filepath.Rel(root, dir)
+		if err != nil {
+			return err
+		}
+		c.addManifest(filepath.ToSlash(capture), m)
+		c.addRun(filepath.ToSlash(capture), m)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build catalog: %w", err)
+	}
+
+	imports, err := loadFile(root, ImportsFileName)
+	if err != nil {
+		return nil, err
+	}
+	c.Merge(imports, "")
+	return c, nil
+}
+
+// addManifest indexes the resources of one capture.
+func (c *Catalog) addManifest(capture string, m *manifest.Manifest) {
+	created := m.CreatedAt.UTC().Format(TimestampFormat)
+	for _, r := range m.Resources {
+		if r.Status != 0 && r.Status != 200 {
+			continue
+		}
+		timestamp := created
+		if !r.FetchedAt.IsZero() {
+			timestamp = r.FetchedAt.UTC().Format(TimestampFormat)
+		}
+		e := Entry{
+			URL:         r.URL,
+			Timestamp:   timestamp,
+			Capture:     capture,
+			Path:        r.Path,
+			ContentType: r.ContentType,
+			Size:        r.Size,
+			Digest:      r.Digest,
+		}
+		if original, ts, ok := OriginalURL(r.URL); ok {
+			e.Timestamp, e.URL = ts, original
+		}
+		id := len(c.Entries)
+		if strings.Contains(r.ContentType, "html") {
+			if page, err := os.ReadFile(filepath.Join(c.root, filepath.FromSlash(capture), filepath.FromSlash(r.Path))); err == nil { // #nosec G304 - paths come from manifests below the output directory
+				text := simhash.Text(page)
+				e.Title = title(page)
+				e.Snippet = snippet(text)
+				for term, n := range terms(e.Title + " " + text) {
+					if c.Index[term] == nil {
+						c.Index[term] = make(map[int]int)
+					}
+					c.Index[term][id] += n
+				}
+			}
+		}
+		c.Entries = append(c.Entries, e)
+	}
+}
+
+// Load reads the catalog stored in root, building it if there is none yet.
+func Load(root string) (*Catalog, error) {
+	if _, err := os.Stat(filepath.Join(root, FileName)); errors.Is(err, os.ErrNotExist) {
+		return Build(root)
+	}
+	return loadFile(root, FileName)
+}
+
+// Write stores the catalog as FileName inside its root.
+func (c *Catalog) Write(perms os.FileMode) error {
+	c.mu.RLock()
+	data, err := json.Marshal(c)
+	c.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode catalog: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.root, FileName), data, perms); err != nil {
+		return fmt.Errorf("failed to write catalog: %w", err)
+	}
+	return nil
+}
+
+// Replace swaps the contents of c for those of other, e.g. after a rebuild.
+func (c *Catalog) Replace(other *Catalog) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Entries, c.Index, c.Runs = other.Entries, other.Index, other.Runs
+}
+
+// Root returns the output directory the catalog describes.
+func (c *Catalog) Root() string {
+	return c.root
+}
+
+// Search returns the pages containing every term of query, best matches
+// first. Words of query starting with TagPrefix keep only the pages of
+// captures with that tag; a query of tags alone returns all their pages,
+// newest first.
+func (c *Catalog) Search(query string, limit int) []Result {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var words, tags []string
+	for _, word := range strings.Fields(query) {
+		if tag, ok := strings.CutPrefix(strings.ToLower(word), TagPrefix); ok {
+			tags = append(tags, tag)
+		} else {
+			words = append(words, word)
+		}
+	}
+	runs := make(map[string]*Run, len(c.Runs))
+	for i := range c.Runs {
+		runs[c.Runs[i].Capture] = &c.Runs[i]
+	}
+	tagged := func(e Entry) bool {
+		for _, tag := range tags {
+			if run := runs[e.Capture]; run == nil || e.Source != "" || !slices.Contains(run.Tags, tag) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var scores map[int]int
+	if len(words) == 0 && len(tags) > 0 {
+		scores = make(map[int]int)
+		for id, e := range c.Entries {
+			if strings.Contains(e.ContentType, "html") {
+				scores[id] = 0
+			}
+		}
+	}
+	for term := range terms(strings.Join(words, " ")) {
+		postings := c.Index[term]
+		if scores == nil {
+			scores = make(map[int]int, len(postings))
+			for id, n := range postings {
+				scores[id] = n
+			}
+			continue
+		}
+		for id := range scores {
+			if n, ok := postings[id]; ok {
+				scores[id] += n
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		e := c.Entries[id]
+		if !tagged(e) {
+			continue
+		}
+		result := Result{Entry: e, Score: score}
+		if run := runs[e.Capture]; run != nil && e.Source == "" {
+			result.Tags, result.Note = run.Tags, run.Note
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Timestamp > results[j].Timestamp
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Captures returns every capture of url, oldest first.
+func (c *Catalog) Captures(url string) []Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var captures []Entry
+	for _, e := range c.Entries {
+		if e.URL == url {
+			captures = append(captures, e)
+		}
+	}
+	sort.Slice(captures, func(i, j int) bool { return captures[i].Timestamp < captures[j].Timestamp })
+	return captures
+}
+
+// Closest returns the capture of url nearest to timestamp.
+func (c *Catalog) Closest(url, timestamp string) (Entry, bool) {
+	captures := c.Captures(url)
+	if len(captures) == 0 {
+		return Entry{}, false
+	}
+	want, err := time.Parse(TimestampFormat, padTimestamp(timestamp))
+	if err != nil {
+		return captures[len(captures)-1], true
+	}
+	best, bestDiff := captures[0], time.Duration(-1)
+	for _, e := range captures {
+		t, err := time.Parse(TimestampFormat, e.Timestamp)
+		if err != nil {
+			continue
+		}
+		diff := t.Sub(want)
+		if diff < 0 {
+			diff = -diff
+		}
+		if bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = e, diff
+		}
+	}
+	return best, true
+}
+
+// FilePath returns the location of the stored copy of e. Imported entries
+// have none, as their captures live with the archiver they came from.
+func (c *Catalog) FilePath(e Entry) (string, error) {
+	if e.Source != "" {
+		return "", fmt.Errorf("capture of %s is stored by %s", e.URL, e.Source)
+	}
+	if !localPath(e.Capture) || !localPath(e.Path) {
+		return "", fmt.Errorf("capture of %s has an unsafe path", e.URL)
+	}
+	return filepath.Join(c.root, filepath.FromSlash(e.Capture), filepath.FromSlash(e.Path)), nil
+}
+
+// localPath reports whether the slash-separated path p stays inside the
+// directory it is relative to.
+func localPath(p string) bool {
+	return filepath.IsLocal(filepath.FromSlash(p))
+}
+
+// padTimestamp completes a partial timestamp such as "2023" to 14 digits,
+// as Wayback URLs allow.
+func padTimestamp(ts string) string {
+	const earliest = "00000101000000"
+	if len(ts) >= len(earliest) {
+		return ts[:len(earliest)]
+	}
+	if len(ts) < 4 {
+		return ""
+	}
+	return ts + earliest[len(ts):]
+}
+
+// terms splits text into lowercase terms with their counts.
+func terms(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) >= minTermLength {
+			counts[word]++
+		}
+	}
+	return counts
+}
+
+// title returns the document title of an HTML page.
+func title(page []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			if name, _ := z.TagName(); string(name) == "title" && z.Next() == html.TextToken {
+				return strings.TrimSpace(string(z.Text()))
+			}
+		}
+	}
+}
+
+// snippet returns the start of the visible text of a page.
+func snippet(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > snippetLength {
+		return string(runes[:snippetLength]) + "…"
+	}
+	return text
+}
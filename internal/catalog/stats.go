@@ -0,0 +1,159 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package catalog
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+// Run summarizes one local capture for the collection statistics.
+type Run struct {
+	Capture string `json:"capture"`
+	// URL is the seed of the capture, the original URL for Wayback seeds.
+	URL       string `json:"url"`
+	Domain    string `json:"domain"`
+	Timestamp string `json:"timestamp"`
+	Resources int    `json:"resources"`
+	Pages     int    `json:"pages"`
+	Bytes     int64  `json:"bytes"`
+	Errors    int    `json:"errors"`
+	// Tags and Note are the annotations of the capture.
+	Tags []string `json:"tags,omitempty"`
+	Note string   `json:"note,omitempty"`
+}
+
+// DomainStats aggregates the captures of one domain.
+type DomainStats struct {
+	Domain    string `json:"domain"`
+	Captures  int    `json:"captures"`
+	First     string `json:"first"`
+	Last      string `json:"last"`
+	Resources int    `json:"resources"`
+	Errors    int    `json:"errors"`
+	// ErrorRate is the share of fetched resources that failed, over all captures.
+	ErrorRate float64 `json:"errorRate"`
+	// Pages and Bytes are those of the latest capture; the growth fields
+	// compare it with the first.
+	Pages       int   `json:"pages"`
+	Bytes       int64 `json:"bytes"`
+	PagesGrowth int   `json:"pagesGrowth"`
+	BytesGrowth int64 `json:"bytesGrowth"`
+	// Trend lists every capture, oldest first.
+	Trend []Run `json:"trend"`
+}
+
+// addRun records the summary of one capture.
+func (c *Catalog) addRun(capture string, m *manifest.Manifest) {
+	run := Run{
+		Capture:   capture,
+		URL:       m.URL,
+		Domain:    domain(m.URL),
+		Timestamp: m.CreatedAt.UTC().Format(TimestampFormat),
+		Resources: len(m.Resources),
+		Tags:      m.Tags,
+		Note:      m.Note,
+	}
+	for _, r := range m.Resources {
+		switch {
+		case r.Status >= 400:
+			run.Errors++
+		case strings.Contains(r.ContentType, "html"):
+			run.Pages++
+			run.Bytes += r.Size
+		default:
+			run.Bytes += r.Size
+		}
+	}
+	if original, _, ok := OriginalURL(m.URL); ok {
+		run.URL = original
+	}
+	c.Runs = append(c.Runs, run)
+}
+
+// domain returns the lowercase host a seed URL belongs to, looking through
+// Wayback Machine replay URLs.
+func domain(seed string) string {
+	if original, _, ok := OriginalURL(seed); ok {
+		seed = original
+	}
+	u, err := url.Parse(seed)
+	if err != nil || u.Host == "" {
+		return seed
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// Stats aggregates the captures per domain, sorted by domain. A non-empty
+// domain limits the result to that domain.
+func (c *Catalog) Stats(domain string) []DomainStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	byDomain := make(map[string]*DomainStats)
+	for _, run := range c.Runs {
+		if domain != "" && run.Domain != domain {
+			continue
+		}
+		s := byDomain[run.Domain]
+		if s == nil {
+			s = &DomainStats{Domain: run.Domain}
+			byDomain[run.Domain] = s
+		}
+		s.Trend = append(s.Trend, run)
+	}
+
+	stats := make([]DomainStats, 0, len(byDomain))
+	for _, s := range byDomain {
+		sort.Slice(s.Trend, func(i, j int) bool { return s.Trend[i].Timestamp < s.Trend[j].Timestamp })
+		first, last := s.Trend[0], s.Trend[len(s.Trend)-1]
+		s.Captures = len(s.Trend)
+		s.First, s.Last = first.Timestamp, last.Timestamp
+		s.Pages, s.Bytes = last.Pages, last.Bytes
+		s.PagesGrowth, s.BytesGrowth = last.Pages-first.Pages, last.Bytes-first.Bytes
+		for _, run := range s.Trend {
+			s.Resources += run.Resources
+			s.Errors += run.Errors
+		}
+		if s.Resources > 0 {
+			s.ErrorRate = float64(s.Errors) / float64(s.Resources)
+		}
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Domain < stats[j].Domain })
+	return stats
+}
+
+// WriteStatsCSV writes one row per domain, or one row per capture when trend
+// is set.
+func WriteStatsCSV(w io.Writer, stats []DomainStats, trend bool) error {
+	cw := csv.NewWriter(w)
+	itoa := strconv.Itoa
+	size := func(n int64) string { return strconv.FormatInt(n, 10) }
+	if trend {
+		_ = cw.Write([]string{"domain", "timestamp", "capture", "resources", "pages", "bytes", "errors"})
+		for _, s := range stats {
+			for _, run := range s.Trend {
+				_ = cw.Write([]string{run.Domain, run.Timestamp, run.Capture, itoa(run.Resources), itoa(run.Pages), size(run.Bytes), itoa(run.Errors)})
+			}
+		}
+	} else {
+		_ = cw.Write([]string{"domain", "captures", "first", "last", "pages", "bytes", "pages_growth", "bytes_growth", "resources", "errors", "error_rate"})
+		for _, s := range stats {
+			_ = cw.Write([]string{s.Domain, itoa(s.Captures), s.First, s.Last, itoa(s.Pages), size(s.Bytes), itoa(s.PagesGrowth), size(s.BytesGrowth), itoa(s.Resources), itoa(s.Errors), strconv.FormatFloat(s.ErrorRate, 'f', 4, 64)})
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write statistics: %w", err)
+	}
+	return nil
+}
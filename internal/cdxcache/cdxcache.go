@@ -0,0 +1,112 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package cdxcache keeps Wayback Machine CDX API responses on disk, keyed by
+// the full query, so re-running a batch of URLs does not query the CDX API
+// again for every URL while the cached responses are fresh.
+package cdxcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// appDir is the directory of the tool inside the user cache directory.
+	appDir = "website-archiver"
+	// cacheDir holds one JSON file per cached query.
+	cacheDir = "cdx"
+	// extension is the file extension of a cached response.
+	extension = ".json"
+)
+
+// entry is a cached response.
+type entry struct {
+	Query     string     `json:"query"`
+	FetchedAt time.Time  `json:"fetchedAt"`
+	Rows      [][]string `json:"rows"`
+}
+
+// Cache is a directory of cached CDX responses. It is safe for concurrent use.
+type Cache struct {
+	dir       string
+	ttl       time.Duration
+	dirPerms  os.FileMode
+	filePerms os.FileMode
+}
+
+// DefaultDir returns the cache directory used when none is configured.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the cache directory: %w", err)
+	}
+	return filepath.Join(dir, appDir, cacheDir), nil
+}
+
+// New returns the cache in dir, or in DefaultDir when dir is empty. Responses
+// older than ttl are fetched again.
+func New(dir string, ttl time.Duration, dirPerms, filePerms os.FileMode) (*Cache, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	return &Cache{dir: dir, ttl: ttl, dirPerms: dirPerms, filePerms: filePerms}, nil
+}
+
+// path returns the file holding the response to query.
+func (c *Cache) path(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+extension)
+}
+
+// Get returns the cached rows of query if they are younger than the TTL.
+func (c *Cache) Get(query string) ([][]string, bool) {
+	data, err := os.ReadFile(c.path(query)) // #nosec G304 - the file name is a hash inside the cache directory
+	if err != nil {
+		return nil, false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || e.Query != query || time.Since(e.FetchedAt) > c.ttl {
+		return nil, false
+	}
+	return e.Rows, true
+}
+
+// Put stores the rows of query, replacing an older response.
+func (c *Cache) Put(query string, rows [][]string) error {
+	if err := os.MkdirAll(c.dir, c.dirPerms); err != nil {
+		return fmt.Errorf("failed to create CDX cache directory: %w", err)
+	}
+	data, err := json.Marshal(entry{Query: query, FetchedAt: time.Now().UTC(), Rows: rows})
+	if err != nil {
+		return fmt.Errorf("failed to encode CDX response: %w", err)
+	}
+	// Write to a temporary file first so concurrent readers never see half a response
+	tmp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write CDX cache: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), c.filePerms)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(query))
+	}
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to write CDX cache: %w", err), os.Remove(tmp.Name()))
+	}
+	return nil
+}
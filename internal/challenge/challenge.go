@@ -0,0 +1,115 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package challenge recognizes the anti-bot challenges of Cloudflare and
+// similar services, which answer crawlers with a "verify you are human"
+// page instead of the site, and lets the user pass them by hand so the crawl
+// continues with the clearance cookies instead of archiving the challenge.
+package challenge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/contentcoding"
+)
+
+// Strategies for challenges.
+const (
+	// Skip leaves challenged resources out of the capture and reports them
+	// as failed.
+	Skip = "skip"
+	// Cookies pauses the crawl until the user passed the challenge in a
+	// browser and exported its cookies to a cookies.txt file.
+	Cookies = "cookies"
+	// Browser pauses the crawl and opens the challenge in a Chromium window,
+	// whose cookies the crawl takes over once the user passed it.
+	Browser = "browser"
+)
+
+// CheckMode reports an error unless mode is a strategy.
+func CheckMode(mode string) error {
+	switch mode {
+	case Skip, Cookies, Browser:
+		return nil
+	}
+	return fmt.Errorf("unknown challenge strategy %q (want %s, %s, or %s)", mode, Skip, Cookies, Browser)
+}
+
+// SniffSize is how much of a body Detect looks at.
+const SniffSize = 32 << 10
+
+// marker is text that gives away the challenge page of a vendor.
+type marker struct {
+	vendor string
+	text   string
+	// anyStatus markers are specific enough to be trusted on pages served
+	// with 200, the others only on the 403, 429, and 503 of challenges
+	anyStatus bool
+}
+
+var markers = []marker{
+	{"cloudflare", "window._cf_chl_opt", true},
+	{"cloudflare", "<title>Just a moment...</title>", true},
+	{"cloudflare", "Attention Required! | Cloudflare", true},
+	{"cloudflare", "cf-browser-verification", false},
+	{"cloudflare", "/cdn-cgi/challenge-platform/", false},
+	{"ddos-guard", "check.ddos-guard.net", true},
+	{"datadome", "captcha-delivery.com", false},
+	{"perimeterx", "px-captcha", false},
+	{"imperva", "_Incapsula_Resource", false},
+	{"imperva", "Incapsula incident ID", false},
+	{"sucuri", "Sucuri WebSite Firewall - Access Denied", true},
+	{"unknown", "Verify you are human", false},
+	{"unknown", "verify you are a human", false},
+	{"unknown", "Checking your browser before accessing", false},
+	{"unknown", "Please enable JavaScript and cookies to continue", false},
+}
+
+// Error is a challenge served instead of the resource at URL.
+type Error struct {
+	URL    string
+	Vendor string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("anti-bot challenge (%s) instead of %s", e.Vendor, e.URL)
+}
+
+// Detect returns the vendor of the challenge a response is, or "" when it
+// is none. head is the start of the body as served, before its content
+// coding is undone.
+func Detect(status int, header http.Header, head []byte) string {
+	if strings.EqualFold(header.Get("Cf-Mitigated"), "challenge") {
+		return "cloudflare"
+	}
+	blocking := status == http.StatusForbidden || status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+	if !blocking && !strings.Contains(strings.ToLower(header.Get("Content-Type")), "html") {
+		return ""
+	}
+	body := decode(head, header.Get("Content-Encoding"))
+	for _, m := range markers {
+		if (blocking || m.anyStatus) && bytes.Contains(body, []byte(m.text)) {
+			return m.vendor
+		}
+	}
+	return ""
+}
+
+// decode undoes what it can of the content coding of head, which is cut off.
+func decode(head []byte, coding string) []byte {
+	if coding == "" {
+		return head
+	}
+	r, _, err := contentcoding.Decode(bytes.NewReader(head), coding)
+	if err != nil {
+		return head
+	}
+	defer r.Close()
+	// The stream ends early, so what was decoded is kept despite the error
+	body, _ := io.ReadAll(io.LimitReader(r, 4*SniffSize))
+	return body
+}
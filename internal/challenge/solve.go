@@ -0,0 +1,301 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package challenge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/browser"
+	"github.com/Sudo-Ivan/website-archiver/internal/cookies"
+)
+
+const (
+	// maxTries is how often the user is asked to pass the challenges of a
+	// host before they are skipped.
+	maxTries = 2
+	// devtoolsTimeout bounds the time the browser takes to start and to
+	// answer for its cookies.
+	devtoolsTimeout = 30 * time.Second
+	// devtoolsPortFile is where Chromium writes the port and path of its
+	// DevTools endpoint inside its profile.
+	devtoolsPortFile = "DevToolsActivePort"
+)
+
+// ErrNotInteractive is returned when a challenge needs the user but standard
+// input is not a terminal, as in the daemon.
+var ErrNotInteractive = errors.New("challenges can only be passed with a terminal on standard input")
+
+// Solver lets the user pass the challenges of a crawl, one at a time, and
+// puts the cookies that clear them into Jar.
+type Solver struct {
+	Mode string
+	// CookiesFile is offered as the file the Cookies strategy reads
+	CookiesFile string
+	// UserAgent is that of the crawl, which clearance cookies are bound to
+	UserAgent string
+	Jar       http.CookieJar
+	In        *os.File
+	Out       io.Writer
+
+	mu     sync.Mutex
+	lines  *bufio.Reader
+	solved map[string]time.Time
+	tries  map[string]int
+}
+
+// Solve pauses until the user passed the challenge of vendor served for u
+// at seen, and returns nil when u should be requested again. Hosts whose
+// challenge was passed after seen are not asked for again. Without a
+// strategy that involves the user, or once they gave up, it returns an
+// *Error.
+func (s *Solver) Solve(ctx context.Context, u *url.URL, vendor string, seen time.Time) error {
+	challenged := &Error{URL: u.String(), Vendor: vendor}
+	if s == nil || s.Mode == Skip || s.Mode == "" {
+		return challenged
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.solved == nil {
+		s.solved, s.tries = make(map[string]time.Time), make(map[string]int)
+	}
+	host := u.Hostname()
+	if s.solved[host].After(seen) {
+		return nil
+	}
+	if s.tries[host] >= maxTries {
+		return challenged
+	}
+	if !isTerminal(s.In) {
+		return fmt.Errorf("%w: %w", challenged, ErrNotInteractive)
+	}
+	s.tries[host]++
+	if s.lines == nil {
+		s.lines = bufio.NewReader(s.In)
+	}
+	fmt.Fprintf(s.Out, "\n%s answered %s with an anti-bot challenge (%s); the crawl is paused.\n", host, u, vendor)
+	var err error
+	switch s.Mode {
+	case Cookies:
+		err = s.importCookies(u)
+	case Browser:
+		err = s.browse(ctx, u)
+	default:
+		err = CheckMode(s.Mode)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", challenged, err)
+	}
+	s.solved[host] = time.Now()
+	fmt.Fprintf(s.Out, "Continuing the crawl of %s.\n", host)
+	return nil
+}
+
+// importCookies has the user pass the challenge in their own browser and
+// reads its cookies from a cookies.txt file.
+func (s *Solver) importCookies(u *url.URL) error {
+	fmt.Fprintf(s.Out, "Open it in a browser sending the User-Agent\n  %s\npass the check, and export the cookies of %s in cookies.txt format.\n", s.UserAgent, u.Hostname())
+	prompt := "Enter the path of the cookies file: "
+	if s.CookiesFile != "" {
+		prompt = fmt.Sprintf("Enter the path of the cookies file [%s]: ", s.CookiesFile)
+	}
+	fmt.Fprint(s.Out, prompt)
+	file, err := s.readLine()
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		file = s.CookiesFile
+	}
+	if file == "" {
+		return errors.New("no cookies file given")
+	}
+	f, err := os.Open(file) // #nosec G304 - path is a cookies file chosen by the user
+	if err != nil {
+		return fmt.Errorf("failed to open cookies file: %w", err)
+	}
+	defer f.Close()
+	n, err := cookies.Load(s.Jar, f, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to read cookies file %s: %w", file, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("cookies file %s holds no cookies", file)
+	}
+	return nil
+}
+
+// browse opens u in a Chromium window with the User-Agent of the crawl and
+// takes over its cookies once the user passed the challenge.
+func (s *Solver) browse(ctx context.Context, u *url.URL) error {
+	chromium, err := browser.Find()
+	if err != nil {
+		return err
+	}
+	profile, err := os.MkdirTemp("", "website-archiver-challenge-")
+	if err != nil {
+		return fmt.Errorf("failed to create browser profile: %w", err)
+	}
+	defer os.RemoveAll(profile)
+
+	ctx, cancel := context.WithCancel(ctx)
+	args := []string{
+		"--user-data-dir=" + profile,
+		"--remote-debugging-port=0",
+		"--no-first-run", "--no-default-browser-check",
+		"--user-agent=" + s.UserAgent,
+		"--new-window",
+	}
+	cmd := browser.Command(ctx, chromium, append(args, u.String())...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("failed to start %s: %w", chromium, err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	defer func() {
+		cancel()
+		<-exited
+	}()
+
+	endpoint, err := devtoolsEndpoint(profile, exited)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	fmt.Fprint(s.Out, "Pass the check in the browser window that opened, then press Enter here: ")
+	if _, err := s.readLine(); err != nil {
+		return err
+	}
+	found, err := browserCookies(endpoint)
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		return errors.New("the browser holds no cookies")
+	}
+	for _, c := range found {
+		cookies.Set(s.Jar, strings.TrimPrefix(c.Domain, "."), c.cookie())
+	}
+	return nil
+}
+
+func (s *Solver) readLine() (string, error) {
+	line, err := s.lines.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// isTerminal reports whether f is a terminal the user can answer on: a
+// character device other than the null device daemons read from.
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// devtoolsEndpoint waits for the browser with profile to open its DevTools
+// endpoint and returns the WebSocket URL of the browser. exited is closed
+// when the browser exits.
+func devtoolsEndpoint(profile string, exited <-chan struct{}) (string, error) {
+	deadline := time.Now().Add(devtoolsTimeout)
+	for time.Now().Before(deadline) {
+		data, err := os.ReadFile(filepath.Join(profile, devtoolsPortFile)) // #nosec G304 - the file is in the temporary profile
+		if lines := strings.Fields(string(data)); err == nil && len(lines) == 2 {
+			return "ws://127.0.0.1:" + lines[0] + lines[1], nil
+		}
+		select {
+		case <-exited:
+			return "", errors.New("the browser exited before it could be controlled")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return "", errors.New("the browser did not open its DevTools endpoint")
+}
+
+// devtoolsCookie is a cookie as the DevTools protocol describes it.
+type devtoolsCookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain"`
+	Path     string  `json:"path"`
+	Expires  float64 `json:"expires"`
+	Secure   bool    `json:"secure"`
+	HTTPOnly bool    `json:"httpOnly"`
+	Session  bool    `json:"session"`
+}
+
+func (c devtoolsCookie) cookie() *http.Cookie {
+	cookie := &http.Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HttpOnly: c.HTTPOnly}
+	if strings.HasPrefix(c.Domain, ".") {
+		cookie.Domain = strings.TrimPrefix(c.Domain, ".")
+	}
+	if !c.Session && c.Expires > 0 {
+		cookie.Expires = time.Unix(int64(c.Expires), 0)
+	}
+	return cookie
+}
+
+// browserCookies asks the browser at the DevTools endpoint for all of its
+// cookies.
+func browserCookies(endpoint string) ([]devtoolsCookie, error) {
+	ws, err := websocket.Dial(endpoint, "", "http://127.0.0.1/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the browser: %w", err)
+	}
+	defer ws.Close()
+	if err := ws.SetDeadline(time.Now().Add(devtoolsTimeout)); err != nil {
+		return nil, err
+	}
+	const id = 1
+	if err := websocket.JSON.Send(ws, map[string]any{"id": id, "method": "Storage.getCookies"}); err != nil {
+		return nil, fmt.Errorf("failed to ask the browser for its cookies: %w", err)
+	}
+	for {
+		var msg struct {
+			ID     int `json:"id"`
+			Result struct {
+				Cookies []devtoolsCookie `json:"cookies"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return nil, fmt.Errorf("failed to read the cookies of the browser: %w", err)
+		}
+		if msg.ID != id {
+			continue
+		}
+		if msg.Error != nil {
+			return nil, fmt.Errorf("the browser refused its cookies: %s", msg.Error.Message)
+		}
+		return msg.Result.Cookies, nil
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package chunk splits content into content-defined chunks with FastCDC.
+// Chunk boundaries depend only on the bytes around them, so an edit in one
+// part of a file leaves the chunks of the rest unchanged and repeated
+// captures of slowly changing files share most of their chunks.
+package chunk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+const (
+	// DefaultMinSize is the smallest chunk cut before the end of the input.
+	DefaultMinSize = 256 << 10
+	// DefaultAvgSize is the chunk size the cut points are normalized around.
+	DefaultAvgSize = 1 << 20
+	// DefaultMaxSize is the largest chunk.
+	DefaultMaxSize = 4 << 20
+
+	// normalization is how many mask bits are added before and removed after
+	// the average size, which narrows the spread of chunk sizes.
+	normalization = 2
+	// gearSeed seeds the gear table. Changing it moves every chunk boundary.
+	gearSeed = 0x5741524348495645
+)
+
+// Options controls chunk sizes. Sizes are in bytes and AvgSize must be a
+// power of two.
+type Options struct {
+	MinSize int
+	AvgSize int
+	MaxSize int
+}
+
+// DefaultOptions returns the chunk sizes used for archives.
+func DefaultOptions() Options {
+	return Options{MinSize: DefaultMinSize, AvgSize: DefaultAvgSize, MaxSize: DefaultMaxSize}
+}
+
+// Validate reports whether the options describe usable chunk sizes.
+func (o Options) Validate() error {
+	if o.MinSize <= 0 || o.AvgSize < o.MinSize || o.MaxSize < o.AvgSize {
+		return fmt.Errorf("invalid chunk sizes min=%d avg=%d max=%d", o.MinSize, o.AvgSize, o.MaxSize)
+	}
+	if bits.OnesCount(uint(o.AvgSize)) != 1 {
+		return fmt.Errorf("average chunk size %d is not a power of two", o.AvgSize)
+	}
+	return nil
+}
+
+// gear maps every byte to a pseudo-random 64-bit value.
+var gear = func() (table [256]uint64) {
+	state := uint64(gearSeed)
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// Chunker reads chunks from a stream.
+type Chunker struct {
+	r     io.Reader
+	opts  Options
+	maskS uint64
+	maskL uint64
+	buf   []byte
+	start int
+	end   int
+	eof   bool
+}
+
+// New returns a Chunker reading from r.
+func New(r io.Reader, opts Options) (*Chunker, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	avgBits := bits.TrailingZeros(uint(opts.AvgSize))
+	return &Chunker{
+		r:     r,
+		opts:  opts,
+		maskS: topBits(avgBits + normalization),
+		maskL: topBits(avgBits - normalization),
+		buf:   make([]byte, 2*opts.MaxSize),
+	}, nil
+}
+
+// topBits returns a mask of the n most significant bits. The high bits of the
+// gear hash depend on the most input bytes, so they make the best cut test.
+func topBits(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	return ^uint64(0) << (64 - n)
+}
+
+// Next returns the next chunk, or io.EOF after the last one. The chunk is
+// only valid until the next call.
+func (c *Chunker) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+	n := c.cut(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+// fill tops up the buffer until it holds at least MaxSize bytes or the
+// input is exhausted.
+func (c *Chunker) fill() error {
+	if c.end-c.start >= c.opts.MaxSize || c.eof {
+		return nil
+	}
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+	for c.end < c.opts.MaxSize && !c.eof {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+		} else if err != nil {
+			return fmt.Errorf("failed to read chunk data: %w", err)
+		}
+	}
+	return nil
+}
+
+// cut returns the length of the chunk at the start of data.
+func (c *Chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.opts.MinSize {
+		return n
+	}
+	if n > c.opts.MaxSize {
+		n = c.opts.MaxSize
+	}
+	normal := c.opts.AvgSize
+	if n < normal {
+		normal = n
+	}
+
+	var hash uint64
+	i := c.opts.MinSize
+	for ; i < normal; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
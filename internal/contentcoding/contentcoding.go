@@ -0,0 +1,161 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package contentcoding decodes the content codings of HTTP responses. The
+// crawler asks for them itself, so the standard library no longer undoes
+// gzip for it, and servers that send a coding they were not asked for, or
+// label plain bytes with one, are handled alike.
+package contentcoding
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Accept is the Accept-Encoding of crawl requests, the codings Decode undoes.
+const Accept = "gzip, deflate, br, zstd"
+
+// Magic numbers of the coded streams, which tell mislabeled bytes apart.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Codings returns the codings of a Content-Encoding header in the order they
+// were applied, lower-cased and without identity.
+func Codings(header string) []string {
+	var codings []string
+	for _, coding := range strings.Split(header, ",") {
+		if coding = strings.ToLower(strings.TrimSpace(coding)); coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
+// Decode returns the payload of body, which has the content codings of the
+// Content-Encoding header, and the codings left on it because there is no
+// decoder for them. A coding whose magic number is missing is taken for a
+// mislabel and skipped. Closing the reader releases the decoders, not body.
+func Decode(body io.Reader, header string) (io.ReadCloser, string, error) {
+	codings := Codings(header)
+	var closers []io.Closer
+	r := bufio.NewReader(body)
+	for i := len(codings) - 1; i >= 0; i-- {
+		decoded, closer, err := decode(r, codings[i])
+		if errors.Is(err, errUnsupported) {
+			return &reader{Reader: r, closers: closers}, strings.Join(codings[:i+1], ", "), nil
+		}
+		if err != nil {
+			closeAll(closers)
+			return nil, "", fmt.Errorf("failed to decode %s content: %w", codings[i], err)
+		}
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		r = bufio.NewReader(decoded)
+	}
+	return &reader{Reader: r, closers: closers}, "", nil
+}
+
+var errUnsupported = errors.New("unsupported content coding")
+
+// decode undoes coding on r, returning r itself when it does not start like
+// a stream of the coding.
+func decode(r *bufio.Reader, coding string) (io.Reader, io.Closer, error) {
+	switch coding {
+	case "gzip", "x-gzip":
+		if !hasMagic(r, gzipMagic) {
+			return r, nil, nil
+		}
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr, nil
+	case "zstd":
+		if !hasMagic(r, zstdMagic) {
+			return r, nil, nil
+		}
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, err
+		}
+		rc := zr.IOReadCloser()
+		return rc, rc, nil
+	case "br":
+		if !looksBrotli(r) {
+			return r, nil, nil
+		}
+		return brotli.NewReader(r), nil, nil
+	case "deflate":
+		// Servers send deflate both as zlib, which it is meant to be, and as
+		// raw deflate
+		header, err := r.Peek(2)
+		if err != nil {
+			return r, nil, nil
+		}
+		if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(r)
+			if err != nil {
+				return nil, nil, err
+			}
+			return zr, zr, nil
+		}
+		fr := flate.NewReader(r)
+		return fr, fr, nil
+	default:
+		return nil, nil, errUnsupported
+	}
+}
+
+// hasMagic reports whether r starts with magic, without consuming it.
+func hasMagic(r *bufio.Reader, magic []byte) bool {
+	head, err := r.Peek(len(magic))
+	return err == nil && bytes.Equal(head, magic)
+}
+
+// brotliProbe is how much of a stream looksBrotli decodes on trial.
+const brotliProbe = 512
+
+// looksBrotli reports whether r starts like a brotli stream, without
+// consuming it. Brotli has no magic number, so a prefix is decoded on trial:
+// a short stream must decode whole, and a longer one must only run out of
+// input.
+func looksBrotli(r *bufio.Reader) bool {
+	head, _ := r.Peek(brotliProbe)
+	if len(head) == 0 {
+		return false
+	}
+	_, err := io.Copy(io.Discard, brotli.NewReader(bytes.NewReader(head)))
+	return err == nil || len(head) == brotliProbe && errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// reader is a decoded payload that releases its decoders on Close.
+type reader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close implements io.Closer.
+func (r *reader) Close() error {
+	return closeAll(r.closers)
+}
+
+func closeAll(closers []io.Closer) error {
+	var errs []error
+	for _, c := range closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
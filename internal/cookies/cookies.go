@@ -0,0 +1,106 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package cookies keeps the cookies of a crawl in a jar and imports the
+// Netscape cookies.txt files that browser extensions, curl, and wget export,
+// so pages behind a login can be archived with the session of a browser.
+package cookies
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	// httpOnlyPrefix marks HttpOnly cookies in cookies.txt, which would
+	// otherwise be comments.
+	httpOnlyPrefix = "#HttpOnly_"
+	// fields is the number of tab-separated fields of a cookies.txt line.
+	fields = 7
+)
+
+// NewJar returns an empty jar that keeps cookies from being set for public
+// suffixes such as co.uk.
+func NewJar() *cookiejar.Jar {
+	// cookiejar.New only fails for options it does not use
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return jar
+}
+
+// LoadFile returns a jar holding the cookies of a cookies.txt file.
+func LoadFile(path string) (*cookiejar.Jar, error) {
+	f, err := os.Open(path) // #nosec G304 - path is a cookies file chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cookies file: %w", err)
+	}
+	defer f.Close()
+	jar := NewJar()
+	if _, err := Load(jar, f, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to read cookies file %s: %w", path, err)
+	}
+	return jar, nil
+}
+
+// Load adds the cookies of a cookies.txt file to jar and returns how many
+// it added. Cookies that expired before now are skipped.
+func Load(jar http.CookieJar, r io.Reader, now time.Time) (int, error) {
+	count := 0
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(text, httpOnlyPrefix)
+		text = strings.TrimPrefix(text, httpOnlyPrefix)
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.Split(text, "\t")
+		if len(parts) != fields {
+			return count, fmt.Errorf("line %d: want %d tab-separated fields, got %d", line, fields, len(parts))
+		}
+		domain, subdomains, path, secure, expiry, name, value := parts[0], parts[1], parts[2], parts[3], parts[4], parts[5], parts[6]
+		host := strings.TrimPrefix(strings.ToLower(domain), ".")
+		if host == "" || name == "" {
+			return count, fmt.Errorf("line %d: missing domain or name", line)
+		}
+
+		cookie := &http.Cookie{Name: name, Value: value, Path: path, Secure: strings.EqualFold(secure, "TRUE"), HttpOnly: httpOnly}
+		if strings.EqualFold(subdomains, "TRUE") {
+			cookie.Domain = host
+		}
+		if seconds, err := strconv.ParseInt(expiry, 10, 64); err != nil {
+			return count, fmt.Errorf("line %d: invalid expiry %q", line, expiry)
+		} else if seconds > 0 {
+			// 0 marks a session cookie, which lasts for the run
+			if cookie.Expires = time.Unix(seconds, 0); cookie.Expires.Before(now) {
+				continue
+			}
+		}
+
+		Set(jar, host, cookie)
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+// Set adds cookie, which a browser keeps for host, to jar. A cookie with a
+// Domain is also sent to the subdomains of host.
+func Set(jar http.CookieJar, host string, cookie *http.Cookie) {
+	scheme := "http"
+	if cookie.Secure {
+		scheme = "https"
+	}
+	jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: "/"}, []*http.Cookie{cookie})
+}
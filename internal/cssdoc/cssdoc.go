@@ -0,0 +1,175 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package cssdoc finds and rewrites the references of CSS stylesheets:
+// url() values such as background images and fonts, and @import rules. It
+// tokenizes just enough CSS to skip comments and strings, so references are
+// found without a full parser and every other byte is kept as it is.
+package cssdoc
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// Ref is a reference inside a stylesheet.
+type Ref struct {
+	// URL is the reference as written in the stylesheet, without quotes.
+	URL string
+	// Start and End are the byte offsets of URL, inside the quotes if any.
+	Start, End int
+	// Quote is the quote character around URL, or 0 for an unquoted url().
+	Quote byte
+}
+
+// IsCSS reports whether a content type denotes a stylesheet.
+func IsCSS(contentType string) bool {
+	return strings.Contains(strings.ToLower(contentType), "text/css")
+}
+
+// Refs returns the references of a stylesheet in document order.
+func Refs(data []byte) []Ref {
+	var refs []Ref
+	importRule := false
+	for i := 0; i < len(data); {
+		switch c := data[i]; {
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return refs
+			}
+			i += end + 4
+			continue
+		case c == '"' || c == '\'':
+			end := stringEnd(data, i)
+			if importRule {
+				refs = append(refs, Ref{URL: string(data[i+1 : end]), Start: i + 1, End: end, Quote: c})
+			}
+			importRule = false
+			i = end + 1
+			continue
+		case c == '@':
+			ident := identEnd(data, i+1)
+			importRule = strings.EqualFold(string(data[i+1:ident]), "import")
+			i = ident
+			continue
+		case isIdentByte(c):
+			ident := identEnd(data, i)
+			if ident < len(data) && data[ident] == '(' && strings.EqualFold(string(data[i:ident]), "url") {
+				ref, end := urlToken(data, ident+1)
+				if ref.URL != "" {
+					refs = append(refs, ref)
+				}
+				importRule = false
+				i = end
+				continue
+			}
+			i = ident
+			continue
+		case c == '\\':
+			// An escaped character never starts a token
+			i += 2
+			continue
+		case c == ';' || c == '{' || c == '}':
+			importRule = false
+		}
+		i++
+	}
+	return refs
+}
+
+// stringEnd returns the offset of the quote closing the string starting at
+// start, or the end of data for an unterminated string.
+func stringEnd(data []byte, start int) int {
+	quote := data[start]
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case quote, '\n':
+			return i
+		}
+	}
+	return len(data)
+}
+
+// urlToken reads the value of a url() whose parenthesis ends before start and
+// returns it with the offset just past the closing parenthesis.
+func urlToken(data []byte, start int) (Ref, int) {
+	i := start
+	for i < len(data) && isSpace(data[i]) {
+		i++
+	}
+	if i < len(data) && (data[i] == '"' || data[i] == '\'') {
+		end := stringEnd(data, i)
+		ref := Ref{URL: string(data[i+1 : end]), Start: i + 1, End: end, Quote: data[i]}
+		for i = end + 1; i < len(data) && data[i] != ')'; i++ {
+		}
+		return ref, i + 1
+	}
+	valueStart := i
+	for i < len(data) && data[i] != ')' {
+		if data[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	if i > len(data) {
+		i = len(data)
+	}
+	valueEnd := i
+	for valueEnd > valueStart && isSpace(data[valueEnd-1]) {
+		valueEnd--
+	}
+	return Ref{URL: string(data[valueStart:valueEnd]), Start: valueStart, End: valueEnd}, i + 1
+}
+
+// identEnd returns the offset just past the identifier starting at start.
+func identEnd(data []byte, start int) int {
+	i := start
+	for i < len(data) && isIdentByte(data[i]) {
+		i++
+	}
+	return i
+}
+
+// isIdentByte reports whether c can be part of an identifier. Bytes of
+// non-ASCII characters are identifier bytes in CSS.
+func isIdentByte(c byte) bool {
+	return c == '-' || c == '_' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// Rewrite replaces the references in data found in mapping, original value to
+// new value, leaving every other byte of the stylesheet untouched.
+func Rewrite(data []byte, mapping map[string]string) []byte {
+	refs := Refs(data)
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Start > refs[j].Start })
+
+	out := append([]byte(nil), data...)
+	for _, ref := range refs {
+		local, ok := mapping[ref.URL]
+		if !ok {
+			continue
+		}
+		out = append(out[:ref.Start], append([]byte(quote(local, ref.Quote)), out[ref.End:]...)...)
+	}
+	return out
+}
+
+// quote escapes a new value for the quoting of the value it replaces. An
+// unquoted value that needs escaping is quoted instead.
+func quote(value string, q byte) string {
+	if q == 0 {
+		if !strings.ContainsAny(value, " \t\n()'\"\\") {
+			return value
+		}
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\a `).Replace(value) + `"`
+	}
+	return strings.NewReplacer(`\`, `\\`, string(q), `\`+string(q), "\n", `\a `).Replace(value)
+}
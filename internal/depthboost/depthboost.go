@@ -0,0 +1,155 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package depthboost changes the crawl depth of parts of a site, so a
+// documentation tree can be followed deeper than the rest of a giant site,
+// or a blog kept shallower.
+package depthboost
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Separator divides the pattern and the depth change of a rule.
+const Separator = "="
+
+// Rule changes the depth of the pages matching Pattern by Boost. Patterns
+// are globs over the URL path when they start with /, and over the host and
+// path otherwise; * matches within a path segment and ** across them, so
+// /docs/** matches /docs and everything below it.
+type Rule struct {
+	Pattern string
+	Boost   int
+	re      *regexp.Regexp
+}
+
+// Parse parses a rule written as "pattern=+N" or "pattern=-N".
+func Parse(spec string) (Rule, error) {
+	i := strings.LastIndex(spec, Separator)
+	if i < 0 {
+		return Rule{}, fmt.Errorf("depth boost %q is not of the form pattern%s+N", spec, Separator)
+	}
+	pattern := strings.TrimSpace(spec[:i])
+	boost, err := strconv.Atoi(strings.TrimSpace(spec[i+1:]))
+	if err != nil || pattern == "" {
+		return Rule{}, fmt.Errorf("depth boost %q is not of the form pattern%s+N", spec, Separator)
+	}
+	return Rule{Pattern: pattern, Boost: boost, re: compile(pattern)}, nil
+}
+
+// compile translates a glob into an anchored regular expression.
+func compile(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("(?:/.*)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Match reports whether the rule applies to u.
+func (r Rule) Match(u *url.URL) bool {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if !strings.HasPrefix(r.Pattern, "/") {
+		p = strings.ToLower(u.Host) + p
+	}
+	return r.re.MatchString(p)
+}
+
+// Rules are checked in order; the first one matching a URL applies.
+type Rules []Rule
+
+// Load parses rule specs and the rules in file, one per line, where blank
+// lines and lines starting with # are ignored. file may be empty.
+func Load(specs []string, file string) (Rules, error) {
+	var rules Rules
+	for _, spec := range specs {
+		rule, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if file == "" {
+		return rules, nil
+	}
+
+	f, err := os.Open(file) // #nosec G304 - the rules file is chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to open depth boosts: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read depth boosts: %w", err)
+	}
+	return rules, nil
+}
+
+// rule returns the index of the rule matching u, or -1.
+func (r Rules) rule(u *url.URL) int {
+	for i, rule := range r {
+		if rule.Match(u) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Depth returns the depth left to a page at u linked from the page at from,
+// which is nil for seeds, when depth is left without boosts. Boosts apply
+// where a link enters the part of the site of a rule and are taken back
+// where one leaves it, so they do not add up along the pages inside.
+func (r Rules) Depth(from, u *url.URL, depth int) int {
+	if len(r) == 0 {
+		return depth
+	}
+	to, at := r.rule(u), -1
+	if from != nil {
+		at = r.rule(from)
+	}
+	if to == at {
+		return depth
+	}
+	if at >= 0 {
+		depth -= r[at].Boost
+	}
+	if to >= 0 {
+		depth += r[to].Boost
+	}
+	return depth
+}
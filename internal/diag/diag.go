@@ -0,0 +1,63 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package diag serves runtime diagnostics, net/http/pprof profiles and
+// expvar variables, so a slow or leaking long-running crawl can be profiled
+// without rebuilding the binary.
+package diag
+
+import (
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// Prefix is the path the diagnostics are served under.
+const Prefix = "/debug/"
+
+var started = time.Now()
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	expvar.Publish("uptime", expvar.Func(func() any { return time.Since(started).Round(time.Second).String() }))
+}
+
+// Handler serves the profiles at /debug/pprof/ and the variables, including
+// memory statistics and crawl counters, at /debug/vars.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(Prefix+"pprof/", pprof.Index)
+	mux.HandleFunc(Prefix+"pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc(Prefix+"pprof/profile", pprof.Profile)
+	mux.HandleFunc(Prefix+"pprof/symbol", pprof.Symbol)
+	mux.HandleFunc(Prefix+"pprof/trace", pprof.Trace)
+	mux.Handle(Prefix+"vars", expvar.Handler())
+	return mux
+}
+
+// Listen serves Handler on addr in the background until the program exits.
+// Profiles can take a while, so responses have no write timeout.
+func Listen(addr string, readHeaderTimeout time.Duration) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for diagnostics on %s: %w", addr, err)
+	}
+	if host, _, err := net.SplitHostPort(ln.Addr().String()); err == nil {
+		if ip := net.ParseIP(host); ip != nil && !ip.IsLoopback() {
+			slog.Warn("Diagnostics are reachable from the network; they expose the command line and memory contents", "addr", ln.Addr().String())
+		}
+	}
+	server := &http.Server{Handler: Handler(), ReadHeaderTimeout: readHeaderTimeout}
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			slog.Warn("Diagnostics server stopped", "error", err)
+		}
+	}()
+	slog.Info("Serving diagnostics", "pprof", "http://"+ln.Addr().String()+Prefix+"pprof/", "vars", "http://"+ln.Addr().String()+Prefix+"vars")
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// isAlias reports whether host is one of the host aliases of the site.
+func (c *crawler) isAlias(host string) bool {
+	host = strings.ToLower(host)
+	for _, alias := range c.cfg.HostAliases {
+		if host == strings.ToLower(strings.TrimSpace(alias)) {
+			return true
+		}
+	}
+	return false
+}
+
+// doAliased sends req and, when the seed's host does not respond or fails
+// with a server error, sends it to each host alias in turn until one
+// answers. URLs on the aliases are spelled with the seed's host, so they
+// are only fetched this way.
+func (c *crawler) doAliased(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if req.URL.Hostname() != c.baseDomain {
+		return resp, err
+	}
+	for _, alias := range c.cfg.HostAliases {
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+		if alias = strings.ToLower(strings.TrimSpace(alias)); alias == "" || alias == c.baseDomain {
+			continue
+		}
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		alt := req.Clone(req.Context())
+		alt.URL.Host = alias
+		if port := req.URL.Port(); port != "" {
+			alt.URL.Host += ":" + port
+		}
+		alt.Host = ""
+		slog.Debug("Trying host alias", "url", req.URL.String(), "alias", alias, "error", err)
+		resp, err = c.client.Do(alt)
+	}
+	return resp, err
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/catalog"
+	"golang.org/x/net/html"
+)
+
+// bannerID marks the provenance banner, so a page archived again gets one banner.
+const bannerID = "website-archiver-banner"
+
+// bannerTemplate is the provenance banner. It is dismissed with a checkbox
+// instead of a script, so it also works with scripts disabled and in ZIM readers.
+var bannerTemplate = template.Must(template.New("banner").Parse(`<div id="` + bannerID + `"><style>
+#` + bannerID + ` input { display: none; }
+#` + bannerID + ` input:checked + div { display: none; }
+#` + bannerID + ` > div { position: relative; z-index: 2147483647; margin: 0; padding: 8px 36px 8px 12px; background: #fff8d6; color: #222; border-bottom: 1px solid #d9c66f; font: 14px/1.4 sans-serif; text-align: left; }
+#` + bannerID + ` a { color: #1a4fa0; text-decoration: underline; }
+#` + bannerID + ` label { position: absolute; top: 6px; right: 12px; cursor: pointer; font-size: 18px; line-height: 1; }
+</style><input type="checkbox" id="` + bannerID + `-close"><div role="note">Archived copy of <a href="{{.URL}}">{{.URL}}</a> captured on {{.Date}} by website-archiver; the original may have changed.<label for="` + bannerID + `-close" title="Dismiss" aria-label="Dismiss">&times;</label></div></div>`))
+
+// addBanner puts the provenance banner at the top of the body of a page.
+func addBanner(doc *html.Node, page *url.URL, fetchedAt time.Time) error {
+	body := findElement(doc, "body")
+	if body == nil {
+		return nil
+	}
+	if old := findByID(body, bannerID); old != nil {
+		old.Parent.RemoveChild(old)
+	}
+
+	original := originalURL(page)
+	var buf strings.Builder
+	err := bannerTemplate.Execute(&buf, struct{ URL, Date string }{original, fetchedAt.UTC().Format("2006-01-02 15:04 MST")})
+	if err != nil {
+		return fmt.Errorf("failed to render banner: %w", err)
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(buf.String()), body)
+	if err != nil {
+		return fmt.Errorf("failed to parse banner: %w", err)
+	}
+	for i := len(nodes) - 1; i >= 0; i-- {
+		body.InsertBefore(nodes[i], body.FirstChild)
+	}
+	return nil
+}
+
+// findByID returns the element below n with the given id.
+func findByID(n *html.Node, id string) *html.Node {
+	if n.Type == html.ElementNode && getAttr(n, "id") == id {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findByID(child, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// originalURL returns the URL of the live page a captured page stands for,
+// unwrapping Wayback Machine snapshot URLs.
+func originalURL(page *url.URL) string {
+	if u, _, ok := catalog.OriginalURL(page.String()); ok {
+		return u
+	}
+	return page.String()
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/challenge"
+)
+
+// passChallenge returns resp unless it is an anti-bot challenge. Then the
+// user is asked to pass it and req is sent again with the cookies that
+// clear it; a challenge is never saved as the resource.
+func (c *crawler) passChallenge(ctx context.Context, req *http.Request, resp *http.Response) (*http.Response, error) {
+	for {
+		vendor := sniffChallenge(resp)
+		if vendor == "" {
+			return resp, nil
+		}
+		seen := time.Now()
+		_ = resp.Body.Close()
+		slog.Warn("Anti-bot challenge", "vendor", vendor, "status", resp.StatusCode, "url", req.URL.String(), "strategy", c.challenges.Mode)
+		if err := c.challenges.Solve(ctx, req.URL, vendor, seen); err != nil {
+			return nil, err
+		}
+		next, err := c.doAliased(req.Clone(ctx))
+		if err != nil {
+			return nil, err
+		}
+		resp = next
+	}
+}
+
+// sniffChallenge returns the vendor of the challenge resp is, or "". Error
+// responses and pages have the start of their body looked at, which stays
+// unread for the caller.
+func sniffChallenge(resp *http.Response) string {
+	if vendor := challenge.Detect(resp.StatusCode, resp.Header, nil); vendor != "" {
+		return vendor
+	}
+	if resp.StatusCode == http.StatusOK && !isHTMLType(resp.Header.Get("Content-Type")) {
+		return ""
+	}
+	br := bufio.NewReaderSize(resp.Body, challenge.SniffSize)
+	head, _ := br.Peek(challenge.SniffSize)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, resp.Body}
+	return challenge.Detect(resp.StatusCode, resp.Header, head)
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/frontier"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+)
+
+// ContinuationFile is the continuation token written into the metadata
+// directory of a capture whose crawl stopped at the Wayback Machine quota of
+// the run.
+const ContinuationFile = manifest.Dir + "/wayback-continuation.json"
+
+// Continuation records where a crawl stopped at the Wayback Machine quota,
+// so a later run can pick it up in the same capture directory.
+type Continuation struct {
+	Seed      string    `json:"seed"`
+	Depth     int       `json:"depth"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Pending are the URLs that were not fetched because of the quota.
+	Pending []frontier.Item `json:"pending"`
+}
+
+// LoadContinuation reads the continuation token of the capture in dir from
+// the storage cfg writes it to.
+func LoadContinuation(dir string, cfg *config.Config) (*Continuation, error) {
+	s, err := OpenStorage(dir, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return loadContinuation(s)
+}
+
+// loadContinuation reads the continuation token of the capture stored in s.
+func loadContinuation(s storage.Storage) (*Continuation, error) {
+	data, err := storage.ReadFile(s, ContinuationFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read continuation token: %w", err)
+	}
+	cont := &Continuation{}
+	if err := json.Unmarshal(data, cont); err != nil {
+		return nil, fmt.Errorf("failed to parse continuation token: %w", err)
+	}
+	return cont, nil
+}
+
+// deferItem keeps an item that failed because of the quota for the next run.
+func (c *crawler) deferItem(item frontier.Item) {
+	c.mu.Lock()
+	c.deferred = append(c.deferred, item)
+	c.mu.Unlock()
+}
+
+// resume prepares a crawl continuing the capture in outputDir: the saved
+// resources are kept in the manifest and not fetched again, and the URLs the
+// quota stopped last time are queued.
+func (c *crawler) resume(cont *Continuation) error {
+	prev, err := manifest.Read(c.storage)
+	if err != nil {
+		return err
+	}
+	c.manifest = prev
+	c.annotate()
+	for _, r := range prev.Resources {
+		if u, err := url.Parse(r.URL); err == nil {
+			c.markVisited(u, cont.Depth)
+		}
+	}
+	slog.Info("Continuing crawl stopped at the Wayback Machine quota", "url", cont.Seed, "saved", len(prev.Resources), "pending", len(cont.Pending))
+	for _, item := range cont.Pending {
+		if u, err := url.Parse(item.URL); err == nil {
+			c.push(u, item.Depth, item.Requisite, item.Referrer)
+		}
+	}
+	return nil
+}
+
+// writeContinuation stores the continuation token of the capture, or removes
+// the consumed one once a continued crawl got through without hitting the
+// quota.
+func (c *crawler) writeContinuation(seed string, depth int, continued bool) error {
+	if len(c.deferred) == 0 {
+		if !continued {
+			return nil
+		}
+		if err := c.storage.Remove(ContinuationFile); err != nil {
+			return fmt.Errorf("failed to remove continuation token: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(Continuation{Seed: seed, Depth: depth, CreatedAt: time.Now().UTC(), Pending: c.deferred}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode continuation token: %w", err)
+	}
+	if err := storage.WriteFile(c.storage, ContinuationFile, data); err != nil {
+		return fmt.Errorf("failed to write continuation token: %w", err)
+	}
+	slog.Warn("Wayback Machine quota of this run used up, the crawl stopped early", "url", seed, "pending", len(c.deferred), "continue", "--wayback-continue "+c.outputDir)
+	return nil
+}
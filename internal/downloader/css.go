@@ -0,0 +1,52 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/cssdoc"
+)
+
+// followCSS fetches the images, fonts, and imported stylesheets a stylesheet
+// references and returns it with those references pointing at the local
+// copies, relative to docPath, the file the stylesheet ends up in. base is the
+// URL references are resolved against, which for a stylesheet embedded in a
+// page is the URL of the stylesheet rather than the page. In fidelity mode the
+// stylesheet is returned unchanged and the rewrites are recorded instead.
+func (c *crawler) followCSS(ctx context.Context, base *url.URL, docPath string, data []byte, depth int) []byte {
+	mapping := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, ref := range cssdoc.Refs(data) {
+		if seen[ref.URL] || strings.HasPrefix(ref.URL, "#") {
+			continue
+		}
+		seen[ref.URL] = true
+		assetURL := resolveURL(base, ref.URL)
+		if assetURL == nil || (assetURL.Scheme != "http" && assetURL.Scheme != "https") {
+			continue
+		}
+		c.spawn(base, assetURL, depth, true)
+
+		if !c.savedLocally(assetURL) {
+			continue
+		}
+		local := linkTo(docPath, c.localPath(assetURL, false))
+		if assetURL.Fragment != "" {
+			// SVG sprites and fonts are addressed by fragment
+			local += "#" + assetURL.Fragment
+		}
+		if c.rewrites != nil {
+			c.rewrites.Add(docPath, ref.URL, local)
+		} else {
+			mapping[ref.URL] = local
+		}
+	}
+	if len(mapping) == 0 {
+		return data
+	}
+	return cssdoc.Rewrite(data, mapping)
+}
@@ -2,180 +2,1195 @@ package downloader
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/a11y"
+	"github.com/Sudo-Ivan/website-archiver/internal/challenge"
+	"github.com/Sudo-Ivan/website-archiver/internal/contentcoding"
+	"github.com/Sudo-Ivan/website-archiver/internal/cookies"
+	"github.com/Sudo-Ivan/website-archiver/internal/cssdoc"
+	"github.com/Sudo-Ivan/website-archiver/internal/depthboost"
+	"github.com/Sudo-Ivan/website-archiver/internal/errorpages"
+	"github.com/Sudo-Ivan/website-archiver/internal/fetcherr"
+	"github.com/Sudo-Ivan/website-archiver/internal/filenames"
+	"github.com/Sudo-Ivan/website-archiver/internal/frontier"
+	"github.com/Sudo-Ivan/website-archiver/internal/gallery"
+	"github.com/Sudo-Ivan/website-archiver/internal/ipfs"
+	"github.com/Sudo-Ivan/website-archiver/internal/legacy"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/mimefilter"
+	"github.com/Sudo-Ivan/website-archiver/internal/onion"
+	"github.com/Sudo-Ivan/website-archiver/internal/preset"
+	"github.com/Sudo-Ivan/website-archiver/internal/progress"
+	"github.com/Sudo-Ivan/website-archiver/internal/quality"
+	"github.com/Sudo-Ivan/website-archiver/internal/referrer"
+	"github.com/Sudo-Ivan/website-archiver/internal/remap"
+	"github.com/Sudo-Ivan/website-archiver/internal/replay"
+	"github.com/Sudo-Ivan/website-archiver/internal/replaytest"
+	"github.com/Sudo-Ivan/website-archiver/internal/retry"
+	"github.com/Sudo-Ivan/website-archiver/internal/searchpage"
+	"github.com/Sudo-Ivan/website-archiver/internal/sitemap"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+	"github.com/Sudo-Ivan/website-archiver/internal/urlfilter"
+	"github.com/Sudo-Ivan/website-archiver/internal/urlnorm"
+	"github.com/Sudo-Ivan/website-archiver/internal/visited"
+	"github.com/Sudo-Ivan/website-archiver/internal/warc"
+	"github.com/Sudo-Ivan/website-archiver/internal/wayback"
+	"github.com/Sudo-Ivan/website-archiver/internal/wordpress"
+	"github.com/Sudo-Ivan/website-archiver/internal/xmldoc"
 	"golang.org/x/net/html"
 )
 
+// a11yReportFile is the accessibility report in the metadata directory of a capture.
+const a11yReportFile = manifest.Dir + "/accessibility-report.json"
+
+// crawler holds the state shared by every fetch of a single Download call.
+type crawler struct {
+	cfg        *config.Config
+	client     *http.Client
+	baseDomain string
+	outputDir  string
+	noJs       bool
+	noCss      bool
+	manifest   *manifest.Manifest
+	scope      *preset.Scope
+
+	wg sync.WaitGroup
+
+	mu      sync.Mutex
+	visited visited.Set
+	wpRoot  string
+	missing []*url.URL
+
+	// listingFiles are files found in directory listings, whose downloads
+	// count against the listing size caps
+	listingFiles map[string]bool
+	listingBytes int64
+
+	// offsite are FTP and Gopher files on other hosts linked from pages
+	offsite map[string]bool
+	// spanned are requisites on other hosts fetched with --span-hosts-assets
+	spanned map[string]bool
+
+	a11y *a11y.Report
+
+	// sitemap records the navigation links between pages when a site map
+	// is generated
+	sitemap *sitemap.Graph
+
+	// rewrites is set in fidelity mode, where pages are kept as fetched and
+	// link rewrites are only recorded
+	rewrites *replay.Rewrites
+
+	// pages feeds fetched HTML pages to the parse stage
+	pages chan *page
+	// inlines feeds the stylesheets and scripts pages embed to the fetch stage
+	inlines chan inlineFetch
+
+	// frontier queues the URLs the fetch workers still have to fetch
+	frontier frontier.Queue
+
+	// failures counts linked resources that could not be fetched, keeping
+	// the first few errors for the summary
+	failures   int
+	failureLog []string
+
+	// deferred are the items not fetched because the Wayback Machine quota
+	// of the run was used up
+	deferred []frontier.Item
+
+	// remap rewrites URLs before they are checked against the site and
+	// turned into local paths
+	remap remap.Rules
+
+	// boosts change the depth of the pages in parts of the site
+	boosts depthboost.Rules
+
+	// restrict escapes characters of local file names the target file
+	// system cannot store
+	restrict filenames.Restriction
+	// cases gives paths differing only by case distinct names
+	cases filenames.Cases
+
+	// mime decides from their media type which responses are saved
+	mime mimefilter.Filter
+	// patterns decide from their URL which discovered URLs are crawled
+	patterns urlfilter.Filter
+	// normalize spells discovered URLs one way, so each is fetched once
+	normalize urlnorm.Normalizer
+	// errorPages decides what is done with error responses, which errorLog
+	// records when they are saved or reported
+	errorPages errorpages.Policy
+	errorLog   *errorpages.Log
+	// referrer sets the Referer, Origin, and Sec-Fetch-* headers of requests
+	referrer referrer.Policy
+	// challenges has the user pass anti-bot challenges, or reports them
+	challenges *challenge.Solver
+
+	// redirects maps the in-site URLs that redirected to where they led
+	redirectsMu sync.Mutex
+	redirects   map[string]*url.URL
+
+	// robots caches the robots.txt of each host with --respect-robots
+	robots *robotsCache
+
+	// storage receives the files of the capture
+	storage storage.Storage
+
+	// tracker follows the crawl for its state file
+	tracker *crawlTracker
+
+	// quality counts what the archive quality score is computed from
+	quality quality.Counter
+
+	// previous are the resources of the capture being updated that are
+	// revalidated, by URL, and unchanged counts those the server kept
+	previous       map[string]manifest.Resource
+	unchanged      int
+	unchangedBytes int64
+}
+
 // Download fetches a URL and its dependencies, saving them to the specified output directory.
 func Download(ctx context.Context, rawURL string, depth int, outputDir string, noJs bool, noCss bool, cfg *config.Config) error {
+	return DownloadURLs(ctx, rawURL, nil, depth, outputDir, noJs, noCss, cfg)
+}
+
+// DownloadURLs is like Download but also fetches the extra URLs of the same
+// site, each with depth 0, into the same capture.
+func DownloadURLs(ctx context.Context, rawURL string, extra []string, depth int, outputDir string, noJs bool, noCss bool, cfg *config.Config) error {
+	crawlVars.Add("active", 1)
+	defer crawlVars.Add("active", -1)
+	store, err := OpenStorage(outputDir, cfg)
+	if err != nil {
+		return err
+	}
+	var resumed *CrawlState
+	if cfg.Resume != "" {
+		if resumed, err = loadState(store); err != nil {
+			return err
+		}
+		rawURL, extra, depth = resumed.Seed, resumed.Extra, resumed.Depth
+	}
+
+	var cont *Continuation
+	if cfg.WaybackContinue != "" {
+		if cont, err = loadContinuation(store); err != nil {
+			return err
+		}
+		if cont.Seed != rawURL {
+			slog.Info("Continuing the seed of the continuation token", "url", cont.Seed, "requested", rawURL)
+		}
+		rawURL, depth = cont.Seed, cont.Depth
+	}
+
 	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
 		return fmt.Errorf("invalid URL: %w", err)
 	}
 
-	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+	var ipfsSource *manifest.IPFS
+	var ipfsRoot string
+	if ipfs.Supports(parsedURL.Scheme) {
+		gatewayURL, root, err := ipfs.GatewayURL(parsedURL, cfg.IPFSGateway)
+		if err != nil {
+			return err
+		}
+		ipfsSource = &manifest.IPFS{URL: rawURL, Gateway: cfg.IPFSGateway}
+		if parsedURL.Scheme == ipfs.SchemeIPFS {
+			ipfsSource.CID = parsedURL.Host
+		}
+		slog.Info("Fetching IPFS content through gateway", "url", rawURL, "gatewayURL", gatewayURL.String())
+		parsedURL, ipfsRoot = gatewayURL, root
+	}
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" && !(cfg.LegacyProtocols && legacy.Supports(parsedURL.Scheme)) {
 		return fmt.Errorf("URL must use http or https scheme")
 	}
+	normalize, err := urlnorm.New(cfg.DropParams, cfg.StripTracking, cfg.StripTrailingSlash)
+	if err != nil {
+		return err
+	}
+	parsedURL = normalize.URL(parsedURL)
+	normalize = normalize.WithAliases(parsedURL.Host, cfg.HostAliases)
+
+	if _, local := store.(*storage.Local); local {
+		// Create output directory if it doesn't exist
+		if err := os.MkdirAll(outputDir, cfg.DirPerms); err != nil {
+			return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+		}
+	}
+
+	client := cfg.HTTPClient()
+	if cfg.WARC {
+		recorder, err := openWARC(outputDir, cfg)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				slog.Warn("Failed to close WARC file", "error", err)
+			}
+		}()
+		// Below the User-Agent and retry layers, so the headers sent and
+		// every attempt are recorded
+		client = cfg.HTTPClientVia(&warc.Transport{Next: cfg.Transport, WARC: recorder})
+	}
+
+	c := &crawler{
+		cfg:        cfg,
+		client:     client,
+		baseDomain: parsedURL.Hostname(),
+		outputDir:  outputDir,
+		noJs:       noJs,
+		noCss:      noCss,
+		manifest:   manifest.New(rawURL),
+		storage:    store,
+		tracker:    newCrawlTracker(),
+		normalize:  normalize,
+
+		listingFiles: make(map[string]bool),
+		offsite:      make(map[string]bool),
+		spanned:      make(map[string]bool),
+		redirects:    make(map[string]*url.URL),
+	}
+	c.annotate()
+	if cfg.Update != "" {
+		if err := c.loadPrevious(); err != nil {
+			return err
+		}
+	}
+	if c.client.Jar == nil {
+		// Keep the session cookies the site hands out for the whole crawl
+		c.client.Jar = cookies.NewJar()
+	}
+	if c.remap, err = remap.Load(cfg.RemapRules, cfg.RemapFile); err != nil {
+		return err
+	}
+	if c.boosts, err = depthboost.Load(cfg.DepthBoosts, cfg.DepthBoostFile); err != nil {
+		return err
+	}
+	if c.restrict, err = filenames.Parse(cfg.RestrictFileNames); err != nil {
+		return err
+	}
+	if c.mime, err = mimefilter.New(cfg.AcceptMIME, cfg.RejectMIME); err != nil {
+		return err
+	}
+	if c.patterns, err = urlfilter.New(cfg.IncludePatterns, cfg.ExcludePatterns); err != nil {
+		return err
+	}
+	if c.errorPages, err = errorpages.New(cfg.ErrorPages, cfg.ErrorStatuses); err != nil {
+		return err
+	}
+	if c.referrer, err = referrer.New(cfg.ReferrerPolicy, cfg.Referer); err != nil {
+		return err
+	}
+	if err := challenge.CheckMode(cfg.Challenge); err != nil {
+		return err
+	}
+	c.challenges = &challenge.Solver{Mode: cfg.Challenge, CookiesFile: cfg.CookiesFile, UserAgent: cfg.UserAgent, Jar: c.client.Jar, In: os.Stdin, Out: os.Stderr}
+	if cfg.Challenge != challenge.Skip && cfg.UserAgents != nil {
+		slog.Warn("Clearance cookies of challenges are bound to one User-Agent; rotating them will bring the challenges back")
+	}
+	if c.errorPages.Reports() {
+		c.errorLog = &errorpages.Log{}
+	}
+	// The disk set is kept for --resume until the crawl got through, and
+	// never becomes part of the capture
+	visitedDir := filepath.Join(outputDir, manifest.Dir, visited.DirName)
+	if cfg.VisitedBloom {
+		set, err := visited.OpenDisk(visitedDir, cfg.VisitedExpected, cfg.VisitedFPRate, cfg.DirPerms, cfg.FilePerms)
+		if err != nil {
+			return err
+		}
+		c.visited = set
+	} else {
+		c.visited = visited.NewMemory()
+	}
+	defer func() {
+		if err := c.visited.Close(); err != nil {
+			slog.Warn("Failed to close visited set", "error", err)
+		}
+		if cfg.VisitedBloom && ctx.Err() == nil {
+			if err := os.RemoveAll(visitedDir); err != nil {
+				slog.Warn("Failed to remove visited set", "error", err)
+			}
+		}
+	}()
+	if ipfsSource != nil {
+		c.manifest.SetIPFS(ipfsSource)
+		// Keep page recursion within the content root on the gateway
+		c.scope = &preset.Scope{Prefix: ipfsRoot}
+	}
+	if cfg.A11yReport {
+		c.a11y = &a11y.Report{}
+	}
+	if cfg.Sitemap {
+		c.sitemap = sitemap.NewGraph()
+	}
+	if cfg.Fidelity {
+		c.rewrites = replay.NewRewrites()
+	}
+	if cfg.RespectRobots {
+		c.robots = newRobotsCache()
+	}
+
+	stopParsers := c.startParsers(ctx)
+	defer stopParsers()
+	stopInlines := c.startInlineFetchers(ctx)
+	defer stopInlines()
+
+	stopFetchers, err := c.startFetchers(ctx, parsedURL)
+	if err != nil {
+		return err
+	}
+	defer stopFetchers()
 
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(outputDir, cfg.DirPerms); err != nil {
-		return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+	if cfg.Preset == preset.Docs {
+		parsedURL = c.applyDocsPreset(ctx, parsedURL, depth)
 	}
 
-	return downloadRecursive(ctx, parsedURL, parsedURL.Hostname(), depth, outputDir, noJs, noCss, cfg)
+	stopState := c.startStateSaver(rawURL, extra, depth)
+	if resumed != nil {
+		if err := c.restoreState(resumed); err != nil {
+			stopState()
+			return err
+		}
+	}
+	if cont != nil {
+		if err := c.resume(cont); err != nil {
+			stopState()
+			return err
+		}
+	}
+	if cfg.SitemapSeeds {
+		c.queueSitemap(ctx, parsedURL, depth)
+	}
+	c.queue(ctx, extra, 0)
+	err = c.downloadRecursive(ctx, parsedURL, c.boosts.Depth(nil, parsedURL, depth))
+	c.wg.Wait()
+	stopState()
+	if err := c.finishState(ctx, rawURL, extra, depth); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if c.failures > 0 {
+		slog.Warn("Some linked resources could not be downloaded", "failed", c.failures, "errors", c.failureLog)
+	}
+	if c.previous != nil {
+		slog.Info("Kept unchanged resources", "count", c.unchanged, "bytes", c.unchangedBytes)
+	}
+	if err := c.writeContinuation(rawURL, depth, cont != nil); err != nil {
+		return err
+	}
+
+	if cfg.WordPress {
+		c.captureWordPress(ctx, parsedURL)
+	}
+
+	if cfg.WaybackPatch {
+		c.patchFromWayback(ctx)
+	}
+
+	if err := c.relinkRedirects(); err != nil {
+		return err
+	}
+
+	if c.a11y != nil {
+		if err := c.a11y.Write(c.storage, a11yReportFile); err != nil {
+			return err
+		}
+	}
+
+	if c.errorLog != nil {
+		if err := c.errorLog.Write(c.storage, errorpages.ReportFile); err != nil {
+			return err
+		}
+		if n := c.errorLog.Len(); n > 0 {
+			slog.Info("Recorded error responses", "count", n, "report", errorpages.ReportFile)
+		}
+	}
+
+	if c.sitemap != nil {
+		if err := c.sitemap.Write(c.storage, filepath.ToSlash(c.localPath(parsedURL, true))); err != nil {
+			return err
+		}
+	}
+
+	if c.rewrites != nil {
+		if err := c.rewrites.Write(c.storage); err != nil {
+			return err
+		}
+	}
+
+	c.scoreQuality()
+	if err := c.manifest.Save(c.storage); err != nil {
+		return err
+	}
+	if cfg.SearchPage {
+		if err := searchpage.Write(c.storage, c.manifest); err != nil {
+			return err
+		}
+	}
+	if cfg.Screenshots {
+		opts := gallery.Options{Limit: cfg.ScreenshotLimit, DirPerms: cfg.DirPerms, FilePerms: cfg.FilePerms}
+		if err := gallery.Write(ctx, outputDir, c.manifest, opts); err != nil {
+			slog.Warn("Failed to create screenshot gallery", "error", err)
+		}
+	}
+	if cfg.OfflineReplayTest {
+		c.testOfflineReplay(ctx, outputDir)
+	}
+	return nil
 }
 
-func downloadRecursive(ctx context.Context, currentURL *url.URL, baseDomain string, depth int, outputDir string, noJs bool, noCss bool, cfg *config.Config) error {
-	if depth < 0 {
+// testOfflineReplay loads the pages of the finished capture without network
+// access and reports the resources that fail to load.
+func (c *crawler) testOfflineReplay(ctx context.Context, outputDir string) {
+	rewrites := c.rewrites
+	if rewrites == nil {
+		rewrites = replay.NewRewrites()
+	}
+	report, err := replaytest.Run(ctx, outputDir, c.manifest, rewrites)
+	if err != nil {
+		slog.Warn("Failed to run offline replay test", "error", err)
+		return
+	}
+	for _, f := range report.Failures {
+		slog.Warn("Resource fails to load offline", "page", f.Page, "resource", f.Resource, "reason", f.Reason)
+	}
+	if err := report.Write(c.storage, replaytest.ReportFile); err != nil {
+		slog.Warn("Failed to save offline replay report", "error", err)
+		return
+	}
+	slog.Info("Offline replay test finished", "pages", report.Pages, "failures", len(report.Failures), "report", replaytest.ReportFile)
+}
+
+// scoreQuality computes the archive quality score of the capture, logs it
+// in the crawl summary, and records it in the manifest. Requisites patched
+// from the Wayback Machine are no longer missing.
+func (c *crawler) scoreQuality() {
+	counts := c.quality.Counts()
+	for _, r := range c.manifest.Resources {
+		counts.SavedBytes += r.Size
+		if r.Patched {
+			counts.MissingRequisites = max(counts.MissingRequisites-1, 0)
+		}
+	}
+	report := quality.Compute(counts)
+	c.manifest.SetQuality(&report)
+	slog.Info("Archive quality",
+		"score", report.Score,
+		"requisitesCaptured", fmt.Sprintf("%d/%d", counts.Requisites-counts.MissingRequisites, counts.Requisites),
+		"brokenLinks", counts.BrokenLinks,
+		"robotsBlocked", counts.RobotsBlocked,
+		"strippedResources", counts.StrippedResources,
+		"strippedBytes", counts.StrippedBytes,
+	)
+}
+
+// WARCFile returns the WARC file the exchanges of the capture in outputDir
+// are recorded into with --warc.
+func WARCFile(outputDir string) string {
+	return filepath.Clean(outputDir) + ".warc.gz"
+}
+
+// openWARC opens the WARC file of the capture in outputDir. Resumed and
+// updated captures add to it, after a warcinfo record of their own.
+func openWARC(outputDir string, cfg *config.Config) (*warc.Writer, error) {
+	path := WARCFile(outputDir)
+	if err := os.MkdirAll(filepath.Dir(path), cfg.DirPerms); err != nil {
+		return nil, fmt.Errorf("failed to create directory for WARC file: %w", err)
+	}
+	w, err := warc.Append(path, cfg.FilePerms)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.WriteInfo("website-archiver"); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	slog.Info("Recording exchanges into WARC file", "path", path)
+	return w, nil
+}
+
+// OpenStorage returns the storage the capture in outputDir is written to.
+// Remote storage keeps the capture under its path inside the output root.
+func OpenStorage(outputDir string, cfg *config.Config) (storage.Storage, error) {
+	if cfg.OpenStorage != nil {
+		return cfg.OpenStorage(outputDir)
+	}
+	capture := filepath.Base(outputDir)
+	if rel, err := filepath.Rel(cfg.OutputDir, outputDir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		capture = filepath.ToSlash(rel)
+	}
+	return storage.Open(cfg.StorageURL, outputDir, capture, storage.Options{
+		DirPerms:   cfg.DirPerms,
+		S3Endpoint: cfg.S3Endpoint,
+		S3Region:   cfg.S3Region,
+	})
+}
+
+// applyDocsPreset scopes the crawl to a documentation tree and queues its
+// search index files and, with all versions requested, every other version.
+// It returns the seed after redirects, which is where the docs actually live.
+func (c *crawler) applyDocsPreset(ctx context.Context, seed *url.URL, depth int) *url.URL {
+	if final, err := c.resolveRedirects(ctx, seed); err == nil {
+		seed = final
+	}
+	c.scope = preset.DocsScope(seed, c.cfg.DocVersions)
+	slog.Info("Using documentation preset", "url", seed.String(), "scope", c.scope.Prefix, "versions", c.cfg.DocVersions)
+
+	var seeds []string
+	if c.scope.VersionsFile != "" {
+		if versionsURL, err := url.Parse(c.scope.VersionsFile); err == nil {
+			if data, err := c.downloadContent(ctx, versionsURL); err == nil {
+				seeds = c.scope.VersionSeeds(seed, []byte(data))
+				_ = c.downloadRecursive(ctx, versionsURL, 0)
+			}
+		}
+	}
+
+	c.queue(ctx, seeds, depth)
+	c.queue(ctx, c.scope.Files, 0)
+	return seed
+}
+
+// queue crawls each raw URL in the background with the given depth.
+func (c *crawler) queue(ctx context.Context, rawURLs []string, depth int) {
+	for _, raw := range rawURLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		c.spawn(nil, u, depth, false)
+	}
+}
+
+// queueSitemap queues the pages listed in the sitemaps of the seed's site as
+// if the seed linked to each of them, so deep pages are reached without
+// raising the depth.
+func (c *crawler) queueSitemap(ctx context.Context, seed *url.URL, depth int) {
+	if c.baseDomain == waybackHost {
+		slog.Info("Sitemap seeding does not apply to Wayback Machine captures", "url", seed.String())
+		return
+	}
+	seeds, err := sitemap.Seeds(ctx, c.client, seed)
+	if err != nil {
+		slog.Warn("Failed to read sitemap", "error", err, "url", seed.String())
+		return
+	}
+	// Sitemaps list every page of a calendar or tag archive as well
+	seeds = slices.DeleteFunc(seeds, func(raw string) bool {
+		u, err := url.Parse(raw)
+		return err == nil && !c.inScope(u, false)
+	})
+	slog.Info("Queueing pages listed in the sitemap", "count", len(seeds), "url", seed.String())
+	c.queue(ctx, seeds, max(depth-1, 0))
+}
+
+// spawn queues u in its canonical spelling, found on the page from, for the
+// fetch workers; from is nil for URLs not found on a page, which the include
+// and exclude patterns do not apply to. Requisites that turn out to be
+// missing are recorded for Wayback patching.
+func (c *crawler) spawn(from, u *url.URL, depth int, requisite bool) {
+	u = c.normalize.URL(u)
+	if from != nil && !c.inScope(u, requisite) {
+		return
+	}
+	c.markSpanned(u, requisite)
+	if !requisite {
+		depth = c.boosts.Depth(from, u, depth)
+	}
+	var source string
+	if from != nil {
+		source = from.String()
+	}
+	if !c.push(u, depth, requisite, source) {
+		return
+	}
+	e := progress.Event{Type: progress.Discovered, URL: u.String()}
+	if from != nil {
+		e.Parent = from.String()
+	}
+	c.emit(e)
+}
+
+// inScope reports whether the include and exclude patterns let u be crawled.
+func (c *crawler) inScope(u *url.URL, requisite bool) bool {
+	ok, reason := c.patterns.Allow(u.String(), !requisite)
+	if !ok {
+		slog.Debug("Skipping URL outside the crawl patterns", "reason", reason, "url", u.String())
+	}
+	return ok
+}
+
+// maxFailureLog caps the errors kept for the failure summary.
+const maxFailureLog = 10
+
+// report logs a failed background download. It does not stop the crawl.
+func (c *crawler) report(u *url.URL, requisite bool, err error) {
+	if err == nil {
+		return
+	}
+	slog.Debug("Failed to download linked resource", "error", err, "url", u.String(), fetcherr.Attr(err))
+	c.mu.Lock()
+	c.failures++
+	if len(c.failureLog) < maxFailureLog {
+		c.failureLog = append(c.failureLog, err.Error())
+	}
+	c.mu.Unlock()
+	if requisite && isMissing(err) {
+		c.recordMissing(u)
+	}
+}
+
+// resolveRedirects returns the URL a request for u ends up at.
+func (c *crawler) resolveRedirects(ctx context.Context, u *url.URL) (*url.URL, error) {
+	ctx, attempts := retry.TrackAttempts(ctx)
+	req, err := http.NewRequestWithContext(ctx, "HEAD", u.String(), nil)
+	if err != nil {
+		return nil, &fetcherr.FetchError{URL: u.String(), Stage: fetcherr.StageFetch, Err: err}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, &fetcherr.FetchError{URL: u.String(), Stage: fetcherr.StageFetch, Attempt: attempts(), Err: err}
+	}
+	resp.Body.Close()
+	return resp.Request.URL, nil
+}
+
+// captureWordPress pulls the REST API content of a WordPress site and fetches
+// the media it references. Failures are logged, since the HTML crawl already succeeded.
+func (c *crawler) captureWordPress(ctx context.Context, seed *url.URL) {
+	apiRoot := c.wpRoot
+	if apiRoot == "" {
+		candidate := seed.ResolveReference(&url.URL{Path: wordpress.DefaultAPIPath}).String()
+		if !wordpress.Probe(ctx, c.client, candidate) {
+			slog.Info("No WordPress REST API detected", "url", seed.String())
+			return
+		}
+		apiRoot = candidate
+	}
+
+	slog.Info("Capturing WordPress REST API content", "apiRoot", apiRoot)
+	wp, err := wordpress.Capture(ctx, c.client, apiRoot, c.outputDir, c.cfg)
+	if err != nil {
+		slog.Warn("Failed to capture WordPress content", "error", err, "apiRoot", apiRoot)
+		return
+	}
+	c.manifest.SetWordPress(wp)
+
+	for _, media := range wp.Media {
+		mediaURL, err := url.Parse(media.SourceURL)
+		if err != nil || media.SourceURL == "" {
+			continue
+		}
+		if err := c.downloadRecursive(ctx, mediaURL, 0); err != nil {
+			slog.Debug("Failed to download WordPress media", "error", err, "url", media.SourceURL)
+		}
+	}
+	c.wg.Wait()
+}
+
+// markVisited reports whether u still needs to be fetched at the given depth,
+// recording the visit if so. A URL seen before is only fetched again when it is
+// reached with more remaining depth than last time.
+func (c *crawler) markVisited(u *url.URL, depth int) bool {
+	fetch, err := c.visited.Mark(u.String(), depth)
+	if err != nil {
+		slog.Warn("Failed to record visit", "error", err, "url", u.String())
+		return false
+	}
+	return fetch
+}
+
+func (c *crawler) downloadRecursive(ctx context.Context, currentURL *url.URL, depth int) error {
+	if !c.admit(currentURL, depth) {
 		return nil
 	}
+	c.hold(frontier.Item{URL: currentURL.String(), Depth: depth})
+	err := c.visit(ctx, currentURL, depth, false)
+	c.release(ctx, currentURL, err)
+	return err
+}
 
-	if currentURL.Hostname() != baseDomain && baseDomain != "" {
+// admit reports whether u is part of the crawl and not fetched with this much
+// depth yet, recording the visit if so.
+func (c *crawler) admit(u *url.URL, depth int) bool {
+	if depth < 0 {
+		return false
+	}
+
+	if !c.inSite(c.remap.URL(u).Hostname()) && c.baseDomain != "" && !c.isOffsite(u) && !c.isSpanned(u) {
 		// Do not download external domains recursively
+		return false
+	}
+
+	return c.markVisited(u, depth)
+}
+
+// visit fetches an admitted URL and reports its progress. requisite tells
+// whether a page references u as a resource rather than a link.
+func (c *crawler) visit(ctx context.Context, currentURL *url.URL, depth int, requisite bool) error {
+	if !c.polite(ctx, currentURL) {
+		c.quality.Blocked()
 		return nil
 	}
+	c.emit(progress.Event{Type: progress.Started, URL: currentURL.String()})
+	size, err := c.fetch(ctx, currentURL, depth)
+	if ctx.Err() == nil && !errors.Is(err, wayback.ErrQuota) {
+		c.quality.Fetched(requisite, err != nil)
+	}
+	if err != nil {
+		err = fetcherr.Wrap(fetcherr.StageFetch, currentURL.String(), err)
+		fe, _ := fetcherr.As(err)
+		c.emit(progress.Event{Type: progress.Failed, URL: currentURL.String(), Error: err.Error(), Stage: fe.Stage, Status: fe.Status})
+		return err
+	}
+	c.emit(progress.Event{Type: progress.Finished, URL: currentURL.String(), Bytes: size})
+	return nil
+}
+
+// annotate applies the tags and the note of the run to the manifest. A
+// resumed capture keeps the ones it has unless new ones are given.
+func (c *crawler) annotate() {
+	tags, note := c.manifest.Tags, c.manifest.Note
+	if len(c.cfg.Tags) > 0 {
+		tags = c.cfg.Tags
+	}
+	if c.cfg.Note != "" {
+		note = c.cfg.Note
+	}
+	c.manifest.SetAnnotations(tags, note)
+}
+
+// emit reports progress if anyone is listening.
+func (c *crawler) emit(e progress.Event) {
+	count(e)
+	if c.cfg.Progress != nil {
+		if c.frontier != nil {
+			e.Queued = c.frontier.Stats().Pending
+		}
+		c.cfg.Progress(e)
+	}
+}
+
+// fetch downloads a single resource, saves it, and queues the resources it
+// links to. It returns the number of bytes saved.
+func (c *crawler) fetch(ctx context.Context, currentURL *url.URL, depth int) (int64, error) {
+	ctx, attempts := retry.TrackAttempts(ctx)
+	status := 0
+	fail := func(stage string, err error) error {
+		return &fetcherr.FetchError{URL: currentURL.String(), Stage: stage, Status: status, Attempt: attempts(), Err: err}
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", currentURL.String(), nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request for %s: %w", currentURL.String(), err)
+		return 0, fail(fetcherr.StageFetch, err)
+	}
+	c.referrer.Apply(req)
+	req.Header.Set("Accept-Encoding", contentcoding.Accept)
+	prev, revalidate := c.previousCopy(currentURL.String())
+	if revalidate {
+		setConditional(req, prev)
 	}
 
-	client := &http.Client{Timeout: cfg.HTTPTimeout}
-	resp, err := client.Do(req)
+	resp, err := c.doAliased(req)
 	if err != nil {
-		return fmt.Errorf("failed to fetch %s: %w", currentURL.String(), err)
+		return 0, fail(fetcherr.StageFetch, err)
+	}
+	status = resp.StatusCode
+	if resp, err = c.passChallenge(ctx, req, resp); err != nil {
+		return 0, fail(fetcherr.StageChallenge, err)
 	}
 	defer resp.Body.Close()
+	fetchedAt := time.Now().UTC()
+	status = resp.StatusCode
 
+	if resp.StatusCode == http.StatusNotModified && revalidate {
+		c.keepUnchanged(prev)
+		return prev.Size, nil
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to fetch %s: status code %d", currentURL.String(), resp.StatusCode)
+		if c.baseDomain == waybackHost {
+			if err := wayback.Classify(currentURL.String(), resp); err != nil {
+				return 0, fail(fetcherr.StageWayback, err)
+			}
+		}
+		switch c.errorPages.Mode(resp.StatusCode) {
+		case errorpages.Fail:
+			return 0, fail(fetcherr.StageFetch, nil)
+		case errorpages.Skip:
+			slog.Info("Skipping resource", "reason", fmt.Sprintf("status %d", resp.StatusCode), "url", currentURL.String())
+			return 0, nil
+		case errorpages.Report:
+			c.errorLog.Add(errorpages.Page{URL: currentURL.String(), Status: resp.StatusCode})
+			return 0, nil
+		}
+		// Saved error pages are handled like other resources below
 	}
 
-	contentType := resp.Header.Get("Content-Type")
-	isHTML := strings.Contains(contentType, "text/html")
+	limit, err := c.sizeLimit(currentURL, resp.ContentLength)
+	if err != nil {
+		slog.Info("Skipping resource", "reason", err.Error(), "url", currentURL.String())
+		c.quality.Stripped(resp.ContentLength)
+		return 0, nil
+	}
+	payload, encoding, err := c.decodeBody(resp, currentURL)
+	if err != nil {
+		return 0, fail(fetcherr.StageFetch, err)
+	}
+	defer payload.Close()
+	body := io.Reader(payload)
+	if limit > 0 {
+		// Read one byte past the cap to notice bodies without a length that
+		// exceed it, counting decoded bytes
+		body = io.LimitReader(payload, limit+1)
+	}
+
+	if c.cfg.WordPress {
+		if root := wordpress.APIRootFromLinkHeader(resp.Header.Get("Link")); root != "" {
+			c.setWordPressRoot(root)
+		}
+	}
 
-	filePath := filepath.Join(outputDir, getPathFromURL(currentURL, isHTML))
-	dir := filepath.Dir(filePath)
+	contentType := resp.Header.Get("Content-Type")
+	if ok, reason := c.mime.Allow(contentType); !ok {
+		// The body is closed unread, so skipped media is never downloaded
+		slog.Info("Skipping resource", "reason", reason, "url", currentURL.String())
+		c.quality.Stripped(resp.ContentLength)
+		return 0, nil
+	}
+	isHTML := isHTMLType(contentType)
+	if isHTML && c.isSpanned(currentURL) {
+		// Only requisites are fetched from other hosts, never their pages
+		slog.Info("Skipping resource", "reason", "page on another host", "url", currentURL.String())
+		return 0, nil
+	}
 
-	if err := os.MkdirAll(dir, cfg.DirPerms); err != nil {
-		return fmt.Errorf("failed to create directory for %s: %w", filePath, err)
+	hops := redirectChain(resp)
+	if final := resp.Request.URL; len(hops) > 0 && c.inSite(final.Hostname()) {
+		c.recordRedirects(hops, final)
+		if isHTML {
+			c.writeRedirectStubs(hops, final)
+		}
+		if !c.markVisited(final, depth) {
+			slog.Debug("Redirect target already fetched", "url", currentURL.String(), "target", final.String())
+			return 0, nil
+		}
+		// The content and its links belong to where the redirects ended
+		currentURL = final
 	}
 
-	file, err := os.Create(filePath) // #nosec G304 - filePath is constructed from a sanitized URL path
+	relPath := c.localPath(currentURL, isHTML)
+	name := filepath.ToSlash(relPath)
+	file, err := c.storage.Create(name)
 	if err != nil {
-		return fmt.Errorf("failed to create file %s: %w", filePath, err)
+		return 0, fail(fetcherr.StageSave, err)
 	}
-	defer file.Close()
+	closed := false
+	defer func() {
+		if !closed {
+			_ = file.Close()
+		}
+	}()
+
+	resource := manifest.Resource{
+		URL:             currentURL.String(),
+		Path:            name,
+		ContentType:     contentType,
+		ContentEncoding: encoding,
+		Status:          resp.StatusCode,
+		CID:             ipfs.ResourceCID(resp.Header),
+		FetchedAt:       fetchedAt,
+		Date:            resp.Header.Get("Date"),
 
-	if isHTML {
-		bodyBytes, err := io.ReadAll(resp.Body)
+		CacheControl: resp.Header.Get("Cache-Control"),
+		Expires:      resp.Header.Get("Expires"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ETag:         resp.Header.Get("ETag"),
+		Redirects:    hops,
+	}
+	if roots := ipfs.RootCID(currentURL, resp.Header); roots != "" {
+		c.manifest.SetIPFSRoot(roots)
+	}
+
+	// Error pages, and documents that are still encoded, are saved as
+	// served without following their links
+	errorPage := resp.StatusCode != http.StatusOK
+	verbatim := errorPage || encoding != ""
+	var queued *page
+	if isHTML && !verbatim {
+		bodyBytes, err := io.ReadAll(body)
 		if err != nil {
-			return fmt.Errorf("failed to read response body for %s: %w", currentURL.String(), err)
+			return 0, fail(fetcherr.StageFetch, fmt.Errorf("failed to read response body: %w", err))
 		}
+		bodyBytes = c.bypassPaywall(ctx, currentURL, bodyBytes, &resource)
 		// Write the original content to the file
 		if _, err := file.Write(bodyBytes); err != nil {
-			return fmt.Errorf("failed to write content to %s: %w", filePath, err)
+			return 0, fail(fetcherr.StageSave, fmt.Errorf("failed to write content to %s: %w", name, err))
 		}
+		digest := sha256.Sum256(bodyBytes)
+		resource.Size = int64(len(bodyBytes))
+		resource.Digest = hex.EncodeToString(digest[:])
 
-		// Parse the HTML for links
-		doc, err := html.Parse(strings.NewReader(string(bodyBytes)))
+		// Parsing and rewriting happen in the parse stage once the size
+		// caps below have been checked
+		queued = &page{url: currentURL, depth: depth, name: name, body: bodyBytes}
+	} else if xmldoc.IsXML(contentType) && !verbatim {
+		bodyBytes, err := io.ReadAll(body)
 		if err != nil {
-			return fmt.Errorf("failed to parse HTML for %s: %w", currentURL.String(), err)
-		}
-
-		var f func(*html.Node)
-		f = func(n *html.Node) {
-			if n.Type == html.ElementNode {
-				for i, a := range n.Attr {
-					var link string
-					switch a.Key {
-					case "href":
-						if n.Data == "link" && getAttr(n, "rel") == "stylesheet" && !noCss {
-							// Handle CSS links: download and embed
-							cssURL := resolveURL(currentURL, a.Val)
-							if cssURL != nil && cssURL.Hostname() == baseDomain {
-								cssContent, err := downloadContent(ctx, cssURL, cfg)
-								if err == nil {
-									n.Attr[i].Key = ""
-									n.Attr[i].Val = ""
-									n.Data = "style"
-									n.FirstChild = &html.Node{Type: html.TextNode, Data: cssContent}
-									continue
-								}
-							}
-						}
-						link = a.Val
-					case "src":
-						if n.Data == "script" && !noJs {
-							// Handle JavaScript links: download and embed
-							jsURL := resolveURL(currentURL, a.Val)
-							if jsURL != nil && jsURL.Hostname() == baseDomain {
-								jsContent, err := downloadContent(ctx, jsURL, cfg)
-								if err == nil {
-									n.Attr[i].Key = ""
-									n.Attr[i].Val = ""
-									n.FirstChild = &html.Node{Type: html.TextNode, Data: jsContent}
-									continue
-								}
-							}
-						}
-						link = a.Val
-					case "poster": // For video poster images
-						link = a.Val
-					default:
-						continue
-					}
-
-					if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(link, "mailto:") || strings.HasPrefix(link, "tel:") {
-						continue
-					}
-
-					resolvedURL := resolveURL(currentURL, link)
-					if resolvedURL != nil && resolvedURL.String() != currentURL.String() {
-						go func(u *url.URL) {
-							if err := downloadRecursive(ctx, u, baseDomain, depth-1, outputDir, noJs, noCss, cfg); err != nil {
-								// Log error, but don't stop the main download
-							}
-						}(resolvedURL)
-
-						// Convert links in the HTML to relative paths or updated paths
-						newLink := getPathFromURL(resolvedURL, strings.Contains(link, ".html") || strings.Contains(link, ".htm"))
-						n.Attr[i].Val = newLink
-					}
-				}
-			}
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				f(c)
-			}
+			return 0, fail(fetcherr.StageFetch, fmt.Errorf("failed to read response body: %w", err))
 		}
-		f(doc)
+		digest := sha256.Sum256(bodyBytes)
+		resource.Size = int64(len(bodyBytes))
+		resource.Digest = hex.EncodeToString(digest[:])
 
-		// Re-write the HTML with updated links
-		var buf strings.Builder
-		if err := html.Render(&buf, doc); err != nil {
-			return fmt.Errorf("failed to render HTML with updated links for %s: %w", filePath, err)
+		if _, err := file.Write(c.followStylesheets(ctx, currentURL, resource.Path, bodyBytes, depth)); err != nil {
+			return 0, fail(fetcherr.StageSave, fmt.Errorf("failed to write content to %s: %w", name, err))
 		}
-		if err := os.WriteFile(filePath, []byte(buf.String()), cfg.FilePerms); err != nil {
-			return fmt.Errorf("failed to write updated HTML to %s: %w", filePath, err)
+	} else if cssdoc.IsCSS(contentType) && !verbatim {
+		bodyBytes, err := io.ReadAll(body)
+		if err != nil {
+			return 0, fail(fetcherr.StageFetch, fmt.Errorf("failed to read response body: %w", err))
 		}
+		digest := sha256.Sum256(bodyBytes)
+		resource.Size = int64(len(bodyBytes))
+		resource.Digest = hex.EncodeToString(digest[:])
 
+		if _, err := file.Write(c.followCSS(ctx, currentURL, resource.Path, bodyBytes, depth)); err != nil {
+			return 0, fail(fetcherr.StageSave, fmt.Errorf("failed to write content to %s: %w", name, err))
+		}
 	} else {
-		if _, err := io.Copy(file, resp.Body); err != nil {
-			return fmt.Errorf("failed to save %s to %s: %w", currentURL.String(), filePath, err)
+		hash := sha256.New()
+		size, err := io.Copy(io.MultiWriter(file, hash), body)
+		if err != nil {
+			return 0, fail(fetcherr.StageSave, fmt.Errorf("failed to save to %s: %w", name, err))
 		}
+		resource.Size = size
+		resource.Digest = hex.EncodeToString(hash.Sum(nil))
 	}
 
-	return nil
+	closed = true
+	if err := file.Close(); err != nil {
+		return 0, fail(fetcherr.StageSave, fmt.Errorf("failed to save to %s: %w", name, err))
+	}
+	if limit > 0 && resource.Size > limit {
+		if err := c.storage.Remove(name); err != nil {
+			return 0, fail(fetcherr.StageSave, fmt.Errorf("failed to remove oversized file %s: %w", name, err))
+		}
+		slog.Info("Skipping resource", "reason", fmt.Sprintf("larger than the size cap of %d bytes", limit), "url", currentURL.String())
+		c.quality.Stripped(resource.Size)
+		return 0, nil
+	}
+	c.addListingBytes(currentURL, resource.Size)
+
+	if queued != nil {
+		queued.resource = resource
+		if err := c.enqueue(ctx, queued); err != nil {
+			return 0, err
+		}
+		return resource.Size, nil
+	}
+	if errorPage {
+		c.errorLog.Add(errorpages.Page{URL: currentURL.String(), Status: resp.StatusCode, Path: name})
+	}
+	c.manifest.Add(resource)
+	return resource.Size, nil
 }
 
+// sizeLimit returns the number of bytes that may be read for u, or 0 when no
+// cap applies: the smaller of --max-file-size and the caps of listing files.
+// It fails when the announced length already exceeds the cap.
+func (c *crawler) sizeLimit(u *url.URL, contentLength int64) (int64, error) {
+	limit, err := c.listingLimit(u, contentLength)
+	if err != nil {
+		return 0, err
+	}
+	if capped := c.cfg.MaxFileSize; capped > 0 && (limit == 0 || capped < limit) {
+		if contentLength > capped {
+			return 0, fmt.Errorf("%d bytes exceeds the size cap of %d bytes", contentLength, capped)
+		}
+		limit = capped
+	}
+	return limit, nil
+}
+
+// followStylesheets fetches the stylesheets an XML document or XSLT stylesheet
+// references and returns the document with those references pointing at the
+// local copies. Stylesheets are requisites, so they are fetched at the depth of
+// the document itself. In fidelity mode the document is returned unchanged and
+// the rewrites are recorded instead.
+func (c *crawler) followStylesheets(ctx context.Context, docURL *url.URL, docPath string, data []byte, depth int) []byte {
+	refs, err := xmldoc.Refs(data)
+	if err != nil {
+		slog.Debug("Failed to parse XML document", "error", err, "url", docURL.String())
+	}
+
+	mapping := make(map[string]string)
+	for _, ref := range refs {
+		styleURL := resolveURL(docURL, html.UnescapeString(ref.Href))
+		if styleURL == nil || styleURL.String() == docURL.String() {
+			continue
+		}
+		c.spawn(docURL, styleURL, depth, true)
+
+		if !c.inSite(styleURL.Hostname()) {
+			continue
+		}
+		local := linkTo(docPath, c.localPath(styleURL, false))
+		if c.rewrites != nil {
+			c.rewrites.Add(docPath, ref.Href, local)
+		} else {
+			mapping[ref.Href] = local
+		}
+	}
+	if len(mapping) == 0 {
+		return data
+	}
+
+	rewritten, err := xmldoc.Rewrite(data, mapping)
+	if err != nil {
+		slog.Debug("Failed to rewrite XML document", "error", err, "url", docURL.String())
+		return data
+	}
+	return rewritten
+}
+
+// inSite reports whether host belongs to the site being crawled. Subdomains
+// of an onion service are the same site, as they share its address, and so
+// are the host aliases.
+func (c *crawler) inSite(host string) bool {
+	return host == c.baseDomain || onion.SameSite(host, c.baseDomain) || c.isAlias(host)
+}
+
+// markOffsite records a link to an FTP or Gopher file on another host, which
+// is fetched without following its own links. It reports whether u is one.
+func (c *crawler) markOffsite(u *url.URL) bool {
+	if !c.cfg.LegacyProtocols || !legacy.Supports(u.Scheme) || u.Hostname() == c.baseDomain {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offsite[u.String()] = true
+	return true
+}
+
+// isOffsite reports whether u was recorded by markOffsite.
+func (c *crawler) isOffsite(u *url.URL) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offsite[u.String()]
+}
+
+// localPath returns the path u is saved at, after URL normalization and the
+// remap rules. A URL known to redirect is saved at the path of where it
+// leads. Files from FTP and Gopher servers on other hosts, and requisites
+// spanned to other hosts, are kept apart under their scheme and host, and
+// paths differing from another only by case get a distinct name.
+func (c *crawler) localPath(u *url.URL, isHTML bool) string {
+	return c.pathFor(c.redirected(c.normalize.URL(u)), isHTML)
+}
+
+// pathFor is localPath without following known redirects.
+func (c *crawler) pathFor(u *url.URL, isHTML bool) string {
+	source := c.sourcePath(u, isHTML)
+	p, renamed := c.cases.Assign(source, c.restrict.Apply(source))
+	if renamed {
+		slog.Info("Path differs from another only by case, saving under a distinct name", "url", u.String(), "path", p)
+	}
+	return filepath.FromSlash(p)
+}
+
+// sourcePath returns the slash-separated path u maps to before file name
+// restrictions and case disambiguation.
+func (c *crawler) sourcePath(u *url.URL, isHTML bool) string {
+	u = c.remap.URL(u)
+	p := getPathFromURL(u, isHTML)
+	if p != "" && ((legacy.Supports(u.Scheme) && u.Hostname() != c.baseDomain) || c.spansTo(u)) {
+		p = filepath.Join(u.Scheme, u.Hostname(), p)
+	}
+	return manifest.EscapePath(filepath.ToSlash(p))
+}
+
+// linkTo returns the link from the file at docPath to the file at target,
+// both paths inside the capture, relative to the directory of docPath and
+// escaped so characters such as '?', '#', and '%' in file names are not
+// read as URL syntax.
+func linkTo(docPath, target string) string {
+	docPath, target = filepath.ToSlash(docPath), filepath.ToSlash(target)
+	rel, err := filepath.Rel(filepath.Dir(filepath.FromSlash(docPath)), filepath.FromSlash(target))
+	if err != nil {
+		rel = target
+	}
+	// A colon in the first segment is escaped as a "./" prefix
+	return (&url.URL{Path: filepath.ToSlash(rel)}).String()
+}
+
+// isHTMLType reports whether a content type is parsed as a page. XHTML served
+// with its XML type is handled like HTML.
+func isHTMLType(contentType string) bool {
+	return strings.Contains(contentType, "text/html") || strings.Contains(contentType, "application/xhtml+xml")
+}
+
+// setWordPressRoot remembers the REST API root advertised by the site.
+func (c *crawler) setWordPressRoot(root string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.wpRoot == "" {
+		c.wpRoot = root
+	}
+}
+
+// dropBlankAttrs removes attributes blanked out during rewriting, which
+// html.Render would otherwise emit as empty-named attributes.
+func dropBlankAttrs(attrs []html.Attribute) []html.Attribute {
+	kept := attrs[:0]
+	for _, a := range attrs {
+		if a.Key != "" {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+// verifyAccessibility checks that the rewritten HTML still carries every ARIA
+// and related attribute of the original page.
+func verifyAccessibility(u *url.URL, before []string, rendered string) {
+	doc, err := html.Parse(strings.NewReader(rendered))
+	if err != nil {
+		return
+	}
+	if lost := a11y.Lost(before, a11y.Metadata(doc)); len(lost) > 0 {
+		slog.Warn("Accessibility attributes lost while rewriting", "url", u.String(), "lost", lost)
+	}
+}
+
+// isNavigation reports whether n links to another page rather than embedding a resource.
+func isNavigation(n *html.Node) bool {
+	return n.Data == "a" || n.Data == "area"
+}
+
+// getPathFromURL returns the relative path u is saved at, or "" for a path
+// escaping the capture. Directories are saved as their index page, and the
+// query is kept in the file name, so pages differing only by their query
+// are saved apart.
 func getPathFromURL(u *url.URL, isHTML bool) string {
 	path := u.Path
 	if strings.HasSuffix(path, "/") || path == "" {
@@ -186,11 +1201,27 @@ func getPathFromURL(u *url.URL, isHTML bool) string {
 		}
 	}
 	// Ensure the path is relative and clean to prevent directory traversal
-	cleanPath := filepath.Clean(strings.TrimPrefix(path, "/"))
+	cleanPath := filepath.Clean(strings.TrimLeft(path, "/"))
 	if strings.HasPrefix(cleanPath, "..") {
 		return "" // Or handle as an error, depending on desired behavior
 	}
-	return cleanPath
+	return withQuery(cleanPath, u.RawQuery)
+}
+
+// withQuery adds a query to the name of a file, before its extension so the
+// type of the file is still known offline: list?page=2 is saved as
+// "list?page=2" and style.css?v=3 as "style?v=3.css". Slashes in the query
+// are escaped so it stays in the last path element.
+func withQuery(path, rawQuery string) string {
+	if rawQuery == "" {
+		return path
+	}
+	query := "?" + strings.NewReplacer("/", "%2F", `\`, "%5C").Replace(rawQuery)
+	ext := filepath.Ext(path)
+	if ext == filepath.Base(path) {
+		ext = "" // A dot file such as .htaccess has no extension
+	}
+	return strings.TrimSuffix(path, ext) + query + ext
 }
 
 func resolveURL(baseURL *url.URL, ref string) *url.URL {
@@ -212,26 +1243,45 @@ func getAttr(n *html.Node, key string) string {
 }
 
 // downloadContent is a helper function to download content from a URL
-func downloadContent(ctx context.Context, u *url.URL, cfg *config.Config) (string, error) {
+func (c *crawler) downloadContent(ctx context.Context, u *url.URL) (string, error) {
+	ctx, attempts := retry.TrackAttempts(ctx)
+	status := 0
+	fail := func(err error) error {
+		return &fetcherr.FetchError{URL: u.String(), Stage: fetcherr.StageFetch, Status: status, Attempt: attempts(), Err: err}
+	}
+	if !c.polite(ctx, u) {
+		return "", fail(errors.New("disallowed by robots.txt"))
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request for %s: %w", u.String(), err)
+		return "", fail(err)
 	}
+	c.referrer.Apply(req)
 
-	client := &http.Client{Timeout: cfg.HTTPTimeout}
-	resp, err := client.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch %s: %w", u.String(), err)
+		return "", fail(err)
 	}
 	defer resp.Body.Close()
+	status = resp.StatusCode
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to fetch %s: status code %d", u.String(), resp.StatusCode)
+		return "", fail(nil)
+	}
+	body := io.Reader(resp.Body)
+	if limit := c.cfg.MaxFileSize; limit > 0 {
+		if resp.ContentLength > limit {
+			return "", fail(fmt.Errorf("%d bytes exceeds the size cap of %d bytes", resp.ContentLength, limit))
+		}
+		body = io.LimitReader(resp.Body, limit+1)
 	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	bodyBytes, err := io.ReadAll(body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body for %s: %w", u.String(), err)
+		return "", fail(fmt.Errorf("failed to read response body: %w", err))
+	}
+	if limit := c.cfg.MaxFileSize; limit > 0 && int64(len(bodyBytes)) > limit {
+		return "", fail(fmt.Errorf("larger than the size cap of %d bytes", limit))
 	}
 	return string(bodyBytes), nil
 }
@@ -0,0 +1,37 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/contentcoding"
+	"github.com/Sudo-Ivan/website-archiver/internal/cssdoc"
+	"github.com/Sudo-Ivan/website-archiver/internal/xmldoc"
+)
+
+// decodeBody returns the payload of resp decoded from its content codings,
+// and the codings left on it: all of them for files kept as served with
+// --keep-content-encoding, and those without a decoder. Pages, feeds, and
+// stylesheets are always decoded, since their links are read.
+func (c *crawler) decodeBody(resp *http.Response, u *url.URL) (io.ReadCloser, string, error) {
+	header := resp.Header.Get("Content-Encoding")
+	contentType := resp.Header.Get("Content-Type")
+	parsed := isHTMLType(contentType) || xmldoc.IsXML(contentType) || cssdoc.IsCSS(contentType)
+	if c.cfg.KeepContentEncoding && !parsed {
+		return io.NopCloser(resp.Body), strings.Join(contentcoding.Codings(header), ", "), nil
+	}
+	payload, rest, err := contentcoding.Decode(resp.Body, header)
+	if err != nil {
+		return nil, "", err
+	}
+	if rest != "" {
+		slog.Warn("No decoder for the content encoding, saving as served", "encoding", rest, "url", u.String())
+	}
+	return payload, rest, nil
+}
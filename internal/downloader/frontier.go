@@ -0,0 +1,141 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/frontier"
+	"github.com/Sudo-Ivan/website-archiver/internal/referrer"
+	"github.com/Sudo-Ivan/website-archiver/internal/wayback"
+)
+
+const (
+	// frontierSuffix ends the name of a frontier database.
+	frontierSuffix = ".frontier.db"
+	// frontierReportInterval is how often the frontier size is logged.
+	frontierReportInterval = 10 * time.Second
+)
+
+// startFetchers starts the pool of fetch workers and the queue they drain:
+// in memory, or the persistent frontier of the seed's host with
+// --frontier-dir, in which case URLs left over from an interrupted crawl are
+// fetched along with the new ones. The returned function closes the queue
+// once the crawl is over.
+func (c *crawler) startFetchers(ctx context.Context, seed *url.URL) (stop func(), err error) {
+	workers := max(c.cfg.Concurrency, 1)
+	if c.cfg.FrontierDir == "" {
+		c.frontier = frontier.NewMemory()
+		for range workers {
+			go c.drainFrontier(ctx)
+		}
+		return func() { _ = c.frontier.Close() }, nil
+	}
+
+	if err := os.MkdirAll(c.cfg.FrontierDir, c.cfg.DirPerms); err != nil {
+		return nil, fmt.Errorf("failed to create frontier directory: %w", err)
+	}
+	name := strings.NewReplacer(":", "_", "/", "_").Replace(seed.Host) + frontierSuffix
+	f, err := frontier.Open(filepath.Join(c.cfg.FrontierDir, name), c.cfg.FilePerms)
+	if err != nil {
+		return nil, err
+	}
+	c.frontier = f
+
+	stats := f.Stats()
+	if stats.Pending > 0 {
+		slog.Info("Resuming crawl frontier", "url", seed.String(), "pending", stats.Pending)
+	}
+	c.wg.Add(stats.Pending)
+
+	if c.cfg.FrontierWorkers > 0 {
+		workers = c.cfg.FrontierWorkers
+	}
+	for range workers {
+		go c.drainFrontier(ctx)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(frontierReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				stats := f.Stats()
+				slog.Info("Crawl frontier", "url", seed.String(), "pending", stats.Pending, "inFlight", stats.InFlight)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		if err := f.Close(); err != nil {
+			slog.Warn("Failed to close crawl frontier", "error", err)
+		}
+	}, nil
+}
+
+// push queues u, found on the page source or a seed when it is empty, for
+// the fetch workers if it is admitted to the crawl and reports whether it
+// was queued.
+func (c *crawler) push(u *url.URL, depth int, requisite bool, source string) bool {
+	if !c.admit(u, depth) {
+		return false
+	}
+	item := frontier.Item{URL: u.String(), Depth: depth, Requisite: requisite, Referrer: source}
+	c.wg.Add(1)
+	c.hold(item)
+	if err := c.frontier.Push(item); err != nil {
+		c.wg.Done()
+		c.release(context.Background(), u, err)
+		slog.Warn("Failed to queue URL", "error", err, "url", u.String())
+		return false
+	}
+	return true
+}
+
+// drainFrontier fetches frontier items until the frontier is closed. Items
+// interrupted by cancellation stay in flight, so they are queued again the
+// next time the frontier is opened.
+func (c *crawler) drainFrontier(ctx context.Context) {
+	for {
+		item, err := c.frontier.Pop()
+		if errors.Is(err, frontier.ErrClosed) {
+			return
+		}
+		if err != nil {
+			slog.Warn("Failed to read crawl frontier", "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		u, err := url.Parse(item.URL)
+		if err == nil {
+			err := c.visit(referrer.WithSource(ctx, item.Referrer, item.Requisite), u, item.Depth, item.Requisite)
+			if errors.Is(err, wayback.ErrQuota) {
+				c.deferItem(item)
+			} else {
+				c.report(u, item.Requisite, err)
+			}
+			c.release(ctx, u, err)
+		}
+		if ctx.Err() == nil {
+			if err := c.frontier.Done(item); err != nil {
+				slog.Warn("Failed to update crawl frontier", "error", err)
+			}
+		}
+		c.wg.Done()
+	}
+}
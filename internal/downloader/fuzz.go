@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+//go:build gofuzz
+
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+)
+
+// fuzzBase is the page URL the fuzz targets resolve links against.
+const fuzzBase = "https://example.com/dir/page.html?q=1"
+
+// FuzzPath checks that no URL maps to a local path outside the capture.
+func FuzzPath(data []byte) int {
+	u, err := url.Parse(string(data))
+	if err != nil {
+		return 0
+	}
+	for _, isHTML := range []bool{true, false} {
+		if p := getPathFromURL(u, isHTML); p != "" && !filepath.IsLocal(p) {
+			panic(fmt.Sprintf("path %q of %q escapes the capture", p, data))
+		}
+	}
+	return 1
+}
+
+// FuzzResolve checks that resolved links are absolute, survive a round trip
+// through their string form, and map to local paths inside the capture.
+func FuzzResolve(data []byte) int {
+	base, _ := url.Parse(fuzzBase)
+	u := resolveURL(base, string(data))
+	if u == nil {
+		return 0
+	}
+	if !u.IsAbs() {
+		panic(fmt.Sprintf("link %q resolved to relative URL %q", data, u))
+	}
+	again, err := url.Parse(u.String())
+	if err != nil {
+		panic(fmt.Sprintf("resolved URL %q of %q does not parse: %v", u, data, err))
+	}
+	if again.String() != u.String() {
+		panic(fmt.Sprintf("resolved URL %q of %q changed to %q when parsed again", u, data, again))
+	}
+	if p := getPathFromURL(u, true); p != "" && !filepath.IsLocal(p) {
+		panic(fmt.Sprintf("path %q of %q escapes the capture", p, data))
+	}
+	return 1
+}
+
+// FuzzRewrite runs the link rewriter over a page.
+func FuzzRewrite(data []byte) int {
+	if _, err := RewriteHTML(context.Background(), config.New(), fuzzBase, data); err != nil {
+		return 0
+	}
+	return 1
+}
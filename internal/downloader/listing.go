@@ -0,0 +1,151 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// listingTitles are the title prefixes of auto-generated directory listings
+// (Apache and nginx "Index of", lighttpd, Python's http.server).
+var listingTitles = []string{"Index of ", "Directory listing for "}
+
+// isListing reports whether doc is an auto-generated directory listing.
+func isListing(doc *html.Node) bool {
+	title := strings.TrimSpace(textOf(findElement(doc, "title")))
+	for _, prefix := range listingTitles {
+		if strings.HasPrefix(title, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// crawlListing follows the entries of a directory listing: subdirectories are
+// crawled as listings at the same depth, since the tree is what is being
+// archived, and files are fetched subject to the listing size caps. Sort links
+// and links leaving the directory are ignored.
+func (c *crawler) crawlListing(ctx context.Context, dirURL *url.URL, doc *html.Node, depth int) {
+	var entries []*url.URL
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			if u := listingEntry(dirURL, getAttr(n, "href")); u != nil {
+				entries = append(entries, u)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	slog.Debug("Crawling directory listing", "url", dirURL.String(), "entries", len(entries))
+	for _, u := range entries {
+		entryDepth := depth
+		if !strings.HasSuffix(u.Path, "/") {
+			c.markListingFile(u)
+			entryDepth = 0
+		}
+		c.spawn(dirURL, u, entryDepth, false)
+	}
+}
+
+// listingEntry resolves href within a listing, returning nil for sort links,
+// fragments, and anything outside the listed directory.
+func listingEntry(dirURL *url.URL, href string) *url.URL {
+	if href == "" || strings.HasPrefix(href, "?") || strings.HasPrefix(href, "#") {
+		return nil
+	}
+	u := resolveURL(dirURL, href)
+	if u == nil || u.Host != dirURL.Host || u.RawQuery != "" {
+		return nil
+	}
+	u.Fragment = ""
+	dir := dirURL.Path
+	if !strings.HasSuffix(dir, "/") {
+		dir = dir[:strings.LastIndex(dir, "/")+1]
+	}
+	if !strings.HasPrefix(u.Path, dir) || u.Path == dir {
+		return nil
+	}
+	return u
+}
+
+// markListingFile records that u is a file found in a directory listing.
+func (c *crawler) markListingFile(u *url.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listingFiles[u.String()] = true
+}
+
+// listingLimit returns the number of bytes that may be read for u, or 0 when
+// it is not a listing file or no cap applies. It fails when the announced
+// length or the total listing budget rules the file out.
+func (c *crawler) listingLimit(u *url.URL, contentLength int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.listingFiles[u.String()] {
+		return 0, nil
+	}
+
+	limit := c.cfg.ListingMaxFileSize
+	if total := c.cfg.ListingMaxTotalSize; total > 0 {
+		remaining := total - c.listingBytes
+		if remaining <= 0 {
+			return 0, fmt.Errorf("listing size cap of %d bytes reached", total)
+		}
+		if limit == 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+	if limit > 0 && contentLength > limit {
+		return 0, fmt.Errorf("%d bytes exceeds the listing size cap of %d bytes", contentLength, limit)
+	}
+	return limit, nil
+}
+
+// addListingBytes counts a saved listing file towards the total budget.
+func (c *crawler) addListingBytes(u *url.URL, n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.listingFiles[u.String()] {
+		c.listingBytes += n
+	}
+}
+
+// findElement returns the first element named tag below n.
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textOf returns the text content of n.
+func textOf(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var b strings.Builder
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return b.String()
+}
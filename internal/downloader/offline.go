@@ -0,0 +1,63 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/frontier"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+	"github.com/Sudo-Ivan/website-archiver/internal/visited"
+)
+
+// offlinePage is the name a page rewritten offline is stored under.
+const offlinePage = "page.html"
+
+// errOffline fails every request of an offline rewrite.
+var errOffline = errors.New("rewriting offline")
+
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errOffline
+}
+
+// RewriteHTML rewrites the links of the page at pageURL as a crawl with cfg
+// would, without fetching anything: the resources it links to are queued and
+// dropped, and scripts and stylesheets are not inlined. It is the rewrite
+// stage alone, for benchmarks and fuzzing.
+func RewriteHTML(ctx context.Context, cfg *config.Config, pageURL string, body []byte) ([]byte, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page URL: %w", err)
+	}
+	store := storage.NewMemory()
+	c := &crawler{
+		cfg:          cfg,
+		client:       &http.Client{Transport: offlineTransport{}},
+		baseDomain:   u.Hostname(),
+		noJs:         true,
+		noCss:        true,
+		manifest:     manifest.New(pageURL),
+		storage:      store,
+		tracker:      newCrawlTracker(),
+		visited:      visited.NewMemory(),
+		frontier:     frontier.NewMemory(),
+		listingFiles: make(map[string]bool),
+		offsite:      make(map[string]bool),
+		spanned:      make(map[string]bool),
+		redirects:    make(map[string]*url.URL),
+	}
+	p := &page{url: u, depth: 1, name: offlinePage, body: body, resource: manifest.Resource{URL: pageURL, Path: offlinePage}}
+	if err := c.rewritePage(ctx, p); err != nil {
+		return nil, err
+	}
+	return storage.ReadFile(store, offlinePage)
+}
@@ -0,0 +1,126 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/fetcherr"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/retry"
+	"github.com/Sudo-Ivan/website-archiver/internal/wayback"
+)
+
+const (
+	// waybackHost is the host serving Wayback Machine replays.
+	waybackHost = wayback.Host
+	// waybackRawURLFormat requests the original bytes of the capture closest to a timestamp.
+	waybackRawURLFormat = "https://web.archive.org/web/%sid_/%s"
+	// waybackTimestampFormat is the timestamp layout used in Wayback URLs.
+	waybackTimestampFormat = "20060102150405"
+)
+
+// isMissing reports whether err means the server no longer has the resource.
+func isMissing(err error) bool {
+	fe, ok := fetcherr.As(err)
+	return ok && (fe.Status == http.StatusNotFound || fe.Status == http.StatusGone)
+}
+
+// recordMissing remembers a page requisite that the live site could not serve.
+func (c *crawler) recordMissing(u *url.URL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.missing = append(c.missing, u)
+}
+
+// patchFromWayback fills holes left by missing page requisites with the
+// closest Wayback Machine capture of each one.
+func (c *crawler) patchFromWayback(ctx context.Context) {
+	if c.baseDomain == waybackHost || len(c.missing) == 0 {
+		return
+	}
+	timestamp := time.Now().UTC().Format(waybackTimestampFormat)
+	slog.Info("Patching missing assets from the Wayback Machine", "count", len(c.missing))
+
+	for _, u := range c.missing {
+		if err := c.patchAsset(ctx, u, timestamp); err != nil {
+			slog.Warn("Failed to patch asset from the Wayback Machine", "error", err, "url", u.String(), fetcherr.Attr(err))
+		}
+	}
+}
+
+func (c *crawler) patchAsset(ctx context.Context, u *url.URL, timestamp string) error {
+	waybackURL := fmt.Sprintf(waybackRawURLFormat, timestamp, u.String())
+	ctx, attempts := retry.TrackAttempts(ctx)
+	fail := func(stage string, status int, err error) error {
+		return &fetcherr.FetchError{URL: waybackURL, Stage: stage, Status: status, Attempt: attempts(), Err: err}
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", waybackURL, nil)
+	if err != nil {
+		return fail(fetcherr.StageWayback, 0, err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fail(fetcherr.StageWayback, 0, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if err := wayback.Classify(waybackURL, resp); err != nil {
+			return fail(fetcherr.StageWayback, resp.StatusCode, err)
+		}
+		return fail(fetcherr.StageWayback, resp.StatusCode, nil)
+	}
+
+	limit := c.cfg.MaxFileSize
+	if limit > 0 && resp.ContentLength > limit {
+		return fail(fetcherr.StageWayback, resp.StatusCode, fmt.Errorf("%d bytes exceeds the size cap of %d bytes", resp.ContentLength, limit))
+	}
+	body := io.Reader(resp.Body)
+	if limit > 0 {
+		body = io.LimitReader(resp.Body, limit+1)
+	}
+
+	name := filepath.ToSlash(c.localPath(u, strings.Contains(resp.Header.Get("Content-Type"), "text/html")))
+	file, err := c.storage.Create(name)
+	if err != nil {
+		return fail(fetcherr.StageSave, resp.StatusCode, err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fail(fetcherr.StageSave, resp.StatusCode, fmt.Errorf("failed to save to %s: %w", name, err))
+	}
+	if limit > 0 && size > limit {
+		if err := c.storage.Remove(name); err != nil {
+			return fail(fetcherr.StageSave, resp.StatusCode, fmt.Errorf("failed to remove oversized file %s: %w", name, err))
+		}
+		return fail(fetcherr.StageWayback, resp.StatusCode, fmt.Errorf("larger than the size cap of %d bytes", limit))
+	}
+
+	c.manifest.Add(manifest.Resource{
+		URL:         u.String(),
+		Path:        name,
+		ContentType: resp.Header.Get("Content-Type"),
+		Status:      resp.StatusCode,
+		Size:        size,
+		Digest:      hex.EncodeToString(hash.Sum(nil)),
+		Patched:     true,
+		ArchivedURL: resp.Request.URL.String(),
+		FetchedAt:   time.Now().UTC(),
+		Date:        resp.Header.Get("Date"),
+	})
+	slog.Info("Patched asset from the Wayback Machine", "url", u.String(), "archivedUrl", resp.Request.URL.String())
+	return nil
+}
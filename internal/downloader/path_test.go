@@ -0,0 +1,59 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetPathFromURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		isHTML bool
+		want   string
+	}{
+		{"https://example.com", true, "index.html"},
+		{"https://example.com/", true, "index.html"},
+		{"https://example.com/docs/", true, "docs/index.html"},
+		{"https://example.com/docs/", false, "docs/index"},
+		{"https://example.com/a/b/c/page.html", true, "a/b/c/page.html"},
+		{"https://example.com/a/b/../c/style.css", false, "a/c/style.css"},
+		{"https://example.com/list?page=1", true, "list?page=1"},
+		{"https://example.com/list?page=2", true, "list?page=2"},
+		{"https://example.com/style.css?v=3", false, "style?v=3.css"},
+		{"https://example.com/docs/?sort=a/b", true, "docs/index?sort=a%2Fb.html"},
+		{"https://example.com/.htaccess?x=1", false, ".htaccess?x=1"},
+		{"https://example.com/../../etc/passwd", false, ""},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := filepath.ToSlash(getPathFromURL(u, tt.isHTML)); got != tt.want {
+			t.Errorf("getPathFromURL(%q, %v) = %q, want %q", tt.url, tt.isHTML, got, tt.want)
+		}
+	}
+}
+
+func TestLinkTo(t *testing.T) {
+	tests := []struct {
+		docPath, target, want string
+	}{
+		{"index.html", "about.html", "about.html"},
+		{"index.html", "docs/index.html", "docs/index.html"},
+		{"a/b/page.html", "a/c/style.css", "../c/style.css"},
+		{"a/b/page.html", "index.html", "../../index.html"},
+		{"index.html", "list?page=1", "list%3Fpage=1"},
+		{"docs/index.html", "docs/index?sort=a%2Fb.html", "index%3Fsort=a%252Fb.html"},
+		{"index.html", "a:b.html", "./a:b.html"},
+	}
+	for _, tt := range tests {
+		if got := linkTo(filepath.FromSlash(tt.docPath), filepath.FromSlash(tt.target)); got != tt.want {
+			t.Errorf("linkTo(%q, %q) = %q, want %q", tt.docPath, tt.target, got, tt.want)
+		}
+	}
+}
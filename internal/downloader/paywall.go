@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/challenge"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/paywall"
+	"github.com/Sudo-Ivan/website-archiver/internal/wayback"
+)
+
+// archiveTodayNewestFormat redirects to the newest archive.today capture of a URL.
+const archiveTodayNewestFormat = "https://archive.ph/newest/%s"
+
+// snapshot is an archive capture of a page.
+type snapshot struct {
+	url  string
+	at   time.Time
+	body []byte
+}
+
+// bypassPaywall returns the body of the newest archive capture of the page at
+// u when body is paywalled, and records where it came from in resource. The
+// live body is kept when no archive has the page.
+func (c *crawler) bypassPaywall(ctx context.Context, u *url.URL, body []byte, resource *manifest.Resource) []byte {
+	mode := c.cfg.PaywallFallback
+	if mode == "" || mode == paywall.None || c.baseDomain == waybackHost {
+		return body
+	}
+	signal := paywall.Detect(body)
+	if signal == "" {
+		return body
+	}
+	var services []string
+	switch mode {
+	case paywall.Newest:
+		services = []string{paywall.Wayback, paywall.ArchiveToday}
+	default:
+		services = []string{mode}
+	}
+	var newest *snapshot
+	var errs []error
+	for _, service := range services {
+		snap, err := c.archiveCapture(ctx, service, u)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if newest == nil || snap.at.After(newest.at) {
+			newest = snap
+		}
+	}
+	if newest == nil {
+		slog.Warn("Keeping paywalled page, no archive capture of it could be fetched", "url", u.String(), "signal", signal, "error", errors.Join(errs...))
+		return body
+	}
+	slog.Info("Saved archive capture of paywalled page", "url", u.String(), "signal", signal, "archivedUrl", newest.url)
+	resource.Paywalled = true
+	resource.ArchivedURL = newest.url
+	return newest.body
+}
+
+// archiveCapture fetches the newest capture of u from service.
+func (c *crawler) archiveCapture(ctx context.Context, service string, u *url.URL) (*snapshot, error) {
+	target := fmt.Sprintf(archiveTodayNewestFormat, u.String())
+	if service == paywall.Wayback {
+		// The capture closest to now is the newest one
+		target = fmt.Sprintf(waybackRawURLFormat, time.Now().UTC().Format(waybackTimestampFormat), u.String())
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s capture: %w", service, err)
+	}
+	defer resp.Body.Close()
+	final := resp.Request.URL
+	if resp.StatusCode != http.StatusOK {
+		if service == paywall.Wayback {
+			if err := wayback.Classify(target, resp); err != nil {
+				return nil, err
+			}
+		}
+		return nil, fmt.Errorf("%s has no capture of %s (status %d)", service, u, resp.StatusCode)
+	}
+	if service == paywall.ArchiveToday && strings.HasPrefix(final.Path, "/newest/") {
+		return nil, fmt.Errorf("%s has no capture of %s", service, u)
+	}
+	body := io.Reader(resp.Body)
+	if limit := c.cfg.MaxFileSize; limit > 0 {
+		body = io.LimitReader(resp.Body, limit)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s capture: %w", service, err)
+	}
+	if vendor := challenge.Detect(resp.StatusCode, resp.Header, data); vendor != "" {
+		return nil, fmt.Errorf("%s answered with an anti-bot challenge (%s)", service, vendor)
+	}
+	return &snapshot{url: final.String(), at: captureTime(resp), body: data}, nil
+}
+
+// captureTime returns when the archive response was captured, from its
+// Memento-Datetime header or the timestamp in a Wayback URL.
+func captureTime(resp *http.Response) time.Time {
+	if at, err := http.ParseTime(resp.Header.Get("Memento-Datetime")); err == nil {
+		return at
+	}
+	if parts := strings.SplitN(strings.TrimPrefix(resp.Request.URL.Path, "/web/"), "/", 2); len(parts) == 2 {
+		if at, err := time.Parse(waybackTimestampFormat, strings.TrimSuffix(parts[0], "id_")); err == nil {
+			return at
+		}
+	}
+	return time.Time{}
+}
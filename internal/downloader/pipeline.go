@@ -0,0 +1,433 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/a11y"
+	"github.com/Sudo-Ivan/website-archiver/internal/fetcherr"
+	"github.com/Sudo-Ivan/website-archiver/internal/frontier"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/referrer"
+	"github.com/Sudo-Ivan/website-archiver/internal/simhash"
+	"github.com/Sudo-Ivan/website-archiver/internal/sitemap"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+	"github.com/Sudo-Ivan/website-archiver/internal/wordpress"
+	"golang.org/x/net/html"
+)
+
+// page is a fetched HTML page waiting for the parse stage.
+type page struct {
+	url   *url.URL
+	depth int
+	// name is where the page is saved in the capture's storage
+	name     string
+	body     []byte
+	resource manifest.Resource
+
+	listing bool
+	// doc, accessibility, and inlines carry a page whose stylesheets and
+	// scripts are being fetched over to its second parse pass
+	doc           *html.Node
+	accessibility []string
+	inlines       []*inline
+	// pending counts the inlines still being fetched
+	pending atomic.Int64
+}
+
+// inline is a stylesheet or script a page embeds.
+type inline struct {
+	node *html.Node
+	// key is the attribute of node linking to url: href for a stylesheet,
+	// src for a script
+	key     string
+	url     *url.URL
+	content string
+	err     error
+}
+
+// inlineFetch asks the fetch stage for an inline of a page.
+type inlineFetch struct {
+	ctx context.Context
+	p   *page
+	in  *inline
+}
+
+// startParsers starts the parse stage: a pool of workers that parse fetched
+// pages, queue the resources they link to, and rewrite them. Fetches hand
+// pages over through a bounded queue, so CPU-bound rewriting of large pages
+// runs beside the network transfers instead of inside them and a full queue
+// slows fetching down. The returned function stops the workers once the
+// crawl is over.
+func (c *crawler) startParsers(ctx context.Context) (stop func()) {
+	workers := c.cfg.ParseWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	c.pages = make(chan *page, c.cfg.ParseQueue)
+	for range workers {
+		go func() {
+			for p := range c.pages {
+				c.parse(ctx, p)
+			}
+		}()
+	}
+	return func() { close(c.pages) }
+}
+
+// enqueue hands a page to the parse stage, waiting while the queue is full.
+func (c *crawler) enqueue(ctx context.Context, p *page) error {
+	c.wg.Add(1)
+	c.hold(frontier.Item{URL: p.url.String(), Depth: p.depth})
+	select {
+	case c.pages <- p:
+		return nil
+	case <-ctx.Done():
+		c.wg.Done()
+		return fmt.Errorf("failed to queue %s for parsing: %w", p.url.String(), ctx.Err())
+	}
+}
+
+// startInlineFetchers starts the fetch workers downloading the stylesheets
+// and scripts pages embed, so slow origins hold up the fetch stage instead of
+// the parse workers. The returned function stops them once the crawl is over.
+func (c *crawler) startInlineFetchers(ctx context.Context) (stop func()) {
+	c.inlines = make(chan inlineFetch)
+	for range max(c.cfg.Concurrency, 1) {
+		go func() {
+			for job := range c.inlines {
+				job.in.content, job.in.err = c.downloadContent(job.ctx, job.in.url)
+				if job.p.pending.Add(-1) > 0 {
+					continue
+				}
+				select {
+				case c.pages <- job.p:
+				case <-ctx.Done():
+					c.parse(ctx, job.p)
+				}
+			}
+		}()
+	}
+	return func() { close(c.inlines) }
+}
+
+// parse runs the parse stage for one page and records it in the manifest. A
+// page embedding stylesheets or scripts leaves the stage while they are
+// fetched and comes back once they are for a second pass.
+func (c *crawler) parse(ctx context.Context, p *page) {
+	var err error
+	if p.doc == nil {
+		p.resource.SimHash = simhash.OfHTML(p.body).String()
+		if err = c.rewritePage(ctx, p); err == nil && len(p.inlines) > 0 {
+			c.fetchInlines(ctx, p)
+			return
+		}
+	} else {
+		err = c.embedInlines(ctx, p)
+	}
+	if err != nil {
+		err = fetcherr.Wrap(fetcherr.StageRewrite, p.url.String(), err)
+		slog.Warn("Failed to rewrite page", "error", err, "url", p.url.String(), fetcherr.Attr(err))
+	}
+	c.manifest.Add(p.resource)
+	c.release(ctx, p.url, nil)
+	c.wg.Done()
+}
+
+// fetchInlines hands the inlines of p to the fetch stage without waiting for
+// it; the last one fetched queues p for its second pass.
+func (c *crawler) fetchInlines(ctx context.Context, p *page) {
+	// Stylesheets and scripts inlined into the page are requested by it
+	ctx = referrer.WithSource(ctx, p.url.String(), true)
+	p.pending.Store(int64(len(p.inlines)))
+	go func() {
+		for _, in := range p.inlines {
+			c.inlines <- inlineFetch{ctx: ctx, p: p, in: in}
+		}
+	}()
+}
+
+// embedInlines is the second parse pass of a page: it embeds the stylesheets
+// and scripts that were fetched, links to those that were not like any other
+// resource, and writes the page.
+func (c *crawler) embedInlines(ctx context.Context, p *page) error {
+	ctx = referrer.WithSource(ctx, p.url.String(), true)
+	for _, in := range p.inlines {
+		n := in.node
+		i := slices.IndexFunc(n.Attr, func(a html.Attribute) bool { return a.Key == in.key })
+		if i < 0 {
+			continue
+		}
+		if in.err != nil {
+			c.rewriteLink(p, n, i, n.Attr[i].Val)
+			continue
+		}
+		content := in.content
+		if in.key == "href" {
+			// References in the stylesheet are relative to it, not to the page
+			content = string(c.followCSS(ctx, in.url, p.resource.Path, []byte(content), p.depth-1))
+			n.Data = "style"
+		}
+		n.Attr = slices.Delete(n.Attr, i, i+1)
+		n.FirstChild = &html.Node{Type: html.TextNode, Data: content}
+	}
+	return c.writePage(p, p.doc, p.accessibility)
+}
+
+// rewritePage parses a page for links, queues the resources they point at,
+// and rewrites the links to the local copies. The stylesheets and scripts to
+// embed are left to the second pass, collected in p.inlines, in which case
+// the page is not written yet.
+func (c *crawler) rewritePage(ctx context.Context, p *page) error {
+	doc, err := html.Parse(bytes.NewReader(p.body))
+	if err != nil {
+		return fmt.Errorf("failed to parse HTML for %s: %w", p.url.String(), err)
+	}
+	// Stylesheets and scripts inlined into the page are requested by it
+	ctx = referrer.WithSource(ctx, p.url.String(), true)
+
+	p.listing = isListing(doc)
+	if p.listing {
+		c.crawlListing(ctx, p.url, doc, p.depth)
+	}
+
+	p.resource.Title = docTitle(doc)
+	if c.sitemap != nil {
+		c.sitemap.AddPage(sitemap.Page{URL: p.url.String(), Path: p.resource.Path, Title: p.resource.Title, LastModified: p.resource.LastModified})
+	}
+	if c.a11y != nil {
+		c.a11y.Add(a11y.Page{URL: p.url.String(), Path: p.resource.Path, Issues: a11y.Check(doc)})
+	}
+	accessibility := a11y.Metadata(doc)
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if c.cfg.WordPress && n.Data == "link" && getAttr(n, "rel") == wordpress.APIRel {
+				if root := resolveURL(p.url, getAttr(n, "href")); root != nil {
+					c.setWordPressRoot(root.String())
+				}
+			}
+			if n.Data == "style" && n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				css := c.followCSS(ctx, p.url, p.resource.Path, []byte(n.FirstChild.Data), p.depth-1)
+				n.FirstChild.Data = string(css)
+			}
+			for i, a := range n.Attr {
+				var link string
+				switch a.Key {
+				case "style":
+					n.Attr[i].Val = string(c.followCSS(ctx, p.url, p.resource.Path, []byte(a.Val), p.depth-1))
+					continue
+				case "href":
+					if n.Data == "link" && getAttr(n, "rel") == "stylesheet" && !c.noCss && c.rewrites == nil {
+						if cssURL := resolveURL(p.url, a.Val); cssURL != nil && c.savedLocally(cssURL) {
+							p.inlines = append(p.inlines, &inline{node: n, key: a.Key, url: cssURL})
+							continue
+						}
+					}
+					link = a.Val
+				case "src":
+					if n.Data == "script" && !c.noJs && c.rewrites == nil {
+						if jsURL := resolveURL(p.url, a.Val); jsURL != nil && c.savedLocally(jsURL) {
+							p.inlines = append(p.inlines, &inline{node: n, key: a.Key, url: jsURL})
+							continue
+						}
+					}
+					link = a.Val
+				case "poster": // For video poster images
+					link = a.Val
+				case "srcset", "imagesrcset": // Responsive images in <img>, <picture> sources, and preloads
+					if srcset := c.rewriteSrcset(ctx, p, a.Val); c.rewrites != nil {
+						c.rewrites.Add(p.resource.Path, a.Val, srcset)
+					} else {
+						n.Attr[i].Val = srcset
+					}
+					continue
+				default:
+					continue
+				}
+
+				c.rewriteLink(p, n, i, link)
+			}
+			n.Attr = dropBlankAttrs(n.Attr)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	if len(p.inlines) > 0 {
+		p.doc, p.accessibility = doc, accessibility
+		return nil
+	}
+	return c.writePage(p, doc, accessibility)
+}
+
+// rewriteLink queues the resource the attribute of n at i links to and
+// rewrites the link to its local copy.
+func (c *crawler) rewriteLink(p *page, n *html.Node, i int, link string) {
+	if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(link, "mailto:") || strings.HasPrefix(link, "tel:") {
+		return
+	}
+
+	resolvedURL := resolveURL(p.url, link)
+	if resolvedURL != nil && isNavigation(n) && (!c.scope.InScope(resolvedURL) || c.spansTo(resolvedURL)) {
+		// Leave links out of the preset's scope, and to pages on the
+		// hosts only requisites are fetched from, pointing at the live site
+		return
+	}
+	if resolvedURL != nil && resolvedURL.String() != p.url.String() {
+		childDepth := p.depth - 1
+		if c.markOffsite(resolvedURL) {
+			childDepth = 0
+		}
+		if !p.listing {
+			c.spawn(p.url, resolvedURL, childDepth, !isNavigation(n))
+		}
+		if c.sitemap != nil && isNavigation(n) {
+			c.sitemap.AddLink(p.resource.Path, c.localPath(resolvedURL, true))
+		}
+
+		// Convert links in the HTML to paths relative to the page.
+		// Links to a directory from anchors and frames, and
+		// subdirectories of a listing, lead to its index page.
+		isPage := strings.Contains(link, ".html") || strings.Contains(link, ".htm") || isNavigation(n) || n.Data == "iframe" || n.Data == "frame" || (p.listing && strings.HasSuffix(resolvedURL.Path, "/"))
+		newLink := linkTo(p.resource.Path, c.localPath(resolvedURL, isPage))
+		if c.rewrites != nil {
+			c.rewrites.Add(p.resource.Path, link, newLink)
+		} else {
+			n.Attr[i].Val = newLink
+		}
+	}
+}
+
+// writePage writes the rewritten doc of a page.
+func (c *crawler) writePage(p *page, doc *html.Node, accessibility []string) error {
+	// Re-write the HTML with updated links. Fidelity mode keeps the
+	// original bytes written when the page was fetched.
+	if c.rewrites == nil {
+		if c.cfg.ArchivedAtMeta {
+			setArchivedAt(doc, p.resource.FetchedAt)
+		}
+		if c.cfg.NoIndex || c.cfg.Canonical {
+			setSearchHints(doc, originalURL(p.url), c.cfg.NoIndex, c.cfg.Canonical)
+		}
+		if c.cfg.Banner {
+			if err := addBanner(doc, p.url, p.resource.FetchedAt); err != nil {
+				return err
+			}
+		}
+		var buf strings.Builder
+		if err := html.Render(&buf, doc); err != nil {
+			return fmt.Errorf("failed to render HTML with updated links for %s: %w", p.name, err)
+		}
+		verifyAccessibility(p.url, accessibility, buf.String())
+		if err := storage.WriteFile(c.storage, p.name, []byte(buf.String())); err != nil {
+			return fmt.Errorf("failed to write updated HTML to %s: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+// docTitle returns the text of the first <title> element of a document.
+func docTitle(doc *html.Node) string {
+	var title string
+	var find func(*html.Node) bool
+	find = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "title" {
+			if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				title = strings.TrimSpace(n.FirstChild.Data)
+			}
+			return true
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if find(child) {
+				return true
+			}
+		}
+		return false
+	}
+	find(doc)
+	return title
+}
+
+// archivedAtMeta names the meta element recording when a page was fetched.
+const archivedAtMeta = "archived-at"
+
+// setArchivedAt adds <meta name="archived-at"> with the fetch time to the
+// head of a page, replacing one left by an earlier capture.
+func setArchivedAt(doc *html.Node, fetchedAt time.Time) {
+	head := findElement(doc, "head")
+	if head == nil {
+		return
+	}
+	for child := head.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "meta" && getAttr(child, "name") == archivedAtMeta {
+			head.RemoveChild(child)
+			break
+		}
+	}
+	meta := &html.Node{Type: html.ElementNode, Data: "meta", Attr: []html.Attribute{
+		{Key: "name", Val: archivedAtMeta},
+		{Key: "content", Val: fetchedAt.UTC().Format(time.RFC3339Nano)},
+	}}
+	head.InsertBefore(meta, head.FirstChild)
+}
+
+// setSearchHints adds <meta name="robots" content="noindex"> and a canonical
+// link to the original URL to the head of a page, so a republished mirror does
+// not compete with the live site in search engines. The page's own robots meta
+// and canonical link are replaced; the latter points at the local copy after
+// link conversion anyway.
+func setSearchHints(doc *html.Node, original string, noIndex, canonical bool) {
+	head := findElement(doc, "head")
+	if head == nil {
+		return
+	}
+	for child := head.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.ElementNode {
+			robots := child.Data == "meta" && strings.EqualFold(getAttr(child, "name"), "robots")
+			link := child.Data == "link" && hasToken(getAttr(child, "rel"), "canonical")
+			if (noIndex && robots) || (canonical && link) {
+				head.RemoveChild(child)
+			}
+		}
+		child = next
+	}
+	if canonical {
+		head.InsertBefore(&html.Node{Type: html.ElementNode, Data: "link", Attr: []html.Attribute{
+			{Key: "rel", Val: "canonical"},
+			{Key: "href", Val: original},
+		}}, head.FirstChild)
+	}
+	if noIndex {
+		head.InsertBefore(&html.Node{Type: html.ElementNode, Data: "meta", Attr: []html.Attribute{
+			{Key: "name", Val: "robots"},
+			{Key: "content", Val: "noindex"},
+		}}, head.FirstChild)
+	}
+}
+
+// hasToken reports whether the space-separated list value contains token,
+// ignoring case, as in rel attributes.
+func hasToken(value, token string) bool {
+	for _, t := range strings.Fields(value) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/replay"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+)
+
+// redirectChain returns the hops of the redirects that led to resp, from
+// the requested URL on, or nil when the request was not redirected.
+func redirectChain(resp *http.Response) []manifest.Redirect {
+	var hops []manifest.Redirect
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		hops = append(hops, manifest.Redirect{URL: req.Response.Request.URL.String(), Status: req.Response.StatusCode})
+	}
+	slices.Reverse(hops)
+	return hops
+}
+
+// redirectKey is the key of u in the redirects of a crawl; fragments never
+// reach the server, so they do not change where a URL leads.
+func redirectKey(u *url.URL) string {
+	v := *u
+	v.Fragment, v.RawFragment = "", ""
+	return v.String()
+}
+
+// recordRedirects remembers that the in-site hops lead to final, so links to
+// them point at the file of final.
+func (c *crawler) recordRedirects(hops []manifest.Redirect, final *url.URL) {
+	c.redirectsMu.Lock()
+	defer c.redirectsMu.Unlock()
+	for _, hop := range hops {
+		u, err := url.Parse(hop.URL)
+		if err != nil || !c.inSite(u.Hostname()) || redirectKey(u) == redirectKey(final) {
+			continue
+		}
+		c.redirects[redirectKey(u)] = final
+	}
+}
+
+// redirected returns where u leads when it is known to redirect, and u
+// otherwise.
+func (c *crawler) redirected(u *url.URL) *url.URL {
+	c.redirectsMu.Lock()
+	defer c.redirectsMu.Unlock()
+	// Chains recorded by separate fetches are followed to their end; the
+	// bound stops redirect loops
+	for range len(c.redirects) {
+		next, ok := c.redirects[redirectKey(u)]
+		if !ok {
+			break
+		}
+		u = next
+	}
+	return u
+}
+
+// redirectMapping returns the local paths of redirect hops, which links to
+// them were written with, mapped to the paths of where the hops lead.
+func (c *crawler) redirectMapping() map[string]string {
+	c.redirectsMu.Lock()
+	hops := make([]*url.URL, 0, len(c.redirects))
+	for raw := range c.redirects {
+		if u, err := url.Parse(raw); err == nil {
+			hops = append(hops, u)
+		}
+	}
+	c.redirectsMu.Unlock()
+
+	mapping := make(map[string]string)
+	for _, hop := range hops {
+		final := c.redirected(hop)
+		for _, isPage := range []bool{true, false} {
+			from, to := c.pathFor(hop, isPage), c.pathFor(final, isPage)
+			// A hop without a directory path is the same file both ways, and
+			// its links are most likely pages
+			if _, ok := mapping[from]; from != to && !ok {
+				mapping[from] = to
+			}
+		}
+	}
+	return mapping
+}
+
+// relinks returns the links of the page at docPath to the paths of mapping,
+// mapped to the links to where they lead.
+func relinks(docPath string, mapping map[string]string) map[string]string {
+	links := make(map[string]string, len(mapping))
+	for from, to := range mapping {
+		links[linkTo(docPath, from)] = linkTo(docPath, to)
+	}
+	return links
+}
+
+// relinkRedirects points the links of saved pages that were rewritten to the
+// path of a redirect hop, before the redirect was known, at the path of
+// where it leads. In fidelity mode the recorded rewrites are changed instead.
+func (c *crawler) relinkRedirects() error {
+	mapping := c.redirectMapping()
+	if len(mapping) == 0 {
+		return nil
+	}
+	if c.rewrites != nil {
+		c.rewrites.Retarget(func(pagePath string) map[string]string {
+			return relinks(pagePath, mapping)
+		})
+		return nil
+	}
+	for _, r := range c.manifest.Resources {
+		if !isHTMLType(r.ContentType) || r.Path == "" {
+			continue
+		}
+		data, err := storage.ReadFile(c.storage, r.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s to relink redirects: %w", r.Path, err)
+		}
+		links := relinks(r.Path, mapping)
+		if !linksAny(data, links) {
+			continue
+		}
+		relinked, err := replay.Apply(data, links)
+		if err != nil {
+			slog.Debug("Failed to relink redirects", "error", err, "path", r.Path)
+			continue
+		}
+		if err := storage.WriteFile(c.storage, r.Path, relinked); err != nil {
+			return fmt.Errorf("failed to relink redirects in %s: %w", r.Path, err)
+		}
+	}
+	return nil
+}
+
+// linksAny reports whether data may contain a link to a path of mapping.
+func linksAny(data []byte, mapping map[string]string) bool {
+	for from := range mapping {
+		if bytes.Contains(data, []byte(from)) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeRedirectStubs saves a page at the path of each in-site hop that
+// forwards to final, so the hops still open offline, e.g. a seed that
+// redirected to a language directory or links no rewrite reaches.
+func (c *crawler) writeRedirectStubs(hops []manifest.Redirect, final *url.URL) {
+	target := filepath.ToSlash(c.pathFor(final, true))
+	for _, hop := range hops {
+		u, err := url.Parse(hop.URL)
+		if err != nil || !c.inSite(u.Hostname()) {
+			continue
+		}
+		stub := filepath.ToSlash(c.pathFor(u, true))
+		if stub == "" || stub == target {
+			continue
+		}
+		href := html.EscapeString(linkTo(stub, target))
+		page := fmt.Sprintf("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><meta http-equiv=\"refresh\" content=\"0; url=%s\"><title>Redirect</title></head>\n<body><a href=\"%s\">%s</a></body></html>\n", href, href, html.EscapeString(path.Base(target)))
+		if err := storage.WriteFile(c.storage, stub, []byte(page)); err != nil {
+			slog.Debug("Failed to write redirect page", "error", err, "path", stub)
+			continue
+		}
+		c.manifest.AddStub(manifest.Stub{Path: stub, Target: target})
+	}
+}
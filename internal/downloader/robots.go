@@ -0,0 +1,262 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/fetcherr"
+	"github.com/Sudo-Ivan/website-archiver/internal/retry"
+)
+
+const (
+	// robotsAgent is the product token matched against User-agent lines.
+	robotsAgent = "website-archiver"
+	// robotsSizeLimit is the part of a robots.txt that is parsed, as in RFC 9309.
+	robotsSizeLimit = 500 << 10
+	// maxCrawlDelay caps Crawl-delay, so a hostile value cannot stall the crawl.
+	maxCrawlDelay = time.Minute
+)
+
+// robotsRule is an Allow or Disallow line.
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsRules are the rules of the group of a robots.txt that applies to us.
+type robotsRules struct {
+	rules []robotsRule
+	delay time.Duration
+}
+
+// parseRobots reads the group of a robots.txt for agent: the groups naming
+// it, or the "*" groups when none do. Groups naming the same agent are merged.
+func parseRobots(data []byte, agent string) robotsRules {
+	var own, star robotsRules
+	var ownFound bool
+	var inOwn, inStar, inRules bool
+
+	scanner := bufio.NewScanner(io.LimitReader(bytes.NewReader(data), robotsSizeLimit))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if inRules {
+				// A User-agent line after rules starts a new group
+				inOwn, inStar, inRules = false, false, false
+			}
+			name := strings.ToLower(value)
+			if name == "*" {
+				inStar = true
+			} else if name != "" && strings.Contains(agent, name) {
+				inOwn, ownFound = true, true
+			}
+			continue
+		}
+
+		var target []*robotsRules
+		if inOwn {
+			target = append(target, &own)
+		}
+		if inStar {
+			target = append(target, &star)
+		}
+		switch key {
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				// An empty Disallow allows everything and adds no rule
+				continue
+			}
+			for _, t := range target {
+				t.rules = append(t.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			inRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			for _, t := range target {
+				t.delay = min(time.Duration(seconds*float64(time.Second)), maxCrawlDelay)
+			}
+		}
+	}
+	if ownFound {
+		return own
+	}
+	return star
+}
+
+// allowed reports whether path (with its query) may be fetched. The longest
+// matching rule decides, and Allow wins a tie, as in RFC 9309.
+func (r robotsRules) allowed(path string) bool {
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > best || (n == best && rule.allow) {
+			best, allow = n, rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsMatch matches a rule pattern against a path. "*" matches any
+// sequence of characters and a trailing "$" anchors the pattern at the end.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	if len(parts) == 1 {
+		return !anchored || len(path) == len(parts[0])
+	}
+	pos := len(parts[0])
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		at := strings.Index(path[pos:], part)
+		if at < 0 {
+			return false
+		}
+		pos += at + len(part)
+	}
+	if anchored {
+		return strings.HasSuffix(path, last) && len(path)-len(last) >= pos
+	}
+	return strings.Contains(path[pos:], last)
+}
+
+// robotsHost is the cached robots.txt of a host and the time its next
+// request may start.
+type robotsHost struct {
+	once  sync.Once
+	rules robotsRules
+	// disallowAll is set when robots.txt could not be reached
+	disallowAll bool
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// robotsCache holds the robots.txt of every host the crawl touches.
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*robotsHost
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{hosts: make(map[string]*robotsHost)}
+}
+
+// robotsHost returns the cache entry of the host of u, fetching its
+// robots.txt the first time.
+func (c *crawler) robotsHost(ctx context.Context, u *url.URL) *robotsHost {
+	key := u.Scheme + "://" + u.Host
+	c.robots.mu.Lock()
+	h, ok := c.robots.hosts[key]
+	if !ok {
+		h = &robotsHost{}
+		c.robots.hosts[key] = h
+	}
+	c.robots.mu.Unlock()
+
+	h.once.Do(func() {
+		data, err := c.fetchRobots(ctx, key+"/robots.txt")
+		if err != nil {
+			slog.Warn("robots.txt unreachable, skipping the host", "error", err, "host", u.Host, fetcherr.Attr(err))
+			h.disallowAll = true
+			return
+		}
+		h.rules = parseRobots(data, robotsAgent)
+	})
+	return h
+}
+
+// fetchRobots fetches a robots.txt. A missing one (4xx) allows everything; an
+// unreachable one (5xx or a network error) is an error, which RFC 9309 asks
+// crawlers to treat as disallowing everything.
+func (c *crawler) fetchRobots(ctx context.Context, robotsURL string) ([]byte, error) {
+	ctx, attempts := retry.TrackAttempts(ctx)
+	fail := func(status int, err error) error {
+		return &fetcherr.FetchError{URL: robotsURL, Stage: fetcherr.StageRobots, Status: status, Attempt: attempts(), Err: err}
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return nil, fail(0, err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fail(0, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fail(resp.StatusCode, nil)
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, robotsSizeLimit))
+	if err != nil {
+		return nil, fail(resp.StatusCode, err)
+	}
+	return data, nil
+}
+
+// polite reports whether robots.txt allows fetching u and, if so, waits for
+// the Crawl-delay of its host. It always allows u without --respect-robots.
+func (c *crawler) polite(ctx context.Context, u *url.URL) bool {
+	if c.robots == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return true
+	}
+	h := c.robotsHost(ctx, u)
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if h.disallowAll || !h.rules.allowed(path) {
+		slog.Info("Skipping URL disallowed by robots.txt", "url", u.String())
+		return false
+	}
+	if h.rules.delay <= 0 {
+		return true
+	}
+
+	h.mu.Lock()
+	now := time.Now()
+	start := now
+	if h.next.After(now) {
+		start = h.next
+	}
+	h.next = start.Add(h.rules.delay)
+	h.mu.Unlock()
+	select {
+	case <-time.After(start.Sub(now)):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"net/url"
+	"strings"
+)
+
+// spansTo reports whether u is on another host whose requisites are fetched
+// with --span-hosts-assets: any host, or only those of --asset-host and
+// their subdomains when some are given.
+func (c *crawler) spansTo(u *url.URL) bool {
+	if !c.cfg.SpanHostsAssets || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := strings.ToLower(c.remap.URL(u).Hostname())
+	if host == "" || c.inSite(host) {
+		return false
+	}
+	if len(c.cfg.AssetHosts) == 0 {
+		return true
+	}
+	for _, allowed := range c.cfg.AssetHosts {
+		allowed = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(allowed), "."))
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// markSpanned records a requisite on another host that --span-hosts-assets
+// fetches. It reports whether u is one.
+func (c *crawler) markSpanned(u *url.URL, requisite bool) bool {
+	if !requisite || !c.spansTo(u) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spanned[u.String()] = true
+	return true
+}
+
+// isSpanned reports whether u was recorded by markSpanned.
+func (c *crawler) isSpanned(u *url.URL) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spanned[u.String()]
+}
+
+// savedLocally reports whether a requisite at u is saved with the capture,
+// so links to it are rewritten to the local copy.
+func (c *crawler) savedLocally(u *url.URL) bool {
+	return c.inSite(c.remap.URL(u).Hostname()) || c.spansTo(u)
+}
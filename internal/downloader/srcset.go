@@ -0,0 +1,133 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+)
+
+// srcCandidate is an image candidate of a srcset attribute.
+type srcCandidate struct {
+	URL string
+	// Descriptor is the width ("640w") or density ("2x") descriptor, if any.
+	Descriptor string
+}
+
+// parseSrcset splits a srcset attribute into its candidates following the
+// HTML parsing rules, so URLs containing commas are kept whole.
+func parseSrcset(value string) []srcCandidate {
+	var candidates []srcCandidate
+	i := 0
+	for {
+		for i < len(value) && (isHTMLSpace(value[i]) || value[i] == ',') {
+			i++
+		}
+		if i >= len(value) {
+			return candidates
+		}
+		start := i
+		for i < len(value) && !isHTMLSpace(value[i]) {
+			i++
+		}
+		u := value[start:i]
+		if trimmed := strings.TrimRight(u, ","); trimmed != u {
+			// A URL ending in commas has no descriptors
+			candidates = append(candidates, srcCandidate{URL: trimmed})
+			continue
+		}
+		start = i
+		depth := 0
+		for i < len(value) && (value[i] != ',' || depth > 0) {
+			switch value[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			i++
+		}
+		candidates = append(candidates, srcCandidate{URL: u, Descriptor: strings.Join(strings.Fields(value[start:i]), " ")})
+	}
+}
+
+// formatSrcset joins candidates into a srcset attribute value.
+func formatSrcset(candidates []srcCandidate) string {
+	parts := make([]string, len(candidates))
+	for i, cand := range candidates {
+		parts[i] = cand.URL
+		if cand.Descriptor != "" {
+			parts[i] += " " + cand.Descriptor
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// size returns the width or density a candidate is offered at. Width
+// descriptors and density descriptors are not mixed in a valid srcset, so
+// comparing them only within one attribute is sound. No descriptor means 1x.
+func (s srcCandidate) size() float64 {
+	for _, d := range strings.Fields(s.Descriptor) {
+		if len(d) < 2 {
+			continue
+		}
+		switch d[len(d)-1] {
+		case 'w', 'x':
+			if v, err := strconv.ParseFloat(d[:len(d)-1], 64); err == nil {
+				return v
+			}
+		}
+	}
+	return 1
+}
+
+// largestCandidate returns the candidate with the largest width or density,
+// preferring the ones saved locally.
+func (c *crawler) largestCandidate(base *url.URL, candidates []srcCandidate) srcCandidate {
+	best, bestLocal := candidates[0], false
+	for _, cand := range candidates {
+		u := resolveURL(base, cand.URL)
+		local := u != nil && c.savedLocally(u)
+		if (local && !bestLocal) || (local == bestLocal && cand.size() > best.size()) {
+			best, bestLocal = cand, local
+		}
+	}
+	return best
+}
+
+// rewriteSrcset queues the candidates of a srcset attribute of the page at
+// p for download and returns the attribute pointing at the local copies. With
+// config.SrcsetLargest only the largest candidate is fetched and kept.
+// Candidates not saved locally keep pointing at the live site.
+func (c *crawler) rewriteSrcset(ctx context.Context, p *page, value string) string {
+	candidates := parseSrcset(value)
+	if len(candidates) == 0 {
+		return value
+	}
+	if c.cfg.Srcset == config.SrcsetLargest {
+		candidates = []srcCandidate{c.largestCandidate(p.url, candidates)}
+	}
+	for i, cand := range candidates {
+		candURL := resolveURL(p.url, cand.URL)
+		if candURL == nil || (candURL.Scheme != "http" && candURL.Scheme != "https") {
+			continue
+		}
+		c.spawn(p.url, candURL, p.depth-1, true)
+		if !c.savedLocally(candURL) {
+			continue
+		}
+		// Commas would split the candidate when the attribute is read
+		candidates[i].URL = strings.ReplaceAll(linkTo(p.resource.Path, c.localPath(candURL, false)), ",", "%2C")
+	}
+	return formatSrcset(candidates)
+}
+
+// isHTMLSpace reports whether b is ASCII whitespace as defined by HTML.
+func isHTMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\f' || b == '\r'
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/frontier"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+)
+
+const (
+	// StateFile is the crawl state kept in the metadata directory of a
+	// capture while its crawl runs.
+	StateFile = manifest.Dir + "/crawl-state.json"
+	// stateInterval is how often the crawl state is saved.
+	stateInterval = 10 * time.Second
+)
+
+// Statuses of the URLs of a crawl state.
+const (
+	StatusDone   = "done"
+	StatusFailed = "failed"
+)
+
+// ErrInterrupted is returned when a crawl stopped before it was finished. The
+// capture is kept along with its crawl state, which --resume picks up.
+var ErrInterrupted = errors.New("crawl interrupted")
+
+// CrawlState records how far a crawl got, so an interrupted crawl can
+// continue in the same capture directory instead of starting from scratch.
+type CrawlState struct {
+	Seed      string    `json:"seed"`
+	Extra     []string  `json:"extra,omitempty"`
+	Depth     int       `json:"depth"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Pending are the URLs queued or being fetched, in queue order.
+	Pending []frontier.Item `json:"pending"`
+	// URLs are the finished URLs. Together with Pending they are the
+	// visited set of the crawl.
+	URLs map[string]URLState `json:"urls"`
+}
+
+// URLState is the outcome of a finished URL.
+type URLState struct {
+	Depth  int    `json:"depth"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// LoadState reads the crawl state of the capture in dir from the storage
+// cfg writes it to.
+func LoadState(dir string, cfg *config.Config) (*CrawlState, error) {
+	s, err := OpenStorage(dir, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return loadState(s)
+}
+
+// loadState reads the crawl state of the capture stored in s.
+func loadState(s storage.Storage) (*CrawlState, error) {
+	data, err := storage.ReadFile(s, StateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crawl state: %w", err)
+	}
+	st := &CrawlState{}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse crawl state: %w", err)
+	}
+	return st, nil
+}
+
+// crawlTracker follows the URLs of a crawl for its state file. A URL stays
+// pending until its fetch and, for pages, its parse stage are over, so the
+// links of a page that was saved but not parsed are not lost.
+type crawlTracker struct {
+	mu      sync.Mutex
+	seq     uint64
+	pending map[string]*trackedItem
+	urls    map[string]URLState
+	// saved is set once a state file was written
+	saved bool
+}
+
+type trackedItem struct {
+	item frontier.Item
+	seq  uint64
+	refs int
+	err  string
+}
+
+func newCrawlTracker() *crawlTracker {
+	return &crawlTracker{pending: make(map[string]*trackedItem), urls: make(map[string]URLState)}
+}
+
+// hold marks the URL of item as having unfinished work.
+func (c *crawler) hold(item frontier.Item) {
+	t := c.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.pending[item.URL]; ok {
+		p.refs++
+		p.item.Depth = max(p.item.Depth, item.Depth)
+		return
+	}
+	t.seq++
+	t.pending[item.URL] = &trackedItem{item: item, seq: t.seq, refs: 1}
+}
+
+// release ends one piece of work on u; the URL is finished with the last.
+// Work cut short by cancellation is not released, so it is redone on resume.
+func (c *crawler) release(ctx context.Context, u *url.URL, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	t := c.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pending[u.String()]
+	if !ok {
+		return
+	}
+	if err != nil && p.err == "" {
+		p.err = err.Error()
+	}
+	if p.refs--; p.refs > 0 {
+		return
+	}
+	delete(t.pending, u.String())
+	state := URLState{Depth: p.item.Depth, Status: StatusDone}
+	if p.err != "" {
+		state.Status, state.Error = StatusFailed, p.err
+	}
+	t.urls[u.String()] = state
+}
+
+// snapshot returns the current crawl state.
+func (c *crawler) snapshot(seed string, extra []string, depth int) CrawlState {
+	t := c.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := CrawlState{Seed: seed, Extra: extra, Depth: depth, UpdatedAt: time.Now().UTC(), URLs: make(map[string]URLState, len(t.urls))}
+	items := make([]*trackedItem, 0, len(t.pending))
+	for _, p := range t.pending {
+		items = append(items, p)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].seq < items[j].seq })
+	for _, p := range items {
+		st.Pending = append(st.Pending, p.item)
+	}
+	for u, s := range t.urls {
+		st.URLs[u] = s
+	}
+	return st
+}
+
+// saveState writes the crawl state and, after it, the manifest, which then
+// lists every resource the state counts as finished.
+func (c *crawler) saveState(seed string, extra []string, depth int) (CrawlState, error) {
+	st := c.snapshot(seed, extra, depth)
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return st, fmt.Errorf("failed to encode crawl state: %w", err)
+	}
+	if err := storage.WriteFile(c.storage, StateFile, data); err != nil {
+		return st, fmt.Errorf("failed to write crawl state: %w", err)
+	}
+	c.tracker.mu.Lock()
+	c.tracker.saved = true
+	c.tracker.mu.Unlock()
+	return st, c.manifest.Save(c.storage)
+}
+
+// startStateSaver saves the crawl state periodically, so even a crawl that is
+// killed can be resumed from its last save. The returned function stops it.
+func (c *crawler) startStateSaver(seed string, extra []string, depth int) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(stateInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := c.saveState(seed, extra, depth); err != nil {
+					slog.Warn("Failed to save crawl state", "error", err, "url", seed)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// finishState saves the state of an interrupted crawl and returns
+// ErrInterrupted, or removes the state of a crawl that got through.
+func (c *crawler) finishState(ctx context.Context, seed string, extra []string, depth int) error {
+	if ctx.Err() != nil {
+		st, err := c.saveState(seed, extra, depth)
+		if err != nil {
+			return err
+		}
+		slog.Warn("Crawl interrupted, its state was saved", "url", seed, "finished", len(st.URLs), "pending", len(st.Pending), "resume", "--resume "+c.outputDir)
+		return fmt.Errorf("%w: %w", ErrInterrupted, ctx.Err())
+	}
+	c.tracker.mu.Lock()
+	saved := c.tracker.saved
+	c.tracker.mu.Unlock()
+	if !saved && c.cfg.Resume == "" {
+		return nil
+	}
+	if err := c.storage.Remove(StateFile); err != nil {
+		return fmt.Errorf("failed to remove crawl state: %w", err)
+	}
+	return nil
+}
+
+// restoreState prepares a crawl resuming the capture in outputDir: the saved
+// resources are kept in the manifest, finished URLs are not fetched again,
+// and the pending ones are queued.
+func (c *crawler) restoreState(st *CrawlState) error {
+	prev, err := manifest.Read(c.storage)
+	if err != nil {
+		return err
+	}
+	c.manifest = prev
+	c.annotate()
+	// Keep the names the crawl gave to paths differing only by case, and
+	// the redirects it followed
+	for _, r := range prev.Resources {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			continue
+		}
+		if r.Path != "" {
+			c.cases.Claim(c.sourcePath(u, isHTMLType(r.ContentType)), filepath.ToSlash(r.Path))
+		}
+		if len(r.Redirects) > 0 && c.inSite(u.Hostname()) {
+			c.recordRedirects(r.Redirects, u)
+		}
+	}
+	c.tracker.mu.Lock()
+	for raw, s := range st.URLs {
+		c.tracker.urls[raw] = s
+	}
+	c.tracker.mu.Unlock()
+	for raw, s := range st.URLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		c.markVisited(u, s.Depth)
+	}
+	slog.Info("Resuming interrupted crawl", "url", st.Seed, "finished", len(st.URLs), "pending", len(st.Pending))
+	for _, item := range st.Pending {
+		if u, err := url.Parse(item.URL); err == nil {
+			c.push(u, item.Depth, item.Requisite, item.Referrer)
+		}
+	}
+	return nil
+}
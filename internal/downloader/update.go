@@ -0,0 +1,81 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/cssdoc"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/visited"
+	"github.com/Sudo-Ivan/website-archiver/internal/xmldoc"
+)
+
+// loadPrevious prepares a crawl updating the capture in outputDir: the
+// manifest keeps its tags and note, and the resources saved with an ETag or
+// a Last-Modified date are revalidated instead of fetched again. Pages,
+// feeds, and stylesheets are always fetched again so their links are
+// followed.
+func (c *crawler) loadPrevious() error {
+	prev, err := manifest.Load(c.outputDir)
+	if err != nil {
+		return err
+	}
+	c.manifest.SetAnnotations(prev.Tags, prev.Note)
+	c.annotate()
+	c.previous = make(map[string]manifest.Resource)
+	for _, r := range prev.Resources {
+		if r.Status != http.StatusOK || r.Patched || len(r.Chunks) > 0 || (r.ETag == "" && r.LastModified == "") {
+			continue
+		}
+		if isHTMLType(r.ContentType) || xmldoc.IsXML(r.ContentType) || cssdoc.IsCSS(r.ContentType) {
+			continue
+		}
+		c.previous[r.URL] = r
+	}
+	// The visited set of the last crawl would skip every URL it saw
+	if err := os.RemoveAll(filepath.Join(c.outputDir, visited.DirName)); err != nil {
+		return err
+	}
+	slog.Info("Updating capture", "dir", c.outputDir, "saved", len(prev.Resources), "revalidated", len(c.previous))
+	return nil
+}
+
+// previousCopy returns the record of the resource at u in the capture being
+// updated, if its file is still there.
+func (c *crawler) previousCopy(u string) (manifest.Resource, bool) {
+	r, ok := c.previous[u]
+	if !ok {
+		return manifest.Resource{}, false
+	}
+	if _, err := os.Stat(filepath.Join(c.outputDir, filepath.FromSlash(r.Path))); err != nil {
+		return manifest.Resource{}, false
+	}
+	return r, true
+}
+
+// setConditional asks the server to answer 304 Not Modified when the
+// resource has not changed since r was saved.
+func setConditional(req *http.Request, r manifest.Resource) {
+	if r.ETag != "" {
+		req.Header.Set("If-None-Match", r.ETag)
+	}
+	if r.LastModified != "" {
+		req.Header.Set("If-Modified-Since", r.LastModified)
+	}
+}
+
+// keepUnchanged records a resource the server reported unchanged with its
+// file from the last crawl.
+func (c *crawler) keepUnchanged(r manifest.Resource) {
+	c.manifest.Add(r)
+	c.mu.Lock()
+	c.unchanged++
+	c.unchangedBytes += r.Size
+	c.mu.Unlock()
+	slog.Debug("Resource unchanged", "url", r.URL, "path", r.Path)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/downloader"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/store"
+)
+
+// TestUpdateLinkedCapture updates a capture whose files are hardlinked to the
+// store and to another snapshot, which must keep their content.
+func TestUpdateLinkedCapture(t *testing.T) {
+	var version atomic.Int32
+	version.Store(1)
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><head><title>Page</title></head><body><p>Version %d</p></body></html>", version.Load())
+	}))
+	defer site.Close()
+
+	root := t.TempDir()
+	cfg := config.New()
+	cfg.OutputDir = root
+	cfg.RetryAttempts = 1
+	first, second := filepath.Join(root, "first"), filepath.Join(root, "second")
+	for _, dir := range []string{first, second} {
+		if err := downloader.Download(context.Background(), site.URL+"/", 0, dir, false, false, cfg); err != nil {
+			t.Fatalf("download into %s: %v", dir, err)
+		}
+	}
+	stats, err := store.Link(root, root, cfg.DirPerms, cfg.FilePerms)
+	if err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	if stats.Shared == 0 {
+		t.Fatalf("no file of the snapshots was shared: %+v", stats)
+	}
+	before, err := os.ReadFile(filepath.Join(second, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version.Store(2)
+	update := *cfg
+	update.Update = first
+	if err := downloader.Download(context.Background(), site.URL+"/", 0, first, false, false, &update); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	updated, err := os.ReadFile(filepath.Join(first, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(updated) == string(before) {
+		t.Fatalf("updated page kept its old content: %s", updated)
+	}
+	after, err := os.ReadFile(filepath.Join(second, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("update rewrote the other snapshot:\nbefore %s\nafter  %s", before, after)
+	}
+
+	m, err := manifest.Load(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range m.Resources {
+		if r.Linked == "" {
+			continue
+		}
+		data, err := os.ReadFile(store.Open(root).Path(r.Linked))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != r.Linked {
+			t.Errorf("store object %s no longer matches its digest", r.Linked)
+		}
+	}
+}
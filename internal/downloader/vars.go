@@ -0,0 +1,30 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package downloader
+
+import (
+	"expvar"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/progress"
+)
+
+// crawlVars counts the work of all crawls of the process for the diagnostics
+// endpoint: crawls running and URLs discovered, started, finished, and
+// failed, and bytes saved.
+var crawlVars = expvar.NewMap("crawl")
+
+// count records a progress event in crawlVars.
+func count(e progress.Event) {
+	switch e.Type {
+	case progress.Discovered:
+		crawlVars.Add("discovered", 1)
+	case progress.Started:
+		crawlVars.Add("started", 1)
+	case progress.Finished:
+		crawlVars.Add("finished", 1)
+		crawlVars.Add("bytes", e.Bytes)
+	case progress.Failed:
+		crawlVars.Add("failed", 1)
+	}
+}
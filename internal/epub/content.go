@@ -0,0 +1,469 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package epub
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/catalog"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+// minArticleText is the paragraph text a page needs to get a chapter in a
+// readable book, and minParagraph the length of a paragraph that counts.
+const (
+	minArticleText = 400
+	minParagraph   = 25
+)
+
+// imageTypes are the media types of the images books may hold, by extension.
+var imageTypes = map[string]string{
+	".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".png": "image/png",
+	".gif": "image/gif", ".webp": "image/webp", ".svg": "image/svg+xml",
+}
+
+// dropped elements are left out of chapters with their content.
+var dropped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Iframe: true, atom.Frame: true, atom.Frameset: true, atom.Object: true,
+	atom.Embed: true, atom.Video: true, atom.Audio: true, atom.Canvas: true,
+	atom.Form: true, atom.Input: true, atom.Button: true, atom.Select: true,
+	atom.Textarea: true, atom.Link: true, atom.Meta: true, atom.Base: true,
+	atom.Dialog: true, atom.Source: true, atom.Track: true,
+}
+
+// unwrapped elements are replaced by their content.
+var unwrapped = map[atom.Atom]bool{
+	atom.Picture: true, atom.Font: true, atom.Center: true, atom.Marquee: true, atom.Blink: true,
+}
+
+// droppedAttrs make no sense in a book.
+var droppedAttrs = map[string]bool{
+	"srcset": true, "sizes": true, "loading": true, "decoding": true, "target": true,
+	"ping": true, "referrerpolicy": true, "crossorigin": true, "integrity": true, "xmlns": true,
+}
+
+var (
+	xmlName = regexp.MustCompile(`^[A-Za-z_][-A-Za-z0-9_.]*$`)
+	// clutter and content tell page furniture from the text by class and id
+	clutter = regexp.MustCompile(`(?i)(^|[-_ ])(comments?|share|sharing|social|sidebar|related|promo|advert|ads?|banner|newsletter|subscribe|cookies?|breadcrumbs?|menu|nav|navbar|footer|masthead|popup|modal)([-_ ]|$)`)
+	content = regexp.MustCompile(`(?i)(^|[-_ ])(article|content|main|post|entry|story|body)([-_ ]|$)`)
+)
+
+// book is an EPUB being assembled from a capture.
+type book struct {
+	dir      string
+	pages    map[string]*page
+	readable bool
+	// media maps the images of the capture that chapters show to their file
+	// in the book
+	media map[string]string
+}
+
+// page is an HTML page of the capture.
+type page struct {
+	// path is the slash-separated path of the page in the capture
+	path  string
+	url   string
+	title string
+	lang  string
+	// links are the pages the page links to, in document order
+	links []string
+	// content is what the chapter of the page shows: its body, or its main
+	// text in a readable book, where it is nil for pages without one
+	content *html.Node
+	// file is the chapter of the page in the book
+	file string
+}
+
+// loadPages reads the pages of the capture in dir recorded in m, and
+// returns them by path along with the page the crawl started at. Readable
+// pages keep only their main text.
+func loadPages(dir string, m *manifest.Manifest, readable bool) (map[string]*page, *page) {
+	pages := make(map[string]*page)
+	var seed *page
+	for _, r := range m.Resources {
+		if !strings.Contains(r.ContentType, "html") || (r.Status != 0 && r.Status != 200) || pages[r.Path] != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(r.Path))) // #nosec G304 - the file is inside the capture directory
+		if err != nil {
+			continue
+		}
+		doc, err := html.Parse(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		p := &page{path: r.Path, url: r.URL, title: r.Title}
+		if original, _, ok := catalog.OriginalURL(r.URL); ok {
+			p.url = original
+		}
+		if root := find(doc, atom.Html); root != nil {
+			p.lang = attr(root, "lang")
+		}
+		if p.title == "" {
+			if t := find(doc, atom.Title); t != nil {
+				p.title = text(t)
+			}
+		}
+		body := find(doc, atom.Body)
+		if body == nil {
+			continue
+		}
+		p.links, p.content = links(body, r.Path), body
+		if readable {
+			p.content = article(body)
+		}
+		pages[r.Path] = p
+		if seed == nil && (r.URL == m.URL || r.Path == "index.html") {
+			seed = p
+		}
+	}
+	for _, p := range pages {
+		var kept []string
+		seen := map[string]bool{p.path: true}
+		for _, to := range p.links {
+			if pages[to] != nil && !seen[to] {
+				seen[to] = true
+				kept = append(kept, to)
+			}
+		}
+		p.links = kept
+	}
+	return pages, seed
+}
+
+// links returns the local pages the links below n point to, in order.
+func links(n *html.Node, from string) []string {
+	var found []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			if to, ok := local(from, attr(n, "href"), true); ok {
+				found = append(found, to)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return found
+}
+
+// local resolves the reference ref on the page at from to a file of the
+// capture. Directories mean their index page when page is set.
+func local(from, ref string, page bool) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	p := path.Clean(path.Join(path.Dir(from), u.Path))
+	if strings.HasPrefix(u.Path, "/") {
+		p = strings.TrimPrefix(path.Clean(u.Path), "/")
+	}
+	if page && (strings.HasSuffix(u.Path, "/") || p == ".") {
+		p = path.Join(p, "index.html")
+	}
+	if p == "" || p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return "", false
+	}
+	return p, true
+}
+
+// chapter returns the XHTML of the chapter c.
+func (b *book) chapter(c *chapter, lang string) ([]byte, error) {
+	n := c.page.content
+	b.clean(n, c.page)
+	body, err := renderNodes(n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chapter %s: %w", c.page.path, err)
+	}
+	return render(c, lang, find(n, atom.H1) == nil, body)
+}
+
+// clean makes the content below n fit for a chapter of the page p: it drops
+// what books cannot show, points links at chapters and images at their
+// copies in the book, and keeps the markup well-formed XML.
+func (b *book) clean(n *html.Node, p *page) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case html.TextNode:
+			c.Data = validText(c.Data)
+		case html.ElementNode:
+			switch {
+			case c.Namespace == "" && dropped[c.DataAtom]:
+				n.RemoveChild(c)
+			case c.Namespace == "" && unwrapped[c.DataAtom], !xmlName.MatchString(c.Data):
+				b.clean(c, p)
+				for c.FirstChild != nil {
+					child := c.FirstChild
+					c.RemoveChild(child)
+					n.InsertBefore(child, c)
+				}
+				n.RemoveChild(c)
+			case b.element(c, p):
+				b.clean(c, p)
+			default:
+				n.RemoveChild(c)
+			}
+		default:
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+// element fixes the attributes of the element n on the page p and reports
+// whether it is kept.
+func (b *book) element(n *html.Node, p *page) bool {
+	var attrs []html.Attribute
+	for _, a := range n.Attr {
+		if a.Namespace == "xlink" && a.Key == "href" {
+			a.Namespace = ""
+		}
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" || droppedAttrs[key] || strings.HasPrefix(key, "on") || !xmlName.MatchString(a.Key) {
+			continue
+		}
+		a.Val = validText(a.Val)
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+	if n.Namespace != "" {
+		if n.Parent == nil || n.Parent.Namespace != n.Namespace {
+			setAttr(n, "xmlns", map[string]string{"svg": "http://www.w3.org/2000/svg", "math": "http://www.w3.org/1998/Math/MathML"}[n.Namespace])
+		}
+		return n.Namespace == "svg" || n.Namespace == "math"
+	}
+	switch n.DataAtom {
+	case atom.Img:
+		file, ok := local(p.path, attr(n, "src"), false)
+		if !ok {
+			return false
+		}
+		media, ok := b.image(file)
+		if !ok {
+			return false
+		}
+		setAttr(n, "src", "../"+media)
+		if !hasAttr(n, "alt") {
+			setAttr(n, "alt", "")
+		}
+	case atom.A:
+		href := attr(n, "href")
+		if href == "" {
+			break
+		}
+		u, err := url.Parse(strings.TrimSpace(href))
+		switch {
+		case err != nil:
+			removeAttr(n, "href")
+		case u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "mailto":
+		case u.Scheme == "" && u.Host == "" && u.Path == "":
+			// Anchors on the same page
+		case u.Scheme == "" && u.Host == "":
+			to, ok := local(p.path, href, true)
+			if target := b.pages[to]; ok && target != nil && target.file != "" {
+				setAttr(n, "href", (&url.URL{Path: target.file, Fragment: u.Fragment}).String())
+			} else if ok && target != nil {
+				setAttr(n, "href", target.url)
+			} else {
+				removeAttr(n, "href")
+			}
+		default:
+			removeAttr(n, "href")
+		}
+	}
+	return true
+}
+
+// image returns the file of the book that holds the image file of the
+// capture, when it is one books may hold.
+func (b *book) image(file string) (string, bool) {
+	if media, ok := b.media[file]; ok {
+		return media, true
+	}
+	ext := strings.ToLower(path.Ext(file))
+	if imageTypes[ext] == "" {
+		return "", false
+	}
+	if info, err := os.Stat(filepath.Join(b.dir, filepath.FromSlash(file))); err != nil || info.IsDir() {
+		return "", false
+	}
+	media := fmt.Sprintf("%s/img%d%s", mediaDir, len(b.media)+1, ext)
+	b.media[file] = media
+	return media, true
+}
+
+// article returns the element below body that holds the main text of the
+// page, where paragraphs gather, or nil when the page has too little text.
+func article(body *html.Node) *html.Node {
+	removeClutter(body, false)
+	scores := make(map[*html.Node]int)
+	var candidates []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.DataAtom == atom.P || n.DataAtom == atom.Pre) {
+			if t := len(text(n)); t >= minParagraph && n.Parent != nil {
+				for i, up := 0, n.Parent; i < 2 && up != nil; i, up = i+1, up.Parent {
+					if _, ok := scores[up]; !ok {
+						candidates = append(candidates, up)
+					}
+					scores[up] += t >> i
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(body)
+	var best *html.Node
+	for _, c := range candidates {
+		if best == nil || scores[c] > scores[best] {
+			best = c
+		}
+	}
+	if best == nil || paragraphText(best) < minArticleText {
+		return nil
+	}
+	// The article or main element around the text also holds its headings
+	for up := best; up != nil && up != body; up = up.Parent {
+		if up.DataAtom == atom.Article || up.DataAtom == atom.Main {
+			return up
+		}
+	}
+	return best
+}
+
+// paragraphText returns the length of the text in the paragraphs below n.
+func paragraphText(n *html.Node) int {
+	total := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.DataAtom == atom.P || n.DataAtom == atom.Pre) {
+			if t := len(text(n)); t >= minParagraph {
+				total += t
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return total
+}
+
+// removeClutter drops navigation, sidebars, footers, and elements whose
+// class or id say they are furniture from below n. Page headers outside an
+// article go too.
+func removeClutter(n *html.Node, inArticle bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode {
+			names := attr(c, "class") + " " + attr(c, "id")
+			switch {
+			case c.DataAtom == atom.Nav || c.DataAtom == atom.Aside || c.DataAtom == atom.Footer || c.DataAtom == atom.Form,
+				c.DataAtom == atom.Header && !inArticle,
+				c.DataAtom != atom.Body && c.DataAtom != atom.Main && c.DataAtom != atom.Article && clutter.MatchString(names) && !content.MatchString(names):
+				n.RemoveChild(c)
+			default:
+				removeClutter(c, inArticle || c.DataAtom == atom.Article || c.DataAtom == atom.Main)
+			}
+		}
+		c = next
+	}
+}
+
+// validText drops the characters XML does not allow.
+func validText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// find returns the first element a below n.
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// text returns the text below n with its white space collapsed.
+func text(n *html.Node) string {
+	var buf strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+			buf.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key && a.Namespace == "" {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func removeAttr(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Key != key {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
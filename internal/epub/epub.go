@@ -0,0 +1,371 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package epub turns a capture into an EPUB 3 book for e-readers: a chapter
+// per page, in the order the crawl reached them, with a table of contents
+// nested the same way. The images of the pages go along; scripts, forms,
+// and embeds do not. The readable variant keeps only the main text of the
+// pages that have one, like a reader view.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1" // #nosec G505 - only derives a stable book identifier
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+const (
+	// mimeType is the media type of EPUB files, stored first in the zip.
+	mimeType = "application/epub+zip"
+	// textDir and mediaDir hold the chapters and images inside the book.
+	textDir  = "text"
+	mediaDir = "media"
+	// defaultLanguage is used when the seed page does not declare one.
+	defaultLanguage = "en"
+)
+
+// Options configure the book.
+type Options struct {
+	// Readable keeps only the main text of each page and leaves out pages
+	// without one, such as indexes, whose chapters move up a level.
+	Readable  bool
+	FilePerms os.FileMode
+}
+
+// chapter is a page of the capture in the book.
+type chapter struct {
+	page     *page
+	Title    string
+	File     string
+	Children []*chapter
+}
+
+// item is a file of the book listed in its package document.
+type item struct {
+	ID, Href, MediaType, Properties string
+}
+
+// Export writes the capture in dir, which holds its manifest, as the EPUB
+// dest. It returns the number of chapters.
+func Export(dir, dest string, opts Options) (int, error) {
+	m, err := manifest.Load(dir)
+	if err != nil {
+		return 0, err
+	}
+	pages, seed := loadPages(dir, m, opts.Readable)
+	if len(pages) == 0 {
+		return 0, fmt.Errorf("%s holds no pages", dir)
+	}
+	b := &book{dir: dir, pages: pages, readable: opts.Readable, media: make(map[string]string)}
+	toc := b.toc(seed)
+	if len(toc) == 0 {
+		return 0, fmt.Errorf("no page of %s has a main text", dir)
+	}
+	var chapters []*chapter
+	var number func([]*chapter)
+	number = func(list []*chapter) {
+		for _, c := range list {
+			chapters = append(chapters, c)
+			c.File = fmt.Sprintf("page%d.xhtml", len(chapters))
+			c.page.file = c.File
+			number(c.Children)
+		}
+	}
+	number(toc)
+
+	title := m.URL
+	lang := defaultLanguage
+	if seed != nil {
+		if seed.title != "" {
+			title = seed.title
+		}
+		if seed.lang != "" {
+			lang = seed.lang
+		}
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := writeMimeType(zw); err != nil {
+		return 0, err
+	}
+	files := map[string][]byte{
+		"META-INF/container.xml": []byte(container),
+		"style.css":              []byte(stylesheet),
+	}
+	for _, c := range chapters {
+		data, err := b.chapter(c, lang)
+		if err != nil {
+			return 0, err
+		}
+		files[textDir+"/"+c.File] = data
+	}
+	items := []item{{ID: "nav", Href: "nav.xhtml", MediaType: "application/xhtml+xml", Properties: "nav"}, {ID: "ncx", Href: "toc.ncx", MediaType: "application/x-dtbncx+xml"}, {ID: "css", Href: "style.css", MediaType: "text/css"}}
+	for i, c := range chapters {
+		items = append(items, item{ID: fmt.Sprintf("page%d", i+1), Href: textDir + "/" + c.File, MediaType: "application/xhtml+xml"})
+	}
+	var media []string
+	for file := range b.media {
+		media = append(media, file)
+	}
+	sort.Strings(media)
+	for i, file := range media {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file))) // #nosec G304 - file is inside the capture directory
+		if err != nil {
+			return 0, fmt.Errorf("failed to read image: %w", err)
+		}
+		files[b.media[file]] = data
+		items = append(items, item{ID: fmt.Sprintf("img%d", i+1), Href: b.media[file], MediaType: imageTypes[strings.ToLower(path.Ext(file))]})
+	}
+
+	modified := m.CreatedAt.UTC()
+	if modified.IsZero() {
+		modified = time.Now().UTC()
+	}
+	sum := sha1.Sum([]byte(m.URL + modified.String())) // #nosec G401 - not used for security
+	data := struct {
+		ID, Title, Lang, Source, Modified string
+		Items                             []item
+		Chapters, TOC                     []*chapter
+	}{
+		ID:       fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16]),
+		Title:    title,
+		Lang:     lang,
+		Source:   m.URL,
+		Modified: modified.Format(time.RFC3339),
+		Items:    items,
+		Chapters: chapters,
+		TOC:      toc,
+	}
+	for name, tmpl := range map[string]*template.Template{"content.opf": packageTemplate, "nav.xhtml": navTemplate, "toc.ncx": ncxTemplate} {
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			return 0, fmt.Errorf("failed to render %s: %w", name, err)
+		}
+		files[name] = out.Bytes()
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add %s to book: %w", name, err)
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return 0, fmt.Errorf("failed to add %s to book: %w", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write book: %w", err)
+	}
+	if err := os.WriteFile(dest, buf.Bytes(), opts.FilePerms); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	return len(chapters), nil
+}
+
+// writeMimeType stores the mimetype file first and uncompressed, without a
+// data descriptor, as readers sniff EPUB files by it.
+func writeMimeType(zw *zip.Writer) error {
+	data := []byte(mimeType)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(len(data)),
+		UncompressedSize64: uint64(len(data)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add mimetype to book: %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// toc arranges the chapters breadth-first from seed along the links between
+// the pages, so every page is a chapter below the page it is reached through
+// in the fewest clicks. Pages the seed does not lead to follow at the top
+// level in path order. Pages left out of a readable book hand their
+// chapters to their parent.
+func (b *book) toc(seed *page) []*chapter {
+	reached := make(map[string]bool)
+	var roots []*chapter
+	walk := func(start *page) {
+		root := &chapter{page: start}
+		reached[start.path] = true
+		roots = append(roots, root)
+		level := []*chapter{root}
+		for len(level) > 0 {
+			var next []*chapter
+			for _, parent := range level {
+				for _, to := range parent.page.links {
+					if reached[to] {
+						continue
+					}
+					reached[to] = true
+					child := &chapter{page: b.pages[to]}
+					parent.Children = append(parent.Children, child)
+					next = append(next, child)
+				}
+			}
+			level = next
+		}
+	}
+	if seed != nil {
+		walk(seed)
+	}
+	paths := make([]string, 0, len(b.pages))
+	for p := range b.pages {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if !reached[p] {
+			walk(b.pages[p])
+		}
+	}
+	return b.prune(roots)
+}
+
+// prune drops the chapters of pages without content from list, putting
+// their children in their place, and sets the titles of the others.
+func (b *book) prune(list []*chapter) []*chapter {
+	var kept []*chapter
+	for _, c := range list {
+		c.Children = b.prune(c.Children)
+		if b.readable && c.page.content == nil {
+			kept = append(kept, c.Children...)
+			continue
+		}
+		c.Title = c.page.title
+		if c.Title == "" {
+			c.Title = c.page.path
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// escapeXML escapes s for XML text and attribute values.
+func escapeXML(s string) string {
+	var buf strings.Builder
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+var funcs = template.FuncMap{
+	"x":    escapeXML,
+	"href": func(s string) string { return escapeXML((&url.URL{Path: s}).EscapedPath()) },
+	"inc":  func(i int) int { return i + 1 },
+}
+
+const container = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const stylesheet = `body { font-family: serif; line-height: 1.5; margin: 0 1em; }
+img { max-width: 100%; height: auto; }
+pre { white-space: pre-wrap; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #999; padding: 0.2em 0.4em; }
+`
+
+var packageTemplate = template.Must(template.New("opf").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{x .Lang}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{x .ID}}</dc:identifier>
+    <dc:title>{{x .Title}}</dc:title>
+    <dc:language>{{x .Lang}}</dc:language>
+    <dc:source>{{x .Source}}</dc:source>
+    <dc:creator>website-archiver</dc:creator>
+    <meta property="dcterms:modified">{{x .Modified}}</meta>
+  </metadata>
+  <manifest>
+{{range .Items}}    <item id="{{.ID}}" href="{{href .Href}}" media-type="{{.MediaType}}"{{if .Properties}} properties="{{.Properties}}"{{end}}/>
+{{end}}  </manifest>
+  <spine toc="ncx">
+{{range $i, $c := .Chapters}}    <itemref idref="page{{inc $i}}"/>
+{{end}}  </spine>
+</package>
+`))
+
+var navTemplate = template.Must(template.New("nav").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="{{x .Lang}}" xml:lang="{{x .Lang}}">
+<head><meta charset="utf-8"/><title>{{x .Title}}</title><link rel="stylesheet" href="style.css"/></head>
+<body>
+<nav epub:type="toc" id="toc"><h1>{{x .Title}}</h1>
+{{define "list"}}<ol>{{range .}}<li><a href="text/{{href .File}}">{{x .Title}}</a>{{if .Children}}{{template "list" .Children}}{{end}}</li>{{end}}</ol>{{end}}{{template "list" .TOC}}
+</nav>
+</body>
+</html>
+`))
+
+var ncxTemplate = template.Must(template.New("ncx").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<head><meta name="dtb:uid" content="{{x .ID}}"/></head>
+<docTitle><text>{{x .Title}}</text></docTitle>
+<navMap>
+{{define "points"}}{{range .}}<navPoint id="nav-{{.File}}"><navLabel><text>{{x .Title}}</text></navLabel><content src="text/{{href .File}}"/>{{template "points" .Children}}</navPoint>
+{{end}}{{end}}{{template "points" .TOC}}</navMap>
+</ncx>
+`))
+
+// chapterTemplate wraps the converted content of a page.
+var chapterTemplate = template.Must(template.New("chapter").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="{{x .Lang}}" xml:lang="{{x .Lang}}">
+<head><meta charset="utf-8"/><title>{{x .Title}}</title><link rel="stylesheet" href="../style.css"/></head>
+<body>
+{{if .Heading}}<h1>{{x .Title}}</h1>
+{{end}}{{.Body}}
+<p class="source"><a href="{{x .URL}}">{{x .URL}}</a></p>
+</body>
+</html>
+`))
+
+// render returns the converted content of c as a chapter.
+func render(c *chapter, lang string, heading bool, body string) ([]byte, error) {
+	var out bytes.Buffer
+	err := chapterTemplate.Execute(&out, struct {
+		Lang, Title, URL, Body string
+		Heading                bool
+	}{lang, c.Title, c.page.url, body, heading})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chapter %s: %w", c.page.path, err)
+	}
+	return out.Bytes(), nil
+}
+
+// renderNodes serializes the children of n as XHTML.
+func renderNodes(n *html.Node) (string, error) {
+	var buf bytes.Buffer
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package errorpages decides what a crawl does with error responses: fail
+// the resource, save the error page as it was served, only report it, or
+// skip it. Sites with pages that are gone on purpose can be archived without
+// a failure for each of them.
+package errorpages
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+)
+
+// Modes of handling error responses.
+const (
+	// Fail fails the resource, as without a policy.
+	Fail = "fail"
+	// Save saves the body of the error response verbatim, without following
+	// its links, and reports it.
+	Save = "save"
+	// Report records the error response in the report only.
+	Report = "report"
+	// Skip drops the error response silently.
+	Skip = "skip"
+)
+
+// ReportFile is the report in the metadata directory of a capture.
+const ReportFile = manifest.Dir + "/error-pages.json"
+
+// Policy selects the mode of the error statuses it covers. The zero value
+// fails every error response.
+type Policy struct {
+	mode     string
+	statuses map[int]bool
+}
+
+// New returns the policy handling statuses, or every 4xx and 5xx status when
+// none are given, with mode.
+func New(mode string, statuses []string) (Policy, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "", Fail:
+		return Policy{}, nil
+	case Save, Report, Skip:
+	default:
+		return Policy{}, fmt.Errorf("unknown error page mode %q (want %s, %s, %s, or %s)", mode, Fail, Save, Report, Skip)
+	}
+	p := Policy{mode: mode}
+	for _, s := range statuses {
+		s = strings.TrimSpace(s)
+		status, err := strconv.Atoi(s)
+		if err != nil || status < 400 || status > 599 {
+			return Policy{}, fmt.Errorf("invalid error status %q (want 400 to 599)", s)
+		}
+		if p.statuses == nil {
+			p.statuses = make(map[int]bool)
+		}
+		p.statuses[status] = true
+	}
+	return p, nil
+}
+
+// Mode returns how a response with status is handled. Statuses the policy
+// does not cover fail.
+func (p Policy) Mode(status int) string {
+	if p.mode == "" || status < 400 || status > 599 || (p.statuses != nil && !p.statuses[status]) {
+		return Fail
+	}
+	return p.mode
+}
+
+// Reports reports whether the error responses the policy covers are
+// recorded in a report.
+func (p Policy) Reports() bool {
+	return p.mode == Save || p.mode == Report
+}
+
+// Page is an error response of the crawl. Path is where it was saved,
+// empty when it was only reported.
+type Page struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Path   string `json:"path,omitempty"`
+}
+
+// Log lists the error responses of a capture. It is safe for concurrent use.
+type Log struct {
+	mu    sync.Mutex
+	Pages []Page `json:"pages"`
+}
+
+// Add records an error response.
+func (l *Log) Add(p Page) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Pages = append(l.Pages, p)
+}
+
+// Len returns the number of error responses recorded.
+func (l *Log) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.Pages)
+}
+
+// Write stores the log as JSON as name in s.
+func (l *Log) Write(s storage.Storage, name string) error {
+	l.mu.Lock()
+	sort.Slice(l.Pages, func(i, j int) bool { return l.Pages[i].URL < l.Pages[j].URL })
+	if l.Pages == nil {
+		l.Pages = []Page{}
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	l.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode error page report: %w", err)
+	}
+	if err := storage.WriteFile(s, name, data); err != nil {
+		return fmt.Errorf("failed to write error page report: %w", err)
+	}
+	return nil
+}
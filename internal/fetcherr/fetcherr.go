@@ -0,0 +1,116 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package fetcherr describes failures of a run in one shape: which URL
+// failed, in which stage, with which HTTP status, and after how many
+// attempts. Logs, progress events, and job results all read it from there
+// instead of parsing error strings.
+package fetcherr
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+)
+
+// Stages of a run a failure can come from.
+const (
+	// StageFetch is downloading a resource.
+	StageFetch = "fetch"
+	// StageSave is writing a downloaded resource to the capture.
+	StageSave = "save"
+	// StageRewrite is parsing a page and rewriting its links.
+	StageRewrite = "rewrite"
+	// StageChallenge is an anti-bot challenge served instead of a resource.
+	StageChallenge = "challenge"
+	// StageRobots is fetching robots.txt.
+	StageRobots = "robots"
+	// StageWayback is fetching a capture from the Wayback Machine.
+	StageWayback = "wayback"
+	// StageCDX is querying the Wayback Machine CDX API.
+	StageCDX = "cdx"
+	// StageZIM is building and validating a ZIM file.
+	StageZIM = "zim"
+	// StagePackage is packaging a capture after the crawl, such as chunking it.
+	StagePackage = "package"
+)
+
+// FetchError is a failure tied to a URL and a stage of the run.
+type FetchError struct {
+	URL   string `json:"url"`
+	Stage string `json:"stage"`
+	// Status is the HTTP status of the response; 0 when there was none.
+	Status int `json:"status,omitempty"`
+	// Attempt is the number of requests made for the URL, retries
+	// included; 0 when not known.
+	Attempt int `json:"attempt,omitempty"`
+	// Err is the underlying error; nil when Status says it all.
+	Err error `json:"-"`
+}
+
+func (e *FetchError) Error() string {
+	msg := e.Stage + " " + e.URL
+	if e.Status != 0 {
+		msg += fmt.Sprintf(": status %d", e.Status)
+	}
+	if e.Attempt > 1 {
+		msg += fmt.Sprintf(" after %d attempts", e.Attempt)
+	}
+	if e.Err != nil {
+		err := e.Err
+		// url.Error would repeat the URL of the failure
+		if ue, ok := err.(*url.Error); ok && ue.URL == e.URL {
+			err = ue.Err
+		}
+		msg += ": " + err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// group returns the fields of the failure as a log group. FetchError is
+// neither a slog.LogValuer nor a json.Marshaler, so "error" attributes keep
+// logging the full message.
+func (e *FetchError) group() slog.Value {
+	attrs := []slog.Attr{slog.String("url", e.URL), slog.String("stage", e.Stage)}
+	if e.Status != 0 {
+		attrs = append(attrs, slog.Int("status", e.Status))
+	}
+	if e.Attempt != 0 {
+		attrs = append(attrs, slog.Int("attempt", e.Attempt))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Wrap ties err to u and stage. Errors that already are a FetchError keep
+// the URL and stage they were given closest to the failure; nil stays nil.
+func Wrap(stage, u string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := As(err); ok {
+		return err
+	}
+	return &FetchError{URL: u, Stage: stage, Err: err}
+}
+
+// As returns the FetchError in the chain of err.
+func As(err error) (*FetchError, bool) {
+	var fe *FetchError
+	ok := errors.As(err, &fe)
+	return fe, ok
+}
+
+// Attr returns the FetchError of err as a "failure" log attribute, or an
+// empty attribute, which slog drops, when there is none.
+func Attr(err error) slog.Attr {
+	if fe, ok := As(err); ok {
+		return slog.Attr{Key: "failure", Value: fe.group()}
+	}
+	return slog.Attr{}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package filenames
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strings"
+	"sync"
+)
+
+// suffixLength is the number of hex digits of the suffix that tells apart
+// paths differing only by case.
+const suffixLength = 8
+
+// Cases keeps the local paths of a mirror from differing only by case, as
+// they would overwrite each other on the case-insensitive file systems of
+// macOS and Windows. The first source of a case-folded path keeps it; every
+// other source gets a suffix derived from its own path, so a source is
+// always saved and linked under the same name. The zero value is ready to
+// use.
+type Cases struct {
+	mu sync.Mutex
+	// owners maps case-folded paths to the source that holds them
+	owners map[string]string
+	// paths maps sources to the path they were given
+	paths map[string]string
+}
+
+// Assign returns the slash-separated path for source, the path a resource
+// maps to before any restriction, given that it would be saved at p. It
+// reports whether p was changed because another source holds it.
+func (c *Cases) Assign(source, p string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if assigned, ok := c.paths[source]; ok {
+		return assigned, false
+	}
+	c.init()
+	if owner, ok := c.owners[fold(p)]; !ok || owner == source {
+		c.claim(source, p)
+		return p, false
+	}
+	assigned := withSuffix(p, source)
+	c.claim(source, assigned)
+	return assigned, true
+}
+
+// Claim records that source is saved at p, as when a crawl resumes with the
+// paths of its manifest.
+func (c *Cases) Claim(source, p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	c.claim(source, p)
+}
+
+func (c *Cases) init() {
+	if c.owners == nil {
+		c.owners = make(map[string]string)
+		c.paths = make(map[string]string)
+	}
+}
+
+func (c *Cases) claim(source, p string) {
+	c.paths[source] = p
+	if _, ok := c.owners[fold(p)]; !ok {
+		c.owners[fold(p)] = source
+	}
+}
+
+// fold returns the case-insensitive form of p.
+func fold(p string) string {
+	return strings.ToLower(p)
+}
+
+// withSuffix inserts a digest of source before the extension of p, e.g.
+// Page.html becomes Page-1a2b3c4d.html.
+func withSuffix(p, source string) string {
+	sum := sha256.Sum256([]byte(source))
+	dir, name := path.Split(p)
+	ext := path.Ext(name)
+	if ext == name {
+		// Dot files like .htaccess have no extension to keep
+		ext = ""
+	}
+	return dir + strings.TrimSuffix(name, ext) + "-" + hex.EncodeToString(sum[:])[:suffixLength] + ext
+}
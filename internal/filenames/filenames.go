@@ -0,0 +1,141 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package filenames restricts the characters of local file names the way
+// wget's --restrict-file-names does, so a mirror can be stored on file
+// systems that reject some characters of URL paths, and keeps local paths
+// from colliding on case-insensitive file systems.
+package filenames
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Modes, combinable with commas as in wget.
+const (
+	// Unix escapes control characters.
+	Unix = "unix"
+	// Windows escapes the characters Windows forbids in file names, control
+	// characters, and reserved device names, and trims trailing dots and spaces.
+	Windows = "windows"
+	// ASCII escapes every byte outside printable ASCII.
+	ASCII = "ascii"
+	// Lowercase and Uppercase fold the case of file names, for
+	// case-insensitive file systems.
+	Lowercase = "lowercase"
+	Uppercase = "uppercase"
+	// NoControl keeps control characters even with unix or windows.
+	NoControl = "nocontrol"
+)
+
+// windowsForbidden are the characters Windows does not allow in file names.
+const windowsForbidden = `\:*?"<>|`
+
+// windowsReserved are device names Windows reserves regardless of extension.
+var windowsReserved = map[string]bool{"CON": true, "PRN": true, "AUX": true, "NUL": true}
+
+func init() {
+	for _, prefix := range []string{"COM", "LPT"} {
+		for i := 1; i <= 9; i++ {
+			windowsReserved[fmt.Sprintf("%s%d", prefix, i)] = true
+		}
+	}
+}
+
+// Restriction is a parsed set of modes. The zero value changes nothing.
+type Restriction struct {
+	windows, ascii, control bool
+	lower, upper            bool
+}
+
+// Parse reads a comma-separated list of modes. An empty list restricts nothing.
+func Parse(modes string) (Restriction, error) {
+	var r Restriction
+	if strings.TrimSpace(modes) == "" {
+		return r, nil
+	}
+	control, noControl := false, false
+	for _, mode := range strings.Split(modes, ",") {
+		switch strings.ToLower(strings.TrimSpace(mode)) {
+		case Unix:
+			control = true
+		case Windows:
+			r.windows, control = true, true
+		case ASCII:
+			r.ascii = true
+		case Lowercase:
+			r.lower = true
+		case Uppercase:
+			r.upper = true
+		case NoControl:
+			noControl = true
+		default:
+			return Restriction{}, fmt.Errorf("unknown file name restriction %q (want %s, %s, %s, %s, %s, or %s)", mode, Unix, Windows, ASCII, Lowercase, Uppercase, NoControl)
+		}
+	}
+	if r.lower && r.upper {
+		return Restriction{}, fmt.Errorf("file name restrictions %s and %s exclude each other", Lowercase, Uppercase)
+	}
+	r.control = control && !noControl
+	return r, nil
+}
+
+// Active reports whether r changes any file names.
+func (r Restriction) Active() bool {
+	return r != Restriction{}
+}
+
+// Apply restricts every element of a slash-separated relative path. Escaped
+// bytes become %XX, so links to the file must escape "%" as "%25".
+func (r Restriction) Apply(path string) string {
+	if !r.Active() {
+		return path
+	}
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = r.element(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// element restricts one path element.
+func (r Restriction) element(name string) string {
+	if r.lower {
+		name = strings.ToLower(name)
+	}
+	if r.upper {
+		name = strings.ToUpper(name)
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		escape := (r.control && (c < 0x20 || c == 0x7f)) ||
+			(r.ascii && c >= 0x80) ||
+			(r.windows && strings.IndexByte(windowsForbidden, c) >= 0)
+		if escape {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	name = b.String()
+	if r.windows {
+		name = windowsSafe(name)
+	}
+	return name
+}
+
+// windowsSafe escapes trailing dots and spaces and reserved device names.
+func windowsSafe(name string) string {
+	trimmed := strings.TrimRight(name, ". ")
+	if trimmed != name && trimmed != "" {
+		tail := name[len(trimmed):]
+		name = trimmed + strings.NewReplacer(".", "%2E", " ", "%20").Replace(tail)
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReserved[strings.ToUpper(base)] {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package frontier keeps the queue of URLs a crawl still has to fetch. The
+// in-memory queue suits ordinary crawls; the persistent one keeps the queue
+// in a bbolt database, so a crawl of an enormous site is not limited by
+// memory and picks up where it stopped after a restart.
+package frontier
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// pendingBucket holds items waiting to be fetched, in queue order.
+	pendingBucket = []byte("pending")
+	// inflightBucket holds items handed out but not finished yet. They go
+	// back to the queue when the frontier is opened again.
+	inflightBucket = []byte("inflight")
+)
+
+// ErrClosed is returned by Pop once the frontier is closed.
+var ErrClosed = errors.New("frontier closed")
+
+// openTimeout bounds the wait for another process holding the database.
+const openTimeout = 5 * time.Second
+
+// Item is a queued URL.
+type Item struct {
+	// ID orders the queue; it is assigned by Push.
+	ID        uint64 `json:"-"`
+	URL       string `json:"url"`
+	Depth     int    `json:"depth"`
+	Requisite bool   `json:"requisite,omitempty"`
+	// Referrer is the page the URL was found on, empty for seeds.
+	Referrer string `json:"referrer,omitempty"`
+}
+
+// Stats counts the items in a frontier.
+type Stats struct {
+	Pending  int `json:"pending"`
+	InFlight int `json:"inFlight"`
+}
+
+// Queue is a FIFO queue of items to fetch, safe for concurrent use. Pop
+// waits for an item until the queue is closed.
+type Queue interface {
+	Push(item Item) error
+	Pop() (Item, error)
+	Done(item Item) error
+	Stats() Stats
+	Close() error
+}
+
+// Frontier is a persistent Queue.
+type Frontier struct {
+	db *bolt.DB
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	stats  Stats
+	closed bool
+}
+
+// Open opens or creates the frontier stored at path. Items that were in
+// flight when it was last closed are queued again.
+func Open(path string, perms os.FileMode) (*Frontier, error) {
+	db, err := bolt.Open(path, perms, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open frontier %s: %w", path, err)
+	}
+	f := &Frontier{db: db}
+	f.cond = sync.NewCond(&f.mu)
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		pending, err := tx.CreateBucketIfNotExists(pendingBucket)
+		if err != nil {
+			return err
+		}
+		inflight, err := tx.CreateBucketIfNotExists(inflightBucket)
+		if err != nil {
+			return err
+		}
+		// Keys and values are only valid while their bucket is unchanged,
+		// so collect copies before moving them
+		var keys, values [][]byte
+		err = inflight.ForEach(func(k, v []byte) error {
+			keys = append(keys, bytes.Clone(k))
+			values = append(values, bytes.Clone(v))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i, k := range keys {
+			if err := pending.Put(k, values[i]); err != nil {
+				return err
+			}
+			if err := inflight.Delete(k); err != nil {
+				return err
+			}
+		}
+		return pending.ForEach(func(_, _ []byte) error {
+			f.stats.Pending++
+			return nil
+		})
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to recover frontier %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Stats returns the current queue counts.
+func (f *Frontier) Stats() Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// Push appends an item to the queue.
+func (f *Frontier) Push(item Item) error {
+	value, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode frontier item: %w", err)
+	}
+	// Batch coalesces concurrent pushes into one transaction and sync
+	err = f.db.Batch(func(tx *bolt.Tx) error {
+		pending := tx.Bucket(pendingBucket)
+		seq, err := pending.NextSequence()
+		if err != nil {
+			return err
+		}
+		return pending.Put(key(seq), value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue %s: %w", item.URL, err)
+	}
+	f.mu.Lock()
+	f.stats.Pending++
+	f.mu.Unlock()
+	f.cond.Signal()
+	return nil
+}
+
+// Pop takes the oldest item off the queue, waiting until one is available.
+// The item stays in flight until Done is called for it.
+func (f *Frontier) Pop() (Item, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for {
+		if f.closed {
+			return Item{}, ErrClosed
+		}
+		if f.stats.Pending > 0 {
+			break
+		}
+		f.cond.Wait()
+	}
+
+	var item Item
+	err := f.db.Update(func(tx *bolt.Tx) error {
+		k, v := tx.Bucket(pendingBucket).Cursor().First()
+		if k == nil {
+			return errors.New("frontier is empty")
+		}
+		if err := json.Unmarshal(v, &item); err != nil {
+			return fmt.Errorf("failed to decode frontier item: %w", err)
+		}
+		item.ID = binary.BigEndian.Uint64(k)
+		if err := tx.Bucket(inflightBucket).Put(k, v); err != nil {
+			return err
+		}
+		return tx.Bucket(pendingBucket).Delete(k)
+	})
+	if err != nil {
+		return Item{}, fmt.Errorf("failed to take item from frontier: %w", err)
+	}
+	f.stats.Pending--
+	f.stats.InFlight++
+	return item, nil
+}
+
+// Done removes a popped item for good.
+func (f *Frontier) Done(item Item) error {
+	err := f.db.Batch(func(tx *bolt.Tx) error {
+		return tx.Bucket(inflightBucket).Delete(key(item.ID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to finish %s: %w", item.URL, err)
+	}
+	f.mu.Lock()
+	f.stats.InFlight--
+	f.mu.Unlock()
+	return nil
+}
+
+// Close wakes every waiting Pop and closes the database.
+func (f *Frontier) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	f.cond.Broadcast()
+	if err := f.db.Close(); err != nil {
+		return fmt.Errorf("failed to close frontier: %w", err)
+	}
+	return nil
+}
+
+func key(seq uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, seq)
+	return k
+}
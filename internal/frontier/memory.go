@@ -0,0 +1,74 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package frontier
+
+import "sync"
+
+// Memory is a Queue held in memory.
+type Memory struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  []Item
+	stats  Stats
+	closed bool
+}
+
+// NewMemory returns an empty in-memory queue.
+func NewMemory() *Memory {
+	m := &Memory{}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// Stats returns the current queue counts.
+func (m *Memory) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// Push appends an item to the queue.
+func (m *Memory) Push(item Item) error {
+	m.mu.Lock()
+	m.items = append(m.items, item)
+	m.stats.Pending++
+	m.mu.Unlock()
+	m.cond.Signal()
+	return nil
+}
+
+// Pop takes the oldest item off the queue, waiting until one is available.
+func (m *Memory) Pop() (Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for !m.closed && len(m.items) == 0 {
+		m.cond.Wait()
+	}
+	if m.closed {
+		return Item{}, ErrClosed
+	}
+	item := m.items[0]
+	m.items[0] = Item{}
+	m.items = m.items[1:]
+	m.stats.Pending--
+	m.stats.InFlight++
+	return item, nil
+}
+
+// Done finishes a popped item.
+func (m *Memory) Done(Item) error {
+	m.mu.Lock()
+	m.stats.InFlight--
+	m.mu.Unlock()
+	return nil
+}
+
+// Close wakes every waiting Pop.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package gallery takes screenshots of captured pages with a headless
+// Chromium and writes a gallery page of their thumbnails, giving a quick
+// visual overview of a large capture.
+package gallery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/browser"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+const (
+	// FileName is the gallery page written into the output directory.
+	FileName = "archive-gallery.html"
+	// DirName holds the screenshots inside the output directory.
+	DirName = "screenshots"
+	// thumbDir holds the thumbnails inside DirName.
+	thumbDir = "thumbs"
+
+	// windowSize is the browser viewport screenshots are taken at.
+	windowSize = "1280,800"
+	// thumbSize is the width thumbnails are scaled to by ImageMagick.
+	thumbSize = "320x"
+	// convertCmd scales screenshots down to thumbnails.
+	convertCmd = "convert"
+	// shotTimeout bounds the time a single screenshot may take.
+	shotTimeout = 30 * time.Second
+)
+
+// Options configure the screenshots.
+type Options struct {
+	// Limit caps the number of pages screenshotted, 0 means every page.
+	Limit     int
+	DirPerms  os.FileMode
+	FilePerms os.FileMode
+}
+
+// item is a gallery entry.
+type item struct {
+	Path     string
+	URL      string
+	Title    string
+	Thumb    string
+	Captured string
+}
+
+// Write screenshots the HTML pages recorded in m from their copies in dir and
+// writes the gallery page. Pages that cannot be screenshotted are left out.
+func Write(ctx context.Context, dir string, m *manifest.Manifest, opts Options) error {
+	chromium, err := browser.Find()
+	if err != nil {
+		return err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+	_, convertErr := exec.LookPath(convertCmd)
+
+	var items []item
+	seen := make(map[string]bool)
+	for _, r := range m.Resources {
+		if opts.Limit > 0 && len(items) >= opts.Limit {
+			break
+		}
+		if !strings.Contains(r.ContentType, "html") || seen[r.Path] || (r.Status != 0 && r.Status != 200) {
+			continue
+		}
+		seen[r.Path] = true
+
+		// Screenshots mirror the layout of the pages, so names cannot collide
+		name := strings.TrimSuffix(r.Path, filepath.Ext(r.Path)) + ".png"
+		shot := filepath.Join(absDir, DirName, filepath.FromSlash(name))
+		thumbFile := filepath.Join(absDir, DirName, thumbDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(shot), opts.DirPerms); err != nil {
+			return fmt.Errorf("failed to create screenshot directory: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(thumbFile), opts.DirPerms); err != nil {
+			return fmt.Errorf("failed to create screenshot directory: %w", err)
+		}
+		if err := screenshot(ctx, chromium, filepath.Join(absDir, filepath.FromSlash(r.Path)), shot); err != nil {
+			slog.Warn("Failed to take screenshot", "error", err, "url", r.URL)
+			continue
+		}
+		thumb := DirName + "/" + name
+		if convertErr == nil {
+			if err := exec.CommandContext(ctx, convertCmd, shot, "-resize", thumbSize, thumbFile).Run(); err == nil { // #nosec G204 - arguments are passed without a shell
+				thumb = DirName + "/" + thumbDir + "/" + name
+			}
+		}
+		captured := m.CreatedAt
+		if !r.FetchedAt.IsZero() {
+			captured = r.FetchedAt
+		}
+		items = append(items, item{Path: r.Path, URL: r.URL, Title: r.Title, Thumb: thumb, Captured: captured.UTC().Format(time.RFC3339)})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+
+	var buf bytes.Buffer
+	err = pageTemplate.Execute(&buf, struct {
+		Seed  string
+		Items []item
+	}{m.URL, items})
+	if err != nil {
+		return fmt.Errorf("failed to render gallery: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(absDir, FileName), buf.Bytes(), opts.FilePerms); err != nil {
+		return fmt.Errorf("failed to write gallery: %w", err)
+	}
+	return nil
+}
+
+// screenshot renders the local page file into a PNG.
+func screenshot(ctx context.Context, chromium, page, out string) error {
+	ctx, cancel := context.WithTimeout(ctx, shotTimeout)
+	defer cancel()
+	args := []string{"--headless", "--disable-gpu", "--hide-scrollbars", "--window-size=" + windowSize, "--screenshot=" + out}
+	cmd := browser.Command(ctx, chromium, append(args, "file://"+filepath.ToSlash(page))...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+	}
+	if _, err := os.Stat(out); err != nil {
+		return fmt.Errorf("browser wrote no screenshot: %w", err)
+	}
+	return nil
+}
+
+var pageTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Gallery of {{.Seed}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(16em, 1fr)); gap: 1.5em; }
+.card { border: 1px solid #ddd; border-radius: 4px; overflow: hidden; }
+.card img { width: 100%; display: block; border-bottom: 1px solid #ddd; }
+.card div { padding: 0.5em; font-size: 0.9em; }
+.card time { color: #777; font-size: 0.85em; display: block; }
+</style>
+</head>
+<body>
+<h1>Gallery</h1>
+<p>{{len .Items}} pages archived from <code>{{.Seed}}</code>.</p>
+<div class="grid">
+{{range .Items}}<a class="card" href="{{.Path}}"><img src="{{.Thumb}}" alt="" loading="lazy"><div>{{if .Title}}{{.Title}}{{else}}{{.Path}}{{end}}<time datetime="{{.Captured}}">{{.Captured}}</time></div></a>
+{{end}}</div>
+</body>
+</html>
+`))
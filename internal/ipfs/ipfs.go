@@ -0,0 +1,76 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package ipfs maps ipfs:// and ipns:// URLs onto an HTTP gateway and reads
+// the content identifiers gateways report for what they serve.
+package ipfs
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// SchemeIPFS addresses immutable content by CID.
+	SchemeIPFS = "ipfs"
+	// SchemeIPNS addresses mutable names that resolve to a CID.
+	SchemeIPNS = "ipns"
+
+	// rootsHeader lists the CIDs of every path segment of a gateway response.
+	rootsHeader = "X-Ipfs-Roots"
+	// pathHeader is the immutable /ipfs/<cid>/... path of a gateway response.
+	pathHeader = "X-Ipfs-Path"
+)
+
+// Supports reports whether scheme is an IPFS scheme.
+func Supports(scheme string) bool {
+	return scheme == SchemeIPFS || scheme == SchemeIPNS
+}
+
+// GatewayURL returns the path-gateway URL serving u, e.g.
+// ipfs://<cid>/docs/ becomes <gateway>/ipfs/<cid>/docs/, together with the
+// gateway path of the content root, <gateway path>/ipfs/<cid>/.
+func GatewayURL(u *url.URL, gateway string) (*url.URL, string, error) {
+	if !Supports(u.Scheme) {
+		return nil, "", fmt.Errorf("URL %s is not an IPFS URL", u.String())
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("URL %s has no CID or name", u.String())
+	}
+	base, err := url.Parse(strings.TrimSuffix(gateway, "/"))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid IPFS gateway %s: %w", gateway, err)
+	}
+	root := base.Path + "/" + u.Scheme + "/" + u.Host + "/"
+	resolved := *base
+	resolved.Path = root + strings.TrimPrefix(u.Path, "/")
+	resolved.RawQuery = u.RawQuery
+	return &resolved, root, nil
+}
+
+// RootCID returns the CID a seed resolved to: the first of the roots the
+// gateway reported, or the CID of an ipfs:// seed itself.
+func RootCID(seed *url.URL, header http.Header) string {
+	if roots := header.Get(rootsHeader); roots != "" {
+		return strings.TrimSpace(strings.Split(roots, ",")[0])
+	}
+	if p := header.Get(pathHeader); strings.HasPrefix(p, "/"+SchemeIPFS+"/") {
+		return strings.SplitN(strings.TrimPrefix(p, "/"+SchemeIPFS+"/"), "/", 2)[0]
+	}
+	if seed.Scheme == SchemeIPFS {
+		return seed.Host
+	}
+	return ""
+}
+
+// ResourceCID returns the CID of the resource in a gateway response, if reported.
+func ResourceCID(header http.Header) string {
+	roots := header.Get(rootsHeader)
+	if roots == "" {
+		return ""
+	}
+	parts := strings.Split(roots, ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package legacy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ftpPort is the default FTP control port.
+	ftpPort = "21"
+	// anonymousUser and anonymousPassword log in to public servers.
+	anonymousUser     = "anonymous"
+	anonymousPassword = "anonymous@"
+)
+
+// FTP reply codes used by the client.
+const (
+	ftpReady          = 220
+	ftpLoggedIn       = 230
+	ftpNeedPassword   = 331
+	ftpCommandOK      = 200
+	ftpFileActionOK   = 250
+	ftpExtPassive     = 229
+	ftpPassive        = 227
+	ftpTransferDone   = 226
+	ftpFileStatusOK   = 150
+	ftpAlreadyOpen    = 125
+	ftpFileNotFound   = 550
+	ftpNotLoggedIn    = 530
+	ftpReplyCodeClass = 100
+)
+
+// pasvAddr matches the host and port numbers of a PASV reply.
+var pasvAddr = regexp.MustCompile(`(\d+),(\d+),(\d+),(\d+),(\d+),(\d+)`)
+
+// ftpSession is a logged-in control connection.
+type ftpSession struct {
+	conn net.Conn
+	text *textproto.Conn
+	req  *http.Request
+	t    *Transport
+}
+
+// ftp fetches a file, or renders a directory listing, from an FTP server.
+func (t *Transport) ftp(req *http.Request) (*http.Response, error) {
+	conn, err := t.dial(req, ftpPort)
+	if err != nil {
+		return nil, err
+	}
+	s := &ftpSession{conn: conn, text: textproto.NewConn(conn), req: req, t: t}
+	if err := s.login(); err != nil {
+		s.close()
+		var tpErr *textproto.Error
+		if errors.As(err, &tpErr) && tpErr.Code == ftpNotLoggedIn {
+			return response(req, http.StatusForbidden, "", nil), nil
+		}
+		return nil, err
+	}
+
+	p := req.URL.Path
+	if p == "" {
+		p = "/"
+	}
+	if strings.HasSuffix(p, "/") {
+		return s.list(p)
+	}
+	if _, err := s.cmd(ftpFileActionOK, "CWD %s", p); err == nil {
+		// A directory requested without its trailing slash
+		s.close()
+		u := *req.URL
+		u.Path = p + "/"
+		return redirect(req, u.String()), nil
+	}
+	return s.retrieve(p)
+}
+
+// cmd sends a command and expects a reply in the class of code.
+func (s *ftpSession) cmd(code int, format string, args ...any) (string, error) {
+	if _, err := s.text.Cmd(format, args...); err != nil {
+		return "", fmt.Errorf("failed to send FTP command: %w", err)
+	}
+	_, msg, err := s.text.ReadResponse(code)
+	return msg, err
+}
+
+// login greets the server and logs in, anonymously unless the URL has credentials.
+func (s *ftpSession) login() error {
+	if _, _, err := s.text.ReadResponse(ftpReady); err != nil {
+		return err
+	}
+	user, password := anonymousUser, anonymousPassword
+	if s.req.URL.User != nil {
+		user = s.req.URL.User.Username()
+		if p, ok := s.req.URL.User.Password(); ok {
+			password = p
+		}
+	}
+	if _, err := s.text.Cmd("USER %s", user); err != nil {
+		return fmt.Errorf("failed to send FTP command: %w", err)
+	}
+	code, msg, err := s.text.ReadResponse(0)
+	if err != nil {
+		return err
+	}
+	switch code {
+	case ftpLoggedIn:
+	case ftpNeedPassword:
+		if _, err := s.cmd(ftpLoggedIn, "PASS %s", password); err != nil {
+			return err
+		}
+	default:
+		return &textproto.Error{Code: code, Msg: msg}
+	}
+	_, err = s.cmd(ftpCommandOK, "TYPE I")
+	return err
+}
+
+// dataConn opens a passive data connection, preferring EPSV.
+func (s *ftpSession) dataConn() (net.Conn, error) {
+	host := s.req.URL.Hostname()
+	var port int
+	if msg, err := s.cmd(ftpExtPassive, "EPSV"); err == nil {
+		// 229 Entering Extended Passive Mode (|||port|)
+		if start := strings.Index(msg, "|||"); start >= 0 {
+			if end := strings.Index(msg[start+3:], "|"); end >= 0 {
+				port, _ = strconv.Atoi(msg[start+3 : start+3+end])
+			}
+		}
+	}
+	if port == 0 {
+		msg, err := s.cmd(ftpPassive, "PASV")
+		if err != nil {
+			return nil, err
+		}
+		m := pasvAddr.FindStringSubmatch(msg)
+		if m == nil {
+			return nil, fmt.Errorf("failed to parse FTP passive reply %q", msg)
+		}
+		high, _ := strconv.Atoi(m[5])
+		low, _ := strconv.Atoi(m[6])
+		// The advertised address is ignored since it is often a private one
+		port = high<<8 | low
+	}
+	dialer := &net.Dialer{Timeout: s.t.Timeout}
+	conn, err := dialer.DialContext(s.req.Context(), "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open FTP data connection: %w", err)
+	}
+	return conn, nil
+}
+
+// transfer opens a data connection and starts the transfer command on it.
+func (s *ftpSession) transfer(format string, args ...any) (net.Conn, error) {
+	data, err := s.dataConn()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.text.Cmd(format, args...); err != nil {
+		data.Close()
+		return nil, fmt.Errorf("failed to send FTP command: %w", err)
+	}
+	code, msg, err := s.text.ReadResponse(0)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	if code != ftpFileStatusOK && code != ftpAlreadyOpen {
+		data.Close()
+		return nil, &textproto.Error{Code: code, Msg: msg}
+	}
+	return data, nil
+}
+
+// retrieve streams the file at p.
+func (s *ftpSession) retrieve(p string) (*http.Response, error) {
+	data, err := s.transfer("RETR %s", p)
+	if err != nil {
+		s.close()
+		var tpErr *textproto.Error
+		if errors.As(err, &tpErr) && tpErr.Code/ftpReplyCodeClass == ftpFileNotFound/ftpReplyCodeClass {
+			return response(s.req, http.StatusNotFound, "", nil), nil
+		}
+		return nil, err
+	}
+	return response(s.req, http.StatusOK, typeByName(p), &ftpBody{Conn: data, session: s}), nil
+}
+
+// list renders the directory p as an HTML listing.
+func (s *ftpSession) list(p string) (*http.Response, error) {
+	defer s.close()
+	if _, err := s.cmd(ftpFileActionOK, "CWD %s", p); err != nil {
+		return response(s.req, http.StatusNotFound, "", nil), nil
+	}
+	data, err := s.transfer("LIST")
+	if err != nil {
+		return nil, err
+	}
+	var entries []listingEntry
+	scanner := bufio.NewScanner(data)
+	for scanner.Scan() {
+		name, dir, ok := parseListLine(scanner.Text())
+		if !ok || name == "." || name == ".." {
+			continue
+		}
+		href := escapeSegment(name)
+		if dir {
+			name += "/"
+			href += "/"
+		}
+		entries = append(entries, listingEntry{Name: name, Href: href})
+	}
+	data.Close()
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read FTP listing: %w", err)
+	}
+	_, _, _ = s.text.ReadResponse(ftpTransferDone)
+	return response(s.req, http.StatusOK, htmlType, renderListing("Index of "+p, entries)), nil
+}
+
+// parseListLine parses a line of a Unix-style LIST reply, which puts the name
+// after eight columns, or of a DOS-style one with four.
+func parseListLine(line string) (name string, dir bool, ok bool) {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) >= 9 && len(fields[0]) == 10:
+		name = strings.Join(fields[8:], " ")
+		if i := strings.Index(name, " -> "); fields[0][0] == 'l' && i >= 0 {
+			name = name[:i]
+		}
+		return name, fields[0][0] == 'd', true
+	case len(fields) >= 4:
+		return strings.Join(fields[3:], " "), fields[2] == "<DIR>", true
+	}
+	return "", false, false
+}
+
+// close ends the session.
+func (s *ftpSession) close() {
+	_, _ = s.text.Cmd("QUIT")
+	s.conn.Close()
+}
+
+// ftpBody is the data connection of a download; closing it ends the session.
+type ftpBody struct {
+	net.Conn
+	session *ftpSession
+}
+
+// Close implements io.Closer.
+func (b *ftpBody) Close() error {
+	err := b.Conn.Close()
+	b.session.close()
+	return err
+}
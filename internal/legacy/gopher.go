@@ -0,0 +1,115 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package legacy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// gopherPort is the default Gopher port.
+	gopherPort = "70"
+	// gopherURLPrefix marks menu selectors that link to other protocols.
+	gopherURLPrefix = "URL:"
+)
+
+// Gopher item types.
+const (
+	gopherText   = '0'
+	gopherMenu   = '1'
+	gopherSearch = '7'
+	gopherBinary = '9'
+	gopherGIF    = 'g'
+	gopherImage  = 'I'
+	gopherHTML   = 'h'
+	gopherInfo   = 'i'
+)
+
+// gopher fetches a Gopher item, rendering menus as HTML listings. URLs follow
+// RFC 4266: the first path character is the item type, the rest the selector.
+func (t *Transport) gopher(req *http.Request) (*http.Response, error) {
+	itemType, selector := byte(gopherMenu), ""
+	if p := strings.TrimPrefix(req.URL.Path, "/"); p != "" {
+		itemType, selector = p[0], p[1:]
+	}
+	if itemType == gopherSearch && req.URL.RawQuery == "" {
+		// A search without a query has nothing to fetch
+		return response(req, http.StatusNotFound, "", nil), nil
+	}
+
+	conn, err := t.dial(req, gopherPort)
+	if err != nil {
+		return nil, err
+	}
+	request := selector
+	if req.URL.RawQuery != "" {
+		query, _ := url.QueryUnescape(req.URL.RawQuery)
+		request += "\t" + query
+	}
+	if _, err := fmt.Fprintf(conn, "%s\r\n", request); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send Gopher selector: %w", err)
+	}
+
+	switch itemType {
+	case gopherMenu, gopherSearch:
+		defer conn.Close()
+		entries, err := readMenu(conn)
+		if err != nil {
+			return nil, err
+		}
+		return response(req, http.StatusOK, htmlType, renderListing("Gopher menu "+req.URL.Host+"/"+selector, entries)), nil
+	case gopherText:
+		return response(req, http.StatusOK, "text/plain; charset=utf-8", conn), nil
+	case gopherHTML:
+		return response(req, http.StatusOK, htmlType, conn), nil
+	case gopherGIF:
+		return response(req, http.StatusOK, "image/gif", conn), nil
+	case gopherImage, gopherBinary:
+		return response(req, http.StatusOK, typeByName(selector), conn), nil
+	}
+	return response(req, http.StatusOK, typeByName(selector), conn), nil
+}
+
+// readMenu parses a Gopher menu into listing entries.
+func readMenu(r io.Reader) ([]listingEntry, error) {
+	var entries []listingEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "." {
+			break
+		}
+		if line == "" {
+			continue
+		}
+		itemType := line[0]
+		fields := strings.Split(line[1:], "\t")
+		display := fields[0]
+		if itemType == gopherInfo || len(fields) < 3 {
+			entries = append(entries, listingEntry{Name: display, Info: true})
+			continue
+		}
+		selector, host := fields[1], fields[2]
+		if len(fields) > 3 && fields[3] != "" && fields[3] != gopherPort {
+			host = net.JoinHostPort(host, fields[3])
+		}
+
+		href := (&url.URL{Scheme: SchemeGopher, Host: host, Path: "/" + string(itemType) + selector}).String()
+		if itemType == gopherHTML && strings.HasPrefix(selector, gopherURLPrefix) {
+			href = strings.TrimPrefix(selector, gopherURLPrefix)
+		}
+		entries = append(entries, listingEntry{Name: display, Href: href})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Gopher menu: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package legacy fetches ftp:// and gopher:// URLs through an
+// http.RoundTripper, so the crawler handles them like any other resource.
+// FTP directories and Gopher menus are rendered as HTML listings.
+package legacy
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// SchemeFTP is the scheme of FTP URLs.
+	SchemeFTP = "ftp"
+	// SchemeGopher is the scheme of Gopher URLs.
+	SchemeGopher = "gopher"
+
+	// htmlType is the content type of generated listings.
+	htmlType = "text/html; charset=utf-8"
+	// binaryType is the content type of files of unknown type.
+	binaryType = "application/octet-stream"
+)
+
+// Supports reports whether scheme is handled by this package.
+func Supports(scheme string) bool {
+	return scheme == SchemeFTP || scheme == SchemeGopher
+}
+
+// Transport routes FTP and Gopher requests to the built-in clients and
+// everything else to Next.
+type Transport struct {
+	// Next handles all other schemes; nil means http.DefaultTransport.
+	Next http.RoundTripper
+	// Timeout bounds connecting to a server.
+	Timeout time.Duration
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.URL.Scheme {
+	case SchemeFTP:
+		return t.ftp(req)
+	case SchemeGopher:
+		return t.gopher(req)
+	}
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(req)
+}
+
+// dial connects to the host of u, using defaultPort if it has none. The
+// connection is closed when the request context ends.
+func (t *Transport) dial(req *http.Request, defaultPort string) (net.Conn, error) {
+	port := req.URL.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	dialer := &net.Dialer{Timeout: t.Timeout}
+	conn, err := dialer.DialContext(req.Context(), "tcp", net.JoinHostPort(req.URL.Hostname(), port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", req.URL.Host, err)
+	}
+	context.AfterFunc(req.Context(), func() { conn.Close() })
+	return conn, nil
+}
+
+// response builds a response for req.
+func response(req *http.Request, status int, contentType string, body io.ReadCloser) *http.Response {
+	if body == nil {
+		body = http.NoBody
+	}
+	header := make(http.Header)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: -1,
+		Request:       req,
+	}
+}
+
+// redirect builds a redirect of req to location.
+func redirect(req *http.Request, location string) *http.Response {
+	resp := response(req, http.StatusMovedPermanently, "", nil)
+	resp.Header.Set("Location", location)
+	return resp
+}
+
+// typeByName guesses a content type from a file name.
+func typeByName(name string) string {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		return ct
+	}
+	return binaryType
+}
+
+// listingEntry is an entry of a generated listing.
+type listingEntry struct {
+	Name string
+	Href string
+	Info bool
+}
+
+// renderListing renders entries as an HTML page. FTP directories are titled
+// "Index of" like web server listings, so they are crawled as listings.
+func renderListing(title string, entries []listingEntry) io.ReadCloser {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s</title></head>\n<body><h1>%s</h1>\n<pre>\n", html.EscapeString(title), html.EscapeString(title))
+	for _, e := range entries {
+		if e.Info {
+			fmt.Fprintf(&b, "%s\n", html.EscapeString(e.Name))
+			continue
+		}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(e.Href), html.EscapeString(e.Name))
+	}
+	b.WriteString("</pre>\n</body></html>\n")
+	return io.NopCloser(strings.NewReader(b.String()))
+}
+
+// escapeSegment escapes a path segment for use in a relative link.
+func escapeSegment(name string) string {
+	return (&url.URL{Path: name}).EscapedPath()
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package linkcheck compares an archive with the live web to find link rot.
+package linkcheck
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/simhash"
+)
+
+// Status classifies the outcome of checking one archived resource.
+type Status string
+
+const (
+	// StatusUnchanged means the live resource is byte-identical to the archived copy.
+	StatusUnchanged Status = "unchanged"
+	// StatusEquivalent means a live page differs in bytes but not in meaningful text.
+	StatusEquivalent Status = "equivalent"
+	// StatusMinorChange means the live resource differs only slightly in size.
+	StatusMinorChange Status = "minor-change"
+	// StatusChanged means the live resource differs substantially.
+	StatusChanged Status = "changed"
+	// StatusRedirect means the live URL now redirects elsewhere.
+	StatusRedirect Status = "redirect"
+	// StatusGone means the live URL answers 404 or 410.
+	StatusGone Status = "gone"
+	// StatusHTTPError means the live URL answers another unexpected status.
+	StatusHTTPError Status = "http-error"
+	// StatusUnreachable means the live URL could not be fetched at all.
+	StatusUnreachable Status = "unreachable"
+)
+
+// workers is the number of resources checked concurrently.
+const workers = 8
+
+// Result is the outcome for one archived resource.
+type Result struct {
+	URL        string `json:"url"`
+	Status     Status `json:"status"`
+	HTTPStatus int    `json:"httpStatus,omitempty"`
+	Location   string `json:"location,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// Options tunes the live comparison.
+type Options struct {
+	// ChangeThreshold is the relative size difference above which a resource
+	// counts as changed rather than slightly changed.
+	ChangeThreshold float64
+}
+
+// AgainstLive re-fetches every resource of m without following redirects and
+// classifies how the live copy relates to the archived one.
+func AgainstLive(ctx context.Context, client *http.Client, m *manifest.Manifest, opts Options) []Result {
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return each(m.Resources, func(r manifest.Resource) Result {
+		return checkLive(ctx, &noRedirect, r, opts)
+	})
+}
+
+func each(resources []manifest.Resource, check func(manifest.Resource) Result) []Result {
+	results := make([]Result, len(resources))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = check(resources[i])
+			}
+		}()
+	}
+	for i := range resources {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func checkLive(ctx context.Context, client *http.Client, r manifest.Resource, opts Options) Result {
+	result := Result{URL: r.URL}
+	req, err := http.NewRequestWithContext(ctx, "GET", r.URL, nil)
+	if err != nil {
+		result.Status, result.Detail = StatusUnreachable, err.Error()
+		return result
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Status, result.Detail = StatusUnreachable, err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	result.HTTPStatus = resp.StatusCode
+
+	switch {
+	case resp.StatusCode >= http.StatusMultipleChoices && resp.StatusCode < http.StatusBadRequest:
+		result.Status, result.Location = StatusRedirect, resp.Header.Get("Location")
+		return result
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		result.Status = StatusGone
+		return result
+	case resp.StatusCode != http.StatusOK:
+		result.Status = StatusHTTPError
+		return result
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		result.Status, result.Detail = StatusUnreachable, err.Error()
+		return result
+	}
+	digest := sha256.Sum256(body)
+	if hex.EncodeToString(digest[:]) == r.Digest {
+		result.Status = StatusUnchanged
+		return result
+	}
+
+	// Pages are compared by their visible text, so ads and timestamps do not count
+	if archived, err := simhash.Parse(r.SimHash); err == nil && r.SimHash != "" {
+		change := simhash.Classify(archived, simhash.OfHTML(body))
+		result.Detail = string(change)
+		switch change {
+		case simhash.NoChange:
+			result.Status = StatusEquivalent
+		case simhash.MinorChange:
+			result.Status = StatusMinorChange
+		default:
+			result.Status = StatusChanged
+		}
+		return result
+	}
+
+	size := int64(len(body))
+	delta := relativeDifference(size, r.Size)
+	result.Detail = fmt.Sprintf("size %d -> %d bytes", r.Size, size)
+	if delta > opts.ChangeThreshold {
+		result.Status = StatusChanged
+	} else {
+		result.Status = StatusMinorChange
+	}
+	return result
+}
+
+func relativeDifference(a, b int64) float64 {
+	larger := max(a, b)
+	if larger == 0 {
+		return 0
+	}
+	diff := a - b
+	if diff < 0 {
+		diff = -diff
+	}
+	return float64(diff) / float64(larger)
+}
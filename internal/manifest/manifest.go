@@ -0,0 +1,315 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package manifest describes what a single archive run captured. A manifest is
+// written as JSON into the capture, beside the downloaded files, so later
+// tooling can inspect an archive without re-crawling it.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/quality"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+)
+
+const (
+	// Dir is the directory at the root of a capture holding its manifest.
+	// EscapePath keeps URL paths out of it, so a site's own manifest.json
+	// cannot take the place of the archive's.
+	Dir = ".website-archiver"
+	// FileName is the name of the manifest file inside Dir.
+	FileName = "manifest.json"
+	// Path is the slash-separated location of the manifest in a capture.
+	Path = Dir + "/" + FileName
+)
+
+// EscapePath returns the slash-separated capture path p of a URL with an
+// underscore prepended to every element that is Dir, or Dir already
+// prepended with underscores, so that no two URLs share a path and none
+// reaches into Dir, at the root or where a snapshot keeps its own.
+func EscapePath(p string) string {
+	elems := strings.Split(p, "/")
+	for i, elem := range elems {
+		if strings.EqualFold(strings.TrimLeft(elem, "_"), Dir) {
+			elems[i] = "_" + elem
+		}
+	}
+	return strings.Join(elems, "/")
+}
+
+// CaptureDir returns the capture a file met while walking a directory tree
+// describes, when the file is the manifest of a capture.
+func CaptureDir(file string) (string, bool) {
+	if filepath.Base(file) != FileName || filepath.Base(filepath.Dir(file)) != Dir {
+		return "", false
+	}
+	return filepath.Dir(filepath.Dir(file)), true
+}
+
+// Resource records a single fetched resource.
+type Resource struct {
+	URL         string `json:"url"`
+	Path        string `json:"path"`
+	ContentType string `json:"contentType,omitempty"`
+	Status      int    `json:"status"`
+	Size        int64  `json:"size"`
+	Digest      string `json:"digest,omitempty"`
+	// ContentEncoding lists the content codings the file is still encoded
+	// with, kept as served or without a decoder.
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+	// FetchedAt is when the response to this resource arrived, which can be
+	// long after CreatedAt in a large crawl.
+	FetchedAt time.Time `json:"fetchedAt"`
+	// Date is the Date header of the response, the server's clock at that
+	// moment, so skew against FetchedAt can be told apart.
+	Date string `json:"date,omitempty"`
+	// Title is the document title of HTML pages.
+	Title string `json:"title,omitempty"`
+	// SimHash fingerprints the visible text of HTML pages for near-duplicate detection.
+	SimHash string `json:"simhash,omitempty"`
+	// Patched marks content filled in from an archive because the live site no longer had it.
+	Patched     bool   `json:"patched,omitempty"`
+	ArchivedURL string `json:"archivedUrl,omitempty"`
+	// Paywalled marks pages the live site served behind a paywall, saved
+	// from the archive capture at ArchivedURL instead.
+	Paywalled bool `json:"paywalled,omitempty"`
+	// CDXDigest is the payload digest the CDX API lists for a Wayback
+	// snapshot; DigestMismatch marks a replayed payload that did not match it.
+	CDXDigest      string `json:"cdxDigest,omitempty"`
+	DigestMismatch bool   `json:"digestMismatch,omitempty"`
+	// CID is the IPFS content identifier reported by the gateway.
+	CID string `json:"cid,omitempty"`
+	// Caching headers of the response, used to schedule recrawls.
+	CacheControl string `json:"cacheControl,omitempty"`
+	Expires      string `json:"expires,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	// ETag is the entity tag of the response, sent back with If-None-Match
+	// when the capture is updated.
+	ETag string `json:"etag,omitempty"`
+	// Chunks lists the store objects the file was split into, in order, when
+	// the capture uses the chunked layout; the file itself is then absent.
+	Chunks []string `json:"chunks,omitempty"`
+	// Linked is the store object the file is hardlinked to, the digest of
+	// the file as saved, when the capture shares identical files.
+	Linked string `json:"linked,omitempty"`
+	// Redirects are the hops from the requested URL to URL when the
+	// request was redirected.
+	Redirects []Redirect `json:"redirects,omitempty"`
+}
+
+// Redirect is a hop of a redirect chain: a URL and the redirect status it
+// answered with.
+type Redirect struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// Stub is a page saved at Path, the path of a redirect hop, that forwards to
+// the file at Target.
+type Stub struct {
+	Path   string `json:"path"`
+	Target string `json:"target"`
+}
+
+// IPFS records the IPFS source of an archive run.
+type IPFS struct {
+	// URL is the ipfs:// or ipns:// seed.
+	URL string `json:"url"`
+	// Gateway is the HTTP gateway the content was fetched through.
+	Gateway string `json:"gateway"`
+	// CID is the root content identifier the seed resolved to.
+	CID string `json:"cid,omitempty"`
+}
+
+// Manifest is the record of an archive run. It is safe for concurrent use.
+type Manifest struct {
+	mu sync.Mutex
+
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Resources []Resource `json:"resources"`
+	WordPress *WordPress `json:"wordpress,omitempty"`
+	IPFS      *IPFS      `json:"ipfs,omitempty"`
+	// Tags and Note are what the user said the capture is for.
+	Tags []string `json:"tags,omitempty"`
+	Note string   `json:"note,omitempty"`
+	// Quality is the archive quality score of the capture.
+	Quality *quality.Report `json:"quality,omitempty"`
+	// Stubs are the redirect pages of the capture.
+	Stubs []Stub `json:"stubs,omitempty"`
+}
+
+// New creates an empty manifest for the given seed URL.
+func New(seedURL string) *Manifest {
+	return &Manifest{
+		URL:       seedURL,
+		CreatedAt: time.Now().UTC(),
+		Resources: []Resource{},
+	}
+}
+
+// Add appends a resource to the manifest.
+func (m *Manifest) Add(r Resource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Resources = append(m.Resources, r)
+}
+
+// Update applies fn to the resources fetched from url and reports whether
+// there were any.
+func (m *Manifest) Update(url string, fn func(*Resource)) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found := false
+	for i := range m.Resources {
+		if m.Resources[i].URL == url {
+			fn(&m.Resources[i])
+			found = true
+		}
+	}
+	return found
+}
+
+// SetWordPress attaches structured WordPress content to the manifest.
+func (m *Manifest) SetWordPress(wp *WordPress) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.WordPress = wp
+}
+
+// SetIPFS records the IPFS source of the archive.
+func (m *Manifest) SetIPFS(source *IPFS) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.IPFS = source
+}
+
+// SetIPFSRoot records the root CID once it is known.
+func (m *Manifest) SetIPFSRoot(cid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.IPFS != nil && m.IPFS.CID == "" {
+		m.IPFS.CID = cid
+	}
+}
+
+// SetQuality records the archive quality score of the capture.
+func (m *Manifest) SetQuality(q *quality.Report) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Quality = q
+}
+
+// AddStub records a redirect page, replacing the one saved earlier at the
+// same path.
+func (m *Manifest) AddStub(s Stub) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.Stubs {
+		if m.Stubs[i].Path == s.Path {
+			m.Stubs[i] = s
+			return
+		}
+	}
+	m.Stubs = append(m.Stubs, s)
+}
+
+// SetAnnotations replaces the tags and the note of the capture.
+func (m *Manifest) SetAnnotations(tags []string, note string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Tags = CleanTags(tags)
+	m.Note = strings.TrimSpace(note)
+}
+
+// CleanTags returns tags lower-cased, sorted, and without duplicates. Items
+// holding several comma-separated tags are split, and the semicolons ZIM
+// metadata separates tags with are dropped.
+func CleanTags(tags []string) []string {
+	var clean []string
+	for _, item := range tags {
+		for _, tag := range strings.Split(strings.ReplaceAll(item, ";", ""), ",") {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && !slices.Contains(clean, tag) {
+				clean = append(clean, tag)
+			}
+		}
+	}
+	slices.Sort(clean)
+	return clean
+}
+
+// Write stores the manifest as FileName inside dir.
+func (m *Manifest) Write(dir string, perms os.FileMode) error {
+	data, err := m.encode()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	// The metadata directory gets the permissions of the capture
+	if err := os.MkdirAll(filepath.Join(dir, Dir), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, Dir, FileName), data, perms); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// Save stores the manifest as Path in s.
+func (m *Manifest) Save(s storage.Storage) error {
+	data, err := m.encode()
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteFile(s, Path, data); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+func (m *Manifest) encode() ([]byte, error) {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return data, nil
+}
+
+// Load reads the manifest stored inside dir.
+func Load(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, Dir, FileName)) // #nosec G304 - dir is an archive directory chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return decode(data)
+}
+
+// Read reads the manifest stored as Path in s.
+func Read(s storage.Storage) (*Manifest, error) {
+	data, err := storage.ReadFile(s, Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return decode(data)
+}
+
+func decode(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package manifest
+
+// WordPress holds structured content pulled from a WordPress REST API.
+type WordPress struct {
+	APIRoot    string          `json:"apiRoot"`
+	Posts      []WordPressItem `json:"posts"`
+	Pages      []WordPressItem `json:"pages"`
+	Media      []WordPressItem `json:"media"`
+	Categories []WordPressTerm `json:"categories"`
+	Tags       []WordPressTerm `json:"tags"`
+}
+
+// WordPressItem is a post, page, or media attachment.
+type WordPressItem struct {
+	ID         int      `json:"id"`
+	Type       string   `json:"type"`
+	Slug       string   `json:"slug"`
+	Title      string   `json:"title"`
+	Link       string   `json:"link"`
+	Published  string   `json:"published"`
+	Modified   string   `json:"modified"`
+	Categories []string `json:"categories,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	SourceURL  string   `json:"sourceUrl,omitempty"`
+	MimeType   string   `json:"mimeType,omitempty"`
+}
+
+// WordPressTerm is a category or tag.
+type WordPressTerm struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package mimefilter decides from the Content-Type of a response whether it
+// is saved, so a crawl can keep text content without downloading video or
+// other large media.
+package mimefilter
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+)
+
+// defaultType is the media type of responses without a Content-Type, as
+// RFC 9110 lets recipients assume.
+const defaultType = "application/octet-stream"
+
+// Filter holds media type globs such as image/* or application/pdf. The
+// zero value accepts everything.
+type Filter struct {
+	accept []string
+	reject []string
+}
+
+// New returns a filter keeping the media types matching a glob of accept,
+// or any type when accept is empty, that match no glob of reject.
+func New(accept, reject []string) (Filter, error) {
+	var f Filter
+	for _, list := range []struct {
+		name  string
+		globs []string
+		dst   *[]string
+	}{{"accept", accept, &f.accept}, {"reject", reject, &f.reject}} {
+		for _, glob := range list.globs {
+			glob = strings.ToLower(strings.TrimSpace(glob))
+			if _, err := path.Match(glob, defaultType); err != nil || !strings.Contains(glob, "/") {
+				return Filter{}, fmt.Errorf("invalid %s MIME pattern %q, want type/subtype globs like image/*", list.name, glob)
+			}
+			*list.dst = append(*list.dst, glob)
+		}
+	}
+	return f, nil
+}
+
+// Active reports whether the filter rules out any media type.
+func (f Filter) Active() bool {
+	return len(f.accept) > 0 || len(f.reject) > 0
+}
+
+// Allow reports whether a response with contentType is saved. When it is
+// not, the reason names the media type and the rule it failed.
+func (f Filter) Allow(contentType string) (bool, string) {
+	if !f.Active() {
+		return true, ""
+	}
+	mediaType := MediaType(contentType)
+	for _, glob := range f.reject {
+		if match(glob, mediaType) {
+			return false, fmt.Sprintf("%s matches rejected MIME pattern %s", mediaType, glob)
+		}
+	}
+	if len(f.accept) == 0 {
+		return true, ""
+	}
+	for _, glob := range f.accept {
+		if match(glob, mediaType) {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("%s matches no accepted MIME pattern", mediaType)
+}
+
+// MediaType returns the lower-case media type of a Content-Type header,
+// without parameters such as the charset.
+func MediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(contentType, ";")
+	}
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		return defaultType
+	}
+	return mediaType
+}
+
+func match(glob, mediaType string) bool {
+	ok, _ := path.Match(glob, mediaType)
+	return ok
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package opds
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+// FeedType is the media type of an OPDS acquisition feed.
+const FeedType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+
+// Link relations of OPDS feeds.
+const (
+	relAcquisition = "http://opds-spec.org/acquisition/open-access"
+	relThumbnail   = "http://opds-spec.org/image/thumbnail"
+)
+
+type feed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	DC      string   `xml:"xmlns:dc,attr"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Author  author   `xml:"author"`
+	Links   []link   `xml:"link"`
+	Entries []entry  `xml:"entry"`
+}
+
+type author struct {
+	Name string `xml:"name"`
+}
+
+type link struct {
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr"`
+	Title  string `xml:"title,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type category struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type entry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Updated    string     `xml:"updated"`
+	Summary    string     `xml:"summary,omitempty"`
+	Language   string     `xml:"dc:language,omitempty"`
+	Publisher  string     `xml:"dc:publisher,omitempty"`
+	Author     *author    `xml:"author"`
+	Categories []category `xml:"category"`
+	Links      []link     `xml:"link"`
+}
+
+// WriteFeed writes an acquisition feed of pubs served at base, which serves
+// their files at base/files/<name> and the icons of ZIM archives at
+// base/illustration/<name>.
+func WriteFeed(w io.Writer, base string, pubs []Publication) error {
+	f := feed{
+		DC:      "http://purl.org/dc/terms/",
+		ID:      "urn:website-archiver:opds",
+		Title:   "website-archiver archives",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  author{Name: "website-archiver"},
+		Links: []link{
+			{Rel: "self", Href: base, Type: FeedType},
+			{Rel: "start", Href: base, Type: FeedType},
+		},
+	}
+	if len(pubs) > 0 {
+		f.Updated = pubs[0].Updated.UTC().Format(time.RFC3339)
+	}
+	for _, pub := range pubs {
+		e := entry{
+			ID:        pub.ID,
+			Title:     pub.Title,
+			Updated:   pub.Updated.UTC().Format(time.RFC3339),
+			Summary:   pub.Summary,
+			Language:  pub.Language,
+			Publisher: pub.Publisher,
+		}
+		if pub.Author != "" {
+			e.Author = &author{Name: pub.Author}
+		}
+		for _, tag := range pub.Tags {
+			e.Categories = append(e.Categories, category{Term: tag, Label: tag})
+		}
+		for i, file := range pub.Files {
+			l := link{Rel: relAcquisition, Href: base + "/files/" + url.PathEscape(file), Type: pub.MediaType}
+			if len(pub.Files) > 1 {
+				l.Title = fmt.Sprintf("Part %d of %d", i+1, len(pub.Files))
+			} else {
+				l.Length = pub.Size
+			}
+			e.Links = append(e.Links, l)
+		}
+		if pub.Illustrated {
+			e.Links = append(e.Links, link{Rel: relThumbnail, Href: base + "/illustration/" + url.PathEscape(pub.Files[0]), Type: "image/png"})
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(f)
+}
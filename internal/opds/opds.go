@@ -0,0 +1,226 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package opds lists the ZIM and EPUB archives of an output directory as an
+// OPDS 1.2 acquisition feed, so that e-readers and Kiwix clients can browse
+// and download them.
+package opds
+
+import (
+	"archive/zip"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/zim"
+)
+
+// Media types of the archives.
+const (
+	ZIMType  = "application/x-zim"
+	EPUBType = "application/epub+zip"
+)
+
+// illustrationPath is the ZIM metadata entry of the 48x48 PNG icon.
+const illustrationPath = "Illustration_48x48@1"
+
+// Publication is an archive of the output directory.
+type Publication struct {
+	// ID is a stable urn of the archive, the UUID for ZIM files.
+	ID string
+	// Files are the names of the archive files: the ZIM or EPUB file, or
+	// the parts of a split ZIM file.
+	Files       []string
+	MediaType   string
+	Title       string
+	Summary     string
+	Language    string
+	Author      string
+	Publisher   string
+	Tags        []string
+	Size        int64
+	Updated     time.Time
+	Illustrated bool
+}
+
+// Scan lists the archives directly in dir, newest first. Archives that cannot
+// be read are listed by file name only.
+func Scan(dir string) ([]Publication, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	var pubs []Publication
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		var pub Publication
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".zim":
+			pub = readZIM(dir, []string{name})
+		case ".zimaa":
+			pub = readZIM(dir, parts(entries, strings.TrimSuffix(name, "aa")))
+		case ".epub":
+			pub = readEPUB(dir, name)
+		default:
+			continue
+		}
+		for _, file := range pub.Files {
+			if info, err := os.Stat(filepath.Join(dir, file)); err == nil {
+				pub.Size += info.Size()
+				if pub.Updated.IsZero() || info.ModTime().After(pub.Updated) {
+					pub.Updated = info.ModTime()
+				}
+			}
+		}
+		pubs = append(pubs, pub)
+	}
+	sort.SliceStable(pubs, func(i, j int) bool { return pubs[i].Updated.After(pubs[j].Updated) })
+	return pubs, nil
+}
+
+// Find returns the archive named by file, one of its Files.
+func Find(pubs []Publication, file string) (Publication, bool) {
+	for _, pub := range pubs {
+		for _, f := range pub.Files {
+			if f == file {
+				return pub, true
+			}
+		}
+	}
+	return Publication{}, false
+}
+
+// Illustration returns the 48x48 PNG icon of a ZIM archive of dir.
+func Illustration(dir string, pub Publication) ([]byte, error) {
+	z, err := zim.Open(filepath.Join(dir, pub.Files[0]))
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	entry, err := z.Find(zim.NamespaceMetadata, illustrationPath)
+	if err != nil {
+		return nil, err
+	}
+	return z.Content(entry)
+}
+
+// parts returns the names of the parts of a split ZIM file in order.
+func parts(entries []os.DirEntry, base string) []string {
+	var names []string
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), base)
+		if ok && len(suffix) == 2 && suffix[0] >= 'a' && suffix[0] <= 'z' && suffix[1] >= 'a' && suffix[1] <= 'z' {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readZIM describes a ZIM file, or the parts of one, from its metadata.
+func readZIM(dir string, files []string) Publication {
+	name := strings.TrimSuffix(files[0], "aa")
+	pub := Publication{ID: "urn:website-archiver:" + name, Files: files, MediaType: ZIMType, Title: strings.TrimSuffix(name, ".zim")}
+	z, err := zim.Open(filepath.Join(dir, files[0]))
+	if err != nil {
+		return pub
+	}
+	defer z.Close()
+	uuid := hex.EncodeToString(z.Header.UUID[:])
+	pub.ID = fmt.Sprintf("urn:uuid:%s-%s-%s-%s-%s", uuid[0:8], uuid[8:12], uuid[12:16], uuid[16:20], uuid[20:])
+	meta := func(key string) string {
+		entry, err := z.Find(zim.NamespaceMetadata, key)
+		if err != nil {
+			return ""
+		}
+		data, err := z.Content(entry)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+	if title := meta("Title"); title != "" {
+		pub.Title = title
+	}
+	pub.Summary = meta("Description")
+	pub.Language = meta("Language")
+	pub.Author = meta("Creator")
+	pub.Publisher = meta("Publisher")
+	for _, tag := range strings.Split(meta("Tags"), ";") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			pub.Tags = append(pub.Tags, tag)
+		}
+	}
+	_, err = z.Find(zim.NamespaceMetadata, illustrationPath)
+	pub.Illustrated = err == nil
+	return pub
+}
+
+// epubContainer is META-INF/container.xml, which names the package document.
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage holds the Dublin Core metadata of the package document.
+type epubPackage struct {
+	Metadata struct {
+		Identifier  string   `xml:"identifier"`
+		Title       string   `xml:"title"`
+		Description string   `xml:"description"`
+		Language    string   `xml:"language"`
+		Creator     string   `xml:"creator"`
+		Publisher   string   `xml:"publisher"`
+		Subjects    []string `xml:"subject"`
+	} `xml:"metadata"`
+}
+
+// readEPUB describes an EPUB file from the metadata of its package document.
+func readEPUB(dir, file string) Publication {
+	pub := Publication{ID: "urn:website-archiver:" + file, Files: []string{file}, MediaType: EPUBType, Title: strings.TrimSuffix(file, filepath.Ext(file))}
+	zr, err := zip.OpenReader(filepath.Join(dir, file))
+	if err != nil {
+		return pub
+	}
+	defer zr.Close()
+	var container epubContainer
+	if err := decodeXML(zr, "META-INF/container.xml", &container); err != nil || len(container.Rootfiles) == 0 {
+		return pub
+	}
+	var pkg epubPackage
+	if err := decodeXML(zr, path.Clean(container.Rootfiles[0].FullPath), &pkg); err != nil {
+		return pub
+	}
+	m := pkg.Metadata
+	if m.Identifier != "" {
+		pub.ID = m.Identifier
+	}
+	if m.Title != "" {
+		pub.Title = m.Title
+	}
+	pub.Summary, pub.Language, pub.Author, pub.Publisher, pub.Tags = m.Description, m.Language, m.Creator, m.Publisher, m.Subjects
+	return pub
+}
+
+// maxEPUBDocument bounds the XML documents read from an EPUB file.
+const maxEPUBDocument = 1 << 20
+
+func decodeXML(zr *zip.ReadCloser, name string, v any) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(io.LimitReader(f, maxEPUBDocument)).Decode(v)
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package paywall recognizes pages served behind a paywall, which hold a
+// teaser and a subscription prompt instead of the article, so the crawl can
+// save an archive capture of the page in their place.
+package paywall
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Archives a paywalled page is taken from.
+const (
+	// None keeps the page as the site served it.
+	None = "none"
+	// Wayback takes the newest Wayback Machine capture of the page.
+	Wayback = "wayback"
+	// ArchiveToday takes the newest archive.today capture of the page.
+	ArchiveToday = "archive.today"
+	// Newest asks both and takes the newer of their captures.
+	Newest = "newest"
+)
+
+// CheckMode reports an error unless mode is an archive choice.
+func CheckMode(mode string) error {
+	switch mode {
+	case None, Wayback, ArchiveToday, Newest:
+		return nil
+	}
+	return fmt.Errorf("unknown paywall fallback %q (want %s, %s, %s, or %s)", mode, None, Wayback, ArchiveToday, Newest)
+}
+
+// signal is markup that gives away a paywall.
+type signal struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var signals = []signal{
+	// The structured data Google asks publishers to mark paywalled content with
+	{"isAccessibleForFree", regexp.MustCompile(`"isAccessibleForFree"\s*:\s*"?(?i:false)"?`)},
+	{"content-tier", regexp.MustCompile(`(?i)<meta[^>]+property=["']article:content_tier["'][^>]+content=["']locked["']`)},
+	{"paywall element", regexp.MustCompile(`(?i)\b(?:id|class|data-testid)=["'][^"']*\bpaywall\b`)},
+	{"subscriber prompt", regexp.MustCompile(`(?i)(?:subscribe|sign in|log in) to (?:continue|keep) reading|this (?:article|story|content) is (?:for|available to|exclusive to) (?:paid )?subscribers`)},
+}
+
+// Detect returns what gives away the paywall of an HTML page, or "" when it
+// has none.
+func Detect(body []byte) string {
+	for _, s := range signals {
+		if s.pattern.Match(body) {
+			return s.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,650 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+// Document is a PDF and the title of its bookmark in a merged PDF.
+type Document struct {
+	Title string
+	Data  []byte
+}
+
+// The objects of a PDF. Numbers, booleans, null, and strings are kept as
+// written.
+type (
+	object any
+	name   string
+	token  string
+	array  []object
+	dict   map[string]object
+	ref    struct{ num, gen int }
+	stream struct {
+		dict dict
+		data []byte
+	}
+)
+
+// inheritable are the attributes pages take from the page tree nodes above
+// them when they do not set them.
+var inheritable = []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// Objects of the merged PDF that come first.
+const (
+	catalogObj = 1 + iota
+	pagesObj
+	outlinesObj
+)
+
+// Merge writes one PDF with the pages of docs, in order, to w, with a
+// bookmark per document leading to its first page. Links between the
+// documents and their own bookmarks are not kept.
+func Merge(w io.Writer, docs []Document) error {
+	m := &merger{objects: make([]object, outlinesObj)}
+	var kids, items array
+	for _, doc := range docs {
+		f, err := parse(doc.Data)
+		if err != nil {
+			return fmt.Errorf("failed to read PDF of %s: %w", doc.Title, err)
+		}
+		m.src, m.renum = f, make(map[int]int)
+		catalog, _ := f.resolve(f.root).(dict)
+		pages := f.pages(catalog["Pages"], nil, make(map[int]bool))
+		if len(pages) == 0 {
+			return fmt.Errorf("PDF of %s has no pages", doc.Title)
+		}
+		// Pages are numbered first, as links on a page may lead to later ones
+		refs := make([]ref, len(pages))
+		for i, page := range pages {
+			refs[i] = m.add(nil)
+			if page.num > 0 {
+				m.renum[page.num] = refs[i].num
+			}
+		}
+		for i, page := range pages {
+			d := m.copy(page.dict).(dict)
+			d["Parent"] = ref{num: pagesObj}
+			m.objects[refs[i].num-1] = d
+			kids = append(kids, refs[i])
+		}
+		items = append(items, m.add(dict{
+			"Title":  utf16Text(doc.Title),
+			"Parent": ref{num: outlinesObj},
+			"Dest":   array{refs[0], name("Fit")},
+		}))
+	}
+	for i, item := range items {
+		d := m.objects[item.(ref).num-1].(dict)
+		if i > 0 {
+			d["Prev"] = items[i-1]
+		}
+		if i < len(items)-1 {
+			d["Next"] = items[i+1]
+		}
+	}
+	m.objects[catalogObj-1] = dict{
+		"Type":     name("Catalog"),
+		"Pages":    ref{num: pagesObj},
+		"Outlines": ref{num: outlinesObj},
+		"PageMode": name("UseOutlines"),
+	}
+	m.objects[pagesObj-1] = dict{"Type": name("Pages"), "Kids": kids, "Count": token(strconv.Itoa(len(kids)))}
+	m.objects[outlinesObj-1] = dict{
+		"Type":  name("Outlines"),
+		"First": items[0],
+		"Last":  items[len(items)-1],
+		"Count": token(strconv.Itoa(len(items))),
+	}
+	return m.write(w)
+}
+
+// merger collects the objects of the merged PDF.
+type merger struct {
+	objects []object
+	// src is the PDF copied from, and renum maps its object numbers to those
+	// of the merged PDF
+	src   *file
+	renum map[int]int
+}
+
+// add appends the object v and returns a reference to it.
+func (m *merger) add(v object) ref {
+	m.objects = append(m.objects, v)
+	return ref{num: len(m.objects)}
+}
+
+// copy returns v with the objects it references copied from the source PDF.
+// References to its page tree lead to that of the merged PDF.
+func (m *merger) copy(v object) object {
+	switch v := v.(type) {
+	case ref:
+		if num, ok := m.renum[v.num]; ok {
+			return ref{num: num}
+		}
+		target, ok := m.src.objects[v.num]
+		if !ok {
+			return token("null")
+		}
+		if d, ok := target.(dict); ok {
+			switch d["Type"] {
+			case name("Pages"):
+				return ref{num: pagesObj}
+			case name("Catalog"):
+				return token("null")
+			}
+		}
+		r := m.add(nil)
+		m.renum[v.num] = r.num
+		m.objects[r.num-1] = m.copy(target)
+		return r
+	case array:
+		out := make(array, len(v))
+		for i, item := range v {
+			out[i] = m.copy(item)
+		}
+		return out
+	case dict:
+		out := make(dict, len(v))
+		for k, item := range v {
+			out[k] = m.copy(item)
+		}
+		return out
+	case *stream:
+		// The length is written with the data, so an indirect one is not copied
+		d := make(dict, len(v.dict))
+		for k, item := range v.dict {
+			if k != "Length" {
+				d[k] = m.copy(item)
+			}
+		}
+		return &stream{dict: d, data: v.data}
+	}
+	return v
+}
+
+// write writes the merged PDF with its cross-reference table.
+func (m *merger) write(w io.Writer) error {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	fmt.Fprint(cw, "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int64, len(m.objects))
+	for i, v := range m.objects {
+		offsets[i] = cw.n
+		fmt.Fprintf(cw, "%d 0 obj\n", i+1)
+		writeObject(cw, v)
+		fmt.Fprint(cw, "\nendobj\n")
+	}
+	xref := cw.n
+	fmt.Fprintf(cw, "xref\n0 %d\n0000000000 65535 f \n", len(m.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(cw, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(cw, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(m.objects)+1, catalogObj, xref)
+	if cw.err != nil {
+		return fmt.Errorf("failed to write merged PDF: %w", cw.err)
+	}
+	return cw.w.Flush()
+}
+
+// writeObject writes v in PDF syntax.
+func writeObject(w io.Writer, v object) {
+	switch v := v.(type) {
+	case nil:
+		fmt.Fprint(w, "null")
+	case name:
+		fmt.Fprint(w, "/"+string(v))
+	case token:
+		fmt.Fprint(w, string(v))
+	case ref:
+		fmt.Fprintf(w, "%d %d R", v.num, v.gen)
+	case array:
+		fmt.Fprint(w, "[")
+		for i, item := range v {
+			if i > 0 {
+				fmt.Fprint(w, " ")
+			}
+			writeObject(w, item)
+		}
+		fmt.Fprint(w, "]")
+	case dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprint(w, "<<")
+		for _, k := range keys {
+			fmt.Fprint(w, " /"+k+" ")
+			writeObject(w, v[k])
+		}
+		fmt.Fprint(w, " >>")
+	case *stream:
+		d := make(dict, len(v.dict))
+		for k, item := range v.dict {
+			d[k] = item
+		}
+		d["Length"] = token(strconv.Itoa(len(v.data)))
+		writeObject(w, d)
+		fmt.Fprint(w, "\nstream\n")
+		_, _ = w.Write(v.data)
+		fmt.Fprint(w, "\nendstream")
+	}
+}
+
+// countingWriter counts the bytes written for the cross-reference table and
+// keeps the first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// utf16Text returns s as a PDF text string, which is UTF-16 with a byte
+// order mark.
+func utf16Text(s string) token {
+	var buf bytes.Buffer
+	buf.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&buf, "%04X", u)
+	}
+	buf.WriteString(">")
+	return token(buf.String())
+}
+
+// file is a parsed PDF.
+type file struct {
+	objects map[int]object
+	root    ref
+}
+
+// parse reads the objects of a PDF in the order they appear, so later
+// revisions replace earlier ones, rather than trusting its cross-reference
+// table, and unpacks its object streams.
+func parse(data []byte) (*file, error) {
+	f := &file{objects: make(map[int]object)}
+	l := &lexer{data: data}
+	for {
+		start := l.pos
+		tok, err := l.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Stray bytes between objects are skipped
+			l.pos = start + 1
+			continue
+		}
+		switch {
+		case tok == "trailer":
+			v, err := l.value()
+			if err != nil {
+				return nil, err
+			}
+			if d, ok := v.(dict); ok {
+				f.setRoot(d)
+			}
+		case isInt(tok):
+			pos := l.pos
+			gen, _ := l.next()
+			if kw, _ := l.next(); !isInt(gen) || kw != "obj" {
+				l.pos = pos
+				continue
+			}
+			v, err := l.value()
+			if err != nil {
+				return nil, fmt.Errorf("object %s: %w", tok, err)
+			}
+			if d, ok := v.(dict); ok && l.peek() == "stream" {
+				_, _ = l.next()
+				if r, ok := d["Length"].(ref); ok {
+					if n, ok := f.objects[r.num].(token); ok {
+						d["Length"] = n
+					}
+				}
+				v = l.stream(d)
+				f.setRoot(d)
+			}
+			num, _ := strconv.Atoi(tok)
+			f.objects[num] = v
+		}
+	}
+	// Objects written on their own take precedence over compressed ones
+	packed := make(map[int]object)
+	for _, v := range f.objects {
+		if s, ok := v.(*stream); ok && s.dict["Type"] == name("ObjStm") {
+			if err := unpack(s, packed); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for num, v := range packed {
+		if _, ok := f.objects[num]; !ok {
+			f.objects[num] = v
+		}
+	}
+	if f.root.num == 0 {
+		return nil, errors.New("no document catalog")
+	}
+	return f, nil
+}
+
+// setRoot takes the document catalog from a trailer or a cross-reference
+// stream.
+func (f *file) setRoot(d dict) {
+	if r, ok := d["Root"].(ref); ok {
+		f.root = r
+	}
+}
+
+// resolve follows v when it is a reference.
+func (f *file) resolve(v object) object {
+	if r, ok := v.(ref); ok {
+		return f.objects[r.num]
+	}
+	return v
+}
+
+// page is a page of a PDF, with its object number when it is an indirect
+// object.
+type page struct {
+	num  int
+	dict dict
+}
+
+// pages returns the pages below the page tree node v in order, as copies
+// with the attributes they inherit set, from the attributes inherited down
+// to v. The copies leave out their parent.
+func (f *file) pages(v object, inherited dict, seen map[int]bool) []page {
+	num := 0
+	if r, ok := v.(ref); ok {
+		if seen[r.num] {
+			return nil
+		}
+		seen[r.num], num = true, r.num
+	}
+	node, ok := f.resolve(v).(dict)
+	if !ok {
+		return nil
+	}
+	kids, ok := f.resolve(node["Kids"]).(array)
+	if !ok {
+		p := make(dict, len(node))
+		for k, item := range node {
+			p[k] = item
+		}
+		for _, k := range inheritable {
+			if _, ok := p[k]; !ok && inherited[k] != nil {
+				p[k] = inherited[k]
+			}
+		}
+		delete(p, "Parent")
+		return []page{{num: num, dict: p}}
+	}
+	below := make(dict, len(inheritable))
+	for _, k := range inheritable {
+		if item, ok := node[k]; ok {
+			below[k] = item
+		} else if item, ok := inherited[k]; ok {
+			below[k] = item
+		}
+	}
+	var pages []page
+	for _, kid := range kids {
+		pages = append(pages, f.pages(kid, below, seen)...)
+	}
+	return pages
+}
+
+// unpack adds the objects of the object stream s to objects.
+func unpack(s *stream, objects map[int]object) error {
+	data := s.data
+	filter := s.dict["Filter"]
+	if a, ok := filter.(array); ok && len(a) == 1 {
+		filter = a[0]
+	}
+	switch filter {
+	case nil:
+	case name("FlateDecode"):
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to decompress object stream: %w", err)
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return fmt.Errorf("failed to decompress object stream: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported object stream filter %v", s.dict["Filter"])
+	}
+	n, _ := strconv.Atoi(tokenText(s.dict["N"]))
+	first, _ := strconv.Atoi(tokenText(s.dict["First"]))
+	if first > len(data) {
+		return errors.New("object stream is cut off")
+	}
+	header := &lexer{data: data[:first]}
+	for range n {
+		num, _ := header.next()
+		off, _ := header.next()
+		o, err := strconv.Atoi(off)
+		if !isInt(num) || err != nil || first+o > len(data) {
+			return errors.New("malformed object stream")
+		}
+		v, err := (&lexer{data: data, pos: first + o}).value()
+		if err != nil {
+			return fmt.Errorf("object %s: %w", num, err)
+		}
+		i, _ := strconv.Atoi(num)
+		objects[i] = v
+	}
+	return nil
+}
+
+func tokenText(v object) string {
+	t, _ := v.(token)
+	return string(t)
+}
+
+func isInt(tok string) bool {
+	_, err := strconv.Atoi(tok)
+	return err == nil
+}
+
+// lexer reads the tokens and objects of PDF syntax.
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelim(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// skipSpace skips white space and comments.
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch c := l.data[l.pos]; {
+		case isSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next token. Strings are returned whole with their
+// delimiters.
+func (l *lexer) next() (string, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return "", io.EOF
+	}
+	start := l.pos
+	switch c := l.data[l.pos]; {
+	case c == '(':
+		depth := 0
+		for ; l.pos < len(l.data); l.pos++ {
+			switch l.data[l.pos] {
+			case '\\':
+				l.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 {
+				l.pos++
+				return string(l.data[start:l.pos]), nil
+			}
+		}
+		return "", errors.New("unterminated string")
+	case c == '<' || c == '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == c {
+			l.pos += 2
+			return string(l.data[start:l.pos]), nil
+		}
+		if c == '>' {
+			return "", errors.New("unexpected '>'")
+		}
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return "", errors.New("unterminated hex string")
+		}
+		l.pos += end + 1
+		return string(l.data[start:l.pos]), nil
+	case c == '[' || c == ']' || c == '{' || c == '}' || c == ')':
+		l.pos++
+		return string(c), nil
+	case c == '/':
+		l.pos++
+	}
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos]), nil
+}
+
+// peek returns the next token without consuming it.
+func (l *lexer) peek() string {
+	pos := l.pos
+	tok, _ := l.next()
+	l.pos = pos
+	return tok
+}
+
+// value reads the next object.
+func (l *lexer) value() (object, error) {
+	tok, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case tok == "<<":
+		d := make(dict)
+		for {
+			key, err := l.next()
+			if err != nil {
+				return nil, err
+			}
+			if key == ">>" {
+				return d, nil
+			}
+			if len(key) < 2 || key[0] != '/' {
+				return nil, fmt.Errorf("dictionary key %q is not a name", key)
+			}
+			v, err := l.value()
+			if err != nil {
+				return nil, err
+			}
+			d[key[1:]] = v
+		}
+	case tok == "[":
+		a := array{}
+		for l.peek() != "]" {
+			v, err := l.value()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, _ = l.next()
+		return a, nil
+	case tok[0] == '/':
+		return name(tok[1:]), nil
+	case tok == "]" || tok == ">>" || tok == "{" || tok == "}" || tok == ")":
+		return nil, fmt.Errorf("unexpected %q", tok)
+	case isInt(tok):
+		pos := l.pos
+		gen, _ := l.next()
+		if kw, _ := l.next(); isInt(gen) && kw == "R" {
+			num, _ := strconv.Atoi(tok)
+			g, _ := strconv.Atoi(gen)
+			return ref{num: num, gen: g}, nil
+		}
+		l.pos = pos
+	}
+	return token(tok), nil
+}
+
+// stream reads the data of the stream with dictionary d, which starts after
+// the stream keyword. Without a direct Length that fits, the data ends at the
+// first endstream keyword followed by endobj.
+func (l *lexer) stream(d dict) *stream {
+	if l.pos < len(l.data) && l.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+	if n, err := strconv.Atoi(tokenText(d["Length"])); err == nil && n >= 0 && start+n <= len(l.data) {
+		end := &lexer{data: l.data, pos: start + n}
+		if tok, _ := end.next(); tok == "endstream" {
+			l.pos = end.pos
+			return &stream{dict: d, data: l.data[start : start+n]}
+		}
+	}
+	end := -1
+	for from := start; end < 0; {
+		i := bytes.Index(l.data[from:], []byte("endstream"))
+		if i < 0 {
+			l.pos = len(l.data)
+			return &stream{dict: d, data: l.data[start:]}
+		}
+		after := &lexer{data: l.data, pos: from + i + len("endstream")}
+		if tok, _ := after.next(); tok == "endobj" {
+			end = from + i - start
+		}
+		from += i + len("endstream")
+	}
+	data := bytes.TrimSuffix(l.data[start:start+end], []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	l.pos = start + end + len("endstream")
+	return &stream{dict: d, data: data}
+}
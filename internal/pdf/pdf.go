@@ -0,0 +1,177 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package pdf prints the pages of a capture to PDF with a headless Chromium
+// and merges them into one PDF of the site with a bookmark per page, for
+// reading, printing, or handing a capture to people without a browser.
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/browser"
+)
+
+// printTimeout bounds the time printing a single page may take.
+const printTimeout = 60 * time.Second
+
+// Options configure the export.
+type Options struct {
+	// Index is the page of the capture printed first, "" for none.
+	Index     string
+	DirPerms  os.FileMode
+	FilePerms os.FileMode
+}
+
+// Export prints every page of the capture in dir to a PDF of the same path
+// inside dest and merges them into the PDF merged, bookmarked by page title.
+// Pages the browser fails to print are left out. It returns the number of
+// pages printed.
+func Export(ctx context.Context, dir, dest, merged string, opts Options) (int, error) {
+	chromium, err := browser.Find()
+	if err != nil {
+		return 0, err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+	pages, err := findPages(absDir, opts.Index)
+	if err != nil {
+		return 0, err
+	}
+
+	var docs []Document
+	for _, page := range pages {
+		out := filepath.Join(dest, strings.TrimSuffix(page, filepath.Ext(page))+".pdf")
+		if err := os.MkdirAll(filepath.Dir(out), opts.DirPerms); err != nil {
+			return len(docs), fmt.Errorf("failed to create directory for %s: %w", out, err)
+		}
+		file := filepath.Join(absDir, page)
+		if err := printPage(ctx, chromium, file, out); err != nil {
+			slog.Warn("Failed to print page to PDF", "error", err, "page", filepath.ToSlash(page))
+			continue
+		}
+		if err := os.Chmod(out, opts.FilePerms); err != nil {
+			return len(docs), fmt.Errorf("failed to set permissions of %s: %w", out, err)
+		}
+		data, err := os.ReadFile(out) // #nosec G304 - out was just written by the browser
+		if err != nil {
+			return len(docs), fmt.Errorf("failed to read %s: %w", out, err)
+		}
+		docs = append(docs, Document{Title: title(file, filepath.ToSlash(page)), Data: data})
+	}
+	if len(docs) == 0 {
+		return 0, fmt.Errorf("no page of %s could be printed", dir)
+	}
+
+	var buf bytes.Buffer
+	if err := Merge(&buf, docs); err != nil {
+		return len(docs), err
+	}
+	if err := os.WriteFile(merged, buf.Bytes(), opts.FilePerms); err != nil {
+		return len(docs), fmt.Errorf("failed to write %s: %w", merged, err)
+	}
+	return len(docs), nil
+}
+
+// findPages returns the pages of the capture in dir relative to it, index
+// first and the others in path order.
+func findPages(dir, index string) ([]string, error) {
+	var pages []string
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isPage(file) {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		if rel == filepath.FromSlash(index) {
+			pages = append([]string{rel}, pages...)
+		} else {
+			pages = append(pages, rel)
+		}
+		return nil
+	})
+	return pages, err
+}
+
+// isPage reports whether file is an HTML page, by its extension or, without
+// one, its content.
+func isPage(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".html", ".htm", ".xhtml":
+		return true
+	case "":
+		f, err := os.Open(file) // #nosec G304 - file is inside the capture directory
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		head := make([]byte, 512)
+		n, _ := f.Read(head)
+		return strings.HasPrefix(http.DetectContentType(head[:n]), "text/html")
+	}
+	return false
+}
+
+// printPage renders the local page file into the PDF out.
+func printPage(ctx context.Context, chromium, file, out string) error {
+	ctx, cancel := context.WithTimeout(ctx, printTimeout)
+	defer cancel()
+	// A PDF left by an earlier export must not pass for this one
+	_ = os.Remove(out)
+	args := []string{
+		"--headless", "--disable-gpu",
+		// The second spelling is that of Chromium before version 120
+		"--no-pdf-header-footer", "--print-to-pdf-no-header",
+		"--print-to-pdf=" + out,
+	}
+	cmd := browser.Command(ctx, chromium, append(args, "file://"+filepath.ToSlash(file))...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+	}
+	if info, err := os.Stat(out); err != nil || info.Size() == 0 {
+		return fmt.Errorf("browser wrote no PDF of %s", file)
+	}
+	return nil
+}
+
+// title returns the title of the page in file, or page without one.
+func title(file, page string) string {
+	f, err := os.Open(file) // #nosec G304 - file is inside the capture directory
+	if err != nil {
+		return page
+	}
+	defer f.Close()
+	z := html.NewTokenizer(f)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return page
+		case html.StartTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) != atom.Title {
+				continue
+			}
+			if z.Next() == html.TextToken {
+				if text := strings.Join(strings.Fields(string(z.Text())), " "); text != "" {
+					return text
+				}
+			}
+			return page
+		}
+	}
+}
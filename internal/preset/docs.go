@@ -0,0 +1,130 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package preset
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// searchFiles are the search index and support files of common documentation
+// generators (Sphinx, MkDocs, Docusaurus), relative to a version root. Search
+// pages load them from JavaScript, so a plain HTML crawl never discovers them.
+var searchFiles = []string{
+	"searchindex.js",
+	"objects.inv",
+	"search.html",
+	"_static/searchtools.js",
+	"_static/documentation_options.js",
+	"_static/language_data.js",
+	"search/search_index.json",
+	"search/main.js",
+	"search/worker.js",
+	"search/lunr.js",
+	"search-index.json",
+}
+
+// DocsScope builds the scope for documentation hosted at seed. The seed should
+// be the URL reached after redirects, so Read the Docs roots already point at
+// a language and version.
+func DocsScope(seed *url.URL, docVersions string) *Scope {
+	host := strings.ToLower(seed.Hostname())
+	segments := pathSegments(seed.Path)
+
+	var projectRoot, versionRoot string
+	switch {
+	case isReadTheDocs(host):
+		// /<language>/<version>/...
+		projectRoot = joinRoot(segments, 1)
+		versionRoot = joinRoot(segments, 2)
+	case strings.HasSuffix(host, ".github.io"), strings.HasSuffix(host, ".gitlab.io"):
+		// Project pages live below /<repository>/; user pages at the root.
+		projectRoot = joinRoot(segments, 1)
+		versionRoot = seedDir(seed.Path)
+	default:
+		projectRoot = "/"
+		versionRoot = seedDir(seed.Path)
+	}
+
+	scope := &Scope{Prefix: versionRoot, ProjectRoot: projectRoot}
+	roots := []string{versionRoot}
+	if docVersions == DocVersionsAll {
+		scope.Prefix = projectRoot
+		roots = []string{projectRoot}
+		if projectRoot != versionRoot {
+			roots = append(roots, versionRoot)
+		}
+		scope.VersionsFile = resolve(seed, projectRoot+"versions.json")
+	}
+
+	for _, root := range roots {
+		for _, file := range searchFiles {
+			scope.Files = append(scope.Files, resolve(seed, root+file))
+		}
+	}
+	return scope
+}
+
+// VersionSeeds turns the contents of a mike versions.json into seed URLs for
+// every published version below the project root.
+func (s *Scope) VersionSeeds(seed *url.URL, versionsJSON []byte) []string {
+	var versions []struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(versionsJSON, &versions); err != nil {
+		return nil
+	}
+	seeds := make([]string, 0, len(versions))
+	for _, v := range versions {
+		name := strings.Trim(v.Version, "/")
+		if name == "" || strings.Contains(name, "..") {
+			continue
+		}
+		seeds = append(seeds, resolve(seed, s.ProjectRoot+name+"/"))
+	}
+	return seeds
+}
+
+func isReadTheDocs(host string) bool {
+	return strings.HasSuffix(host, ".readthedocs.io") ||
+		strings.HasSuffix(host, ".readthedocs-hosted.com") ||
+		strings.HasSuffix(host, ".readthedocs.org")
+}
+
+func pathSegments(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// joinRoot returns the directory made of the first n directory segments.
+// The last segment only counts when it looks like a directory, not a page.
+func joinRoot(segments []string, n int) string {
+	if len(segments) > 0 && strings.Contains(segments[len(segments)-1], ".") {
+		segments = segments[:len(segments)-1]
+	}
+	if len(segments) > n {
+		segments = segments[:n]
+	}
+	if len(segments) == 0 {
+		return "/"
+	}
+	return "/" + strings.Join(segments, "/") + "/"
+}
+
+func seedDir(path string) string {
+	if idx := strings.LastIndex(path, "/"); idx != -1 {
+		return path[:idx+1]
+	}
+	return "/"
+}
+
+func resolve(seed *url.URL, path string) string {
+	return seed.ResolveReference(&url.URL{Path: path}).String()
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package preset adapts a crawl to well-known kinds of sites. A preset narrows
+// the crawl scope and adds seeds the HTML alone would not reveal.
+package preset
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Docs archives project documentation hosted on GitHub Pages, GitLab Pages, or Read the Docs.
+	Docs = "docs"
+
+	// DocVersionsLatest keeps only the documentation version the seed points at.
+	DocVersionsLatest = "latest"
+	// DocVersionsAll follows every published documentation version.
+	DocVersionsAll = "all"
+)
+
+// Scope describes how a preset shapes a crawl.
+type Scope struct {
+	// Prefix restricts page recursion to URL paths starting with it.
+	// Page requisites outside the prefix are still fetched.
+	Prefix string
+	// Seeds are crawled with full depth in addition to the main URL.
+	Seeds []string
+	// Files are fetched without recursion; missing ones are not an error.
+	Files []string
+	// ProjectRoot is the path that holds every version of the documentation.
+	ProjectRoot string
+	// VersionsFile, when set, lists further version roots (mike's versions.json).
+	VersionsFile string
+}
+
+// Validate checks a preset name and its options.
+func Validate(name, docVersions string) error {
+	switch name {
+	case "":
+		return nil
+	case Docs:
+		if docVersions != DocVersionsLatest && docVersions != DocVersionsAll {
+			return fmt.Errorf("doc-versions must be %q or %q", DocVersionsLatest, DocVersionsAll)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown preset %q", name)
+	}
+}
+
+// InScope reports whether u may be recursed into under the scope.
+func (s *Scope) InScope(u *url.URL) bool {
+	if s == nil || s.Prefix == "" {
+		return true
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	return strings.HasPrefix(path, s.Prefix) || path+"/" == s.Prefix
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package profile saves sets of command-line flags under a name in the
+// user's configuration directory, so long command lines for common kinds of
+// archive can be recalled with --profile <name>.
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// FlagProfile applies a saved profile; it may be repeated.
+	FlagProfile = "profile"
+	// FlagSave saves the flags of the command line as a profile.
+	FlagSave = "save-profile"
+
+	// appDir is the directory of the tool inside the user configuration directory.
+	appDir = "website-archiver"
+	// profilesDir holds one JSON file per profile.
+	profilesDir = "profiles"
+	// extension is the file extension of a stored profile.
+	extension = ".json"
+)
+
+// ErrNotFound is returned for a profile that was never saved.
+var ErrNotFound = errors.New("profile not found")
+
+// validName keeps profile names usable as file names.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Profile is a named set of flags.
+type Profile struct {
+	Name    string    `json:"name"`
+	Flags   []string  `json:"flags"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+// Dir returns the directory profiles are stored in.
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the configuration directory: %w", err)
+	}
+	return filepath.Join(dir, appDir, profilesDir), nil
+}
+
+// path returns the file of the profile called name.
+func path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid profile name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+extension), nil
+}
+
+// Save stores flags as the profile called name, replacing any earlier one.
+func Save(name string, flags []string, dirPerms, filePerms os.FileMode) error {
+	file, err := path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), dirPerms); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	data, err := json.MarshalIndent(Profile{Name: name, Flags: flags, SavedAt: time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode profile: %w", err)
+	}
+	if err := os.WriteFile(file, data, filePerms); err != nil {
+		return fmt.Errorf("failed to write profile %s: %w", name, err)
+	}
+	return nil
+}
+
+// Load reads the profile called name.
+func Load(name string) (*Profile, error) {
+	file, err := path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file) // #nosec G304 - the name is validated and the file lives in the profile directory
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile %s: %w", name, err)
+	}
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse profile %s: %w", name, err)
+	}
+	return &p, nil
+}
+
+// List returns the names of every saved profile, sorted.
+func List() ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), extension); ok && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete removes the profile called name.
+func Delete(name string) error {
+	file, err := path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete profile %s: %w", name, err)
+	}
+	return nil
+}
+
+// Split takes the profile flags out of a command line. It returns the
+// profiles to apply in order, the name to save the command line under (if
+// any), and the remaining arguments. Flag parsing stops at "--".
+func Split(args []string) (apply []string, save string, rest []string, err error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || (name != FlagProfile && name != FlagSave) {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, "", nil, fmt.Errorf("flag needs an argument: -%s", name)
+			}
+			i++
+			value = args[i]
+		}
+		if name == FlagProfile {
+			apply = append(apply, value)
+		} else {
+			save = value
+		}
+	}
+	return apply, save, rest, nil
+}
+
+// Expand splits the profile flags out of args and returns the flags of the
+// applied profiles followed by the remaining arguments, so flags given on the
+// command line override the profiles.
+func Expand(args []string) (profileFlags []string, save string, rest []string, err error) {
+	apply, save, rest, err := Split(args)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	for _, name := range apply {
+		p, err := Load(name)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		profileFlags = append(profileFlags, p.Flags...)
+	}
+	return profileFlags, save, rest, nil
+}
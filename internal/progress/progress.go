@@ -0,0 +1,92 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package progress carries crawl progress events from running jobs to
+// whoever is watching, such as the server's event stream.
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	// JobStarted and JobFinished bracket a whole job.
+	JobStarted  = "job-started"
+	JobFinished = "job-finished"
+	// Discovered reports a URL queued for the first time, with the page it
+	// was found on as Parent.
+	Discovered = "discovered"
+	// Started, Finished, and Failed report a single URL.
+	Started  = "started"
+	Finished = "finished"
+	Failed   = "failed"
+)
+
+// Event is a single progress update.
+type Event struct {
+	Job   string `json:"job,omitempty"`
+	Type  string `json:"type"`
+	URL   string `json:"url,omitempty"`
+	Bytes int64  `json:"bytes,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Stage and Status tell where a Failed URL failed and with which HTTP
+	// status, as in fetcherr.FetchError.
+	Stage  string `json:"stage,omitempty"`
+	Status int    `json:"status,omitempty"`
+	// Parent is the page a Discovered URL was found on.
+	Parent string `json:"parent,omitempty"`
+	// Queued is the number of URLs waiting in a persistent crawl frontier.
+	Queued int       `json:"queued,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Func receives events. It must not block.
+type Func func(Event)
+
+// subscriberBuffer is the number of events a slow subscriber may lag behind
+// before further events are dropped for it.
+const subscriberBuffer = 256
+
+// Bus fans events out to subscribers. It is safe for concurrent use.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewBus creates a bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Publish sends e to every subscriber, dropping it for those that are full.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of future events and a function ending the subscription.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package proxy
+
+import (
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/catalog"
+)
+
+// staleWarning marks responses served from the archive, as in RFC 7234.
+const staleWarning = `110 - "Response is Stale"`
+
+// Archiver is an http.Handler acting as a forward proxy that archives pages
+// in the background, stale-while-revalidate style: pages are served live,
+// and those whose latest capture is older than MaxAge are queued to be
+// archived again. When a site fails, its latest capture is served instead.
+type Archiver struct {
+	// Transport sends the upstream requests.
+	Transport http.RoundTripper
+	// CA issues certificates for intercepted HTTPS hosts.
+	CA *CA
+	// Timeout bounds reading request headers from the browser.
+	Timeout time.Duration
+	// Catalog holds the captures of the archive.
+	Catalog *catalog.Catalog
+	// MaxAge is how long a capture keeps a page fresh.
+	MaxAge time.Duration
+	// Queue receives the URLs of the pages to archive. Pages are skipped
+	// while it is full.
+	Queue chan<- string
+
+	mu sync.Mutex
+	// queued maps pages to when they were queued, so they are not queued
+	// again before their capture reaches Catalog
+	queued map[string]time.Time
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Archiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		intercept(w, r, p.CA, p.Timeout, p.forward)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is an archiving proxy; configure it as your browser's HTTP proxy", http.StatusBadRequest)
+		return
+	}
+	p.forward(w, r)
+}
+
+// forward sends r upstream and relays the response, queueing pages for the
+// archive, or serves the archived copy when the site fails.
+func (p *Archiver) forward(w http.ResponseWriter, r *http.Request) {
+	out, _, err := upstreamRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page := pageURL(out.URL)
+
+	resp, err := p.Transport.RoundTrip(out)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		if p.serveStale(w, out, page) {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return
+		}
+	}
+	if err != nil {
+		slog.Warn("Upstream request failed", "error", err, "url", out.URL.String())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	if isPage(out, resp) {
+		p.revalidate(page)
+	}
+	relay(w, resp, resp.Body)
+}
+
+// revalidate queues page for the archive unless it has a fresh capture or
+// was queued within MaxAge.
+func (p *Archiver) revalidate(page string) {
+	now := time.Now()
+	if captures := p.Catalog.Captures(page); len(captures) > 0 {
+		latest, err := time.Parse(catalog.TimestampFormat, captures[len(captures)-1].Timestamp)
+		if err == nil && now.Sub(latest) < p.MaxAge {
+			return
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.queued == nil {
+		p.queued = make(map[string]time.Time)
+	}
+	if at, ok := p.queued[page]; ok && now.Sub(at) < p.MaxAge {
+		return
+	}
+	select {
+	case p.Queue <- page:
+		p.queued[page] = now
+		slog.Info("Queued page for archiving", "url", page)
+	default:
+		slog.Warn("Archive queue is full, page not queued", "url", page)
+	}
+}
+
+// serveStale answers r with the latest capture of page and reports whether
+// there was one.
+func (p *Archiver) serveStale(w http.ResponseWriter, r *http.Request, page string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	var latest catalog.Entry
+	var f *os.File
+	captures := p.Catalog.Captures(page)
+	for i := len(captures) - 1; i >= 0 && f == nil; i-- {
+		file, err := p.Catalog.FilePath(captures[i])
+		if err != nil {
+			continue
+		}
+		if f, err = os.Open(file); err == nil { // #nosec G304 - FilePath keeps the path inside the output directory
+			latest = captures[i]
+		}
+	}
+	if f == nil {
+		return false
+	}
+	defer f.Close()
+
+	if latest.ContentType != "" {
+		w.Header().Set("Content-Type", latest.ContentType)
+	}
+	if t, err := time.Parse(catalog.TimestampFormat, latest.Timestamp); err == nil {
+		w.Header().Set("Memento-Datetime", t.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Warning", staleWarning)
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = io.Copy(w, f)
+	}
+	slog.Info("Served archived copy", "url", page, "timestamp", latest.Timestamp)
+	return true
+}
+
+// isPage reports whether resp is a page the browser navigated to, rather
+// than one of its assets or a frame.
+func isPage(r *http.Request, resp *http.Response) bool {
+	if r.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return false
+	}
+	dest := r.Header.Get("Sec-Fetch-Dest")
+	return dest == "" || dest == "document"
+}
+
+// pageURL returns u as the archive records it: without its fragment and
+// the default port, which intercepted HTTPS requests carry.
+func pageURL(u *url.URL) string {
+	page := *u
+	page.Fragment = ""
+	if port := page.Port(); (page.Scheme == "https" && port == "443") || (page.Scheme == "http" && port == "80") {
+		page.Host = strings.TrimSuffix(page.Host, ":"+port)
+	}
+	return page.String()
+}
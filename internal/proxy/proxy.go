@@ -0,0 +1,206 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package proxy implements local HTTP proxies that archive what is browsed
+// through them, including HTTPS traffic, which is intercepted with
+// certificates issued by a local CA. The recorder writes every exchange to a
+// WARC file; the archiver queues the pages for the archive.
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/warc"
+)
+
+// hopHeaders are connection-specific headers that are not forwarded.
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Recorder is an http.Handler acting as a forward proxy.
+type Recorder struct {
+	// Transport sends the upstream requests.
+	Transport http.RoundTripper
+	// WARC receives every exchange.
+	WARC *warc.Writer
+	// CA issues certificates for intercepted HTTPS hosts.
+	CA *CA
+	// Timeout bounds reading request headers from the browser.
+	Timeout time.Duration
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		intercept(w, r, p.CA, p.Timeout, p.forward)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is a recording proxy; configure it as your browser's HTTP proxy", http.StatusBadRequest)
+		return
+	}
+	p.forward(w, r)
+}
+
+// forward sends r upstream, records the exchange, and relays the response.
+func (p *Recorder) forward(w http.ResponseWriter, r *http.Request) {
+	out, reqBody, err := upstreamRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := p.Transport.RoundTrip(out)
+	if err != nil {
+		slog.Warn("Upstream request failed", "error", err, "url", out.URL.String())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if err := p.WARC.WriteExchange(out, reqBody, resp, respBody); err != nil {
+		slog.Error("Failed to record exchange", "error", err, "url", out.URL.String())
+	} else {
+		slog.Info("Recorded", "url", out.URL.String(), "status", resp.StatusCode, "bytes", len(respBody))
+	}
+	relay(w, resp, bytes.NewReader(respBody))
+}
+
+// upstreamRequest returns the request to send upstream for r, without its
+// hop-by-hop headers, along with its body.
+func upstreamRequest(r *http.Request) (*http.Request, []byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	return out, body, nil
+}
+
+// relay writes the response resp with the given body to the browser.
+func relay(w http.ResponseWriter, resp *http.Response, body io.Reader) {
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for key, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, body)
+}
+
+// intercept answers a CONNECT request and serves the tunnel over TLS with a
+// certificate from ca for the requested host, passing each request inside it
+// to forward.
+func intercept(w http.ResponseWriter, r *http.Request, ca *CA, timeout time.Duration, forward http.HandlerFunc) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	cert, err := ca.leaf(host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be intercepted", http.StatusInternalServerError)
+		return
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		conn.Close()
+		return
+	}
+
+	tlsConn := tls.Server(&bufferedConn{Conn: conn, reader: buffered.Reader}, &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		NextProtos:   []string{"http/1.1"},
+		MinVersion:   tls.VersionTLS12,
+	})
+	target := r.Host
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.URL.Scheme = "https"
+			r.URL.Host = target
+			forward(w, r)
+		}),
+		ReadHeaderTimeout: timeout,
+	}
+	_ = server.Serve(newSingleConnListener(tlsConn))
+}
+
+// bufferedConn reads what was buffered before the connection was hijacked first.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// singleConnListener hands out one connection and then blocks until it is
+// closed, so that an http.Server can serve a hijacked tunnel.
+type singleConnListener struct {
+	conn   net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newSingleConnListener(conn net.Conn) *singleConnListener {
+	l := &singleConnListener{closed: make(chan struct{})}
+	l.conn = &notifyConn{Conn: conn, closed: l.closed}
+	return l
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	var conn net.Conn
+	l.once.Do(func() { conn = l.conn })
+	if conn != nil {
+		return conn, nil
+	}
+	<-l.closed
+	return nil, net.ErrClosed
+}
+
+func (l *singleConnListener) Close() error   { return nil }
+func (l *singleConnListener) Addr() net.Addr { return l.conn.LocalAddr() }
+
+// notifyConn closes a channel when the connection is closed.
+type notifyConn struct {
+	net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (c *notifyConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package quality scores how complete a capture is. Like a Lighthouse score
+// it is a weighted mean of sub-scores, from 0 for nothing usable to 100 for
+// a capture that holds everything its pages reference.
+package quality
+
+import (
+	"math"
+	"sync"
+)
+
+// Weights of the sub-scores in the score. They add up to 1.
+const (
+	resourcesWeight = 0.4
+	linksWeight     = 0.3
+	robotsWeight    = 0.15
+	contentWeight   = 0.15
+)
+
+// Counts are what a crawl saw of the completeness of its capture.
+type Counts struct {
+	// Requisites are the images, stylesheets, scripts, and other resources
+	// pages reference, of which MissingRequisites could not be fetched
+	Requisites        int `json:"requisites"`
+	MissingRequisites int `json:"missingRequisites"`
+	// Links are the links followed to other pages of the site, of which
+	// BrokenLinks could not be fetched
+	Links       int `json:"links"`
+	BrokenLinks int `json:"brokenLinks"`
+	// RobotsBlocked are the URLs robots.txt kept from being fetched
+	RobotsBlocked int `json:"robotsBlocked"`
+	// StrippedResources are the responses left out by the size caps and
+	// media type filters, of StrippedBytes when their size was announced
+	StrippedResources int   `json:"strippedResources"`
+	StrippedBytes     int64 `json:"strippedBytes"`
+	// SavedBytes is the size of the capture
+	SavedBytes int64 `json:"savedBytes"`
+}
+
+// Report is the score of a capture with the sub-scores it is made of, each
+// from 0 to 1, and the counts they were computed from.
+type Report struct {
+	Score     int     `json:"score"`
+	Resources float64 `json:"resources"`
+	Links     float64 `json:"links"`
+	Robots    float64 `json:"robots"`
+	Content   float64 `json:"content"`
+	Counts
+}
+
+// Compute scores the counts of a capture.
+func Compute(c Counts) Report {
+	r := Report{
+		Resources: 1 - ratio(int64(c.MissingRequisites), int64(c.Requisites)),
+		Links:     1 - ratio(int64(c.BrokenLinks), int64(c.Links)),
+		Robots:    1 - ratio(int64(c.RobotsBlocked), int64(c.Links+c.RobotsBlocked)),
+		Content:   1 - ratio(c.StrippedBytes, c.SavedBytes+c.StrippedBytes),
+		Counts:    c,
+	}
+	score := resourcesWeight*r.Resources + linksWeight*r.Links + robotsWeight*r.Robots + contentWeight*r.Content
+	r.Score = int(math.Round(100 * score))
+	return r
+}
+
+// ratio returns part of whole, 0 for an empty whole.
+func ratio(part, whole int64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return math.Min(1, float64(part)/float64(whole))
+}
+
+// Counter collects the counts of a crawl. The zero value is empty and safe
+// for concurrent use.
+type Counter struct {
+	mu     sync.Mutex
+	counts Counts
+}
+
+// Fetched counts a fetched requisite or linked page, and whether it failed.
+func (c *Counter) Fetched(requisite, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if requisite {
+		c.counts.Requisites++
+		if failed {
+			c.counts.MissingRequisites++
+		}
+		return
+	}
+	c.counts.Links++
+	if failed {
+		c.counts.BrokenLinks++
+	}
+}
+
+// Blocked counts a URL robots.txt disallows.
+func (c *Counter) Blocked() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts.RobotsBlocked++
+}
+
+// Stripped counts a response left out of the capture, of size bytes when
+// known and positive.
+func (c *Counter) Stripped(size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts.StrippedResources++
+	if size > 0 {
+		c.counts.StrippedBytes += size
+	}
+}
+
+// Counts returns the counts so far.
+func (c *Counter) Counts() Counts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package referrer sets the Referer, Origin, and Sec-Fetch-* headers of crawl
+// requests. Some sites serve other content, or none, to requests without the
+// headers a browser following the same links would send.
+package referrer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Policies of the headers sent.
+const (
+	// None sends no referrer headers, as without a policy.
+	None = "none"
+	// Browser sends the headers a browser would: the Referer of the
+	// strict-origin-when-cross-origin policy, Origin with cross-origin font
+	// requests, and the Sec-Fetch-* metadata of the request.
+	Browser = "browser"
+	// Custom sends one fixed Referer with every request.
+	Custom = "custom"
+)
+
+// Policy sets the referrer headers of requests. The zero value sends none.
+type Policy struct {
+	mode    string
+	referer string
+}
+
+// New returns the policy mode, with the Referer the custom policy sends.
+func New(mode, referer string) (Policy, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "", None:
+		return Policy{}, nil
+	case Browser:
+		return Policy{mode: Browser}, nil
+	case Custom:
+		u, err := url.Parse(referer)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Policy{}, fmt.Errorf("the %s referrer policy needs an absolute http(s) --referer, got %q", Custom, referer)
+		}
+		return Policy{mode: Custom, referer: referer}, nil
+	default:
+		return Policy{}, fmt.Errorf("unknown referrer policy %q (want %s, %s, or %s)", mode, None, Browser, Custom)
+	}
+}
+
+type sourceKey struct{}
+
+// source is where the URL of a request was found.
+type source struct {
+	page      string
+	requisite bool
+}
+
+// WithSource returns ctx recording that its requests fetch a URL found on
+// page, as a resource the page uses when requisite and as a link otherwise.
+// Requests without a page are navigations typed into the address bar.
+func WithSource(ctx context.Context, page string, requisite bool) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source{page: page, requisite: requisite})
+}
+
+// Apply sets the headers of the policy on req.
+func (p Policy) Apply(req *http.Request) {
+	switch p.mode {
+	case Custom:
+		req.Header.Set("Referer", p.referer)
+	case Browser:
+		src, _ := req.Context().Value(sourceKey{}).(source)
+		var from *url.URL
+		if src.page != "" {
+			from, _ = url.Parse(src.page)
+		}
+		dest, mode := destination(req.URL, src.requisite)
+		req.Header.Set("Sec-Fetch-Dest", dest)
+		req.Header.Set("Sec-Fetch-Mode", mode)
+		req.Header.Set("Sec-Fetch-Site", site(from, req.URL))
+		if !src.requisite {
+			req.Header.Set("Sec-Fetch-User", "?1")
+		}
+		if ref := referer(from, req.URL); ref != "" {
+			req.Header.Set("Referer", ref)
+		}
+		if mode == "cors" && from != nil && origin(from) != origin(req.URL) {
+			req.Header.Set("Origin", origin(from))
+		}
+	}
+}
+
+// Destination returns the Sec-Fetch-Dest of req, which tells documents,
+// images, stylesheets, scripts, and fonts apart, from the URL and the source
+// recorded with WithSource.
+func Destination(req *http.Request) string {
+	src, _ := req.Context().Value(sourceKey{}).(source)
+	dest, _ := destination(req.URL, src.requisite)
+	return dest
+}
+
+// destinations are the Sec-Fetch-Dest of requisites by file extension, and
+// the modes they are requested in.
+var destinations = map[string][2]string{
+	".css": {"style", "no-cors"}, ".js": {"script", "no-cors"}, ".mjs": {"script", "cors"},
+	".png": {"image", "no-cors"}, ".jpg": {"image", "no-cors"}, ".jpeg": {"image", "no-cors"},
+	".gif": {"image", "no-cors"}, ".webp": {"image", "no-cors"}, ".avif": {"image", "no-cors"},
+	".svg": {"image", "no-cors"}, ".ico": {"image", "no-cors"}, ".bmp": {"image", "no-cors"},
+	".woff": {"font", "cors"}, ".woff2": {"font", "cors"}, ".ttf": {"font", "cors"},
+	".otf": {"font", "cors"}, ".eot": {"font", "cors"},
+	".mp4": {"video", "no-cors"}, ".webm": {"video", "no-cors"},
+	".mp3": {"audio", "no-cors"}, ".ogg": {"audio", "no-cors"}, ".wav": {"audio", "no-cors"},
+}
+
+// destination returns the Sec-Fetch-Dest and Sec-Fetch-Mode of a request
+// for u. The element that referenced a requisite is not known, so its file
+// extension tells.
+func destination(u *url.URL, requisite bool) (string, string) {
+	if !requisite {
+		return "document", "navigate"
+	}
+	if d, ok := destinations[strings.ToLower(path.Ext(u.Path))]; ok {
+		return d[0], d[1]
+	}
+	return "empty", "no-cors"
+}
+
+// site returns the Sec-Fetch-Site of a request for u made from the page at
+// from, which is nil for navigations without one.
+func site(from, u *url.URL) string {
+	switch {
+	case from == nil:
+		return "none"
+	case origin(from) == origin(u):
+		return "same-origin"
+	case registrable(from.Hostname()) == registrable(u.Hostname()) && from.Scheme == u.Scheme:
+		return "same-site"
+	default:
+		return "cross-site"
+	}
+}
+
+// registrable returns the registrable domain of host, or host itself when
+// it has none, such as an IP address.
+func registrable(host string) string {
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	return host
+}
+
+// referer returns the Referer of the strict-origin-when-cross-origin policy
+// for a request for u made from the page at from: the page without its
+// fragment on the same origin, its origin on others, and none when leaving
+// https for http.
+func referer(from, u *url.URL) string {
+	switch {
+	case from == nil || (from.Scheme == "https" && u.Scheme != "https"):
+		return ""
+	case origin(from) == origin(u):
+		page := *from
+		page.User, page.Fragment, page.RawFragment = nil, "", ""
+		return page.String()
+	default:
+		return origin(from) + "/"
+	}
+}
+
+// origin returns the serialized origin of u.
+func origin(u *url.URL) string {
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package remap applies user-defined URL rewrite rules, so resources of
+// sites spread over several hosts or URL schemes end up in one navigable
+// tree: a rule can fold a CDN host into the site or strip a locale prefix.
+package remap
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Separator divides the pattern and the replacement of a rule.
+const Separator = "=>"
+
+// Rule replaces matches of Pattern in a URL with Replacement, which may
+// refer to submatches as $1 or ${name}.
+type Rule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Parse parses a rule written as "pattern=>replacement".
+func Parse(spec string) (Rule, error) {
+	pattern, replacement, ok := strings.Cut(spec, Separator)
+	if !ok {
+		return Rule{}, fmt.Errorf("remap rule %q is not of the form pattern%sreplacement", spec, Separator)
+	}
+	re, err := regexp.Compile(strings.TrimSpace(pattern))
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid remap pattern %q: %w", pattern, err)
+	}
+	return Rule{Pattern: re, Replacement: strings.TrimSpace(replacement)}, nil
+}
+
+// Rules are applied in order, each to the result of the previous one.
+type Rules []Rule
+
+// Load parses rule specs and the rules in file, one per line, where blank
+// lines and lines starting with # are ignored. file may be empty.
+func Load(specs []string, file string) (Rules, error) {
+	var rules Rules
+	for _, spec := range specs {
+		rule, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if file == "" {
+		return rules, nil
+	}
+
+	f, err := os.Open(file) // #nosec G304 - the rules file is chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to open remap rules: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read remap rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Apply returns raw with every rule applied.
+func (r Rules) Apply(raw string) string {
+	for _, rule := range r {
+		raw = rule.Pattern.ReplaceAllString(raw, rule.Replacement)
+	}
+	return raw
+}
+
+// URL returns u with every rule applied. URLs the rules leave unchanged, or
+// turn into something unparsable, are returned as they are.
+func (r Rules) URL(u *url.URL) *url.URL {
+	if len(r) == 0 {
+		return u
+	}
+	raw := u.String()
+	mapped := r.Apply(raw)
+	if mapped == raw {
+		return u
+	}
+	parsed, err := url.Parse(mapped)
+	if err != nil {
+		return u
+	}
+	return parsed
+}
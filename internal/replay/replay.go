@@ -0,0 +1,208 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package replay serves archive directories over HTTP. Archives captured in
+// fidelity mode keep every page byte-for-byte as it was fetched and record link
+// rewrites separately; replay applies those rewrites on the fly.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/cssdoc"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+	"github.com/Sudo-Ivan/website-archiver/internal/xmldoc"
+	"golang.org/x/net/html"
+)
+
+// RewritesFile is the rewrite mapping in the metadata directory of an archive.
+const RewritesFile = manifest.Dir + "/rewrites.json"
+
+// Rewrites maps the archive path of a page to the attribute values that have
+// to be replaced in it, original value to local value. It is safe for
+// concurrent use.
+type Rewrites struct {
+	mu    sync.Mutex
+	Pages map[string]map[string]string `json:"pages"`
+}
+
+// NewRewrites creates an empty mapping.
+func NewRewrites() *Rewrites {
+	return &Rewrites{Pages: make(map[string]map[string]string)}
+}
+
+// Add records that value original in the page at pagePath points to local.
+func (r *Rewrites) Add(pagePath, original, local string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.Pages[pagePath]
+	if !ok {
+		m = make(map[string]string)
+		r.Pages[pagePath] = m
+	}
+	m[original] = local
+}
+
+// Retarget changes the local values of each page found in the mapping
+// returned for its path to the mapped ones.
+func (r *Rewrites) Retarget(mapping func(pagePath string) map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for pagePath, m := range r.Pages {
+		targets := mapping(pagePath)
+		for original, local := range m {
+			if target, ok := targets[local]; ok {
+				m[original] = target
+			}
+		}
+	}
+}
+
+// Write stores the mapping as RewritesFile in s.
+func (r *Rewrites) Write(s storage.Storage) error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode rewrites: %w", err)
+	}
+	if err := storage.WriteFile(s, RewritesFile, data); err != nil {
+		return fmt.Errorf("failed to write rewrites: %w", err)
+	}
+	return nil
+}
+
+// LoadRewrites reads the mapping stored as RewritesFile in s. Archives
+// captured without fidelity mode have none, which yields an empty mapping.
+func LoadRewrites(s storage.Storage) (*Rewrites, error) {
+	data, err := storage.ReadFile(s, RewritesFile)
+	if errors.Is(err, storage.ErrNotExist) {
+		return NewRewrites(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rewrites: %w", err)
+	}
+	r := NewRewrites()
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to parse rewrites: %w", err)
+	}
+	return r, nil
+}
+
+// linkAttrs are the attributes whose values may be rewritten.
+var linkAttrs = map[string]bool{"href": true, "src": true, "poster": true, "srcset": true, "imagesrcset": true}
+
+// Apply rewrites the link attributes, style elements, and style attributes of
+// an HTML document using mapping.
+func Apply(page []byte, mapping map[string]string) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page: %w", err)
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n.Data == "style" && n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				n.FirstChild.Data = string(cssdoc.Rewrite([]byte(n.FirstChild.Data), mapping))
+			}
+			for i, a := range n.Attr {
+				if a.Key == "style" {
+					n.Attr[i].Val = string(cssdoc.Rewrite([]byte(a.Val), mapping))
+					continue
+				}
+				if !linkAttrs[a.Key] {
+					continue
+				}
+				if local, ok := mapping[a.Val]; ok {
+					n.Attr[i].Val = local
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return nil, fmt.Errorf("failed to render page: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// legacyTypes are content types for extensions the standard library does not
+// know, so that browsers apply XSLT stylesheets during replay.
+var legacyTypes = map[string]string{
+	".xsl":  "text/xsl",
+	".xslt": "text/xsl",
+}
+
+// isXMLPage reports whether a page is an XML document rather than HTML.
+func isXMLPage(name string, page []byte) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".html", ".htm", ".xhtml":
+		return false
+	}
+	return strings.HasPrefix(http.DetectContentType(page), "text/xml")
+}
+
+// Handler serves the archive directory dir, applying recorded rewrites to pages.
+func Handler(dir string, rewrites *Rewrites) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+		}
+		if ct, ok := legacyTypes[strings.ToLower(path.Ext(name))]; ok {
+			w.Header().Set("Content-Type", ct)
+		}
+
+		rewrites.mu.Lock()
+		mapping, ok := rewrites.Pages[name]
+		rewrites.mu.Unlock()
+		if !ok {
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		page, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))) // #nosec G304 - name is cleaned and rooted in the archive directory
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.EqualFold(path.Ext(name), ".css") {
+			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+			_, _ = w.Write(cssdoc.Rewrite(page, mapping))
+			return
+		}
+		if isXMLPage(name, page) {
+			rewritten, err := xmldoc.Rewrite(page, mapping)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+			_, _ = w.Write(rewritten)
+			return
+		}
+		rewritten, err := Apply(page, mapping)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(rewritten)
+	})
+}
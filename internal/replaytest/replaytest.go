@@ -0,0 +1,224 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package replaytest loads the pages of a capture in a headless Chromium
+// that can reach nothing but the capture, and reports the resources that
+// fail to load, verifying that the capture is self-contained.
+package replaytest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/browser"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/replay"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+)
+
+const (
+	// ReportFile is the report in the metadata directory of a capture.
+	ReportFile = manifest.Dir + "/offline-replay-report.json"
+
+	// stubHost is the host the capture is served under. Names under .invalid
+	// never resolve, so only the proxy can answer for it.
+	stubHost = "archive.invalid"
+	// pageTimeout bounds the time the browser may take for a single page.
+	pageTimeout = 30 * time.Second
+	// settleBudget is the virtual time in milliseconds pages get to load
+	// their resources and run their scripts.
+	settleBudget = "5000"
+)
+
+// Reasons a resource fails to load.
+const (
+	ReasonMissing  = "missing from the capture"
+	ReasonExternal = "outside the capture"
+)
+
+// Failure is a resource a page of the capture could not load offline.
+// Resource is the path of a missing file inside the capture or the URL of
+// a resource elsewhere.
+type Failure struct {
+	Page     string `json:"page"`
+	Resource string `json:"resource"`
+	Reason   string `json:"reason"`
+}
+
+// Report is the result of the test.
+type Report struct {
+	Pages    int       `json:"pages"`
+	Failures []Failure `json:"failures"`
+}
+
+// Write stores the report as JSON as name in s.
+func (r *Report) Write(s storage.Storage, name string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode offline replay report: %w", err)
+	}
+	if err := storage.WriteFile(s, name, data); err != nil {
+		return fmt.Errorf("failed to write offline replay report: %w", err)
+	}
+	return nil
+}
+
+// Run loads the HTML pages recorded in m from their copies in dir, one at a
+// time, with rewrites applied as in replay. The browser goes through a proxy
+// that serves the capture and refuses everything else.
+func Run(ctx context.Context, dir string, m *manifest.Manifest, rewrites *replay.Rewrites) (*Report, error) {
+	chromium, err := browser.Find()
+	if err != nil {
+		return nil, err
+	}
+	profile, err := os.MkdirTemp("", "website-archiver-replay-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create browser profile: %w", err)
+	}
+	defer os.RemoveAll(profile)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start offline replay proxy: %w", err)
+	}
+	p := &proxy{archive: replay.Handler(dir, rewrites)}
+	srv := &http.Server{Handler: p, ReadHeaderTimeout: pageTimeout}
+	go func() { _ = srv.Serve(listener) }()
+	defer srv.Close()
+
+	report := &Report{Failures: []Failure{}}
+	seen := make(map[string]bool)
+	for _, r := range m.Resources {
+		if !strings.Contains(r.ContentType, "html") || seen[r.Path] || (r.Status != 0 && r.Status != 200) {
+			continue
+		}
+		seen[r.Path] = true
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p.start(r.Path)
+		err := load(ctx, chromium, profile, listener.Addr().String(), pageURL(r.Path))
+		failures := p.stop()
+		if err != nil {
+			slog.Warn("Failed to load page for the offline replay test", "error", err, "path", r.Path)
+			continue
+		}
+		report.Pages++
+		report.Failures = append(report.Failures, failures...)
+	}
+	return report, nil
+}
+
+// pageURL returns the URL the page at name is loaded from.
+func pageURL(name string) string {
+	// The file server redirects index pages to their directory
+	if path.Base(name) == "index.html" {
+		name = strings.TrimSuffix(name, "index.html")
+	}
+	return (&url.URL{Scheme: "http", Host: stubHost, Path: "/" + name}).String()
+}
+
+// load opens page in chromium until it has settled.
+func load(ctx context.Context, chromium, profile, proxyAddr, page string) error {
+	ctx, cancel := context.WithTimeout(ctx, pageTimeout)
+	defer cancel()
+	args := []string{
+		"--headless", "--disable-gpu", "--no-first-run", "--no-default-browser-check",
+		"--disable-background-networking", "--disable-component-update", "--disable-sync", "--disable-default-apps",
+		"--user-data-dir=" + profile,
+		"--proxy-server=http://" + proxyAddr,
+		// Loopback addresses would otherwise bypass the proxy
+		"--proxy-bypass-list=<-loopback>",
+		// Nothing the proxy is not asked for can be resolved
+		"--host-resolver-rules=MAP * ~NOTFOUND",
+		"--force-webrtc-ip-handling-policy=disable_non_proxied_udp",
+		"--virtual-time-budget=" + settleBudget,
+		"--dump-dom",
+	}
+	cmd := browser.Command(ctx, chromium, append(args, page)...)
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+// proxy serves the capture under stubHost to the browser and records the
+// requests of the page being loaded that fail.
+type proxy struct {
+	archive http.Handler
+
+	mu       sync.Mutex
+	page     string
+	failures []Failure
+	seen     map[string]bool
+}
+
+func (p *proxy) start(page string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.page, p.failures, p.seen = page, nil, make(map[string]bool)
+}
+
+func (p *proxy) stop() []Failure {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	failures := p.failures
+	p.page, p.failures, p.seen = "", nil, nil
+	return failures
+}
+
+func (p *proxy) fail(resource, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.seen == nil || p.seen[resource] {
+		return
+	}
+	p.seen[resource] = true
+	p.failures = append(p.failures, Failure{Page: p.page, Resource: resource, Reason: reason})
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		// Only the host of HTTPS requests is seen before they are refused
+		p.fail("https://"+strings.TrimSuffix(r.Host, ":443"), ReasonExternal)
+		http.Error(w, "offline", http.StatusForbidden)
+		return
+	}
+	if r.URL.Host != stubHost {
+		p.fail(r.URL.String(), ReasonExternal)
+		http.Error(w, "offline", http.StatusBadGateway)
+		return
+	}
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	p.archive.ServeHTTP(rec, r)
+	// Browsers ask for the icon of a page that does not link to one
+	if rec.status == http.StatusNotFound && r.URL.Path != "/favicon.ico" {
+		p.fail(strings.TrimPrefix(r.URL.Path, "/"), ReasonMissing)
+	}
+}
+
+// statusRecorder remembers the status of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
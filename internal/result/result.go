@@ -0,0 +1,190 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package result collects what a download of a URL produced: the outcome of
+// every URL it fetched, the bytes received, the files it created, and the
+// problems it worked around. The command line writes it as JSON and the
+// server returns it with jobs, so wrappers do not have to read the logs to
+// know what was archived.
+package result
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/downloader"
+	"github.com/Sudo-Ivan/website-archiver/internal/fetcherr"
+	"github.com/Sudo-Ivan/website-archiver/internal/progress"
+)
+
+// Statuses of a download.
+const (
+	StatusDone        = "done"
+	StatusFailed      = "failed"
+	StatusInterrupted = "interrupted"
+)
+
+// Outcomes of a URL.
+const (
+	Fetched = "fetched"
+	Failed  = "failed"
+)
+
+// Kinds of outputs.
+const (
+	Capture = "capture"
+	ZIM     = "zim"
+	Torrent = "torrent"
+	WARC    = "warc"
+	Single  = "single-file"
+	MHTML   = "mhtml"
+	PDF     = "pdf"
+	EPUB    = "epub"
+)
+
+// Resource is the outcome of a URL of the download.
+type Resource struct {
+	URL     string `json:"url"`
+	Outcome string `json:"outcome"`
+	Bytes   int64  `json:"bytes,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Stage and Status tell where a failed URL failed and with which HTTP
+	// status, as in fetcherr.FetchError.
+	Stage  string `json:"stage,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+// Output is a file or directory the download created.
+type Output struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+// Result is what the download of URL produced. It is safe for concurrent
+// use while the download runs.
+type Result struct {
+	URL        string    `json:"url"`
+	Status     string    `json:"status"`
+	OutputDir  string    `json:"outputDir,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Error      string    `json:"error,omitempty"`
+	// Failure tells which URL and stage failed, when the error says.
+	Failure *fetcherr.FetchError `json:"failure,omitempty"`
+	// Fetched, Failed, and Bytes count the URLs of Resources.
+	Fetched   int        `json:"fetched"`
+	Failed    int        `json:"failed"`
+	Bytes     int64      `json:"bytes"`
+	Resources []Resource `json:"resources"`
+	Outputs   []Output   `json:"outputs"`
+	// Warnings are steps that failed without failing the download.
+	Warnings []string `json:"warnings"`
+
+	mu sync.Mutex
+	// index maps URLs to their place in Resources
+	index map[string]int
+}
+
+// New starts the result of a download of url.
+func New(url string) *Result {
+	return &Result{
+		URL:       url,
+		StartedAt: time.Now().UTC(),
+		Resources: []Resource{},
+		Outputs:   []Output{},
+		Warnings:  []string{},
+		index:     make(map[string]int),
+	}
+}
+
+// Observe records the outcome of the URL of a progress event. A URL fetched
+// again, such as after a challenge, keeps its last outcome.
+func (r *Result) Observe(e progress.Event) {
+	var res Resource
+	switch e.Type {
+	case progress.Finished:
+		res = Resource{URL: e.URL, Outcome: Fetched, Bytes: e.Bytes}
+	case progress.Failed:
+		res = Resource{URL: e.URL, Outcome: Failed, Error: e.Error, Stage: e.Stage, Status: e.Status}
+	default:
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.index[e.URL]
+	if !ok {
+		r.index[e.URL] = len(r.Resources)
+		r.Resources = append(r.Resources, res)
+		r.count(res, 1)
+		return
+	}
+	r.count(r.Resources[i], -1)
+	r.Resources[i] = res
+	r.count(res, 1)
+}
+
+// count adds res to the totals, or takes it away with sign -1.
+func (r *Result) count(res Resource, sign int) {
+	if res.Outcome == Fetched {
+		r.Fetched += sign
+	} else {
+		r.Failed += sign
+	}
+	r.Bytes += int64(sign) * res.Bytes
+}
+
+// AddOutput records a file or directory of kind the download created. Like
+// Warn, it does nothing on a nil result.
+func (r *Result) AddOutput(kind, path string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Outputs = append(r.Outputs, Output{Kind: kind, Path: path})
+}
+
+// Warn records a step that failed with err without failing the download.
+func (r *Result) Warn(step string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Warnings = append(r.Warnings, fmt.Sprintf("%s: %v", step, err))
+}
+
+// Finish records how the download ended and returns r.
+func (r *Result) Finish(outputDir string, err error) *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.FinishedAt = time.Now().UTC()
+	r.OutputDir = outputDir
+	r.Status = StatusDone
+	if err != nil {
+		r.Status = StatusFailed
+		if errors.Is(err, downloader.ErrInterrupted) {
+			r.Status = StatusInterrupted
+		}
+		r.Error = err.Error()
+		r.Failure, _ = fetcherr.As(err)
+	}
+	return r
+}
+
+// Write writes results to w as an indented JSON array.
+func Write(w io.Writer, results []*Result) error {
+	if results == nil {
+		results = []*Result{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	return nil
+}
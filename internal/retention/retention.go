@@ -0,0 +1,214 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package retention enforces how many captures of each collection are kept.
+// A collection is every capture of one domain in the output directory, both
+// raw download directories and ZIM files.
+package retention
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// dirTimestampLayout matches the suffix of download directories (<domain>_<timestamp>).
+	dirTimestampLayout = "20060102_150405"
+	// zimTimestampLayout matches the suffix of ZIM files (<domain>_<date>.zim).
+	zimTimestampLayout = "20060102"
+	// zimExt is the extension of ZIM files.
+	zimExt = ".zim"
+	// bytesPerGB converts the MaxGB setting to bytes.
+	bytesPerGB = 1 << 30
+)
+
+// Policy describes which captures are kept. Zero values disable a rule.
+type Policy struct {
+	// KeepLast keeps only the newest N captures of each collection.
+	KeepLast int
+	// MaxAge removes captures older than this.
+	MaxAge time.Duration
+	// MaxGB caps the total size of each collection.
+	MaxGB float64
+}
+
+// Enabled reports whether any rule is set.
+func (p Policy) Enabled() bool {
+	return p.KeepLast > 0 || p.MaxAge > 0 || p.MaxGB > 0
+}
+
+// Capture is a single archive run stored in the output directory. A split
+// ZIM file is one capture made of several parts.
+type Capture struct {
+	Collection string
+	Paths      []string
+	Time       time.Time
+	Size       int64
+}
+
+// Scan groups the captures found directly inside root by collection.
+func Scan(root string) (map[string][]Capture, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output directory %s: %w", root, err)
+	}
+
+	captures := make(map[string]*Capture)
+	var order []string
+	for _, entry := range entries {
+		key, capture, ok := parseCapture(root, entry)
+		if !ok {
+			continue
+		}
+		size, err := diskUsage(capture.Paths[0])
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := captures[key]; ok {
+			existing.Paths = append(existing.Paths, capture.Paths...)
+			existing.Size += size
+			continue
+		}
+		capture.Size = size
+		captures[key] = &capture
+		order = append(order, key)
+	}
+
+	collections := make(map[string][]Capture)
+	for _, key := range order {
+		c := captures[key]
+		collections[c.Collection] = append(collections[c.Collection], *c)
+	}
+	return collections, nil
+}
+
+// zimBase strips the extension of a ZIM file or of a split part (.zimaa), so
+// every part of one ZIM resolves to the same capture.
+func zimBase(name string) (string, bool) {
+	if strings.HasSuffix(name, zimExt) {
+		return strings.TrimSuffix(name, zimExt), true
+	}
+	idx := strings.LastIndex(name, zimExt)
+	if idx == -1 || len(name)-idx != len(zimExt)+2 {
+		return "", false
+	}
+	for _, r := range name[idx+len(zimExt):] {
+		if r < 'a' || r > 'z' {
+			return "", false
+		}
+	}
+	return name[:idx], true
+}
+
+// parseCapture recognizes a capture and returns the key shared by all of its parts.
+func parseCapture(root string, entry fs.DirEntry) (string, Capture, bool) {
+	name := entry.Name()
+	layout := dirTimestampLayout
+	base := name
+	if !entry.IsDir() {
+		var ok bool
+		if base, ok = zimBase(name); !ok {
+			return "", Capture{}, false
+		}
+		layout = zimTimestampLayout
+	}
+	if len(base) <= len(layout) || base[len(base)-len(layout)-1] != '_' {
+		return "", Capture{}, false
+	}
+	t, err := time.ParseInLocation(layout, base[len(base)-len(layout):], time.Local)
+	if err != nil {
+		return "", Capture{}, false
+	}
+	key := base
+	if !entry.IsDir() {
+		key += zimExt
+	}
+	return key, Capture{
+		Collection: base[:len(base)-len(layout)-1],
+		Paths:      []string{filepath.Join(root, name)},
+		Time:       t,
+	}, true
+}
+
+func diskUsage(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure %s: %w", path, err)
+	}
+	return total, nil
+}
+
+// Expired returns the captures of one collection the policy does not keep.
+// The newest capture is always kept so a collection never disappears entirely.
+func (p Policy) Expired(captures []Capture, now time.Time) []Capture {
+	sorted := append([]Capture(nil), captures...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time.After(sorted[j].Time) })
+
+	var expired []Capture
+	var kept int64
+	for i, c := range sorted {
+		if i == 0 {
+			kept += c.Size
+			continue
+		}
+		switch {
+		case p.KeepLast > 0 && i >= p.KeepLast,
+			p.MaxAge > 0 && now.Sub(c.Time) > p.MaxAge,
+			p.MaxGB > 0 && float64(kept+c.Size) > p.MaxGB*bytesPerGB:
+			expired = append(expired, c)
+		default:
+			kept += c.Size
+		}
+	}
+	return expired
+}
+
+// Prune applies the policy to every collection in root and returns the
+// captures it removed, or would remove when dryRun is set.
+func Prune(root string, p Policy, dryRun bool) ([]Capture, error) {
+	collections, err := Scan(root)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(collections))
+	for name := range collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	var removed []Capture
+	for _, name := range names {
+		for _, c := range p.Expired(collections[name], now) {
+			if !dryRun {
+				for _, path := range c.Paths {
+					if err := os.RemoveAll(path); err != nil {
+						return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+					}
+				}
+			}
+			removed = append(removed, c)
+		}
+	}
+	return removed, nil
+}
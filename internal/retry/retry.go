@@ -0,0 +1,181 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package retry retries HTTP requests that failed for transient reasons,
+// network errors and statuses such as 503, with exponential backoff and
+// jitter, so a hiccup of a server does not lose a resource for good.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxBackoff caps the wait between two attempts.
+	maxBackoff = time.Minute
+	// drainLimit is the part of a failed response read so its connection
+	// can be reused.
+	drainLimit = 64 << 10
+)
+
+// DefaultStatuses are the statuses retried when none are configured.
+var DefaultStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Policy says which requests are retried and how long to wait between
+// attempts.
+type Policy struct {
+	// MaxAttempts is the number of attempts, the first one included; 1 or
+	// less disables retries.
+	MaxAttempts int
+	// Base is the wait before the second attempt. It doubles with every
+	// attempt, and each wait is drawn uniformly below it (full jitter).
+	Base time.Duration
+	// Statuses are the response statuses retried.
+	Statuses []int
+}
+
+// Transport is an http.RoundTripper retrying requests according to Policy.
+// Each attempt gets its own Timeout, which lasts until the response body is
+// closed, so a hung attempt does not use up the time of the ones after it.
+type Transport struct {
+	// Next is the wrapped transport; nil means http.DefaultTransport.
+	Next    http.RoundTripper
+	Policy  Policy
+	Timeout time.Duration
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.Body != nil {
+			// The body of the previous attempt was consumed
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		if n, ok := req.Context().Value(attemptsKey{}).(*atomic.Int64); ok {
+			n.Store(int64(attempt))
+		}
+		resp, err := t.attempt(req)
+		if attempt >= t.Policy.MaxAttempts || !t.retryable(req, resp, err) {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				wait = min(after, maxBackoff)
+			}
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, drainLimit))
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// attemptsKey is the context key of an attempt counter.
+type attemptsKey struct{}
+
+// TrackAttempts returns a context whose requests record how many attempts
+// they took, and a function returning the attempts of the last of them. It
+// returns 0 when no request went through a Transport.
+func TrackAttempts(ctx context.Context) (context.Context, func() int) {
+	n := new(atomic.Int64)
+	return context.WithValue(ctx, attemptsKey{}, n), func() int { return int(n.Load()) }
+}
+
+// attempt sends req once, within the per-attempt timeout.
+func (t *Transport) attempt(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if t.Timeout <= 0 {
+		return next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.Timeout)
+	resp, err := next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// retryable reports whether a failed attempt may succeed when repeated.
+func (t *Transport) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil || (req.Body != nil && req.GetBody == nil) {
+		return false
+	}
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false
+		}
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+	}
+	return slices.Contains(t.Policy.Statuses, resp.StatusCode)
+}
+
+// backoff returns the wait after the given failed attempt.
+func (t *Transport) backoff(attempt int) time.Duration {
+	ceiling := maxBackoff
+	if shift := attempt - 1; shift < 32 && t.Policy.Base<<shift > 0 {
+		ceiling = min(t.Policy.Base<<shift, maxBackoff)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) // #nosec G404 - jitter needs no cryptographic randomness
+}
+
+// retryAfter reads the Retry-After header of a response in seconds or as a date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// cancelBody releases the timeout of an attempt once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
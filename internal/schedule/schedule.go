@@ -0,0 +1,228 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package schedule chooses when each watched URL is crawled again. The
+// interval of a URL starts from what its caching headers promise and then
+// adapts to what recrawls observe: it shrinks when the content changed and
+// grows when it did not, so pages that change often are fetched more often
+// than the rest of the site.
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// FileName is the name of the watch state inside an output directory.
+	FileName = "watch.json"
+
+	// DefaultMinInterval is the shortest recrawl interval.
+	DefaultMinInterval = 15 * time.Minute
+	// DefaultMaxInterval is the longest recrawl interval.
+	DefaultMaxInterval = 7 * 24 * time.Hour
+	// DefaultInterval is the first interval of a URL without caching hints.
+	DefaultInterval = 24 * time.Hour
+
+	// lastModifiedFraction is the share of a resource's age used as its
+	// freshness lifetime when only Last-Modified is known, as HTTP caches do.
+	lastModifiedFraction = 10
+	// growth and shrink scale the interval after unchanged and changed recrawls.
+	growth = 1.5
+	shrink = 0.5
+)
+
+// Bounds limits recrawl intervals.
+type Bounds struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Clamp returns d limited to the bounds.
+func (b Bounds) Clamp(d time.Duration) time.Duration {
+	return min(max(d, b.Min), b.Max)
+}
+
+// Headers are the caching headers of a captured response.
+type Headers struct {
+	CacheControl string
+	Expires      string
+	LastModified string
+}
+
+// Hint returns the freshness lifetime the headers announce at time now.
+// max-age and s-maxage win over Expires, which wins over the Last-Modified
+// heuristic. It reports false when the headers give no usable hint.
+func (h Headers) Hint(now time.Time) (time.Duration, bool) {
+	for _, directive := range strings.Split(h.CacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+		if name != "max-age" && name != "s-maxage" {
+			continue
+		}
+		if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	if h.Expires != "" {
+		if expires, err := http.ParseTime(h.Expires); err == nil && expires.After(now) {
+			return expires.Sub(now), true
+		}
+	}
+	if h.LastModified != "" {
+		if modified, err := http.ParseTime(h.LastModified); err == nil && modified.Before(now) {
+			return now.Sub(modified) / lastModifiedFraction, true
+		}
+	}
+	return 0, false
+}
+
+// Entry is the schedule of one watched URL.
+type Entry struct {
+	URL string `json:"url"`
+	// Seed is the site the URL belongs to.
+	Seed string `json:"seed"`
+	// Depth is the crawl depth of a recrawl; seeds are recrawled with the
+	// watch depth so new pages are discovered.
+	Depth     int           `json:"depth"`
+	Interval  time.Duration `json:"interval"`
+	NextCrawl time.Time     `json:"nextCrawl"`
+	LastCrawl time.Time     `json:"lastCrawl"`
+	Digest    string        `json:"digest,omitempty"`
+	Checks    int           `json:"checks"`
+	Changes   int           `json:"changes"`
+	Failures  int           `json:"failures,omitempty"`
+}
+
+// Observe updates the entry with a recrawl at time now that found content
+// with the given digest and caching headers.
+func (e *Entry) Observe(digest string, h Headers, now time.Time, b Bounds) {
+	hint, hasHint := h.Hint(now)
+	switch {
+	case e.Checks == 0 && hasHint:
+		e.Interval = hint
+	case e.Checks == 0:
+		e.Interval = DefaultInterval
+	case digest != e.Digest:
+		e.Changes++
+		e.Interval = scale(e.Interval, shrink)
+	default:
+		e.Interval = scale(e.Interval, growth)
+	}
+	if hasHint && e.Checks > 0 {
+		// Stay near what the server announces while following the observations
+		e.Interval = (e.Interval + hint) / 2
+	}
+	e.Interval = b.Clamp(e.Interval)
+	e.Digest = digest
+	e.Checks++
+	e.Failures = 0
+	e.LastCrawl = now
+	e.NextCrawl = now.Add(e.Interval)
+}
+
+// Fail backs the entry off after a recrawl at time now that did not capture it.
+func (e *Entry) Fail(now time.Time, b Bounds) {
+	e.Failures++
+	e.Interval = b.Clamp(scale(e.Interval, growth))
+	e.NextCrawl = now.Add(e.Interval)
+}
+
+func scale(d time.Duration, factor float64) time.Duration {
+	return time.Duration(float64(d) * factor)
+}
+
+// State is the schedule of every watched URL. It is safe for concurrent use.
+type State struct {
+	mu      sync.Mutex
+	path    string
+	Entries map[string]*Entry `json:"entries"`
+}
+
+// Load reads the watch state of an output directory; a missing file is an
+// empty state.
+func Load(outputDir string) (*State, error) {
+	s := &State{path: filepath.Join(outputDir, FileName), Entries: make(map[string]*Entry)}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse watch state: %w", err)
+	}
+	if s.Entries == nil {
+		s.Entries = make(map[string]*Entry)
+	}
+	return s, nil
+}
+
+// Save writes the state back to its output directory.
+func (s *State) Save(perms os.FileMode) error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode watch state: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, perms); err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	return nil
+}
+
+// Entry returns the entry of u, creating it for the given seed if needed.
+func (s *State) Entry(u, seed string) *Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.Entries[u]
+	if !ok {
+		e = &Entry{URL: u, Seed: seed}
+		s.Entries[u] = e
+	}
+	return e
+}
+
+// Has reports whether any URL of the seed is watched.
+func (s *State) Has(seed string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.Entries {
+		if e.Seed == seed {
+			return true
+		}
+	}
+	return false
+}
+
+// Due returns the entries due at time now grouped by seed, and the time the
+// next entry after those becomes due. The zero time means nothing is watched.
+func (s *State) Due(now time.Time) (map[string][]*Entry, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := make(map[string][]*Entry)
+	var next time.Time
+	for _, e := range s.Entries {
+		if !e.NextCrawl.After(now) {
+			due[e.Seed] = append(due[e.Seed], e)
+			continue
+		}
+		if next.IsZero() || e.NextCrawl.Before(next) {
+			next = e.NextCrawl
+		}
+	}
+	for _, entries := range due {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+	}
+	return due, next
+}
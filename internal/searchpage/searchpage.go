@@ -0,0 +1,176 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package searchpage writes a self-contained search page into a mirror. The
+// text of every captured page is embedded in the page together with a small
+// script, so the archive can be searched offline in any browser, including
+// inside a ZIM reader.
+package searchpage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/simhash"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+	"golang.org/x/net/html"
+)
+
+const (
+	// FileName is the search page written into the output directory.
+	FileName = "archive-search.html"
+	// textLimit caps the text kept per page, keeping the page loadable for
+	// large sites.
+	textLimit = 20000
+)
+
+// doc is one searchable page.
+type doc struct {
+	Path  string `json:"p"`
+	URL   string `json:"u"`
+	Title string `json:"t"`
+	Text  string `json:"x"`
+}
+
+// Write builds the search page for the HTML pages recorded in m, read from s.
+func Write(s storage.Storage, m *manifest.Manifest) error {
+	var docs []doc
+	seen := make(map[string]bool)
+	for _, r := range m.Resources {
+		if !strings.Contains(r.ContentType, "html") || seen[r.Path] || (r.Status != 0 && r.Status != 200) {
+			continue
+		}
+		seen[r.Path] = true
+		page, err := storage.ReadFile(s, r.Path)
+		if err != nil {
+			continue
+		}
+		text := strings.Join(strings.Fields(simhash.Text(page)), " ")
+		if runes := []rune(text); len(runes) > textLimit {
+			text = string(runes[:textLimit])
+		}
+		docs = append(docs, doc{Path: r.Path, URL: r.URL, Title: title(page), Text: text})
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Path < docs[j].Path })
+
+	// json.Marshal escapes <, > and &, so the index cannot close its script element
+	index, err := json.Marshal(docs)
+	if err != nil {
+		return fmt.Errorf("failed to encode search index: %w", err)
+	}
+	var buf bytes.Buffer
+	err = pageTemplate.Execute(&buf, struct {
+		Seed      string
+		Generated string
+		Pages     int
+		Index     template.JS
+	}{m.URL, time.Now().UTC().Format(time.RFC1123), len(docs), template.JS(index)}) // #nosec G203 - the index is JSON with HTML characters escaped
+	if err != nil {
+		return fmt.Errorf("failed to render search page: %w", err)
+	}
+	if err := storage.WriteFile(s, FileName, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write search page: %w", err)
+	}
+	return nil
+}
+
+// title returns the document title of an HTML page.
+func title(page []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			if name, _ := z.TagName(); string(name) == "title" && z.Next() == html.TextToken {
+				return strings.TrimSpace(string(z.Text()))
+			}
+		}
+	}
+}
+
+var pageTemplate = template.Must(template.New("search").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Search the archive of {{.Seed}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 50em; padding: 0 1em; line-height: 1.5; }
+input { width: 100%; font-size: 1.2em; padding: 0.4em; box-sizing: border-box; }
+.hit { margin: 1.2em 0; }
+.hit a { font-size: 1.1em; }
+.url { color: #060; font-size: 0.85em; word-break: break-all; }
+.snippet { color: #333; }
+mark { background: #ff7; }
+#status { color: #777; }
+</style>
+</head>
+<body>
+<h1>Search</h1>
+<p id="status">{{.Pages}} pages archived from <code>{{.Seed}}</code>, indexed {{.Generated}}.</p>
+<input id="q" type="search" placeholder="Search archived pages" autofocus>
+<div id="results"></div>
+<script type="application/json" id="index">{{.Index}}</script>
+<script>
+(function () {
+  var docs = JSON.parse(document.getElementById("index").textContent);
+  docs.forEach(function (d) { d.lt = d.t.toLowerCase(); d.lx = d.x.toLowerCase(); });
+  var input = document.getElementById("q"), results = document.getElementById("results"), status = document.getElementById("status");
+  var initial = status.textContent;
+
+  function terms(q) {
+    return q.toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(function (t) { return t.length > 1; });
+  }
+  function count(text, term) {
+    var n = 0, i = text.indexOf(term);
+    while (i !== -1) { n++; i = text.indexOf(term, i + term.length); }
+    return n;
+  }
+  function escape(s) {
+    return s.replace(/[&<>"]/g, function (c) { return {"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"}[c]; });
+  }
+  function snippet(d, ts) {
+    var at = d.lx.indexOf(ts[0]), start = Math.max(0, at - 80);
+    var s = escape(d.x.substr(start, 240));
+    ts.forEach(function (t) {
+      s = s.replace(new RegExp("(" + t.replace(/[.*+?^${}()|[\]\\]/g, "\\$&") + ")", "gi"), "<mark>$1</mark>");
+    });
+    return (start > 0 ? "…" : "") + s + "…";
+  }
+  function search() {
+    var ts = terms(input.value);
+    if (location.hash.slice(1) !== encodeURIComponent(input.value)) {
+      history.replaceState(null, "", "#" + encodeURIComponent(input.value));
+    }
+    if (!ts.length) { results.innerHTML = ""; status.textContent = initial; return; }
+    var hits = [];
+    docs.forEach(function (d) {
+      var score = 0;
+      for (var i = 0; i < ts.length; i++) {
+        var n = 5 * count(d.lt, ts[i]) + count(d.lx, ts[i]);
+        if (!n) return;
+        score += n;
+      }
+      hits.push({d: d, score: score});
+    });
+    hits.sort(function (a, b) { return b.score - a.score; });
+    status.textContent = hits.length + " of " + docs.length + " pages match";
+    results.innerHTML = hits.slice(0, 100).map(function (h) {
+      return '<div class="hit"><a href="' + escape(h.d.p) + '">' + escape(h.d.t || h.d.p) + '</a>' +
+        '<div class="url">' + escape(h.d.u) + '</div><div class="snippet">' + snippet(h.d, ts) + '</div></div>';
+    }).join("");
+  }
+  input.addEventListener("input", search);
+  if (location.hash.length > 1) { input.value = decodeURIComponent(location.hash.slice(1)); search(); }
+})();
+</script>
+</body>
+</html>
+`))
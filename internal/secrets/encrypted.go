@@ -0,0 +1,163 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const (
+	// IdentityEnv names the age identity file used to decrypt age: secrets files.
+	IdentityEnv = "AGE_IDENTITY_FILE"
+	// sopsIdentityEnv is where sops looks for age identities; it is honoured
+	// too so one key file serves both tools.
+	sopsIdentityEnv = "SOPS_AGE_KEY_FILE"
+	// sopsCmd is the sops command-line client.
+	sopsCmd = "sops"
+)
+
+// decrypted caches the contents of age secrets files by path, so a file is
+// only decrypted once however many secrets are taken from it.
+var decrypted = struct {
+	sync.Mutex
+	files map[string]map[string]string
+}{files: make(map[string]map[string]string)}
+
+// splitNamed splits "<path>#<name>".
+func splitNamed(scheme, rest string) (path, name string, err error) {
+	i := strings.LastIndex(rest, "#")
+	if i <= 0 || i == len(rest)-1 {
+		return "", "", fmt.Errorf("invalid %s reference %q (want %s:<path>#<name>)", scheme, rest, scheme)
+	}
+	return rest[:i], rest[i+1:], nil
+}
+
+// ageGet reads one secret from an age-encrypted secrets file.
+func ageGet(path, name string) (string, error) {
+	decrypted.Lock()
+	defer decrypted.Unlock()
+	values, ok := decrypted.files[path]
+	if !ok {
+		var err error
+		if values, err = decryptAge(path); err != nil {
+			return "", err
+		}
+		decrypted.files[path] = values
+	}
+	value, ok := values[name]
+	if !ok {
+		return "", fmt.Errorf("%w: age:%s#%s", ErrNotFound, path, name)
+	}
+	return value, nil
+}
+
+// decryptAge decrypts a secrets file with the identities from identityFile.
+func decryptAge(path string) (map[string]string, error) {
+	keyFile, err := identityFile()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := os.Open(keyFile) // #nosec G304 - the identity file is chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to open age identity file: %w", err)
+	}
+	defer func() { _ = keys.Close() }()
+	identities, err := age.ParseIdentities(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age identity file %s: %w", keyFile, err)
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 - the secrets file is chosen by the user
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	var in io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte(armor.Header)) {
+		in = armor.NewReader(in)
+	}
+	r, err := age.Decrypt(in, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets file %s: %w", path, err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets file %s: %w", path, err)
+	}
+	return parseValues(plain)
+}
+
+// identityFile returns the age identity file to decrypt with: IdentityEnv,
+// then sops's key file setting, then sops's default location.
+func identityFile() (string, error) {
+	for _, env := range []string{IdentityEnv, sopsIdentityEnv} {
+		if path := os.Getenv(env); path != "" {
+			return path, nil
+		}
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("no age identity file, set %s: %w", IdentityEnv, err)
+	}
+	return filepath.Join(dir, "sops", "age", "keys.txt"), nil
+}
+
+// parseValues reads decrypted secrets, either a JSON object of strings or
+// dotenv-style NAME=value lines.
+func parseValues(plain []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	if trimmed := bytes.TrimSpace(plain); bytes.HasPrefix(trimmed, []byte("{")) {
+		if err := json.Unmarshal(trimmed, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse secrets file: %w", err)
+		}
+		return values, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(plain))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid line in secrets file: want NAME=value")
+		}
+		values[strings.TrimSpace(name)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file: %w", err)
+	}
+	return values, nil
+}
+
+// sopsGet extracts one top-level key from a sops-encrypted file with the
+// sops client, which handles every key type sops supports (age, PGP, cloud KMS).
+func sopsGet(path, name string) (string, error) {
+	cmd := exec.Command(sopsCmd, "--decrypt", "--extract", fmt.Sprintf("[%q]", name), path) // #nosec G204 - arguments are passed without a shell
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("failed to decrypt sops:%s#%s: %s", path, name, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return "", fmt.Errorf("failed to run %s: %w", sopsCmd, err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
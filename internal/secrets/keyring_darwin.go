@@ -0,0 +1,42 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityTool manages the macOS keychain.
+const securityTool = "security"
+
+// KeyringGet reads a secret from the OS keyring.
+func KeyringGet(service, account string) (string, error) {
+	cmd := exec.Command(securityTool, "find-generic-password", "-s", service, "-a", account, "-w") // #nosec G204 - arguments are passed without a shell
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("%w: keyring:%s/%s", ErrNotFound, service, account)
+		}
+		return "", fmt.Errorf("failed to query keychain: %w", err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+// KeyringSet stores a secret in the OS keyring.
+func KeyringSet(service, account, secret string) error {
+	// -w without a value makes security prompt, so the secret never shows up
+	// in the process list
+	cmd := exec.Command(securityTool, "add-generic-password", "-U", "-s", service, "-a", account, "-w") // #nosec G204 - arguments are passed without a shell
+	cmd.Stdin = strings.NewReader(secret + "\n" + secret + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store secret in keychain: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
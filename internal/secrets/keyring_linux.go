@@ -0,0 +1,41 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretTool talks to the Secret Service (GNOME Keyring, KWallet) through
+// libsecret's command-line client.
+const secretTool = "secret-tool"
+
+// KeyringGet reads a secret from the OS keyring.
+func KeyringGet(service, account string) (string, error) {
+	cmd := exec.Command(secretTool, "lookup", "service", service, "account", account) // #nosec G204 - arguments are passed without a shell
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("%w: keyring:%s/%s", ErrNotFound, service, account)
+		}
+		return "", fmt.Errorf("failed to query keyring with %s: %w", secretTool, err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+// KeyringSet stores a secret in the OS keyring.
+func KeyringSet(service, account, secret string) error {
+	cmd := exec.Command(secretTool, "store", "--label", "website-archiver "+service+"/"+account, "service", service, "account", account) // #nosec G204 - arguments are passed without a shell
+	cmd.Stdin = strings.NewReader(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store secret with %s: %w: %s", secretTool, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+//go:build !linux && !darwin
+
+package secrets
+
+import (
+	"errors"
+	"runtime"
+)
+
+// errNoKeyring is returned where no keyring integration exists.
+var errNoKeyring = errors.New("OS keyring is not supported on " + runtime.GOOS + "; use a file: or env: reference")
+
+// KeyringGet reads a secret from the OS keyring.
+func KeyringGet(_, _ string) (string, error) {
+	return "", errNoKeyring
+}
+
+// KeyringSet stores a secret in the OS keyring.
+func KeyringSet(_, _, _ string) error {
+	return errNoKeyring
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package secrets resolves references to credentials kept outside the
+// configuration, so passwords and tokens do not have to sit in plaintext
+// environment variables or on the command line.
+//
+// A reference is one of
+//
+//	keyring:<service>/<account>  an entry in the OS keyring
+//	file:<path>                  the contents of a file, such as a mounted secret
+//	env:<NAME>                   an environment variable
+//	age:<path>#<name>            a value in an age-encrypted secrets file
+//	sops:<path>#<name>           a top-level value in a sops-encrypted file
+//
+// An age secrets file holds a JSON object or NAME=value lines and is
+// decrypted with the identity file named by AGE_IDENTITY_FILE, falling back
+// to sops's age key file.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Reference schemes.
+const (
+	SchemeKeyring = "keyring"
+	SchemeFile    = "file"
+	SchemeEnv     = "env"
+	SchemeAge     = "age"
+	SchemeSops    = "sops"
+)
+
+// ErrNotFound is returned when a referenced secret does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// Resolve returns the secret a reference points at.
+func Resolve(ref string) (string, error) {
+	scheme, rest, ok := strings.Cut(ref, ":")
+	if !ok || rest == "" {
+		return "", fmt.Errorf("invalid secret reference %q (want keyring:<service>/<account>, file:<path>, env:<NAME>, age:<path>#<name>, or sops:<path>#<name>)", ref)
+	}
+	switch scheme {
+	case SchemeKeyring:
+		service, account, err := splitKeyring(rest)
+		if err != nil {
+			return "", err
+		}
+		return KeyringGet(service, account)
+	case SchemeFile:
+		data, err := os.ReadFile(rest) // #nosec G304 - the secret file is chosen by the user
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, ref)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case SchemeEnv:
+		value, ok := os.LookupEnv(rest)
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, ref)
+		}
+		return value, nil
+	case SchemeAge, SchemeSops:
+		path, name, err := splitNamed(scheme, rest)
+		if err != nil {
+			return "", err
+		}
+		if scheme == SchemeAge {
+			return ageGet(path, name)
+		}
+		return sopsGet(path, name)
+	default:
+		return "", fmt.Errorf("unknown secret reference scheme %q", scheme)
+	}
+}
+
+// splitKeyring splits "<service>/<account>".
+func splitKeyring(rest string) (service, account string, err error) {
+	service, account, ok := strings.Cut(rest, "/")
+	if !ok || service == "" || account == "" {
+		return "", "", fmt.Errorf("invalid keyring reference %q (want keyring:<service>/<account>)", rest)
+	}
+	return service, account, nil
+}
+
+// Store saves a secret under a keyring reference.
+func Store(ref, secret string) error {
+	scheme, rest, _ := strings.Cut(ref, ":")
+	if scheme != SchemeKeyring {
+		return fmt.Errorf("only keyring references can be stored, got %q", ref)
+	}
+	service, account, err := splitKeyring(rest)
+	if err != nil {
+		return err
+	}
+	return KeyringSet(service, account, secret)
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// authorized wraps the handler of a route that changes the server's state.
+// It requires a JSON body, which browsers cannot send across origins without
+// asking first, and the API token as a bearer token.
+func (s *Server) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type must be application/json"))
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || s.cfg.APIToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.APIToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("a valid API token is required"))
+			return
+		}
+		handler(w, r)
+	}
+}
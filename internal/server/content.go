@@ -0,0 +1,40 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package server
+
+import (
+	"net"
+	"net/http"
+)
+
+// ContentHandler returns the routes of the archived content: the captures
+// under /web/<timestamp>/<url> and the Memento TimeGate and TimeMap. Captures
+// run their own scripts, so they are served on addr, an origin of their own,
+// where nothing else of the server is reachable. It must be called before
+// the server handles requests.
+func (s *Server) ContentHandler(addr string) http.Handler {
+	s.contentAddr = addr
+	return s.withArchived(http.NewServeMux())
+}
+
+// contentBase returns the scheme and host of the content origin as seen by
+// the client of r, which reaches it at the host it reached the server at
+// when the content listens on every interface.
+func (s *Server) contentBase(r *http.Request) string {
+	host, port, err := net.SplitHostPort(s.contentAddr)
+	if err != nil {
+		return ""
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
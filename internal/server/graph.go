@@ -0,0 +1,77 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/progress"
+)
+
+// maxGraphNodes caps the crawl graph kept per job, so a crawl escaping into
+// an endless URL space cannot grow the server without bound.
+const maxGraphNodes = 5000
+
+// GraphNode is a URL of a job's crawl graph. State is the type of the last
+// progress event of the URL: discovered, started, finished, or failed.
+type GraphNode struct {
+	URL    string `json:"url"`
+	Parent string `json:"parent,omitempty"`
+	State  string `json:"state"`
+	Status int    `json:"status,omitempty"`
+}
+
+// Graph is the crawl graph of a job: the URLs it discovered, linked to the
+// pages they were found on.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	// Truncated is set when URLs were left out beyond maxGraphNodes.
+	Truncated bool `json:"truncated,omitempty"`
+
+	index map[string]int
+}
+
+// apply records a URL event in the graph.
+func (g *Graph) apply(e progress.Event) {
+	switch e.Type {
+	case progress.Discovered, progress.Started, progress.Finished, progress.Failed:
+	default:
+		return
+	}
+	if g.index == nil {
+		g.index = make(map[string]int)
+	}
+	i, ok := g.index[e.URL]
+	if !ok {
+		if len(g.Nodes) >= maxGraphNodes {
+			g.Truncated = true
+			return
+		}
+		i = len(g.Nodes)
+		g.index[e.URL] = i
+		g.Nodes = append(g.Nodes, GraphNode{URL: e.URL, Parent: e.Parent})
+	}
+	g.Nodes[i].State = e.Type
+	if e.Status != 0 {
+		g.Nodes[i].Status = e.Status
+	}
+}
+
+// handleGraph returns the crawl graph of a job, which the event stream keeps
+// up to date from then on.
+func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job, ok := s.jobs[r.PathValue("id")]
+	var graph Graph
+	if ok {
+		graph = Graph{Nodes: append([]GraphNode{}, job.graph.Nodes...), Truncated: job.graph.Truncated}
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job %s not found", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, graph)
+}
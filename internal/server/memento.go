@@ -0,0 +1,114 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/catalog"
+)
+
+// Memento (RFC 7089) endpoints over the catalog. Mementos are the captures
+// served under /web/<timestamp>/<url>.
+const (
+	timeGatePath = "/timegate/"
+	timeMapPath  = "/timemap/link/"
+	mementoPath  = "/web/"
+
+	linkFormatType = "application/link-format"
+)
+
+// baseURL returns the scheme and host the request was made to.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// mementoLinks returns the Link header relating original, TimeGate, and TimeMap.
+func mementoLinks(base, original string) string {
+	return fmt.Sprintf(`<%s>; rel="original", <%s%s%s>; rel="timegate", <%s%s%s>; rel="timemap"; type="%s"`,
+		original, base, timeGatePath, original, base, timeMapPath, original, linkFormatType)
+}
+
+// mementoDatetime returns the HTTP date of a capture timestamp.
+func mementoDatetime(timestamp string) string {
+	t, err := time.Parse(catalog.TimestampFormat, timestamp)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(http.TimeFormat)
+}
+
+// handleTimeGate redirects to the memento closest to the Accept-Datetime
+// header, or to the latest one without it.
+func (s *Server) handleTimeGate(w http.ResponseWriter, r *http.Request) {
+	original := storedURL(r)
+	timestamp := ""
+	if v := r.Header.Get("Accept-Datetime"); v != "" {
+		t, err := http.ParseTime(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid Accept-Datetime %s", v))
+			return
+		}
+		timestamp = t.UTC().Format(catalog.TimestampFormat)
+	}
+	entry, ok := s.catalog.Closest(original, timestamp)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no capture of %s", original))
+		return
+	}
+	if timestamp == "" {
+		captures := s.catalog.Captures(original)
+		entry = captures[len(captures)-1]
+	}
+
+	base := baseURL(r)
+	w.Header().Set("Vary", "accept-datetime")
+	w.Header().Set("Link", mementoLinks(base, original))
+	http.Redirect(w, r, base+mementoPath+entry.Timestamp+"/"+original, http.StatusFound)
+}
+
+// handleTimeMap lists every memento of a URL in link format.
+func (s *Server) handleTimeMap(w http.ResponseWriter, r *http.Request) {
+	original := storedURL(r)
+	captures := s.catalog.Captures(original)
+	if len(captures) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no capture of %s", original))
+		return
+	}
+
+	base := baseURL(r)
+	links := []string{
+		fmt.Sprintf(`<%s>; rel="original"`, original),
+		fmt.Sprintf(`<%s%s%s>; rel="timegate"`, base, timeGatePath, original),
+		fmt.Sprintf(`<%s%s%s>; rel="self"; type="%s"; from="%s"; until="%s"`, base, timeMapPath, original, linkFormatType,
+			mementoDatetime(captures[0].Timestamp), mementoDatetime(captures[len(captures)-1].Timestamp)),
+	}
+	seen := make(map[string]bool)
+	for i, e := range captures {
+		if seen[e.Timestamp] {
+			continue
+		}
+		seen[e.Timestamp] = true
+		rel := "memento"
+		switch {
+		case len(captures) == 1:
+			rel = "first last memento"
+		case i == 0:
+			rel = "first memento"
+		case i == len(captures)-1:
+			rel = "last memento"
+		}
+		links = append(links, fmt.Sprintf(`<%s%s%s/%s>; rel="%s"; datetime="%s"`, base, mementoPath, e.Timestamp, original, rel, mementoDatetime(e.Timestamp)))
+	}
+
+	w.Header().Set("Content-Type", linkFormatType)
+	_, _ = fmt.Fprint(w, strings.Join(links, ",\n")+"\n")
+}
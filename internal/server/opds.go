@@ -0,0 +1,79 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/opds"
+)
+
+// opdsPath is the OPDS feed of the archives in the output directory.
+const opdsPath = "/opds"
+
+// handleOPDS lists the ZIM and EPUB archives as an OPDS acquisition feed.
+func (s *Server) handleOPDS(w http.ResponseWriter, r *http.Request) {
+	pubs, err := opds.Scan(s.catalog.Root())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", opds.FeedType)
+	if err := opds.WriteFeed(w, baseURL(r)+opdsPath, pubs); err != nil {
+		slog.Warn("Failed to write OPDS feed", "error", err)
+	}
+}
+
+// handleArchiveFile downloads a file of an archive the feed lists. Only
+// those are served, so nothing else of the output directory is reachable.
+func (s *Server) handleArchiveFile(w http.ResponseWriter, r *http.Request) {
+	pub, name, ok := s.findArchive(w, r)
+	if !ok {
+		return
+	}
+	file, err := os.Open(filepath.Join(s.catalog.Root(), name)) // #nosec G304 - name is an archive listed in the output directory
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("archive %s is missing on disk", name))
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", pub.MediaType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, "", pub.Updated, file)
+}
+
+// handleArchiveIllustration serves the icon of a ZIM archive.
+func (s *Server) handleArchiveIllustration(w http.ResponseWriter, r *http.Request) {
+	pub, name, ok := s.findArchive(w, r)
+	if !ok {
+		return
+	}
+	data, err := opds.Illustration(s.catalog.Root(), pub)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("archive %s has no illustration", name))
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	_, _ = w.Write(data)
+}
+
+// findArchive looks up the archive of the file path value, answering 404
+// when the feed does not list it.
+func (s *Server) findArchive(w http.ResponseWriter, r *http.Request) (opds.Publication, string, bool) {
+	name := r.PathValue("file")
+	pubs, err := opds.Scan(s.catalog.Root())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return opds.Publication{}, "", false
+	}
+	pub, ok := opds.Find(pubs, name)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("archive %s not found", name))
+	}
+	return pub, name, ok
+}
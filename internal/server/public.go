@@ -0,0 +1,60 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package server
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/catalog"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+//go:embed public.html
+var publicHTML []byte
+
+// Collection is a published collection: a tag and the captures it marks.
+type Collection struct {
+	Name     string        `json:"name"`
+	Captures []catalog.Run `json:"captures"`
+}
+
+// PublicHandler returns the routes of the public gallery, a read-only view
+// of the captures tagged with one of collections: their pages, search, and
+// snapshots, but no jobs, annotations, events, or diagnostics. Nothing else
+// in the output directory is reachable through it. It must be called before
+// the server handles requests.
+func (s *Server) PublicHandler(collections []string) http.Handler {
+	s.collections = manifest.CleanTags(collections)
+	s.public = &Server{cfg: s.cfg, ctx: s.ctx, catalog: s.catalog.Published(s.collections), collections: s.collections}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.public.handleGallery)
+	mux.HandleFunc("GET /api/collections", s.public.handleCollections)
+	mux.HandleFunc("GET /api/search", s.public.handleSearch)
+	mux.HandleFunc("GET /api/captures", s.public.handleCaptures)
+	return s.public.withArchived(mux)
+}
+
+// refreshPublic publishes the captures of the collections as the catalog
+// now lists them.
+func (s *Server) refreshPublic() {
+	if s.public != nil {
+		s.public.catalog.Replace(s.catalog.Published(s.collections))
+	}
+}
+
+func (s *Server) handleGallery(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(publicHTML)
+}
+
+// handleCollections lists the published collections with their captures.
+func (s *Server) handleCollections(w http.ResponseWriter, _ *http.Request) {
+	collections := make([]Collection, 0, len(s.collections))
+	for _, name := range s.collections {
+		collections = append(collections, Collection{Name: name, Captures: s.catalog.Tagged(name)})
+	}
+	writeJSON(w, http.StatusOK, collections)
+}
@@ -0,0 +1,487 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package server runs archive jobs on request and reports on them over HTTP:
+// a JSON API for jobs and their live crawl graphs, a Server-Sent Events
+// stream of progress, search over the catalog of captures, a small web UI,
+// an OPDS feed of the ZIM and EPUB archives for e-readers and Kiwix clients,
+// and the pprof and expvar diagnostics under /debug/. The captures
+// themselves are served by a separate content handler, meant for an origin
+// of its own, and another read-only handler publishes tagged collections as
+// a gallery.
+package server
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/catalog"
+	"github.com/Sudo-Ivan/website-archiver/internal/diag"
+	"github.com/Sudo-Ivan/website-archiver/internal/fetcherr"
+	"github.com/Sudo-Ivan/website-archiver/internal/progress"
+	"github.com/Sudo-Ivan/website-archiver/internal/result"
+)
+
+//go:embed ui.html
+var uiHTML []byte
+
+// heartbeatInterval keeps idle event streams open through proxies.
+const heartbeatInterval = 15 * time.Second
+
+// Job states.
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// JobRequest is the body of a request to start a job.
+type JobRequest struct {
+	URL          string `json:"url"`
+	Depth        int    `json:"depth"`
+	ZIM          bool   `json:"zim"`
+	AllSnapshots bool   `json:"allSnapshots"`
+	Snapshot     string `json:"snapshot,omitempty"`
+	// Tags and Note annotate the capture.
+	Tags []string `json:"tags,omitempty"`
+	Note string   `json:"note,omitempty"`
+}
+
+// Job is a running or finished archive job.
+type Job struct {
+	ID         string     `json:"id"`
+	Request    JobRequest `json:"request"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	OutputDir  string     `json:"outputDir,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	// Failure tells which URL and stage failed, when the error says.
+	Failure *fetcherr.FetchError `json:"failure,omitempty"`
+	// Fetched, Failed, and Bytes count the URLs of the job so far.
+	Fetched int   `json:"fetched"`
+	Failed  int   `json:"failed"`
+	Bytes   int64 `json:"bytes"`
+	// Result is what the job produced, URL by URL, once it finished.
+	Result *result.Result `json:"result,omitempty"`
+
+	// graph is served by /api/jobs/{id}/graph, not with the job
+	graph *Graph
+}
+
+// RunFunc archives what req asks for with cfg and returns what it produced,
+// which may be nil when it failed early.
+type RunFunc func(ctx context.Context, req JobRequest, cfg *config.Config) (*result.Result, error)
+
+// Server keeps track of jobs. It is safe for concurrent use.
+type Server struct {
+	cfg     *config.Config
+	run     RunFunc
+	ctx     context.Context
+	bus     *progress.Bus
+	catalog *catalog.Catalog
+
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	order []string
+
+	// public serves the published collections, once PublicHandler is called
+	public      *Server
+	collections []string
+	// contentAddr serves the captures, once ContentHandler is called
+	contentAddr string
+}
+
+// defaultSearchLimit is the number of search results returned unless asked otherwise.
+const defaultSearchLimit = 20
+
+// New creates a server whose jobs run with run until ctx ends. The catalog is
+// refreshed after every job.
+func New(ctx context.Context, cfg *config.Config, cat *catalog.Catalog, run RunFunc) *Server {
+	return &Server{
+		cfg:     cfg,
+		run:     run,
+		ctx:     ctx,
+		bus:     progress.NewBus(),
+		catalog: cat,
+		jobs:    make(map[string]*Job),
+	}
+}
+
+// Handler returns the routes of the server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleUI)
+	mux.HandleFunc("GET /api/jobs", s.handleListJobs)
+	mux.HandleFunc("POST /api/jobs", s.authorized(s.handleStartJob))
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("GET /api/jobs/{id}/graph", s.handleGraph)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	mux.HandleFunc("GET /api/search", s.handleSearch)
+	mux.HandleFunc("GET /api/captures", s.handleCaptures)
+	mux.HandleFunc("GET /api/stats", s.handleStats)
+	mux.HandleFunc("GET /api/runs", s.handleRuns)
+	mux.HandleFunc("PUT /api/annotations/{capture...}", s.authorized(s.handleAnnotate))
+	mux.HandleFunc("GET "+opdsPath, s.handleOPDS)
+	mux.HandleFunc("GET "+opdsPath+"/files/{file}", s.handleArchiveFile)
+	mux.HandleFunc("GET "+opdsPath+"/illustration/{file}", s.handleArchiveIllustration)
+	mux.Handle(diag.Prefix, diag.Handler())
+	return mux
+}
+
+// withArchived serves the paths that embed an archived URL before mux.
+func (s *Server) withArchived(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			if s.serveArchived(w, r) {
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// serveArchived routes the paths that embed an archived URL. ServeMux would
+// clean the "//" after its scheme and redirect, which Memento clients do not
+// expect, so these are matched before it.
+func (s *Server) serveArchived(w http.ResponseWriter, r *http.Request) bool {
+	p := r.URL.EscapedPath()
+	if rest, ok := strings.CutPrefix(p, mementoPath); ok {
+		timestamp, target, ok := strings.Cut(rest, "/")
+		if !ok {
+			return false
+		}
+		r.SetPathValue("timestamp", timestamp)
+		r.SetPathValue("url", target)
+		s.handleStored(w, r)
+		return true
+	}
+	for prefix, handler := range map[string]http.HandlerFunc{timeGatePath: s.handleTimeGate, timeMapPath: s.handleTimeMap} {
+		if target, ok := strings.CutPrefix(p, prefix); ok {
+			r.SetPathValue("url", target)
+			handler(w, r)
+			return true
+		}
+	}
+	return false
+}
+
+// Start begins a job in the background.
+func (s *Server) Start(req JobRequest) *Job {
+	s.mu.Lock()
+	id := strconv.Itoa(len(s.order) + 1)
+	job := &Job{ID: id, Request: req, Status: StatusRunning, StartedAt: time.Now().UTC(), graph: &Graph{}}
+	s.jobs[id] = job
+	s.order = append(s.order, id)
+	s.mu.Unlock()
+
+	jobCfg := *s.cfg
+	jobCfg.Progress = func(e progress.Event) {
+		e.Job = id
+		s.count(job, e)
+		s.bus.Publish(e)
+	}
+
+	s.bus.Publish(progress.Event{Job: id, Type: progress.JobStarted, URL: req.URL})
+	go func() {
+		// A crawl takes as long as it takes; HTTPTimeout bounds its requests
+		res, err := s.run(s.ctx, req, &jobCfg)
+
+		s.mu.Lock()
+		now := time.Now().UTC()
+		job.FinishedAt = &now
+		if res != nil {
+			job.OutputDir, job.Result = res.OutputDir, res
+		}
+		job.Status = StatusDone
+		done := progress.Event{Job: id, Type: progress.JobFinished, URL: req.URL, Bytes: job.Bytes}
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = err.Error()
+			job.Failure, _ = fetcherr.As(err)
+			done.Error = job.Error
+		}
+		s.mu.Unlock()
+		s.refreshCatalog()
+		s.bus.Publish(done)
+	}()
+	return s.snapshot(job)
+}
+
+// refreshCatalog rebuilds the catalog so new captures become searchable.
+func (s *Server) refreshCatalog() {
+	rebuilt, err := catalog.Build(s.catalog.Root())
+	if err != nil {
+		slog.Warn("Failed to rebuild catalog", "error", err)
+		return
+	}
+	s.catalog.Replace(rebuilt)
+	if err := s.catalog.Write(s.cfg.FilePerms); err != nil {
+		slog.Warn("Failed to write catalog", "error", err)
+	}
+	s.refreshPublic()
+}
+
+// count updates the counters and the crawl graph of job from a URL event.
+func (s *Server) count(job *Job, e progress.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.graph.apply(e)
+	switch e.Type {
+	case progress.Finished:
+		job.Fetched++
+		job.Bytes += e.Bytes
+	case progress.Failed:
+		job.Failed++
+	}
+}
+
+// snapshot returns a copy of job that is safe to encode.
+func (s *Server) snapshot(job *Job) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := *job
+	return &c
+}
+
+// contentURLPlaceholder in the UI is replaced with the content origin, which
+// search results link to.
+const contentURLPlaceholder = "{{content-url}}"
+
+func (s *Server) handleUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(bytes.ReplaceAll(uiHTML, []byte(contentURLPlaceholder), []byte(html.EscapeString(s.contentBase(r)))))
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	jobs := make([]Job, 0, len(s.order))
+	for _, id := range s.order {
+		jobs = append(jobs, *s.jobs[id])
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *Server) handleStartJob(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid job request: %w", err))
+		return
+	}
+	if req.URL == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("url is required"))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.Start(req))
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job, ok := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job %s not found", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.snapshot(job))
+}
+
+// handleEvents streams progress events as Server-Sent Events, optionally
+// only those of the job given by the job query parameter.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+	jobFilter := r.URL.Query().Get("job")
+	events, unsubscribe := s.bus.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			// The server is stopping, which waits for open streams
+			return
+		case <-heartbeat.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if jobFilter != "" && e.Job != jobFilter {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// handleSearch runs a full-text query given by the q parameter.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("q is required"))
+		return
+	}
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %s", v))
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, s.catalog.Search(query, limit))
+}
+
+// handleCaptures lists the captures of the URL given by the url parameter.
+func (s *Server) handleCaptures(w http.ResponseWriter, r *http.Request) {
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("url is required"))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.catalog.Captures(url))
+}
+
+// handleRuns lists the local captures with their annotations, only those
+// with the tag parameter when it is given.
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.catalog.Tagged(strings.ToLower(r.URL.Query().Get("tag"))))
+}
+
+// Annotations is the body of a request replacing the annotations of a
+// capture.
+type Annotations struct {
+	Tags []string `json:"tags"`
+	Note string   `json:"note"`
+}
+
+// handleAnnotate replaces the tags and the note of a capture.
+func (s *Server) handleAnnotate(w http.ResponseWriter, r *http.Request) {
+	var body Annotations
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid annotations: %w", err))
+		return
+	}
+	run, err := s.catalog.Annotate(r.PathValue("capture"), body.Tags, body.Note, s.cfg.FilePerms)
+	if errors.Is(err, catalog.ErrUnknownCapture) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := s.catalog.Write(s.cfg.FilePerms); err != nil {
+		slog.Warn("Failed to write catalog", "error", err)
+	}
+	s.refreshPublic()
+	writeJSON(w, http.StatusOK, run)
+}
+
+// handleStats reports per-domain collection statistics as JSON, or as CSV
+// with format=csv. trend=1 lists every capture instead of one row per domain.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	stats := s.catalog.Stats(query.Get("domain"))
+	trend := query.Get("trend") == "1" || query.Get("trend") == "true"
+	switch query.Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, stats)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		if err := catalog.WriteStatsCSV(w, stats, trend); err != nil {
+			slog.Warn("Failed to write statistics", "error", err)
+		}
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown format %s", query.Get("format")))
+	}
+}
+
+// handleStored serves the capture of a URL closest to a timestamp, addressed
+// like the Wayback Machine: /web/<timestamp>/<url>. Captures are Mementos and
+// carry the headers that relate them to their TimeGate and TimeMap.
+func (s *Server) handleStored(w http.ResponseWriter, r *http.Request) {
+	url := storedURL(r)
+	entry, ok := s.catalog.Closest(url, r.PathValue("timestamp"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no capture of %s", url))
+		return
+	}
+	path, err := s.catalog.FilePath(entry)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	file, err := os.Open(path) // #nosec G304 - FilePath keeps the path inside the output directory
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("capture of %s is missing on disk", url))
+		return
+	}
+	defer file.Close()
+	modified, _ := time.Parse(catalog.TimestampFormat, entry.Timestamp)
+	if entry.ContentType != "" {
+		w.Header().Set("Content-Type", entry.ContentType)
+	}
+	w.Header().Set("Memento-Datetime", mementoDatetime(entry.Timestamp))
+	w.Header().Set("Link", mementoLinks(baseURL(r), url))
+	http.ServeContent(w, r, "", modified, file)
+}
+
+// storedURL recovers the archived URL of a request. Clients that clean paths
+// themselves turn the "//" after the scheme into "/", which is restored here.
+func storedURL(r *http.Request) string {
+	url := r.PathValue("url")
+	for _, scheme := range []string{"http:/", "https:/"} {
+		if strings.HasPrefix(url, scheme) && !strings.HasPrefix(url, scheme+"/") {
+			url = scheme + "/" + strings.TrimPrefix(url, scheme)
+		}
+	}
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+	return url
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
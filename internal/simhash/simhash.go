@@ -0,0 +1,152 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package simhash fingerprints the visible text of HTML pages so captures can
+// be compared by content rather than bytes. Rotating ads, timestamps, and
+// markup churn move a fingerprint by only a few bits, while real edits move it
+// much further.
+package simhash
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// shingleSize is the number of consecutive words hashed together.
+	shingleSize = 4
+	// hashBits is the width of a fingerprint.
+	hashBits = 64
+
+	// noChangeDistance is the largest distance still treated as no meaningful change.
+	noChangeDistance = 3
+	// minorChangeDistance is the largest distance treated as a minor change.
+	minorChangeDistance = 10
+)
+
+// Change classifies the difference between two fingerprints.
+type Change string
+
+const (
+	// NoChange means the text is the same apart from noise.
+	NoChange Change = "no meaningful change"
+	// MinorChange means small edits.
+	MinorChange Change = "minor change"
+	// SignificantChange means the content was substantially rewritten.
+	SignificantChange Change = "significant change"
+)
+
+// Fingerprint is a 64-bit SimHash.
+type Fingerprint uint64
+
+// String returns the fingerprint as 16 hex digits.
+func (f Fingerprint) String() string {
+	return fmt.Sprintf("%016x", uint64(f))
+}
+
+// Parse reads a fingerprint produced by String.
+func Parse(s string) (Fingerprint, error) {
+	v, err := strconv.ParseUint(s, 16, hashBits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid fingerprint %q: %w", s, err)
+	}
+	return Fingerprint(v), nil
+}
+
+// Distance returns the number of differing bits.
+func Distance(a, b Fingerprint) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// Classify turns the distance between two fingerprints into a Change.
+func Classify(a, b Fingerprint) Change {
+	switch d := Distance(a, b); {
+	case d <= noChangeDistance:
+		return NoChange
+	case d <= minorChangeDistance:
+		return MinorChange
+	default:
+		return SignificantChange
+	}
+}
+
+// OfHTML fingerprints the visible text of an HTML document.
+func OfHTML(doc []byte) Fingerprint {
+	return OfText(Text(doc))
+}
+
+// OfText fingerprints plain text using overlapping word shingles.
+func OfText(text string) Fingerprint {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	var weights [hashBits]int
+	n := max(len(words)-shingleSize+1, 1)
+	for i := 0; i < n; i++ {
+		end := min(i+shingleSize, len(words))
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(strings.Join(words[i:end], " ")))
+		sum := h.Sum64()
+		for b := 0; b < hashBits; b++ {
+			if sum&(1<<uint(b)) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+
+	var f uint64
+	for b := 0; b < hashBits; b++ {
+		if weights[b] > 0 {
+			f |= 1 << uint(b)
+		}
+	}
+	return Fingerprint(f)
+}
+
+// Text extracts the visible text of an HTML document, skipping scripts,
+// styles, and other non-rendered elements.
+func Text(doc []byte) string {
+	var sb strings.Builder
+	z := html.NewTokenizer(bytes.NewReader(doc))
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return sb.String()
+		case html.StartTagToken:
+			if name, _ := z.TagName(); hidden(string(name)) {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); hidden(string(name)) && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if skip == 0 {
+				sb.Write(z.Text())
+				sb.WriteByte(' ')
+			}
+		}
+	}
+}
+
+func hidden(tag string) bool {
+	switch tag {
+	case "script", "style", "noscript", "template", "head":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package singlefile
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+// fallbackBase is the origin of the Content-Location of files the manifest
+// does not name the URL of.
+const fallbackBase = "https://capture.invalid/"
+
+// part is a file of an MHTML archive.
+type part struct {
+	location string
+	kind     string
+	data     []byte
+}
+
+// originalURLs returns the URLs the files of the capture in dir were fetched
+// from, by path, when it has a manifest.
+func originalURLs(dir string) map[string]string {
+	m, err := manifest.Load(dir)
+	if err != nil {
+		return nil
+	}
+	urls := make(map[string]string, len(m.Resources))
+	for _, r := range m.Resources {
+		urls[r.Path] = r.URL
+	}
+	return urls
+}
+
+// location returns the Content-Location of the file at the slash-separated
+// path: its original URL, or one below fallbackBase.
+func location(locations map[string]string, file string) string {
+	if u, ok := locations[file]; ok {
+		return u
+	}
+	return fallbackBase + (&url.URL{Path: file}).EscapedPath()
+}
+
+// archive returns the MHTML archive of the page at the slash-separated path
+// inside root. Stylesheets and scripts are inlined into the page as with
+// HTML, and the other files it uses become parts referenced by their
+// Content-Location. Links to other pages lead to their original URLs.
+func archive(root, page string, locations map[string]string, date time.Time) ([]byte, error) {
+	var parts []part
+	in := &inliner{root: root, embed: func(file, kind string, data []byte) string {
+		loc := location(locations, file)
+		parts = append(parts, part{location: loc, kind: kind, data: data})
+		return loc
+	}, link: func(file string) string {
+		return locations[file]
+	}}
+	doc, err := in.page(page)
+	if err != nil {
+		return nil, err
+	}
+	body, err := render(doc, page)
+	if err != nil {
+		return nil, err
+	}
+	top := part{location: location(locations, page), kind: "text/html", data: body}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: <Saved by website-archiver>\r\n")
+	fmt.Fprintf(&buf, "Snapshot-Content-Location: %s\r\n", top.location)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", title(doc)))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/related;\r\n\ttype=\"text/html\";\r\n\tboundary=\"%s\"\r\n\r\n", w.Boundary())
+	for _, p := range append([]part{top}, parts...) {
+		if err := writePart(w, p); err != nil {
+			return nil, fmt.Errorf("failed to write MHTML part %s: %w", p.location, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write MHTML archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writePart writes p, text as quoted-printable and the rest as base64.
+func writePart(w *multipart.Writer, p part) error {
+	text := strings.HasPrefix(p.kind, "text/") || strings.Contains(p.kind, "javascript") || strings.Contains(p.kind, "+xml")
+	encoding := "base64"
+	if text {
+		encoding = "quoted-printable"
+	}
+	out, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {p.kind},
+		"Content-Transfer-Encoding": {encoding},
+		"Content-Location":          {p.location},
+	})
+	if err != nil {
+		return err
+	}
+	if text {
+		qp := quotedprintable.NewWriter(out)
+		if _, err := qp.Write(p.data); err != nil {
+			return err
+		}
+		return qp.Close()
+	}
+	encoded := base64.StdEncoding.EncodeToString(p.data)
+	for len(encoded) > 76 {
+		if _, err := fmt.Fprintf(out, "%s\r\n", encoded[:76]); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = fmt.Fprintf(out, "%s\r\n", encoded)
+	return err
+}
+
+// title returns the text of the first title element of doc.
+func title(doc *html.Node) string {
+	var find func(*html.Node) string
+	find = func(n *html.Node) string {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Title && n.FirstChild != nil {
+			return strings.TrimSpace(n.FirstChild.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if t := find(c); t != "" {
+				return t
+			}
+		}
+		return ""
+	}
+	return find(doc)
+}
+
+// modTime returns when file was last written, or now.
+func modTime(file string) time.Time {
+	if info, err := os.Stat(file); err == nil {
+		return info.ModTime()
+	}
+	return time.Now()
+}
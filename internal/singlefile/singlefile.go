@@ -0,0 +1,422 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package singlefile turns the pages of a capture into self-contained files,
+// so a page can be mailed or stashed as one file: HTML like the SingleFile
+// extension, with stylesheets and scripts inlined into the page and images,
+// fonts, and icons as data: URIs, or MHTML archives that Chrome and Edge
+// open natively.
+package singlefile
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/cssdoc"
+)
+
+// Formats of the files written.
+const (
+	// HTML inlines everything into the page.
+	HTML = "html"
+	// MHTML writes RFC 2557 multipart/related archives of the page and the
+	// files it uses.
+	MHTML = "mhtml"
+)
+
+// maxImportDepth bounds the chain of stylesheets importing each other.
+const maxImportDepth = 8
+
+// Export writes a self-contained copy in format of every page of the capture
+// in dir to the same path below dest, with the extension .mhtml for MHTML,
+// and returns the number of pages written.
+func Export(dir, dest, format string, dirPerms, filePerms os.FileMode) (int, error) {
+	var locations map[string]string
+	if format == MHTML {
+		locations = originalURLs(dir)
+	}
+	pages := 0
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isPage(file) {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		page := filepath.ToSlash(rel)
+		out := filepath.Join(dest, rel)
+		var data []byte
+		switch format {
+		case MHTML:
+			data, err = archive(dir, page, locations, modTime(file))
+			out = strings.TrimSuffix(out, filepath.Ext(out)) + ".mhtml"
+		default:
+			data, err = Inline(dir, page)
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(out), dirPerms); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", out, err)
+		}
+		if err := os.WriteFile(out, data, filePerms); err != nil {
+			return fmt.Errorf("failed to write %s: %w", out, err)
+		}
+		pages++
+		return nil
+	})
+	return pages, err
+}
+
+// isPage reports whether file is an HTML page, by its extension or, for the
+// pages saved without one, its content.
+func isPage(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".html", ".htm", ".xhtml":
+		return true
+	case "":
+		f, err := os.Open(file) // #nosec G304 - file is inside the capture directory
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		head := make([]byte, 512)
+		n, _ := f.Read(head)
+		return strings.HasPrefix(http.DetectContentType(head[:n]), "text/html")
+	}
+	return false
+}
+
+// Inline returns the page at the slash-separated path page inside root with
+// the local files it uses inlined. References to files that are not in root
+// are left as they are.
+func Inline(root, page string) ([]byte, error) {
+	in := &inliner{root: root, embed: dataURI}
+	doc, err := in.page(page)
+	if err != nil {
+		return nil, err
+	}
+	return render(doc, page)
+}
+
+// dataURI embeds a file as a data: URI.
+func dataURI(_, kind string, data []byte) string {
+	return "data:" + kind + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// render returns doc as HTML.
+func render(doc *html.Node, page string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", page, err)
+	}
+	return buf.Bytes(), nil
+}
+
+type inliner struct {
+	root string
+	// embed returns the reference through which a file of root, at the
+	// slash-separated path, with its content type and the data, is used
+	embed func(file, kind string, data []byte) string
+	// link, when set, returns what links to the page at the slash-separated
+	// path lead to instead, or "" to keep them
+	link func(file string) string
+	// refs caches the references of files, which pages often use repeatedly
+	refs map[string]string
+}
+
+// page parses the page at the slash-separated path inside root and inlines
+// the local files it uses.
+func (in *inliner) page(page string) (*html.Node, error) {
+	data, err := os.ReadFile(filepath.Join(in.root, filepath.FromSlash(page))) // #nosec G304 - page is inside the capture directory
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", page, err)
+	}
+	in.node(doc, path.Dir(page))
+	return doc, nil
+}
+
+func (in *inliner) node(n *html.Node, dir string) {
+	if n.Type == html.ElementNode {
+		in.element(n, dir)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		in.node(c, dir)
+	}
+}
+
+func (in *inliner) element(n *html.Node, dir string) {
+	for i, a := range n.Attr {
+		if a.Key == "style" {
+			n.Attr[i].Val = string(in.css([]byte(a.Val), dir, 0))
+		}
+	}
+	switch n.DataAtom {
+	case atom.A, atom.Area:
+		if in.link == nil {
+			return
+		}
+		href := attr(n, "href")
+		if file, ok := in.local(dir, href); ok {
+			if target := in.link(in.relative(file)); target != "" {
+				if u, err := url.Parse(href); err == nil && u.Fragment != "" {
+					target += "#" + u.Fragment
+				}
+				setAttr(n, "href", target)
+			}
+		}
+	case atom.Style:
+		if text := n.FirstChild; text != nil && text.Type == html.TextNode {
+			text.Data = escapeText(string(in.css([]byte(text.Data), dir, 0)), "style")
+		}
+	case atom.Link:
+		rel := strings.ToLower(attr(n, "rel"))
+		file, ok := in.local(dir, attr(n, "href"))
+		switch {
+		case !ok:
+		case strings.Contains(rel, "stylesheet"):
+			css, err := os.ReadFile(file) // #nosec G304 - local only returns files inside the capture
+			if err != nil {
+				return
+			}
+			n.Data, n.DataAtom = "style", atom.Style
+			n.Attr = keep(n.Attr, "media")
+			n.AppendChild(&html.Node{Type: html.TextNode, Data: escapeText(string(in.css(css, in.dirOf(file), 0)), "style")})
+		case strings.Contains(rel, "icon") || rel == "preload":
+			if uri, ok := in.ref(file, 0); ok {
+				setAttr(n, "href", uri)
+			}
+		}
+	case atom.Script:
+		file, ok := in.local(dir, attr(n, "src"))
+		if !ok {
+			return
+		}
+		js, err := os.ReadFile(file) // #nosec G304 - local only returns files inside the capture
+		if err != nil {
+			return
+		}
+		n.Attr = drop(n.Attr, "src", "integrity", "crossorigin")
+		n.AppendChild(&html.Node{Type: html.TextNode, Data: escapeText(string(js), "script")})
+	case atom.Img, atom.Input, atom.Source, atom.Video:
+		key := "src"
+		switch {
+		case n.DataAtom == atom.Video:
+			key = "poster"
+		case n.DataAtom == atom.Source && !isPicture(n.Parent):
+			// Video and audio sources stay files
+			return
+		}
+		if file, ok := in.local(dir, attr(n, key)); ok {
+			if uri, ok := in.ref(file, 0); ok {
+				setAttr(n, key, uri)
+			}
+		}
+		if srcset := attr(n, "srcset"); srcset != "" {
+			if attr(n, "src") != "" {
+				// The inlined src is enough; every candidate would multiply the page
+				n.Attr = drop(n.Attr, "srcset", "sizes")
+			} else {
+				setAttr(n, "srcset", in.srcset(srcset, dir))
+			}
+		}
+	}
+}
+
+// css returns a stylesheet read from dir with its references inlined.
+// Stylesheets it imports are inlined as data: URIs themselves.
+func (in *inliner) css(data []byte, dir string, depth int) []byte {
+	mapping := make(map[string]string)
+	for _, ref := range cssdoc.Refs(data) {
+		if _, done := mapping[ref.URL]; done {
+			continue
+		}
+		if file, ok := in.local(dir, ref.URL); ok {
+			uri, ok := in.ref(file, depth)
+			if !ok {
+				continue
+			}
+			if u, err := url.Parse(ref.URL); err == nil && u.Fragment != "" {
+				// SVG sprites and fonts are addressed by fragment
+				uri += "#" + u.Fragment
+			}
+			mapping[ref.URL] = uri
+		}
+	}
+	if len(mapping) == 0 {
+		return data
+	}
+	return cssdoc.Rewrite(data, mapping)
+}
+
+// srcset inlines the candidates of a srcset attribute.
+func (in *inliner) srcset(value, dir string) string {
+	candidates := strings.Split(value, ",")
+	for i, c := range candidates {
+		fields := strings.Fields(c)
+		if len(fields) == 0 {
+			continue
+		}
+		if file, ok := in.local(dir, fields[0]); ok {
+			if uri, ok := in.ref(file, 0); ok {
+				fields[0] = uri
+			}
+		}
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// ref returns the reference file is embedded through, and false when it
+// cannot be read. Stylesheets at depth in a chain of imports have their own
+// references inlined first.
+func (in *inliner) ref(file string, depth int) (string, bool) {
+	if uri, ok := in.refs[file]; ok {
+		return uri, true
+	}
+	data, err := os.ReadFile(file) // #nosec G304 - local only returns files inside the capture
+	if err != nil {
+		return "", false
+	}
+	kind := mime.TypeByExtension(filepath.Ext(file))
+	if kind == "" {
+		kind = http.DetectContentType(data)
+	}
+	css := strings.HasPrefix(kind, "text/css")
+	if css {
+		if depth >= maxImportDepth {
+			return "", false
+		}
+		data = in.css(data, in.dirOf(file), depth+1)
+		kind = "text/css"
+	}
+	uri := in.embed(in.relative(file), strings.ReplaceAll(kind, " ", ""), data)
+	if in.refs == nil {
+		in.refs = make(map[string]string)
+	}
+	if !css || depth == 0 {
+		// Imported stylesheets may have been cut off at the depth bound
+		in.refs[file] = uri
+	}
+	return uri, true
+}
+
+// local returns the file inside root that ref, relative to the directory
+// dir of root, refers to.
+func (in *inliner) local(dir, ref string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || ref == "" || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	p := u.Path
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(dir, p)
+	}
+	p = path.Clean("/" + p)
+	file := filepath.Join(in.root, filepath.FromSlash(p))
+	info, err := os.Stat(file)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return file, true
+}
+
+// relative returns the slash-separated path of file inside root.
+func (in *inliner) relative(file string) string {
+	rel, err := filepath.Rel(in.root, file)
+	if err != nil {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+// dirOf returns the slash-separated directory of file relative to root.
+func (in *inliner) dirOf(file string) string {
+	return path.Dir(in.relative(file))
+}
+
+// escapeText keeps the text of a raw text element from closing it early.
+func escapeText(text, element string) string {
+	end := "</" + element
+	lower := strings.ToLower(text)
+	if !strings.Contains(lower, end) {
+		return text
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(lower, end)
+		if i < 0 {
+			b.WriteString(text)
+			return b.String()
+		}
+		b.WriteString(text[:i] + `<\/`)
+		text, lower = text[i+2:], lower[i+2:]
+	}
+}
+
+func isPicture(n *html.Node) bool {
+	return n != nil && n.DataAtom == atom.Picture
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+}
+
+// keep returns the attributes named keys.
+func keep(attrs []html.Attribute, keys ...string) []html.Attribute {
+	var kept []html.Attribute
+	for _, a := range attrs {
+		for _, k := range keys {
+			if a.Key == k {
+				kept = append(kept, a)
+			}
+		}
+	}
+	return kept
+}
+
+// drop returns the attributes not named keys.
+func drop(attrs []html.Attribute, keys ...string) []html.Attribute {
+	var kept []html.Attribute
+	for _, a := range attrs {
+		dropped := false
+		for _, k := range keys {
+			dropped = dropped || a.Key == k
+		}
+		if !dropped {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
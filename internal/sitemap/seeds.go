@@ -0,0 +1,155 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// sizeLimit is the largest sitemap read, uncompressed, as in the protocol.
+	sizeLimit = 50 << 20
+	// maxFiles caps the sitemap files read through nested sitemap indexes.
+	maxFiles = 1000
+)
+
+// Seeds returns the page URLs listed in the sitemaps of the site at root.
+// Sitemaps named by Sitemap lines of robots.txt are read, or /sitemap.xml
+// when there are none; sitemap indexes and gzip-compressed sitemaps are
+// followed. Sitemaps that cannot be read are skipped unless none can.
+func Seeds(ctx context.Context, client *http.Client, root *url.URL) ([]string, error) {
+	queue := robotsSitemaps(ctx, client, root)
+	if len(queue) == 0 {
+		queue = []string{root.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String()}
+	}
+
+	var seeds []string
+	var errs []error
+	read := 0
+	seen := make(map[string]bool)
+	for len(queue) > 0 && read < maxFiles {
+		sitemapURL := queue[0]
+		queue = queue[1:]
+		if seen[sitemapURL] {
+			continue
+		}
+		seen[sitemapURL] = true
+		read++
+
+		pages, nested, err := readSitemap(ctx, client, sitemapURL)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		seeds = append(seeds, pages...)
+		queue = append(queue, nested...)
+	}
+	if len(seeds) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return seeds, nil
+}
+
+// robotsSitemaps returns the sitemaps a site's robots.txt names.
+func robotsSitemaps(ctx context.Context, client *http.Client, root *url.URL) []string {
+	body, err := get(ctx, client, root.ResolveReference(&url.URL{Path: "/robots.txt"}).String())
+	if err != nil {
+		return nil
+	}
+	var sitemaps []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "sitemap") {
+			if u, err := root.Parse(strings.TrimSpace(value)); err == nil {
+				sitemaps = append(sitemaps, u.String())
+			}
+		}
+	}
+	return sitemaps
+}
+
+// readSitemap returns the page URLs of a sitemap, or the sitemaps listed by a
+// sitemap index.
+func readSitemap(ctx context.Context, client *http.Client, sitemapURL string) (pages, sitemaps []string, err error) {
+	body, err := get(ctx, client, sitemapURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) > 1 && body[0] == 0x1f && body[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress sitemap %s: %w", sitemapURL, err)
+		}
+		if body, err = io.ReadAll(io.LimitReader(zr, sizeLimit)); err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress sitemap %s: %w", sitemapURL, err)
+		}
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = false
+	var parent string
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return pages, sitemaps, fmt.Errorf("failed to parse sitemap %s: %w", sitemapURL, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "url", "sitemap":
+			parent = start.Name.Local
+		case "loc":
+			var loc string
+			if err := d.DecodeElement(&loc, &start); err != nil {
+				continue
+			}
+			if loc = strings.TrimSpace(loc); loc == "" {
+				continue
+			}
+			if parent == "sitemap" {
+				sitemaps = append(sitemaps, loc)
+			} else {
+				pages = append(pages, loc)
+			}
+		}
+	}
+	return pages, sitemaps, nil
+}
+
+// get fetches a URL and returns its body.
+func get(ctx context.Context, client *http.Client, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", u, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status code %d", u, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, sizeLimit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", u, err)
+	}
+	return body, nil
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package sitemap records the link graph of a crawl and writes a sitemap.xml
+// and a browsable site map page for the mirror, so large archives stay
+// navigable when the site's own menus relied on JavaScript.
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+)
+
+const (
+	// XMLFileName is the sitemap written into the output directory. It does
+	// not use the usual name so a sitemap.xml captured from the site survives.
+	XMLFileName = "archive-sitemap.xml"
+	// HTMLFileName is the human-readable site map page.
+	HTMLFileName = "archive-sitemap.html"
+
+	// xmlNamespace is the sitemap protocol namespace.
+	xmlNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	// lastModLayout is the W3C datetime layout of <lastmod>.
+	lastModLayout = "2006-01-02"
+)
+
+// Page is a captured HTML page.
+type Page struct {
+	URL   string
+	Path  string
+	Title string
+	// LastModified is the Last-Modified response header, if any.
+	LastModified string
+}
+
+// Graph is the link graph of a crawl, keyed by the local path of each page
+// so URLs saved to the same file are one page. It is safe for concurrent use.
+type Graph struct {
+	mu    sync.Mutex
+	pages map[string]Page
+	links map[string][]string
+}
+
+// NewGraph creates an empty graph.
+func NewGraph() *Graph {
+	return &Graph{pages: make(map[string]Page), links: make(map[string][]string)}
+}
+
+// AddPage records a captured page. The first URL saved to a path is kept.
+func (g *Graph) AddPage(p Page) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.pages[p.Path]; !ok {
+		g.pages[p.Path] = p
+	}
+}
+
+// AddLink records a navigation link between the local paths of two pages.
+func (g *Graph) AddLink(from, to string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.links[from] = append(g.links[from], to)
+}
+
+// node is a page in the site map tree.
+type node struct {
+	Page
+	Children []*node
+}
+
+// tree arranges the pages breadth-first from seed, so every page hangs below
+// the page it is reached through in the fewest clicks. Pages the seed does not
+// lead to are returned separately.
+func (g *Graph) tree(seed string) (root *node, orphans []Page) {
+	nodes := make(map[string]*node, len(g.pages))
+	for path, p := range g.pages {
+		nodes[path] = &node{Page: p}
+	}
+	root = nodes[seed]
+	if root != nil {
+		reached := map[string]bool{seed: true}
+		level := []*node{root}
+		for len(level) > 0 {
+			var next []*node
+			for _, parent := range level {
+				for _, to := range g.links[parent.Path] {
+					child, ok := nodes[to]
+					if !ok || reached[to] {
+						continue
+					}
+					reached[to] = true
+					parent.Children = append(parent.Children, child)
+					next = append(next, child)
+				}
+				sort.Slice(parent.Children, func(i, j int) bool { return parent.Children[i].Path < parent.Children[j].Path })
+			}
+			level = next
+		}
+		for path, p := range g.pages {
+			if !reached[path] {
+				orphans = append(orphans, p)
+			}
+		}
+	} else {
+		for _, p := range g.pages {
+			orphans = append(orphans, p)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Path < orphans[j].Path })
+	return root, orphans
+}
+
+// urlSet is the root element of sitemap.xml.
+type urlSet struct {
+	XMLName xml.Name   `xml:"urlset"`
+	XMLNS   string     `xml:"xmlns,attr"`
+	URLs    []urlEntry `xml:"url"`
+}
+
+type urlEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Write stores the sitemap and the site map page in s. seed is the local
+// path of the page the crawl started at and sets the top of the tree.
+func (g *Graph) Write(s storage.Storage, seed string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	set := urlSet{XMLNS: xmlNamespace}
+	for _, p := range g.pages {
+		e := urlEntry{Loc: p.URL}
+		if t, err := http.ParseTime(p.LastModified); err == nil {
+			e.LastMod = t.UTC().Format(lastModLayout)
+		}
+		set.URLs = append(set.URLs, e)
+	}
+	sort.Slice(set.URLs, func(i, j int) bool { return set.URLs[i].Loc < set.URLs[j].Loc })
+	data, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sitemap: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	if err := storage.WriteFile(s, XMLFileName, data); err != nil {
+		return fmt.Errorf("failed to write sitemap: %w", err)
+	}
+
+	root, orphans := g.tree(seed)
+	seedURL := seed
+	if root != nil {
+		seedURL = root.URL
+	}
+	f, err := s.Create(HTMLFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create site map page: %w", err)
+	}
+	err = pageTemplate.Execute(f, struct {
+		Seed      string
+		Generated string
+		Pages     int
+		Root      *node
+		Orphans   []Page
+	}{seedURL, time.Now().UTC().Format(time.RFC1123), len(g.pages), root, orphans})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write site map page: %w", err)
+	}
+	return nil
+}
+
+var pageTemplate = template.Must(template.New("sitemap").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Site map of {{.Seed}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; line-height: 1.5; }
+ul { list-style: none; padding-left: 1.2em; border-left: 1px solid #ddd; }
+.path { color: #777; font-size: 0.85em; margin-left: 0.5em; }
+</style>
+</head>
+<body>
+<h1>Site map</h1>
+<p>{{.Pages}} pages archived from <code>{{.Seed}}</code>, generated {{.Generated}}.</p>
+{{define "node"}}<li><a href="{{.Path}}">{{if .Title}}{{.Title}}{{else}}{{.Path}}{{end}}</a><span class="path">{{.Path}}</span>{{if .Children}}
+<ul>{{range .Children}}{{template "node" .}}{{end}}</ul>{{end}}</li>
+{{end}}{{if .Root}}<ul>{{template "node" .Root}}</ul>{{end}}
+{{if .Orphans}}<h2>Other pages</h2>
+<ul>{{range .Orphans}}<li><a href="{{.Path}}">{{if .Title}}{{.Title}}{{else}}{{.Path}}{{end}}</a><span class="path">{{.Path}}</span></li>
+{{end}}</ul>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,141 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package sitetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+)
+
+const (
+	// FixtureFile indexes the resources of a fixture directory.
+	FixtureFile = "fixture.json"
+	// bodiesDir holds the response bodies of a fixture, named by digest.
+	bodiesDir = "bodies"
+)
+
+// volatileHeaders are left out of recordings: they change on every request,
+// are recomputed on replay, or would leak the recording session.
+var volatileHeaders = []string{
+	"Date", "Content-Length", "Transfer-Encoding", "Connection", "Keep-Alive",
+	"Set-Cookie", "Age", "Alt-Svc", "Report-To", "Nel", "Server-Timing",
+	"Cf-Ray", "X-Request-Id",
+}
+
+// Recorder is an http.RoundTripper that records every GET response passing
+// through it into Site, turning a crawl of a live site into a fixture.
+type Recorder struct {
+	// Next is the wrapped transport; nil means http.DefaultTransport.
+	Next http.RoundTripper
+	Site *Site
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record %s: %w", req.URL, err)
+	}
+	header := resp.Header.Clone()
+	for _, name := range volatileHeaders {
+		header.Del(name)
+	}
+	r.Site.Set(req.URL.String(), &Resource{Status: resp.StatusCode, Header: header, Body: body})
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// Record crawls the live site at seed to depth, as the downloader would with
+// cfg, and returns everything it fetched as a site. The crawl writes nothing
+// to disk.
+func Record(ctx context.Context, seed string, depth int, cfg *config.Config) (*Site, error) {
+	site := NewSite(seed)
+	if _, err := crawl(ctx, seed, depth, &Recorder{Next: cfg.Transport, Site: site}, cfg); err != nil {
+		return nil, err
+	}
+	return site, nil
+}
+
+// fixture is the JSON form of a site.
+type fixture struct {
+	Seed      string                     `json:"seed"`
+	Resources map[string]fixtureResource `json:"resources"`
+}
+
+type fixtureResource struct {
+	Resource
+	// Body is the file holding the body, relative to the fixture directory.
+	Body string `json:"body"`
+}
+
+// Save writes the site as a fixture into dir. Bodies are stored once per
+// content, so fixtures stay small enough to commit as test data.
+func (s *Site) Save(dir string, dirPerms, filePerms os.FileMode) error {
+	if err := os.MkdirAll(filepath.Join(dir, bodiesDir), dirPerms); err != nil {
+		return fmt.Errorf("failed to create fixture directory %s: %w", dir, err)
+	}
+	f := fixture{Seed: s.Seed, Resources: make(map[string]fixtureResource)}
+	for _, u := range s.URLs() {
+		r, _ := s.Get(u)
+		sum := sha256.Sum256(r.Body)
+		name := bodiesDir + "/" + hex.EncodeToString(sum[:])
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), r.Body, filePerms); err != nil {
+			return fmt.Errorf("failed to write fixture body of %s: %w", u, err)
+		}
+		f.Resources[u] = fixtureResource{Resource: *r, Body: name}
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FixtureFile), data, filePerms); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+// Load reads the fixture in dir.
+func Load(dir string) (*Site, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FixtureFile)) // #nosec G304 - dir is a fixture directory chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture: %w", err)
+	}
+	site := NewSite(f.Seed)
+	for u, fr := range f.Resources {
+		if !filepath.IsLocal(filepath.FromSlash(fr.Body)) {
+			return nil, fmt.Errorf("fixture body of %s is outside the fixture: %s", u, fr.Body)
+		}
+		body, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(fr.Body))) // #nosec G304 - checked to stay inside the fixture
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture body of %s: %w", u, err)
+		}
+		r := fr.Resource
+		r.Body = body
+		site.Set(u, &r)
+	}
+	return site, nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package sitetest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// GeneratedSeed is the seed URL of generated sites. The .test TLD is reserved
+// and never resolves, so a generated site cannot leak onto the network.
+const GeneratedSeed = "http://site.test/"
+
+// pixel is a 1x1 transparent PNG.
+var pixel = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d,
+	0x49, 0x48, 0x44, 0x52, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+	0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4, 0x89, 0x00, 0x00, 0x00,
+	0x0b, 0x49, 0x44, 0x41, 0x54, 0x78, 0x9c, 0x63, 0x60, 0x00, 0x02, 0x00,
+	0x00, 0x05, 0x00, 0x01, 0x7a, 0x5e, 0xab, 0x3f, 0x00, 0x00, 0x00, 0x00,
+	0x49, 0x45, 0x4e, 0x44, 0xae, 0x42, 0x60, 0x82,
+}
+
+// Spec describes a generated site.
+type Spec struct {
+	// Pages is the number of HTML pages, the index included.
+	Pages int
+	// Links is the number of links from each page to other pages.
+	Links int
+	// Assets adds a stylesheet with a background image, a script, and an
+	// image per page.
+	Assets bool
+	// Broken adds a link from the index to a page that does not exist.
+	Broken bool
+	// Query adds a listing linked from the index whose pages differ only in
+	// their query string.
+	Query bool
+	// Seed makes the random link structure reproducible.
+	Seed uint64
+}
+
+// Generate builds a fake site from spec. The same spec always yields the
+// same site, so archives of it can be compared with golden snapshots. Links
+// are relative, so the site works in memory and over Start alike.
+func Generate(spec Spec) *Site {
+	site := NewSite(GeneratedSeed)
+	pages := max(spec.Pages, 1)
+	rng := rand.New(rand.NewPCG(spec.Seed, spec.Seed^0x9e3779b97f4a7c15)) // #nosec G404 - reproducible structure, not security
+
+	if spec.Assets {
+		site.Add(GeneratedSeed+"style.css", "text/css", []byte("body { background: url(img/bg.png); font-family: sans-serif; }\n"))
+		site.Add(GeneratedSeed+"img/bg.png", "image/png", pixel)
+		site.Add(GeneratedSeed+"app.js", "text/javascript", []byte("document.documentElement.className = 'js';\n"))
+	}
+	for i := range pages {
+		var links []int
+		for range min(spec.Links, pages-1) {
+			// Every page links onwards first, so all of them are reachable
+			target := (i + 1 + rng.IntN(pages-1)) % pages
+			if len(links) == 0 {
+				target = (i + 1) % pages
+			}
+			links = append(links, target)
+		}
+		site.Add(GeneratedSeed+pagePath(i), "text/html; charset=utf-8", []byte(page(i, links, spec.Assets, spec.Broken && i == 0, spec.Query && i == 0)))
+		if spec.Assets {
+			site.Add(fmt.Sprintf("%simg/%d.png", GeneratedSeed, i), "image/png", pixel)
+		}
+	}
+	if spec.Query {
+		for n := 1; n <= listingPages; n++ {
+			site.Add(fmt.Sprintf("%slist?page=%d", GeneratedSeed, n), "text/html; charset=utf-8", []byte(listing(n)))
+		}
+	}
+	return site
+}
+
+// listingPages is the number of pages of the listing Query adds.
+const listingPages = 2
+
+// pagePath is the path of page i relative to the site root.
+func pagePath(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return fmt.Sprintf("page-%d.html", i)
+}
+
+// page renders page i linking to the given pages.
+func page(i int, links []int, assets, broken, query bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<title>Page %d</title>\n", i)
+	if assets {
+		b.WriteString("<link rel=\"stylesheet\" href=\"style.css\">\n<script src=\"app.js\"></script>\n")
+	}
+	fmt.Fprintf(&b, "</head>\n<body>\n<h1>Page %d</h1>\n", i)
+	if assets {
+		fmt.Fprintf(&b, "<img src=\"img/%d.png\" alt=\"Image %d\">\n", i, i)
+	}
+	b.WriteString("<ul>\n")
+	for _, target := range links {
+		href := pagePath(target)
+		if href == "" {
+			href = "./"
+		}
+		fmt.Fprintf(&b, "<li><a href=\"%s\">Page %d</a></li>\n", href, target)
+	}
+	if broken {
+		b.WriteString("<li><a href=\"missing.html\">Missing</a></li>\n")
+	}
+	if query {
+		b.WriteString("<li><a href=\"list?page=1\">Listing</a></li>\n")
+	}
+	b.WriteString("</ul>\n</body>\n</html>\n")
+	return b.String()
+}
+
+// listing renders page n of the listing, linking to the other pages of it.
+func listing(n int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<title>Listing %d</title>\n</head>\n<body>\n<h1>Listing %d</h1>\n<ul>\n", n, n)
+	for other := 1; other <= listingPages; other++ {
+		if other != n {
+			fmt.Fprintf(&b, "<li><a href=\"list?page=%d\">Listing %d</a></li>\n", other, other)
+		}
+	}
+	b.WriteString("</ul>\n</body>\n</html>\n")
+	return b.String()
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package sitetest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/downloader"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+)
+
+// GoldenFile is the golden snapshot kept in a fixture directory.
+const GoldenFile = "golden.json"
+
+// archiveDir is the output directory archives are produced under. Nothing
+// is written there; it only names the capture.
+const archiveDir = "archive"
+
+// File is a file of an archive in a golden snapshot.
+type File struct {
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Entry is a manifest resource in a golden snapshot, without the fields that
+// change from run to run.
+type Entry struct {
+	URL         string `json:"url"`
+	Path        string `json:"path"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType,omitempty"`
+	Digest      string `json:"digest,omitempty"`
+}
+
+// Golden is a snapshot of an archive that later archives of the same site
+// are compared with.
+type Golden struct {
+	Files     map[string]File `json:"files"`
+	Resources []Entry         `json:"resources"`
+}
+
+// Archive crawls the site from its seed to depth entirely in memory and
+// returns the files the downloader produced. cfg is copied; its transport
+// and storage are replaced by the site and the returned memory storage.
+func Archive(ctx context.Context, site *Site, depth int, cfg *config.Config) (*storage.Memory, error) {
+	return crawl(ctx, site.Seed, depth, site, cfg)
+}
+
+// crawl runs the downloader over transport into memory storage.
+func crawl(ctx context.Context, seed string, depth int, transport http.RoundTripper, cfg *config.Config) (*storage.Memory, error) {
+	mem := storage.NewMemory()
+	run := *cfg
+	run.Transport = transport
+	run.StorageURL = ""
+	run.OpenStorage = func(string) (storage.Storage, error) { return mem, nil }
+	run.OutputDir = archiveDir
+	if err := downloader.DownloadURLs(ctx, seed, nil, depth, archiveDir, false, false, &run); err != nil {
+		return nil, err
+	}
+	return mem, nil
+}
+
+// Snapshot takes the golden snapshot of an archive. The manifest is reduced
+// to entries that do not depend on when the crawl ran, and JSON reports next
+// to it, which carry timestamps of the run, are left out.
+func Snapshot(mem *storage.Memory) (*Golden, error) {
+	g := &Golden{Files: make(map[string]File)}
+	archived := make(map[string]bool)
+	if data, err := storage.ReadFile(mem, manifest.Path); err == nil {
+		m := &manifest.Manifest{}
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		for _, r := range m.Resources {
+			g.Resources = append(g.Resources, Entry{URL: r.URL, Path: r.Path, Status: r.Status, ContentType: r.ContentType, Digest: r.Digest})
+			archived[r.Path] = true
+		}
+		sort.Slice(g.Resources, func(i, j int) bool { return g.Resources[i].URL < g.Resources[j].URL })
+	}
+	for _, name := range mem.Names() {
+		if !archived[name] && (path.Dir(name) == "." || path.Dir(name) == manifest.Dir) && path.Ext(name) == ".json" {
+			continue
+		}
+		data, err := storage.ReadFile(mem, name)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		g.Files[name] = File{Size: len(data), SHA256: hex.EncodeToString(sum[:])}
+	}
+	return g, nil
+}
+
+// LoadGolden reads a golden snapshot.
+func LoadGolden(file string) (*Golden, error) {
+	data, err := os.ReadFile(file) // #nosec G304 - file is a golden snapshot chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read golden snapshot: %w", err)
+	}
+	g := &Golden{}
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, fmt.Errorf("failed to parse golden snapshot: %w", err)
+	}
+	return g, nil
+}
+
+// Write stores the golden snapshot in file.
+func (g *Golden) Write(file string, perms os.FileMode) error {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode golden snapshot: %w", err)
+	}
+	if err := os.WriteFile(file, append(data, '\n'), perms); err != nil {
+		return fmt.Errorf("failed to write golden snapshot: %w", err)
+	}
+	return nil
+}
+
+// Diff lists how g differs from want, one line per difference, in order.
+func (g *Golden) Diff(want *Golden) []string {
+	var diffs []string
+	names := make(map[string]bool)
+	for name := range want.Files {
+		names[name] = true
+	}
+	for name := range g.Files {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		got, inGot := g.Files[name]
+		exp, inWant := want.Files[name]
+		switch {
+		case !inGot:
+			diffs = append(diffs, "missing file "+name)
+		case !inWant:
+			diffs = append(diffs, "unexpected file "+name)
+		case got != exp:
+			diffs = append(diffs, fmt.Sprintf("changed file %s: %d bytes, was %d", name, got.Size, exp.Size))
+		}
+	}
+
+	gotEntries := make(map[string]Entry)
+	for _, e := range g.Resources {
+		gotEntries[e.URL] = e
+	}
+	wantEntries := make(map[string]Entry)
+	urls := make(map[string]bool)
+	for _, e := range want.Resources {
+		wantEntries[e.URL] = e
+		urls[e.URL] = true
+	}
+	for u := range gotEntries {
+		urls[u] = true
+	}
+	for _, u := range sortedKeys(urls) {
+		got, inGot := gotEntries[u]
+		exp, inWant := wantEntries[u]
+		switch {
+		case !inGot:
+			diffs = append(diffs, "missing resource "+u)
+		case !inWant:
+			diffs = append(diffs, "unexpected resource "+u)
+		case got != exp:
+			diffs = append(diffs, fmt.Sprintf("changed resource %s: %+v, was %+v", u, got, exp))
+		}
+	}
+	return diffs
+}
+
+// Compare archives site and compares the archive with the golden snapshot in
+// file. With update, the snapshot is rewritten instead and no differences
+// are reported.
+func Compare(ctx context.Context, site *Site, depth int, cfg *config.Config, file string, update bool) ([]string, error) {
+	mem, err := Archive(ctx, site, depth, cfg)
+	if err != nil {
+		return nil, err
+	}
+	got, err := Snapshot(mem)
+	if err != nil {
+		return nil, err
+	}
+	if update {
+		return nil, got.Write(file, cfg.FilePerms)
+	}
+	want, err := LoadGolden(file)
+	if err != nil {
+		return nil, err
+	}
+	return got.Diff(want), nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
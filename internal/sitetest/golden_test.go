@@ -0,0 +1,84 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package sitetest
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/storage"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden snapshot in testdata")
+
+// testSpec is the site the golden snapshot in testdata was taken of.
+var testSpec = Spec{Pages: 6, Links: 2, Assets: true, Broken: true, Query: true, Seed: 1}
+
+// crawlServed archives the site generated from testSpec over a loopback
+// server and returns the archive and the server's URL.
+func crawlServed(t *testing.T) (*storage.Memory, string) {
+	t.Helper()
+	srv := Generate(testSpec).Start()
+	t.Cleanup(srv.Close)
+	cfg := config.New()
+	mem, err := crawl(context.Background(), srv.URL+"/", 3, http.DefaultTransport, cfg)
+	if err != nil {
+		t.Fatalf("crawl: %v", err)
+	}
+	return mem, srv.URL
+}
+
+func TestGolden(t *testing.T) {
+	mem, base := crawlServed(t)
+	got, err := Snapshot(mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The server listens on a port of its own every run
+	for i := range got.Resources {
+		got.Resources[i].URL = strings.Replace(got.Resources[i].URL, base+"/", GeneratedSeed, 1)
+	}
+
+	golden := filepath.Join("testdata", GoldenFile)
+	if *update {
+		if err := got.Write(golden, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := LoadGolden(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, diff := range got.Diff(want) {
+		t.Error(diff)
+	}
+}
+
+// TestQueryPages checks that pages differing only in their query string are
+// saved as files of their own and linked to as such.
+func TestQueryPages(t *testing.T) {
+	mem, _ := crawlServed(t)
+	for n, page := range []string{"list?page=1", "list?page=2"} {
+		data, err := storage.ReadFile(mem, page)
+		if err != nil {
+			t.Fatalf("listing page %d was not saved: %v", n+1, err)
+		}
+		if want := "<h1>Listing " + string(rune('1'+n)) + "</h1>"; !strings.Contains(string(data), want) {
+			t.Errorf("%s does not hold %s", page, want)
+		}
+	}
+	index, err := storage.ReadFile(mem, "index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), `href="list%3Fpage=1"`) {
+		t.Errorf("index does not link to the saved listing page:\n%s", index)
+	}
+}
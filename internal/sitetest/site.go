@@ -0,0 +1,154 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package sitetest runs the downloader end to end against sites it controls:
+// generated fake sites and fixtures recorded from live ones, served in memory
+// or over httptest, with the produced archives compared to golden snapshots.
+package sitetest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Resource is a response the site answers with.
+type Resource struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"-"`
+}
+
+// Site is a set of resources keyed by absolute URL. It is an http.RoundTripper,
+// so a crawl using it as transport never touches the network, and an
+// http.Handler serving its resources by path for httptest. It is safe for
+// concurrent use.
+type Site struct {
+	// Seed is the URL a crawl of the site starts at.
+	Seed string
+
+	mu        sync.RWMutex
+	resources map[string]*Resource
+	// paths maps a request URI to the first URL added with it, for ServeHTTP.
+	paths map[string]string
+}
+
+// NewSite returns an empty site crawled from seed.
+func NewSite(seed string) *Site {
+	return &Site{Seed: seed, resources: make(map[string]*Resource), paths: make(map[string]string)}
+}
+
+// Add adds a 200 response of the given content type.
+func (s *Site) Add(rawURL, contentType string, body []byte) {
+	s.Set(rawURL, &Resource{Status: http.StatusOK, Header: http.Header{"Content-Type": {contentType}}, Body: body})
+}
+
+// Set adds or replaces the response for rawURL.
+func (s *Site) Set(rawURL string, r *Resource) {
+	key := resourceKey(rawURL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[key] = r
+	if u, err := url.Parse(key); err == nil {
+		if _, ok := s.paths[u.RequestURI()]; !ok {
+			s.paths[u.RequestURI()] = key
+		}
+	}
+}
+
+// Get returns the response for rawURL.
+func (s *Site) Get(rawURL string) (*Resource, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.resources[resourceKey(rawURL)]
+	return r, ok
+}
+
+// URLs lists the URLs of the site in order.
+func (s *Site) URLs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	urls := make([]string, 0, len(s.resources))
+	for u := range s.resources {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// RoundTrip implements http.RoundTripper. URLs the site lacks are answered
+// with 404.
+func (s *Site) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	r, ok := s.Get(req.URL.String())
+	if !ok {
+		r = &Resource{Status: http.StatusNotFound, Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, Body: []byte("404 page not found\n")}
+	}
+	body := r.Body
+	if req.Method == http.MethodHead {
+		body = nil
+	}
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(r.Body)))
+	return &http.Response{
+		Status:        strconv.Itoa(r.Status) + " " + http.StatusText(r.Status),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler, answering each request with the
+// resource of the same path and query on any host.
+func (s *Site) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.RLock()
+	key, ok := s.paths[req.URL.RequestURI()]
+	r := s.resources[key]
+	s.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	for name, values := range r.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(r.Status)
+	if req.Method != http.MethodHead {
+		_, _ = w.Write(r.Body)
+	}
+}
+
+// Start serves the site over HTTP on a loopback port. Links between its
+// pages must be relative for a crawl of the server to stay on it.
+func (s *Site) Start() *httptest.Server {
+	return httptest.NewServer(s)
+}
+
+// resourceKey drops the fragment, which is never sent to a server.
+func resourceKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Local stores files in a directory.
+type Local struct {
+	root     string
+	dirPerms os.FileMode
+}
+
+// NewLocal returns the storage rooted at dir. Directories are created as
+// needed with dirPerms; files are created like os.Create does, so a web
+// server can serve the capture. A file created again replaces the old one
+// rather than truncating it, since captures may share it by hardlink.
+func NewLocal(dir string, dirPerms os.FileMode) *Local {
+	return &Local{root: dir, dirPerms: dirPerms}
+}
+
+// Root returns the directory the files are stored in.
+func (l *Local) Root() string {
+	return l.root
+}
+
+func (l *Local) path(name string) string {
+	return filepath.Join(l.root, filepath.FromSlash(name))
+}
+
+// Create implements Storage.
+func (l *Local) Create(name string) (io.WriteCloser, error) {
+	path := l.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), l.dirPerms); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	// Truncating a hardlinked file would rewrite every capture sharing it
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to replace file %s: %w", path, err)
+	}
+	f, err := os.Create(path) // #nosec G304 - names are sanitized local paths inside the capture
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Open implements Storage.
+func (l *Local) Open(name string) (io.ReadCloser, error) {
+	f, err := os.Open(l.path(name)) // #nosec G304 - names are sanitized local paths inside the capture
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	return f, nil
+}
+
+// Remove implements Storage.
+func (l *Local) Remove(name string) error {
+	if err := os.Remove(l.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", name, err)
+	}
+	return nil
+}
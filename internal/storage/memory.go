@@ -0,0 +1,73 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Memory keeps files in memory, for programs that process captures without
+// touching the disk and for tests.
+type Memory struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// NewMemory returns an empty in-memory storage.
+func NewMemory() *Memory {
+	return &Memory{files: make(map[string][]byte)}
+}
+
+// Create implements Storage. The file appears when the writer is closed.
+func (m *Memory) Create(name string) (io.WriteCloser, error) {
+	return &memoryFile{memory: m, name: name}, nil
+}
+
+// Open implements Storage.
+func (m *Memory) Open(name string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	data, ok := m.files[name]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("failed to open %s: %w", name, ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Remove implements Storage.
+func (m *Memory) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, name)
+	return nil
+}
+
+// Names lists the stored files in order.
+func (m *Memory) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type memoryFile struct {
+	bytes.Buffer
+	memory *Memory
+	name   string
+}
+
+func (f *memoryFile) Close() error {
+	f.memory.mu.Lock()
+	defer f.memory.mu.Unlock()
+	f.memory.files[f.name] = f.Bytes()
+	return nil
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultS3Region is used when no region is configured.
+	defaultS3Region = "us-east-1"
+	// s3Timeout bounds a single object request.
+	s3Timeout = 5 * time.Minute
+	// amzDateLayout is the timestamp format of AWS Signature Version 4.
+	amzDateLayout = "20060102T150405Z"
+)
+
+// Credentials are the AWS credentials requests are signed with.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// S3 writes files as objects of an S3 bucket, using path-style requests so
+// S3-compatible services such as MinIO work as well. Each file is kept in
+// memory until its writer is closed and then uploaded in one request.
+type S3 struct {
+	endpoint *url.URL
+	region   string
+	bucket   string
+	prefix   string
+	creds    Credentials
+	client   *http.Client
+}
+
+// NewS3 returns the storage writing under prefix in bucket. An empty
+// endpoint uses AWS in region.
+func NewS3(endpoint, region, bucket, prefix string, creds Credentials) *S3 {
+	if region == "" {
+		region = defaultS3Region
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		u = &url.URL{Scheme: "https", Host: endpoint}
+	}
+	return &S3{endpoint: u, region: region, bucket: bucket, prefix: prefix, creds: creds, client: &http.Client{Timeout: s3Timeout}}
+}
+
+// key returns the object key of name.
+func (s *S3) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+// Create implements Storage.
+func (s *S3) Create(name string) (io.WriteCloser, error) {
+	return &s3Object{s3: s, name: name}, nil
+}
+
+// Open implements Storage.
+func (s *S3) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", name, ErrNotExist)
+	}
+	if err := s3Error(name, resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Remove implements Storage.
+func (s *S3) Remove(name string) error {
+	resp, err := s.do(http.MethodDelete, name, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil
+	}
+	if err := s3Error(name, resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// s3Error turns a failed response into an error, closing its body.
+func s3Error(name string, resp *http.Response) error {
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	return fmt.Errorf("S3 request for %s failed with status %d: %s", name, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// do sends a signed request for the object of name.
+func (s *S3) do(method, name string, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + s.key(name)
+	u.RawPath = strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + "/" + escapeKey(s.bucket) + "/" + escapeKey(s.key(name))
+
+	req, err := http.NewRequestWithContext(context.Background(), method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request for %s: %w", name, err)
+	}
+	req.ContentLength = int64(len(body))
+	if method == http.MethodPut {
+		// Objects keep a content type, so a bucket serving the capture as a
+		// website hands out pages as HTML
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+	}
+	s.sign(req, body, time.Now().UTC())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send S3 request for %s: %w", name, err)
+	}
+	return resp, nil
+}
+
+// sign adds an AWS Signature Version 4 to req.
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format(amzDateLayout)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if s.creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.creds.SessionToken)
+		signed = append(signed, "x-amz-security-token")
+	}
+
+	var headers strings.Builder
+	for _, h := range signed {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		headers.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, headers.String(), signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := []byte("AWS4" + s.creds.SecretAccessKey)
+	for _, part := range []string{date, s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.creds.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// escapeKey URI-encodes an object key as Signature Version 4 expects,
+// keeping the slashes between its segments.
+func escapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		ch := key[i]
+		if ch == '/' || ch == '-' || ch == '_' || ch == '.' || ch == '~' ||
+			(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Object buffers a file and uploads it when closed.
+type s3Object struct {
+	bytes.Buffer
+	s3   *S3
+	name string
+}
+
+func (o *s3Object) Close() error {
+	resp, err := o.s3.do(http.MethodPut, o.name, o.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := s3Error(o.name, resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package storage abstracts where the files of a capture are written: a
+// local directory, an S3 bucket written to directly, or memory. Names are
+// slash-separated paths relative to the root of the capture.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// SchemeS3 selects S3 storage in a storage URL.
+const SchemeS3 = "s3"
+
+// ErrNotExist is returned for names that were never written.
+var ErrNotExist = os.ErrNotExist
+
+// Storage stores the files of a capture. It is safe for concurrent use.
+type Storage interface {
+	// Create opens name for writing, replacing an existing file. The file
+	// is only complete once the writer is closed without error.
+	Create(name string) (io.WriteCloser, error)
+	// Open opens name for reading.
+	Open(name string) (io.ReadCloser, error)
+	// Remove deletes name; removing a missing name is not an error.
+	Remove(name string) error
+}
+
+// WriteFile writes data to name in s.
+func WriteFile(s Storage, name string, data []byte) error {
+	w, err := s.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// ReadFile reads name from s.
+func ReadFile(s Storage, name string) ([]byte, error) {
+	r, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// Options configure the storage opened by Open.
+type Options struct {
+	DirPerms os.FileMode
+	// S3Endpoint and S3Region locate the S3 service; an empty endpoint
+	// uses AWS in S3Region.
+	S3Endpoint string
+	S3Region   string
+}
+
+// Open returns the storage of the capture named capture. An empty rawURL
+// stores it in the local directory dir; "s3://bucket/prefix" stores it under
+// prefix/capture in the bucket, with credentials from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables.
+func Open(rawURL, dir, capture string, opts Options) (Storage, error) {
+	if rawURL == "" {
+		return NewLocal(dir, opts.DirPerms), nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage URL %q: %w", rawURL, err)
+	}
+	switch u.Scheme {
+	case SchemeS3:
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid storage URL %q: missing bucket", rawURL)
+		}
+		prefix := strings.Trim(u.Path, "/")
+		if capture = strings.Trim(capture, "/"); capture != "" {
+			prefix = strings.TrimPrefix(prefix+"/"+capture, "/")
+		}
+		creds := Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return nil, errors.New("S3 storage needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		return NewS3(opts.S3Endpoint, opts.S3Region, u.Host, prefix, creds), nil
+	default:
+		return nil, fmt.Errorf("unsupported storage URL %q (use s3://bucket/prefix)", rawURL)
+	}
+}
+
+// Check reports whether rawURL is a storage URL Open accepts.
+func Check(rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid storage URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != SchemeS3 || u.Host == "" {
+		return fmt.Errorf("unsupported storage URL %q (use s3://bucket/prefix)", rawURL)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/chunk"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+// Put stores data under its SHA-256 digest unless an object with that digest
+// already exists, and returns the digest.
+func (s *Store) Put(data []byte, dirPerms, filePerms os.FileMode) (string, error) {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	path := s.Path(digest)
+	if _, err := os.Stat(path); err == nil {
+		return digest, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), dirPerms); err != nil {
+		return "", fmt.Errorf("failed to create store directory: %w", err)
+	}
+	// Write to a temporary name first so an interrupted run never leaves a
+	// truncated object under a valid digest
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create object %s: %w", digest, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write object %s: %w", digest, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write object %s: %w", digest, err)
+	}
+	if err := os.Chmod(tmp.Name(), filePerms); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to set permissions of object %s: %w", digest, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to store object %s: %w", digest, err)
+	}
+	return digest, nil
+}
+
+// ChunkStats summarizes a chunking or restore pass.
+type ChunkStats struct {
+	Files  int
+	Bytes  int64
+	Chunks int
+}
+
+// Chunk replaces every file of at least threshold bytes in the captures
+// below captureDir by content-defined chunks in the store of outputDir, and
+// records the chunk digests in the capture manifests. Chunks shared between
+// captures are stored once.
+func Chunk(outputDir, captureDir string, threshold int64, opts chunk.Options, dirPerms, filePerms os.FileMode) (ChunkStats, error) {
+	s := Open(outputDir)
+	var stats ChunkStats
+	err := eachManifest(captureDir, filePerms, func(dir string, m *manifest.Manifest) (bool, error) {
+		changed := false
+		for i := range m.Resources {
+			r := &m.Resources[i]
+			if len(r.Chunks) > 0 || r.Path == "" {
+				continue
+			}
+			path := filepath.Join(dir, filepath.FromSlash(r.Path))
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() || info.Size() < threshold {
+				continue
+			}
+			digests, err := s.putFile(path, opts, dirPerms, filePerms)
+			if err != nil {
+				return changed, err
+			}
+			r.Chunks, r.Linked = digests, ""
+			changed = true
+			stats.Files++
+			stats.Bytes += info.Size()
+			stats.Chunks += len(digests)
+		}
+		if !changed {
+			return false, nil
+		}
+		// The manifest must reference the chunks before the files go away
+		if err := m.Write(dir, filePerms); err != nil {
+			return false, err
+		}
+		for _, r := range m.Resources {
+			if len(r.Chunks) == 0 {
+				continue
+			}
+			path := filepath.Join(dir, filepath.FromSlash(r.Path))
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return false, fmt.Errorf("failed to remove chunked file %s: %w", path, err)
+			}
+		}
+		return false, nil
+	})
+	return stats, err
+}
+
+// putFile stores the chunks of the file at path and returns their digests.
+func (s *Store) putFile(path string, opts chunk.Options, dirPerms, filePerms os.FileMode) ([]string, error) {
+	f, err := os.Open(path) // #nosec G304 - path comes from a capture manifest
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	chunker, err := chunk.New(f, opts)
+	if err != nil {
+		return nil, err
+	}
+	var digests []string
+	for {
+		data, err := chunker.Next()
+		if errors.Is(err, io.EOF) {
+			return digests, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to chunk %s: %w", path, err)
+		}
+		digest, err := s.Put(data, dirPerms, filePerms)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+	}
+}
+
+// Restore reassembles the chunked files of the captures below captureDir
+// from the store of outputDir, verifying every chunk, and clears their chunk
+// lists so the captures are plain mirrors again.
+func Restore(outputDir, captureDir string, dirPerms, filePerms os.FileMode) (ChunkStats, error) {
+	s := Open(outputDir)
+	var stats ChunkStats
+	err := eachManifest(captureDir, filePerms, func(dir string, m *manifest.Manifest) (bool, error) {
+		changed := false
+		for i := range m.Resources {
+			r := &m.Resources[i]
+			if len(r.Chunks) == 0 {
+				continue
+			}
+			path := filepath.Join(dir, filepath.FromSlash(r.Path))
+			n, err := s.assemble(path, r.Chunks, dirPerms, filePerms)
+			if err != nil {
+				return changed, err
+			}
+			stats.Files++
+			stats.Bytes += n
+			stats.Chunks += len(r.Chunks)
+			r.Chunks = nil
+			changed = true
+		}
+		return changed, nil
+	})
+	return stats, err
+}
+
+// assemble writes the concatenation of the given chunks to path.
+func (s *Store) assemble(path string, digests []string, dirPerms, filePerms os.FileMode) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), dirPerms); err != nil {
+		return 0, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerms) // #nosec G304 - path comes from a capture manifest
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var total int64
+	for _, digest := range digests {
+		data, err := os.ReadFile(s.Path(digest))
+		if err != nil {
+			return total, fmt.Errorf("failed to read chunk %s of %s: %w", digest, path, err)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != digest {
+			return total, fmt.Errorf("chunk %s of %s is corrupt", digest, path)
+		}
+		n, err := f.Write(data)
+		total += int64(n)
+		if err != nil {
+			return total, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return total, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return total, nil
+}
+
+// eachManifest calls fn for every capture manifest below dir and writes the
+// manifest back when fn reports a change.
+func eachManifest(dir string, filePerms os.FileMode, fn func(dir string, m *manifest.Manifest) (bool, error)) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == DirName {
+			return fs.SkipDir
+		}
+		captureDir, ok := manifest.CaptureDir(path)
+		if d.IsDir() || !ok {
+			return nil
+		}
+		m, err := manifest.Load(captureDir)
+		if err != nil {
+			return err
+		}
+		changed, err := fn(captureDir, m)
+		if changed {
+			if werr := m.Write(captureDir, filePerms); werr != nil && err == nil {
+				err = werr
+			}
+		}
+		return err
+	})
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+// LinkStats summarizes a hardlink pass.
+type LinkStats struct {
+	// Files is the number of saved files now linked to a store object.
+	Files int
+	// Shared is how many of them replaced a copy of an existing object.
+	Shared int
+	// Bytes is the disk space the shared files no longer take up.
+	Bytes int64
+}
+
+// Link hardlinks every saved file of the captures below captureDir to the
+// object with the same content in the store of outputDir, so a file that is
+// the same in many snapshots takes one inode and is stored once. The first
+// copy of a content becomes the object; later copies are replaced by links
+// to it. The digests are recorded as Linked in the manifests, so gc keeps
+// the objects while a capture uses them.
+//
+// Linked files share their data: they must not be rewritten in place, only
+// replaced or removed.
+func Link(outputDir, captureDir string, dirPerms, filePerms os.FileMode) (LinkStats, error) {
+	s := Open(outputDir)
+	var stats LinkStats
+	err := eachManifest(captureDir, filePerms, func(dir string, m *manifest.Manifest) (bool, error) {
+		changed := false
+		for i := range m.Resources {
+			r := &m.Resources[i]
+			if r.Linked != "" || len(r.Chunks) > 0 || r.Path == "" {
+				continue
+			}
+			path := filepath.Join(dir, filepath.FromSlash(r.Path))
+			info, err := os.Lstat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			digest, shared, err := s.linkFile(path, info, dirPerms)
+			if err != nil {
+				return changed, err
+			}
+			r.Linked = digest
+			changed = true
+			stats.Files++
+			if shared {
+				stats.Shared++
+				stats.Bytes += info.Size()
+			}
+		}
+		return changed, nil
+	})
+	return stats, err
+}
+
+// linkFile links the file at path with the store object of its content and
+// reports whether the file was replaced by a link to an existing object.
+func (s *Store) linkFile(path string, info os.FileInfo, dirPerms os.FileMode) (string, bool, error) {
+	digest, err := fileDigest(path)
+	if err != nil {
+		return "", false, err
+	}
+	object := s.Path(digest)
+	existing, err := os.Stat(object)
+	if err != nil {
+		if err := os.MkdirAll(filepath.Dir(object), dirPerms); err != nil {
+			return "", false, fmt.Errorf("failed to create store directory: %w", err)
+		}
+		if err := os.Link(path, object); err != nil {
+			return "", false, fmt.Errorf("failed to link %s into the store, which must be on the same filesystem: %w", path, err)
+		}
+		return digest, false, nil
+	}
+	if os.SameFile(existing, info) {
+		return digest, false, nil
+	}
+	// Link next to the file first and rename over it, so the file is never
+	// missing if the run stops halfway
+	tmp := filepath.Join(filepath.Dir(path), ".tmp-link-"+digest)
+	_ = os.Remove(tmp)
+	if err := os.Link(object, tmp); err != nil {
+		return "", false, fmt.Errorf("failed to link object %s to %s: %w", digest, path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", false, fmt.Errorf("failed to replace %s by a link: %w", path, err)
+	}
+	return digest, true, nil
+}
+
+// fileDigest returns the hex-encoded SHA-256 digest of the file at path.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path) // #nosec G304 - path comes from a capture manifest
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
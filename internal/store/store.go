@@ -0,0 +1,154 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package store implements the content-addressed object store shared by the
+// captures in an output directory. Objects are named by the SHA-256 digest of
+// their content and referenced from capture manifests.
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+const (
+	// DirName is the name of the store directory inside an output directory.
+	DirName = ".store"
+	// fanout is the number of digest characters used for the subdirectory.
+	fanout = 2
+	// digestLength is the length of a hex-encoded SHA-256 digest.
+	digestLength = 64
+)
+
+// Store is an object store rooted at an output directory.
+type Store struct {
+	root string
+}
+
+// Open returns the store belonging to the output directory outputDir.
+func Open(outputDir string) *Store {
+	return &Store{root: filepath.Join(outputDir, DirName)}
+}
+
+// Path returns where the object with the given digest is stored.
+func (s *Store) Path(digest string) string {
+	if len(digest) < fanout {
+		return filepath.Join(s.root, digest)
+	}
+	return filepath.Join(s.root, digest[:fanout], digest)
+}
+
+// Object is a stored blob.
+type Object struct {
+	Digest string
+	Path   string
+	Size   int64
+}
+
+// Objects lists every object in the store.
+func (s *Store) Objects() ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == s.root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || len(d.Name()) != digestLength {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Digest: d.Name(), Path: path, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list store objects: %w", err)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Digest < objects[j].Digest })
+	return objects, nil
+}
+
+// References collects every digest referenced by a manifest below outputDir.
+// Manifests that cannot be read are logged and skipped; it returns how many.
+func References(outputDir string) (map[string]bool, int, error) {
+	refs := make(map[string]bool)
+	skipped := 0
+	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == DirName {
+			return fs.SkipDir
+		}
+		dir, ok := manifest.CaptureDir(path)
+		if d.IsDir() || !ok {
+			return nil
+		}
+		m, err := manifest.Load(dir)
+		if err != nil {
+			slog.Warn("Skipping unreadable manifest", "error", err, "dir", dir)
+			skipped++
+			return nil
+		}
+		for _, r := range m.Resources {
+			if r.Digest != "" {
+				refs[r.Digest] = true
+			}
+			if r.Linked != "" {
+				refs[r.Linked] = true
+			}
+			for _, digest := range r.Chunks {
+				refs[digest] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, skipped, fmt.Errorf("failed to collect manifest references: %w", err)
+	}
+	return refs, skipped, nil
+}
+
+// GC removes objects no manifest below outputDir references and returns them.
+// With dryRun set nothing is removed and the orphans are only reported, as
+// when a manifest could not be read, since its objects are not known.
+func GC(outputDir string, dryRun bool) ([]Object, error) {
+	s := Open(outputDir)
+	objects, err := s.Objects()
+	if err != nil {
+		return nil, err
+	}
+	refs, skipped, err := References(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	if skipped > 0 && !dryRun {
+		slog.Warn("Keeping unreferenced objects while manifests cannot be read", "unreadable", skipped)
+		dryRun = true
+	}
+
+	var orphans []Object
+	for _, obj := range objects {
+		if refs[obj.Digest] {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(obj.Path); err != nil {
+				return orphans, fmt.Errorf("failed to remove object %s: %w", obj.Digest, err)
+			}
+		}
+		orphans = append(orphans, obj)
+	}
+	return orphans, nil
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package timetravel adds a navigation bar to the pages of multi-snapshot
+// archives that links each page to the same page in the other snapshots, like
+// the timeline of the Wayback Machine but offline. The bar is static HTML and
+// CSS, so it also works with scripts disabled and in ZIM readers.
+package timetravel
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/catalog"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+// barID marks the bar, so a page gets one bar when it is added again.
+const barID = "website-archiver-timetravel"
+
+var barTemplate = template.Must(template.New("timetravel").Parse(`<div id="` + barID + `"><style>
+#` + barID + ` { position: relative; z-index: 2147483646; margin: 0; padding: 6px 12px; background: #eef3fb; color: #222; border-bottom: 1px solid #b8c7e0; font: 13px/1.5 sans-serif; text-align: left; }
+#` + barID + ` a { color: #1a4fa0; text-decoration: underline; }
+#` + barID + ` ol { display: inline; margin: 0; padding: 0; list-style: none; }
+#` + barID + ` li { display: inline-block; margin: 0 4px; }
+#` + barID + ` .current { font-weight: bold; }
+#` + barID + ` .missing { color: #888; text-decoration: line-through; }
+</style><nav aria-label="Snapshots">Snapshots of <a href="{{.URL}}">{{.URL}}</a>: <ol>{{range .Snapshots}}<li>{{if .Current}}<span class="current" aria-current="page">{{.Label}}</span>{{else if .Href}}<a href="{{.Href}}">{{.Label}}</a>{{else}}<span class="missing" title="Not in this snapshot">{{.Label}}</span>{{end}}</li>{{end}}</ol>{{if .Index}} <a href="{{.Index}}">All snapshots</a>{{end}}</nav></div>`))
+
+// Snapshot is a capture of a multi-snapshot archive: the directory of its
+// files, relative to the archive, and its name in the bar.
+type Snapshot struct {
+	Dir   string
+	Label string
+}
+
+// entry is a snapshot in the bar of a page.
+type entry struct {
+	Label   string
+	Href    string
+	Current bool
+}
+
+// Add puts the bar at the top of every page of the snapshots of the archive
+// in root, in the order given, linking to index, a page of root listing them,
+// when it is not empty. Snapshots sharing a directory are the same capture.
+// It returns the number of pages changed.
+func Add(root string, snapshots []Snapshot, index string, perms os.FileMode) (int, error) {
+	// pages maps original URLs to their page in each snapshot directory
+	pages := make(map[string]map[string]string)
+	var order []string
+	loaded := make(map[string]bool)
+	for _, s := range snapshots {
+		if loaded[s.Dir] {
+			continue
+		}
+		loaded[s.Dir] = true
+		m, err := manifest.Load(filepath.Join(root, s.Dir))
+		if err != nil {
+			return 0, err
+		}
+		for _, r := range m.Resources {
+			if !strings.Contains(r.ContentType, "html") {
+				continue
+			}
+			original := r.URL
+			if u, _, ok := catalog.OriginalURL(r.URL); ok {
+				original = u
+			}
+			if pages[original] == nil {
+				pages[original] = make(map[string]string)
+				order = append(order, original)
+			}
+			pages[original][s.Dir] = r.Path
+		}
+	}
+
+	changed := 0
+	for _, original := range order {
+		for dir, page := range pages[original] {
+			file := filepath.Join(root, dir, filepath.FromSlash(page))
+			var list []entry
+			for _, s := range snapshots {
+				e := entry{Label: s.Label, Current: s.Dir == dir}
+				if other, ok := pages[original][s.Dir]; ok && !e.Current {
+					e.Href = link(file, filepath.Join(root, s.Dir, filepath.FromSlash(other)))
+				}
+				list = append(list, e)
+			}
+			var indexHref string
+			if index != "" {
+				indexHref = link(file, filepath.Join(root, index))
+			}
+			if err := addBar(file, original, list, indexHref, perms); err != nil {
+				return changed, err
+			}
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// link returns the relative link from the page in file to the file to.
+func link(file, to string) string {
+	rel, err := filepath.Rel(filepath.Dir(file), to)
+	if err != nil {
+		return ""
+	}
+	return (&url.URL{Path: filepath.ToSlash(rel)}).EscapedPath()
+}
+
+// addBar puts the bar at the top of the body of the page in file, replacing
+// one added before. The page is replaced rather than written in place, as it
+// may be hardlinked to the same page of another capture.
+func addBar(file, original string, snapshots []entry, index string, perms os.FileMode) error {
+	data, err := os.ReadFile(file) // #nosec G304 - file is a page of the archive
+	if err != nil {
+		return fmt.Errorf("failed to read page: %w", err)
+	}
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	body := find(doc, func(n *html.Node) bool { return n.Data == "body" })
+	if body == nil {
+		return nil
+	}
+	if old := find(body, func(n *html.Node) bool { return hasID(n, barID) }); old != nil {
+		old.Parent.RemoveChild(old)
+	}
+	var buf strings.Builder
+	err = barTemplate.Execute(&buf, struct {
+		URL       string
+		Snapshots []entry
+		Index     string
+	}{original, snapshots, index})
+	if err != nil {
+		return fmt.Errorf("failed to render snapshot bar: %w", err)
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(buf.String()), body)
+	if err != nil {
+		return fmt.Errorf("failed to parse snapshot bar: %w", err)
+	}
+	for i := len(nodes) - 1; i >= 0; i-- {
+		body.InsertBefore(nodes[i], body.FirstChild)
+	}
+
+	var out bytes.Buffer
+	if err := html.Render(&out, doc); err != nil {
+		return fmt.Errorf("failed to render %s: %w", file, err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, out.Bytes(), perms); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", file, err)
+	}
+	return nil
+}
+
+// find returns the first element below n that match reports true for.
+func find(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func hasID(n *html.Node, id string) bool {
+	for _, a := range n.Attr {
+		if a.Key == "id" && a.Val == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package torrent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// maxNesting bounds the lists and dictionaries nested in a decoded value.
+const maxNesting = 64
+
+// encode appends the bencoding of v, which is a string, []byte, int, int64,
+// []string, []any, or map[string]any, to buf.
+func encode(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case string:
+		fmt.Fprintf(buf, "%d:%s", len(v), v)
+	case []byte:
+		fmt.Fprintf(buf, "%d:", len(v))
+		buf.Write(v)
+	case int:
+		fmt.Fprintf(buf, "i%de", v)
+	case int64:
+		fmt.Fprintf(buf, "i%de", v)
+	case []string:
+		buf.WriteByte('l')
+		for _, s := range v {
+			fmt.Fprintf(buf, "%d:%s", len(s), s)
+		}
+		buf.WriteByte('e')
+	case []any:
+		buf.WriteByte('l')
+		for _, item := range v {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// Keys are sorted as raw strings, which the info hash depends on
+		sort.Strings(keys)
+		buf.WriteByte('d')
+		for _, k := range keys {
+			fmt.Fprintf(buf, "%d:%s", len(k), k)
+			if err := encode(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("cannot bencode %T", v)
+	}
+	return nil
+}
+
+// decoder reads bencoded values into strings, int64s, []any, and
+// map[string]any. It remembers the raw bytes of the top-level info
+// dictionary, which the info hash is computed over.
+type decoder struct {
+	data []byte
+	pos  int
+	info []byte
+}
+
+// decode parses data as a single bencoded value.
+func decode(data []byte) (any, []byte, error) {
+	d := &decoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.pos != len(data) {
+		return nil, nil, fmt.Errorf("trailing data at offset %d", d.pos)
+	}
+	return v, d.info, nil
+}
+
+var errTruncated = errors.New("truncated bencoded data")
+
+func (d *decoder) value(depth int) (any, error) {
+	if d.pos >= len(d.data) {
+		return nil, errTruncated
+	}
+	if depth > maxNesting {
+		return nil, errors.New("bencoded data nested too deeply")
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		end := bytes.IndexByte(d.data[d.pos:], 'e')
+		if end < 0 {
+			return nil, errTruncated
+		}
+		n, err := strconv.ParseInt(string(d.data[d.pos+1:d.pos+end]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer at offset %d", d.pos)
+		}
+		d.pos += end + 1
+		return n, nil
+	case c == 'l':
+		d.pos++
+		list := []any{}
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			item, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		if d.pos >= len(d.data) {
+			return nil, errTruncated
+		}
+		d.pos++
+		return list, nil
+	case c == 'd':
+		d.pos++
+		dict := map[string]any{}
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			key, err := d.str()
+			if err != nil {
+				return nil, err
+			}
+			start := d.pos
+			val, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if depth == 0 && key == "info" {
+				d.info = d.data[start:d.pos]
+			}
+			dict[key] = val
+		}
+		if d.pos >= len(d.data) {
+			return nil, errTruncated
+		}
+		d.pos++
+		return dict, nil
+	case c >= '0' && c <= '9':
+		return d.str()
+	default:
+		return nil, fmt.Errorf("invalid bencoded value at offset %d", d.pos)
+	}
+}
+
+func (d *decoder) str() (string, error) {
+	colon := bytes.IndexByte(d.data[d.pos:], ':')
+	if colon < 0 {
+		return "", errTruncated
+	}
+	n, err := strconv.Atoi(string(d.data[d.pos : d.pos+colon]))
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("invalid string length at offset %d", d.pos)
+	}
+	start := d.pos + colon + 1
+	if n > len(d.data)-start {
+		return "", errTruncated
+	}
+	d.pos = start + n
+	return string(d.data[start:d.pos]), nil
+}
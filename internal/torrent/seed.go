@@ -0,0 +1,321 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package torrent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 - info hashes are SHA-1 in BitTorrent v1
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	protocol = "BitTorrent protocol"
+	// handshakeSize is the length of the peer handshake.
+	handshakeSize = 1 + len(protocol) + 8 + sha1.Size + sha1.Size
+	// maxBlock is the largest block a peer may request, twice what clients
+	// ask for.
+	maxBlock = 32 << 10
+	// maxMessage bounds the messages read from peers, which only need to
+	// send short ones to a seeder.
+	maxMessage = 1 << 16
+	// maxPeers caps the connections served at once.
+	maxPeers = 50
+
+	handshakeTimeout = 30 * time.Second
+	idleTimeout      = 3 * time.Minute
+	// retryInterval is how long a failed announce waits to be tried again.
+	retryInterval = 5 * time.Minute
+	// minInterval bounds the announce interval a tracker may ask for.
+	minInterval = time.Minute
+)
+
+// Peer wire message IDs.
+const (
+	msgUnchoke    = 1
+	msgInterested = 2
+	msgBitfield   = 5
+	msgRequest    = 6
+	msgPiece      = 7
+)
+
+// Seeder uploads the content of torrents to the peers that ask for it and
+// announces them to their HTTP trackers. It never downloads.
+type Seeder struct {
+	client *http.Client
+	peerID [sha1.Size]byte
+
+	mu       sync.Mutex
+	torrents map[[sha1.Size]byte]*seed
+}
+
+type seed struct {
+	meta     *MetaInfo
+	content  *Content
+	uploaded atomic.Int64
+}
+
+// NewSeeder returns a seeder announcing with client.
+func NewSeeder(client *http.Client) *Seeder {
+	s := &Seeder{client: client, torrents: make(map[[sha1.Size]byte]*seed)}
+	copy(s.peerID[:], "-WA0001-")
+	_, _ = rand.Read(s.peerID[8:])
+	return s
+}
+
+// Add seeds the torrent m, whose content is in dir.
+func (s *Seeder) Add(m *MetaInfo, dir string) error {
+	content, err := m.Open(dir)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.torrents[m.InfoHash]; ok {
+		old.content.Close()
+	}
+	s.torrents[m.InfoHash] = &seed{meta: m, content: content}
+	return nil
+}
+
+// Serve accepts peers on listener and announces every torrent until ctx
+// ends, then closes listener and the content of the torrents.
+func (s *Seeder) Serve(ctx context.Context, listener net.Listener) error {
+	port := 0
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		port = addr.Port
+	}
+	var wg sync.WaitGroup
+	s.mu.Lock()
+	for _, sd := range s.torrents {
+		for _, tracker := range sd.meta.Trackers {
+			if !strings.HasPrefix(tracker, "http://") && !strings.HasPrefix(tracker, "https://") {
+				slog.Warn("Skipping tracker, only HTTP trackers are supported", "tracker", tracker, "torrent", sd.meta.Name)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.announceLoop(ctx, sd, tracker, port)
+			}()
+		}
+	}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	slots := make(chan struct{}, maxPeers)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			wg.Wait()
+			s.close()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept peer: %w", err)
+		}
+		select {
+		case slots <- struct{}{}:
+			go func() {
+				defer func() { <-slots }()
+				if err := s.handle(conn); err != nil && !errors.Is(err, io.EOF) {
+					slog.Debug("Peer connection ended", "peer", conn.RemoteAddr().String(), "error", err)
+				}
+			}()
+		default:
+			conn.Close()
+		}
+	}
+}
+
+func (s *Seeder) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sd := range s.torrents {
+		sd.content.Close()
+	}
+}
+
+// handle serves the pieces a peer requests after a handshake for a seeded
+// torrent.
+func (s *Seeder) handle(conn net.Conn) error {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	hs := make([]byte, handshakeSize)
+	if _, err := io.ReadFull(conn, hs); err != nil {
+		return err
+	}
+	if int(hs[0]) != len(protocol) || string(hs[1:1+len(protocol)]) != protocol {
+		return errors.New("not a BitTorrent handshake")
+	}
+	var hash [sha1.Size]byte
+	copy(hash[:], hs[1+len(protocol)+8:])
+	s.mu.Lock()
+	sd, ok := s.torrents[hash]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown info hash %x", hash)
+	}
+
+	w := bufio.NewWriter(conn)
+	w.WriteByte(byte(len(protocol)))
+	w.WriteString(protocol)
+	w.Write(make([]byte, 8))
+	w.Write(hash[:])
+	w.Write(s.peerID[:])
+	bitfield := make([]byte, (sd.meta.PieceCount()+7)/8)
+	for i := 0; i < sd.meta.PieceCount(); i++ {
+		bitfield[i/8] |= 0x80 >> (i % 8)
+	}
+	writeMessage(w, msgBitfield, bitfield)
+	// Everyone is unchoked, as a seeder has nothing to trade for
+	writeMessage(w, msgUnchoke, nil)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		_ = conn.SetDeadline(time.Now().Add(idleTimeout))
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return err
+		}
+		if length == 0 {
+			continue // keep-alive
+		}
+		if length > maxMessage {
+			return fmt.Errorf("message of %d bytes is too long", length)
+		}
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return err
+		}
+		switch msg[0] {
+		case msgInterested:
+			writeMessage(w, msgUnchoke, nil)
+		case msgRequest:
+			if len(msg) != 13 {
+				return errors.New("malformed request")
+			}
+			if err := s.sendBlock(w, sd, binary.BigEndian.Uint32(msg[1:]), binary.BigEndian.Uint32(msg[5:]), binary.BigEndian.Uint32(msg[9:])); err != nil {
+				return err
+			}
+		}
+		// Choke, have, cancel, and other messages need no answer from a
+		// seeder that sends what is asked right away
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// sendBlock writes the piece message of a requested block.
+func (s *Seeder) sendBlock(w *bufio.Writer, sd *seed, index, begin, length uint32) error {
+	pieceStart := int64(index) * sd.meta.PieceLength
+	pieceEnd := min(pieceStart+sd.meta.PieceLength, sd.meta.TotalLength())
+	if int(index) >= sd.meta.PieceCount() || length == 0 || length > maxBlock || pieceStart+int64(begin)+int64(length) > pieceEnd {
+		return fmt.Errorf("invalid request for piece %d at %d of %d bytes", index, begin, length)
+	}
+	block := make([]byte, 8+length)
+	binary.BigEndian.PutUint32(block, index)
+	binary.BigEndian.PutUint32(block[4:], begin)
+	if _, err := sd.content.ReadAt(block[8:], pieceStart+int64(begin)); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read piece %d: %w", index, err)
+	}
+	writeMessage(w, msgPiece, block)
+	sd.uploaded.Add(int64(length))
+	return nil
+}
+
+func writeMessage(w *bufio.Writer, id byte, payload []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(1+len(payload))) // #nosec G115 - payloads are at most a block
+	w.Write(length[:])
+	w.WriteByte(id)
+	w.Write(payload)
+}
+
+// announceLoop announces sd to tracker, as often as it asks, until ctx ends.
+func (s *Seeder) announceLoop(ctx context.Context, sd *seed, tracker string, port int) {
+	event := "started"
+	for {
+		interval, err := s.announce(ctx, sd, tracker, port, event)
+		if err != nil {
+			slog.Warn("Failed to announce torrent", "error", err, "tracker", tracker, "torrent", sd.meta.Name)
+			interval = retryInterval
+		} else {
+			slog.Debug("Announced torrent", "tracker", tracker, "torrent", sd.meta.Name, "interval", interval)
+			event = ""
+		}
+		select {
+		case <-ctx.Done():
+			stopCtx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+			_, _ = s.announce(stopCtx, sd, tracker, port, "stopped")
+			cancel()
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// announce reports sd to tracker as a complete copy and returns the
+// interval the tracker asks for.
+func (s *Seeder) announce(ctx context.Context, sd *seed, tracker string, port int, event string) (time.Duration, error) {
+	query := "info_hash=" + url.QueryEscape(string(sd.meta.InfoHash[:])) +
+		"&peer_id=" + url.QueryEscape(string(s.peerID[:])) +
+		"&port=" + strconv.Itoa(port) +
+		"&uploaded=" + strconv.FormatInt(sd.uploaded.Load(), 10) +
+		"&downloaded=0&left=0&compact=1"
+	if event != "" {
+		query += "&event=" + event
+	}
+	sep := "?"
+	if strings.Contains(tracker, "?") {
+		sep = "&"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tracker+sep+query, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("tracker answered %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessage))
+	if err != nil {
+		return 0, err
+	}
+	v, _, err := decode(bytes.TrimSpace(body))
+	if err != nil {
+		return 0, fmt.Errorf("invalid tracker response: %w", err)
+	}
+	dict, _ := v.(map[string]any)
+	if reason, ok := dict["failure reason"].(string); ok {
+		return 0, fmt.Errorf("tracker refused: %s", reason)
+	}
+	interval, _ := dict["interval"].(int64)
+	return max(time.Duration(interval)*time.Second, minInterval), nil
+}
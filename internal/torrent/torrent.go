@@ -0,0 +1,424 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package torrent creates BitTorrent metainfo files of finished archives,
+// with web seeds where they are published over HTTP, and seeds them with a
+// small built-in peer that only uploads.
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1" // #nosec G505 - SHA-1 is the piece and info hash mandated by BitTorrent v1
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Ext is the extension of metainfo files.
+const Ext = ".torrent"
+
+// Piece length bounds; the length doubles from the minimum until the
+// archive has at most targetPieces pieces.
+const (
+	minPieceLength = 256 << 10
+	maxPieceLength = 16 << 20
+	targetPieces   = 1500
+)
+
+// File is a file of a multi-file torrent.
+type File struct {
+	Path   []string
+	Length int64
+}
+
+// MetaInfo is a BitTorrent v1 metainfo file.
+type MetaInfo struct {
+	// Trackers are announce URLs, each its own tier.
+	Trackers []string
+	// WebSeeds are BEP 19 URLs the content is also served at.
+	WebSeeds     []string
+	Comment      string
+	CreatedBy    string
+	CreationDate time.Time
+
+	Name        string
+	PieceLength int64
+	// Pieces are the concatenated SHA-1 hashes of the pieces.
+	Pieces []byte
+	// Length is the size of a single-file torrent; Files are set instead
+	// for a directory.
+	Length int64
+	Files  []File
+
+	InfoHash [sha1.Size]byte
+}
+
+// Options describe the torrent Create makes.
+type Options struct {
+	Trackers  []string
+	WebSeeds  []string
+	Comment   string
+	CreatedBy string
+}
+
+// Create hashes the file or directory at path into a torrent named after it.
+func Create(path string, opts Options) (*MetaInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	m := &MetaInfo{
+		Trackers:     opts.Trackers,
+		WebSeeds:     opts.WebSeeds,
+		Comment:      opts.Comment,
+		CreatedBy:    opts.CreatedBy,
+		CreationDate: time.Now().UTC(),
+		Name:         filepath.Base(path),
+	}
+	paths := []string{path}
+	if info.IsDir() {
+		paths = nil
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(path, p)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, p)
+			m.Files = append(m.Files, File{Path: strings.Split(filepath.ToSlash(rel), "/"), Length: fi.Size()})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", path, err)
+		}
+		if len(m.Files) == 0 {
+			return nil, fmt.Errorf("%s has no files", path)
+		}
+	} else {
+		m.Length = info.Size()
+	}
+	m.PieceLength = pieceLength(m.TotalLength())
+
+	content, err := openFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	if m.Pieces, err = hashPieces(io.NewSectionReader(content, 0, content.size), m.PieceLength); err != nil {
+		return nil, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	if err := m.setInfoHash(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// pieceLength picks the piece length of an archive of total bytes.
+func pieceLength(total int64) int64 {
+	n := int64(minPieceLength)
+	for n < maxPieceLength && total/n > targetPieces {
+		n *= 2
+	}
+	return n
+}
+
+func hashPieces(r io.Reader, pieceLength int64) ([]byte, error) {
+	var pieces []byte
+	buf := make([]byte, pieceLength)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			sum := sha1.Sum(buf[:n]) // #nosec G401 - mandated by BitTorrent v1
+			pieces = append(pieces, sum[:]...)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return pieces, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// TotalLength returns the size of the content of the torrent.
+func (m *MetaInfo) TotalLength() int64 {
+	if m.Files == nil {
+		return m.Length
+	}
+	var total int64
+	for _, f := range m.Files {
+		total += f.Length
+	}
+	return total
+}
+
+// PieceCount returns the number of pieces of the torrent.
+func (m *MetaInfo) PieceCount() int {
+	return len(m.Pieces) / sha1.Size
+}
+
+// infoDict returns the info dictionary the info hash is computed over.
+func (m *MetaInfo) infoDict() map[string]any {
+	info := map[string]any{
+		"name":         m.Name,
+		"piece length": m.PieceLength,
+		"pieces":       m.Pieces,
+	}
+	if m.Files == nil {
+		info["length"] = m.Length
+	} else {
+		files := make([]any, 0, len(m.Files))
+		for _, f := range m.Files {
+			files = append(files, map[string]any{"length": f.Length, "path": f.Path})
+		}
+		info["files"] = files
+	}
+	return info
+}
+
+func (m *MetaInfo) setInfoHash() error {
+	var buf bytes.Buffer
+	if err := encode(&buf, m.infoDict()); err != nil {
+		return err
+	}
+	m.InfoHash = sha1.Sum(buf.Bytes()) // #nosec G401 - mandated by BitTorrent v1
+	return nil
+}
+
+// Magnet returns the magnet link of the torrent.
+func (m *MetaInfo) Magnet() string {
+	q := url.Values{"dn": {m.Name}}
+	if len(m.Trackers) > 0 {
+		q["tr"] = m.Trackers
+	}
+	if len(m.WebSeeds) > 0 {
+		q["ws"] = m.WebSeeds
+	}
+	return "magnet:?xt=urn:btih:" + hex.EncodeToString(m.InfoHash[:]) + "&" + q.Encode()
+}
+
+// Write saves the metainfo file at path.
+func (m *MetaInfo) Write(path string, perms os.FileMode) error {
+	top := map[string]any{
+		"info":          m.infoDict(),
+		"creation date": m.CreationDate.Unix(),
+	}
+	if len(m.Trackers) > 0 {
+		top["announce"] = m.Trackers[0]
+		tiers := make([]any, 0, len(m.Trackers))
+		for _, t := range m.Trackers {
+			tiers = append(tiers, []string{t})
+		}
+		top["announce-list"] = tiers
+	}
+	if len(m.WebSeeds) > 0 {
+		top["url-list"] = m.WebSeeds
+	}
+	if m.Comment != "" {
+		top["comment"] = m.Comment
+	}
+	if m.CreatedBy != "" {
+		top["created by"] = m.CreatedBy
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, top); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), perms); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load reads a metainfo file.
+func Load(path string) (*MetaInfo, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - path is a torrent chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	v, rawInfo, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent %s: %w", path, err)
+	}
+	top, _ := v.(map[string]any)
+	info, _ := top["info"].(map[string]any)
+	if info == nil {
+		return nil, fmt.Errorf("invalid torrent %s: no info dictionary", path)
+	}
+	m := &MetaInfo{InfoHash: sha1.Sum(rawInfo)} // #nosec G401 - mandated by BitTorrent v1
+	m.Name, _ = info["name"].(string)
+	m.PieceLength, _ = info["piece length"].(int64)
+	pieces, _ := info["pieces"].(string)
+	m.Pieces = []byte(pieces)
+	m.Length, _ = info["length"].(int64)
+	if files, ok := info["files"].([]any); ok {
+		for _, f := range files {
+			fd, _ := f.(map[string]any)
+			var file File
+			file.Length, _ = fd["length"].(int64)
+			parts, _ := fd["path"].([]any)
+			for _, p := range parts {
+				s, _ := p.(string)
+				file.Path = append(file.Path, s)
+			}
+			m.Files = append(m.Files, file)
+		}
+	}
+	if announce, ok := top["announce"].(string); ok {
+		m.Trackers = append(m.Trackers, announce)
+	}
+	if tiers, ok := top["announce-list"].([]any); ok {
+		for _, tier := range tiers {
+			list, _ := tier.([]any)
+			for _, t := range list {
+				if s, ok := t.(string); ok && !slices.Contains(m.Trackers, s) {
+					m.Trackers = append(m.Trackers, s)
+				}
+			}
+		}
+	}
+	switch seeds := top["url-list"].(type) {
+	case string:
+		m.WebSeeds = []string{seeds}
+	case []any:
+		for _, s := range seeds {
+			if s, ok := s.(string); ok {
+				m.WebSeeds = append(m.WebSeeds, s)
+			}
+		}
+	}
+	if !validName(m.Name) || m.PieceLength <= 0 || len(m.Pieces)%sha1.Size != 0 || len(m.Pieces) == 0 {
+		return nil, fmt.Errorf("invalid torrent %s: bad name, piece length, or pieces", path)
+	}
+	for _, f := range m.Files {
+		for _, p := range f.Path {
+			if !validName(p) {
+				return nil, fmt.Errorf("invalid torrent %s: bad file path %q", path, strings.Join(f.Path, "/"))
+			}
+		}
+	}
+	return m, nil
+}
+
+// validName rejects names that would leave the content directory.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// Open opens the content of the torrent in dir for reading. A single
+// file that was split into zimsplit-style parts (name.zimaa, name.zimab,
+// ...) after the torrent was made is read through its parts.
+func (m *MetaInfo) Open(dir string) (*Content, error) {
+	var paths []string
+	if m.Files == nil {
+		path := filepath.Join(dir, m.Name)
+		paths = []string{path}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if parts, _ := filepath.Glob(globEscape(path) + "[a-z][a-z]"); len(parts) > 0 {
+				sort.Strings(parts)
+				paths = parts
+			}
+		}
+	} else {
+		for _, f := range m.Files {
+			paths = append(paths, filepath.Join(append([]string{dir, m.Name}, f.Path...)...))
+		}
+	}
+	content, err := openFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	if content.size != m.TotalLength() {
+		content.Close()
+		return nil, fmt.Errorf("content of %s is %d bytes, the torrent has %d", m.Name, content.size, m.TotalLength())
+	}
+	return content, nil
+}
+
+func globEscape(path string) string {
+	return strings.NewReplacer("[", `\[`, "*", `\*`, "?", `\?`).Replace(path)
+}
+
+// Content reads a list of files as one, as the pieces of a torrent span
+// them.
+type Content struct {
+	files []*os.File
+	ends  []int64
+	size  int64
+}
+
+func openFiles(paths []string) (*Content, error) {
+	fl := &Content{}
+	for _, p := range paths {
+		f, err := os.Open(p) // #nosec G304 - p is content of an archive
+		if err != nil {
+			fl.Close()
+			return nil, fmt.Errorf("failed to open %s: %w", p, err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			fl.Close()
+			return nil, fmt.Errorf("failed to stat %s: %w", p, err)
+		}
+		fl.files = append(fl.files, f)
+		fl.size += info.Size()
+		fl.ends = append(fl.ends, fl.size)
+	}
+	return fl, nil
+}
+
+// ReadAt reads the concatenated files.
+func (fl *Content) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for read < len(p) {
+		pos := off + int64(read)
+		i := sort.Search(len(fl.ends), func(i int) bool { return fl.ends[i] > pos })
+		if i == len(fl.ends) {
+			return read, io.EOF
+		}
+		start := fl.ends[i] - fl.sizeOf(i)
+		n, err := fl.files[i].ReadAt(p[read:min(len(p), read+int(fl.ends[i]-pos))], pos-start)
+		read += n
+		if err != nil && !errors.Is(err, io.EOF) {
+			return read, err
+		}
+		if n == 0 {
+			return read, io.ErrUnexpectedEOF
+		}
+	}
+	return read, nil
+}
+
+func (fl *Content) sizeOf(i int) int64 {
+	if i == 0 {
+		return fl.ends[0]
+	}
+	return fl.ends[i] - fl.ends[i-1]
+}
+
+// Close closes the files.
+func (fl *Content) Close() error {
+	var firstErr error
+	for _, f := range fl.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package urlfilter limits the scope of a crawl with regular expressions
+// over the URLs it discovers, to keep it out of tag archives, search result
+// pages, calendars, and other URL spaces without end.
+package urlfilter
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Filter holds include and exclude patterns. The zero value allows every URL.
+type Filter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// New compiles the include and exclude patterns of a filter.
+func New(include, exclude []string) (Filter, error) {
+	var f Filter
+	for _, list := range []struct {
+		name     string
+		patterns []string
+		dst      *[]*regexp.Regexp
+	}{{"include", include, &f.include}, {"exclude", exclude, &f.exclude}} {
+		for _, pattern := range list.patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return Filter{}, fmt.Errorf("invalid %s pattern %q: %w", list.name, pattern, err)
+			}
+			*list.dst = append(*list.dst, re)
+		}
+	}
+	return f, nil
+}
+
+// Allow reports whether rawURL is crawled. A URL matching an exclude
+// pattern never is. Include patterns only apply to pages, as the images and
+// stylesheets of an included page are needed to display it; when there are
+// any, a page must match one. When a URL is not crawled, the reason names
+// the pattern it failed.
+func (f Filter) Allow(rawURL string, page bool) (bool, string) {
+	for _, re := range f.exclude {
+		if re.MatchString(rawURL) {
+			return false, "matches exclude pattern " + re.String()
+		}
+	}
+	if !page || len(f.include) == 0 {
+		return true, ""
+	}
+	for _, re := range f.include {
+		if re.MatchString(rawURL) {
+			return true, ""
+		}
+	}
+	return false, "matches no include pattern"
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package urlnorm spells URLs one way before they are queued, so a crawl
+// does not fetch the same page again under another spelling.
+package urlnorm
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Tracking lists the query parameters of analytics and ad campaigns, which
+// --strip-tracking drops.
+var Tracking = []string{"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid", "yclid", "mc_cid", "mc_eid", "_ga", "_gl", "igshid"}
+
+// defaultPorts are left out of the host of URLs with these schemes.
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// Normalizer rewrites URLs into their canonical spelling. The zero value
+// lower-cases the scheme and host and drops fragments and default ports.
+type Normalizer struct {
+	drop       []string
+	stripSlash bool
+	// hosts maps the hostnames of aliases to the host they are spelled as
+	hosts map[string]string
+}
+
+// New returns a normalizer that also drops the query parameters matching a
+// glob of drop, and those of Tracking with tracking. With stripSlash, paths
+// other than the root lose their trailing slash.
+func New(drop []string, tracking, stripSlash bool) (Normalizer, error) {
+	n := Normalizer{stripSlash: stripSlash}
+	if tracking {
+		n.drop = append(n.drop, Tracking...)
+	}
+	for _, glob := range drop {
+		glob = strings.ToLower(strings.TrimSpace(glob))
+		if _, err := path.Match(glob, ""); err != nil || glob == "" {
+			return Normalizer{}, fmt.Errorf("invalid query parameter pattern %q", glob)
+		}
+		n.drop = append(n.drop, glob)
+	}
+	return n, nil
+}
+
+// WithAliases returns the normalizer that also spells URLs on the hostnames
+// of aliases with host, so the names of one site share one namespace.
+func (n Normalizer) WithAliases(host string, aliases []string) Normalizer {
+	hosts := make(map[string]string, len(n.hosts)+len(aliases))
+	for alias, canonical := range n.hosts {
+		hosts[alias] = canonical
+	}
+	host = strings.ToLower(host)
+	for _, alias := range aliases {
+		if alias = strings.ToLower(strings.TrimSpace(alias)); alias != "" {
+			hosts[alias] = host
+		}
+	}
+	n.hosts = hosts
+	return n
+}
+
+// URL returns the canonical spelling of u, leaving u unchanged.
+func (n Normalizer) URL(u *url.URL) *url.URL {
+	v := *u
+	v.Scheme = strings.ToLower(v.Scheme)
+	v.Host = strings.ToLower(v.Host)
+	if port, ok := defaultPorts[v.Scheme]; ok {
+		v.Host = strings.TrimSuffix(v.Host, ":"+port)
+	}
+	if host, ok := n.hosts[v.Hostname()]; ok {
+		v.Host = host
+	}
+	v.Fragment, v.RawFragment = "", ""
+	if n.stripSlash && len(v.Path) > 1 && strings.HasSuffix(v.Path, "/") {
+		v.Path = strings.TrimSuffix(v.Path, "/")
+		v.RawPath = strings.TrimSuffix(v.RawPath, "/")
+	}
+	if len(n.drop) > 0 {
+		v.RawQuery = n.query(v.RawQuery)
+	}
+	// A bare "?" asks for the same resource as none
+	v.ForceQuery = false
+	return &v
+}
+
+// query drops the parameters to drop from a raw query, keeping the order
+// and spelling of the others.
+func (n Normalizer) query(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	var kept []string
+	for _, param := range strings.Split(raw, "&") {
+		if param == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !n.drops(strings.ToLower(name)) {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+func (n Normalizer) drops(name string) bool {
+	for _, glob := range n.drop {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package useragent sets the User-Agent of outgoing requests, either one
+// agent for all of them or a list used in turn, as many sites block the
+// default agents of Go and wget outright.
+package useragent
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Default is the User-Agent sent when none is configured. It carries the
+// product token robots.txt rules are matched against.
+const Default = "Mozilla/5.0 (compatible; website-archiver/1.0; +https://github.com/Sudo-Ivan/website-archiver)"
+
+// Rotation hands out its agents round-robin. It is safe for concurrent use.
+type Rotation struct {
+	agents []string
+	next   atomic.Uint64
+}
+
+// New returns a rotation over agents, which must not be empty.
+func New(agents ...string) *Rotation {
+	return &Rotation{agents: agents}
+}
+
+// Next returns the agent for the next request.
+func (r *Rotation) Next() string {
+	n := r.next.Add(1) - 1
+	return r.agents[n%uint64(len(r.agents))]
+}
+
+// Len returns the number of agents.
+func (r *Rotation) Len() int {
+	return len(r.agents)
+}
+
+// LoadFile reads a rotation file: one User-Agent per line, with blank lines
+// and lines starting with # left out.
+func LoadFile(path string) (*Rotation, error) {
+	f, err := os.Open(path) // #nosec G304 - path is a rotation file chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to open User-Agent file: %w", err)
+	}
+	defer f.Close()
+	var agents []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.ContainsAny(line, "\x00\x7f") {
+			return nil, fmt.Errorf("User-Agent file %s has a control character in %q", path, line)
+		}
+		agents = append(agents, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read User-Agent file %s: %w", path, err)
+	}
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("User-Agent file %s lists no agents", path)
+	}
+	return New(agents...), nil
+}
+
+// Transport sets the User-Agent of requests that do not have one yet.
+type Transport struct {
+	Next   http.RoundTripper
+	Agents *Rotation
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if req.Header.Get("User-Agent") != "" {
+		return next.RoundTrip(req)
+	}
+	// RoundTrippers must not modify the request they are given
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.Agents.Next())
+	return next.RoundTrip(req)
+}
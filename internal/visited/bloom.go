@@ -0,0 +1,104 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package visited
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+)
+
+// bloomMagic starts a serialized Bloom filter.
+const bloomMagic = "WABLOOM1"
+
+// Bloom is a Bloom filter over strings. It answers "definitely not seen" or
+// "maybe seen"; the rate of wrong "maybe" answers is set when it is created.
+type Bloom struct {
+	bits []uint64
+	m    uint64
+	k    uint32
+}
+
+// NewBloom returns a filter sized for n keys at false-positive rate p.
+func NewBloom(n int, p float64) (*Bloom, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("expected key count must be positive, got %d", n)
+	}
+	if p <= 0 || p >= 1 {
+		return nil, fmt.Errorf("false-positive rate must be between 0 and 1, got %g", p)
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	m = (m + 63) &^ 63
+	return &Bloom{bits: make([]uint64, m/64), m: m, k: k}, nil
+}
+
+// locations returns the two halves of the key hash used for double hashing.
+func locations(key string) (uint64, uint64) {
+	h := fnv.New128a()
+	_, _ = io.WriteString(h, key)
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+// Add records key.
+func (b *Bloom) Add(key string) {
+	h1, h2 := locations(key)
+	for i := uint64(0); i < uint64(b.k); i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Test reports whether key may have been added.
+func (b *Bloom) Test(key string) bool {
+	h1, h2 := locations(key)
+	for i := uint64(0); i < uint64(b.k); i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo serializes the filter.
+func (b *Bloom) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, len(bloomMagic)+12)
+	copy(header, bloomMagic)
+	binary.BigEndian.PutUint64(header[len(bloomMagic):], b.m)
+	binary.BigEndian.PutUint32(header[len(bloomMagic)+8:], b.k)
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), fmt.Errorf("failed to write Bloom filter: %w", err)
+	}
+	if err := binary.Write(w, binary.BigEndian, b.bits); err != nil {
+		return int64(n), fmt.Errorf("failed to write Bloom filter: %w", err)
+	}
+	return int64(n) + int64(len(b.bits))*8, nil
+}
+
+// ReadBloom reads a filter written by WriteTo.
+func ReadBloom(r io.Reader) (*Bloom, error) {
+	header := make([]byte, len(bloomMagic)+12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read Bloom filter: %w", err)
+	}
+	if string(header[:len(bloomMagic)]) != bloomMagic {
+		return nil, errors.New("not a Bloom filter file")
+	}
+	m := binary.BigEndian.Uint64(header[len(bloomMagic):])
+	k := binary.BigEndian.Uint32(header[len(bloomMagic)+8:])
+	if m == 0 || m%64 != 0 || k == 0 {
+		return nil, errors.New("corrupt Bloom filter header")
+	}
+	b := &Bloom{bits: make([]uint64, m/64), m: m, k: k}
+	if err := binary.Read(r, binary.BigEndian, b.bits); err != nil {
+		return nil, fmt.Errorf("failed to read Bloom filter: %w", err)
+	}
+	return b, nil
+}
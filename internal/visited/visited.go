@@ -0,0 +1,277 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package visited records which URLs a crawl has fetched and with how much
+// depth left. The in-memory set suits ordinary sites; the disk set keeps
+// memory flat for crawls of millions of URLs by answering most lookups from
+// a Bloom filter and only confirming possible hits against an exact index on
+// disk.
+package visited
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DirName is the directory of a disk set inside the metadata directory
+	// of a capture.
+	DirName = "visited"
+	// bloomFile holds the serialized Bloom filter of a disk set.
+	bloomFile = "bloom.bin"
+	// layoutFile holds the bucket count of a disk set.
+	layoutFile = "buckets"
+	// keysPerBucket is the average number of keys per index bucket the
+	// bucket count is chosen for.
+	keysPerBucket = 256
+	// minBuckets is the smallest number of index buckets.
+	minBuckets = 256
+)
+
+// Set remembers the deepest remaining depth each key was visited with.
+type Set interface {
+	// Mark reports whether key still needs a visit at depth, and records it
+	// if so. A key is visited again only with more depth than before.
+	Mark(key string, depth int) (bool, error)
+	// Close releases the set, persisting it if it lives on disk.
+	Close() error
+}
+
+// Memory is a Set held in a map.
+type Memory struct {
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+// NewMemory returns an empty in-memory set.
+func NewMemory() *Memory {
+	return &Memory{seen: make(map[string]int)}
+}
+
+// Mark implements Set.
+func (s *Memory) Mark(key string, depth int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if seen, ok := s.seen[key]; ok && seen >= depth {
+		return false, nil
+	}
+	s.seen[key] = depth
+	return true, nil
+}
+
+// Close implements Set.
+func (s *Memory) Close() error {
+	return nil
+}
+
+// Disk is a Set stored in a directory: a Bloom filter in memory, saved on
+// Close, and an exact index of append-only bucket files holding one
+// "depth<TAB>key" line per visit.
+type Disk struct {
+	mu      sync.Mutex
+	dir     string
+	perms   os.FileMode
+	bloom   *Bloom
+	buckets int
+}
+
+// OpenDisk opens or creates the disk set in dir, sized for expected keys
+// at the false-positive rate fpRate. An existing set keeps its own sizing.
+func OpenDisk(dir string, expected int, fpRate float64, dirPerms, filePerms os.FileMode) (*Disk, error) {
+	if err := os.MkdirAll(dir, dirPerms); err != nil {
+		return nil, fmt.Errorf("failed to create visited set directory: %w", err)
+	}
+	s := &Disk{dir: dir, perms: filePerms}
+
+	buckets, err := s.existingBuckets()
+	if err != nil {
+		return nil, err
+	}
+	if buckets == 0 {
+		buckets = max(expected/keysPerBucket, minBuckets)
+	}
+	s.buckets = buckets
+
+	f, err := os.Open(filepath.Join(dir, bloomFile)) // #nosec G304 - dir is inside the output directory
+	switch {
+	case err == nil:
+		s.bloom, err = ReadBloom(bufio.NewReader(f))
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+		// The saved filter goes stale as soon as keys are added, so only a
+		// clean Close may leave one behind
+		if err := os.Remove(filepath.Join(dir, bloomFile)); err != nil {
+			return nil, fmt.Errorf("failed to remove saved Bloom filter: %w", err)
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		if s.bloom, err = NewBloom(expected, fpRate); err != nil {
+			return nil, err
+		}
+		// A set that was not closed cleanly has an index but no filter
+		if err := s.rebuildBloom(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to open Bloom filter: %w", err)
+	}
+	return s, nil
+}
+
+// existingBuckets returns the bucket count recorded by an earlier run, or 0.
+func (s *Disk) existingBuckets() (int, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, layoutFile)) // #nosec G304 - dir is inside the output directory
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read visited set layout: %w", err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("corrupt visited set layout %q", data)
+	}
+	return n, nil
+}
+
+// rebuildBloom adds every indexed key to the filter.
+func (s *Disk) rebuildBloom() error {
+	for i := 0; i < s.buckets; i++ {
+		err := s.scan(i, func(key string, _ int) { s.bloom.Add(key) })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bucket returns the index bucket of key.
+func (s *Disk) bucket(key string) int {
+	h := fnv.New32a()
+	_, _ = io.WriteString(h, key)
+	return int(h.Sum32() % uint32(s.buckets)) // #nosec G115 - buckets is positive
+}
+
+func (s *Disk) bucketPath(i int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%06d", i))
+}
+
+// scan calls fn for every visit recorded in bucket i.
+func (s *Disk) scan(i int, fn func(key string, depth int)) error {
+	f, err := os.Open(s.bucketPath(i)) // #nosec G304 - bucket files live in the set directory
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open visited index: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		depthText, key, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		depth, err := strconv.Atoi(depthText)
+		if err != nil {
+			continue
+		}
+		fn(key, depth)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read visited index: %w", err)
+	}
+	return nil
+}
+
+// Mark implements Set. Keys the filter has never seen are new without a
+// disk read; possible hits are looked up in the key's index bucket.
+func (s *Disk) Mark(key string, depth int) (bool, error) {
+	if strings.ContainsAny(key, "\n") {
+		return false, fmt.Errorf("key %q contains a newline", key)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.bucket(key)
+	if s.bloom.Test(key) {
+		seen := -1
+		found := false
+		err := s.scan(i, func(k string, d int) {
+			if k == key {
+				found = true
+				seen = max(seen, d)
+			}
+		})
+		if err != nil {
+			return false, err
+		}
+		if found && seen >= depth {
+			return false, nil
+		}
+	}
+
+	if err := s.writeLayout(); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(s.bucketPath(i), os.O_CREATE|os.O_WRONLY|os.O_APPEND, s.perms)
+	if err != nil {
+		return false, fmt.Errorf("failed to open visited index: %w", err)
+	}
+	_, err = fmt.Fprintf(f, "%d\t%s\n", depth, key)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record visit: %w", err)
+	}
+	s.bloom.Add(key)
+	return true, nil
+}
+
+// writeLayout records the bucket count so a later run hashes keys the same way.
+func (s *Disk) writeLayout() error {
+	path := filepath.Join(s.dir, layoutFile)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(s.buckets)+"\n"), s.perms); err != nil {
+		return fmt.Errorf("failed to write visited set layout: %w", err)
+	}
+	return nil
+}
+
+// Close implements Set by saving the Bloom filter.
+func (s *Disk) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(filepath.Join(s.dir, bloomFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, s.perms)
+	if err != nil {
+		return fmt.Errorf("failed to save Bloom filter: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	if _, err := s.bloom.WriteTo(w); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to save Bloom filter: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to save Bloom filter: %w", err)
+	}
+	return nil
+}
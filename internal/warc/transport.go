@@ -0,0 +1,95 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package warc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Transport records the exchanges it makes into WARC. A response is written
+// once its body is closed, with as much of the body as was read.
+type Transport struct {
+	Next http.RoundTripper
+	WARC *Writer
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	var reqBody []byte
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		reqBody, err = io.ReadAll(body)
+		_ = body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+	sent := time.Now()
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &recordedBody{
+		ReadCloser: resp.Body,
+		warc:       t.WARC,
+		sent:       sent,
+		req:        req,
+		reqBody:    reqBody,
+		resp:       resp,
+		complete:   req.Method == http.MethodHead || resp.ContentLength == 0,
+	}
+	return resp, nil
+}
+
+// recordedBody keeps what is read of a response body and writes the
+// exchange when it is closed.
+type recordedBody struct {
+	io.ReadCloser
+	warc     *Writer
+	sent     time.Time
+	req      *http.Request
+	reqBody  []byte
+	resp     *http.Response
+	buf      bytes.Buffer
+	complete bool
+	once     sync.Once
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.complete = true
+	}
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		var extra []Field
+		if !b.complete {
+			// The reader stopped early, e.g. on a not modified or failed
+			// response, so the payload is cut off
+			extra = append(extra, Field{"WARC-Truncated", "unspecified"})
+		}
+		if werr := b.warc.writeExchange(b.sent, b.req, b.reqBody, b.resp, b.buf.Bytes(), extra); werr != nil {
+			slog.Warn("Failed to record exchange in WARC file", "url", b.req.URL.String(), "error", werr)
+		}
+	})
+	return err
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package warc writes WARC 1.1 files. Files ending in .gz are written with one
+// gzip member per record, as most WARC tools expect.
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 - SHA-1 is the digest WARC tools expect, not used for security
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the WARC format version written.
+const Version = "WARC/1.1"
+
+// Record types.
+const (
+	TypeWarcinfo = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+)
+
+// Field is a named WARC header field. Fields keep their order when written.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Writer appends records to a WARC file. It is safe for concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	file *os.File
+	gzip bool
+}
+
+// Create creates the WARC file at path, compressed when path ends in .gz.
+func Create(path string, perms os.FileMode) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perms) // #nosec G304 - path is the output file chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WARC file %s: %w", path, err)
+	}
+	return &Writer{file: file, gzip: strings.HasSuffix(path, ".gz")}, nil
+}
+
+// Append opens the WARC file at path to add records to its end, creating it
+// if needed. Gzip members concatenate, so compressed files stay valid.
+func Append(path string, perms os.FileMode) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, perms) // #nosec G304 - path is the output file chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WARC file %s: %w", path, err)
+	}
+	return &Writer{file: file, gzip: strings.HasSuffix(path, ".gz")}, nil
+}
+
+// Close closes the file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// NewRecordID returns a new urn:uuid record identifier.
+func NewRecordID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Digest returns the sha1 digest of data in the form WARC tools expect.
+func Digest(data []byte) string {
+	sum := sha1.Sum(data) // #nosec G401 - SHA-1 is the digest WARC tools expect
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// WriteRecord writes a record of the given type with block as its content.
+// WARC-Record-ID, WARC-Date, and Content-Length are added unless present.
+func (w *Writer) WriteRecord(recordType string, fields []Field, contentType string, block []byte) error {
+	var head bytes.Buffer
+	head.WriteString(Version + "\r\n")
+	fmt.Fprintf(&head, "WARC-Type: %s\r\n", recordType)
+	has := func(name string) bool {
+		for _, f := range fields {
+			if strings.EqualFold(f.Name, name) {
+				return true
+			}
+		}
+		return false
+	}
+	if !has("WARC-Record-ID") {
+		fmt.Fprintf(&head, "WARC-Record-ID: %s\r\n", NewRecordID())
+	}
+	if !has("WARC-Date") {
+		fmt.Fprintf(&head, "WARC-Date: %s\r\n", time.Now().UTC().Format(time.RFC3339))
+	}
+	for _, f := range fields {
+		fmt.Fprintf(&head, "%s: %s\r\n", f.Name, f.Value)
+	}
+	if contentType != "" {
+		fmt.Fprintf(&head, "Content-Type: %s\r\n", contentType)
+	}
+	fmt.Fprintf(&head, "WARC-Block-Digest: %s\r\n", Digest(block))
+	fmt.Fprintf(&head, "Content-Length: %d\r\n\r\n", len(block))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out io.Writer = w.file
+	var gz *gzip.Writer
+	if w.gzip {
+		gz = gzip.NewWriter(w.file)
+		out = gz
+	}
+	for _, part := range [][]byte{head.Bytes(), block, []byte("\r\n\r\n")} {
+		if _, err := out.Write(part); err != nil {
+			return fmt.Errorf("failed to write WARC record: %w", err)
+		}
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to write WARC record: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteInfo writes a warcinfo record describing the software that made the file.
+func (w *Writer) WriteInfo(software string) error {
+	block := fmt.Sprintf("software: %s\r\nformat: WARC File Format 1.1\r\n", software)
+	return w.WriteRecord(TypeWarcinfo, nil, "application/warc-fields", []byte(block))
+}
+
+// WriteExchange writes a request record and the response record for it. The
+// bodies are passed separately since the originals have been consumed.
+func (w *Writer) WriteExchange(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) error {
+	return w.writeExchange(time.Now(), req, reqBody, resp, respBody, nil)
+}
+
+// writeExchange writes the exchange with date, the time the request was
+// sent, and extra fields on the response record.
+func (w *Writer) writeExchange(at time.Time, req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, extra []Field) error {
+	target := req.URL.String()
+	date := at.UTC().Format(time.RFC3339)
+	responseID := NewRecordID()
+
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	if err := resp.Header.Write(&head); err != nil {
+		return fmt.Errorf("failed to encode response headers: %w", err)
+	}
+	head.WriteString("\r\n")
+	fields := []Field{
+		{"WARC-Record-ID", responseID},
+		{"WARC-Date", date},
+		{"WARC-Target-URI", target},
+		{"WARC-Payload-Digest", Digest(respBody)},
+	}
+	fields = append(fields, extra...)
+	if err := w.WriteRecord(TypeResponse, fields, "application/http;msgtype=response", append(head.Bytes(), respBody...)); err != nil {
+		return err
+	}
+
+	head.Reset()
+	fmt.Fprintf(&head, "%s %s HTTP/1.1\r\nHost: %s\r\n", req.Method, req.URL.RequestURI(), req.URL.Host)
+	if err := req.Header.Write(&head); err != nil {
+		return fmt.Errorf("failed to encode request headers: %w", err)
+	}
+	head.WriteString("\r\n")
+	fields = []Field{
+		{"WARC-Date", date},
+		{"WARC-Target-URI", target},
+		{"WARC-Concurrent-To", responseID},
+	}
+	return w.WriteRecord(TypeRequest, fields, "application/http;msgtype=request", append(head.Bytes(), reqBody...))
+}
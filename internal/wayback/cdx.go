@@ -0,0 +1,24 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package wayback
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ParseCDX decodes a CDX API response in JSON output: rows of string
+// fields, the first of them naming the fields. An empty body, which the API
+// sends when nothing matches, yields no rows.
+func ParseCDX(body []byte) ([][]string, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+	var rows [][]string
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("failed to parse CDX response: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+//go:build gofuzz
+
+package wayback
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FuzzCDX checks that CDX responses either fail to parse or yield rows that
+// encode back to an equal response.
+func FuzzCDX(data []byte) int {
+	rows, err := ParseCDX(data)
+	if err != nil || rows == nil {
+		return 0
+	}
+	encoded, err := json.Marshal(rows)
+	if err != nil {
+		panic(fmt.Sprintf("CDX rows of %q do not encode: %v", data, err))
+	}
+	again, err := ParseCDX(encoded)
+	if err != nil {
+		panic(fmt.Sprintf("encoded CDX rows %q do not parse: %v", encoded, err))
+	}
+	if len(again) != len(rows) {
+		panic(fmt.Sprintf("CDX rows of %q changed from %d to %d", data, len(rows), len(again)))
+	}
+	return 1
+}
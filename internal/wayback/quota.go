@@ -0,0 +1,87 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package wayback
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Host is the host of the Wayback Machine and its CDX API.
+const Host = "web.archive.org"
+
+// ErrQuota is returned for Wayback Machine requests once the quota of the run
+// is used up.
+var ErrQuota = errors.New("Wayback Machine quota of this run used up")
+
+// Quota is an http.RoundTripper that caps the requests sent to and the bytes
+// downloaded from the Wayback Machine in a run. The caps are soft: requests
+// already in flight finish, and only later ones fail with ErrQuota. Other
+// hosts are not counted.
+type Quota struct {
+	// Next is the wrapped transport; nil means http.DefaultTransport.
+	Next http.RoundTripper
+	// MaxBytes and MaxRequests are the caps; 0 means unlimited.
+	MaxBytes    int64
+	MaxRequests int
+
+	mu       sync.Mutex
+	bytes    int64
+	requests int
+}
+
+// RoundTrip implements http.RoundTripper.
+func (q *Quota) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := q.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if !strings.EqualFold(req.URL.Hostname(), Host) {
+		return next.RoundTrip(req)
+	}
+
+	q.mu.Lock()
+	if q.exceeded() {
+		q.mu.Unlock()
+		return nil, ErrQuota
+	}
+	q.requests++
+	q.mu.Unlock()
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	resp.Body = &quotaBody{ReadCloser: resp.Body, quota: q}
+	return resp, nil
+}
+
+// Exceeded reports whether the quota is used up.
+func (q *Quota) Exceeded() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.exceeded()
+}
+
+func (q *Quota) exceeded() bool {
+	return (q.MaxRequests > 0 && q.requests >= q.MaxRequests) || (q.MaxBytes > 0 && q.bytes >= q.MaxBytes)
+}
+
+type quotaBody struct {
+	io.ReadCloser
+	quota *Quota
+}
+
+func (b *quotaBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.quota.mu.Lock()
+		b.quota.bytes += int64(n)
+		b.quota.mu.Unlock()
+	}
+	return n, err
+}
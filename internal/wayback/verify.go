@@ -0,0 +1,30 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package wayback
+
+import (
+	"crypto/sha1" // #nosec G505 - the CDX API reports SHA-1 payload digests
+	"encoding/base32"
+)
+
+// Capture is a capture as listed by the CDX API.
+type Capture struct {
+	Timestamp string
+	Original  string
+	// Digest is the base32 SHA-1 digest of the archived payload.
+	Digest string
+}
+
+// Match reports whether payload is the archived payload of the capture, the
+// digest of which the CDX API lists. A mismatch usually means the replay was
+// truncated.
+func (c Capture) Match(payload []byte) bool {
+	return PayloadDigest(payload) == c.Digest
+}
+
+// PayloadDigest returns the digest of payload as the CDX API lists it.
+func PayloadDigest(payload []byte) string {
+	sum := sha1.Sum(payload) // #nosec G401 - matching the digest the CDX API reports
+	return base32.StdEncoding.EncodeToString(sum[:])
+}
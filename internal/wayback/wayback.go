@@ -0,0 +1,114 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package wayback classifies the ways the Wayback Machine refuses to serve a
+// capture, so failures can name the actual cause and point users at other
+// web archives instead of reporting a generic failed download.
+package wayback
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/catalog"
+)
+
+// bodyLimit caps the part of an error page read for classification.
+const bodyLimit = 64 << 10
+
+// runtimeErrorHeader carries the Java exception behind a Wayback error page.
+const runtimeErrorHeader = "X-Archive-Wayback-Runtime-Error"
+
+// Reasons the Wayback Machine refuses a capture. Each is matched by errors.Is
+// against the *Error describing the refusal.
+var (
+	// ErrExcluded means the site owner had the site excluded from the archive.
+	ErrExcluded = errors.New("excluded from the Wayback Machine by the site owner")
+	// ErrRobots means the site's robots.txt blocks playback.
+	ErrRobots = errors.New("blocked by the site's robots.txt")
+	// ErrForbidden means the Internet Archive denied access for another reason.
+	ErrForbidden = errors.New("access denied by the Internet Archive")
+	// ErrReplay means the capture exists but the Wayback Machine failed to replay it.
+	ErrReplay = errors.New("capture exists but the Wayback Machine failed to replay it")
+)
+
+// Error is a classified Wayback Machine failure.
+type Error struct {
+	// URL is the Wayback URL that failed.
+	URL string
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Reason is one of ErrExcluded, ErrRobots, ErrForbidden, or ErrReplay.
+	Reason error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("failed to fetch %s: %v (status code %d); %s", e.URL, e.Reason, e.StatusCode, e.Hint())
+}
+
+// Unwrap returns the reason, so errors.Is(err, ErrExcluded) works.
+func (e *Error) Unwrap() error {
+	return e.Reason
+}
+
+// Hint suggests where else to look for a copy of the page.
+func (e *Error) Hint() string {
+	original := e.URL
+	if u, _, ok := catalog.OriginalURL(e.URL); ok {
+		original = u
+	}
+	if errors.Is(e.Reason, ErrReplay) {
+		return "try again later, pick another snapshot with --snapshot, or look for the page on archive.today (https://archive.ph/newest/" + original + ")"
+	}
+	return "look for the page on archive.today (https://archive.ph/newest/" + original + ") or another web archive"
+}
+
+// excludedMarkers and robotsMarkers identify exclusion and robots.txt blocks,
+// in the runtime error header or the text of the error page.
+var (
+	excludedMarkers = []string{"AdministrativeAccessControlException", "Blocked Site Error", "has been excluded from the Wayback Machine"}
+	robotsMarkers   = []string{"RobotAccessControlException", "Blocked By Robots", "robots.txt"}
+)
+
+// Classify returns the failure a non-OK Wayback Machine response stands for,
+// or nil when it is not one of the known refusals, such as a 404 for a URL
+// that was never captured. It reads at most a small prefix of the body.
+func Classify(u string, resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, bodyLimit))
+	return ClassifyBody(u, resp.StatusCode, resp.Header, body)
+}
+
+// ClassifyBody is Classify for a response body that has already been read.
+func ClassifyBody(u string, status int, header http.Header, body []byte) *Error {
+	if status == http.StatusOK {
+		return nil
+	}
+	evidence := header.Get(runtimeErrorHeader) + "\n" + string(bytes.ToValidUTF8(body, nil))
+	e := &Error{URL: u, StatusCode: status}
+	switch {
+	case containsAny(evidence, excludedMarkers):
+		e.Reason = ErrExcluded
+	case containsAny(evidence, robotsMarkers) && status < http.StatusInternalServerError:
+		e.Reason = ErrRobots
+	case status == http.StatusForbidden:
+		e.Reason = ErrForbidden
+	case status >= http.StatusInternalServerError:
+		e.Reason = ErrReplay
+	default:
+		return nil
+	}
+	return e
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, m := range markers {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
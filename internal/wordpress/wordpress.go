@@ -0,0 +1,262 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package wordpress pulls structured content from the WordPress REST API
+// (wp-json) of a site so posts, pages, media, and their taxonomy survive in an
+// archive alongside the rendered HTML.
+package wordpress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+const (
+	// APIRel is the link relation WordPress uses to advertise its REST API root.
+	APIRel = "https://api.w.org/"
+	// DefaultAPIPath is where the REST API lives on a default installation.
+	DefaultAPIPath = "/wp-json/"
+	// perPage is the largest page size the REST API accepts.
+	perPage = 100
+	// maxPages bounds pagination in case a site misreports X-WP-TotalPages.
+	maxPages = 1000
+	// totalPagesHeader carries the number of result pages for a collection.
+	totalPagesHeader = "X-WP-TotalPages"
+	// outputSubdir is where raw API responses are stored inside the archive.
+	outputSubdir = "wp-json"
+)
+
+type rendered struct {
+	Rendered string `json:"rendered"`
+}
+
+type apiItem struct {
+	ID         int      `json:"id"`
+	Type       string   `json:"type"`
+	Slug       string   `json:"slug"`
+	Link       string   `json:"link"`
+	Date       string   `json:"date_gmt"`
+	Modified   string   `json:"modified_gmt"`
+	Title      rendered `json:"title"`
+	Categories []int    `json:"categories"`
+	Tags       []int    `json:"tags"`
+	SourceURL  string   `json:"source_url"`
+	MimeType   string   `json:"mime_type"`
+}
+
+type apiIndex struct {
+	Namespaces []string `json:"namespaces"`
+}
+
+// APIRootFromLinkHeader extracts the REST API root from a Link response header.
+func APIRootFromLinkHeader(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		if !strings.Contains(part, `rel="`+APIRel+`"`) {
+			continue
+		}
+		start := strings.Index(part, "<")
+		end := strings.Index(part, ">")
+		if start != -1 && end > start {
+			return part[start+1 : end]
+		}
+	}
+	return ""
+}
+
+// Probe checks whether apiRoot serves a WordPress REST API index.
+func Probe(ctx context.Context, client *http.Client, apiRoot string) bool {
+	body, _, err := get(ctx, client, apiRoot)
+	if err != nil {
+		return false
+	}
+	var index apiIndex
+	if err := json.Unmarshal(body, &index); err != nil {
+		return false
+	}
+	for _, ns := range index.Namespaces {
+		if ns == "wp/v2" {
+			return true
+		}
+	}
+	return false
+}
+
+// Capture downloads posts, pages, media, categories, and tags from apiRoot.
+// Raw API responses are stored below outputDir/wp-json and the structured
+// content is returned for the manifest.
+func Capture(ctx context.Context, client *http.Client, apiRoot, outputDir string, cfg *config.Config) (*manifest.WordPress, error) {
+	if !strings.HasSuffix(apiRoot, "/") {
+		apiRoot += "/"
+	}
+	c := &capturer{client: client, apiRoot: apiRoot, outputDir: filepath.Join(outputDir, outputSubdir), cfg: cfg}
+
+	categories, err := c.terms(ctx, "categories")
+	if err != nil {
+		return nil, err
+	}
+	tags, err := c.terms(ctx, "tags")
+	if err != nil {
+		return nil, err
+	}
+
+	wp := &manifest.WordPress{APIRoot: apiRoot, Categories: categories, Tags: tags}
+	names := func(terms []manifest.WordPressTerm) map[int]string {
+		m := make(map[int]string, len(terms))
+		for _, t := range terms {
+			m[t.ID] = t.Name
+		}
+		return m
+	}
+	categoryNames, tagNames := names(categories), names(tags)
+
+	for _, collection := range []struct {
+		name string
+		dst  *[]manifest.WordPressItem
+	}{
+		{"posts", &wp.Posts},
+		{"pages", &wp.Pages},
+		{"media", &wp.Media},
+	} {
+		items, err := c.items(ctx, collection.name, categoryNames, tagNames)
+		if err != nil {
+			return nil, err
+		}
+		*collection.dst = items
+	}
+	return wp, nil
+}
+
+type capturer struct {
+	client    *http.Client
+	apiRoot   string
+	outputDir string
+	cfg       *config.Config
+}
+
+// fetchAll walks every page of a wp/v2 collection, saving each raw page.
+func (c *capturer) fetchAll(ctx context.Context, collection string) ([]json.RawMessage, error) {
+	var all []json.RawMessage
+	for page := 1; page <= maxPages; page++ {
+		endpoint := fmt.Sprintf("%swp/v2/%s?per_page=%d&page=%d", c.apiRoot, collection, perPage, page)
+		body, header, err := get(ctx, c.client, endpoint)
+		if err != nil {
+			if page > 1 {
+				// WordPress answers 400 once the last page has been passed.
+				break
+			}
+			return nil, err
+		}
+
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, fmt.Errorf("failed to parse %s page %d: %w", collection, page, err)
+		}
+		if err := c.save(collection, page, body); err != nil {
+			return nil, err
+		}
+		all = append(all, batch...)
+
+		totalPages, err := strconv.Atoi(header.Get(totalPagesHeader))
+		if len(batch) < perPage || (err == nil && page >= totalPages) {
+			break
+		}
+	}
+	return all, nil
+}
+
+func (c *capturer) save(collection string, page int, body []byte) error {
+	dir := filepath.Join(c.outputDir, "wp", "v2", collection)
+	if err := os.MkdirAll(dir, c.cfg.DirPerms); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("page-%d.json", page))
+	if err := os.WriteFile(path, body, c.cfg.FilePerms); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *capturer) terms(ctx context.Context, collection string) ([]manifest.WordPressTerm, error) {
+	raw, err := c.fetchAll(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	terms := make([]manifest.WordPressTerm, 0, len(raw))
+	for _, r := range raw {
+		var t manifest.WordPressTerm
+		if err := json.Unmarshal(r, &t); err != nil {
+			return nil, fmt.Errorf("failed to parse %s entry: %w", collection, err)
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+func (c *capturer) items(ctx context.Context, collection string, categoryNames, tagNames map[int]string) ([]manifest.WordPressItem, error) {
+	raw, err := c.fetchAll(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]manifest.WordPressItem, 0, len(raw))
+	for _, r := range raw {
+		var a apiItem
+		if err := json.Unmarshal(r, &a); err != nil {
+			return nil, fmt.Errorf("failed to parse %s entry: %w", collection, err)
+		}
+		items = append(items, manifest.WordPressItem{
+			ID:         a.ID,
+			Type:       a.Type,
+			Slug:       a.Slug,
+			Title:      a.Title.Rendered,
+			Link:       a.Link,
+			Published:  a.Date,
+			Modified:   a.Modified,
+			Categories: lookup(a.Categories, categoryNames),
+			Tags:       lookup(a.Tags, tagNames),
+			SourceURL:  a.SourceURL,
+			MimeType:   a.MimeType,
+		})
+	}
+	return items, nil
+}
+
+func lookup(ids []int, names map[int]string) []string {
+	var out []string
+	for _, id := range ids {
+		if name, ok := names[id]; ok {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func get(ctx context.Context, client *http.Client, rawURL string) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request for %s: %w", rawURL, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to fetch %s: status code %d", rawURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body for %s: %w", rawURL, err)
+	}
+	return body, resp.Header, nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package xmldoc finds and rewrites the stylesheet references of XML documents:
+// xml-stylesheet processing instructions, as used by RSS feeds and older sites
+// rendered via XSLT, and the imports and includes of XSLT stylesheets.
+package xmldoc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// XSLTNamespace is the namespace of XSLT elements.
+const XSLTNamespace = "http://www.w3.org/1999/XSL/Transform"
+
+// stylesheetTarget is the processing instruction linking a stylesheet.
+const stylesheetTarget = "xml-stylesheet"
+
+// hrefAttr matches an href attribute or pseudo-attribute and captures its value.
+var hrefAttr = regexp.MustCompile(`\bhref\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// Ref is a stylesheet reference inside a document.
+type Ref struct {
+	// Href is the reference as written in the document.
+	Href string
+	// Start and End are the byte offsets of the value of Href.
+	Start, End int
+}
+
+// IsXML reports whether a content type denotes an XML document or stylesheet.
+func IsXML(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	return strings.Contains(ct, "xml") || strings.Contains(ct, "xsl")
+}
+
+// Refs returns the stylesheet references of an XML document in document order.
+func Refs(data []byte) ([]Ref, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	// Offsets are byte based and references are ASCII in practice, so legacy
+	// encodings are read as they are
+	d.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	var refs []Ref
+	for {
+		start := int(d.InputOffset())
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return refs, fmt.Errorf("failed to parse XML: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.ProcInst:
+			if t.Target != stylesheetTarget {
+				continue
+			}
+		case xml.StartElement:
+			if t.Name.Space != XSLTNamespace || (t.Name.Local != "import" && t.Name.Local != "include") {
+				continue
+			}
+		default:
+			continue
+		}
+		end := int(d.InputOffset())
+		if ref, ok := hrefIn(data, start, end); ok {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// hrefIn finds the href attribute within data[start:end].
+func hrefIn(data []byte, start, end int) (Ref, bool) {
+	m := hrefAttr.FindSubmatchIndex(data[start:end])
+	if m == nil {
+		return Ref{}, false
+	}
+	for _, group := range []int{2, 4} {
+		if m[group] >= 0 {
+			return Ref{
+				Href:  string(data[start+m[group] : start+m[group+1]]),
+				Start: start + m[group],
+				End:   start + m[group+1],
+			}, true
+		}
+	}
+	return Ref{}, false
+}
+
+// valueEscaper escapes text for use inside a quoted attribute value.
+var valueEscaper = strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `"`, "&quot;", `'`, "&apos;")
+
+// Rewrite replaces the references in data found in mapping, original value to
+// new value, leaving every other byte of the document untouched.
+func Rewrite(data []byte, mapping map[string]string) ([]byte, error) {
+	refs, err := Refs(data)
+	if err != nil && len(refs) == 0 {
+		return nil, err
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Start > refs[j].Start })
+
+	out := append([]byte(nil), data...)
+	for _, ref := range refs {
+		local, ok := mapping[ref.Href]
+		if !ok {
+			continue
+		}
+		out = append(out[:ref.Start], append([]byte(valueEscaper.Replace(local)), out[ref.End:]...)...)
+	}
+	return out, nil
+}
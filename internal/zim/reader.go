@@ -0,0 +1,493 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package zim
+
+import (
+	"bytes"
+	"crypto/md5" // #nosec G501 - MD5 is the checksum mandated by the ZIM format
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	// Magic is the magic number at the start of every ZIM file.
+	Magic = 72173914
+	// headerSize is the size of the fixed ZIM header.
+	headerSize = 80
+	// redirectMimeType marks a directory entry as a redirect.
+	redirectMimeType = 0xffff
+	// checksumSize is the length of the trailing MD5 checksum.
+	checksumSize = md5.Size
+	// noMainPage is the header value used when no main page is set.
+	noMainPage = 0xffffffff
+	// maxStringLength bounds the strings read from directory entries.
+	maxStringLength = 4096
+
+	// Cluster compression types.
+	compressionNone   = 1
+	compressionXZ     = 4
+	compressionZstd   = 5
+	compressionMask   = 0x0f
+	extendedOffsetBit = 0x10
+
+	// NamespaceContent holds user content in ZIM files of minor version 1 and later.
+	NamespaceContent = 'C'
+	// NamespaceMetadata holds metadata such as Title and Illustration_48x48@1.
+	NamespaceMetadata = 'M'
+	// NamespaceWellKnown holds entries such as mainPage.
+	NamespaceWellKnown = 'W'
+	// NamespaceArticles holds articles in ZIM files of minor version 0.
+	NamespaceArticles = 'A'
+)
+
+// Header is the fixed-size header of a ZIM file.
+type Header struct {
+	MagicNumber   uint32
+	MajorVersion  uint16
+	MinorVersion  uint16
+	UUID          [16]byte
+	EntryCount    uint32
+	ClusterCount  uint32
+	PathPtrPos    uint64
+	TitlePtrPos   uint64
+	ClusterPtrPos uint64
+	MimeListPos   uint64
+	MainPage      uint32
+	LayoutPage    uint32
+	ChecksumPos   uint64
+}
+
+// Entry is a directory entry of a ZIM file.
+type Entry struct {
+	Index     uint32
+	MimeType  string
+	Namespace byte
+	Path      string
+	Title     string
+	Redirect  bool
+	// RedirectIndex is the target entry of a redirect.
+	RedirectIndex uint32
+	Cluster       uint32
+	Blob          uint32
+}
+
+// FullPath returns the namespace-qualified path of the entry, e.g. "C/index.html".
+func (e *Entry) FullPath() string {
+	return string(e.Namespace) + "/" + e.Path
+}
+
+// Reader reads a ZIM file, or the parts of a split one.
+type Reader struct {
+	r      io.ReaderAt
+	size   int64
+	closer []io.Closer
+
+	Header    Header
+	mimeTypes []string
+}
+
+// Open opens a ZIM file. A path ending in .zimaa, or a .zim path whose file is
+// missing but whose parts exist, opens the parts as one file.
+func Open(path string) (*Reader, error) {
+	paths := []string{path}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) || strings.HasSuffix(path, ".zimaa") {
+		base := strings.TrimSuffix(path, "aa")
+		if parts, _ := filepath.Glob(globEscape(base) + "[a-z][a-z]"); len(parts) > 0 {
+			sort.Strings(parts)
+			paths = parts
+		}
+	}
+
+	mr := &multiReaderAt{}
+	z := &Reader{r: mr}
+	for _, p := range paths {
+		f, err := os.Open(p) // #nosec G304 - path is a ZIM file chosen by the user
+		if err != nil {
+			z.Close()
+			return nil, fmt.Errorf("failed to open %s: %w", p, err)
+		}
+		z.closer = append(z.closer, f)
+		info, err := f.Stat()
+		if err != nil {
+			z.Close()
+			return nil, fmt.Errorf("failed to stat %s: %w", p, err)
+		}
+		mr.add(f, info.Size())
+	}
+	z.size = mr.size
+
+	if err := z.readHeader(); err != nil {
+		z.Close()
+		return nil, err
+	}
+	return z, nil
+}
+
+// Close releases the underlying files.
+func (z *Reader) Close() error {
+	var firstErr error
+	for _, c := range z.closer {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Size returns the total size of the ZIM file in bytes.
+func (z *Reader) Size() int64 {
+	return z.size
+}
+
+// MimeTypes returns the MIME type list of the file.
+func (z *Reader) MimeTypes() []string {
+	return z.mimeTypes
+}
+
+func (z *Reader) readHeader() error {
+	buf := make([]byte, headerSize)
+	if _, err := z.r.ReadAt(buf, 0); err != nil {
+		return fmt.Errorf("failed to read ZIM header: %w", err)
+	}
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &z.Header); err != nil {
+		return fmt.Errorf("failed to decode ZIM header: %w", err)
+	}
+	if z.Header.MagicNumber != Magic {
+		return fmt.Errorf("not a ZIM file (magic number %d)", z.Header.MagicNumber)
+	}
+	for _, pos := range []uint64{z.Header.PathPtrPos, z.Header.ClusterPtrPos, z.Header.MimeListPos, z.Header.ChecksumPos} {
+		if pos >= uint64(z.size) { // #nosec G115 - size is a file size and never negative
+			return fmt.Errorf("corrupt ZIM header: offset %d beyond file size %d", pos, z.size)
+		}
+	}
+
+	pos := int64(z.Header.MimeListPos) // #nosec G115 - checked against the file size above
+	for {
+		s, err := z.readString(pos)
+		if err != nil {
+			return fmt.Errorf("failed to read MIME type list: %w", err)
+		}
+		if s == "" {
+			break
+		}
+		z.mimeTypes = append(z.mimeTypes, s)
+		pos += int64(len(s)) + 1
+	}
+	return nil
+}
+
+func (z *Reader) readUint64(pos int64) (uint64, error) {
+	var buf [8]byte
+	if _, err := z.r.ReadAt(buf[:], pos); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+func (z *Reader) readString(pos int64) (string, error) {
+	var sb strings.Builder
+	buf := make([]byte, 256)
+	for sb.Len() < maxStringLength {
+		n, err := z.r.ReadAt(buf, pos)
+		if idx := bytes.IndexByte(buf[:n], 0); idx != -1 {
+			sb.Write(buf[:idx])
+			return sb.String(), nil
+		}
+		sb.Write(buf[:n])
+		pos += int64(n)
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("string at offset %d is too long", pos)
+}
+
+// EntryAt reads the directory entry with the given index in path order.
+func (z *Reader) EntryAt(index uint32) (*Entry, error) {
+	if index >= z.Header.EntryCount {
+		return nil, fmt.Errorf("entry index %d out of range (%d entries)", index, z.Header.EntryCount)
+	}
+	ptr, err := z.readUint64(int64(z.Header.PathPtrPos) + int64(index)*8) // #nosec G115 - offsets are checked against the file size
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pointer of entry %d: %w", index, err)
+	}
+	if ptr >= uint64(z.size) { // #nosec G115 - size is never negative
+		return nil, fmt.Errorf("entry %d points beyond the end of the file", index)
+	}
+	pos := int64(ptr) // #nosec G115 - checked above
+
+	var fixed [16]byte
+	if _, err := z.r.ReadAt(fixed[:], pos); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read entry %d: %w", index, err)
+	}
+	e := &Entry{Index: index}
+	mime := binary.LittleEndian.Uint16(fixed[0:2])
+	e.Namespace = fixed[3]
+	if mime == redirectMimeType {
+		e.Redirect = true
+		e.RedirectIndex = binary.LittleEndian.Uint32(fixed[8:12])
+		pos += 12
+	} else {
+		if int(mime) >= len(z.mimeTypes) {
+			return nil, fmt.Errorf("entry %d has unknown MIME type %d", index, mime)
+		}
+		e.MimeType = z.mimeTypes[mime]
+		e.Cluster = binary.LittleEndian.Uint32(fixed[8:12])
+		e.Blob = binary.LittleEndian.Uint32(fixed[12:16])
+		pos += 16
+	}
+
+	if e.Path, err = z.readString(pos); err != nil {
+		return nil, fmt.Errorf("failed to read path of entry %d: %w", index, err)
+	}
+	pos += int64(len(e.Path)) + 1
+	if e.Title, err = z.readString(pos); err != nil {
+		return nil, fmt.Errorf("failed to read title of entry %d: %w", index, err)
+	}
+	if e.Title == "" {
+		e.Title = e.Path
+	}
+	return e, nil
+}
+
+// Find looks up an entry by namespace and path using the sorted path list.
+func (z *Reader) Find(namespace byte, path string) (*Entry, error) {
+	target := string(namespace) + "/" + path
+	var findErr error
+	n := int(z.Header.EntryCount)
+	idx := sort.Search(n, func(i int) bool {
+		e, err := z.EntryAt(uint32(i)) // #nosec G115 - i is below EntryCount
+		if err != nil {
+			findErr = err
+			return true
+		}
+		return e.FullPath() >= target
+	})
+	if findErr != nil {
+		return nil, findErr
+	}
+	if idx < n {
+		e, err := z.EntryAt(uint32(idx)) // #nosec G115 - idx is below EntryCount
+		if err == nil && e.FullPath() == target {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("entry %s not found", target)
+}
+
+// Resolve follows redirects until it reaches a content entry.
+func (z *Reader) Resolve(e *Entry) (*Entry, error) {
+	for hops := 0; e.Redirect; hops++ {
+		if hops > int(z.Header.EntryCount) {
+			return nil, fmt.Errorf("redirect loop at %s", e.FullPath())
+		}
+		next, err := z.EntryAt(e.RedirectIndex)
+		if err != nil {
+			return nil, err
+		}
+		e = next
+	}
+	return e, nil
+}
+
+// MainPage returns the entry of the main (welcome) page.
+func (z *Reader) MainPage() (*Entry, error) {
+	if z.Header.MainPage != noMainPage {
+		e, err := z.EntryAt(z.Header.MainPage)
+		if err != nil {
+			return nil, err
+		}
+		return z.Resolve(e)
+	}
+	e, err := z.Find(NamespaceWellKnown, "mainPage")
+	if err != nil {
+		return nil, fmt.Errorf("no main page set")
+	}
+	return z.Resolve(e)
+}
+
+// Content returns the payload of a content entry, following redirects.
+func (z *Reader) Content(e *Entry) ([]byte, error) {
+	e, err := z.Resolve(e)
+	if err != nil {
+		return nil, err
+	}
+	return z.blob(e.Cluster, e.Blob)
+}
+
+func (z *Reader) blob(cluster, blob uint32) ([]byte, error) {
+	data, extended, err := z.cluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+	offsetSize := 4
+	if extended {
+		offsetSize = 8
+	}
+	readOffset := func(i int) (uint64, error) {
+		start := i * offsetSize
+		if start+offsetSize > len(data) {
+			return 0, fmt.Errorf("cluster %d is truncated", cluster)
+		}
+		if extended {
+			return binary.LittleEndian.Uint64(data[start:]), nil
+		}
+		return uint64(binary.LittleEndian.Uint32(data[start:])), nil
+	}
+
+	first, err := readOffset(0)
+	if err != nil {
+		return nil, err
+	}
+	blobCount := int(first)/offsetSize - 1 // #nosec G115 - first is bounded by the cluster size below
+	if first > uint64(len(data)) || int(blob) >= blobCount {
+		return nil, fmt.Errorf("blob %d not in cluster %d", blob, cluster)
+	}
+	start, err := readOffset(int(blob))
+	if err != nil {
+		return nil, err
+	}
+	end, err := readOffset(int(blob) + 1)
+	if err != nil {
+		return nil, err
+	}
+	if start > end || end > uint64(len(data)) {
+		return nil, fmt.Errorf("blob %d of cluster %d has invalid offsets", blob, cluster)
+	}
+	return data[start:end], nil
+}
+
+// cluster reads and decompresses a cluster, returning its data after the info byte.
+func (z *Reader) cluster(index uint32) ([]byte, bool, error) {
+	if index >= z.Header.ClusterCount {
+		return nil, false, fmt.Errorf("cluster %d out of range (%d clusters)", index, z.Header.ClusterCount)
+	}
+	base := int64(z.Header.ClusterPtrPos) // #nosec G115 - checked against the file size when opening
+	start, err := z.readUint64(base + int64(index)*8)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read pointer of cluster %d: %w", index, err)
+	}
+	end := z.Header.ChecksumPos
+	if index+1 < z.Header.ClusterCount {
+		if end, err = z.readUint64(base + int64(index+1)*8); err != nil {
+			return nil, false, fmt.Errorf("failed to read pointer of cluster %d: %w", index+1, err)
+		}
+	}
+	if start >= end || end > uint64(z.size) { // #nosec G115 - size is never negative
+		return nil, false, fmt.Errorf("cluster %d has invalid bounds", index)
+	}
+
+	raw := make([]byte, end-start)
+	if _, err := z.r.ReadAt(raw, int64(start)); err != nil && !errors.Is(err, io.EOF) { // #nosec G115 - checked above
+		return nil, false, fmt.Errorf("failed to read cluster %d: %w", index, err)
+	}
+	info := raw[0]
+	extended := info&extendedOffsetBit != 0
+	payload := raw[1:]
+
+	switch info & compressionMask {
+	case 0, compressionNone:
+		return payload, extended, nil
+	case compressionZstd:
+		dec, err := zstd.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decompress cluster %d: %w", index, err)
+		}
+		defer dec.Close()
+		data, err := io.ReadAll(dec)
+		if err != nil && len(data) == 0 {
+			return nil, false, fmt.Errorf("failed to decompress cluster %d: %w", index, err)
+		}
+		return data, extended, nil
+	case compressionXZ:
+		dec, err := xz.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decompress cluster %d: %w", index, err)
+		}
+		data, err := io.ReadAll(dec)
+		if err != nil && len(data) == 0 {
+			return nil, false, fmt.Errorf("failed to decompress cluster %d: %w", index, err)
+		}
+		return data, extended, nil
+	default:
+		return nil, false, fmt.Errorf("cluster %d uses unsupported compression %d", index, info&compressionMask)
+	}
+}
+
+// VerifyChecksum compares the stored MD5 checksum with the file contents.
+func (z *Reader) VerifyChecksum() error {
+	pos := int64(z.Header.ChecksumPos) // #nosec G115 - checked against the file size when opening
+	if pos+checksumSize > z.size {
+		return fmt.Errorf("checksum beyond end of file")
+	}
+	hash := md5.New() // #nosec G401 - MD5 is the checksum mandated by the ZIM format
+	if _, err := io.Copy(hash, io.NewSectionReader(z.r, 0, pos)); err != nil {
+		return fmt.Errorf("failed to compute checksum: %w", err)
+	}
+	stored := make([]byte, checksumSize)
+	if _, err := z.r.ReadAt(stored, pos); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read checksum: %w", err)
+	}
+	if !bytes.Equal(hash.Sum(nil), stored) {
+		return fmt.Errorf("checksum mismatch")
+	}
+	return nil
+}
+
+// multiReaderAt presents consecutive files as one io.ReaderAt.
+type multiReaderAt struct {
+	parts   []io.ReaderAt
+	offsets []int64
+	size    int64
+}
+
+func (m *multiReaderAt) add(r io.ReaderAt, size int64) {
+	m.parts = append(m.parts, r)
+	m.offsets = append(m.offsets, m.size)
+	m.size += size
+}
+
+func (m *multiReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= m.size {
+		return 0, io.EOF
+	}
+	read := 0
+	for read < len(p) && off < m.size {
+		i := sort.Search(len(m.offsets), func(i int) bool { return m.offsets[i] > off }) - 1
+		n, err := m.parts[i].ReadAt(p[read:], off-m.offsets[i])
+		read += n
+		off += int64(n)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return read, err
+		}
+		if n == 0 {
+			break
+		}
+	}
+	if read < len(p) {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+// globEscape escapes glob metacharacters in a literal path.
+func globEscape(path string) string {
+	var sb strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
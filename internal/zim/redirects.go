@@ -0,0 +1,118 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package zim
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/store"
+)
+
+// Redirect is a redirect entry of a ZIM file: the entry at Path leads to
+// the one at Target, both relative to the directory the ZIM file is made of.
+type Redirect struct {
+	Path   string
+	Title  string
+	Target string
+}
+
+// Redirects returns the redirect pages the manifests below dir recorded,
+// leaving out those whose file was since replaced by a resource or removed.
+func Redirects(dir string) ([]Redirect, error) {
+	var redirects []Redirect
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == store.DirName {
+			return filepath.SkipDir
+		}
+		captureDir, ok := manifest.CaptureDir(p)
+		if d.IsDir() || !ok {
+			return nil
+		}
+		m, err := manifest.Load(captureDir)
+		if err != nil {
+			return err
+		}
+		prefix, err := filepath.Rel(dir, captureDir)
+		if err != nil {
+			return err
+		}
+		titles := make(map[string]string, len(m.Resources))
+		for _, r := range m.Resources {
+			if r.Path != "" {
+				titles[r.Path] = r.Title
+			}
+		}
+		for _, s := range m.Stubs {
+			if _, saved := titles[s.Path]; saved || strings.ContainsAny(s.Path+s.Target, "\t\n") {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(p), filepath.FromSlash(s.Path))); err != nil {
+				continue
+			}
+			title := titles[s.Target]
+			if title == "" || strings.ContainsAny(title, "\t\n") {
+				title = path.Base(s.Target)
+			}
+			redirects = append(redirects, Redirect{
+				Path:   path.Join(filepath.ToSlash(prefix), s.Path),
+				Title:  title,
+				Target: path.Join(filepath.ToSlash(prefix), s.Target),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redirects of %s: %w", dir, err)
+	}
+	return redirects, nil
+}
+
+// WriteRedirects stores redirects in the tab-separated form zimwriterfs
+// reads with --redirects: path, title, and target on each line.
+func WriteRedirects(file string, redirects []Redirect, perms os.FileMode) error {
+	var b strings.Builder
+	for _, r := range redirects {
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", r.Path, r.Title, r.Target)
+	}
+	if err := os.WriteFile(file, []byte(b.String()), perms); err != nil {
+		return fmt.Errorf("failed to write redirects: %w", err)
+	}
+	return nil
+}
+
+// SetAside moves the redirect pages out of dir into aside, so zimwriterfs
+// adds redirect entries at their paths instead of the pages, and returns a
+// function that moves them back.
+func SetAside(dir, aside string, redirects []Redirect, dirPerms os.FileMode) (func() error, error) {
+	var moved []Redirect
+	restore := func() error {
+		for _, r := range moved {
+			if err := os.Rename(filepath.Join(aside, filepath.FromSlash(r.Path)), filepath.Join(dir, filepath.FromSlash(r.Path))); err != nil {
+				return fmt.Errorf("failed to restore redirect page %s: %w", r.Path, err)
+			}
+		}
+		return nil
+	}
+	for _, r := range redirects {
+		dst := filepath.Join(aside, filepath.FromSlash(r.Path))
+		if err := os.MkdirAll(filepath.Dir(dst), dirPerms); err != nil {
+			return nil, fmt.Errorf("failed to set aside redirect page %s: %w", r.Path, errors.Join(err, restore()))
+		}
+		if err := os.Rename(filepath.Join(dir, filepath.FromSlash(r.Path)), dst); err != nil {
+			return nil, fmt.Errorf("failed to set aside redirect page %s: %w", r.Path, errors.Join(err, restore()))
+		}
+		moved = append(moved, r)
+	}
+	return restore, nil
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package zim
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Handler serves the content of a ZIM file over HTTP. The root redirects to
+// the main page and every other path is looked up as a content entry.
+func Handler(z *Reader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		if path == "" {
+			main, err := z.MainPage()
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			http.Redirect(w, r, "/"+(&url.URL{Path: main.Path}).EscapedPath(), http.StatusFound)
+			return
+		}
+
+		entry, err := z.FindContent(path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if entry.Redirect {
+			target, err := z.Resolve(entry)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, "/"+(&url.URL{Path: target.Path}).EscapedPath(), http.StatusMovedPermanently)
+			return
+		}
+
+		data, err := z.Content(entry)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", entry.MimeType)
+		_, _ = w.Write(data)
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+// Package zim contains helpers for working with ZIM files produced by the archiver.
+package zim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxParts is the number of two-letter suffixes available (aa through zz).
+const maxParts = 26 * 26
+
+// PartName returns the zimsplit-compatible name of part i of a ZIM file, e.g. archive.zimaa.
+func PartName(zimFile string, i int) string {
+	return fmt.Sprintf("%s%c%c", zimFile, 'a'+i/26, 'a'+i%26)
+}
+
+// Split cuts zimFile into parts of at most maxSize bytes, named like zimsplit
+// does (.zimaa, .zimab, ...), and removes the original. Readers such as Kiwix
+// open the set through its first part. Files that already fit are left alone
+// and reported as a single part.
+func Split(zimFile string, maxSize int64, perms os.FileMode) ([]string, error) {
+	info, err := os.Stat(zimFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", zimFile, err)
+	}
+	if maxSize <= 0 || info.Size() <= maxSize {
+		return []string{zimFile}, nil
+	}
+	if (info.Size()+maxSize-1)/maxSize > maxParts {
+		return nil, fmt.Errorf("splitting %s into %d byte parts needs more than %d parts", zimFile, maxSize, maxParts)
+	}
+
+	src, err := os.Open(zimFile) // #nosec G304 - zimFile is created by this program
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", zimFile, err)
+	}
+	defer src.Close()
+
+	var parts []string
+	for i := 0; ; i++ {
+		part := PartName(zimFile, i)
+		written, err := writePart(src, part, maxSize, perms)
+		if err != nil {
+			return parts, err
+		}
+		if written == 0 {
+			if err := os.Remove(part); err != nil {
+				return parts, fmt.Errorf("failed to remove empty part %s: %w", part, err)
+			}
+			break
+		}
+		parts = append(parts, part)
+		if written < maxSize {
+			break
+		}
+	}
+
+	if err := os.Remove(zimFile); err != nil {
+		return parts, fmt.Errorf("failed to remove %s after splitting: %w", zimFile, err)
+	}
+	return parts, nil
+}
+
+func writePart(src io.Reader, part string, maxSize int64, perms os.FileMode) (int64, error) {
+	dst, err := os.OpenFile(part, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perms) // #nosec G304 - part is derived from a file created by this program
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", part, err)
+	}
+	written, err := io.CopyN(dst, src, maxSize)
+	if closeErr := dst.Close(); err == nil || errors.Is(err, io.EOF) {
+		err = closeErr
+	}
+	if err != nil {
+		return written, fmt.Errorf("failed to write %s: %w", part, err)
+	}
+	return written, nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package zim
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// IllustrationPath is the metadata entry holding the 48x48 illustration.
+const IllustrationPath = "Illustration_48x48@1"
+
+// Expectation describes what a freshly built ZIM file must contain.
+type Expectation struct {
+	// Paths are the files that were handed to the ZIM writer, relative to its
+	// input directory and using forward slashes.
+	Paths []string
+	// SampleSize is the number of random entries whose content is read back.
+	SampleSize int
+}
+
+// Validate opens the ZIM file at path and checks it against expect: the
+// checksum matches, every expected path has an entry, the welcome page and
+// the illustration resolve, and a random sample of entries is readable.
+// All problems found are reported together.
+func Validate(path string, expect Expectation) error {
+	z, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+
+	var problems []error
+	if err := z.VerifyChecksum(); err != nil {
+		problems = append(problems, err)
+	}
+
+	contentEntries, err := z.countContent()
+	if err != nil {
+		problems = append(problems, err)
+	}
+	if contentEntries < len(expect.Paths) {
+		problems = append(problems, fmt.Errorf("ZIM has %d content entries but %d files were archived", contentEntries, len(expect.Paths)))
+	}
+	missing := 0
+	for _, p := range expect.Paths {
+		if _, err := z.FindContent(p); err != nil {
+			if missing < maxReportedMissing {
+				problems = append(problems, fmt.Errorf("archived file %s has no entry", p))
+			}
+			missing++
+		}
+	}
+	if missing > maxReportedMissing {
+		problems = append(problems, fmt.Errorf("%d more archived files have no entry", missing-maxReportedMissing))
+	}
+
+	if main, err := z.MainPage(); err != nil {
+		problems = append(problems, fmt.Errorf("welcome page does not resolve: %w", err))
+	} else if _, err := z.Content(main); err != nil {
+		problems = append(problems, fmt.Errorf("welcome page %s is unreadable: %w", main.FullPath(), err))
+	}
+
+	if illustration, err := z.Find(NamespaceMetadata, IllustrationPath); err != nil {
+		problems = append(problems, fmt.Errorf("illustration missing: %w", err))
+	} else if data, err := z.Content(illustration); err != nil || len(data) == 0 {
+		problems = append(problems, fmt.Errorf("illustration is unreadable or empty"))
+	}
+
+	problems = append(problems, z.sample(expect.SampleSize)...)
+	return errors.Join(problems...)
+}
+
+// maxReportedMissing limits how many missing files are listed individually.
+const maxReportedMissing = 10
+
+// FindContent looks up a user content entry in either namespace scheme.
+func (z *Reader) FindContent(path string) (*Entry, error) {
+	path = strings.TrimPrefix(path, "/")
+	if e, err := z.Find(NamespaceContent, path); err == nil {
+		return e, nil
+	}
+	// ZIM files of minor version 0 spread content over several namespaces
+	for _, ns := range []byte{NamespaceArticles, 'I', '-'} {
+		if e, err := z.Find(ns, path); err == nil {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("entry %s not found", path)
+}
+
+// isContentNamespace reports whether a namespace holds user content.
+func isContentNamespace(ns byte) bool {
+	return ns == NamespaceContent || ns == NamespaceArticles || ns == 'I' || ns == '-'
+}
+
+func (z *Reader) countContent() (int, error) {
+	count := 0
+	for i := uint32(0); i < z.Header.EntryCount; i++ {
+		e, err := z.EntryAt(i)
+		if err != nil {
+			return count, err
+		}
+		if isContentNamespace(e.Namespace) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// sample reads the content of up to n random content entries.
+func (z *Reader) sample(n int) []error {
+	if n <= 0 || z.Header.EntryCount == 0 {
+		return nil
+	}
+	var problems []error
+	for _, i := range rand.Perm(int(z.Header.EntryCount)) { // #nosec G404 - sampling does not need a secure source
+		if n == 0 {
+			break
+		}
+		e, err := z.EntryAt(uint32(i)) // #nosec G115 - i is below EntryCount
+		if err != nil {
+			problems = append(problems, err)
+			n--
+			continue
+		}
+		if !isContentNamespace(e.Namespace) {
+			continue
+		}
+		if _, err := z.Content(e); err != nil {
+			problems = append(problems, fmt.Errorf("entry %s is unreadable: %w", e.FullPath(), err))
+		}
+		n--
+	}
+	return problems
+}
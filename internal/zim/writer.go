@@ -0,0 +1,618 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package zim
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5" // #nosec G501 - MD5 is the checksum mandated by the ZIM format
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/store"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/net/html"
+)
+
+const (
+	// clusterSize is the payload size at which a cluster is closed, as with
+	// libzim.
+	clusterSize = 2 << 20
+	// NamespaceListing holds the title listing of the articles.
+	NamespaceListing = 'X'
+	// listingPath lists the articles by title for suggestions and random
+	// article selection.
+	listingPath     = "listing/titleOrdered/v1"
+	listingMimeType = "application/octet-stream+zimlisting"
+	// noLayoutPage is the header value used without a layout page.
+	noLayoutPage = 0xffffffff
+	// maxTitleScan bounds how much of a page is read for its title.
+	maxTitleScan = 1 << 20
+)
+
+// Metadata is the metadata of a ZIM file. Welcome and Illustration are
+// paths relative to the directory the file is made of.
+type Metadata struct {
+	Welcome         string
+	Illustration    string
+	Language        string
+	Title           string
+	Name            string
+	Description     string
+	LongDescription string
+	Creator         string
+	Publisher       string
+	// Tags are separated by semicolons
+	Tags string
+}
+
+// item is an entry to write.
+type item struct {
+	namespace byte
+	path      string
+	title     string
+	mimeType  string
+	// file holds the content of file entries and data that of the others
+	file string
+	data []byte
+	size int64
+	// target is the full path a redirect leads to
+	target   string
+	redirect bool
+
+	index         uint32
+	redirectIndex uint32
+	cluster       uint32
+	blob          uint32
+}
+
+func (it *item) fullPath() string {
+	return string(it.namespace) + "/" + it.path
+}
+
+// sortTitle is the title the title index orders the entry by.
+func (it *item) sortTitle() string {
+	if it.title != "" {
+		return string(it.namespace) + "/" + it.title
+	}
+	return it.fullPath()
+}
+
+// cluster is a group of entries stored, and compressed, together.
+type cluster struct {
+	index      uint32
+	compressed bool
+	items      []*item
+	size       int64
+}
+
+// Write creates the ZIM file zimFile from the files below dir, without
+// zimwriterfs. Every file of the site becomes a content entry, except the
+// pages of redirects, which become redirect entries when their target was
+// archived.
+// Text is stored in zstd-compressed clusters and media as it is. No
+// full-text index is built.
+func Write(ctx context.Context, dir, zimFile string, meta Metadata, redirects []Redirect, perms os.FileMode) error {
+	items, err := fileItems(ctx, dir, redirects)
+	if err != nil {
+		return err
+	}
+	content := make(map[string]*item, len(items))
+	for _, it := range items {
+		content[it.path] = it
+	}
+	welcome, ok := content[filepath.ToSlash(meta.Welcome)]
+	if !ok {
+		return fmt.Errorf("welcome page %s is not in %s", meta.Welcome, dir)
+	}
+	for _, r := range redirects {
+		if _, ok := content[r.Target]; ok {
+			items = append(items, &item{namespace: NamespaceContent, path: r.Path, title: r.Title, redirect: true, target: string(NamespaceContent) + "/" + r.Target})
+		}
+	}
+	items = append(items, &item{namespace: NamespaceWellKnown, path: "mainPage", redirect: true, target: welcome.fullPath()})
+
+	illustration, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(meta.Illustration))) // #nosec G304 - the illustration is part of the capture
+	if err != nil {
+		return fmt.Errorf("failed to read illustration: %w", err)
+	}
+	items = append(items, dataItem(NamespaceMetadata, IllustrationPath, "image/png", illustration))
+	for _, field := range []struct{ name, value string }{
+		{"Title", meta.Title},
+		{"Name", meta.Name},
+		{"Description", meta.Description},
+		{"LongDescription", meta.LongDescription},
+		{"Language", meta.Language},
+		{"Creator", meta.Creator},
+		{"Publisher", meta.Publisher},
+		{"Tags", meta.Tags},
+		{"Date", time.Now().Format(time.DateOnly)},
+		{"Counter", counter(items)},
+	} {
+		if field.value != "" {
+			items = append(items, dataItem(NamespaceMetadata, field.name, "text/plain", []byte(field.value)))
+		}
+	}
+	var articles []*item
+	for _, it := range items {
+		if it.namespace == NamespaceContent && !it.redirect && it.mimeType == "text/html" {
+			articles = append(articles, it)
+		}
+	}
+	listing := dataItem(NamespaceListing, listingPath, listingMimeType, make([]byte, 4*len(articles)))
+	items = append(items, listing)
+
+	sort.Slice(items, func(i, j int) bool { return items[i].fullPath() < items[j].fullPath() })
+	if uint64(len(items)) > math.MaxUint32 {
+		return fmt.Errorf("%d entries are more than a ZIM file holds", len(items))
+	}
+	byPath := make(map[string]*item, len(items))
+	for i, it := range items {
+		it.index = uint32(i) // #nosec G115 - checked against MaxUint32 above
+		byPath[it.fullPath()] = it
+	}
+	for _, it := range items {
+		if it.redirect {
+			it.redirectIndex = byPath[it.target].index
+		}
+	}
+	sort.SliceStable(articles, func(i, j int) bool { return articles[i].sortTitle() < articles[j].sortTitle() })
+	for i, it := range articles {
+		binary.LittleEndian.PutUint32(listing.data[4*i:], it.index)
+	}
+
+	w := &writer{items: items}
+	w.layout()
+	return w.write(ctx, zimFile, byPath["W/mainPage"].index, perms)
+}
+
+// fileItems returns the content entries of the files below dir, leaving out
+// the pages of redirects, the metadata directories of captures, and the
+// object store.
+func fileItems(ctx context.Context, dir string, redirects []Redirect) ([]*item, error) {
+	skip := make(map[string]bool, len(redirects))
+	for _, r := range redirects {
+		skip[r.Path] = true
+	}
+	var items []*item
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() && p != dir && (d.Name() == manifest.Dir || d.Name() == store.DirName) {
+			return filepath.SkipDir
+		}
+		info, err := os.Stat(p) // Follows links, as zimwriterfs does
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if skip[rel] {
+			return nil
+		}
+		it := &item{namespace: NamespaceContent, path: rel, file: p, size: info.Size(), mimeType: mimeType(p)}
+		if it.mimeType == "text/html" {
+			it.title = htmlTitle(p)
+		}
+		items = append(items, it)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of %s: %w", dir, err)
+	}
+	return items, nil
+}
+
+func dataItem(namespace byte, path, mimeType string, data []byte) *item {
+	return &item{namespace: namespace, path: path, mimeType: mimeType, data: data, size: int64(len(data))}
+}
+
+// mimeType returns the MIME type of the file at p without parameters, by its
+// extension or, failing that, its first bytes.
+func mimeType(p string) string {
+	t := mime.TypeByExtension(strings.ToLower(filepath.Ext(p)))
+	if t == "" {
+		t = "application/octet-stream"
+		if f, err := os.Open(p); err == nil { // #nosec G304 - p is a file of the capture
+			head := make([]byte, 512)
+			n, _ := io.ReadFull(f, head)
+			f.Close()
+			t = http.DetectContentType(head[:n])
+		}
+	}
+	if base, _, err := mime.ParseMediaType(t); err == nil {
+		return base
+	}
+	return t
+}
+
+// htmlTitle returns the title of the page at p, or "" when it has none.
+func htmlTitle(p string) string {
+	f, err := os.Open(p) // #nosec G304 - p is a file of the capture
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	z := html.NewTokenizer(io.LimitReader(f, maxTitleScan))
+	inTitle := false
+	var title strings.Builder
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			inTitle = string(name) == "title"
+		case html.TextToken:
+			if inTitle {
+				title.Write(z.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "title" {
+				return strings.Join(strings.Fields(title.String()), " ")
+			}
+		}
+	}
+}
+
+// counter returns the Counter metadata of the content entries: how many
+// there are of each MIME type.
+func counter(items []*item) string {
+	counts := make(map[string]int)
+	for _, it := range items {
+		if it.namespace == NamespaceContent && !it.redirect {
+			counts[it.mimeType]++
+		}
+	}
+	var parts []string
+	for t, n := range counts {
+		parts = append(parts, fmt.Sprintf("%s=%d", t, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ";")
+}
+
+// compressible reports whether content of type t shrinks when compressed;
+// images, media, fonts, and archives mostly are compressed already.
+func compressible(t string) bool {
+	switch {
+	case t == "image/svg+xml", t == "image/bmp", t == "image/x-icon", t == "image/vnd.microsoft.icon":
+		return true
+	case strings.HasPrefix(t, "image/"), strings.HasPrefix(t, "video/"), strings.HasPrefix(t, "audio/"):
+		return false
+	}
+	switch t {
+	case "font/woff", "font/woff2", "application/font-woff", "application/zip", "application/gzip",
+		"application/x-gzip", "application/zstd", "application/x-xz", "application/x-bzip2",
+		"application/x-7z-compressed", "application/pdf", "application/epub+zip":
+		return false
+	}
+	return true
+}
+
+// writer lays out and writes the entries of a ZIM file.
+type writer struct {
+	items     []*item
+	mimeTypes []string
+	mimeIndex map[string]uint16
+	clusters  []*cluster
+	header    Header
+	direntPos uint64
+	// clusterPos are the offsets of the clusters once written
+	clusterPos []uint64
+}
+
+// layout assigns the entries to clusters and the MIME type list and the
+// pointer lists their offsets.
+func (w *writer) layout() {
+	w.mimeIndex = make(map[string]uint16)
+	for _, it := range w.items {
+		if !it.redirect {
+			w.mimeIndex[it.mimeType] = 0
+		}
+	}
+	for t := range w.mimeIndex {
+		w.mimeTypes = append(w.mimeTypes, t)
+	}
+	sort.Strings(w.mimeTypes)
+	for i, t := range w.mimeTypes {
+		w.mimeIndex[t] = uint16(i) // #nosec G115 - there are far fewer MIME types than 0xffff
+	}
+
+	open := map[bool]*cluster{}
+	for _, it := range w.items {
+		if it.redirect {
+			continue
+		}
+		group := compressible(it.mimeType)
+		c := open[group]
+		if c == nil || (len(c.items) > 0 && c.size+it.size > clusterSize) {
+			c = &cluster{index: uint32(len(w.clusters)), compressed: group} // #nosec G115 - there are fewer clusters than entries
+			open[group] = c
+			w.clusters = append(w.clusters, c)
+		}
+		it.cluster = c.index
+		it.blob = uint32(len(c.items)) // #nosec G115 - there are fewer blobs than entries
+		c.items = append(c.items, it)
+		c.size += it.size
+	}
+
+	pos := uint64(headerSize)
+	w.header.MimeListPos = pos
+	for _, t := range w.mimeTypes {
+		pos += uint64(len(t)) + 1
+	}
+	pos++
+	n := uint64(len(w.items))
+	w.header.PathPtrPos = pos
+	pos += 8 * n
+	w.header.TitlePtrPos = pos
+	pos += 4 * n
+	w.direntPos = pos
+	for _, it := range w.items {
+		pos += uint64(direntSize(it))
+	}
+	w.header.ClusterPtrPos = pos
+	w.header.EntryCount = uint32(n)                 // #nosec G115 - checked against MaxUint32 by Write
+	w.header.ClusterCount = uint32(len(w.clusters)) // #nosec G115 - there are fewer clusters than entries
+}
+
+func direntSize(it *item) int {
+	size := 16
+	if it.redirect {
+		size = 12
+	}
+	return size + len(it.path) + 1 + len(direntTitle(it)) + 1
+}
+
+// direntTitle leaves out titles that are the path, which readers fall back
+// to.
+func direntTitle(it *item) string {
+	if it.title == it.path {
+		return ""
+	}
+	return it.title
+}
+
+// write stores the file at zimFile, through a temporary file next to it.
+func (w *writer) write(ctx context.Context, zimFile string, mainPage uint32, perms os.FileMode) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(zimFile), "."+filepath.Base(zimFile)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create ZIM file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	w.header.MagicNumber = Magic
+	w.header.MajorVersion = 6
+	w.header.MinorVersion = 1
+	if _, err := rand.Read(w.header.UUID[:]); err != nil {
+		return fmt.Errorf("failed to create ZIM UUID: %w", err)
+	}
+	w.header.UUID[6] = w.header.UUID[6]&0x0f | 0x40
+	w.header.UUID[8] = w.header.UUID[8]&0x3f | 0x80
+	w.header.MainPage = mainPage
+	w.header.LayoutPage = noLayoutPage
+
+	bw := bufio.NewWriter(f)
+	if err := w.writeIndex(bw); err != nil {
+		return fmt.Errorf("failed to write ZIM directory: %w", err)
+	}
+	pos := int64(w.header.ClusterPtrPos) + 8*int64(len(w.clusters)) // #nosec G115 - offsets of the file being written
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	defer enc.Close()
+	for i, c := range w.clusters {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		w.clusterPos = append(w.clusterPos, uint64(pos)) // #nosec G115 - pos is never negative
+		n, err := writeCluster(bw, enc, c)
+		if err != nil {
+			return fmt.Errorf("failed to write cluster %d: %w", i, err)
+		}
+		pos += n
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write ZIM file: %w", err)
+	}
+	w.header.ChecksumPos = uint64(pos) // #nosec G115 - pos is never negative
+
+	var header bytes.Buffer
+	if err := binary.Write(&header, binary.LittleEndian, &w.header); err != nil {
+		return fmt.Errorf("failed to encode ZIM header: %w", err)
+	}
+	if _, err := f.WriteAt(header.Bytes(), 0); err != nil {
+		return fmt.Errorf("failed to write ZIM header: %w", err)
+	}
+	ptrs := make([]byte, 8*len(w.clusterPos))
+	for i, p := range w.clusterPos {
+		binary.LittleEndian.PutUint64(ptrs[8*i:], p)
+	}
+	if _, err := f.WriteAt(ptrs, int64(w.header.ClusterPtrPos)); err != nil { // #nosec G115 - offset of the file being written
+		return fmt.Errorf("failed to write cluster pointers: %w", err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to checksum ZIM file: %w", err)
+	}
+	hash := md5.New() // #nosec G401 - MD5 is the checksum mandated by the ZIM format
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("failed to checksum ZIM file: %w", err)
+	}
+	if _, err := f.Write(hash.Sum(nil)); err != nil {
+		return fmt.Errorf("failed to write checksum: %w", err)
+	}
+	if err := f.Chmod(perms); err != nil {
+		return fmt.Errorf("failed to set permissions of ZIM file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write ZIM file: %w", err)
+	}
+	if err := os.Rename(f.Name(), zimFile); err != nil {
+		return fmt.Errorf("failed to create ZIM file: %w", err)
+	}
+	return nil
+}
+
+// writeIndex writes the header space, the MIME type list, the pointer lists,
+// the directory entries, and space for the cluster pointers.
+func (w *writer) writeIndex(bw *bufio.Writer) error {
+	if _, err := bw.Write(make([]byte, headerSize)); err != nil {
+		return err
+	}
+	for _, t := range w.mimeTypes {
+		bw.WriteString(t)
+		bw.WriteByte(0)
+	}
+	bw.WriteByte(0)
+
+	var buf [8]byte
+	pos := w.direntPos
+	for _, it := range w.items {
+		binary.LittleEndian.PutUint64(buf[:], pos)
+		bw.Write(buf[:])
+		pos += uint64(direntSize(it))
+	}
+	byTitle := make([]*item, len(w.items))
+	copy(byTitle, w.items)
+	sort.SliceStable(byTitle, func(i, j int) bool { return byTitle[i].sortTitle() < byTitle[j].sortTitle() })
+	for _, it := range byTitle {
+		binary.LittleEndian.PutUint32(buf[:4], it.index)
+		bw.Write(buf[:4])
+	}
+
+	for _, it := range w.items {
+		var fixed [16]byte
+		fixed[3] = it.namespace
+		size := 16
+		if it.redirect {
+			binary.LittleEndian.PutUint16(fixed[0:], redirectMimeType)
+			binary.LittleEndian.PutUint32(fixed[8:], it.redirectIndex)
+			size = 12
+		} else {
+			binary.LittleEndian.PutUint16(fixed[0:], w.mimeIndex[it.mimeType])
+			binary.LittleEndian.PutUint32(fixed[8:], it.cluster)
+			binary.LittleEndian.PutUint32(fixed[12:], it.blob)
+		}
+		bw.Write(fixed[:size])
+		bw.WriteString(it.path)
+		bw.WriteByte(0)
+		bw.WriteString(direntTitle(it))
+		bw.WriteByte(0)
+	}
+	_, err := bw.Write(make([]byte, 8*len(w.clusters)))
+	return err
+}
+
+// writeCluster writes c to bw and returns its size in the file.
+func writeCluster(bw *bufio.Writer, enc *zstd.Encoder, c *cluster) (int64, error) {
+	offsetSize := int64(4)
+	if int64(len(c.items)+1)*4+c.size > math.MaxUint32 {
+		offsetSize = 8
+	}
+	info := byte(compressionNone)
+	if c.compressed {
+		info = compressionZstd
+	}
+	if offsetSize == 8 {
+		info |= extendedOffsetBit
+	}
+	if err := bw.WriteByte(info); err != nil {
+		return 0, err
+	}
+
+	out := &countingWriter{w: bw}
+	var dst io.Writer = out
+	if c.compressed {
+		enc.Reset(out)
+		dst = enc
+	}
+	offsets := make([]byte, 0, offsetSize*int64(len(c.items)+1))
+	offset := offsetSize * int64(len(c.items)+1)
+	for i := 0; i <= len(c.items); i++ {
+		if offsetSize == 8 {
+			offsets = binary.LittleEndian.AppendUint64(offsets, uint64(offset)) // #nosec G115 - offset is never negative
+		} else {
+			offsets = binary.LittleEndian.AppendUint32(offsets, uint32(offset)) // #nosec G115 - checked against MaxUint32 above
+		}
+		if i < len(c.items) {
+			offset += c.items[i].size
+		}
+	}
+	if _, err := dst.Write(offsets); err != nil {
+		return 0, err
+	}
+	for _, it := range c.items {
+		if err := writeBlob(dst, it); err != nil {
+			return 0, err
+		}
+	}
+	if c.compressed {
+		if err := enc.Close(); err != nil {
+			return 0, err
+		}
+	}
+	return 1 + out.n, nil
+}
+
+// writeBlob copies the content of it to w.
+func writeBlob(w io.Writer, it *item) error {
+	if it.file == "" {
+		_, err := w.Write(it.data)
+		return err
+	}
+	f, err := os.Open(it.file) // #nosec G304 - the file is part of the capture
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := io.Copy(w, io.LimitReader(f, it.size))
+	if err != nil {
+		return err
+	}
+	if n != it.size {
+		return errors.New(it.path + " changed while the ZIM file was written")
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
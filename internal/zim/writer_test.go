@@ -0,0 +1,90 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package zim
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+)
+
+func TestWriteReadBack(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":                        "<html><head><title>Home</title></head><body><a href=\"docs/page.html\">Docs</a></body></html>",
+		"docs/page.html":                    "<html><head><title>Page</title></head><body>Docs</body></html>",
+		"style.css":                         "body{color:red}",
+		"illustration.png":                  "\x89PNG\r\n\x1a\n",
+		manifest.Path:                       `{"resources":[]}`,
+		manifest.Dir + "/crawl-state.json":  "{}",
+		manifest.Dir + "/visited/bloom.bin": "bloom",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	zimFile := filepath.Join(t.TempDir(), "site.zim")
+	meta := Metadata{Welcome: "index.html", Illustration: "illustration.png", Language: "eng", Title: "Site", Name: "site"}
+	if err := Write(context.Background(), dir, zimFile, meta, nil, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	z, err := Open(zimFile)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer z.Close()
+	if err := z.VerifyChecksum(); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{"index.html", "docs/page.html", "style.css"} {
+		e, err := z.Find(NamespaceContent, name)
+		if err != nil {
+			t.Errorf("find %s: %v", name, err)
+			continue
+		}
+		data, err := z.Content(e)
+		if err != nil {
+			t.Errorf("read %s: %v", name, err)
+			continue
+		}
+		if string(data) != files[name] {
+			t.Errorf("%s = %q, want %q", name, data, files[name])
+		}
+	}
+	if e, err := z.Find(NamespaceContent, "docs/page.html"); err == nil && e.Title != "Page" {
+		t.Errorf("title of docs/page.html = %q, want Page", e.Title)
+	}
+	for _, name := range []string{manifest.Path, manifest.Dir + "/crawl-state.json", manifest.Dir + "/visited/bloom.bin"} {
+		if _, err := z.Find(NamespaceContent, name); err == nil {
+			t.Errorf("metadata file %s was packed", name)
+		}
+	}
+
+	main, err := z.MainPage()
+	if err != nil {
+		t.Fatalf("main page: %v", err)
+	}
+	if main.FullPath() != "C/index.html" {
+		t.Errorf("main page = %s, want C/index.html", main.FullPath())
+	}
+	title, err := z.Find(NamespaceMetadata, "Title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := z.Content(title); err != nil || string(data) != "Site" {
+		t.Errorf("Title metadata = %q, %v", data, err)
+	}
+	if _, err := z.Find(NamespaceMetadata, IllustrationPath); err != nil {
+		t.Error(err)
+	}
+}
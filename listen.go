@@ -0,0 +1,50 @@
+// Copyright (c) 2025 Sudo-Ivan
+// Licensed under the MIT License
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Sudo-Ivan/website-archiver/pkg"
+)
+
+// serveHTTP serves srv on ln, or on srv.Addr when ln is nil, until ctx ends
+// or the program is interrupted. It then shuts srv down, giving requests in
+// flight ShutdownTimeout to finish before their connections are closed.
+func serveHTTP(ctx context.Context, srv *http.Server, ln net.Listener) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	served := make(chan error, pkg.OneLength)
+	go func() {
+		if ln == nil {
+			served <- srv.ListenAndServe()
+			return
+		}
+		served <- srv.Serve(ln)
+	}()
+	select {
+	case err := <-served:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), pkg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Closing connections still open at shutdown", pkg.LogError, err)
+		return srv.Close()
+	}
+	return nil
+}
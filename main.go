@@ -6,24 +6,64 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
+	"image"
+	"image/color"
+	// Illustrations may be GIF and JPEG images too
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
 	"log/slog"
 	"net/http"
-	"net/url"
+	neturl "net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/Sudo-Ivan/website-archiver/config"
+	"github.com/Sudo-Ivan/website-archiver/internal/auth"
+	"github.com/Sudo-Ivan/website-archiver/internal/bandwidth"
+	"github.com/Sudo-Ivan/website-archiver/internal/browserprofile"
+	"github.com/Sudo-Ivan/website-archiver/internal/catalog"
+	"github.com/Sudo-Ivan/website-archiver/internal/challenge"
+	"github.com/Sudo-Ivan/website-archiver/internal/cookies"
+	"github.com/Sudo-Ivan/website-archiver/internal/depthboost"
+	"github.com/Sudo-Ivan/website-archiver/internal/diag"
 	"github.com/Sudo-Ivan/website-archiver/internal/downloader"
+	"github.com/Sudo-Ivan/website-archiver/internal/epub"
+	"github.com/Sudo-Ivan/website-archiver/internal/errorpages"
+	"github.com/Sudo-Ivan/website-archiver/internal/fetcherr"
+	"github.com/Sudo-Ivan/website-archiver/internal/ipfs"
+	"github.com/Sudo-Ivan/website-archiver/internal/legacy"
+	"github.com/Sudo-Ivan/website-archiver/internal/manifest"
+	"github.com/Sudo-Ivan/website-archiver/internal/mimefilter"
+	"github.com/Sudo-Ivan/website-archiver/internal/onion"
+	"github.com/Sudo-Ivan/website-archiver/internal/paywall"
+	"github.com/Sudo-Ivan/website-archiver/internal/pdf"
+	"github.com/Sudo-Ivan/website-archiver/internal/preset"
+	"github.com/Sudo-Ivan/website-archiver/internal/profile"
+	"github.com/Sudo-Ivan/website-archiver/internal/progress"
+	"github.com/Sudo-Ivan/website-archiver/internal/referrer"
+	"github.com/Sudo-Ivan/website-archiver/internal/result"
+	"github.com/Sudo-Ivan/website-archiver/internal/simhash"
+	"github.com/Sudo-Ivan/website-archiver/internal/singlefile"
+	"github.com/Sudo-Ivan/website-archiver/internal/timetravel"
+	"github.com/Sudo-Ivan/website-archiver/internal/torrent"
+	"github.com/Sudo-Ivan/website-archiver/internal/urlfilter"
+	"github.com/Sudo-Ivan/website-archiver/internal/urlnorm"
+	"github.com/Sudo-Ivan/website-archiver/internal/useragent"
+	"github.com/Sudo-Ivan/website-archiver/internal/wayback"
+	"github.com/Sudo-Ivan/website-archiver/internal/zim"
 	"github.com/Sudo-Ivan/website-archiver/pkg"
 )
 
@@ -46,6 +86,8 @@ type DownloadResult struct {
 	Error     error
 	OutputDir string
 	Timestamp string
+	// Report is what the download produced, URL by URL
+	Report *result.Result
 }
 
 // Snapshot represents a downloaded snapshot.
@@ -53,14 +95,20 @@ type Snapshot struct {
 	Timestamp string
 	URL       string
 	Path      string
+	// Change describes how the snapshot's text differs from the previous snapshot
+	Change simhash.Change
 }
 
-// validateURL checks if a URL is valid and uses either HTTP or HTTPS scheme.
-func validateURL(rawURL string) error {
-	parsedURL, err := url.Parse(rawURL)
+// validateURL checks if a URL is valid and uses either HTTP or HTTPS scheme,
+// IPFS or IPNS, or FTP or Gopher when legacy protocols are allowed.
+func validateURL(rawURL string, allowLegacy bool) error {
+	parsedURL, err := neturl.Parse(rawURL)
 	if err != nil {
 		return fmt.Errorf("invalid URL format: %w", err)
 	}
+	if allowLegacy && legacy.Supports(parsedURL.Scheme) || ipfs.Supports(parsedURL.Scheme) {
+		return nil
+	}
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
 		return fmt.Errorf("URL must use http or https scheme")
 	}
@@ -69,8 +117,10 @@ func validateURL(rawURL string) error {
 
 // getDomain extracts the domain name from a URL.
 func getDomain(url string) string {
-	domain := strings.TrimPrefix(url, "http://")
-	domain = strings.TrimPrefix(domain, "https://")
+	domain := url
+	if _, rest, found := strings.Cut(url, "://"); found {
+		domain = rest
+	}
 
 	if idx := strings.Index(domain, "/"); idx != -1 {
 		domain = domain[:idx]
@@ -103,41 +153,30 @@ func parseCDXResponse(rawResponse [][]string) ([]CDXResponse, error) {
 
 // getCDXSnapshots retrieves snapshots for a given URL from the Wayback Machine's CDX API.
 func getCDXSnapshots(ctx context.Context, url string, cfg *config.Config) ([]CDXResponse, error) {
-	cdxURL := fmt.Sprintf("%s?url=%s&output=json&fl=timestamp,original,mimetype,status,digest,length", cfg.WaybackAPIURL, url)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", cdxURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create CDX request: %w", err)
-	}
+	params := neturl.Values{}
+	params.Set("url", url)
+	params.Set("fl", "timestamp,original,mimetype,status,digest,length")
 
-	client := &http.Client{Timeout: cfg.HTTPTimeout}
-	resp, err := client.Do(req)
+	rawResponse, err := queryCDX(ctx, cfg, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch CDX data: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CDX response: %w", err)
-	}
-
-	var rawResponse [][]string
-	if err := json.Unmarshal(body, &rawResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse CDX response: %w", err)
+		return nil, err
 	}
 
 	return parseCDXResponse(rawResponse)
 }
 
-// tryConvertImage attempts to convert and resize an image to PNG format
+// tryConvertImage scales an image to the PNG illustration of a ZIM file
 func tryConvertImage(srcPath, domainDir string) (string, error) {
-	pngPath := filepath.Join(domainDir, pkg.IllustrationPNG)
-	cmd := exec.Command(pkg.ConvertCmd, srcPath, pkg.ResizeFlag, pkg.ResizeSize, pngPath) // #nosec G204 - convert args are validated
-	if err := cmd.Run(); err != nil {
-		return pkg.EmptyString, err
+	f, err := os.Open(srcPath) // #nosec G304 - srcPath is an image found in the capture directory
+	if err != nil {
+		return pkg.EmptyString, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return pkg.EmptyString, fmt.Errorf("failed to decode %s: %w", srcPath, err)
 	}
-	return filepath.Rel(domainDir, pngPath)
+	return writeIllustration(scaleImage(src, pkg.IllustrationSize), domainDir)
 }
 
 // findImageInPatterns searches for images matching the given patterns in the domain directory
@@ -152,48 +191,62 @@ func findImageInPatterns(domainDir string, patterns []string) (string, error) {
 	return pkg.EmptyString, fmt.Errorf("no images found matching patterns")
 }
 
-// convertDefaultImage converts the default image to the required format
+// convertDefaultImage writes the default image, default.png in the working
+// directory or the embedded one, as the illustration
 func convertDefaultImage(domainDir string) (string, error) {
-	defaultSrc := pkg.DefaultPNG
-	defaultDst := filepath.Join(domainDir, pkg.IllustrationPNG)
-
-	// If default.png exists on disk, copy it to the domainDir
-	if _, err := os.Stat(pkg.DefaultPNG); err == nil {
-		srcFile, err := os.Open(defaultSrc) // #nosec G304 - defaultSrc is a constant
-		if err != nil {
-			return pkg.EmptyString, fmt.Errorf("failed to open source default.png: %w", err)
-		}
-		defer srcFile.Close()
-
-		dstFile, err := os.Create(filepath.Join(domainDir, pkg.DefaultPNG)) // #nosec G304 - domainDir is controlled, pkg.DefaultPNG is a constant
-		if err != nil {
-			return pkg.EmptyString, fmt.Errorf("failed to create destination default.png: %w", err)
-		}
-		defer dstFile.Close()
+	data, err := os.ReadFile(pkg.DefaultPNG)
+	if err != nil {
+		data = embeddedDefaultPNG
+	}
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return pkg.EmptyString, fmt.Errorf("failed to decode %s: %w", pkg.DefaultPNG, err)
+	}
+	return writeIllustration(scaleImage(src, pkg.IllustrationSize), domainDir)
+}
 
-		if _, err := io.Copy(dstFile, srcFile); err != nil {
-			return pkg.EmptyString, fmt.Errorf("failed to copy default.png to domain directory: %w", err)
-		}
-	} else if len(embeddedDefaultPNG) > 0 {
-		// If not found on disk, use embedded default.png
-		if err := os.WriteFile(filepath.Join(domainDir, pkg.DefaultPNG), embeddedDefaultPNG, pkg.FilePerms); err != nil {
-			return pkg.EmptyString, fmt.Errorf("failed to write embedded default.png to domain directory: %w", err)
-		}
-	} else {
-		return pkg.EmptyString, fmt.Errorf("no suitable illustration found and %s is not available", pkg.DefaultPNG)
+// writeIllustration writes img as the illustration in domainDir and returns
+// its path relative to domainDir
+func writeIllustration(img image.Image, domainDir string) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return pkg.EmptyString, fmt.Errorf("failed to encode illustration: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(domainDir, pkg.IllustrationPNG), buf.Bytes(), pkg.FilePerms); err != nil {
+		return pkg.EmptyString, fmt.Errorf("failed to write illustration: %w", err)
 	}
+	return pkg.IllustrationPNG, nil
+}
 
-	// Now run convert on the copied/written default.png within the domainDir
-	cmd := exec.Command(pkg.ConvertCmd, filepath.Join(domainDir, pkg.DefaultPNG), pkg.ResizeFlag, pkg.ResizeSize, defaultDst) // #nosec G204 - cmd args are from validated/constant sources
-	slog.Info("Attempting to convert default.png (copied)", "command", cmd.String())
-	if err := cmd.Run(); err != nil {
-		return pkg.EmptyString, fmt.Errorf("failed to convert %s: %w", pkg.DefaultPNG, err)
+// scaleImage returns src scaled to a size by size square, averaging the
+// source pixels each pixel covers. Images of that size are returned as is.
+func scaleImage(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	if b.Dx() == size && b.Dy() == size {
+		return src
+	}
+	dst := image.NewNRGBA64(image.Rect(0, 0, size, size))
+	for y := range size {
+		y0, y1 := b.Min.Y+y*b.Dy()/size, b.Min.Y+max((y+1)*b.Dy()/size, y*b.Dy()/size+1)
+		for x := range size {
+			x0, x1 := b.Min.X+x*b.Dx()/size, b.Min.X+max((x+1)*b.Dx()/size, x*b.Dx()/size+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1 && sy < b.Max.Y; sy++ {
+				for sx := x0; sx < x1 && sx < b.Max.X; sx++ {
+					c := color.NRGBA64Model.Convert(src.At(sx, sy)).(color.NRGBA64)
+					r, g, bl, a, n = r+uint64(c.R), g+uint64(c.G), bl+uint64(c.B), a+uint64(c.A), n+1
+				}
+			}
+			if n > 0 {
+				dst.SetNRGBA64(x, y, color.NRGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)}) // #nosec G115 - averages of 16-bit values
+			}
+		}
 	}
-	return filepath.Rel(domainDir, defaultDst)
+	return dst
 }
 
 // findOrCreateIllustration attempts to find an illustration (image) for a given domain,
-// or creates one from a default image if none is found.
+// or creates one from a default image if none is found or it cannot be decoded.
 func findOrCreateIllustration(outputDir, domain string) (string, error) {
 	domainDir := filepath.Join(outputDir, domain)
 
@@ -206,9 +259,12 @@ func findOrCreateIllustration(outputDir, domain string) (string, error) {
 		"favicon.ico", "favicon.png", "logo.png", "logo.jpg",
 	}
 
-	srcPath, err := findImageInPatterns(domainDir, imagePatterns)
-	if err == nil {
-		return tryConvertImage(srcPath, domainDir)
+	if srcPath, err := findImageInPatterns(domainDir, imagePatterns); err == nil {
+		illustration, err := tryConvertImage(srcPath, domainDir)
+		if err == nil {
+			return illustration, nil
+		}
+		slog.Debug("Using the default illustration", "error", err)
 	}
 
 	return convertDefaultImage(domainDir)
@@ -243,6 +299,10 @@ func createSnapshotSelectionPage(snapshots []Snapshot, outputDir string) error {
             color: #666;
             font-size: 0.9em;
         }
+        .change {
+            color: #886600;
+            font-size: 0.9em;
+        }
         a {
             color: #0066cc;
             text-decoration: none;
@@ -263,13 +323,18 @@ func createSnapshotSelectionPage(snapshots []Snapshot, outputDir string) error {
 `
 
 	for _, snapshot := range snapshots {
+		change := pkg.EmptyString
+		if snapshot.Change != pkg.EmptyString {
+			change = fmt.Sprintf(`
+                <div class="change">%s</div>`, snapshot.Change)
+		}
 		html += fmt.Sprintf(`
         <div class="snapshot">
             <a href="%s/index.html">
                 <strong>Snapshot from %s</strong>
-                <div class="timestamp">%s</div>
+                <div class="timestamp">%s</div>%s
             </a>
-        </div>`, snapshot.Path, snapshot.Timestamp, snapshot.Timestamp)
+        </div>`, snapshot.Path, snapshot.Timestamp, snapshot.Timestamp, change)
 	}
 
 	html += `
@@ -290,7 +355,22 @@ func downloadSnapshot(ctx context.Context, snapshot string, url string, depth in
 // downloadAllSnapshots downloads all available snapshots for a URL
 func downloadAllSnapshots(ctx context.Context, snapshots []CDXResponse, url string, depth int, outputDir string, noJs bool, noCss bool, cfg *config.Config) []Snapshot {
 	var downloadedSnapshots []Snapshot
+	// byDigest maps payload digests to the snapshots downloaded with them
+	byDigest := make(map[string]Snapshot)
 	for _, snapshot := range snapshots {
+		// Only a crawl of the seed alone is identical with an identical seed,
+		// pages it links to may have been captured anew in between
+		if earlier, ok := byDigest[snapshot.Digest]; ok && depth == pkg.ZeroValue {
+			// The archived payload is identical, so the earlier download serves
+			slog.Info("Skipping snapshot identical to an earlier one", pkg.LogTimestamp, snapshot.Timestamp, "sameAs", earlier.Timestamp)
+			downloadedSnapshots = append(downloadedSnapshots, Snapshot{
+				Timestamp: snapshot.Timestamp,
+				URL:       fmt.Sprintf(pkg.WaybackURLFormat, snapshot.Timestamp, url),
+				Path:      earlier.Path,
+			})
+			continue
+		}
+
 		snapshotDir := filepath.Join(outputDir, snapshot.Timestamp)
 		if err := os.MkdirAll(snapshotDir, cfg.DirPerms); err != nil {
 			slog.Warn("Failed to create directory for snapshot", pkg.LogError, err, pkg.LogTimestamp, snapshot.Timestamp)
@@ -299,60 +379,207 @@ func downloadAllSnapshots(ctx context.Context, snapshots []CDXResponse, url stri
 
 		waybackURL := fmt.Sprintf(pkg.WaybackURLFormat, snapshot.Timestamp, url)
 		if err := downloader.Download(ctx, waybackURL, depth, snapshotDir, noJs, noCss, cfg); err != nil {
-			slog.Warn("Failed to download snapshot", pkg.LogError, err, pkg.LogTimestamp, snapshot.Timestamp)
+			slog.Warn("Failed to download snapshot", pkg.LogError, err, pkg.LogTimestamp, snapshot.Timestamp, fetcherr.Attr(err))
 			continue
 		}
+		verifySnapshot(snapshot, waybackURL, snapshotDir, cfg)
 
-		downloadedSnapshots = append(downloadedSnapshots, Snapshot{
+		downloaded := Snapshot{
 			Timestamp: snapshot.Timestamp,
 			URL:       waybackURL,
 			Path:      snapshot.Timestamp,
-		})
+		}
+		if snapshot.Digest != pkg.EmptyString {
+			byDigest[snapshot.Digest] = downloaded
+		}
+		downloadedSnapshots = append(downloadedSnapshots, downloaded)
 	}
 	return downloadedSnapshots
 }
 
-// createZIMFile creates a ZIM file from the downloaded content
-func createZIMFile(ctx context.Context, outputDir, url string, downloadedSnapshots []Snapshot) error {
+// zimMetadata holds the zimwriterfs settings of a ZIM file
+type zimMetadata struct {
+	Welcome         string
+	Illustration    string
+	Language        string
+	Title           string
+	Name            string
+	Description     string
+	LongDescription string
+	Creator         string
+	Publisher       string
+	// Tags are separated by semicolons
+	Tags          string
+	FullTextIndex bool
+}
+
+// with returns the metadata with every non-empty field of overrides applied
+func (m zimMetadata) with(overrides zimMetadata) zimMetadata {
+	for _, field := range []struct {
+		dst *string
+		src string
+	}{
+		{&m.Welcome, overrides.Welcome},
+		{&m.Illustration, overrides.Illustration},
+		{&m.Language, overrides.Language},
+		{&m.Title, overrides.Title},
+		{&m.Name, overrides.Name},
+		{&m.Description, overrides.Description},
+		{&m.LongDescription, overrides.LongDescription},
+		{&m.Creator, overrides.Creator},
+		{&m.Publisher, overrides.Publisher},
+		{&m.Tags, overrides.Tags},
+	} {
+		if field.src != pkg.EmptyString {
+			*field.dst = field.src
+		}
+	}
+	m.FullTextIndex = m.FullTextIndex || overrides.FullTextIndex
+	return m
+}
+
+// zimIllustration finds or creates the illustration of the capture of url in
+// outputDir and returns its path relative to outputDir
+func zimIllustration(outputDir, url string) (string, error) {
+	domain := getDomain(url)
+	illustrationRelPath, err := findOrCreateIllustration(outputDir, domain)
+	if err != nil {
+		return pkg.EmptyString, fmt.Errorf("failed to find or create illustration: %w", err)
+	}
+	return filepath.Join(domain, illustrationRelPath), nil
+}
+
+// defaultZIMMetadata returns the metadata of a ZIM file of the capture
+// info describes, without an illustration. The note of the capture becomes
+// the long description.
+func defaultZIMMetadata(info captureInfo) zimMetadata {
+	url, snapshots := info.URL, info.Snapshots
+	domain := getDomain(url)
+	description := fmt.Sprintf("Archive of %s", url)
+	longDescription := fmt.Sprintf("Offline archive of %s created with website-archiver", url)
+	if snapshots > pkg.OneLength {
+		description += fmt.Sprintf(" with %d snapshots", snapshots)
+		longDescription += fmt.Sprintf(". Contains %d snapshots.", snapshots)
+	}
+	if info.Note != pkg.EmptyString {
+		longDescription = info.Note
+	}
+	return zimMetadata{
+		Tags:            strings.Join(info.Tags, ";"),
+		Welcome:         pkg.IndexHTML,
+		Language:        "eng",
+		Title:           domain,
+		Name:            domain,
+		Description:     description,
+		LongDescription: longDescription,
+		Creator:         "website-archiver",
+		Publisher:       "website-archiver",
+	}
+}
+
+// createZIMFile creates a ZIM file from the downloaded content and returns its path
+func createZIMFile(ctx context.Context, outputDir, url string, downloadedSnapshots []Snapshot, engine string) (string, error) {
 	currentDate := time.Now().Format("20060102")
 	zimFile := filepath.Join(filepath.Dir(outputDir), fmt.Sprintf("%s_%s.zim", getDomain(url), currentDate))
 	slog.Info("Creating ZIM file", "file", zimFile)
 
-	domain := getDomain(url)
-	illustrationRelPath, err := findOrCreateIllustration(outputDir, domain)
+	info, err := describeCapture(outputDir)
 	if err != nil {
-		return fmt.Errorf("failed to find or create illustration: %w", err)
-	}
-
-	// Determine the HTML directory and relative paths for zimwriterfs
-	htmlDir := outputDir
-	welcomePage := pkg.IndexHTML
-	illustration := filepath.Join(domain, illustrationRelPath) // This needs to be relative to htmlDir (outputDir)
-
-	cmd := exec.CommandContext(ctx, "zimwriterfs", // #nosec G204 - zimwriterfs args are validated
-		"--welcome", welcomePage,
-		"--illustration", illustration,
-		"--language", "eng",
-		"--title", domain,
-		"--name", domain,
-		"--description", fmt.Sprintf("Archive of %s%s", url, func() string {
-			if len(downloadedSnapshots) > pkg.OneLength {
-				return fmt.Sprintf(" with %d snapshots", len(downloadedSnapshots))
-			}
-			return pkg.EmptyString
-		}()),
-		"--longDescription", fmt.Sprintf("Offline archive of %s created with website-archiver%s", url, func() string {
-			if len(downloadedSnapshots) > pkg.OneLength {
-				return fmt.Sprintf(". Contains %d snapshots.", len(downloadedSnapshots))
-			}
-			return pkg.EmptyString
-		}()),
-		"--creator", "website-archiver",
-		"--publisher", "website-archiver",
-		"--withoutFTIndex",
-		htmlDir, // The directory relative to which welcome/illustration paths are resolved
-		zimFile,
-	)
+		return pkg.EmptyString, err
+	}
+	// The seed and number of snapshots of the run take precedence over what
+	// the manifests of Wayback snapshots say
+	info.URL, info.Snapshots = url, len(downloadedSnapshots)
+	meta := defaultZIMMetadata(info)
+	illustration, err := zimIllustration(outputDir, url)
+	if err != nil {
+		return pkg.EmptyString, err
+	}
+	meta.Illustration = illustration // This needs to be relative to htmlDir (outputDir)
+	if err := writeZIM(ctx, outputDir, zimFile, meta, engine); err != nil {
+		return pkg.EmptyString, err
+	}
+	return zimFile, nil
+}
+
+// writeZIM writes the ZIM file of htmlDir, the directory relative to which
+// the welcome page and illustration are resolved, with the engine named.
+// The redirect pages of the capture become redirect entries.
+func writeZIM(ctx context.Context, htmlDir, zimFile string, meta zimMetadata, engine string) error {
+	redirects, err := zim.Redirects(htmlDir)
+	if err != nil {
+		return err
+	}
+	if len(redirects) > pkg.ZeroLength {
+		slog.Info("Adding redirect entries", "count", len(redirects))
+	}
+	if engine == config.ZIMEngineExternal {
+		return writeZIMExternal(ctx, htmlDir, zimFile, meta, redirects)
+	}
+	if meta.FullTextIndex {
+		slog.Warn("The native ZIM writer builds no full-text index; use --zim-engine external for one")
+	}
+	err = zim.Write(ctx, htmlDir, zimFile, zim.Metadata{
+		Welcome:         meta.Welcome,
+		Illustration:    meta.Illustration,
+		Language:        meta.Language,
+		Title:           meta.Title,
+		Name:            meta.Name,
+		Description:     meta.Description,
+		LongDescription: meta.LongDescription,
+		Creator:         meta.Creator,
+		Publisher:       meta.Publisher,
+		Tags:            meta.Tags,
+	}, redirects, pkg.FilePerms)
+	if err != nil {
+		return fmt.Errorf("failed to create ZIM file: %w", err)
+	}
+	return nil
+}
+
+// writeZIMExternal runs zimwriterfs on htmlDir, with the redirect pages set
+// aside so it adds redirect entries at their paths instead
+func writeZIMExternal(ctx context.Context, htmlDir, zimFile string, meta zimMetadata, redirects []zim.Redirect) (err error) {
+	args := []string{
+		"--welcome", meta.Welcome,
+		"--illustration", meta.Illustration,
+		"--language", meta.Language,
+		"--title", meta.Title,
+		"--name", meta.Name,
+		"--description", meta.Description,
+		"--longDescription", meta.LongDescription,
+		"--creator", meta.Creator,
+		"--publisher", meta.Publisher,
+	}
+	if meta.Tags != pkg.EmptyString {
+		args = append(args, "--tags", meta.Tags)
+	}
+	if !meta.FullTextIndex {
+		args = append(args, "--withoutFTIndex")
+	}
+
+	if len(redirects) > pkg.ZeroLength {
+		aside, err := os.MkdirTemp(filepath.Dir(filepath.Clean(htmlDir)), ".zim-redirects-")
+		if err != nil {
+			return fmt.Errorf("failed to create redirects directory: %w", err)
+		}
+		defer os.RemoveAll(aside)
+		redirectsFile := filepath.Join(aside, "redirects.tsv")
+		if err := zim.WriteRedirects(redirectsFile, redirects, pkg.FilePerms); err != nil {
+			return err
+		}
+		restore, err := zim.SetAside(htmlDir, filepath.Join(aside, "pages"), redirects, pkg.DirPerms)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, restore())
+		}()
+		args = append(args, "--redirects", redirectsFile)
+	}
+	args = append(args, htmlDir, zimFile)
+
+	cmd := exec.CommandContext(ctx, "zimwriterfs", args...) // #nosec G204 - zimwriterfs args are validated
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -362,6 +589,83 @@ func createZIMFile(ctx context.Context, outputDir, url string, downloadedSnapsho
 	return nil
 }
 
+// createTorrent writes the torrent of the archive at path, a ZIM file or a
+// capture directory, next to it
+func createTorrent(path string, cfg *config.Config) error {
+	meta, err := torrent.Create(path, torrent.Options{
+		Trackers:  cfg.TorrentTrackers,
+		WebSeeds:  cfg.TorrentWebSeeds,
+		Comment:   cfg.Note,
+		CreatedBy: "website-archiver",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create torrent: %w", err)
+	}
+	torrentFile := path + torrent.Ext
+	if err := meta.Write(torrentFile, cfg.FilePerms); err != nil {
+		return err
+	}
+	slog.Info("Created torrent", "file", torrentFile, "pieces", meta.PieceCount(), "magnet", meta.Magnet())
+	return nil
+}
+
+// archivedPaths lists the files below dir as slash-separated relative paths
+func archivedPaths(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	return paths, err
+}
+
+// finalizeZIMFile validates a freshly built ZIM file against the downloaded
+// content, creates its torrent when asked, and splits it into parts when a
+// maximum size is configured. The files are recorded in report, which may be
+// nil.
+func finalizeZIMFile(zimFile, outputDir string, report *result.Result, cfg *config.Config) error {
+	paths, err := archivedPaths(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to list archived files: %w", err)
+	}
+	if err := zim.Validate(zimFile, zim.Expectation{Paths: paths, SampleSize: pkg.ZIMSampleSize}); err != nil {
+		return fmt.Errorf("ZIM file %s failed validation: %w", zimFile, err)
+	}
+	slog.Info("Validated ZIM file", "file", zimFile, "files", len(paths))
+
+	if cfg.Torrent {
+		// The torrent is of the whole file; seeding reads it through its parts
+		if err := createTorrent(zimFile, cfg); err != nil {
+			slog.Warn("Failed to create torrent", pkg.LogError, err, "file", zimFile)
+			report.Warn("torrent", err)
+		} else {
+			report.AddOutput(result.Torrent, zimFile+torrent.Ext)
+		}
+	}
+
+	parts := []string{zimFile}
+	if cfg.ZIMMaxSize > pkg.ZeroValue {
+		parts, err = zim.Split(zimFile, cfg.ZIMMaxSize, cfg.FilePerms)
+		if err != nil {
+			return fmt.Errorf("failed to split ZIM file: %w", err)
+		}
+		if len(parts) > pkg.OneLength {
+			slog.Info("Split ZIM file", "file", zimFile, "parts", len(parts), "maxSize", cfg.ZIMMaxSize)
+		}
+	}
+	for _, part := range parts {
+		report.AddOutput(result.ZIM, part)
+	}
+	return nil
+}
+
 // downloadCurrentVersion attempts to download the current version of a URL
 func downloadCurrentVersion(ctx context.Context, url string, depth int, outputDir string, noJs bool, noCss bool, cfg *config.Config) ([]Snapshot, error) {
 	if err := downloader.Download(ctx, url, depth, outputDir, noJs, noCss, cfg); err != nil {
@@ -375,7 +679,7 @@ func downloadCurrentVersion(ctx context.Context, url string, depth int, outputDi
 }
 
 // downloadArchivedVersion downloads an archived version of a URL
-func downloadArchivedVersion(ctx context.Context, url string, depth int, allSnapshots bool, noJs bool, noCss bool, cfg *config.Config) ([]Snapshot, error) {
+func downloadArchivedVersion(ctx context.Context, url string, depth int, outputDir string, allSnapshots bool, noJs bool, noCss bool, cfg *config.Config) ([]Snapshot, error) {
 	snapshots, err := getCDXSnapshots(ctx, url, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get snapshots: %w", err)
@@ -387,15 +691,16 @@ func downloadArchivedVersion(ctx context.Context, url string, depth int, allSnap
 
 	if allSnapshots {
 		slog.Info("Found archived versions", "count", len(snapshots), pkg.LogURL, url)
-		return downloadAllSnapshots(ctx, snapshots, url, depth, cfg.OutputDir, noJs, noCss, cfg), nil
+		return downloadAllSnapshots(ctx, snapshots, url, depth, outputDir, noJs, noCss, cfg), nil
 	}
 
 	waybackURL := fmt.Sprintf(pkg.WaybackURLFormat, snapshots[pkg.FirstIndex].Timestamp, url)
 	slog.Info("Downloading most recent archived version", pkg.LogTimestamp, snapshots[pkg.FirstIndex].Timestamp, pkg.LogURL, url)
 
-	if err := downloader.Download(ctx, waybackURL, depth, cfg.OutputDir, noJs, noCss, cfg); err != nil {
+	if err := downloader.Download(ctx, waybackURL, depth, outputDir, noJs, noCss, cfg); err != nil {
 		return nil, fmt.Errorf("failed to download archived version: %w", err)
 	}
+	verifySnapshot(snapshots[pkg.FirstIndex], waybackURL, outputDir, cfg)
 
 	return []Snapshot{{
 		Timestamp: snapshots[pkg.FirstIndex].Timestamp,
@@ -407,7 +712,7 @@ func downloadArchivedVersion(ctx context.Context, url string, depth int, allSnap
 // handleSpecificSnapshot handles downloading a specific snapshot
 func handleSpecificSnapshot(ctx context.Context, specificSnapshot, url string, depth int, outputDir string, noJs, noCss bool, cfg *config.Config) ([]Snapshot, error) {
 	if err := downloadSnapshot(ctx, specificSnapshot, url, depth, outputDir, noJs, noCss, cfg); err != nil {
-		slog.Error("Failed to download snapshot", pkg.LogError, err, pkg.LogURL, url)
+		slog.Error("Failed to download snapshot", pkg.LogError, err, pkg.LogURL, url, fetcherr.Attr(err))
 		return nil, fmt.Errorf("failed to download snapshot: %w", err)
 	}
 
@@ -422,11 +727,14 @@ func handleSpecificSnapshot(ctx context.Context, specificSnapshot, url string, d
 func handleCurrentOrArchivedVersion(ctx context.Context, url string, depth int, outputDir string, allSnapshots bool, noJs, noCss bool, cfg *config.Config) ([]Snapshot, error) {
 	slog.Info("Attempting direct download", pkg.LogURL, url)
 	downloadedSnapshots, err := downloadCurrentVersion(ctx, url, depth, outputDir, noJs, noCss, cfg)
+	if err != nil && (errors.Is(err, downloader.ErrInterrupted) || cfg.Tor || !hasWaybackCaptures(url)) {
+		return nil, err
+	}
 	if err != nil {
-		slog.Warn("Direct download failed, attempting archived versions", pkg.LogError, err, pkg.LogURL, url)
-		downloadedSnapshots, err = downloadArchivedVersion(ctx, url, depth, allSnapshots, noJs, noCss, cfg)
+		slog.Warn("Direct download failed, attempting archived versions", pkg.LogError, err, pkg.LogURL, url, fetcherr.Attr(err))
+		downloadedSnapshots, err = downloadArchivedVersion(ctx, url, depth, outputDir, allSnapshots, noJs, noCss, cfg)
 		if err != nil {
-			slog.Error("Failed to download archived version", pkg.LogError, err, pkg.LogURL, url)
+			slog.Error("Failed to download archived version", pkg.LogError, err, pkg.LogURL, url, fetcherr.Attr(err))
 			return nil, err
 		}
 	}
@@ -435,6 +743,11 @@ func handleCurrentOrArchivedVersion(ctx context.Context, url string, depth int,
 
 // handleDownloadResult handles the result of a download attempt
 func handleDownloadResult(url, outputDir string, err error, results chan<- DownloadResult) {
+	if errors.Is(err, downloader.ErrInterrupted) {
+		// Keep the capture so its crawl can be resumed
+		results <- DownloadResult{URL: url, Error: err, OutputDir: outputDir}
+		return
+	}
 	if err != nil {
 		results <- DownloadResult{URL: url, Error: err}
 		if removeErr := os.RemoveAll(outputDir); removeErr != nil {
@@ -445,32 +758,207 @@ func handleDownloadResult(url, outputDir string, err error, results chan<- Downl
 	results <- DownloadResult{URL: url, OutputDir: outputDir}
 }
 
-// handlePostDownloadTasks handles tasks after successful download
-func handlePostDownloadTasks(ctx context.Context, downloadedSnapshots []Snapshot, outputDir, url string, createZim bool) {
+// addTimeTravel links every page of a multi-snapshot archive to the same page
+// in the other snapshots.
+func addTimeTravel(snapshots []Snapshot, outputDir string, report *result.Result, cfg *config.Config) {
+	var list []timetravel.Snapshot
+	for _, s := range snapshots {
+		label := s.Timestamp
+		if t, err := time.Parse(catalog.TimestampFormat, s.Timestamp); err == nil {
+			label = t.Format(time.DateOnly)
+		}
+		list = append(list, timetravel.Snapshot{Dir: s.Path, Label: label})
+	}
+	pages, err := timetravel.Add(outputDir, list, pkg.IndexHTML, cfg.FilePerms)
+	if err != nil {
+		slog.Warn("Failed to add snapshot navigation", pkg.LogError, err)
+		report.Warn("time-travel", err)
+		return
+	}
+	slog.Info("Added snapshot navigation", "pages", pages, "snapshots", len(list))
+}
+
+// createSingleFiles writes a self-contained copy in format of every page of
+// the capture in outputDir to outputDir.single, or outputDir.mhtml.
+func createSingleFiles(outputDir, format string, report *result.Result, cfg *config.Config) {
+	dest, kind := filepath.Clean(outputDir)+".single", result.Single
+	if format == singlefile.MHTML {
+		dest, kind = filepath.Clean(outputDir)+".mhtml", result.MHTML
+	}
+	pages, err := singlefile.Export(outputDir, dest, format, cfg.DirPerms, cfg.FilePerms)
+	if err != nil {
+		slog.Warn("Failed to create single-file pages", pkg.LogError, err, "dir", dest, "format", format)
+		report.Warn(kind, err)
+		return
+	}
+	slog.Info("Created single-file pages", "dir", dest, "format", format, "pages", pages)
+	report.AddOutput(kind, dest)
+}
+
+// createPDF prints every page of the capture in outputDir to PDF and merges
+// them into one PDF next to it.
+func createPDF(ctx context.Context, outputDir string, report *result.Result, cfg *config.Config) {
+	dest := filepath.Clean(outputDir) + ".pdfs"
+	merged := filepath.Clean(outputDir) + ".pdf"
+	opts := pdf.Options{Index: pkg.IndexHTML, DirPerms: cfg.DirPerms, FilePerms: cfg.FilePerms}
+	pages, err := pdf.Export(ctx, outputDir, dest, merged, opts)
+	if err != nil {
+		slog.Warn("Failed to create PDF", pkg.LogError, err, "file", merged)
+		report.Warn(result.PDF, err)
+		return
+	}
+	slog.Info("Created PDF", "file", merged, "dir", dest, "pages", pages)
+	report.AddOutput(result.PDF, merged)
+}
+
+// createEPUB writes the capture in outputDir as an EPUB book next to it, or
+// one book per snapshot of a multi-snapshot archive.
+func createEPUB(outputDir string, snapshots []Snapshot, report *result.Result, cfg *config.Config) {
+	books := map[string]string{outputDir: filepath.Clean(outputDir) + ".epub"}
+	if len(snapshots) > pkg.OneLength {
+		books = make(map[string]string, len(snapshots))
+		for _, s := range snapshots {
+			books[filepath.Join(outputDir, s.Path)] = filepath.Clean(outputDir) + "_" + s.Timestamp + ".epub"
+		}
+	}
+	opts := epub.Options{Readable: cfg.EPUBReadable, FilePerms: cfg.FilePerms}
+	for dir, book := range books {
+		chapters, err := epub.Export(dir, book, opts)
+		if err != nil {
+			slog.Warn("Failed to create EPUB", pkg.LogError, err, "file", book)
+			report.Warn(result.EPUB, err)
+			continue
+		}
+		slog.Info("Created EPUB", "file", book, "chapters", chapters, "readable", cfg.EPUBReadable)
+		report.AddOutput(result.EPUB, book)
+	}
+}
+
+// handlePostDownloadTasks handles tasks after successful download, recording
+// the files they create in report. It only returns an error when a ZIM file
+// was built but turned out to be invalid; the downloaded directory is kept in
+// that case.
+func handlePostDownloadTasks(ctx context.Context, downloadedSnapshots []Snapshot, outputDir, url string, createZim bool, report *result.Result, cfg *config.Config) error {
 	if len(downloadedSnapshots) > pkg.OneLength {
+		detectSnapshotChanges(downloadedSnapshots, outputDir, cfg)
 		if err := createSnapshotSelectionPage(downloadedSnapshots, outputDir); err != nil {
 			slog.Warn("Failed to create selection page", pkg.LogError, err)
+			report.Warn("selection page", err)
+		}
+		if cfg.TimeTravel && !cfg.Fidelity {
+			addTimeTravel(downloadedSnapshots, outputDir, report, cfg)
 		}
 	}
+	// Before the ZIM file, which may remove the capture directory
+	if cfg.SingleFile {
+		createSingleFiles(outputDir, singlefile.HTML, report, cfg)
+	}
+	if cfg.MHTML {
+		createSingleFiles(outputDir, singlefile.MHTML, report, cfg)
+	}
+	if cfg.PDF {
+		createPDF(ctx, outputDir, report, cfg)
+	}
+	if cfg.EPUB || cfg.EPUBReadable {
+		createEPUB(outputDir, downloadedSnapshots, report, cfg)
+	}
 
-	if createZim {
-		if err := createZIMFile(ctx, outputDir, url, downloadedSnapshots); err != nil {
-			slog.Warn("Failed to create ZIM file", pkg.LogError, err)
-		} else {
-			// If ZIM creation succeeds, remove the downloaded directory
-			if err := os.RemoveAll(outputDir); err != nil {
-				slog.Warn("Failed to remove directory after ZIM creation", pkg.LogError, err, "dir", outputDir)
+	if !createZim {
+		if cfg.Torrent {
+			if err := createTorrent(outputDir, cfg); err != nil {
+				slog.Warn("Failed to create torrent", pkg.LogError, err, "dir", outputDir)
+				report.Warn(result.Torrent, err)
+			} else {
+				report.AddOutput(result.Torrent, outputDir+torrent.Ext)
 			}
 		}
+		return nil
+	}
+
+	zimFile, err := createZIMFile(ctx, outputDir, url, downloadedSnapshots, cfg.ZIMEngine)
+	if err != nil {
+		err = fetcherr.Wrap(fetcherr.StageZIM, url, err)
+		slog.Warn("Failed to create ZIM file", pkg.LogError, err, fetcherr.Attr(err))
+		report.Warn(result.ZIM, err)
+		return nil
+	}
+	if err := finalizeZIMFile(zimFile, outputDir, report, cfg); err != nil {
+		err = fetcherr.Wrap(fetcherr.StageZIM, url, err)
+		slog.Error("Invalid ZIM file", pkg.LogError, err, "file", zimFile, fetcherr.Attr(err))
+		return err
+	}
+
+	// If ZIM creation succeeds, remove the downloaded directory unless it is
+	// kept for rebuilding the ZIM later or updating it again
+	if cfg.KeepRaw || cfg.Update != pkg.EmptyString {
+		return nil
+	}
+	if err := os.RemoveAll(outputDir); err != nil {
+		slog.Warn("Failed to remove directory after ZIM creation", pkg.LogError, err, "dir", outputDir)
+	}
+	return nil
+}
+
+// recordOutputs records the capture directory and WARC file of res that are
+// left on disk in report.
+func recordOutputs(report *result.Result, res DownloadResult, cfg *config.Config) {
+	if res.OutputDir == pkg.EmptyString || cfg.StorageURL != pkg.EmptyString {
+		return
+	}
+	if info, err := os.Stat(res.OutputDir); err == nil && info.IsDir() {
+		report.AddOutput(result.Capture, res.OutputDir)
+	}
+	if cfg.WARC {
+		if _, err := os.Stat(downloader.WARCFile(res.OutputDir)); err == nil {
+			report.AddOutput(result.WARC, downloader.WARCFile(res.OutputDir))
+		}
 	}
 }
 
 // processURL downloads a URL, either directly or from the Wayback Machine, and optionally creates a ZIM file.
-func processURL(ctx context.Context, url string, depth int, createZim bool, allSnapshots bool, specificSnapshot string, noJs bool, noCss bool, results chan<- DownloadResult, cfg *config.Config) {
+func processURL(ctx context.Context, url string, depth int, createZim bool, allSnapshots bool, specificSnapshot string, noJs bool, noCss bool, out chan<- DownloadResult, cfg *config.Config) {
+	// The report follows the crawl through its progress events
+	report := result.New(url)
+	urlCfg, next := *cfg, cfg.Progress
+	urlCfg.Progress = func(e progress.Event) {
+		report.Observe(e)
+		if next != nil {
+			next(e)
+		}
+	}
+	cfg = &urlCfg
+	// Every way out below sends one result, which gets the report
+	results := make(chan DownloadResult, pkg.OneLength)
+	defer func() {
+		res := <-results
+		recordOutputs(report, res, cfg)
+		res.Report = report.Finish(res.OutputDir, res.Error)
+		out <- res
+	}()
+
 	timestampStr := time.Now().Format("20060102_150405")
 	outputDir := filepath.Join(cfg.OutputDir, getDomain(url)+"_"+timestampStr)
+	finish := handleDownloadResult
+	if cfg.WaybackContinue != pkg.EmptyString || cfg.Resume != pkg.EmptyString || cfg.Update != pkg.EmptyString {
+		// Continue into the capture that stopped or is updated, which must
+		// survive errors
+		outputDir = cfg.WaybackContinue
+		if cfg.Resume != pkg.EmptyString {
+			outputDir = cfg.Resume
+		} else if cfg.Update != pkg.EmptyString {
+			outputDir = cfg.Update
+		}
+		finish = func(url, outputDir string, err error, results chan<- DownloadResult) {
+			results <- DownloadResult{URL: url, Error: err, OutputDir: outputDir}
+		}
+	}
 
-	if err := os.MkdirAll(outputDir, cfg.DirPerms); err != nil {
+	if cfg.StorageURL != pkg.EmptyString {
+		// The capture lives in remote storage, there is no local directory to clean up
+		finish = func(url, outputDir string, err error, results chan<- DownloadResult) {
+			results <- DownloadResult{URL: url, Error: err, OutputDir: outputDir}
+		}
+	} else if err := os.MkdirAll(outputDir, cfg.DirPerms); err != nil {
 		slog.Error("Failed to create output directory", pkg.LogError, err, pkg.LogURL, url)
 		results <- DownloadResult{URL: url, Error: fmt.Errorf("failed to create output directory: %w", err)}
 		return
@@ -479,6 +967,11 @@ func processURL(ctx context.Context, url string, depth int, createZim bool, allS
 	var downloadedSnapshots []Snapshot
 	var err error
 
+	if !hasWaybackCaptures(url) && (specificSnapshot != pkg.EmptyString || allSnapshots) {
+		finish(url, outputDir, errNoWaybackCaptures, results)
+		return
+	}
+
 	if specificSnapshot != pkg.EmptyString {
 		downloadedSnapshots, err = handleSpecificSnapshot(ctx, specificSnapshot, url, depth, outputDir, noJs, noCss, cfg)
 	} else {
@@ -486,29 +979,275 @@ func processURL(ctx context.Context, url string, depth int, createZim bool, allS
 	}
 
 	if err != nil {
-		handleDownloadResult(url, outputDir, err, results)
+		finish(url, outputDir, err, results)
+		return
+	}
+
+	if err := handlePostDownloadTasks(ctx, downloadedSnapshots, outputDir, url, createZim, report, cfg); err != nil {
+		results <- DownloadResult{URL: url, Error: err, OutputDir: outputDir}
 		return
 	}
+	if cfg.ChunkThreshold > pkg.ZeroValue {
+		if err := chunkCapture(cfg, outputDir, cfg.ChunkThreshold); err != nil {
+			results <- DownloadResult{URL: url, Error: fetcherr.Wrap(fetcherr.StagePackage, url, err), OutputDir: outputDir}
+			return
+		}
+	}
+	if cfg.Hardlink {
+		if err := linkCapture(cfg, outputDir); err != nil {
+			results <- DownloadResult{URL: url, Error: fetcherr.Wrap(fetcherr.StagePackage, url, err), OutputDir: outputDir}
+			return
+		}
+	}
+	finish(url, outputDir, nil, results)
+}
 
-	handlePostDownloadTasks(ctx, downloadedSnapshots, outputDir, url, createZim)
-	handleDownloadResult(url, outputDir, nil, results)
+// runOptions holds the flags of a download run that are not part of the
+// configuration
+type runOptions struct {
+	createZim        bool
+	allSnapshots     bool
+	specificSnapshot string
+	noJs             bool
+	noCss            bool
 }
 
-// validateAndParseArgs validates URLs and parses command line arguments
-func validateAndParseArgs() (urls []string, depth int, createZim bool, allSnapshots bool, specificSnapshot string, noJs bool, noCss bool, err error) {
-	flag.BoolVar(&createZim, "zim", false, "Create ZIM file from downloaded content")
-	flag.BoolVar(&createZim, "z", false, "Create ZIM file from downloaded content (shorthand)")
-	flag.BoolVar(&allSnapshots, "all-snapshots", false, "Download all available snapshots")
-	flag.BoolVar(&allSnapshots, "as", false, "Download all available snapshots (shorthand)")
-	flag.StringVar(&specificSnapshot, "snapshot", pkg.EmptyString, "Download a specific snapshot (format: YYYYMMDDHHMMSS)")
-	flag.StringVar(&specificSnapshot, "s", pkg.EmptyString, "Download a specific snapshot (format: YYYYMMDDHHMMSS) (shorthand)")
+// runFlags registers the per-run download flags on fs
+func runFlags(fs *flag.FlagSet, opts *runOptions) {
+	fs.BoolVar(&opts.createZim, "zim", false, "Create ZIM file from downloaded content")
+	fs.BoolVar(&opts.createZim, "z", false, "Create ZIM file from downloaded content (shorthand)")
+	fs.BoolVar(&opts.allSnapshots, "all-snapshots", false, "Download all available snapshots")
+	fs.BoolVar(&opts.allSnapshots, "as", false, "Download all available snapshots (shorthand)")
+	fs.StringVar(&opts.specificSnapshot, "snapshot", pkg.EmptyString, "Download a specific snapshot (format: YYYYMMDDHHMMSS)")
+	fs.StringVar(&opts.specificSnapshot, "s", pkg.EmptyString, "Download a specific snapshot (format: YYYYMMDDHHMMSS) (shorthand)")
+
+	fs.BoolVar(&opts.noJs, "no-js", false, "Do not embed JavaScript in HTML")
+	fs.BoolVar(&opts.noCss, "no-css", false, "Do not embed CSS in HTML")
+}
 
-	flag.BoolVar(&noJs, "no-js", false, "Do not embed JavaScript in HTML")
-	flag.BoolVar(&noCss, "no-css", false, "Do not embed CSS in HTML")
+// torFlags registers the Tor flags of download runs on fs
+func torFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.BoolVar(&cfg.Tor, "tor", cfg.Tor, "Route every request through the Tor SOCKS5 proxy at --tor-proxy, accept bare .onion seeds, and skip the Wayback Machine")
+	fs.StringVar(&cfg.TorProxy, "tor-proxy", cfg.TorProxy, "Address of the Tor SOCKS5 proxy used with --tor")
+}
+
+// debugFlags registers the diagnostics flag of long-running modes on fs
+func debugFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.DebugAddr, "debug-addr", cfg.DebugAddr, "Serve pprof profiles and expvar variables on this address, e.g. 127.0.0.1:6060")
+}
 
-	flag.Parse()
+// diagnosticsStarted is set once the diagnostics endpoints are served
+var diagnosticsStarted bool
+
+// startDiagnostics serves the diagnostics endpoints when an address is
+// configured. It runs once; later calls do nothing.
+func startDiagnostics(cfg *config.Config) error {
+	if cfg.DebugAddr == pkg.EmptyString || diagnosticsStarted {
+		return nil
+	}
+	diagnosticsStarted = true
+	return diag.Listen(cfg.DebugAddr, cfg.HTTPTimeout)
+}
+
+// configFlags registers the flags that override configuration values on fs
+func configFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.IntVar(&cfg.RetryAttempts, "retry-attempts", cfg.RetryAttempts, "Attempts per request before a network error or retryable status is given up on (1 disables retries)")
+	fs.DurationVar(&cfg.RetryBackoff, "retry-backoff", cfg.RetryBackoff, "Backoff before the first retry, doubled for every further one and jittered")
+	fs.IntVar(&cfg.MaxIdleConnsPerHost, "max-idle-conns-per-host", cfg.MaxIdleConnsPerHost, "Idle connections kept alive for each host and reused by later requests")
+	fs.DurationVar(&cfg.TLSHandshakeTimeout, "tls-handshake-timeout", cfg.TLSHandshakeTimeout, "Timeout for TLS handshakes; 0 for none")
+	fs.BoolVar(&cfg.DisableHTTP2, "disable-http2", cfg.DisableHTTP2, "Only speak HTTP/1.1, for servers whose HTTP/2 support is broken")
+	fs.Func("retry-statuses", "Comma-separated response statuses to retry (default 429,500,502,503,504)", func(value string) error {
+		statuses, err := config.ParseIntList(value)
+		cfg.RetryStatuses = statuses
+		return err
+	})
+	fs.BoolVar(&cfg.WordPress, "wordpress", cfg.WordPress, "Capture posts, pages, and media from the WordPress REST API if available")
+	fs.BoolVar(&cfg.WaybackPatch, "wayback-patch", cfg.WaybackPatch, "Fill missing assets of direct downloads from the closest Wayback Machine capture")
+	fs.StringVar(&cfg.PaywallFallback, "paywall-fallback", cfg.PaywallFallback, "Save the newest archive capture of pages served behind a paywall instead: none, wayback, archive.today, or newest of both")
+	fs.Func("wayback-max-bytes", "Stop fetching from the Wayback Machine after this much data in a run (e.g. 5G)", func(value string) error {
+		size, err := config.ParseSize(value)
+		cfg.WaybackMaxBytes = size
+		return err
+	})
+	fs.IntVar(&cfg.WaybackMaxRequests, "wayback-max-requests", cfg.WaybackMaxRequests, "Stop fetching from the Wayback Machine after this many requests in a run")
+	fs.StringVar(&cfg.WaybackContinue, "wayback-continue", cfg.WaybackContinue, "Continue the crawl of a capture directory that stopped at the Wayback Machine quota")
+	fs.StringVar(&cfg.Resume, "resume", cfg.Resume, "Resume the interrupted crawl of a capture directory from its crawl state")
+	fs.StringVar(&cfg.Update, "update", cfg.Update, "Archive the site again into this existing capture directory, revalidating its files with If-None-Match and If-Modified-Since and keeping the unchanged ones")
+	fs.Float64Var(&cfg.CostPerGB, "cost-per-gb", cfg.CostPerGB, "Price per GB of downloaded traffic for the bandwidth cost report")
+	fs.StringVar(&cfg.ZIMEngine, "zim-engine", cfg.ZIMEngine, "ZIM writer: native, or external to run zimwriterfs, which can build a full-text index")
+	fs.BoolVar(&cfg.WARC, "warc", cfg.WARC, "Also record every request and response of the crawl into a WARC 1.1 file next to the capture (<capture>.warc.gz)")
+	fs.BoolVar(&cfg.SingleFile, "single-file", cfg.SingleFile, "Also write every page as one self-contained HTML file with its stylesheets, scripts, images, and fonts inlined (<capture>.single/)")
+	fs.BoolVar(&cfg.MHTML, "mhtml", cfg.MHTML, "Also write every page as an MHTML archive that Chrome and Edge open natively (<capture>.mhtml/)")
+	fs.BoolVar(&cfg.PDF, "pdf", cfg.PDF, "Also print every page to PDF with headless Chromium (<capture>.pdfs/) and merge them into <capture>.pdf with a bookmark per page")
+	fs.BoolVar(&cfg.EPUB, "epub", cfg.EPUB, "Also write the capture as an EPUB book with a chapter per page and a table of contents following the crawl (<capture>.epub)")
+	fs.BoolVar(&cfg.EPUBReadable, "epub-readable", cfg.EPUBReadable, "Write the EPUB book with only the main text of each page, leaving out pages without one (implies --epub)")
+	fs.Func("zim-max-size", "Split ZIM files into zimsplit-compatible parts of at most this size (e.g. 2G for FAT32)", func(value string) error {
+		size, err := config.ParseSize(value)
+		cfg.ZIMMaxSize = size
+		return err
+	})
+	fs.BoolVar(&cfg.SpanHostsAssets, "span-hosts-assets", cfg.SpanHostsAssets, "Also fetch images, stylesheets, scripts, and other requisites from other hosts such as CDNs; pages are still only crawled on the site")
+	fs.Func("asset-host", "With --span-hosts-assets, only fetch requisites from this host and its subdomains; comma-separated (repeatable)", func(value string) error {
+		cfg.AssetHosts = append(cfg.AssetHosts, strings.Split(value, ",")...)
+		return nil
+	})
+	fs.Func("host-alias", "Treat this hostname as another name of the seed's site, such as its www or non-www twin or an old domain: its links are saved as the seed's host, and it is tried when the seed's host does not respond; comma-separated (repeatable)", func(value string) error {
+		cfg.HostAliases = append(cfg.HostAliases, strings.Split(value, ",")...)
+		return nil
+	})
+	fs.Func("include-pattern", "Only crawl discovered pages whose URL matches this regular expression (repeatable; requisites are exempt)", func(value string) error {
+		cfg.IncludePatterns = append(cfg.IncludePatterns, value)
+		return nil
+	})
+	fs.Func("exclude-pattern", "Never crawl discovered URLs matching this regular expression, e.g. '/tag/' or '/search\\?' (repeatable)", func(value string) error {
+		cfg.ExcludePatterns = append(cfg.ExcludePatterns, value)
+		return nil
+	})
+	fs.Func("drop-param", "Drop query parameters matching these comma-separated globs from discovered URLs before deduplicating them, e.g. sessionid,ref (repeatable)", func(value string) error {
+		cfg.DropParams = append(cfg.DropParams, strings.Split(value, ",")...)
+		return nil
+	})
+	fs.BoolVar(&cfg.StripTracking, "strip-tracking", cfg.StripTracking, "Drop tracking query parameters such as utm_* and fbclid from discovered URLs")
+	fs.BoolVar(&cfg.StripTrailingSlash, "strip-trailing-slash", cfg.StripTrailingSlash, "Treat /path/ and /path as the same URL, fetching the latter")
+	fs.Func("tag", "Tag the capture, e.g. research or tax-2025; comma-separated (repeatable)", func(value string) error {
+		cfg.Tags = append(cfg.Tags, value)
+		return nil
+	})
+	fs.StringVar(&cfg.Note, "note", cfg.Note, "Free-text note on why the capture was made, kept in its manifest and ZIM metadata")
+	fs.Func("accept-mime", "Save only responses whose media type matches one of these comma-separated globs, e.g. text/*,image/* (repeatable)", func(value string) error {
+		cfg.AcceptMIME = append(cfg.AcceptMIME, strings.Split(value, ",")...)
+		return nil
+	})
+	fs.Func("reject-mime", "Skip responses whose media type matches one of these comma-separated globs, e.g. video/*,audio/* (repeatable)", func(value string) error {
+		cfg.RejectMIME = append(cfg.RejectMIME, strings.Split(value, ",")...)
+		return nil
+	})
+	fs.Func("max-file-size", "Skip resources larger than this size, by Content-Length or once the download passes it (e.g. 500M)", func(value string) error {
+		size, err := config.ParseSize(value)
+		cfg.MaxFileSize = size
+		return err
+	})
+	fs.StringVar(&cfg.ErrorPages, "error-pages", cfg.ErrorPages, "What to do with error responses such as 404, 410, and 500: fail, save (keep the error page verbatim), report (list it in .website-archiver/error-pages.json), or skip")
+	fs.Func("error-status", "Apply --error-pages only to these comma-separated statuses, e.g. 404,410 (repeatable; default every 4xx and 5xx)", func(value string) error {
+		cfg.ErrorStatuses = append(cfg.ErrorStatuses, strings.Split(value, ",")...)
+		return nil
+	})
+	fs.Func("listing-max-file-size", "Skip files in directory listings larger than this size (e.g. 100M)", func(value string) error {
+		size, err := config.ParseSize(value)
+		cfg.ListingMaxFileSize = size
+		return err
+	})
+	fs.Func("listing-max-total-size", "Stop fetching files from directory listings after this much data (e.g. 10G)", func(value string) error {
+		size, err := config.ParseSize(value)
+		cfg.ListingMaxTotalSize = size
+		return err
+	})
+	fs.IntVar(&cfg.ParseWorkers, "parse-workers", cfg.ParseWorkers, "Number of workers parsing and rewriting HTML pages (0 = one per CPU)")
+	fs.IntVar(&cfg.ParseQueue, "parse-queue", cfg.ParseQueue, "Number of fetched pages that may wait for a parse worker")
+	fs.BoolVar(&cfg.VisitedBloom, "visited-bloom", cfg.VisitedBloom, "Keep the visited URL set on disk behind a Bloom filter for crawls of millions of URLs")
+	fs.IntVar(&cfg.VisitedExpected, "visited-expected", cfg.VisitedExpected, "Number of URLs the on-disk visited set is sized for")
+	fs.Float64Var(&cfg.VisitedFPRate, "visited-fp-rate", cfg.VisitedFPRate, "False-positive rate of the visited set Bloom filter")
+	fs.StringVar(&cfg.CDXCacheDir, "cdx-cache-dir", cfg.CDXCacheDir, "Directory of cached CDX API responses (default: the user cache directory)")
+	fs.DurationVar(&cfg.CDXCacheTTL, "cdx-cache-ttl", cfg.CDXCacheTTL, "How long cached CDX API responses are reused; 0 disables the cache")
+	fs.IntVar(&cfg.CDXConcurrency, "cdx-concurrency", cfg.CDXConcurrency, "Maximum CDX API queries in flight across the URLs of a run")
+	fs.StringVar(&cfg.ResultFile, "result-file", cfg.ResultFile, "Write the results of the run as JSON to this file, with the outcome of every URL, the bytes received, the files created, and warnings (- for standard output)")
+	fs.StringVar(&cfg.StorageURL, "storage", cfg.StorageURL, "Write captures directly to remote storage instead of the output directory, e.g. s3://bucket/prefix")
+	fs.StringVar(&cfg.S3Endpoint, "s3-endpoint", cfg.S3Endpoint, "Endpoint of an S3-compatible service for --storage (default: AWS)")
+	fs.StringVar(&cfg.S3Region, "s3-region", cfg.S3Region, "Region of the S3 bucket for --storage (default: us-east-1)")
+	fs.StringVar(&cfg.FrontierDir, "frontier-dir", cfg.FrontierDir, "Keep the crawl frontier in a database in this directory so crawls survive restarts")
+	fs.IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "Number of fetch workers of a crawl")
+	fs.IntVar(&cfg.FrontierWorkers, "frontier-workers", cfg.FrontierWorkers, "Number of fetch workers draining the persistent frontier (default: --concurrency)")
+	authFlags(fs, cfg)
+	fs.Func("remap", "URL rewrite rule 'pattern=>replacement' applied during link conversion (repeatable)", func(value string) error {
+		cfg.RemapRules = append(cfg.RemapRules, value)
+		return nil
+	})
+	fs.StringVar(&cfg.RemapFile, "remap-file", cfg.RemapFile, "File of URL rewrite rules, one 'pattern=>replacement' per line")
+	fs.Func("depth-boost", "Depth change 'pattern=+N' for the pages matching a glob like /docs/** (repeatable)", func(value string) error {
+		cfg.DepthBoosts = append(cfg.DepthBoosts, value)
+		return nil
+	})
+	fs.StringVar(&cfg.DepthBoostFile, "depth-boost-file", cfg.DepthBoostFile, "File of depth boosts, one 'pattern=+N' per line")
+	fs.StringVar(&cfg.RestrictFileNames, "restrict-file-names", cfg.RestrictFileNames, "Escape file name characters like wget: unix, windows, ascii, lowercase, uppercase, nocontrol (comma-separated)")
+	fs.BoolVar(&cfg.KeepRaw, "keep-raw", cfg.KeepRaw, "Keep the downloaded files after creating a ZIM file, so the rezim subcommand can rebuild it")
+	fs.BoolVar(&cfg.Torrent, "torrent", cfg.Torrent, "Create a .torrent of the finished archive, the ZIM file or else the capture directory, next to it")
+	fs.Func("tracker", "Announce URL of a tracker written to created torrents; comma-separated (repeatable)", func(value string) error {
+		cfg.TorrentTrackers = append(cfg.TorrentTrackers, strings.Split(value, ",")...)
+		return nil
+	})
+	fs.Func("webseed", "URL the archive is published at over HTTP or S3, written to created torrents as a web seed; comma-separated (repeatable)", func(value string) error {
+		cfg.TorrentWebSeeds = append(cfg.TorrentWebSeeds, strings.Split(value, ",")...)
+		return nil
+	})
+	fs.Func("chunk-threshold", "Store files of at least this size as deduplicated content-defined chunks (e.g. 8M)", func(value string) error {
+		size, err := config.ParseSize(value)
+		cfg.ChunkThreshold = size
+		return err
+	})
+	fs.BoolVar(&cfg.Hardlink, "hardlink", cfg.Hardlink, "Hardlink files identical to ones of earlier captures instead of storing them again")
+	fs.BoolVar(&cfg.ArchivedAtMeta, "archived-at-meta", cfg.ArchivedAtMeta, "Add <meta name=\"archived-at\"> with the fetch time to saved HTML pages")
+	fs.BoolVar(&cfg.Banner, "banner", cfg.Banner, "Add a dismissible banner naming the original URL and capture date to saved HTML pages")
+	fs.BoolVar(&cfg.TimeTravel, "time-travel", cfg.TimeTravel, "Add a bar linking to the same page in the other snapshots to the pages of multi-snapshot archives")
+	fs.BoolVar(&cfg.NoIndex, "noindex", cfg.NoIndex, "Add <meta name=\"robots\" content=\"noindex\"> to saved HTML pages, for publicly hosted mirrors")
+	fs.BoolVar(&cfg.Canonical, "canonical", cfg.Canonical, "Add a rel=canonical link to the original URL to saved HTML pages")
+	fs.BoolVar(&cfg.SitemapSeeds, "sitemap-seeds", cfg.SitemapSeeds, "Queue every page listed in the site's sitemap.xml (or the sitemaps named in robots.txt) before crawling")
+	fs.BoolVar(&cfg.RespectRobots, "respect-robots", cfg.RespectRobots, "Honor robots.txt Disallow rules and Crawl-delay of every host, logging skipped URLs")
+	fs.StringVar(&cfg.Srcset, "srcset", cfg.Srcset, "Responsive image candidates to download from srcset: all or largest")
+	fs.BoolVar(&cfg.Sitemap, "sitemap", cfg.Sitemap, "Write archive-sitemap.xml and a browsable archive-sitemap.html built from the crawl graph")
+	fs.BoolVar(&cfg.SearchPage, "search-page", cfg.SearchPage, "Write archive-search.html, an offline full-text search page for the capture")
+	fs.BoolVar(&cfg.Screenshots, "screenshots", cfg.Screenshots, "Screenshot pages with headless Chromium and write archive-gallery.html of their thumbnails")
+	fs.IntVar(&cfg.ScreenshotLimit, "screenshot-limit", cfg.ScreenshotLimit, "Screenshot at most this many pages (0 = all)")
+	fs.BoolVar(&cfg.OfflineReplayTest, "offline-replay-test", cfg.OfflineReplayTest, "After the capture, load every page in headless Chromium without network access and report the resources that fail to load")
+	fs.BoolVar(&cfg.A11yReport, "a11y-report", cfg.A11yReport, "Run basic accessibility checks on captured pages and write .website-archiver/accessibility-report.json")
+	fs.StringVar(&cfg.IPFSGateway, "ipfs-gateway", cfg.IPFSGateway, "HTTP gateway used to fetch ipfs:// and ipns:// URLs")
+	fs.BoolVar(&cfg.LegacyProtocols, "legacy-protocols", cfg.LegacyProtocols, "Fetch ftp:// and gopher:// URLs given as seeds or linked from pages")
+	fs.BoolVar(&cfg.Fidelity, "fidelity", cfg.Fidelity, "Store pages byte-for-byte and record link rewrites in .website-archiver/rewrites.json for the replay subcommand")
+	fs.BoolVar(&cfg.KeepContentEncoding, "keep-content-encoding", cfg.KeepContentEncoding, "Save files other than pages, feeds, and stylesheets with the gzip, deflate, or zstd coding they were served with, recorded in the manifest, instead of decoded")
+	fs.StringVar(&cfg.Preset, "preset", cfg.Preset, "Apply a site preset (docs: GitHub Pages, GitLab Pages, Read the Docs)")
+	fs.StringVar(&cfg.DocVersions, "doc-versions", cfg.DocVersions, "Documentation versions to archive with the docs preset (latest|all)")
+}
+
+// validateAndParseArgs validates URLs and parses command line arguments
+func validateAndParseArgs(cfg *config.Config) (urls []string, depth int, createZim bool, allSnapshots bool, specificSnapshot string, noJs bool, noCss bool, err error) {
+	var opts runOptions
+	runFlags(flag.CommandLine, &opts)
+	configFlags(flag.CommandLine, cfg)
+	torFlags(flag.CommandLine, cfg)
+	debugFlags(flag.CommandLine, cfg)
+
+	profileFlags, save, rest, err := profile.Expand(os.Args[pkg.OneIndex:])
+	if err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	if err := flag.CommandLine.Parse(append(profileFlags, rest...)); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	if save != pkg.EmptyString {
+		flags := append(append([]string{}, profileFlags...), rest[:len(rest)-flag.NArg()]...)
+		if err := profile.Save(save, flags, cfg.DirPerms, cfg.FilePerms); err != nil {
+			return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+		}
+		slog.Info("Profile saved", "profile", save, "flags", len(flags))
+		if flag.NArg() == pkg.ZeroLength {
+			return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, errProfileSaved
+		}
+	}
+
+	if err := preset.Validate(cfg.Preset, cfg.DocVersions); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
 
 	args := flag.Args()
+	if cfg.Resume != pkg.EmptyString {
+		if len(args) > pkg.ZeroLength || opts.allSnapshots || opts.specificSnapshot != pkg.EmptyString {
+			return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, fmt.Errorf("--resume takes the URL and depth from the crawl state; pass no URLs, --all-snapshots, or --snapshot")
+		}
+		state, err := downloader.LoadState(cfg.Resume, cfg)
+		if err != nil {
+			return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, fmt.Errorf("nothing to resume in %s: %w", cfg.Resume, err)
+		}
+		return []string{state.Seed}, state.Depth, opts.createZim, false, pkg.EmptyString, opts.noJs, opts.noCss, nil
+	}
 	if len(args) < pkg.OneLength {
 		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, fmt.Errorf("no URLs provided")
 	}
@@ -522,23 +1261,243 @@ func validateAndParseArgs() (urls []string, depth int, createZim bool, allSnapsh
 		urls = args
 	}
 
-	for _, url := range urls {
-		if err := validateURL(url); err != nil {
+	for i, url := range urls {
+		if cfg.Tor {
+			url = onion.WithScheme(url)
+			urls[i] = url
+		}
+		if err := validateURL(url, cfg.LegacyProtocols); err != nil {
 			return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, fmt.Errorf("invalid URL %s: %w", url, err)
 		}
 	}
+	if cfg.Tor && (opts.allSnapshots || opts.specificSnapshot != pkg.EmptyString || cfg.WaybackContinue != pkg.EmptyString) {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, fmt.Errorf("--tor disables the Wayback Machine; drop --all-snapshots, --snapshot, and --wayback-continue")
+	}
+	if cfg.WaybackContinue != pkg.EmptyString && (len(urls) != pkg.OneLength || opts.allSnapshots) {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, fmt.Errorf("--wayback-continue continues a single capture; pass only its URL and no --all-snapshots")
+	}
+	if cfg.Update != pkg.EmptyString {
+		if len(urls) != pkg.OneLength || opts.allSnapshots || opts.specificSnapshot != pkg.EmptyString || cfg.WaybackContinue != pkg.EmptyString {
+			return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, fmt.Errorf("--update archives a single capture again; pass only its URL and no --all-snapshots, --snapshot, or --wayback-continue")
+		}
+		if _, err := manifest.Load(cfg.Update); err != nil {
+			return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, fmt.Errorf("nothing to update in %s: %w", cfg.Update, err)
+		}
+	}
+	if cfg.StorageURL != pkg.EmptyString && (opts.createZim || opts.allSnapshots || cfg.Torrent) {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, fmt.Errorf("--zim, --all-snapshots, and --torrent need the capture on local disk and cannot be used with --storage")
+	}
+	if _, err := mimefilter.New(cfg.AcceptMIME, cfg.RejectMIME); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	if _, err := urlfilter.New(cfg.IncludePatterns, cfg.ExcludePatterns); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	if _, err := urlnorm.New(cfg.DropParams, cfg.StripTracking, cfg.StripTrailingSlash); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	if _, err := errorpages.New(cfg.ErrorPages, cfg.ErrorStatuses); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	if _, err := referrer.New(cfg.ReferrerPolicy, cfg.Referer); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	if cfg.BrowserProfile != pkg.EmptyString {
+		if _, err := browserprofile.Lookup(cfg.BrowserProfile); err != nil {
+			return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+		}
+	}
+	if err := challenge.CheckMode(cfg.Challenge); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	if err := paywall.CheckMode(cfg.PaywallFallback); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	if _, err := depthboost.Load(cfg.DepthBoosts, cfg.DepthBoostFile); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	if err := config.CheckZIMEngine(cfg.ZIMEngine); err != nil {
+		return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+	}
+	for _, alias := range cfg.HostAliases {
+		if err := config.CheckHostAlias(alias); err != nil {
+			return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, err
+		}
+	}
+	if cfg.WaybackContinue != pkg.EmptyString {
+		if _, err := downloader.LoadContinuation(cfg.WaybackContinue, cfg); err != nil {
+			return nil, pkg.ZeroDepth, false, false, pkg.EmptyString, false, false, fmt.Errorf("nothing to continue in %s: %w", cfg.WaybackContinue, err)
+		}
+	}
+
+	return urls, depth, opts.createZim, opts.allSnapshots, opts.specificSnapshot, opts.noJs, opts.noCss, nil
+}
+
+// errProfileSaved is returned when the command line only saved a profile.
+var errProfileSaved = errors.New("profile saved")
+
+// errNoWaybackCaptures is returned when snapshots of a URL the Wayback Machine
+// cannot have are requested.
+var errNoWaybackCaptures = errors.New("the Wayback Machine does not archive onion services or IPFS URLs")
+
+// hasWaybackCaptures reports whether the Wayback Machine can have captures of
+// url. It does not capture onion services or ipfs:// and ipns:// URLs.
+func hasWaybackCaptures(url string) bool {
+	parsedURL, err := neturl.Parse(url)
+	if err != nil {
+		return true
+	}
+	return !onion.IsOnion(parsedURL.Hostname()) && !ipfs.Supports(parsedURL.Scheme)
+}
+
+// isOnionURL reports whether url points at a Tor onion service.
+func isOnionURL(url string) bool {
+	parsedURL, err := neturl.Parse(url)
+	return err == nil && onion.IsOnion(parsedURL.Hostname())
+}
+
+// authFlags registers the per-host credential flags on fs
+func authFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.CookiesFile, "cookies-file", cfg.CookiesFile, "Netscape cookies.txt file, as exported from a browser, whose cookies are sent with every request")
+	fs.Func("auth", "Authorization for a host as 'host=<secret reference>', e.g. example.com=keyring:website-archiver/example.com or example.com=age:secrets.age#EXAMPLE (repeatable)", func(value string) error {
+		cfg.AuthHeaders = append(cfg.AuthHeaders, value)
+		return nil
+	})
+	fs.Func("auth-cookie", "Cookie for a host as 'host=<secret reference>' (repeatable)", func(value string) error {
+		cfg.AuthCookies = append(cfg.AuthCookies, value)
+		return nil
+	})
+	fs.Func("header", "Header sent with every request as 'Name: value' (repeatable)", func(value string) error {
+		cfg.Headers = append(cfg.Headers, value)
+		return nil
+	})
+	fs.StringVar(&cfg.UserAgent, "user-agent", cfg.UserAgent, "User-Agent sent with every request")
+	fs.StringVar(&cfg.UserAgentFile, "user-agent-file", cfg.UserAgentFile, "File of User-Agents, one per line, used in turn for each request instead of --user-agent")
+	fs.StringVar(&cfg.User, "user", cfg.User, "User name for HTTP Basic authentication, sent with every request")
+	fs.StringVar(&cfg.Password, "password", cfg.Password, "Password for HTTP Basic authentication; prefer HTTP_PASSWORD, which stays out of the process list")
+	fs.StringVar(&cfg.ReferrerPolicy, "referrer-policy", cfg.ReferrerPolicy, "Referer, Origin, and Sec-Fetch-* headers of crawl requests: none, browser (what a browser following the links would send), or custom (--referer with every request)")
+	fs.StringVar(&cfg.Referer, "referer", cfg.Referer, "Referer sent with every request with --referrer-policy custom")
+	fs.StringVar(&cfg.Challenge, "challenge", cfg.Challenge, "Anti-bot challenges (Cloudflare and the like): skip them, or pause for you to pass them and import the cookies of your browser (cookies) or of a Chromium window (browser)")
+	fs.StringVar(&cfg.BrowserProfile, "browser-profile", cfg.BrowserProfile, "Send the User-Agent, Accept, and client hint headers of a browser: "+strings.Join(browserprofile.Names(), ", "))
+}
+
+// setupAuth resolves the configured per-host credentials, headers, Basic
+// credentials, and User-Agents and adds them to every request
+func setupAuth(cfg *config.Config) error {
+	if cfg.UserAgentFile != pkg.EmptyString {
+		agents, err := useragent.LoadFile(cfg.UserAgentFile)
+		if err != nil {
+			return err
+		}
+		cfg.UserAgents = agents
+		slog.Debug("Rotating User-Agents", "agents", agents.Len(), "file", cfg.UserAgentFile)
+	}
+	if cfg.CookiesFile != pkg.EmptyString {
+		jar, err := cookies.LoadFile(cfg.CookiesFile)
+		if err != nil {
+			return err
+		}
+		cfg.CookieJar = jar
+	}
+	if cfg.BrowserProfile != pkg.EmptyString {
+		profile, err := browserprofile.Lookup(cfg.BrowserProfile)
+		if err != nil {
+			return err
+		}
+		// An explicit --user-agent or agent file still wins
+		if cfg.UserAgent == useragent.Default && cfg.UserAgentFile == pkg.EmptyString {
+			cfg.UserAgent = profile.UserAgent
+		}
+		// Added before the --header values so those take precedence
+		cfg.Transport = &browserprofile.Transport{Next: cfg.Transport, Profile: profile}
+	}
+	var rules []auth.Rule
+	for _, set := range []struct {
+		kind  string
+		specs []string
+	}{{auth.KindAuthorization, cfg.AuthHeaders}, {auth.KindCookie, cfg.AuthCookies}} {
+		for _, spec := range set.specs {
+			rule, err := auth.ParseRule(set.kind, spec)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+	}
+	if len(cfg.Headers) > pkg.ZeroLength || cfg.User != pkg.EmptyString {
+		header := make(http.Header)
+		for _, spec := range cfg.Headers {
+			name, value, err := auth.ParseHeader(spec)
+			if err != nil {
+				return err
+			}
+			header.Add(name, value)
+		}
+		// Per-host credentials are added first and take precedence
+		cfg.Transport = &auth.Headers{Next: cfg.Transport, Header: header, User: cfg.User, Password: cfg.Password}
+	}
+	if len(rules) == pkg.ZeroLength {
+		return nil
+	}
+	cfg.Transport = &auth.Transport{Next: cfg.Transport, Rules: rules}
+	return nil
+}
 
-	return urls, depth, createZim, allSnapshots, specificSnapshot, noJs, noCss, nil
+// setupOnion prepares the transport for onion seeds, which must go through a
+// Tor proxy and whose certificates are not verified against public CAs. With
+// --tor every request goes through the Tor proxy instead of the environment's.
+func setupOnion(urls []string, cfg *config.Config) error {
+	if cfg.Tor {
+		if cfg.LegacyProtocols {
+			return errors.New("--legacy-protocols cannot be used with --tor; FTP and Gopher connections would bypass Tor")
+		}
+		if err := onion.CheckTor(cfg.TorProxy, cfg.HTTPTimeout); err != nil {
+			return err
+		}
+		// Wayback patching would fetch the clearnet archive for onion pages
+		cfg.WaybackPatch = false
+		cfg.PaywallFallback = paywall.None
+		cfg.Transport = onion.NewTransport(onion.ProxyTransport(cfg.NewTransport(), cfg.TorProxy))
+		slog.Info("Routing requests through Tor", "proxy", cfg.TorProxy)
+		return nil
+	}
+	hasOnion := false
+	for _, url := range urls {
+		if !isOnionURL(url) {
+			continue
+		}
+		parsedURL, _ := neturl.Parse(url)
+		if err := onion.CheckProxy(parsedURL); err != nil {
+			return err
+		}
+		hasOnion = true
+	}
+	if hasOnion {
+		cfg.Transport = onion.NewTransport(cfg.Transport)
+	}
+	return nil
 }
 
-// processResults processes download results and prints a summary
-func processResults(results <-chan DownloadResult, totalURLs int) {
+// processResults processes download results, prints a summary, and returns
+// the reports of the downloads
+func processResults(results <-chan DownloadResult, totalURLs int) []*result.Result {
 	successCount := pkg.ZeroCount
-	for result := range results {
-		if result.Error != nil {
-			slog.Error("Failed to download", pkg.LogError, result.Error, pkg.LogURL, result.URL)
+	var reports []*result.Result
+	for res := range results {
+		if res.Report != nil {
+			reports = append(reports, res.Report)
+		}
+		var waybackErr *wayback.Error
+		if errors.Is(res.Error, downloader.ErrInterrupted) {
+			slog.Warn("Interrupted, the crawl can be resumed", pkg.LogURL, res.URL, "resume", "--resume "+res.OutputDir)
+		} else if errors.Is(res.Error, wayback.ErrQuota) {
+			slog.Warn("Skipped, the Wayback Machine quota of this run is used up", pkg.LogURL, res.URL)
+		} else if errors.As(res.Error, &waybackErr) {
+			slog.Error("The Wayback Machine refused the capture", "reason", waybackErr.Reason.Error(), "status", waybackErr.StatusCode, "hint", waybackErr.Hint(), pkg.LogURL, res.URL, fetcherr.Attr(res.Error))
+		} else if res.Error != nil {
+			slog.Error("Failed to download", pkg.LogError, res.Error, pkg.LogURL, res.URL, fetcherr.Attr(res.Error))
 		} else {
-			slog.Info("Successfully downloaded", pkg.LogURL, result.URL, "outputDir", result.OutputDir)
+			slog.Info("Successfully downloaded", pkg.LogURL, res.URL, "outputDir", res.OutputDir)
 			successCount++
 		}
 	}
@@ -548,6 +1507,44 @@ func processResults(results <-chan DownloadResult, totalURLs int) {
 		"successful", successCount,
 		"failed", totalURLs-successCount,
 	)
+	return reports
+}
+
+// writeResults writes the reports of the run as JSON to the result file, or
+// to standard output when it is "-"
+func writeResults(reports []*result.Result, cfg *config.Config) error {
+	if cfg.ResultFile == "-" {
+		return result.Write(os.Stdout, reports)
+	}
+	var buf bytes.Buffer
+	if err := result.Write(&buf, reports); err != nil {
+		return err
+	}
+	if err := os.WriteFile(cfg.ResultFile, buf.Bytes(), cfg.FilePerms); err != nil {
+		return fmt.Errorf("failed to write result file: %w", err)
+	}
+	return nil
+}
+
+// reportBandwidth logs the traffic of the run and stores it as a JSON cost report
+func reportBandwidth(meter *bandwidth.Meter, cfg *config.Config) {
+	report := meter.Report(cfg.CostPerGB)
+	for _, host := range report.Hosts {
+		slog.Info("Bandwidth by host", "host", host.Name, "bytes", host.Bytes, "cost", host.Cost)
+	}
+	for _, contentType := range report.Types {
+		slog.Info("Bandwidth by content type", "contentType", contentType.Name, "bytes", contentType.Bytes, "cost", contentType.Cost)
+	}
+	slog.Info("Bandwidth Summary", "totalBytes", report.TotalBytes, "costPerGB", report.CostPerGB, "totalCost", report.TotalCost)
+
+	if err := os.MkdirAll(cfg.OutputDir, cfg.DirPerms); err != nil {
+		slog.Warn("Failed to create output directory for bandwidth report", pkg.LogError, err)
+		return
+	}
+	reportFile := filepath.Join(cfg.OutputDir, fmt.Sprintf("bandwidth_%s.json", time.Now().Format("20060102_150405")))
+	if err := report.Write(reportFile, cfg.FilePerms); err != nil {
+		slog.Warn("Failed to write bandwidth report", pkg.LogError, err)
+	}
 }
 
 // main is the entry point of the program. It parses command-line arguments,
@@ -556,23 +1553,70 @@ func main() {
 	// Initialize configuration
 	cfg := config.New()
 
-	urls, depth, createZim, allSnapshots, specificSnapshot, noJs, noCss, err := validateAndParseArgs()
+	if len(os.Args) > pkg.OneLength {
+		if run, ok := subcommands[os.Args[pkg.OneIndex]]; ok {
+			if err := startDiagnostics(cfg); err != nil {
+				slog.Error("Failed to serve diagnostics", pkg.LogError, err)
+				os.Exit(pkg.ExitFailure)
+			}
+			cfg.Transport = cfg.NewTransport()
+			if err := run(context.Background(), cfg, os.Args[pkg.OneIndex+pkg.OneLength:]); err != nil {
+				slog.Error("Command failed", "command", os.Args[pkg.OneIndex], pkg.LogError, err)
+				os.Exit(pkg.ExitFailure)
+			}
+			os.Exit(pkg.ExitSuccess)
+		}
+	}
+
+	urls, depth, createZim, allSnapshots, specificSnapshot, noJs, noCss, err := validateAndParseArgs(cfg)
+	if errors.Is(err, errProfileSaved) {
+		os.Exit(pkg.ExitSuccess)
+	}
 	if err != nil {
 		slog.Error("Failed to parse arguments", pkg.LogError, err)
-		fmt.Println("Usage: website-archiver [--zim|-z] [--all-snapshots|-as] [--snapshot|-s YYYYMMDDHHMMSS] <url1> [url2] [url3] ... [depth]")
+		fmt.Println("Usage: website-archiver [--profile name] [--save-profile name] [--zim|-z] [--all-snapshots|-as] [--snapshot|-s YYYYMMDDHHMMSS] <url1> [url2] [url3] ... [depth]")
 		fmt.Println("Example: website-archiver --zim --all-snapshots https://example.com")
 		fmt.Println("Example: website-archiver --zim --snapshot 20230101000000 https://example.com")
 		os.Exit(pkg.ExitFailure)
 	}
 
-	if createZim {
+	if cfg.ResultFile == "-" {
+		// Standard output carries the results, so the log moves out of the way
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: cfg.LogLevel})))
+	}
+	if err := startDiagnostics(cfg); err != nil {
+		slog.Error("Failed to serve diagnostics", pkg.LogError, err)
+		os.Exit(pkg.ExitFailure)
+	}
+	if createZim && cfg.ZIMEngine == config.ZIMEngineExternal {
 		if _, err := exec.LookPath("zimwriterfs"); err != nil {
 			slog.Error("zimwriterfs not found in PATH", pkg.LogError, err)
 			os.Exit(pkg.ExitFailure)
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPTimeout*time.Duration(len(urls)))
+	cfg.Transport = cfg.NewTransport()
+	if err := setupOnion(urls, cfg); err != nil {
+		slog.Error("Cannot archive onion services", pkg.LogError, err)
+		os.Exit(pkg.ExitFailure)
+	}
+	if err := setupAuth(cfg); err != nil {
+		slog.Error("Failed to load credentials", pkg.LogError, err)
+		os.Exit(pkg.ExitFailure)
+	}
+	if cfg.LegacyProtocols {
+		cfg.Transport = &legacy.Transport{Next: cfg.Transport, Timeout: cfg.HTTPTimeout}
+	}
+	if cfg.WaybackMaxBytes > pkg.ZeroValue || cfg.WaybackMaxRequests > pkg.ZeroValue {
+		cfg.Transport = &wayback.Quota{Next: cfg.Transport, MaxBytes: cfg.WaybackMaxBytes, MaxRequests: cfg.WaybackMaxRequests}
+	}
+	meter := bandwidth.NewMeter(cfg.Transport)
+	cfg.Transport = meter
+
+	// An interrupt stops the crawls gracefully, so their state is saved
+	interrupted, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(interrupted, cfg.HTTPTimeout*time.Duration(len(urls)))
 	defer cancel()
 
 	results := make(chan DownloadResult, len(urls))
@@ -591,6 +1635,18 @@ func main() {
 		close(results)
 	}()
 
-	processResults(results, len(urls))
+	reports := processResults(results, len(urls))
+	reportBandwidth(meter, cfg)
+	if cfg.ResultFile != pkg.EmptyString {
+		if err := writeResults(reports, cfg); err != nil {
+			slog.Warn("Failed to write results", pkg.LogError, err, "file", cfg.ResultFile)
+		}
+	}
+
+	if policy := retentionPolicy(cfg); policy.Enabled() {
+		if err := pruneCaptures(cfg, policy, false); err != nil {
+			slog.Warn("Failed to apply retention policy", pkg.LogError, err)
+		}
+	}
 	os.Exit(pkg.ExitSuccess)
 }
@@ -13,6 +13,13 @@ const (
 	// HTTPTimeout is the default timeout for HTTP requests
 	HTTPTimeout = 30 * time.Second
 
+	// Day is the length of a day for retention policies
+	Day = 24 * time.Hour
+
+	// ShutdownTimeout is how long built-in servers wait for requests in
+	// flight when they stop
+	ShutdownTimeout = 10 * time.Second
+
 	// DirPerms is the default directory permissions in octal
 	DirPerms = 0750
 
@@ -26,14 +33,33 @@ const (
 	DefaultPNG = "default.png"
 	// IndexHTML is the name of the index HTML file
 	IndexHTML = "index.html"
-	// ConvertCmd is the name of the image conversion command
-	ConvertCmd = "convert"
-	// ResizeFlag is the flag used for image resizing
-	ResizeFlag = "-resize"
-	// ResizeSize is the target size for image resizing
-	ResizeSize = "48x48"
+	// ChangesJSON is the name of the snapshot change report
+	ChangesJSON = "changes.json"
+	// IllustrationSize is the width and height of ZIM illustrations in pixels
+	IllustrationSize = 48
 	// EmptyString represents an empty string
 	EmptyString = ""
+	// KiwixServeCmd is the name of the Kiwix ZIM server command
+	KiwixServeCmd = "kiwix-serve"
+	// DefaultServeAddr is the default listen address for built-in servers
+	DefaultServeAddr = "127.0.0.1:8080"
+	// DefaultSeedAddr is the default listen address of the torrent seeder,
+	// which peers on other machines must reach
+	DefaultSeedAddr = ":6881"
+	// DefaultProxyAddr is the default listen address of the recording proxy
+	DefaultProxyAddr = "127.0.0.1:8081"
+	// ProxyCADir is the directory inside the output directory holding the recording proxy CA
+	ProxyCADir = ".proxy-ca"
+	// ProxyQueueSize is the number of browsed pages the archiving proxy queues
+	ProxyQueueSize = 100
+	// DefaultChangeThreshold is the relative size difference at which a live resource counts as changed
+	DefaultChangeThreshold = 0.2
+	// TabWidth is the minimal cell width of table output
+	TabWidth = 4
+	// TabPadding is the padding between columns of table output
+	TabPadding = 2
+	// ZIMSampleSize is the number of random ZIM entries read back during validation
+	ZIMSampleSize = 20
 
 	// Log field names
 	// LogError is the field name for error logging
@@ -106,6 +132,8 @@ const (
 	ZeroLength = 0
 	// OneLength represents a length of one
 	OneLength = 1
+	// TwoLength represents a length of two
+	TwoLength = 2
 	// ZeroCount represents a count of zero
 	ZeroCount = 0
 	// OneCount represents a count of one